@@ -0,0 +1,40 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Loading of arbitrary discovery configurations to analyze them, including the elements outside of the definitions
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+// LoadAnyConfiguration reads a discovery configuration not generated by this tool; the specifics, ranges and URLs
+// declared outside of the definitions (supported by OpenNMS) are moved into a definition at the beginning.
+func LoadAnyConfiguration(fileName string) (*discovery.DiscoveryConfiguration, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	cfg := new(discovery.DiscoveryConfiguration)
+	if err := xml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %v", fileName, err)
+	}
+	topLevel := discovery.Definition{}
+	if err := xml.Unmarshal(data, &struct {
+		XMLName       xml.Name                  `xml:"discovery-configuration"`
+		Specifics     *[]discovery.Specific     `xml:"specific"`
+		IncludeRanges *[]discovery.IncludeRange `xml:"include-range"`
+		ExcludeRanges *[]discovery.ExcludeRange `xml:"exclude-range"`
+		IncludeURLs   *[]discovery.IncludeURL   `xml:"include-url"`
+	}{Specifics: &topLevel.Specifics, IncludeRanges: &topLevel.IncludeRanges, ExcludeRanges: &topLevel.ExcludeRanges, IncludeURLs: &topLevel.IncludeURLs}); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %v", fileName, err)
+	}
+	if !topLevel.IsEmpty() || len(topLevel.ExcludeRanges) > 0 {
+		cfg.Definitions = append([]discovery.Definition{topLevel}, cfg.Definitions...)
+	}
+	return cfg, nil
+}
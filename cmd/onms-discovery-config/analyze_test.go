@@ -0,0 +1,47 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadAnyConfiguration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "analyze")
+	if err != nil {
+		t.Fatalf("cannot create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "discovery-configuration.xml")
+	ioutil.WriteFile(fileName, []byte(`<?xml version="1.0"?>
+<discovery-configuration xmlns="http://xmlns.opennms.org/xsd/config/discovery" packets-per-second="1" initial-sleep-time="30000" restart-sleep-time="86400000" retries="1" timeout="2000">
+  <include-range>
+    <begin>192.168.0.1</begin>
+    <end>192.168.0.254</end>
+  </include-range>
+  <specific>10.0.0.1</specific>
+  <definition location="Lab">
+    <specific>10.1.0.1</specific>
+  </definition>
+</discovery-configuration>`), 0644)
+
+	cfg, err := LoadAnyConfiguration(fileName)
+	if err != nil {
+		t.Fatalf("cannot load configuration: %v", err)
+	}
+	if len(cfg.Definitions) != 2 {
+		t.Fatalf("there should be 2 definitions: %s", cfg.String())
+	}
+	if top := cfg.Definitions[0]; len(top.IncludeRanges) != 1 || len(top.Specifics) != 1 || top.Location != "" {
+		t.Errorf("unexpected top-level definition: %+v", top)
+	}
+	if cfg.Definitions[1].Location != "Lab" {
+		t.Errorf("unexpected definition: %+v", cfg.Definitions[1])
+	}
+	if _, err := LoadAnyConfiguration(filepath.Join(dir, "missing.xml")); err == nil {
+		t.Errorf("the configuration should not exist")
+	}
+}
@@ -0,0 +1,285 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// JSON query API of the daemon over the generated configurations, for portals that embed views of the discovery scope
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+// DefaultKeepGenerations is the number of generated configurations the daemon keeps in memory
+const DefaultKeepGenerations = 10
+
+// ModelGeneration is a configuration generated by the daemon
+type ModelGeneration struct {
+	ID      int                               `json:"id"`
+	Time    time.Time                         `json:"time"`
+	Variant string                            `json:"variant"`
+	Config  *discovery.DiscoveryConfiguration `json:"-"`
+}
+
+// GenerationSummary summarizes a generation for the API
+type GenerationSummary struct {
+	*ModelGeneration
+	Definitions        int    `json:"definitions"`
+	EstimatedAddresses string `json:"estimated-addresses"`
+}
+
+// DefinitionView is a definition with its key and the estimated number of addresses it sweeps
+type DefinitionView struct {
+	Key                string `json:"key"`
+	EstimatedAddresses string `json:"estimated-addresses"`
+	*discovery.Definition
+}
+
+// RangeView is a specific, an include range or an exclude range of a definition
+type RangeView struct {
+	Definition    string `json:"definition"`
+	Type          string `json:"type"` // specific, include or exclude
+	Begin         string `json:"begin"`
+	End           string `json:"end"`
+	Location      string `json:"location,omitempty"`
+	ForeignSource string `json:"foreign-source,omitempty"`
+}
+
+// LookupResult tells which definitions discover an address, and which ones exclude it
+type LookupResult struct {
+	IP         string   `json:"ip"`
+	Generation int      `json:"generation"`
+	Covered    bool     `json:"covered"`
+	CoveredBy  []string `json:"covered-by"`
+	ExcludedBy []string `json:"excluded-by"`
+}
+
+// DiffChange is a difference between two generations; the operation is +, - or ~
+type DiffChange struct {
+	Operation string `json:"op"`
+	Change    string `json:"change"`
+}
+
+// ModelServer keeps the last generations of the daemon and serves them through the query API under /api
+type ModelServer struct {
+	keep        int
+	generations []*ModelGeneration // Oldest first
+	lastID      int
+	mutex       sync.RWMutex
+}
+
+// NewModelServer creates a server that keeps the given number of generations
+func NewModelServer(keep int) *ModelServer {
+	if keep < 2 { // The diff needs at least two
+		keep = 2
+	}
+	return &ModelServer{keep: keep}
+}
+
+// Add registers a new generation, discarding the oldest one when there are too many
+func (m *ModelServer) Add(cfg *discovery.DiscoveryConfiguration, variant string) *ModelGeneration {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.lastID++
+	g := &ModelGeneration{ID: m.lastID, Time: time.Now(), Variant: variant, Config: cfg}
+	m.generations = append(m.generations, g)
+	if len(m.generations) > m.keep {
+		m.generations = m.generations[len(m.generations)-m.keep:]
+	}
+	return g
+}
+
+// Generation returns a generation by ID, or the latest when the ID is 0
+func (m *ModelServer) Generation(id int) *ModelGeneration {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if len(m.generations) == 0 {
+		return nil
+	}
+	if id == 0 {
+		return m.generations[len(m.generations)-1]
+	}
+	for _, g := range m.generations {
+		if g.ID == id {
+			return g
+		}
+	}
+	return nil
+}
+
+// Generations returns the generations kept, oldest first
+func (m *ModelServer) Generations() []*ModelGeneration {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return append([]*ModelGeneration{}, m.generations...)
+}
+
+// generationParam returns the generation from a query parameter, the latest when it is absent
+func (m *ModelServer) generationParam(r *http.Request, name string) (*ModelGeneration, error) {
+	id := 0
+	if value := r.URL.Query().Get(name); value != "" {
+		var err error
+		if id, err = strconv.Atoi(value); err != nil || id < 1 {
+			return nil, fmt.Errorf("invalid %s %s", name, value)
+		}
+	}
+	if g := m.Generation(id); g != nil {
+		return g, nil
+	}
+	if id == 0 {
+		return nil, fmt.Errorf("there are no generations yet")
+	}
+	return nil, fmt.Errorf("generation %d is not available", id)
+}
+
+// matches returns true when the definition matches the location and foreign-source query parameters
+func matches(r *http.Request, def *discovery.Definition) bool {
+	q := r.URL.Query()
+	if _, ok := q["location"]; ok && q.Get("location") != def.Location {
+		return false
+	}
+	if _, ok := q["foreign-source"]; ok && q.Get("foreign-source") != def.ForeignSource {
+		return false
+	}
+	return true
+}
+
+// ServeHTTP implements the query API:
+// /api/generations, /api/definitions, /api/ranges, /api/lookup?ip= and /api/diff?from=&to=
+func (m *ModelServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	var result interface{}
+	var err error
+	switch r.URL.Path {
+	case "/api/generations":
+		result = m.listGenerations()
+	case "/api/definitions":
+		result, err = m.definitions(r)
+	case "/api/ranges":
+		result, err = m.ranges(r)
+	case "/api/lookup":
+		result, err = m.lookup(r)
+	case "/api/diff":
+		result, err = m.diff(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (m *ModelServer) listGenerations() []GenerationSummary {
+	list := make([]GenerationSummary, 0)
+	for _, g := range m.Generations() {
+		list = append(list, GenerationSummary{g, len(g.Config.Definitions), g.Config.GetTotalEstimatedAddresses().String()})
+	}
+	return list
+}
+
+func (m *ModelServer) definitions(r *http.Request) ([]DefinitionView, error) {
+	g, err := m.generationParam(r, "generation")
+	if err != nil {
+		return nil, err
+	}
+	list := make([]DefinitionView, 0)
+	for i := range g.Config.Definitions {
+		def := &g.Config.Definitions[i]
+		if matches(r, def) {
+			list = append(list, DefinitionView{discovery.DefinitionKey(def), def.GetTotalEstimatedAddresses().String(), def})
+		}
+	}
+	return list, nil
+}
+
+func (m *ModelServer) ranges(r *http.Request) ([]RangeView, error) {
+	g, err := m.generationParam(r, "generation")
+	if err != nil {
+		return nil, err
+	}
+	var contains net.IP
+	if value := r.URL.Query().Get("contains"); value != "" {
+		if contains = net.ParseIP(value); contains == nil {
+			return nil, fmt.Errorf("invalid IP address %s", value)
+		}
+	}
+	list := make([]RangeView, 0)
+	for i := range g.Config.Definitions {
+		def := &g.Config.Definitions[i]
+		if !matches(r, def) {
+			continue
+		}
+		key := discovery.DefinitionKey(def)
+		add := func(kind string, begin, end net.IP, location, foreignSource string) {
+			if contains == nil || (iprange.Compare(begin, contains) <= 0 && iprange.Compare(contains, end) <= 0) {
+				list = append(list, RangeView{key, kind, begin.String(), end.String(), location, foreignSource})
+			}
+		}
+		for _, s := range def.Specifics {
+			add("specific", s.IP, s.IP, s.Location, s.ForeignSource)
+		}
+		for _, ir := range def.IncludeRanges {
+			add("include", ir.Begin, ir.End, ir.Location, ir.ForeignSource)
+		}
+		for _, er := range def.ExcludeRanges {
+			add("exclude", er.Begin, er.End, er.Location, "")
+		}
+	}
+	return list, nil
+}
+
+func (m *ModelServer) lookup(r *http.Request) (*LookupResult, error) {
+	g, err := m.generationParam(r, "generation")
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(r.URL.Query().Get("ip"))
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address '%s'", r.URL.Query().Get("ip"))
+	}
+	result := &LookupResult{IP: ip.String(), Generation: g.ID, CoveredBy: make([]string, 0), ExcludedBy: make([]string, 0)}
+	for i := range g.Config.Definitions {
+		def := &g.Config.Definitions[i]
+		if def.Covers(result.IP) {
+			result.CoveredBy = append(result.CoveredBy, discovery.DefinitionKey(def))
+		} else if def.ExcludeRangesContain(result.IP) {
+			result.ExcludedBy = append(result.ExcludedBy, discovery.DefinitionKey(def))
+		}
+	}
+	result.Covered = len(result.CoveredBy) > 0
+	return result, nil
+}
+
+func (m *ModelServer) diff(r *http.Request) (interface{}, error) {
+	to, err := m.generationParam(r, "to")
+	if err != nil {
+		return nil, err
+	}
+	var from *ModelGeneration
+	if r.URL.Query().Get("from") != "" {
+		if from, err = m.generationParam(r, "from"); err != nil {
+			return nil, err
+		}
+	} else if from = m.Generation(to.ID - 1); from == nil {
+		return nil, fmt.Errorf("the generation before %d is not available", to.ID)
+	}
+	changes := make([]DiffChange, 0)
+	for _, line := range Drift(from.Config, to.Config) {
+		changes = append(changes, DiffChange{line[:1], line[2:]})
+	}
+	return map[string]interface{}{"from": from.ID, "to": to.ID, "changes": changes}, nil
+}
@@ -0,0 +1,90 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func getAPI(t *testing.T, m *ModelServer, path string, result interface{}) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	if w.Code == http.StatusOK && result != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), result); err != nil {
+			t.Fatalf("invalid response from %s: %v", path, err)
+		}
+	}
+	return w.Code
+}
+
+func TestModelServer(t *testing.T) {
+	m := NewModelServer(2)
+	if code := getAPI(t, m, "/api/definitions", nil); code != http.StatusNotFound {
+		t.Errorf("there should be no generations yet: %d", code)
+	}
+
+	first := new(discovery.DiscoveryConfiguration)
+	paris := discovery.Definition{Location: "Paris"}
+	paris.IncludeCIDR("10.0.0.0/24")
+	paris.ExcludeCIDR("10.0.0.128/25")
+	paris.AddSpecific("192.168.0.1")
+	first.AddDefinition(paris)
+	m.Add(first, "default")
+
+	second := new(discovery.DiscoveryConfiguration)
+	paris.AddSpecific("192.168.0.2")
+	second.AddDefinition(paris)
+	second.AddDefinition(discovery.Definition{Location: "Berlin", Specifics: []discovery.Specific{}})
+	m.Add(second, "weekend")
+
+	var generations []GenerationSummary
+	getAPI(t, m, "/api/generations", &generations)
+	if len(generations) != 2 || generations[1].ID != 2 || generations[1].Variant != "weekend" || generations[1].Definitions != 2 {
+		t.Errorf("unexpected generations: %+v", generations)
+	}
+
+	var defs []DefinitionView
+	getAPI(t, m, "/api/definitions?location=Paris&generation=1", &defs)
+	if len(defs) != 1 || defs[0].Key != "location=Paris,foreign-source=" || defs[0].EstimatedAddresses != "129" || len(defs[0].Specifics) != 1 {
+		t.Errorf("unexpected definitions: %+v", defs)
+	}
+
+	var ranges []RangeView
+	getAPI(t, m, "/api/ranges?contains=10.0.0.200", &ranges)
+	if len(ranges) != 2 || ranges[0].Type != "include" || ranges[1].Type != "exclude" || ranges[1].Begin != "10.0.0.129" {
+		t.Errorf("unexpected ranges: %+v", ranges)
+	}
+
+	var lookup LookupResult
+	getAPI(t, m, "/api/lookup?ip=10.0.0.200", &lookup)
+	if lookup.Covered || lookup.Generation != 2 || len(lookup.ExcludedBy) != 1 {
+		t.Errorf("the address should be excluded: %+v", lookup)
+	}
+	getAPI(t, m, "/api/lookup?ip=192.168.0.2", &lookup)
+	if !lookup.Covered || len(lookup.CoveredBy) != 1 || lookup.CoveredBy[0] != "location=Paris,foreign-source=" {
+		t.Errorf("the address should be covered: %+v", lookup)
+	}
+	if code := getAPI(t, m, "/api/lookup?ip=bogus", nil); code != http.StatusNotFound {
+		t.Errorf("an invalid address should fail: %d", code)
+	}
+
+	var diff struct {
+		From, To int
+		Changes  []DiffChange
+	}
+	getAPI(t, m, "/api/diff", &diff)
+	if diff.From != 1 || diff.To != 2 || len(diff.Changes) != 2 || diff.Changes[0].Operation != "+" {
+		t.Errorf("unexpected diff: %+v", diff)
+	}
+
+	m.Add(first, "default")
+	if code := getAPI(t, m, "/api/diff?from=1&to=3", nil); code != http.StatusNotFound {
+		t.Errorf("the oldest generation should have been discarded: %d", code)
+	}
+}
@@ -0,0 +1,141 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Signed artifacts to separate the generation of the configuration from its deployment,
+// so the people that review and apply the changes don't have to be the ones that generate them.
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+type Artifact struct {
+	Configuration string   `json:"configuration"` // The content of discovery-configuration.xml
+	Hash          string   `json:"hash"`          // SHA-256 of the configuration
+	Summary       *Summary `json:"summary"`
+	Host          string   `json:"host"`
+	Time          string   `json:"time"`
+}
+
+type SignedArtifact struct {
+	Payload   []byte `json:"payload"` // The artifact as JSON
+	Signature []byte `json:"signature"`
+}
+
+func NewArtifact(cfg *discovery.DiscoveryConfiguration, summary *Summary) *Artifact {
+	hostname, _ := os.Hostname()
+	content := cfg.String()
+	return &Artifact{
+		Configuration: content,
+		Hash:          Checksum([]byte(content)),
+		Summary:       summary,
+		Host:          hostname,
+		Time:          time.Now().Format(time.RFC3339),
+	}
+}
+
+// Sign returns the signed artifact, using an Ed25519 private key
+func (a *Artifact) Sign(key ed25519.PrivateKey) (*SignedArtifact, error) {
+	payload, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedArtifact{Payload: payload, Signature: ed25519.Sign(key, payload)}, nil
+}
+
+// Verify checks the signature and the integrity of the artifact, returning its content
+func (s *SignedArtifact) Verify(key ed25519.PublicKey) (*Artifact, error) {
+	if !ed25519.Verify(key, s.Payload, s.Signature) {
+		return nil, fmt.Errorf("invalid signature")
+	}
+	artifact := new(Artifact)
+	if err := json.Unmarshal(s.Payload, artifact); err != nil {
+		return nil, fmt.Errorf("invalid artifact: %v", err)
+	}
+	if Checksum([]byte(artifact.Configuration)) != artifact.Hash {
+		return nil, fmt.Errorf("the configuration doesn't match the hash of the artifact")
+	}
+	return artifact, nil
+}
+
+// Config returns the discovery configuration of the artifact
+func (a *Artifact) Config() (*discovery.DiscoveryConfiguration, error) {
+	cfg := new(discovery.DiscoveryConfiguration)
+	if err := xml.Unmarshal([]byte(a.Configuration), cfg); err != nil {
+		return nil, fmt.Errorf("cannot parse the configuration of the artifact: %v", err)
+	}
+	return cfg, nil
+}
+
+func (s *SignedArtifact) Save(fileName string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, data, 0644)
+}
+
+// LoadSignedArtifact reads an artifact saved via -artifact, or the one included in a bundle saved via -bundle
+func LoadSignedArtifact(fileName string) (*SignedArtifact, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(fileName, ".tar.gz") || strings.HasSuffix(fileName, ".tgz") {
+		if data, err = ReadBundleEntry(bytes.NewReader(data), "artifact.json"); err != nil {
+			return nil, fmt.Errorf("cannot read artifact from bundle %s: %v", fileName, err)
+		}
+	}
+	s := new(SignedArtifact)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("cannot parse artifact %s: %v", fileName, err)
+	}
+	return s, nil
+}
+
+// GenerateKeys creates an Ed25519 key pair, saving the private key (base64) in name.key and the public key in name.pub
+func GenerateKeys(name string) error {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(name+".key", []byte(base64.StdEncoding.EncodeToString(priv)+"\n"), 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name+".pub", []byte(base64.StdEncoding.EncodeToString(pub)+"\n"), 0644)
+}
+
+func readKey(fileName string, size int) ([]byte, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != size {
+		return nil, fmt.Errorf("invalid key %s", fileName)
+	}
+	return key, nil
+}
+
+func LoadPrivateKey(fileName string) (ed25519.PrivateKey, error) {
+	checkPrivateFile(fileName)
+	key, err := readKey(fileName, ed25519.PrivateKeySize)
+	return ed25519.PrivateKey(key), err
+}
+
+func LoadPublicKey(fileName string) (ed25519.PublicKey, error) {
+	key, err := readKey(fileName, ed25519.PublicKeySize)
+	return ed25519.PublicKey(key), err
+}
@@ -0,0 +1,61 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestSignedArtifact(t *testing.T) {
+	dir := t.TempDir()
+	if err := GenerateKeys(filepath.Join(dir, "reviewer")); err != nil {
+		t.Fatalf("cannot generate keys: %v", err)
+	}
+	priv, err := LoadPrivateKey(filepath.Join(dir, "reviewer.key"))
+	if err != nil {
+		t.Fatalf("cannot load private key: %v", err)
+	}
+	pub, err := LoadPublicKey(filepath.Join(dir, "reviewer.pub"))
+	if err != nil {
+		t.Fatalf("cannot load public key: %v", err)
+	}
+
+	cfg := &discovery.DiscoveryConfiguration{Retries: 1, Definitions: []discovery.Definition{{}}}
+	cfg.Definitions[0].AddSpecific("10.0.0.1")
+	signed, err := NewArtifact(cfg, NewSummary()).Sign(priv)
+	if err != nil {
+		t.Fatalf("cannot sign artifact: %v", err)
+	}
+	file := filepath.Join(dir, "artifact.json")
+	if err := signed.Save(file); err != nil {
+		t.Fatalf("cannot save artifact: %v", err)
+	}
+	loaded, err := LoadSignedArtifact(file)
+	if err != nil {
+		t.Fatalf("cannot load artifact: %v", err)
+	}
+	artifact, err := loaded.Verify(pub)
+	if err != nil {
+		t.Fatalf("cannot verify artifact: %v", err)
+	}
+	out, err := artifact.Config()
+	if err != nil {
+		t.Fatalf("cannot get configuration: %v", err)
+	}
+	if out.String() != cfg.String() {
+		t.Errorf("unexpected configuration: %s", out.String())
+	}
+
+	loaded.Payload[len(loaded.Payload)-2] = ' ' // Tamper the content
+	if _, err := loaded.Verify(pub); err == nil {
+		t.Errorf("a tampered artifact should fail")
+	}
+	GenerateKeys(filepath.Join(dir, "other"))
+	other, _ := LoadPublicKey(filepath.Join(dir, "other.pub"))
+	if _, err := signed.Verify(other); err == nil {
+		t.Errorf("an artifact signed by another key should fail")
+	}
+}
@@ -0,0 +1,112 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Semantic comparison between the live discovery configuration and the last recorded generation
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+// definitionSettings returns the XML representation of a definition without its elements
+func definitionSettings(def discovery.Definition) string {
+	def.Specifics, def.IncludeRanges, def.ExcludeRanges, def.IncludeURLs = nil, nil, nil, nil
+	data, _ := xml.Marshal(def)
+	return string(data)
+}
+
+// Drift returns the semantic differences between the recorded and the live configurations,
+// ignoring formatting and the order of the definitions and their elements.
+func Drift(recorded, live *discovery.DiscoveryConfiguration) []string {
+	diff := make([]string, 0)
+	settings := []struct {
+		name           string
+		recorded, live int
+	}{
+		{"packets-per-second", recorded.PacketsPerSecond, live.PacketsPerSecond},
+		{"initial-sleep-time", recorded.InitialSleepTime, live.InitialSleepTime},
+		{"restart-sleep-time", recorded.RestartSleepTime, live.RestartSleepTime},
+		{"retries", recorded.Retries, live.Retries},
+		{"timeout", recorded.Timeout, live.Timeout},
+		{"chunk-size", recorded.ChunkSize, live.ChunkSize},
+	}
+	for _, s := range settings {
+		if s.recorded != s.live {
+			diff = append(diff, fmt.Sprintf("~ %s: %d -> %d", s.name, s.recorded, s.live))
+		}
+	}
+
+	definitions := func(cfg *discovery.DiscoveryConfiguration) map[string]*discovery.Definition {
+		defs := make(map[string]*discovery.Definition)
+		for i := range cfg.Definitions {
+			defs[discovery.DefinitionKey(&cfg.Definitions[i])] = &cfg.Definitions[i]
+		}
+		return defs
+	}
+	recordedDefs, liveDefs := definitions(recorded), definitions(live)
+	keys := make([]string, 0)
+	for key := range recordedDefs {
+		keys = append(keys, key)
+	}
+	for key := range liveDefs {
+		if _, ok := recordedDefs[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		r, l := recordedDefs[key], liveDefs[key]
+		switch {
+		case l == nil:
+			diff = append(diff, fmt.Sprintf("- definition %s", key))
+			continue
+		case r == nil:
+			diff = append(diff, fmt.Sprintf("+ definition %s", key))
+			continue
+		}
+		if rs, ls := definitionSettings(*r), definitionSettings(*l); rs != ls {
+			diff = append(diff, fmt.Sprintf("~ definition %s: %s -> %s", key, rs, ls))
+		}
+		recordedKeys, liveKeys := discovery.ElementKeys(r), discovery.ElementKeys(l)
+		for _, category := range []string{"specific", "include-range", "exclude-range", "include-url"} {
+			recordedSet, liveSet := keySet(recordedKeys[category]), keySet(liveKeys[category])
+			for _, k := range recordedKeys[category] {
+				if !liveSet[k] {
+					diff = append(diff, fmt.Sprintf("- definition %s: %s", key, k))
+				}
+			}
+			for _, k := range liveKeys[category] {
+				if !recordedSet[k] {
+					diff = append(diff, fmt.Sprintf("+ definition %s: %s", key, k))
+				}
+			}
+		}
+	}
+	return diff
+}
+
+func keySet(keys []string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		set[k] = true
+	}
+	return set
+}
+
+// Audit compares the live configuration of an OpenNMS instance against the last recorded generation
+func Audit(onmsHomePath string) ([]string, error) {
+	recorded, err := loadConfiguration(deployedPath(onmsHomePath))
+	if err != nil {
+		return nil, fmt.Errorf("cannot load the last recorded generation: %v", err)
+	}
+	live, err := loadConfiguration(discoveryConfigPath(onmsHomePath))
+	if err != nil {
+		return nil, fmt.Errorf("cannot load the live configuration: %v", err)
+	}
+	return Drift(recorded, live), nil
+}
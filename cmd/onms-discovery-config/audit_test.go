@@ -0,0 +1,69 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestDrift(t *testing.T) {
+	recorded := &discovery.DiscoveryConfiguration{Retries: 1}
+	def := discovery.Definition{Location: "Default"}
+	def.AddSpecific("10.0.0.1")
+	def.AddSpecific("10.0.0.2")
+	def.IncludeCIDR("192.168.0.0/24")
+	recorded.AddDefinition(def)
+
+	// Same content in a different order
+	live := &discovery.DiscoveryConfiguration{Retries: 1}
+	def = discovery.Definition{Location: "Default"}
+	def.IncludeCIDR("192.168.0.0/24")
+	def.AddSpecific("10.0.0.2")
+	def.AddSpecific("10.0.0.1")
+	live.AddDefinition(def)
+	if diff := Drift(recorded, live); len(diff) != 0 {
+		t.Errorf("there should be no drift: %v", diff)
+	}
+
+	live.Retries = 2
+	live.Definitions[0].Specifics = live.Definitions[0].Specifics[:1]
+	live.Definitions[0].AddSpecific("10.0.0.3")
+	live.AddDefinition(discovery.Definition{Location: "Remote"})
+	diff := Drift(recorded, live)
+	if len(diff) != 4 {
+		t.Fatalf("there should be 4 differences: %v", diff)
+	}
+	expected := []string{"~ retries: 1 -> 2", "- definition", "10.0.0.1", "+ definition location=Remote"}
+	text := strings.Join(diff, "\n")
+	for _, e := range expected {
+		if !strings.Contains(text, e) {
+			t.Errorf("the differences should contain %q: %v", e, diff)
+		}
+	}
+}
+
+func TestAudit(t *testing.T) {
+	home := t.TempDir()
+	os.MkdirAll(filepath.Join(home, "etc"), 0755)
+	if _, err := Audit(home); err == nil {
+		t.Errorf("audit should fail without a recorded generation")
+	}
+	cfg := &discovery.DiscoveryConfiguration{Retries: 1, Definitions: []discovery.Definition{{Location: "Default"}}}
+	cfg.Definitions[0].AddSpecific("10.0.0.1")
+	data := []byte(cfg.String())
+	ioutil.WriteFile(discoveryConfigPath(home), data, 0644)
+	ioutil.WriteFile(deployedPath(home), data, 0644)
+	diff, err := Audit(home)
+	if err != nil {
+		t.Fatalf("cannot audit: %v", err)
+	}
+	if len(diff) != 0 {
+		t.Errorf("there should be no drift: %v", diff)
+	}
+}
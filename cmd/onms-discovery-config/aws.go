@@ -0,0 +1,311 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// AWS EC2 as a source of private IP addresses and VPC subnets, using the Query API signed with Signature Version 4
+// https://docs.aws.amazon.com/AWSEC2/latest/APIReference/Query-Requests.html
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const ec2APIVersion = "2016-11-15"
+
+// awsResources are the values accepted by -inc-aws
+var awsResources = []string{"instances", "subnets", "all"}
+
+// awsRegion returns the given region, or the one from the environment like the AWS CLI
+func awsRegion(region string) (string, error) {
+	for _, r := range []string{region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION")} {
+		if r != "" {
+			return r, nil
+		}
+	}
+	return "", fmt.Errorf("the AWS region is required; use 'aws-region' or AWS_REGION")
+}
+
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// awsClient returns the client and the tag filters for the given resources of 'inc-aws'
+func (o *Options) awsClient(resources string) (*AWSClient, []AWSFilter, error) {
+	valid := false
+	for _, r := range awsResources {
+		valid = valid || r == resources
+	}
+	if !valid {
+		return nil, nil, fmt.Errorf("invalid AWS resources %s; valid resources are %s", resources, strings.Join(awsResources, ", "))
+	}
+	filters, err := ParseAWSTagFilter(o.AWSTagFilter)
+	if err != nil {
+		return nil, nil, err
+	}
+	region, err := awsRegion(o.AWSRegion)
+	if err != nil {
+		return nil, nil, err
+	}
+	creds, err := LoadAWSCredentials(o.AWSProfile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewAWSClient(region, creds), filters, nil
+}
+
+// LoadAWSCredentials returns the credentials from the environment, or from the shared credentials file for the given profile;
+// the environment is only used without an explicit profile, like the AWS CLI.
+func LoadAWSCredentials(profile string) (*AWSCredentials, error) {
+	if profile == "" && os.Getenv("AWS_ACCESS_KEY_ID") != "" {
+		return &AWSCredentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+	if profile == "" {
+		if profile = os.Getenv("AWS_PROFILE"); profile == "" {
+			profile = "default"
+		}
+	}
+	fileName := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if fileName == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("cannot find the AWS credentials: %v", err)
+		}
+		fileName = filepath.Join(home, ".aws", "credentials")
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the AWS credentials: %v", err)
+	}
+	defer file.Close()
+	creds := new(AWSCredentials)
+	section := ""
+	s := newScanner(file)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		pair := strings.SplitN(line, "=", 2)
+		if section != profile || len(pair) != 2 {
+			continue
+		}
+		switch strings.TrimSpace(pair[0]) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = strings.TrimSpace(pair[1])
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = strings.TrimSpace(pair[1])
+		case "aws_session_token":
+			creds.SessionToken = strings.TrimSpace(pair[1])
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read %s: %v", fileName, err)
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("cannot find the AWS credentials for profile %s in %s", profile, fileName)
+	}
+	return creds, nil
+}
+
+type AWSFilter struct {
+	Name   string
+	Values []string
+}
+
+// ParseAWSTagFilter converts a list of tags into EC2 filters (see ParseTagFilter)
+func ParseAWSTagFilter(filter string) ([]AWSFilter, error) {
+	tags, err := ParseTagFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	filters := make([]AWSFilter, 0, len(tags))
+	for _, tag := range tags {
+		filters = append(filters, AWSFilter{Name: "tag:" + tag.Key, Values: tag.Values})
+	}
+	return filters, nil
+}
+
+type AWSClient struct {
+	Endpoint    string // e.x. https://ec2.us-east-1.amazonaws.com
+	Region      string
+	Credentials *AWSCredentials
+	Client      *http.Client
+}
+
+func NewAWSClient(region string, creds *AWSCredentials) *AWSClient {
+	return &AWSClient{
+		Endpoint:    fmt.Sprintf("https://ec2.%s.amazonaws.com", region),
+		Region:      region,
+		Credentials: creds,
+		Client:      &http.Client{Timeout: 60 * time.Second, Transport: apiTransport},
+	}
+}
+
+// sign adds the Signature Version 4 headers to a request with the given body
+func (c *AWSClient) sign(req *http.Request, body []byte, now time.Time) {
+	hash := func(data []byte) string {
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	}
+	mac := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if c.Credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.Credentials.SessionToken)
+	}
+	names := make([]string, 0)
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var headers strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&headers, "%s:%s\n", name, strings.TrimSpace(req.Header.Get(name)))
+	}
+	signed := strings.Join(names, ";")
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{req.Method, path, req.URL.RawQuery, headers.String(), signed, hash(body)}, "\n")
+	scope := day + "/" + c.Region + "/ec2/aws4_request"
+	toSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hash([]byte(canonical))}, "\n")
+	key := mac(mac(mac(mac([]byte("AWS4"+c.Credentials.SecretAccessKey), day), c.Region), "ec2"), "aws4_request")
+	signature := hex.EncodeToString(mac(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", c.Credentials.AccessKeyID, scope, signed, signature))
+}
+
+// call invokes an EC2 action with the given filters, passing every page of the response to the handler, which returns the next token
+func (c *AWSClient) call(action string, filters []AWSFilter, handle func(data []byte) (string, error)) error {
+	token := ""
+	for {
+		form := url.Values{"Action": {action}, "Version": {ec2APIVersion}, "MaxResults": {"1000"}}
+		for i, f := range filters {
+			form.Set(fmt.Sprintf("Filter.%d.Name", i+1), f.Name)
+			for j, value := range f.Values {
+				form.Set(fmt.Sprintf("Filter.%d.Value.%d", i+1, j+1), value)
+			}
+		}
+		if token != "" {
+			form.Set("NextToken", token)
+		}
+		body := []byte(form.Encode())
+		req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(c.Endpoint, "/")+"/", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+		c.sign(req, body, time.Now())
+		var resp *http.Response
+		err = lookupThrottle.Do(func() error {
+			var e error
+			resp, e = c.Client.Do(req)
+			return e
+		})
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("cannot read the response of %s: %v", action, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s failed with %s: %s", action, resp.Status, string(data))
+		}
+		if token, err = handle(data); err != nil {
+			return fmt.Errorf("invalid response from %s: %v", action, err)
+		}
+		if token == "" {
+			return nil
+		}
+	}
+}
+
+// GetIPAddresses returns the private IPv4 and the IPv6 addresses of the running instances that match the filters
+func (c *AWSClient) GetIPAddresses(filters []AWSFilter) ([]string, error) {
+	addresses := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(ip string) {
+		if ip != "" && !seen[ip] {
+			seen[ip] = true
+			addresses = append(addresses, ip)
+		}
+	}
+	filters = append(append([]AWSFilter{}, filters...), AWSFilter{Name: "instance-state-name", Values: []string{"running"}})
+	err := c.call("DescribeInstances", filters, func(data []byte) (string, error) {
+		page := struct {
+			Instances []struct {
+				PrivateIP  string `xml:"privateIpAddress"`
+				Interfaces []struct {
+					PrivateIPs []string `xml:"privateIpAddressesSet>item>privateIpAddress"`
+					IPv6       []string `xml:"ipv6AddressesSet>item>ipv6Address"`
+				} `xml:"networkInterfaceSet>item"`
+			} `xml:"reservationSet>item>instancesSet>item"`
+			NextToken string `xml:"nextToken"`
+		}{}
+		if err := xml.Unmarshal(data, &page); err != nil {
+			return "", err
+		}
+		for _, instance := range page.Instances {
+			add(instance.PrivateIP)
+			for _, intf := range instance.Interfaces { // Secondary addresses
+				for _, ip := range append(intf.PrivateIPs, intf.IPv6...) {
+					add(ip)
+				}
+			}
+		}
+		return page.NextToken, nil
+	})
+	return addresses, err
+}
+
+// GetSubnets returns the IPv4 and IPv6 CIDRs of the VPC subnets that match the filters
+func (c *AWSClient) GetSubnets(filters []AWSFilter) ([]string, error) {
+	cidrs := make([]string, 0)
+	err := c.call("DescribeSubnets", filters, func(data []byte) (string, error) {
+		page := struct {
+			Subnets []struct {
+				CIDR string   `xml:"cidrBlock"`
+				IPv6 []string `xml:"ipv6CidrBlockAssociationSet>item>ipv6CidrBlock"`
+			} `xml:"subnetSet>item"`
+			NextToken string `xml:"nextToken"`
+		}{}
+		if err := xml.Unmarshal(data, &page); err != nil {
+			return "", err
+		}
+		for _, subnet := range page.Subnets {
+			if subnet.CIDR != "" {
+				cidrs = append(cidrs, subnet.CIDR)
+			}
+			cidrs = append(cidrs, subnet.IPv6...)
+		}
+		return page.NextToken, nil
+	})
+	return cidrs, err
+}
@@ -0,0 +1,114 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadAWSCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_aws")
+	if err != nil {
+		t.Fatalf("cannot create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "credentials")
+	ioutil.WriteFile(file, []byte("[default]\naws_access_key_id = AKID1\naws_secret_access_key = secret1\n\n[prod]\naws_access_key_id=AKID2\naws_secret_access_key=secret2\naws_session_token=token2\n"), 0600)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", file)
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_PROFILE", "")
+	if creds, err := LoadAWSCredentials(""); err != nil || creds.AccessKeyID != "AKID1" || creds.SecretAccessKey != "secret1" {
+		t.Errorf("incorrect default credentials: %+v %v", creds, err)
+	}
+	if creds, err := LoadAWSCredentials("prod"); err != nil || creds.AccessKeyID != "AKID2" || creds.SessionToken != "token2" {
+		t.Errorf("incorrect prod credentials: %+v %v", creds, err)
+	}
+	if _, err := LoadAWSCredentials("dev"); err == nil {
+		t.Errorf("the dev profile should not exist")
+	}
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID3")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret3")
+	if creds, err := LoadAWSCredentials(""); err != nil || creds.AccessKeyID != "AKID3" {
+		t.Errorf("the environment should be used without a profile: %+v %v", creds, err)
+	}
+	if creds, err := LoadAWSCredentials("prod"); err != nil || creds.AccessKeyID != "AKID2" {
+		t.Errorf("an explicit profile should win over the environment: %+v %v", creds, err)
+	}
+}
+
+func TestParseAWSTagFilter(t *testing.T) {
+	filters, err := ParseAWSTagFilter("discovery=true, env=prod|staging")
+	if err != nil {
+		t.Fatalf("cannot parse filter: %v", err)
+	}
+	if len(filters) != 2 || filters[0].Name != "tag:discovery" || filters[1].Name != "tag:env" || len(filters[1].Values) != 2 || filters[1].Values[1] != "staging" {
+		t.Errorf("incorrect filters: %+v", filters)
+	}
+	if _, err := ParseAWSTagFilter("discovery"); err == nil {
+		t.Errorf("a tag without value should be rejected")
+	}
+}
+
+func TestAWSClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/us-east-1/ec2/aws4_request") || r.Header.Get("X-Amz-Security-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("<Response><Errors><Error><Code>AuthFailure</Code></Error></Errors></Response>"))
+			return
+		}
+		r.ParseForm()
+		if r.Form.Get("Filter.1.Name") != "tag:env" || r.Form.Get("Filter.1.Value.2") != "staging" {
+			t.Errorf("the tag filter was not applied: %v", r.Form)
+		}
+		switch r.Form.Get("Action") {
+		case "DescribeInstances":
+			if r.Form.Get("Filter.2.Name") != "instance-state-name" || r.Form.Get("Filter.2.Value.1") != "running" {
+				t.Errorf("only running instances should be requested: %v", r.Form)
+			}
+			if r.Form.Get("NextToken") == "" {
+				w.Write([]byte(`<DescribeInstancesResponse><reservationSet><item><instancesSet><item><privateIpAddress>10.0.0.1</privateIpAddress>
+<networkInterfaceSet><item><privateIpAddressesSet><item><privateIpAddress>10.0.0.1</privateIpAddress></item><item><privateIpAddress>10.0.0.2</privateIpAddress></item></privateIpAddressesSet>
+<ipv6AddressesSet><item><ipv6Address>2001:db8::1</ipv6Address></item></ipv6AddressesSet></item></networkInterfaceSet></item></instancesSet></item></reservationSet>
+<nextToken>page2</nextToken></DescribeInstancesResponse>`))
+			} else {
+				w.Write([]byte(`<DescribeInstancesResponse><reservationSet><item><instancesSet><item><privateIpAddress>10.0.1.1</privateIpAddress></item></instancesSet></item></reservationSet></DescribeInstancesResponse>`))
+			}
+		case "DescribeSubnets":
+			w.Write([]byte(`<DescribeSubnetsResponse><subnetSet><item><cidrBlock>10.0.0.0/24</cidrBlock><ipv6CidrBlockAssociationSet><item><ipv6CidrBlock>2001:db8::/64</ipv6CidrBlock></item></ipv6CidrBlockAssociationSet></item>
+<item><cidrBlock>10.0.1.0/24</cidrBlock></item></subnetSet></DescribeSubnetsResponse>`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	client := NewAWSClient("us-east-1", &AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token"})
+	client.Endpoint = server.URL
+	filters := []AWSFilter{{Name: "tag:env", Values: []string{"prod", "staging"}}}
+	addresses, err := client.GetIPAddresses(filters)
+	if err != nil {
+		t.Fatalf("cannot get IP addresses: %v", err)
+	}
+	if strings.Join(addresses, ",") != "10.0.0.1,10.0.0.2,2001:db8::1,10.0.1.1" {
+		t.Errorf("unexpected IP addresses: %v", addresses)
+	}
+	subnets, err := client.GetSubnets(filters)
+	if err != nil {
+		t.Fatalf("cannot get subnets: %v", err)
+	}
+	if strings.Join(subnets, ",") != "10.0.0.0/24,2001:db8::/64,10.0.1.0/24" {
+		t.Errorf("unexpected subnets: %v", subnets)
+	}
+	client.Credentials = &AWSCredentials{AccessKeyID: "invalid", SecretAccessKey: "secret"}
+	if _, err := client.GetSubnets(filters); err == nil || !strings.Contains(err.Error(), "AuthFailure") {
+		t.Errorf("invalid credentials should fail: %v", err)
+	}
+}
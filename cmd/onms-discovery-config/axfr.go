@@ -0,0 +1,459 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// DNS zone transfers (AXFR) as a source of the IP addresses from the A and AAAA records,
+// optionally signed with TSIG (HMAC-SHA256): https://www.rfc-editor.org/rfc/rfc5936 and https://www.rfc-editor.org/rfc/rfc8945
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	dnsTypeA    = 1
+	dnsTypeSOA  = 6
+	dnsTypeAAAA = 28
+	dnsTypeTSIG = 250
+	dnsTypeAXFR = 252
+	dnsClassIN  = 1
+	dnsClassANY = 255
+
+	tsigAlgorithm = "hmac-sha256."
+	tsigFudge     = 300
+)
+
+// axfrIdleTimeout is the maximum time to wait for the next message of a zone transfer
+var axfrIdleTimeout = 30 * time.Second
+
+var dnsRcodes = map[int]string{1: "FORMERR", 2: "SERVFAIL", 3: "NXDOMAIN", 4: "NOTIMP", 5: "REFUSED", 9: "NOTAUTH"}
+
+var tsigErrors = map[uint16]string{16: "BADSIG", 17: "BADKEY", 18: "BADTIME", 22: "BADTRUNC"}
+
+// ParseAXFRTarget parses zone@server, where the server can have a port (53 by default)
+func ParseAXFRTarget(spec string) (string, string, error) {
+	parts := strings.SplitN(spec, "@", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+		return "", "", fmt.Errorf("invalid zone transfer '%s'; expected zone@server", spec)
+	}
+	zone, server := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+	}
+	return zone, server, nil
+}
+
+// TSIGKey is a shared secret to sign the zone transfer requests with HMAC-SHA256
+type TSIGKey struct {
+	Name   string
+	Secret []byte
+}
+
+// ParseTSIGKey parses name:secret, where the secret is encoded in base64 (like the keys from tsig-keygen)
+func ParseTSIGKey(spec string) (*TSIGKey, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, fmt.Errorf("invalid TSIG key; expected name:secret")
+	}
+	secret, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret of TSIG key %s: %v", parts[0], err)
+	}
+	return &TSIGKey{Name: parts[0], Secret: secret}, nil
+}
+
+// appendDNSName appends a domain name in wire format, lowercase and uncompressed
+func appendDNSName(msg []byte, name string) ([]byte, error) {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if len(label) == 0 || len(label) > 63 {
+				return nil, fmt.Errorf("invalid domain name %s", name)
+			}
+			msg = append(msg, byte(len(label)))
+			msg = append(msg, label...)
+		}
+	}
+	return append(msg, 0), nil
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+// readDNSName returns the lowercase domain name that starts at the given offset, without the trailing dot,
+// following the compression pointers, and the offset after it
+func readDNSName(msg []byte, offset int) (string, int, error) {
+	labels := make([]string, 0)
+	end := -1
+	for jumps := 0; offset < len(msg) && jumps < 64; {
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			if end < 0 {
+				end = offset + 1
+			}
+			return strings.ToLower(strings.Join(labels, ".")), end, nil
+		case length&0xC0 == 0xC0: // Pointer to a previous name
+			if offset+2 > len(msg) {
+				return "", 0, fmt.Errorf("truncated domain name")
+			}
+			if end < 0 {
+				end = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:]) & 0x3FFF)
+			jumps++
+			continue
+		}
+		if offset+1+length > len(msg) {
+			break
+		}
+		labels = append(labels, string(msg[offset+1:offset+1+length]))
+		offset += length + 1
+	}
+	return "", 0, fmt.Errorf("truncated domain name")
+}
+
+// skipDNSName returns the offset after the domain name that starts at the given offset, which can be compressed
+func skipDNSName(msg []byte, offset int) (int, error) {
+	for offset < len(msg) {
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			return offset + 1, nil
+		case length&0xC0 == 0xC0: // Pointer to a previous name
+			return offset + 2, nil
+		}
+		offset += length + 1
+	}
+	return 0, fmt.Errorf("truncated domain name")
+}
+
+// newAXFRQuery returns the zone transfer request, signed when a key is provided, and its MAC
+func newAXFRQuery(zone string, id uint16, key *TSIGKey, now time.Time) ([]byte, []byte, error) {
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[4:], 1) // One question
+	msg, err := appendDNSName(msg, zone)
+	if err != nil {
+		return nil, nil, err
+	}
+	msg = appendUint16(msg, dnsTypeAXFR)
+	msg = appendUint16(msg, dnsClassIN)
+	if key == nil {
+		return msg, nil, nil
+	}
+
+	// The MAC covers the message without the TSIG record, followed by the TSIG variables
+	keyName, err := appendDNSName(nil, key.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	algorithm, _ := appendDNSName(nil, tsigAlgorithm)
+	timers := make([]byte, 8)
+	binary.BigEndian.PutUint16(timers[0:], uint16(now.Unix()>>32))
+	binary.BigEndian.PutUint32(timers[2:], uint32(now.Unix()))
+	binary.BigEndian.PutUint16(timers[6:], tsigFudge)
+	mac := hmac.New(sha256.New, key.Secret)
+	mac.Write(msg)
+	mac.Write(keyName)
+	mac.Write([]byte{0, dnsClassANY, 0, 0, 0, 0}) // Class and TTL
+	mac.Write(algorithm)
+	mac.Write(timers)
+	mac.Write([]byte{0, 0, 0, 0}) // Error and other length
+	sum := mac.Sum(nil)
+
+	rdata := append(algorithm, timers...)
+	rdata = appendUint16(rdata, uint16(len(sum)))
+	rdata = append(rdata, sum...)
+	rdata = appendUint16(rdata, id)
+	rdata = append(rdata, 0, 0, 0, 0) // Error and other length
+	msg = append(msg, keyName...)
+	msg = appendUint16(msg, dnsTypeTSIG)
+	msg = appendUint16(msg, dnsClassANY)
+	msg = append(msg, 0, 0, 0, 0) // TTL
+	msg = appendUint16(msg, uint16(len(rdata)))
+	msg = append(msg, rdata...)
+	binary.BigEndian.PutUint16(msg[10:], 1) // One additional record
+	return msg, sum, nil
+}
+
+// tsigRecord is the TSIG record of a response
+type tsigRecord struct {
+	offset     int // Start of the record, as the MAC covers the message before it
+	name       string
+	algorithm  string
+	timers     []byte // Time signed and fudge
+	mac        []byte
+	originalID uint16
+	errorCode  uint16
+	other      []byte
+}
+
+// findTSIG returns the TSIG record of a message, which must be its last additional record, or nil when it is not signed
+func findTSIG(msg []byte) (*tsigRecord, error) {
+	if len(msg) < 12 {
+		return nil, fmt.Errorf("truncated response")
+	}
+	if binary.BigEndian.Uint16(msg[10:]) == 0 {
+		return nil, nil
+	}
+	questions := int(binary.BigEndian.Uint16(msg[4:]))
+	records := int(binary.BigEndian.Uint16(msg[6:])) + int(binary.BigEndian.Uint16(msg[8:])) + int(binary.BigEndian.Uint16(msg[10:]))
+	offset := 12
+	var err error
+	for i := 0; i < questions; i++ {
+		if offset, err = skipDNSName(msg, offset); err != nil {
+			return nil, err
+		}
+		offset += 4
+	}
+	last := offset
+	for i := 0; i < records; i++ {
+		last = offset
+		if offset, err = skipDNSName(msg, offset); err != nil {
+			return nil, err
+		}
+		if offset+10 > len(msg) {
+			return nil, fmt.Errorf("truncated record")
+		}
+		offset += 10 + int(binary.BigEndian.Uint16(msg[offset+8:]))
+		if offset > len(msg) {
+			return nil, fmt.Errorf("truncated record")
+		}
+	}
+
+	tsig := &tsigRecord{offset: last}
+	if tsig.name, offset, err = readDNSName(msg, last); err != nil {
+		return nil, err
+	}
+	if binary.BigEndian.Uint16(msg[offset:]) != dnsTypeTSIG {
+		return nil, nil
+	}
+	rdata := msg[offset+10 : offset+10+int(binary.BigEndian.Uint16(msg[offset+8:]))]
+	n := 0
+	if tsig.algorithm, n, err = readDNSName(rdata, 0); err != nil {
+		return nil, err
+	}
+	if n+10 > len(rdata) {
+		return nil, fmt.Errorf("truncated TSIG record")
+	}
+	tsig.timers = rdata[n : n+8]
+	macSize := int(binary.BigEndian.Uint16(rdata[n+8:]))
+	n += 10
+	if n+macSize+6 > len(rdata) {
+		return nil, fmt.Errorf("truncated TSIG record")
+	}
+	tsig.mac = rdata[n : n+macSize]
+	n += macSize
+	tsig.originalID = binary.BigEndian.Uint16(rdata[n:])
+	tsig.errorCode = binary.BigEndian.Uint16(rdata[n+2:])
+	otherSize := int(binary.BigEndian.Uint16(rdata[n+4:]))
+	if n+6+otherSize > len(rdata) {
+		return nil, fmt.Errorf("truncated TSIG record")
+	}
+	tsig.other = rdata[n+6 : n+6+otherSize]
+	return tsig, nil
+}
+
+// tsigVerifier checks the signatures of the messages of a zone transfer. Every MAC covers the previous one, starting with
+// the MAC of the request, and the messages since the previous signed one, as up to 99 messages can be unsigned in between.
+// Truncated MACs are not accepted.
+type tsigVerifier struct {
+	key      *TSIGKey
+	prior    []byte // MAC of the request, and then of the last signed message
+	pending  []byte // Unsigned messages since the last signed one
+	unsigned int
+	signed   int
+}
+
+// verify checks the signature of the next message of the zone transfer, if it is signed
+func (v *tsigVerifier) verify(msg []byte, now time.Time) error {
+	tsig, err := findTSIG(msg)
+	if err != nil {
+		return err
+	}
+	if tsig == nil {
+		if v.signed == 0 {
+			return fmt.Errorf("the response is not signed")
+		}
+		if v.unsigned++; v.unsigned > 99 {
+			return fmt.Errorf("too many unsigned messages in the zone transfer")
+		}
+		v.pending = append(v.pending, msg...)
+		return nil
+	}
+	if tsig.name != strings.TrimSuffix(strings.ToLower(v.key.Name), ".") || tsig.algorithm != strings.TrimSuffix(tsigAlgorithm, ".") {
+		return fmt.Errorf("the response is signed with an unexpected key %s (%s)", tsig.name, tsig.algorithm)
+	}
+	if tsig.errorCode != 0 {
+		name, ok := tsigErrors[tsig.errorCode]
+		if !ok {
+			name = fmt.Sprintf("error %d", tsig.errorCode)
+		}
+		return fmt.Errorf("the server rejected the TSIG signature with %s", name)
+	}
+
+	// The MAC covers the prior MAC, the unsigned messages, and the message without the TSIG record and with the original ID,
+	// followed by the TSIG variables on the first message, or just the timers on the next ones
+	unsigned := append([]byte{}, msg[:tsig.offset]...)
+	binary.BigEndian.PutUint16(unsigned[0:], tsig.originalID)
+	binary.BigEndian.PutUint16(unsigned[10:], binary.BigEndian.Uint16(unsigned[10:])-1)
+	mac := hmac.New(sha256.New, v.key.Secret)
+	mac.Write(appendUint16(nil, uint16(len(v.prior))))
+	mac.Write(v.prior)
+	mac.Write(v.pending)
+	mac.Write(unsigned)
+	if v.signed == 0 {
+		keyName, _ := appendDNSName(nil, v.key.Name)
+		algorithm, _ := appendDNSName(nil, tsigAlgorithm)
+		mac.Write(keyName)
+		mac.Write([]byte{0, dnsClassANY, 0, 0, 0, 0}) // Class and TTL
+		mac.Write(algorithm)
+		mac.Write(tsig.timers)
+		mac.Write(appendUint16(nil, tsig.errorCode))
+		mac.Write(appendUint16(nil, uint16(len(tsig.other))))
+		mac.Write(tsig.other)
+	} else {
+		mac.Write(tsig.timers)
+	}
+	if !hmac.Equal(mac.Sum(nil), tsig.mac) {
+		return fmt.Errorf("invalid TSIG signature in the response")
+	}
+	signed := int64(binary.BigEndian.Uint16(tsig.timers))<<32 | int64(binary.BigEndian.Uint32(tsig.timers[2:]))
+	fudge := int64(binary.BigEndian.Uint16(tsig.timers[6:]))
+	if delta := now.Unix() - signed; delta > fudge || delta < -fudge {
+		return fmt.Errorf("the TSIG signature of the response is outside of the allowed time window")
+	}
+	v.prior, v.pending, v.unsigned = tsig.mac, nil, 0
+	v.signed++
+	return nil
+}
+
+// done checks that the last message of the zone transfer was signed
+func (v *tsigVerifier) done() error {
+	if v.unsigned > 0 {
+		return fmt.Errorf("the last message of the zone transfer is not signed")
+	}
+	return nil
+}
+
+// parseAXFRMessage adds the addresses of the A and AAAA records of a response, and returns the number of SOA records found
+func parseAXFRMessage(msg []byte, id uint16, add func(ip string)) (int, error) {
+	if len(msg) < 12 {
+		return 0, fmt.Errorf("truncated response")
+	}
+	if binary.BigEndian.Uint16(msg[0:]) != id {
+		return 0, fmt.Errorf("unexpected response ID")
+	}
+	if rcode := int(msg[3] & 0x0F); rcode != 0 {
+		name, ok := dnsRcodes[rcode]
+		if !ok {
+			name = fmt.Sprintf("RCODE %d", rcode)
+		}
+		return 0, fmt.Errorf("the server responded with %s", name)
+	}
+	questions := int(binary.BigEndian.Uint16(msg[4:]))
+	answers := int(binary.BigEndian.Uint16(msg[6:]))
+	offset := 12
+	var err error
+	for i := 0; i < questions; i++ {
+		if offset, err = skipDNSName(msg, offset); err != nil {
+			return 0, err
+		}
+		offset += 4
+	}
+	soa := 0
+	for i := 0; i < answers; i++ {
+		if offset, err = skipDNSName(msg, offset); err != nil {
+			return 0, err
+		}
+		if offset+10 > len(msg) {
+			return 0, fmt.Errorf("truncated record")
+		}
+		rtype := binary.BigEndian.Uint16(msg[offset:])
+		length := int(binary.BigEndian.Uint16(msg[offset+8:]))
+		offset += 10
+		if offset+length > len(msg) {
+			return 0, fmt.Errorf("truncated record")
+		}
+		rdata := msg[offset : offset+length]
+		offset += length
+		switch {
+		case rtype == dnsTypeSOA:
+			soa++
+		case rtype == dnsTypeA && length == net.IPv4len, rtype == dnsTypeAAAA && length == net.IPv6len:
+			add(net.IP(rdata).String())
+		}
+	}
+	return soa, nil
+}
+
+// ZoneTransfer returns the unique IP addresses of the A and AAAA records of a zone, transferred from the given server (host:port).
+// With a key, the request is signed, and the signatures of the responses are verified.
+func ZoneTransfer(zone, server string, key *TSIGKey) ([]string, error) {
+	idBytes := make([]byte, 2)
+	rand.Read(idBytes)
+	id := binary.BigEndian.Uint16(idBytes)
+	query, mac, err := newAXFRQuery(zone, id, key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	var verifier *tsigVerifier
+	if key != nil {
+		verifier = &tsigVerifier{key: key, prior: mac}
+	}
+	conn, err := net.DialTimeout("tcp", server, axfrIdleTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(axfrIdleTimeout))
+	if _, err := conn.Write(append(appendUint16(nil, uint16(len(query))), query...)); err != nil {
+		return nil, fmt.Errorf("cannot send request: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	addresses := make([]string, 0)
+	add := func(ip string) {
+		if !seen[ip] {
+			seen[ip] = true
+			addresses = append(addresses, ip)
+		}
+	}
+	// The transfer starts and ends with the SOA record of the zone, and can span multiple messages
+	soa := 0
+	for soa < 2 {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return nil, fmt.Errorf("incomplete zone transfer: %v", err)
+		}
+		msg := make([]byte, binary.BigEndian.Uint16(header))
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			return nil, fmt.Errorf("incomplete zone transfer: %v", err)
+		}
+		conn.SetDeadline(time.Now().Add(axfrIdleTimeout))
+		count, err := parseAXFRMessage(msg, id, add)
+		if err == nil && verifier != nil {
+			err = verifier.verify(msg, time.Now())
+		}
+		if err != nil {
+			return nil, err
+		}
+		soa += count
+	}
+	if verifier != nil {
+		if err := verifier.done(); err != nil {
+			return nil, err
+		}
+	}
+	return addresses, nil
+}
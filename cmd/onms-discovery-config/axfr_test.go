@@ -0,0 +1,213 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAXFRTarget(t *testing.T) {
+	cases := map[string]string{
+		"example.com@ns1.example.com":     "ns1.example.com:53",
+		"example.com@10.0.0.53:5353":      "10.0.0.53:5353",
+		"example.com@2001:db8::53":        "[2001:db8::53]:53",
+		"example.com@[2001:db8::53]:5353": "[2001:db8::53]:5353",
+	}
+	for spec, expected := range cases {
+		zone, server, err := ParseAXFRTarget(spec)
+		if err != nil || zone != "example.com" || server != expected {
+			t.Errorf("unexpected target for %s: %s %s %v", spec, zone, server, err)
+		}
+	}
+	for _, spec := range []string{"example.com", "@ns1", "example.com@"} {
+		if _, _, err := ParseAXFRTarget(spec); err == nil {
+			t.Errorf("target %s should be invalid", spec)
+		}
+	}
+	if _, err := ParseTSIGKey("transfer-key:not-base64!"); err == nil {
+		t.Errorf("the secret should be invalid")
+	}
+}
+
+// dnsRecord returns a resource record with a compressed name pointing to the question
+func dnsRecord(rtype uint16, rdata []byte) []byte {
+	rr := []byte{0xC0, 12}
+	rr = appendUint16(rr, rtype)
+	rr = appendUint16(rr, dnsClassIN)
+	rr = append(rr, 0, 0, 0x0E, 0x10) // TTL
+	rr = appendUint16(rr, uint16(len(rdata)))
+	return append(rr, rdata...)
+}
+
+// dnsResponse returns a response for the query with the given records and RCODE
+func dnsResponse(query []byte, rcode byte, records ...[]byte) []byte {
+	end, _ := skipDNSName(query, 12)
+	msg := append([]byte{}, query[:end+4]...)
+	msg[2], msg[3] = 0x84, rcode // Response, authoritative
+	binary.BigEndian.PutUint16(msg[6:], uint16(len(records)))
+	binary.BigEndian.PutUint16(msg[10:], 0)
+	for _, rr := range records {
+		msg = append(msg, rr...)
+	}
+	return msg
+}
+
+// dnsFrame returns a message prefixed with its length, as sent over TCP
+func dnsFrame(msg []byte) []byte {
+	return append(appendUint16(nil, uint16(len(msg))), msg...)
+}
+
+// signResponse appends a TSIG record to a response, and returns it with its MAC. The MAC covers the prior one and the unsigned
+// messages since then, followed by all the TSIG variables on the first response, or only the timers on the next ones.
+func signResponse(msg []byte, key *TSIGKey, prior, pending []byte, first bool) ([]byte, []byte) {
+	keyName, _ := appendDNSName(nil, key.Name)
+	algorithm, _ := appendDNSName(nil, tsigAlgorithm)
+	timers := make([]byte, 8)
+	binary.BigEndian.PutUint32(timers[2:], uint32(time.Now().Unix()))
+	binary.BigEndian.PutUint16(timers[6:], tsigFudge)
+	mac := hmac.New(sha256.New, key.Secret)
+	mac.Write(appendUint16(nil, uint16(len(prior))))
+	mac.Write(prior)
+	mac.Write(pending)
+	mac.Write(msg)
+	if first {
+		mac.Write(keyName)
+		mac.Write([]byte{0, dnsClassANY, 0, 0, 0, 0})
+		mac.Write(algorithm)
+		mac.Write(timers)
+		mac.Write([]byte{0, 0, 0, 0})
+	} else {
+		mac.Write(timers)
+	}
+	sum := mac.Sum(nil)
+	rdata := append(algorithm, timers...)
+	rdata = appendUint16(rdata, uint16(len(sum)))
+	rdata = append(rdata, sum...)
+	rdata = append(rdata, msg[0], msg[1], 0, 0, 0, 0) // Original ID, error and other length
+	signed := append(append([]byte{}, msg...), keyName...)
+	signed = appendUint16(signed, dnsTypeTSIG)
+	signed = appendUint16(signed, dnsClassANY)
+	signed = append(signed, 0, 0, 0, 0)
+	signed = appendUint16(signed, uint16(len(rdata)))
+	signed = append(signed, rdata...)
+	binary.BigEndian.PutUint16(signed[10:], binary.BigEndian.Uint16(signed[10:])+1)
+	return signed, sum
+}
+
+// verifyTSIG validates the signature of a query, and returns the name of the zone and the MAC
+func verifyTSIG(t *testing.T, query []byte, key *TSIGKey) (string, []byte) {
+	end, _ := skipDNSName(query, 12)
+	zone := string(query[13 : end-1])
+	unsigned := append([]byte{}, query[:end+4]...)
+	if binary.BigEndian.Uint16(query[10:]) != 1 {
+		t.Errorf("the query should be signed")
+		return zone, nil
+	}
+	binary.BigEndian.PutUint16(unsigned[10:], 0)
+	tsig := query[end+4:]
+	nameEnd, _ := skipDNSName(tsig, 0)
+	rdata := tsig[nameEnd+10:]
+	algEnd, _ := skipDNSName(rdata, 0)
+	macSize := int(binary.BigEndian.Uint16(rdata[algEnd+8:]))
+	mac := hmac.New(sha256.New, key.Secret)
+	mac.Write(unsigned)
+	mac.Write(tsig[:nameEnd])
+	mac.Write([]byte{0, dnsClassANY, 0, 0, 0, 0})
+	mac.Write(rdata[:algEnd+8])
+	mac.Write([]byte{0, 0, 0, 0})
+	if !hmac.Equal(mac.Sum(nil), rdata[algEnd+10:algEnd+10+macSize]) {
+		t.Errorf("invalid TSIG signature")
+	}
+	return zone, rdata[algEnd+10 : algEnd+10+macSize]
+}
+
+func TestZoneTransfer(t *testing.T) {
+	key, err := ParseTSIGKey("transfer-key:c2VjcmV0LXNlY3JldC1zZWNyZXQ=")
+	if err != nil {
+		t.Fatalf("cannot parse key: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			header := make([]byte, 2)
+			io.ReadFull(conn, header)
+			query := make([]byte, binary.BigEndian.Uint16(header))
+			io.ReadFull(conn, query)
+			soa := dnsRecord(dnsTypeSOA, bytes.Repeat([]byte{0}, 22))
+			zone, requestMAC := verifyTSIG(t, query, key)
+			first := dnsResponse(query, 0, soa, dnsRecord(dnsTypeA, []byte{10, 0, 0, 1}), dnsRecord(dnsTypeA, []byte{10, 0, 0, 2}))
+			middle := dnsResponse(query, 0, dnsRecord(dnsTypeA, []byte{10, 0, 0, 3}))
+			last := dnsResponse(query, 0, dnsRecord(dnsTypeAAAA, net.ParseIP("fd00::1")), dnsRecord(dnsTypeA, []byte{10, 0, 0, 1}), soa)
+			signedFirst, mac := signResponse(first, key, requestMAC, nil, true)
+			switch zone {
+			case "example":
+				signedLast, _ := signResponse(last, key, mac, nil, false)
+				conn.Write(dnsFrame(signedFirst))
+				conn.Write(dnsFrame(signedLast))
+			case "partial": // Unsigned messages in between are covered by the next MAC
+				signedLast, _ := signResponse(last, key, mac, middle, false)
+				conn.Write(dnsFrame(signedFirst))
+				conn.Write(dnsFrame(middle))
+				conn.Write(dnsFrame(signedLast))
+			case "unsigned-end":
+				conn.Write(dnsFrame(signedFirst))
+				conn.Write(dnsFrame(last))
+			case "tampered":
+				signedLast, _ := signResponse(last, key, mac, nil, false)
+				signedLast[bytes.Index(signedLast, net.ParseIP("fd00::1"))] = 0xfc
+				conn.Write(dnsFrame(signedFirst))
+				conn.Write(dnsFrame(signedLast))
+			case "unsigned":
+				conn.Write(dnsFrame(first))
+				conn.Write(dnsFrame(last))
+			case "wrong-chain": // Signed as the first response, without the request MAC
+				signedFirst, _ = signResponse(first, key, nil, nil, true)
+				conn.Write(dnsFrame(signedFirst))
+			default:
+				conn.Write(dnsFrame(dnsResponse(query, 5)))
+			}
+			conn.Close()
+		}
+	}()
+
+	addresses, err := ZoneTransfer("Example.", listener.Addr().String(), key)
+	if err != nil {
+		t.Fatalf("cannot transfer zone: %v", err)
+	}
+	if strings.Join(addresses, ",") != "10.0.0.1,10.0.0.2,fd00::1" {
+		t.Errorf("unexpected addresses: %v", addresses)
+	}
+	if addresses, err := ZoneTransfer("partial", listener.Addr().String(), key); err != nil || len(addresses) != 4 {
+		t.Errorf("the unsigned messages in between should be accepted: %v %v", addresses, err)
+	}
+	if _, err := ZoneTransfer("other", listener.Addr().String(), key); err == nil || !strings.Contains(err.Error(), "REFUSED") {
+		t.Errorf("the transfer should be refused, got %v", err)
+	}
+	invalid := map[string]string{
+		"unsigned-end": "last message of the zone transfer is not signed",
+		"tampered":     "invalid TSIG signature",
+		"unsigned":     "response is not signed",
+		"wrong-chain":  "invalid TSIG signature",
+	}
+	for zone, expected := range invalid {
+		if _, err := ZoneTransfer(zone, listener.Addr().String(), key); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("the transfer of %s should fail with '%s', got %v", zone, expected, err)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Azure Resource Graph as a source of the private IP addresses of the VMs and the VNet subnets
+// https://learn.microsoft.com/en-us/rest/api/azureresourcegraph/resourcegraph/resources/resources
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph"
+)
+
+// azureResources are the values accepted by -inc-azure
+var azureResources = []string{"vms", "subnets", "all"}
+
+type AzureClient struct {
+	Subscriptions []string // All the subscriptions the service principal can access when empty
+	graph         *armresourcegraph.Client
+}
+
+// azureClientOptions returns the options of the Azure SDK, sending the requests through the recorder of the API responses
+func azureClientOptions() azidentity.ClientSecretCredentialOptions {
+	return azidentity.ClientSecretCredentialOptions{
+		ClientOptions: azcore.ClientOptions{Transport: &http.Client{Timeout: 60 * time.Second, Transport: apiTransport}},
+	}
+}
+
+// NewAzureClient creates a client for a service principal, using the same environment variables as the Azure SDK by default
+func NewAzureClient(tenantID, clientID, clientSecret string, options azidentity.ClientSecretCredentialOptions) (*AzureClient, error) {
+	if tenantID == "" {
+		tenantID = os.Getenv("AZURE_TENANT_ID")
+	}
+	if clientID == "" {
+		clientID = os.Getenv("AZURE_CLIENT_ID")
+	}
+	if clientSecret == "" {
+		clientSecret = os.Getenv("AZURE_CLIENT_SECRET")
+	}
+	if tenantID == "" || clientID == "" || clientSecret == "" {
+		return nil, fmt.Errorf("the Azure tenant, client ID and client secret are required; use 'azure-tenant', 'azure-client-id' and 'azure-client-secret', or AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET")
+	}
+	credential, err := azidentity.NewClientSecretCredential(tenantID, clientID, clientSecret, &options)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Azure credentials: %v", err)
+	}
+	graph, err := armresourcegraph.NewClient(credential, &arm.ClientOptions{ClientOptions: options.ClientOptions})
+	if err != nil {
+		return nil, fmt.Errorf("cannot create Resource Graph client: %v", err)
+	}
+	return &AzureClient{graph: graph}, nil
+}
+
+// azureClient returns the client and the filter of the resources for the given resources of 'inc-azure'
+func (o *Options) azureClient(resources string) (*AzureClient, string, error) {
+	valid := false
+	for _, r := range azureResources {
+		valid = valid || r == resources
+	}
+	if !valid {
+		return nil, "", fmt.Errorf("invalid Azure resources %s; valid resources are %s", resources, strings.Join(azureResources, ", "))
+	}
+	tags, err := ParseTagFilter(o.AzureTagFilter)
+	if err != nil {
+		return nil, "", err
+	}
+	client, err := NewAzureClient(o.AzureTenant, o.AzureClientID, o.AzureSecret, azureClientOptions())
+	if err != nil {
+		return nil, "", err
+	}
+	for _, s := range strings.Split(o.AzureSubscriptions, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			client.Subscriptions = append(client.Subscriptions, s)
+		}
+	}
+	return client, AzureFilter(o.AzureResourceGroup, tags), nil
+}
+
+// kqlString returns a string literal for a Kusto query
+func kqlString(value string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(value, `\`, `\\`), "'", `\'`) + "'"
+}
+
+// AzureFilter returns the Kusto conditions to select the resources by resource group and tags
+func AzureFilter(resourceGroup string, tags []TagFilter) string {
+	var sb strings.Builder
+	if resourceGroup != "" {
+		fmt.Fprintf(&sb, " | where resourceGroup =~ %s", kqlString(resourceGroup))
+	}
+	for _, tag := range tags {
+		values := make([]string, 0, len(tag.Values))
+		for _, v := range tag.Values {
+			values = append(values, kqlString(v))
+		}
+		fmt.Fprintf(&sb, " | where tostring(tags[%s]) in~ (%s)", kqlString(tag.Key), strings.Join(values, ", "))
+	}
+	return sb.String()
+}
+
+// Query runs a Resource Graph query following the pagination, and returns the given column of every row
+func (c *AzureClient) Query(query, column string) ([]string, error) {
+	values := make([]string, 0)
+	request := armresourcegraph.QueryRequest{
+		Query:   to.Ptr(query),
+		Options: &armresourcegraph.QueryRequestOptions{ResultFormat: to.Ptr(armresourcegraph.ResultFormatObjectArray), Top: to.Ptr[int32](1000)},
+	}
+	for _, s := range c.Subscriptions {
+		request.Subscriptions = append(request.Subscriptions, to.Ptr(s))
+	}
+	for {
+		var resp armresourcegraph.ClientResourcesResponse
+		err := lookupThrottle.Do(func() error {
+			var e error
+			resp, e = c.graph.Resources(context.Background(), request, nil)
+			return e
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Resource Graph query failed: %v", err)
+		}
+		rows, ok := resp.Data.([]interface{})
+		if !ok && resp.Data != nil {
+			return nil, fmt.Errorf("invalid response from Resource Graph: unexpected data %T", resp.Data)
+		}
+		for _, row := range rows {
+			if fields, ok := row.(map[string]interface{}); ok {
+				if v, _ := fields[column].(string); v != "" {
+					values = append(values, v)
+				}
+			}
+		}
+		if resp.SkipToken == nil || *resp.SkipToken == "" {
+			return values, nil
+		}
+		request.Options.SkipToken = resp.SkipToken
+	}
+}
+
+// GetIPAddresses returns the private IP addresses of the network interfaces of the VMs that match the filter
+func (c *AzureClient) GetIPAddresses(filter string) ([]string, error) {
+	query := "Resources | where type =~ 'microsoft.compute/virtualmachines'" + filter + " | project vmId = tolower(id)" +
+		" | join kind=inner (Resources | where type =~ 'microsoft.network/networkinterfaces'" +
+		" | mv-expand ipconfig = properties.ipConfigurations" +
+		" | project vmId = tolower(tostring(properties.virtualMachine.id)), ip = tostring(ipconfig.properties.privateIPAddress)) on vmId" +
+		" | project ip"
+	return c.Query(query, "ip")
+}
+
+// GetSubnets returns the address prefixes (CIDRs) of the subnets of the virtual networks that match the filter
+func (c *AzureClient) GetSubnets(filter string) ([]string, error) {
+	query := "Resources | where type =~ 'microsoft.network/virtualnetworks'" + filter +
+		" | mv-expand subnet = properties.subnets" +
+		" | mv-expand prefix = iff(isnotempty(subnet.properties.addressPrefixes), subnet.properties.addressPrefixes, pack_array(subnet.properties.addressPrefix))" +
+		" | project prefix = tostring(prefix)"
+	return c.Query(query, "prefix")
+}
@@ -0,0 +1,128 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+func TestAzureFilter(t *testing.T) {
+	filter := AzureFilter("rg-prod", []TagFilter{{Key: "env", Values: []string{"prod", "it's"}}})
+	expected := ` | where resourceGroup =~ 'rg-prod' | where tostring(tags['env']) in~ ('prod', 'it\'s')`
+	if filter != expected {
+		t.Errorf("incorrect filter: %s", filter)
+	}
+	if AzureFilter("", nil) != "" {
+		t.Errorf("there should be no conditions without filters")
+	}
+}
+
+// azureTestOptions returns the options of the Azure SDK to use a test server for the authentication and the API
+func azureTestOptions(server *httptest.Server) azidentity.ClientSecretCredentialOptions {
+	return azidentity.ClientSecretCredentialOptions{
+		ClientOptions: azcore.ClientOptions{
+			Transport: server.Client(),
+			Cloud: cloud.Configuration{
+				ActiveDirectoryAuthorityHost: server.URL + "/",
+				Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+					cloud.ResourceManager: {Endpoint: server.URL, Audience: server.URL},
+				},
+			},
+			Retry: policy.RetryOptions{MaxRetries: -1},
+		},
+		DisableInstanceDiscovery: true,
+	}
+}
+
+func TestAzureClient(t *testing.T) {
+	logins := 0
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/my-tenant/v2.0/.well-known/openid-configuration":
+			w.Write([]byte(`{"token_endpoint":"` + server.URL + `/my-tenant/oauth2/v2.0/token","authorization_endpoint":"` + server.URL + `/my-tenant/oauth2/v2.0/authorize","issuer":"` + server.URL + `/my-tenant/v2.0"}`))
+			return
+		case "/my-tenant/oauth2/v2.0/token":
+			r.ParseForm()
+			if r.Form.Get("client_id") != "my-client" || r.Form.Get("client_secret") != "my-secret" || r.Form.Get("grant_type") != "client_credentials" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"invalid_client"}`))
+				return
+			}
+			logins++
+			w.Write([]byte(`{"access_token":"my-token","token_type":"Bearer","expires_in":3600}`))
+			return
+		}
+		if r.URL.Path != "/providers/Microsoft.ResourceGraph/resources" || r.Header.Get("Authorization") != "Bearer my-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		request := struct {
+			Query         string                 `json:"query"`
+			Subscriptions []string               `json:"subscriptions"`
+			Options       map[string]interface{} `json:"options"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&request)
+		if len(request.Subscriptions) != 1 || request.Subscriptions[0] != "sub1" || !strings.Contains(request.Query, "resourceGroup =~ 'rg'") {
+			t.Errorf("the filters were not applied: %+v", request)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(request.Query, "networkinterfaces"):
+			if request.Options["$skipToken"] == nil {
+				w.Write([]byte(`{"$skipToken":"page2","data":[{"ip":"10.0.0.4"},{"ip":""}]}`))
+			} else {
+				w.Write([]byte(`{"data":[{"ip":"10.0.0.5"}]}`))
+			}
+		case strings.Contains(request.Query, "virtualnetworks"):
+			w.Write([]byte(`{"data":[{"prefix":"10.0.0.0/24"},{"prefix":"10.0.1.0/24"}]}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewAzureClient("my-tenant", "my-client", "my-secret", azureTestOptions(server))
+	if err != nil {
+		t.Fatalf("cannot create client: %v", err)
+	}
+	client.Subscriptions = []string{"sub1"}
+	filter := AzureFilter("rg", nil)
+	addresses, err := client.GetIPAddresses(filter)
+	if err != nil {
+		t.Fatalf("cannot get IP addresses: %v", err)
+	}
+	if strings.Join(addresses, ",") != "10.0.0.4,10.0.0.5" {
+		t.Errorf("unexpected IP addresses: %v", addresses)
+	}
+	subnets, err := client.GetSubnets(filter)
+	if err != nil {
+		t.Fatalf("cannot get subnets: %v", err)
+	}
+	if strings.Join(subnets, ",") != "10.0.0.0/24,10.0.1.0/24" {
+		t.Errorf("unexpected subnets: %v", subnets)
+	}
+	if logins != 1 {
+		t.Errorf("the access token should be reused: %d logins", logins)
+	}
+	client, err = NewAzureClient("my-tenant", "my-client", "invalid", azureTestOptions(server))
+	if err != nil {
+		t.Fatalf("cannot create client: %v", err)
+	}
+	if _, err := client.GetSubnets(filter); err == nil {
+		t.Errorf("invalid credentials should fail")
+	}
+	t.Setenv("AZURE_TENANT_ID", "")
+	if _, err := NewAzureClient("", "my-client", "my-secret", azureTestOptions(server)); err == nil {
+		t.Errorf("a missing tenant should fail")
+	}
+}
@@ -0,0 +1,178 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Reproducible archive with the generated configuration, the summary, and the hashes of the inputs, for audits
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+type InputHash struct {
+	Option string `json:"option"`
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+type BundleManifest struct {
+	Version           string      `json:"version"`
+	ConfigurationHash string      `json:"configurationHash"`
+	Inputs            []InputHash `json:"inputs"`
+	URLs              []string    `json:"urls,omitempty"`
+}
+
+// inputHashes returns the SHA-256 of every input file passed to the tool
+func (o *Options) inputHashes() ([]InputHash, error) {
+	inputs := make([]InputHash, 0)
+	add := func(option, spec string, annotated bool) error {
+		if spec == "" {
+			return nil
+		}
+		path := spec
+		if annotated {
+			input, err := ParseInputFile(spec)
+			if err != nil {
+				return err
+			}
+			path = input.Path
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("cannot read input %s: %v", path, err)
+		}
+		inputs = append(inputs, InputHash{Option: option, Path: path, SHA256: Checksum(data)})
+		return nil
+	}
+	files := []struct {
+		option, spec string
+		annotated    bool
+	}{
+		{"exc-cidr", o.ExcludeCIDR, false},
+		{"exc-list", o.ExcludeList, false},
+		{"inc-cidr", o.IncludeCIDR, true},
+		{"inc-list", o.IncludeList, true},
+		{"inc-dns", o.IncludeDNS, true},
+		{"inc-dns-locations", o.DNSLocations, false},
+		{"inc-hexnnmi", o.IncludeNNMiHex, true},
+		{"site-catalog", o.SiteCatalog, false},
+	}
+	for _, f := range files {
+		if err := add(f.option, f.spec, f.annotated); err != nil {
+			return nil, err
+		}
+	}
+	return inputs, nil
+}
+
+// BundleEntry is a file to be added to the bundle
+type BundleEntry struct {
+	Name    string
+	Content []byte
+}
+
+// WriteBundle creates a tar.gz archive with the given entries; the output only depends on their content and order
+func WriteBundle(w io.Writer, entries []BundleEntry) error {
+	gz := gzip.NewWriter(w) // The header has no name nor modification time
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		header := &tar.Header{
+			Name:    e.Name,
+			Mode:    0644,
+			Size:    int64(len(e.Content)),
+			ModTime: time.Unix(0, 0),
+			Format:  tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(e.Content); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// ReadBundleEntry returns the content of a file from a bundle
+func ReadBundleEntry(r io.Reader, name string) ([]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s not found in the bundle", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Name == name {
+			return ioutil.ReadAll(tr)
+		}
+	}
+}
+
+// saveBundle saves the generated configuration, the summary, and the manifest (plus the signed artifact when possible)
+func saveBundle(opts *Options) error {
+	inputs, err := opts.inputHashes()
+	if err != nil {
+		return err
+	}
+	config := []byte(baseConfig.String())
+	manifest := BundleManifest{
+		Version:           version,
+		ConfigurationHash: Checksum(config),
+		Inputs:            inputs,
+	}
+	for _, spec := range opts.IncludeURLs {
+		if input, err := ParseInputFile(spec); err == nil {
+			manifest.URLs = append(manifest.URLs, input.Path)
+		}
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	reproducible := *summary
+	reproducible.Generation.Time = "" // Otherwise, the bundle would change on every run
+	summaryData, err := json.MarshalIndent(reproducible, "", "  ")
+	if err != nil {
+		return err
+	}
+	entries := []BundleEntry{
+		{Name: "discovery-configuration.xml", Content: config},
+		{Name: "summary.json", Content: summaryData},
+		{Name: "manifest.json", Content: manifestData},
+	}
+	if opts.SigningKey != "" {
+		signed, err := signArtifact(opts)
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(signed, "", "  ")
+		if err != nil {
+			return err
+		}
+		entries = append(entries, BundleEntry{Name: "artifact.json", Content: data})
+	}
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, entries); err != nil {
+		return fmt.Errorf("cannot create bundle: %v", err)
+	}
+	if err := os.WriteFile(opts.BundleFile, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("cannot save bundle: %v", err)
+	}
+	return nil
+}
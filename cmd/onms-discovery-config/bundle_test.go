@@ -0,0 +1,50 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteBundle(t *testing.T) {
+	entries := []BundleEntry{
+		{Name: "discovery-configuration.xml", Content: []byte("<discovery-configuration/>")},
+		{Name: "summary.json", Content: []byte("{}")},
+	}
+	var first, second bytes.Buffer
+	if err := WriteBundle(&first, entries); err != nil {
+		t.Fatalf("cannot create bundle: %v", err)
+	}
+	WriteBundle(&second, entries)
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Errorf("the bundle should be reproducible")
+	}
+	data, err := ReadBundleEntry(bytes.NewReader(first.Bytes()), "summary.json")
+	if err != nil || string(data) != "{}" {
+		t.Errorf("cannot read entry from bundle: %v", err)
+	}
+	if _, err := ReadBundleEntry(bytes.NewReader(first.Bytes()), "missing.json"); err == nil {
+		t.Errorf("reading a missing entry should fail")
+	}
+}
+
+func TestInputHashes(t *testing.T) {
+	dir := t.TempDir()
+	list := filepath.Join(dir, "list.txt")
+	ioutil.WriteFile(list, []byte("10.0.0.1\n"), 0644)
+	opts := &Options{IncludeList: list + ":location=Remote", ExcludeList: list}
+	inputs, err := opts.inputHashes()
+	if err != nil {
+		t.Fatalf("cannot get input hashes: %v", err)
+	}
+	if len(inputs) != 2 || inputs[1].Option != "inc-list" || inputs[1].Path != list || inputs[1].SHA256 != Checksum([]byte("10.0.0.1\n")) {
+		t.Errorf("unexpected input hashes: %v", inputs)
+	}
+	opts.IncludeCIDR = filepath.Join(dir, "missing.txt")
+	if _, err := opts.inputHashes(); err == nil {
+		t.Errorf("a missing input should fail")
+	}
+}
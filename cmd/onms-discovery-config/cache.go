@@ -0,0 +1,108 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Persistent cache for the results of external lookups, to avoid resolving unchanged data on every run
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+type CacheEntry struct {
+	Values  []string  `json:"values"`
+	Expires time.Time `json:"expires"`
+}
+
+type LookupCache struct {
+	Entries map[string]CacheEntry `json:"entries"`
+	TTL     time.Duration         `json:"-"`
+	Refresh bool                  `json:"-"` // When true, all the entries are considered expired
+	path    string
+	mutex   sync.Mutex
+}
+
+// LoadLookupCache reads the cache from the given file; a missing file is an empty cache
+func LoadLookupCache(path string, ttl time.Duration, refresh bool) (*LookupCache, error) {
+	cache := &LookupCache{
+		Entries: make(map[string]CacheEntry),
+		TTL:     ttl,
+		Refresh: refresh,
+		path:    path,
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err == nil {
+		data, err = openFile(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read cache %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, cache); err != nil {
+		return nil, fmt.Errorf("invalid cache %s: %v", path, err)
+	}
+	return cache, nil
+}
+
+// Get returns the cached values of a key, if they exist and have not expired; a nil cache never has values
+func (c *LookupCache) Get(key string) ([]string, bool) {
+	if c == nil || c.Refresh {
+		return nil, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, ok := c.Entries[key]
+	if !ok || time.Now().After(entry.Expires) {
+		return nil, false
+	}
+	return entry.Values, true
+}
+
+// Put stores the values of a key; a nil cache ignores them
+func (c *LookupCache) Put(key string, values []string) {
+	if c == nil {
+		return
+	}
+	c.PutWithTTL(key, values, c.TTL)
+}
+
+// PutWithTTL stores the values of a key, valid for the given time instead of the TTL of the cache; a nil cache ignores them
+func (c *LookupCache) PutWithTTL(key string, values []string, ttl time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.Entries[key] = CacheEntry{Values: values, Expires: time.Now().Add(ttl)}
+}
+
+// Save writes the cache to its file, discarding the expired entries
+func (c *LookupCache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	for key, entry := range c.Entries {
+		if now.After(entry.Expires) {
+			delete(c.Entries, key)
+		}
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err == nil {
+		data, err = sealFile(data)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.path, data, 0644); err != nil {
+		return fmt.Errorf("cannot write cache %s: %v", c.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLookupCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	cache, err := LoadLookupCache(path, time.Hour, false)
+	if err != nil {
+		t.Fatalf("a missing cache should be empty: %v", err)
+	}
+	cache.Put("ptr:10.0.0.1", []string{"srv01.example.com."})
+	cache.Put("ptr:10.0.0.2", []string{})
+	cache.Entries["ptr:10.0.0.3"] = CacheEntry{Values: []string{"old"}, Expires: time.Now().Add(-time.Minute)}
+	if _, ok := cache.Get("ptr:10.0.0.3"); ok {
+		t.Errorf("expired entries should not be returned")
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatalf("cannot save cache: %v", err)
+	}
+
+	cache, err = LoadLookupCache(path, time.Hour, false)
+	if err != nil {
+		t.Fatalf("cannot load cache: %v", err)
+	}
+	if len(cache.Entries) != 2 {
+		t.Errorf("expired entries should not be saved: %v", cache.Entries)
+	}
+	if values, ok := cache.Get("ptr:10.0.0.1"); !ok || len(values) != 1 || values[0] != "srv01.example.com." {
+		t.Errorf("unexpected cached values: %v", values)
+	}
+	if values, ok := cache.Get("ptr:10.0.0.2"); !ok || len(values) != 0 {
+		t.Errorf("empty results should be cached: %v", values)
+	}
+
+	cache, _ = LoadLookupCache(path, time.Hour, true)
+	if _, ok := cache.Get("ptr:10.0.0.1"); ok {
+		t.Errorf("entries should be ignored when refreshing the cache")
+	}
+	var nilCache *LookupCache
+	if _, ok := nilCache.Get("ptr:10.0.0.1"); ok {
+		t.Errorf("a nil cache should not have entries")
+	}
+}
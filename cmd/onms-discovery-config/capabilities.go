@@ -0,0 +1,157 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Capabilities of the target OpenNMS release, probed via /rest/info, to adapt the schema, the endpoints and the events to it
+
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+// Feature is something the tool uses that is not available on every release of OpenNMS
+type Feature string
+
+const (
+	FeatureReloadDaemonConfig Feature = "reloadDaemonConfig event for Discovery"
+	FeatureFilesystemAPI      Feature = "/rest/filesystem"
+	FeatureDefinitions        Feature = "discovery definitions"
+	FeatureIPInterfacesAPI    Feature = "/api/v2/ipinterfaces"
+)
+
+// firstReleases has the first major version of Horizon and Meridian with each feature
+var firstReleases = map[Feature]struct{ Horizon, Meridian int }{
+	FeatureReloadDaemonConfig: {18, 2017},
+	FeatureFilesystemAPI:      {24, 2019},
+	FeatureDefinitions:        {25, 2020},
+	FeatureIPInterfacesAPI:    {26, 2020},
+}
+
+// target is the release of OpenNMS to update; nil means the latest one
+var target *Target
+
+// SystemInfo is the response of /rest/info
+type SystemInfo struct {
+	DisplayVersion     string `json:"displayVersion"`
+	Version            string `json:"version"`
+	PackageName        string `json:"packageName"`
+	PackageDescription string `json:"packageDescription"`
+}
+
+// GetSystemInfo returns the release of the OpenNMS server
+func (c *RestClient) GetSystemInfo() (*SystemInfo, error) {
+	info := new(SystemInfo)
+	if err := c.Get("/rest/info", info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Target is a release of OpenNMS Horizon or Meridian
+type Target struct {
+	Meridian bool
+	Version  string
+	major    int
+}
+
+// ParseTarget parses a version like 23.0.4 or 2019.1.20; Meridian uses the year as the major version
+func ParseTarget(version string) (*Target, error) {
+	major, err := strconv.Atoi(strings.SplitN(strings.TrimSpace(version), ".", 2)[0])
+	if err != nil || major < 1 {
+		return nil, fmt.Errorf("invalid OpenNMS version '%s'", version)
+	}
+	return &Target{Meridian: major >= 2000, Version: strings.TrimSpace(version), major: major}, nil
+}
+
+// Supports returns true when the release has the given feature
+func (t *Target) Supports(f Feature) bool {
+	if t == nil {
+		return true
+	}
+	first, ok := firstReleases[f]
+	if !ok {
+		return true
+	}
+	if t.Meridian {
+		return t.major >= first.Meridian
+	}
+	return t.major >= first.Horizon
+}
+
+func (t *Target) String() string {
+	if t == nil {
+		return "latest"
+	}
+	if t.Meridian {
+		return "Meridian " + t.Version
+	}
+	return "Horizon " + t.Version
+}
+
+// detectTarget sets the target release from 'onms-version', or from /rest/info when 'rest-url' is provided.
+// When the server cannot be probed, the latest release is assumed.
+func detectTarget(opts *Options) error {
+	target = nil
+	version := opts.OnmsVersion
+	meridian := false
+	if version == "" && opts.RestURL != "" {
+		info, err := opts.restClient().GetSystemInfo()
+		if err != nil {
+			log.Printf("cannot detect the version of OpenNMS, assuming the latest: %v", err)
+			return nil
+		}
+		version = info.Version
+		meridian = strings.Contains(strings.ToLower(info.PackageName), "meridian")
+	}
+	if version == "" {
+		return nil
+	}
+	t, err := ParseTarget(version)
+	if err != nil {
+		return err
+	}
+	t.Meridian = t.Meridian || meridian
+	target = t
+	log.Printf("target release: %s", t)
+	for f := range firstReleases {
+		if !t.Supports(f) {
+			log.Printf("%s doesn't support the %s", t, f)
+		}
+	}
+	if !t.Supports(FeatureDefinitions) && (opts.MergeExisting || opts.MergeManual) {
+		return errors.New("'merge-existing' and 'merge-manual' require a release with discovery definitions")
+	}
+	return nil
+}
+
+// renderConfiguration returns the XML of the configuration for the target release,
+// in the layout without definitions when it doesn't support them
+func renderConfiguration(cfg *discovery.DiscoveryConfiguration) string {
+	if target.Supports(FeatureDefinitions) {
+		return cfg.String()
+	}
+	legacy, lost := cfg.Legacy()
+	for _, l := range lost {
+		log.Printf("warning: %s doesn't support %s", target, l)
+	}
+	return legacy.String()
+}
+
+// renderCurrent parses the current configuration as the target release expects it, and renders it again to compare
+// it against the generated one
+func renderCurrent(data []byte) string {
+	if target.Supports(FeatureDefinitions) {
+		current := new(discovery.DiscoveryConfiguration)
+		xml.Unmarshal(data, current)
+		return current.String()
+	}
+	current := new(discovery.LegacyConfiguration)
+	xml.Unmarshal(data, current)
+	return current.String()
+}
@@ -0,0 +1,144 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/events"
+)
+
+func TestParseTarget(t *testing.T) {
+	cases := []struct {
+		version  string
+		meridian bool
+		missing  []Feature
+	}{
+		{"33.0.2", false, nil},
+		{"24.1.3", false, []Feature{FeatureDefinitions, FeatureIPInterfacesAPI}},
+		{"17.1.1", false, []Feature{FeatureReloadDaemonConfig, FeatureFilesystemAPI, FeatureDefinitions, FeatureIPInterfacesAPI}},
+		{"2019.1.20", true, []Feature{FeatureDefinitions, FeatureIPInterfacesAPI}},
+		{"2023.1.0", true, nil},
+	}
+	for _, c := range cases {
+		target, err := ParseTarget(c.version)
+		if err != nil {
+			t.Fatalf("cannot parse %s: %v", c.version, err)
+		}
+		if target.Meridian != c.meridian {
+			t.Errorf("%s: expected meridian %v", c.version, c.meridian)
+		}
+		missing := make(map[Feature]bool)
+		for _, f := range c.missing {
+			missing[f] = true
+		}
+		for f := range firstReleases {
+			if target.Supports(f) == missing[f] {
+				t.Errorf("%s: unexpected support of the %s: %v", target, f, target.Supports(f))
+			}
+		}
+	}
+	if _, err := ParseTarget("latest"); err == nil {
+		t.Errorf("invalid version accepted")
+	}
+	var latest *Target
+	if !latest.Supports(FeatureDefinitions) {
+		t.Errorf("the latest release must support everything")
+	}
+}
+
+func TestDetectTarget(t *testing.T) {
+	defer func() { target = nil }()
+	mock := NewMockOpenNMS(MockData{Info: &SystemInfo{Version: "2019.1.20", PackageName: "meridian"}}, nil)
+	server := httptest.NewServer(http.StripPrefix("/opennms", mock))
+	defer server.Close()
+	opts := &Options{RestURL: server.URL + "/opennms"}
+	if err := detectTarget(opts); err != nil {
+		t.Fatalf("cannot detect target: %v", err)
+	}
+	if target == nil || !target.Meridian || target.Version != "2019.1.20" {
+		t.Fatalf("unexpected target: %v", target)
+	}
+	opts.MergeExisting = true
+	if err := detectTarget(opts); err == nil {
+		t.Errorf("merge-existing accepted without definitions")
+	}
+	opts = &Options{RestURL: server.URL + "/opennms", OnmsVersion: "33.0.0"}
+	if err := detectTarget(opts); err != nil || target.Meridian {
+		t.Errorf("the version from the options must be used: %v, %v", err, target)
+	}
+	opts = &Options{RestURL: "http://127.0.0.1:1/opennms"}
+	if err := detectTarget(opts); err != nil || target != nil {
+		t.Errorf("the latest release must be assumed when the server cannot be probed: %v, %v", err, target)
+	}
+}
+
+func TestLegacyTarget(t *testing.T) {
+	defer func() { target = nil }()
+	target, _ = ParseTarget("17.1.1")
+	event := reloadEvent()
+	if event.UEI != "uei.opennms.org/internal/discoveryConfigChange" || len(event.Parameters) != 0 {
+		t.Errorf("unexpected reload event: %+v", event)
+	}
+	cfg := new(discovery.DiscoveryConfiguration)
+	cfg.AddDefinition(discovery.Definition{Location: "Paris", Retries: 2})
+	cfg.Definitions[0].AddSpecific("10.0.0.1")
+	output := renderConfiguration(cfg)
+	if strings.Contains(output, "<definition") || !strings.Contains(output, `location="Paris"`) {
+		t.Errorf("unexpected legacy configuration: %s", output)
+	}
+	if renderCurrent([]byte(output)) != output {
+		t.Errorf("the legacy configuration must render the same after parsing it")
+	}
+	files := map[string]string{discoveryConfigFile: "<discovery-configuration/>"}
+	sent := make([]events.Event, 0)
+	server := mockFilesystem(t, files, &sent, false)
+	defer server.Close()
+	opts := &Options{RestURL: server.URL, RestToken: "my-token"}
+	if err := opts.deployViaRest(cfg); err == nil {
+		t.Errorf("the ReST push must fail without the filesystem API")
+	}
+}
+
+func TestSearchIPInterfacesV1(t *testing.T) {
+	defer func() { target = nil }()
+	target, _ = ParseTarget("2019.1.20")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/nodes":
+			nodes := []map[string]interface{}{{"id": "1", "foreignSource": "Servers"}, {"id": "2", "foreignSource": "Routers"}}
+			if fs := r.URL.Query().Get("foreignSource"); fs != "" {
+				nodes = []map[string]interface{}{{"id": "1", "foreignSource": fs}}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"node": nodes})
+		case "/rest/nodes/1/ipinterfaces":
+			json.NewEncoder(w).Encode(map[string]interface{}{"ipInterface": []IPInterface{{IPAddress: "10.0.0.1"}}})
+		case "/rest/nodes/2/ipinterfaces":
+			json.NewEncoder(w).Encode(map[string]interface{}{"ipInterface": []IPInterface{{IPAddress: "10.0.0.2"}, {IPAddress: "10.0.0.1"}}})
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	client := NewRestClient(server.URL, "", "")
+	addresses, err := client.GetIPAddresses()
+	if err != nil {
+		t.Fatalf("cannot get addresses: %v", err)
+	}
+	if len(addresses) != 2 {
+		t.Errorf("unexpected addresses: %v", addresses)
+	}
+	addresses, err = client.GetIPAddressesByForeignSource([]string{"Servers"})
+	if err != nil {
+		t.Fatalf("cannot get addresses: %v", err)
+	}
+	if len(addresses) != 1 || addresses[0] != "10.0.0.1" {
+		t.Errorf("unexpected addresses: %v", addresses)
+	}
+}
@@ -0,0 +1,124 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Change tickets to annotate a run, validated against ServiceNow before updating OpenNMS
+// https://docs.servicenow.com/bundle/vancouver-api-reference/page/integrate/inbound-rest/concept/c_TableAPI.html
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// serviceNowTimeFormat is the format of the dates of ServiceNow, in UTC
+const serviceNowTimeFormat = "2006-01-02 15:04:05"
+
+type ChangeRequest struct {
+	Number string
+	State  string    // Display value of the state, e.x. Implement
+	Start  time.Time // Planned start date; zero when unknown
+	End    time.Time // Planned end date; zero when unknown
+}
+
+// Permits returns an error when the change is not in one of the given states, or the time is outside of its planned dates
+func (c *ChangeRequest) Permits(now time.Time, states []string) error {
+	allowed := false
+	for _, state := range states {
+		allowed = allowed || strings.EqualFold(state, c.State)
+	}
+	if !allowed {
+		return fmt.Errorf("change %s is in state %s; expected %s", c.Number, c.State, strings.Join(states, " or "))
+	}
+	if !c.Start.IsZero() && now.Before(c.Start) {
+		return fmt.Errorf("change %s starts at %s", c.Number, c.Start.Format(time.RFC3339))
+	}
+	if !c.End.IsZero() && now.After(c.End) {
+		return fmt.Errorf("change %s ended at %s", c.Number, c.End.Format(time.RFC3339))
+	}
+	return nil
+}
+
+type ServiceNowClient struct {
+	URL      string // e.x. https://acme.service-now.com
+	User     string
+	Password string
+	Client   *http.Client
+}
+
+func NewServiceNowClient(url, user, password string) *ServiceNowClient {
+	return &ServiceNowClient{
+		URL:      strings.TrimSuffix(url, "/"),
+		User:     user,
+		Password: password,
+		Client:   &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// GetChange returns the change request with the given number
+func (c *ServiceNowClient) GetChange(number string) (*ChangeRequest, error) {
+	query := url.Values{
+		"sysparm_query":         {"number=" + number},
+		"sysparm_fields":        {"number,state,start_date,end_date"},
+		"sysparm_display_value": {"all"},
+		"sysparm_limit":         {"1"},
+	}
+	path := "/api/now/table/change_request"
+	req, err := http.NewRequest(http.MethodGet, c.URL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(c.User, c.Password)
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("GET %s failed with %s: %s", path, resp.Status, string(body))
+	}
+	type field struct {
+		Value        string `json:"value"`
+		DisplayValue string `json:"display_value"`
+	}
+	data := struct {
+		Result []map[string]field `json:"result"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("invalid response from %s: %v", path, err)
+	}
+	if len(data.Result) == 0 {
+		return nil, fmt.Errorf("cannot find change %s", number)
+	}
+	result := data.Result[0]
+	change := &ChangeRequest{Number: result["number"].Value, State: result["state"].DisplayValue}
+	for target, name := range map[*time.Time]string{&change.Start: "start_date", &change.End: "end_date"} {
+		if value := result[name].Value; value != "" {
+			if *target, err = time.ParseInLocation(serviceNowTimeFormat, value, time.UTC); err != nil {
+				return nil, fmt.Errorf("invalid %s of change %s: %v", name, number, err)
+			}
+		}
+	}
+	return change, nil
+}
+
+// validateChange verifies the change ticket against ServiceNow, when configured, before updating OpenNMS
+func (o *Options) validateChange(now time.Time) error {
+	if o.ServiceNowURL == "" {
+		return nil
+	}
+	if o.Change == "" {
+		return fmt.Errorf("a change ticket is required to update OpenNMS; use 'change'")
+	}
+	change, err := NewServiceNowClient(o.ServiceNowURL, o.ServiceNowUser, o.ServiceNowPassword).GetChange(o.Change)
+	if err != nil {
+		return fmt.Errorf("cannot validate change %s: %v", o.Change, err)
+	}
+	return change.Permits(now, splitNames(o.ServiceNowStates))
+}
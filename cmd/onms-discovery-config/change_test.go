@@ -0,0 +1,65 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServiceNowChange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, _ := r.BasicAuth(); user != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/now/table/change_request" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.URL.Query().Get("sysparm_query") {
+		case "number=CHG000123":
+			w.Write([]byte(`{"result":[{"number":{"value":"CHG000123","display_value":"CHG000123"},"state":{"value":"-1","display_value":"Implement"},"start_date":{"value":"2024-03-15 22:00:00","display_value":"2024-03-15 18:00:00"},"end_date":{"value":"2024-03-16 02:00:00","display_value":"2024-03-15 22:00:00"}}]}`))
+		case "number=CHG000124":
+			w.Write([]byte(`{"result":[{"number":{"value":"CHG000124"},"state":{"value":"-3","display_value":"Authorize"},"start_date":{"value":""},"end_date":{"value":""}}]}`))
+		default:
+			w.Write([]byte(`{"result":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	during := time.Date(2024, 3, 15, 23, 0, 0, 0, time.UTC)
+	opts := new(Options)
+	if err := opts.validateChange(during); err != nil {
+		t.Errorf("the change should not be validated without ServiceNow: %v", err)
+	}
+	opts = &Options{ServiceNowURL: server.URL, ServiceNowUser: "admin", ServiceNowPassword: "secret", ServiceNowStates: "Scheduled, Implement"}
+	if err := opts.validateChange(during); err == nil {
+		t.Errorf("the change ticket should be required")
+	}
+	opts.Change = "CHG000123"
+	if err := opts.validateChange(during); err != nil {
+		t.Errorf("the change should be valid: %v", err)
+	}
+	if err := opts.validateChange(during.Add(-2 * time.Hour)); err == nil || !strings.Contains(err.Error(), "starts at 2024-03-15T22:00:00Z") {
+		t.Errorf("the change should not have started, got %v", err)
+	}
+	if err := opts.validateChange(during.Add(4 * time.Hour)); err == nil || !strings.Contains(err.Error(), "ended") {
+		t.Errorf("the change should have ended, got %v", err)
+	}
+	opts.Change = "CHG000124"
+	if err := opts.validateChange(during); err == nil || !strings.Contains(err.Error(), "Authorize") {
+		t.Errorf("the change should not be approved, got %v", err)
+	}
+	opts.Change = "CHG999999"
+	if err := opts.validateChange(during); err == nil || !strings.Contains(err.Error(), "cannot find") {
+		t.Errorf("the change should not exist, got %v", err)
+	}
+	opts.Change, opts.ServiceNowPassword = "CHG000123", "wrong"
+	if err := opts.validateChange(during); err == nil {
+		t.Errorf("the authentication should fail")
+	}
+}
@@ -0,0 +1,107 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Tool configuration file, as an alternative to passing all the options on the command line
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configValue converts a scalar from the configuration file into the string expected by a flag
+func configValue(v interface{}) (string, error) {
+	switch value := v.(type) {
+	case string:
+		return value, nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case int:
+		return strconv.Itoa(value), nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case map[string]interface{}: // Options with a list of attributes, e.x. a definition; the lists are separated by semicolons
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		entries := make([]string, 0, len(keys))
+		for _, key := range keys {
+			items, ok := value[key].([]interface{})
+			if !ok {
+				items = []interface{}{value[key]}
+			}
+			values := make([]string, 0, len(items))
+			for _, item := range items {
+				v, err := configValue(item)
+				if err != nil {
+					return "", fmt.Errorf("invalid attribute %s: %v", key, err)
+				}
+				values = append(values, v)
+			}
+			entries = append(entries, key+"="+strings.Join(values, ";"))
+		}
+		return strings.Join(entries, ","), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
+
+// LoadConfigFile reads a YAML or JSON file whose keys are the names of the options, e.x. inc-cidr: /tmp/cidrs.txt,
+// and applies the values to the options that were not passed on the command line, so the command line wins.
+// Options that can be specified multiple times accept a list, and the ones with a list of attributes accept a map.
+func LoadConfigFile(fs *flag.FlagSet, fileName string) error {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("cannot read configuration file: %v", err)
+	}
+	values := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &values); err != nil { // JSON is valid YAML
+		return fmt.Errorf("cannot parse configuration file %s: %v", fileName, err)
+	}
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	for name, value := range values {
+		if name == "config" {
+			continue
+		}
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown option %s in configuration file %s", name, fileName)
+		}
+		if explicit[name] {
+			continue
+		}
+		items, ok := value.([]interface{})
+		if !ok {
+			items = []interface{}{value}
+		}
+		for _, item := range items {
+			v, err := configValue(item)
+			if err != nil {
+				return fmt.Errorf("invalid option %s in configuration file %s: %v", name, fileName, err)
+			}
+			if err := fs.Set(name, v); err != nil {
+				return fmt.Errorf("invalid option %s in configuration file %s: %v", name, fileName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Parse parses the command line, and then applies the configuration file when provided
+func (o *Options) Parse(fs *flag.FlagSet, args []string) {
+	fs.Parse(args)
+	if o.ConfigFile != "" {
+		if err := LoadConfigFile(fs, o.ConfigFile); err != nil {
+			o.Fail(err)
+		}
+	}
+}
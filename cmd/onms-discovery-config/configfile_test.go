@@ -0,0 +1,81 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_config")
+	if err != nil {
+		t.Fatalf("cannot create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"tool.yaml": `
+inc-cidr: /tmp/cidrs.txt
+inc-list: /tmp/list.txt
+inc-url:
+  - http://server/a.txt
+  - http://server/b.txt
+lookup-qps: 100
+lookup-jitter: 2s
+dry-run: true
+`,
+		"tool.json": `{"inc-cidr": "/tmp/cidrs.txt", "inc-list": "/tmp/list.txt", "inc-url": ["http://server/a.txt", "http://server/b.txt"], "lookup-qps": 100, "lookup-jitter": "2s", "dry-run": true}`,
+	}
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		ioutil.WriteFile(file, []byte(content), 0644)
+		opts := new(Options)
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		opts.Register(fs)
+		opts.Parse(fs, []string{"-config", file, "-inc-list", "/tmp/override.txt"})
+		if opts.IncludeCIDR != "/tmp/cidrs.txt" || !opts.DryRun || opts.LookupQPS != 100 || opts.LookupJitter != 2*time.Second {
+			t.Errorf("%s: the options were not applied: %+v", name, opts)
+		}
+		if opts.IncludeList != "/tmp/override.txt" {
+			t.Errorf("%s: the command line should override the file, got %s", name, opts.IncludeList)
+		}
+		if len(opts.IncludeURLs) != 2 {
+			t.Errorf("%s: expected 2 URLs, got %v", name, opts.IncludeURLs)
+		}
+	}
+
+	file := filepath.Join(dir, "definitions.yaml")
+	ioutil.WriteFile(file, []byte(`
+definition:
+  - name=london,inc-cidr=/tmp/london.txt
+  - name: paris
+    priority: 10
+    location: Paris
+    detectors: [DNS, SNMP]
+    inc-cidr: /tmp/paris.txt
+`), 0644)
+	opts := new(Options)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	opts.Register(fs)
+	opts.Parse(fs, []string{"-config", file})
+	definitions, err := opts.GetDefinitions()
+	if err != nil || len(definitions) != 2 {
+		t.Fatalf("cannot parse definitions: %v %v", opts.Definitions, err)
+	}
+	if ds := definitions[1]; ds.Name != "paris" || ds.Priority != 10 || ds.Location != "Paris" || len(ds.Detectors) != 2 || ds.Inputs["inc-cidr"] != "/tmp/paris.txt" {
+		t.Errorf("the definition passed as a map was not applied: %+v", ds)
+	}
+
+	file = filepath.Join(dir, "invalid.yaml")
+	ioutil.WriteFile(file, []byte("unknown-option: true\n"), 0644)
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	new(Options).Register(fs)
+	if err := LoadConfigFile(fs, file); err == nil {
+		t.Errorf("unknown options should fail")
+	}
+}
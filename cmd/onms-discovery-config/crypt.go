@@ -0,0 +1,214 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Encryption at rest for the files and credentials handled by this tool, using a passphrase from the environment or Vault
+
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// KeyEnvVariable is the environment variable with the passphrase used to derive the encryption key
+const KeyEnvVariable = "ONMS_DISCOVERY_KEY"
+
+// Prefix of the encrypted files, and of the encrypted values passed as flags; the content starts with the salt of the key
+var encryptedFileHeader = []byte("ODC-AES256-GCM-SCRYPT\n")
+
+const encryptedValuePrefix = "enc:"
+
+const saltSize = 16
+
+// Work factor of scrypt to derive the keys, as recommended for interactive use
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// ErrNoKey is returned when encrypted content is found but the key is not available
+var ErrNoKey = errors.New("the content is encrypted but neither " + KeyEnvVariable + " nor " + VaultKeyEnvVariable + " are set")
+
+// derivedKeys keeps the keys derived within a run by passphrase and salt, as each derivation is deliberately expensive
+var derivedKeys sync.Map
+
+// encryptionPassphrase returns the passphrase from the environment, or from Vault, or an empty string when encryption is disabled
+func encryptionPassphrase() (string, error) {
+	if passphrase := os.Getenv(KeyEnvVariable); passphrase != "" {
+		return passphrase, nil
+	}
+	if os.Getenv(VaultKeyEnvVariable) != "" {
+		return vaultPassphrase()
+	}
+	return "", nil
+}
+
+// deriveKey returns the AES-256 key for the passphrase and salt, computed with scrypt
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	id := string(salt) + passphrase
+	if key, ok := derivedKeys.Load(id); ok {
+		return key.([]byte), nil
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("cannot derive the encryption key: %v", err)
+	}
+	derivedKeys.Store(id, key)
+	return key, nil
+}
+
+// seal encrypts data with a key derived from the passphrase and a random salt, which is prepended to the result
+func seal(passphrase string, data []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := Encrypt(key, data)
+	if err != nil {
+		return nil, err
+	}
+	return append(salt, sealed...), nil
+}
+
+// unseal decrypts data sealed by seal with the same passphrase
+func unseal(passphrase string, data []byte) ([]byte, error) {
+	if len(data) < saltSize {
+		return nil, fmt.Errorf("encrypted content is too short")
+	}
+	key, err := deriveKey(passphrase, data[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(key, data[saltSize:])
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt seals the data with AES-256-GCM, prepending a random nonce
+func Encrypt(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// Decrypt opens data sealed by Encrypt
+func Decrypt(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted content is too short")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt content (wrong key?): %v", err)
+	}
+	return plain, nil
+}
+
+// sealFile encrypts the content of a file when a key is available
+func sealFile(data []byte) ([]byte, error) {
+	passphrase, err := encryptionPassphrase()
+	if err != nil || passphrase == "" {
+		return data, err
+	}
+	sealed, err := seal(passphrase, data)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, encryptedFileHeader...), sealed...), nil
+}
+
+// openFile decrypts the content of a file when it was encrypted by sealFile
+func openFile(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedFileHeader) {
+		return data, nil
+	}
+	passphrase, err := encryptionPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		return nil, ErrNoKey
+	}
+	return unseal(passphrase, data[len(encryptedFileHeader):])
+}
+
+// EncryptValue returns the encrypted representation of a secret, to be used as a flag value
+func EncryptValue(value string) (string, error) {
+	passphrase, err := encryptionPassphrase()
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("neither %s nor %s are set", KeyEnvVariable, VaultKeyEnvVariable)
+	}
+	sealed, err := seal(passphrase, []byte(value))
+	if err != nil {
+		return "", err
+	}
+	return encryptedValuePrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptValue returns the secret from a value created by EncryptValue; other values are returned as they are
+func DecryptValue(value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedValuePrefix) {
+		return value, nil
+	}
+	passphrase, err := encryptionPassphrase()
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", ErrNoKey
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedValuePrefix))
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value: %v", err)
+	}
+	plain, err := unseal(passphrase, data)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// DecryptSecrets replaces the encrypted credentials passed as flags with their values
+func (o *Options) DecryptSecrets() error {
+	for _, secret := range []*string{&o.RestPassword, &o.RestToken, &o.URLPassword, &o.KarafPassword, &o.NetBoxToken, &o.AzureSecret, &o.VSphereSecret, &o.ServiceNowPassword, &o.AXFRKey, &o.SMTPPassword, &o.KeaPassword} {
+		value, err := DecryptValue(*secret)
+		if err != nil {
+			return fmt.Errorf("cannot decrypt credentials: %v", err)
+		}
+		*secret = value
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEncryptedValues(t *testing.T) {
+	t.Setenv(KeyEnvVariable, "my secret passphrase")
+	value, err := EncryptValue("admin")
+	if err != nil {
+		t.Fatalf("cannot encrypt value: %v", err)
+	}
+	opts := &Options{RestPassword: value, URLPassword: "plain"}
+	if err := opts.DecryptSecrets(); err != nil {
+		t.Fatalf("cannot decrypt secrets: %v", err)
+	}
+	if opts.RestPassword != "admin" || opts.URLPassword != "plain" {
+		t.Errorf("unexpected secrets: %s, %s", opts.RestPassword, opts.URLPassword)
+	}
+
+	t.Setenv(KeyEnvVariable, "another passphrase")
+	if _, err := DecryptValue(value); err == nil {
+		t.Errorf("a wrong key should fail")
+	}
+	t.Setenv(KeyEnvVariable, "")
+	if _, err := DecryptValue(value); !errors.Is(err, ErrNoKey) {
+		t.Errorf("a missing key should fail: %v", err)
+	}
+}
+
+func TestEncryptedCache(t *testing.T) {
+	t.Setenv(KeyEnvVariable, "my secret passphrase")
+	path := filepath.Join(t.TempDir(), "cache.json")
+	cache, _ := LoadLookupCache(path, time.Hour, false)
+	cache.Put("ptr:10.0.0.1", []string{"srv01.example.com."})
+	if err := cache.Save(); err != nil {
+		t.Fatalf("cannot save cache: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !bytes.HasPrefix(data, encryptedFileHeader) || bytes.Contains(data, []byte("srv01")) {
+		t.Errorf("the cache should be encrypted")
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatalf("cannot save cache: %v", err)
+	}
+	if again, _ := os.ReadFile(path); bytes.Equal(again[:len(encryptedFileHeader)+saltSize], data[:len(encryptedFileHeader)+saltSize]) {
+		t.Errorf("each encrypted file should have its own salt")
+	}
+	cache, err := LoadLookupCache(path, time.Hour, false)
+	if err != nil {
+		t.Fatalf("cannot load cache: %v", err)
+	}
+	if _, ok := cache.Get("ptr:10.0.0.1"); !ok {
+		t.Errorf("the cache should contain the saved entry")
+	}
+	t.Setenv(KeyEnvVariable, "")
+	if _, err := LoadLookupCache(path, time.Hour, false); err == nil {
+		t.Errorf("loading an encrypted cache without key should fail")
+	}
+}
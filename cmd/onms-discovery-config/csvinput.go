@@ -0,0 +1,144 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Structured CSV input where each IP address or CIDR carries its own location, foreign-source, retries and timeout
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+// csvColumns are the columns of 'inc-csv', in order; all but the first one are optional
+var csvColumns = []string{"ip_or_cidr", "location", "foreign-source", "retries", "timeout"}
+
+// CSVEntry is an IP address or CIDR with its attributes
+type CSVEntry struct {
+	Address    string
+	Attributes discovery.Attributes
+}
+
+// IsCIDR returns true when the entry is a CIDR instead of an IP address
+func (e *CSVEntry) IsCIDR() bool {
+	return strings.Contains(e.Address, "/")
+}
+
+// LoadCSVEntries reads a CSV file with one ip_or_cidr,location,foreign-source,retries,timeout entry per line.
+// Lines starting with # are ignored, as well as a header line with the names of the columns.
+func LoadCSVEntries(fileName string) ([]*CSVEntry, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening file: %v", err)
+	}
+	defer file.Close()
+	r := csv.NewReader(decodeReader(file))
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	entries := make([]*CSVEntry, 0)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s: %v", fileName, err)
+		}
+		line, _ := r.FieldPos(0)
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+		if len(entries) == 0 && strings.EqualFold(record[0], csvColumns[0]) {
+			continue // Header
+		}
+		if len(record) == 1 && record[0] == "" {
+			continue
+		}
+		entry, err := parseCSVRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry at line %d of %s: %v", line, fileName, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func parseCSVRecord(record []string) (*CSVEntry, error) {
+	if len(record) > len(csvColumns) {
+		return nil, fmt.Errorf("expected at most %d columns (%s), got %d", len(csvColumns), strings.Join(csvColumns, ","), len(record))
+	}
+	for len(record) < len(csvColumns) {
+		record = append(record, "")
+	}
+	entry := &CSVEntry{Address: record[0]}
+	var err error
+	if entry.IsCIDR() {
+		entry.Address, err = iprange.NormalizeCIDR(record[0])
+	} else {
+		entry.Address, err = iprange.NormalizeIP(record[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	entry.Attributes.Location = record[1]
+	entry.Attributes.ForeignSource = record[2]
+	for i, value := range map[int]*int{3: &entry.Attributes.Retries, 4: &entry.Attributes.Timeout} {
+		if record[i] == "" {
+			continue
+		}
+		n, err := ParseNumber(record[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %s", csvColumns[i], record[i])
+		}
+		*value = n
+	}
+	return entry, nil
+}
+
+// definitionFor returns the definition with the location and foreign-source of the attributes, creating it
+// with the settings of the first definition when it doesn't exist
+func definitionFor(cfg *discovery.DiscoveryConfiguration, attrs discovery.Attributes) *discovery.Definition {
+	for i := range cfg.Definitions {
+		if def := &cfg.Definitions[i]; def.Location == attrs.Location && def.ForeignSource == attrs.ForeignSource {
+			return def
+		}
+	}
+	base := cfg.Definitions[0]
+	log.Printf("adding definition for location %s and foreign-source %s", attrs.Location, attrs.ForeignSource)
+	cfg.AddDefinition(discovery.Definition{
+		Location:      attrs.Location,
+		ForeignSource: attrs.ForeignSource,
+		ChunkSize:     base.ChunkSize,
+		Retries:       base.Retries,
+		Timeout:       base.Timeout,
+		Detectors:     base.Detectors,
+		ExcludeRanges: append([]discovery.ExcludeRange{}, base.ExcludeRanges...),
+	})
+	return &cfg.Definitions[len(cfg.Definitions)-1]
+}
+
+// addCSVEntries adds every entry to the definition that matches its location and foreign-source
+func addCSVEntries(cfg *discovery.DiscoveryConfiguration, streamed *streamedSet, entries []*CSVEntry) error {
+	for _, entry := range entries {
+		def := definitionFor(cfg, entry.Attributes)
+		if !entry.IsCIDR() {
+			if err := addSpecific(def, streamed, "inc-csv", entry.Address, entry.Attributes); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := prefixLimit.Check(entry.Address); err != nil {
+			return err
+		}
+		log.Printf("including CIDR %s", entry.Address)
+		def.IncludeCIDRWithAttributes(entry.Address, entry.Attributes)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func writeCSV(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir(os.TempDir(), "_csv")
+	if err != nil {
+		t.Fatalf("cannot create temp directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	fileName := filepath.Join(dir, "inputs.csv")
+	ioutil.WriteFile(fileName, []byte(content), 0644)
+	return fileName
+}
+
+func TestLoadCSVEntries(t *testing.T) {
+	fileName := writeCSV(t, `ip_or_cidr,location,foreign-source,retries,timeout
+# Lab devices
+10.0.0.1,Lab,Lab Devices,2,5000
+10.0.1.0/24, Lab
+"2001:db8::1",,"Core, IPv6",,3000
+`)
+	entries, err := LoadCSVEntries(fileName)
+	if err != nil {
+		t.Fatalf("cannot load entries: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("there should be 3 entries: %+v", entries)
+	}
+	if e := entries[0]; e.Address != "10.0.0.1" || e.IsCIDR() || e.Attributes != (discovery.Attributes{Location: "Lab", ForeignSource: "Lab Devices", Retries: 2, Timeout: 5000}) {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if e := entries[1]; !e.IsCIDR() || e.Attributes != (discovery.Attributes{Location: "Lab"}) {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if e := entries[2]; e.Attributes != (discovery.Attributes{ForeignSource: "Core, IPv6", Timeout: 3000}) {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+
+	for content, msg := range map[string]string{
+		"10.0.0.256,Lab\n":                "invalid IP address",
+		"10.0.0.1,Lab\n10.0.0.0/33\n":     "line 2",
+		"10.0.0.1,Lab,Lab,two\n":          "invalid retries",
+		"10.0.0.1,Lab,Lab,2,5000,extra\n": "at most 5 columns",
+	} {
+		if _, err := LoadCSVEntries(writeCSV(t, content)); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected error with %q for %q, got %v", msg, content, err)
+		}
+	}
+}
+
+func TestAddCSVEntries(t *testing.T) {
+	addressBlackList = make(map[string]bool)
+	addressWhiteList = make(map[string]string)
+	summary = NewSummary()
+	cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{{Retries: 1, Timeout: 2000}, {Location: "Lab"}}}
+	cfg.Definitions[0].ExcludeCIDR("192.168.0.0/24")
+	entries := []*CSVEntry{
+		{Address: "10.0.0.1"},
+		{Address: "10.0.1.0/24", Attributes: discovery.Attributes{Location: "Lab", Retries: 3}},
+		{Address: "172.16.0.1", Attributes: discovery.Attributes{Location: "Paris", ForeignSource: "Paris", Timeout: 5000}},
+		{Address: "172.16.0.2", Attributes: discovery.Attributes{Location: "Paris", ForeignSource: "Paris"}},
+		{Address: "192.168.0.1", Attributes: discovery.Attributes{Location: "Paris", ForeignSource: "Paris"}},
+	}
+	if err := addCSVEntries(cfg, newStreamedSet(), entries); err != nil {
+		t.Fatalf("cannot add entries: %v", err)
+	}
+	if len(cfg.Definitions) != 3 {
+		t.Fatalf("there should be 3 definitions: %s", cfg.String())
+	}
+	if d := cfg.Definitions[0]; len(d.Specifics) != 1 || d.Specifics[0].IP.String() != "10.0.0.1" {
+		t.Errorf("unexpected default definition: %s", cfg.String())
+	}
+	if d := cfg.Definitions[1]; len(d.IncludeRanges) != 1 || d.IncludeRanges[0].Retries != 3 || d.IncludeRanges[0].Location != "Lab" {
+		t.Errorf("the existing definition should be extended: %s", cfg.String())
+	}
+	d := cfg.Definitions[2]
+	if d.Location != "Paris" || d.ForeignSource != "Paris" || d.Retries != 1 || d.Timeout != 2000 || len(d.ExcludeRanges) != 1 {
+		t.Errorf("the new definition should inherit the settings of the first one: %s", cfg.String())
+	}
+	if len(d.Specifics) != 2 || d.Specifics[0].Timeout != 5000 || d.Specifics[1].Timeout != 0 {
+		t.Errorf("the specifics should keep their attributes, and the excluded ones should be skipped: %s", cfg.String())
+	}
+}
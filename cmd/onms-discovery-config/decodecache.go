@@ -0,0 +1,88 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Reuse of the decoded content of expensive input files (NNMi Hex, DNS exports) across runs, while they don't change
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// decodeCacheTTL is how long the decoded content of a file is kept after its last use; as the entries are identified by
+// the hash of the file, they never become stale, and this only discards the content of the files that changed.
+const decodeCacheTTL = 7 * 24 * time.Hour
+
+// decodeCache keeps the decoded content of the input files when 'cache-file' is provided
+var decodeCache *LookupCache
+
+// decodeFile passes the values decoded from each line of a file to emit. When decodeCache is enabled, the values are
+// cached by the hash of the file, and reused without reading its lines again while the content of the file doesn't change.
+func decodeFile(source string, path string, decode func(line string) []string, emit func(value string) error) error {
+	if decodeCache == nil {
+		return scanFile(path, decode, emit)
+	}
+	hash, err := hashFile(path)
+	if err != nil {
+		return err
+	}
+	key := "decode:" + source + ":" + hash
+	values, ok := decodeCache.Get(key)
+	if ok {
+		log.Printf("using the decoded content of %s from the cache", path)
+	} else {
+		values = make([]string, 0)
+		err := scanFile(path, decode, func(value string) error {
+			values = append(values, value)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	decodeCache.PutWithTTL(key, values, decodeCacheTTL) // Extends the validity of the reused entries
+	for _, value := range values {
+		if err := emit(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanFile passes the values decoded from each line of a file to emit
+func scanFile(path string, decode func(line string) []string, emit func(value string) error) error {
+	s, err := getScanner(path)
+	if err != nil {
+		return err
+	}
+	for s.Scan() {
+		for _, value := range decode(s.Text()) {
+			if err := emit(value); err != nil {
+				return err
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("cannot read %s: %v", path, err)
+	}
+	return nil
+}
+
+// hashFile returns the SHA-256 of the content of a file, without loading it in memory
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed opening file: %v", err)
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("cannot read %s: %v", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,66 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeFile(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.txt")
+	os.WriteFile(input, []byte("a\nb\n\nc\n"), 0644)
+	decoded := 0
+	decode := func(line string) []string {
+		decoded++
+		if line == "" {
+			return nil
+		}
+		return []string{strings.ToUpper(line)}
+	}
+	run := func() string {
+		values := make([]string, 0)
+		err := decodeFile("test", input, decode, func(value string) error {
+			values = append(values, value)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("cannot decode file: %v", err)
+		}
+		return strings.Join(values, ",")
+	}
+
+	decodeCache = nil
+	if values := run(); values != "A,B,C" || decoded != 4 {
+		t.Errorf("unexpected values without cache: %s (%d lines decoded)", values, decoded)
+	}
+
+	cacheFile := filepath.Join(dir, "cache.json")
+	cache, _ := LoadLookupCache(cacheFile, time.Hour, false)
+	decodeCache = cache
+	defer func() { decodeCache = nil }()
+	decoded = 0
+	run()
+	if err := cache.Save(); err != nil {
+		t.Fatalf("cannot save cache: %v", err)
+	}
+	decodeCache, _ = LoadLookupCache(cacheFile, time.Hour, false)
+	if values := run(); values != "A,B,C" || decoded != 4 {
+		t.Errorf("the unchanged file should not be decoded again: %s (%d lines decoded)", values, decoded)
+	}
+
+	os.WriteFile(input, []byte("d\n"), 0644)
+	if values := run(); values != "D" || decoded != 5 {
+		t.Errorf("the modified file should be decoded again: %s (%d lines decoded)", values, decoded)
+	}
+
+	decodeCache.Refresh = true
+	os.WriteFile(input, []byte("a\nb\n\nc\n"), 0644)
+	if values := run(); values != "A,B,C" || decoded != 9 {
+		t.Errorf("the cache should be ignored when refreshing: %s (%d lines decoded)", values, decoded)
+	}
+}
@@ -0,0 +1,159 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Additional discovery definitions, each with its own settings and input files
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+type DefinitionSpec struct {
+	Name          string
+	Priority      int // Position in the output, higher first; the default definition has priority 0
+	Tenant        string
+	Location      string
+	ForeignSource string
+	Retries       int
+	Timeout       int
+	Detectors     []string          // Names of the detectors to keep from the default definition; empty means all
+	Inputs        map[string]string // Input options by name, e.x. inc-cidr
+}
+
+// definitionInputs are the input options accepted by a definition
+var definitionInputs = []string{"exc-cidr", "exc-list", "inc-cidr", "inc-list", "inc-dns", "inc-dns-locations", "inc-hexnnmi", "inc-dhcp-leases", "inc-nmap", "inc-kea", "inc-netbox", "inc-aws", "inc-azure", "inc-gcp", "inc-k8s", "inc-vsphere"}
+
+// ParseDefinitionSpec parses a definition; e.x. name=paris,priority=10,location=Paris,foreign-source=Paris,detectors=DNS;SNMP,inc-cidr=/tmp/paris.txt
+func ParseDefinitionSpec(spec string) (*DefinitionSpec, error) {
+	ds := &DefinitionSpec{Inputs: make(map[string]string)}
+	for _, entry := range strings.Split(spec, ",") {
+		pair := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("invalid definition attribute '%s'", entry)
+		}
+		key, value := strings.TrimSpace(pair[0]), strings.TrimSpace(pair[1])
+		switch key {
+		case "name":
+			ds.Name = value
+		case "priority":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid definition priority '%s'", value)
+			}
+			ds.Priority = n
+		case "tenant":
+			ds.Tenant = value
+		case "location":
+			ds.Location = value
+		case "foreign-source":
+			ds.ForeignSource = value
+		case "retries", "timeout":
+			n, err := ParseNumber(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid definition %s '%s'", key, value)
+			}
+			if key == "retries" {
+				ds.Retries = n
+			} else {
+				ds.Timeout = n
+			}
+		case "detectors":
+			for _, name := range strings.Split(value, ";") {
+				if name = strings.TrimSpace(name); name != "" {
+					ds.Detectors = append(ds.Detectors, name)
+				}
+			}
+		default:
+			valid := false
+			for _, input := range definitionInputs {
+				valid = valid || input == key
+			}
+			if !valid {
+				return nil, fmt.Errorf("unknown definition attribute '%s'", key)
+			}
+			ds.Inputs[key] = value
+		}
+	}
+	if len(ds.Inputs) == 0 {
+		return nil, fmt.Errorf("at least one input is required for definition '%s'", spec)
+	}
+	if ds.Name == "" {
+		ds.Name = ds.Location
+	}
+	if err := discovery.ValidateName(ds.Name); err != nil {
+		return nil, fmt.Errorf("invalid definition '%s': %v", spec, err)
+	}
+	return ds, nil
+}
+
+// NewDefinition returns an empty definition based on the given one, with the settings of the spec
+func (ds *DefinitionSpec) NewDefinition(base *discovery.Definition) discovery.Definition {
+	def := discovery.Definition{
+		Name:          ds.Name,
+		Priority:      ds.Priority,
+		Location:      ds.Location,
+		ForeignSource: ds.ForeignSource,
+		ChunkSize:     base.ChunkSize,
+		Retries:       base.Retries,
+		Timeout:       base.Timeout,
+	}
+	if ds.Retries > 0 {
+		def.Retries = ds.Retries
+	}
+	if ds.Timeout > 0 {
+		def.Timeout = ds.Timeout
+	}
+	for _, d := range base.Detectors {
+		keep := len(ds.Detectors) == 0
+		for _, name := range ds.Detectors {
+			if strings.EqualFold(name, d.Name) {
+				keep = true
+			}
+		}
+		if keep {
+			def.Detectors = append(def.Detectors, d)
+		}
+	}
+	return def
+}
+
+// Options returns a copy of the given options with the inputs of the definition only
+func (ds *DefinitionSpec) Options(o *Options) *Options {
+	opts := *o
+	opts.ExcludeCIDR = ds.Inputs["exc-cidr"]
+	opts.ExcludeList = ds.Inputs["exc-list"]
+	opts.IncludeCIDR = ds.Inputs["inc-cidr"]
+	opts.IncludeList = ds.Inputs["inc-list"]
+	opts.IncludeDNS = ds.Inputs["inc-dns"]
+	opts.DNSLocations = ds.Inputs["inc-dns-locations"]
+	opts.IncludeNNMiHex = ds.Inputs["inc-hexnnmi"]
+	opts.IncludeDHCP = ds.Inputs["inc-dhcp-leases"]
+	opts.IncludeNmap = ds.Inputs["inc-nmap"]
+	opts.IncludeKea = ds.Inputs["inc-kea"]
+	opts.IncludeNetBox = ds.Inputs["inc-netbox"]
+	opts.IncludeAWS = ds.Inputs["inc-aws"]
+	opts.IncludeAzure = ds.Inputs["inc-azure"]
+	opts.IncludeGCP = ds.Inputs["inc-gcp"]
+	opts.IncludeK8s = ds.Inputs["inc-k8s"]
+	opts.IncludeVSphere = ds.Inputs["inc-vsphere"]
+	opts.IncludeURLs = nil
+	opts.IncludeAXFR = nil
+	return &opts
+}
+
+// GetDefinitions returns the additional definitions
+func (o *Options) GetDefinitions() ([]*DefinitionSpec, error) {
+	specs := make([]*DefinitionSpec, 0, len(o.Definitions))
+	for _, spec := range o.Definitions {
+		ds, err := ParseDefinitionSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, ds)
+	}
+	return specs, nil
+}
@@ -0,0 +1,47 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestParseDefinitionSpec(t *testing.T) {
+	ds, err := ParseDefinitionSpec("location=Paris,foreign-source=Paris,retries=2,detectors=DNS,inc-cidr=/tmp/paris.txt,exc-list=/tmp/ignore.txt")
+	if err != nil {
+		t.Fatalf("cannot parse definition: %v", err)
+	}
+	if ds.Name != "Paris" || ds.ForeignSource != "Paris" || ds.Retries != 2 || len(ds.Detectors) != 1 {
+		t.Errorf("unexpected definition: %+v", ds)
+	}
+	if ds.Inputs["inc-cidr"] != "/tmp/paris.txt" || ds.Inputs["exc-list"] != "/tmp/ignore.txt" {
+		t.Errorf("unexpected inputs: %v", ds.Inputs)
+	}
+
+	base := &discovery.Definition{Retries: 1, Timeout: 2000, ChunkSize: 100, Detectors: []discovery.Detector{{Name: "DNS"}, {Name: "SNMP"}}}
+	def := ds.NewDefinition(base)
+	if def.Name != "Paris" || def.Location != "Paris" || def.Retries != 2 || def.Timeout != 2000 || def.ChunkSize != 100 {
+		t.Errorf("unexpected settings: %+v", def)
+	}
+	if len(def.Detectors) != 1 || def.Detectors[0].Name != "DNS" {
+		t.Errorf("only the DNS detector should be kept: %v", def.Detectors)
+	}
+
+	opts := ds.Options(&Options{IncludeList: "/tmp/global.txt", IncludeURLs: StringList{"http://server/ips.txt"}, IncludeAXFR: StringList{"example.com@ns1"}, DryRun: true})
+	if opts.IncludeCIDR != "/tmp/paris.txt" || opts.IncludeList != "" || len(opts.IncludeURLs) != 0 || len(opts.IncludeAXFR) != 0 || !opts.DryRun {
+		t.Errorf("only the inputs of the definition should be used: %+v", opts)
+	}
+
+	ds, err = ParseDefinitionSpec("name=fallback,priority=-5,inc-cidr=/tmp/fallback.txt")
+	if err != nil || ds.Priority != -5 || ds.NewDefinition(base).Priority != -5 {
+		t.Errorf("the priority should be parsed and kept: %+v %v", ds, err)
+	}
+
+	for _, spec := range []string{"location=Paris", "location=Paris,inc-url=http://server", "location=Paris,retries=x,inc-cidr=/tmp/a.txt", "location=Paris,priority=high,inc-cidr=/tmp/a.txt", "name=a--b,location=Paris,inc-cidr=/tmp/a.txt", "name=a-,location=Paris,inc-cidr=/tmp/a.txt"} {
+		if _, err := ParseDefinitionSpec(spec); err == nil {
+			t.Errorf("%s should be invalid", spec)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Tracking of the deployed configuration to detect out-of-band changes to discovery-configuration.xml
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+// ErrManualChanges is returned when the configuration was modified after the last deployment
+var ErrManualChanges = errors.New("the discovery configuration was modified outside of this tool since the last deployment")
+
+func discoveryConfigPath(onmsHomePath string) string {
+	return filepath.Join(onmsHomePath, "etc", "discovery-configuration.xml")
+}
+
+func checksumPath(onmsHomePath string) string {
+	return discoveryConfigPath(onmsHomePath) + ".sha256"
+}
+
+// The copy of the previous configuration, kept when updating it
+func backupPath(onmsHomePath string) string {
+	return discoveryConfigPath(onmsHomePath) + ".bak"
+}
+
+// The copy of the last deployed configuration, used as the base for three-way merges
+func deployedPath(onmsHomePath string) string {
+	return discoveryConfigPath(onmsHomePath) + ".deployed"
+}
+
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyDeployed compares the current configuration against the checksum of the last deployment.
+// Nothing is verified when there are no records from a previous deployment.
+func VerifyDeployed(onmsHomePath string) error {
+	recorded, err := ioutil.ReadFile(checksumPath(onmsHomePath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read checksum of the last deployment: %v", err)
+	}
+	current, err := ioutil.ReadFile(discoveryConfigPath(onmsHomePath))
+	if err != nil {
+		return fmt.Errorf("cannot read discovery configuration: %v", err)
+	}
+	if Checksum(current) != strings.TrimSpace(string(recorded)) {
+		return ErrManualChanges
+	}
+	return nil
+}
+
+// RecordDeployed saves the checksum and a copy of the current configuration
+func RecordDeployed(onmsHomePath string) error {
+	current, err := ioutil.ReadFile(discoveryConfigPath(onmsHomePath))
+	if err != nil {
+		return fmt.Errorf("cannot read discovery configuration: %v", err)
+	}
+	if err := ioutil.WriteFile(deployedPath(onmsHomePath), current, 0644); err != nil {
+		return fmt.Errorf("cannot save a copy of the deployed configuration: %v", err)
+	}
+	if err := ioutil.WriteFile(checksumPath(onmsHomePath), []byte(Checksum(current)+"\n"), 0644); err != nil {
+		return fmt.Errorf("cannot save checksum of the deployed configuration: %v", err)
+	}
+	return nil
+}
+
+func loadConfiguration(fileName string) (*discovery.DiscoveryConfiguration, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfiguration(fileName, data)
+}
+
+func parseConfiguration(fileName string, data []byte) (*discovery.DiscoveryConfiguration, error) {
+	cfg := new(discovery.DiscoveryConfiguration)
+	if err := xml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %v", fileName, err)
+	}
+	return cfg, nil
+}
+
+// MergeManualChanges merges the changes applied to the current configuration since the last deployment into the generated one
+func MergeManualChanges(onmsHomePath string, generated *discovery.DiscoveryConfiguration) (*discovery.DiscoveryConfiguration, []string, error) {
+	base, err := loadConfiguration(deployedPath(onmsHomePath))
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot load the last deployed configuration: %v", err)
+	}
+	current, err := loadConfiguration(discoveryConfigPath(onmsHomePath))
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot load the current configuration: %v", err)
+	}
+	merged, conflicts := discovery.ThreeWayMerge(base, current, generated)
+	return merged, conflicts, nil
+}
+
+// resolveManualChanges handles the changes applied outside of this tool since the last deployment, as reported by verify:
+// with -merge-manual they are merged into the generated configuration, with -force they are overwritten, and otherwise
+// the deployment is refused. It returns the configuration to deploy.
+func (o *Options) resolveManualChanges(target string, cfg *discovery.DiscoveryConfiguration, verify func() error, merge func(*discovery.DiscoveryConfiguration) (*discovery.DiscoveryConfiguration, []string, error)) (*discovery.DiscoveryConfiguration, error) {
+	err := verify()
+	switch {
+	case err == nil:
+		return cfg, nil
+	case errors.Is(err, ErrManualChanges) && o.MergeManual:
+		merged, conflicts, err := merge(cfg)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range conflicts {
+			log.Printf("%s: conflict: %s", target, c)
+		}
+		if len(conflicts) > 0 && !o.Force {
+			return nil, fmt.Errorf("%w; %d conflicts with the manual changes require human review; use -force to apply the merged configuration", ErrManualChanges, len(conflicts))
+		}
+		log.Printf("%s: manual changes merged into the generated configuration", target)
+		return merged, nil
+	case o.Force:
+		log.Printf("%s: %v; overwriting it", target, err)
+		return cfg, nil
+	default:
+		return nil, fmt.Errorf("%w; use -force to overwrite it or -merge-manual to merge the changes", err)
+	}
+}
+
+// deployViaRest updates the configuration through the ReST API, with the same checks of the manual changes as the files
+func (o *Options) deployViaRest(cfg *discovery.DiscoveryConfiguration) error {
+	if !target.Supports(FeatureFilesystemAPI) {
+		return fmt.Errorf("%s doesn't support the %s", target, FeatureFilesystemAPI)
+	}
+	client := o.restClient()
+	verify := func() error { return VerifyDeployedViaRest(client) }
+	merge := func(generated *discovery.DiscoveryConfiguration) (*discovery.DiscoveryConfiguration, []string, error) {
+		return MergeManualChangesViaRest(client, generated)
+	}
+	cfg, err := o.resolveManualChanges("ReST API", cfg, verify, merge)
+	if err != nil {
+		return err
+	}
+	log.Printf("saving discovery configuration and notifying OpenNMS via %s", o.RestURL)
+	policy := UpdatePolicy{Retries: o.NotifyRetries, Delay: o.NotifyDelay, Rollback: !o.NoRollback}
+	err = UpdateOpenNMSViaRest(cfg, client, policy)
+	if err != nil && !errors.Is(err, ErrNotReloaded) {
+		return err
+	}
+	if recErr := RecordDeployedViaRest(client); recErr != nil {
+		log.Printf("ReST API: %v", recErr)
+	}
+	return err
+}
+
+// deploy updates the configuration of the given OpenNMS instance, handling changes applied outside of this tool
+func (o *Options) deploy(cfg *discovery.DiscoveryConfiguration, instance *Instance) error {
+	if o.RestPush {
+		if o.RestURL == "" || len(o.Instances) > 0 {
+			return fmt.Errorf("the ReST push requires 'rest-url', and it doesn't support multiple instances")
+		}
+		err := o.deployViaRest(cfg)
+		if err == nil || errors.Is(err, ErrNoChanges) || errors.Is(err, ErrNotReloaded) || errors.Is(err, ErrManualChanges) {
+			return err
+		}
+		if _, statErr := os.Stat(discoveryConfigPath(instance.Home)); statErr != nil {
+			return err
+		}
+		log.Printf("cannot update OpenNMS via ReST: %v; falling back to the files", err)
+	}
+	verify := func() error { return VerifyDeployed(instance.Home) }
+	merge := func(generated *discovery.DiscoveryConfiguration) (*discovery.DiscoveryConfiguration, []string, error) {
+		return MergeManualChanges(instance.Home, generated)
+	}
+	cfg, err := o.resolveManualChanges("instance "+instance.Name, cfg, verify, merge)
+	if err != nil {
+		return err
+	}
+	log.Printf("saving discovery configuration and notifying OpenNMS instance %s", instance.Name)
+	policy := UpdatePolicy{Retries: o.NotifyRetries, Delay: o.NotifyDelay, Rollback: !o.NoRollback}
+	sendOptions, err := o.eventOptions()
+	if err != nil {
+		return err
+	}
+	policy.Events = sendOptions
+	if o.KarafAddress != "" {
+		policy.Reload = o.karafShell().Reload
+	}
+	err = UpdateOpenNMSWithPolicy(cfg, instance.Home, instance.Host, instance.Port, policy)
+	if err != nil && !errors.Is(err, ErrNotReloaded) {
+		return err
+	}
+	// The written configuration is tracked whenever it is kept, so it is not reported as a manual change on the next run
+	if recErr := RecordDeployed(instance.Home); recErr != nil {
+		log.Printf("instance %s: %v", instance.Name, recErr)
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestDeploymentTracking(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_discovery")
+	if err != nil {
+		t.Fatalf("cannot create temp directory: %v", err)
+	}
+	os.Mkdir(dir+"/etc", 0755)
+	defer os.RemoveAll(dir)
+
+	file := discoveryConfigPath(dir)
+	if err := os.WriteFile(file, []byte("<discovery-configuration/>"), 0644); err != nil {
+		t.Fatalf("cannot create discovery configuration")
+	}
+	if err := VerifyDeployed(dir); err != nil {
+		t.Errorf("there should be nothing to verify without a previous deployment: %v", err)
+	}
+	if err := RecordDeployed(dir); err != nil {
+		t.Fatalf("cannot record deployment: %v", err)
+	}
+	if err := VerifyDeployed(dir); err != nil {
+		t.Errorf("the configuration should match the last deployment: %v", err)
+	}
+	if err := os.WriteFile(file, []byte("<discovery-configuration retries=\"3\"/>"), 0644); err != nil {
+		t.Fatalf("cannot update discovery configuration")
+	}
+	if err := VerifyDeployed(dir); err != ErrManualChanges {
+		t.Errorf("the manual changes should have been detected: %v", err)
+	}
+}
+
+func TestDeployWithoutRollback(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(dir+"/etc", 0755)
+	os.WriteFile(discoveryConfigPath(dir), []byte("<discovery-configuration/>"), 0644)
+	if err := RecordDeployed(dir); err != nil {
+		t.Fatalf("cannot record deployment: %v", err)
+	}
+	cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{{}}}
+	cfg.Definitions[0].AddSpecific("10.0.0.1")
+
+	// Nothing listens on the port, so the reload event cannot be delivered, but the new configuration is kept
+	opts := &Options{NoRollback: true}
+	instance := &Instance{Name: "default", Home: dir, Host: "127.0.0.1", Port: 50818}
+	if err := opts.deploy(cfg, instance); !errors.Is(err, ErrNotReloaded) {
+		t.Fatalf("the deployment should fail without reloading: %v", err)
+	}
+	if err := VerifyDeployed(dir); err != nil {
+		t.Errorf("the kept configuration should be recorded as deployed: %v", err)
+	}
+}
+
+func TestMergeManualChanges(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_discovery")
+	if err != nil {
+		t.Fatalf("cannot create temp directory: %v", err)
+	}
+	os.Mkdir(dir+"/etc", 0755)
+	defer os.RemoveAll(dir)
+
+	deployed := discovery.Definition{}
+	deployed.AddSpecific("10.0.0.1")
+	cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{deployed}}
+	os.WriteFile(discoveryConfigPath(dir), []byte(cfg.String()), 0644)
+	if err := RecordDeployed(dir); err != nil {
+		t.Fatalf("cannot record deployment: %v", err)
+	}
+
+	cfg.Definitions[0].AddSpecific("10.0.0.100") // Manual edit
+	os.WriteFile(discoveryConfigPath(dir), []byte(cfg.String()), 0644)
+
+	generated := discovery.Definition{}
+	generated.AddSpecific("10.0.0.1")
+	generated.AddSpecific("10.0.0.2")
+	merged, conflicts, err := MergeManualChanges(dir, &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{generated}})
+	if err != nil {
+		t.Fatalf("cannot merge changes: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Errorf("there should be no conflicts: %v", conflicts)
+	}
+	if len(merged.Definitions[0].Specifics) != 3 {
+		t.Errorf("the merged configuration should have 3 specifics: %s", merged.String())
+	}
+}
@@ -0,0 +1,143 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Detectors defined in a YAML or JSON file, either explicitly or based on a library of presets
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"gopkg.in/yaml.v3"
+)
+
+// detectorPresets are the built-in detectors selectable by name
+var detectorPresets = map[string]discovery.Detector{
+	"icmp": {
+		Name:  "ICMP",
+		Class: "org.opennms.netmgt.provision.detector.icmp.IcmpDetector",
+	},
+	"snmp": {
+		Name:       "SNMP",
+		Class:      "org.opennms.netmgt.provision.detector.snmp.SnmpDetector",
+		Parameters: []discovery.Parameter{{Key: "useSnmpProfiles", Value: "true"}},
+	},
+	"http": {
+		Name:       "HTTP",
+		Class:      "org.opennms.netmgt.provision.detector.simple.HttpDetector",
+		Parameters: []discovery.Parameter{{Key: "port", Value: "80"}},
+	},
+	"ssh": {
+		Name:       "SSH",
+		Class:      "org.opennms.netmgt.provision.detector.ssh.SshDetector",
+		Parameters: []discovery.Parameter{{Key: "port", Value: "22"}},
+	},
+}
+
+// DetectorPresets returns the sorted names of the built-in detectors
+func DetectorPresets() []string {
+	names := make([]string, 0, len(detectorPresets))
+	for name := range detectorPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type DetectorSpec struct {
+	Preset     string            `yaml:"preset"`
+	Name       string            `yaml:"name"`
+	Class      string            `yaml:"class"`
+	Parameters map[string]string `yaml:"parameters"`
+}
+
+// ToDetector returns the detector of the spec; the name and the parameters override the ones from the preset
+func (ds *DetectorSpec) ToDetector() (discovery.Detector, error) {
+	detector := discovery.Detector{Name: ds.Name, Class: ds.Class}
+	params := make(map[string]string)
+	if ds.Preset != "" {
+		preset, ok := detectorPresets[strings.ToLower(ds.Preset)]
+		if !ok {
+			return detector, fmt.Errorf("unknown detector preset %s; valid presets are %s", ds.Preset, strings.Join(DetectorPresets(), ", "))
+		}
+		if detector.Name == "" {
+			detector.Name = preset.Name
+		}
+		if detector.Class == "" {
+			detector.Class = preset.Class
+		}
+		for _, p := range preset.Parameters {
+			params[p.Key] = p.Value
+		}
+	}
+	if detector.Name == "" || detector.Class == "" {
+		return detector, fmt.Errorf("the name and the class are required for detectors without a preset")
+	}
+	for key, value := range ds.Parameters {
+		params[key] = value
+	}
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		detector.Parameters = append(detector.Parameters, discovery.Parameter{Key: key, Value: params[key]})
+	}
+	return detector, nil
+}
+
+type DetectorsFile struct {
+	Default     []DetectorSpec            `yaml:"default"`     // Detectors of the default definition
+	Definitions map[string][]DetectorSpec `yaml:"definitions"` // Detectors of the additional definitions by name
+}
+
+// LoadDetectorsFile reads a YAML or JSON file with the detectors of the default definition and the additional ones
+func LoadDetectorsFile(fileName string) (*DetectorsFile, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read detectors file: %v", err)
+	}
+	file := &DetectorsFile{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data)) // JSON is valid YAML
+	decoder.KnownFields(true)
+	if err := decoder.Decode(file); err != nil {
+		return nil, fmt.Errorf("cannot parse detectors file %s: %v", fileName, err)
+	}
+	if _, err := file.Get(""); err != nil {
+		return nil, err
+	}
+	for name := range file.Definitions {
+		if _, err := file.Get(name); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+// Get returns the detectors for a given definition (empty for the default one), or nil when the file doesn't have them
+func (f *DetectorsFile) Get(definition string) ([]discovery.Detector, error) {
+	specs := f.Default
+	if definition != "" {
+		specs = f.Definitions[definition]
+	}
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	detectors := make([]discovery.Detector, 0, len(specs))
+	for i := range specs {
+		d, err := specs[i].ToDetector()
+		if err != nil {
+			if definition == "" {
+				return nil, fmt.Errorf("default detector %d: %v", i+1, err)
+			}
+			return nil, fmt.Errorf("definition %s, detector %d: %v", definition, i+1, err)
+		}
+		detectors = append(detectors, d)
+	}
+	return detectors, nil
+}
@@ -0,0 +1,75 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectorSpec(t *testing.T) {
+	spec := DetectorSpec{Preset: "HTTP", Name: "HTTPS", Parameters: map[string]string{"port": "443", "useSSLFilter": "true"}}
+	d, err := spec.ToDetector()
+	if err != nil {
+		t.Fatalf("cannot get detector: %v", err)
+	}
+	if d.Name != "HTTPS" || d.Class != detectorPresets["http"].Class {
+		t.Errorf("incorrect detector: %+v", d)
+	}
+	if len(d.Parameters) != 2 || d.Parameters[0].Key != "port" || d.Parameters[0].Value != "443" || d.Parameters[1].Key != "useSSLFilter" {
+		t.Errorf("incorrect parameters: %+v", d.Parameters)
+	}
+	if _, err := (&DetectorSpec{Preset: "telnet"}).ToDetector(); err == nil {
+		t.Errorf("telnet should not be a valid preset")
+	}
+	if _, err := (&DetectorSpec{Name: "Custom"}).ToDetector(); err == nil {
+		t.Errorf("the class should be required without a preset")
+	}
+}
+
+func TestLoadDetectorsFile(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_detectors")
+	if err != nil {
+		t.Fatalf("cannot create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "detectors.yaml")
+	ioutil.WriteFile(file, []byte(`
+default:
+  - preset: icmp
+  - preset: snmp
+definitions:
+  paris:
+    - name: Custom
+      class: org.opennms.netmgt.provision.detector.simple.TcpDetector
+      parameters:
+        port: "8080"
+`), 0644)
+	detectors, err := LoadDetectorsFile(file)
+	if err != nil {
+		t.Fatalf("cannot load detectors: %v", err)
+	}
+	def, _ := detectors.Get("")
+	if len(def) != 2 || def[0].Name != "ICMP" || def[1].Name != "SNMP" {
+		t.Errorf("incorrect default detectors: %+v", def)
+	}
+	paris, _ := detectors.Get("paris")
+	if len(paris) != 1 || paris[0].Name != "Custom" || paris[0].Parameters[0].Value != "8080" {
+		t.Errorf("incorrect detectors for paris: %+v", paris)
+	}
+	if none, _ := detectors.Get("berlin"); none != nil {
+		t.Errorf("there should be no detectors for berlin: %+v", none)
+	}
+
+	ioutil.WriteFile(file, []byte("default:\n  - preset: icmp\n    clas: typo\n"), 0644)
+	if _, err := LoadDetectorsFile(file); err == nil {
+		t.Errorf("unknown fields should be rejected")
+	}
+	ioutil.WriteFile(file, []byte("definitions:\n  paris:\n    - preset: telnet\n"), 0644)
+	if _, err := LoadDetectorsFile(file); err == nil {
+		t.Errorf("unknown presets should be rejected")
+	}
+}
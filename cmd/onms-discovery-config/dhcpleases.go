@@ -0,0 +1,96 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// ISC DHCP lease files (dhcpd.leases) as a source of the IPv4 addresses of the DHCP clients
+// https://kb.isc.org/docs/en/isc-dhcp-44-manual-pages-dhcpdleases
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DHCPLease is the latest state of the lease of an address
+type DHCPLease struct {
+	IP     string
+	State  string    // Binding state, e.x. active, free or backup
+	Starts time.Time // Zero when unknown
+	Ends   time.Time // Zero when the lease never ends
+}
+
+// Active returns true when the lease is bound and has not expired, and it started at least minAge ago
+func (l *DHCPLease) Active(now time.Time, minAge time.Duration) bool {
+	if l.State != "active" || (!l.Ends.IsZero() && !l.Ends.After(now)) {
+		return false
+	}
+	return minAge <= 0 || (!l.Starts.IsZero() && now.Sub(l.Starts) >= minAge)
+}
+
+// parseLeaseTime parses the date of a lease statement, either as 'W YYYY/MM/DD HH:MM:SS' in UTC, 'epoch N', or 'never'
+func parseLeaseTime(fields []string) (time.Time, error) {
+	switch {
+	case len(fields) == 1 && fields[0] == "never":
+		return time.Time{}, nil
+	case len(fields) >= 2 && fields[0] == "epoch":
+		seconds, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid epoch %s", fields[1])
+		}
+		return time.Unix(seconds, 0).UTC(), nil
+	case len(fields) >= 3:
+		return time.ParseInLocation("2006/01/02 15:04:05", fields[1]+" "+fields[2], time.UTC)
+	}
+	return time.Time{}, fmt.Errorf("invalid date %s", strings.Join(fields, " "))
+}
+
+// ParseDHCPLeases returns the latest state of every lease; as the file is a journal, the last entry of an address wins
+func ParseDHCPLeases(s *bufio.Scanner) ([]*DHCPLease, error) {
+	leases := make([]*DHCPLease, 0)
+	index := make(map[string]int)
+	var current *DHCPLease
+	lineNumber := 0
+	for s.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(s.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(strings.TrimSuffix(line, ";"))
+		switch {
+		case current == nil && fields[0] == "lease" && len(fields) == 3 && fields[2] == "{":
+			current = &DHCPLease{IP: fields[1]}
+		case current == nil:
+			continue // Other declarations, e.x. server-duid, failover peer or lease6
+		case fields[0] == "}":
+			if i, ok := index[current.IP]; ok {
+				leases[i] = current
+			} else {
+				index[current.IP] = len(leases)
+				leases = append(leases, current)
+			}
+			current = nil
+		case fields[0] == "starts" || fields[0] == "ends":
+			t, err := parseLeaseTime(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid lease for %s at line %d: %v", current.IP, lineNumber, err)
+			}
+			if fields[0] == "starts" {
+				current.Starts = t
+			} else {
+				current.Ends = t
+			}
+		case len(fields) == 3 && fields[0] == "binding" && fields[1] == "state":
+			current.State = fields[2]
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return leases, nil // The last lease is ignored when incomplete, as dhcpd might be writing it
+}
@@ -0,0 +1,79 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testDHCPLeases = `# The format of this file is documented in the dhcpd.leases(5) manual page.
+authoring-byte-order little-endian;
+server-duid "\000\001\000\001";
+
+lease 10.0.0.10 {
+  starts 4 2024/03/14 10:00:00;
+  ends 5 2024/03/15 10:00:00;
+  binding state free;
+}
+lease 10.0.0.11 {
+  starts 4 2024/03/14 10:00:00;
+  ends 6 2024/03/16 10:00:00;
+  cltt 4 2024/03/14 10:00:00;
+  binding state active;
+  next binding state free;
+  hardware ethernet 00:11:22:33:44:55;
+  client-hostname "printer#1";
+}
+lease 10.0.0.12 {
+  starts epoch 1710455400; # Thu Mar 14 22:30:00 2024
+  ends never;
+  binding state active;
+}
+lease 10.0.0.13 {
+  starts 3 2024/03/13 10:00:00;
+  ends 4 2024/03/14 10:00:00;
+  binding state active;
+}
+lease 10.0.0.10 {
+  starts 5 2024/03/15 09:00:00;
+  ends 6 2024/03/16 09:00:00;
+  binding state active;
+}
+lease 10.0.0.14 {
+  starts 5 2024/03/15 09:59:00;
+`
+
+func TestDHCPLeases(t *testing.T) {
+	leases, err := ParseDHCPLeases(newScanner(strings.NewReader(testDHCPLeases)))
+	if err != nil {
+		t.Fatalf("cannot parse leases: %v", err)
+	}
+	if len(leases) != 4 {
+		t.Fatalf("expected 4 leases, got %d", len(leases))
+	}
+	now := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	active := func(minAge time.Duration) string {
+		ips := make([]string, 0)
+		for _, l := range leases {
+			if l.Active(now, minAge) {
+				ips = append(ips, l.IP)
+			}
+		}
+		return strings.Join(ips, ",")
+	}
+	if ips := active(0); ips != "10.0.0.10,10.0.0.11,10.0.0.12" {
+		t.Errorf("unexpected active leases: %s", ips)
+	}
+	if ips := active(12 * time.Hour); ips != "10.0.0.11" {
+		t.Errorf("unexpected active leases older than 12 hours: %s", ips)
+	}
+	if !leases[2].Ends.IsZero() || !leases[2].Starts.Equal(time.Date(2024, 3, 14, 22, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected dates: %+v", leases[2])
+	}
+
+	if _, err := ParseDHCPLeases(newScanner(strings.NewReader("lease 10.0.0.1 {\n  starts 4 yesterday;\n}\n"))); err == nil {
+		t.Errorf("the date should be invalid")
+	}
+}
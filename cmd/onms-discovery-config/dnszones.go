@@ -0,0 +1,79 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Mapping of DNS views and zones from the DNS export to OpenNMS locations
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var dnsViewRegex = regexp.MustCompile(`view: (\S+)`)
+var dnsZoneRegex = regexp.MustCompile(`zone: (\S+)`)
+var dnsNameRegex = regexp.MustCompile(`\b(?:fqdn|name): (\S+)`)
+
+// ZoneLocations maps DNS views or zones to locations
+type ZoneLocations map[string]string
+
+// LoadZoneLocations reads a file with one view=location or zone=location entry per line
+func LoadZoneLocations(fileName string) (ZoneLocations, error) {
+	s, err := getScanner(fileName)
+	if err != nil {
+		return nil, err
+	}
+	locations := make(ZoneLocations)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pair := strings.SplitN(line, "=", 2)
+		if len(pair) != 2 || strings.TrimSpace(pair[0]) == "" || strings.TrimSpace(pair[1]) == "" {
+			return nil, fmt.Errorf("invalid location mapping '%s' in %s", line, fileName)
+		}
+		locations[strings.ToLower(strings.TrimSpace(pair[0]))] = strings.TrimSpace(pair[1])
+	}
+	return locations, s.Err()
+}
+
+// Lookup returns the location for a line from the DNS export based on its view, or its zone (in that order)
+func (z ZoneLocations) Lookup(line string) (string, bool) {
+	return z.LookupKeys(zoneKeys(line))
+}
+
+// LookupKeys returns the location for a view, or a zone (in that order), as returned by zoneKeys
+func (z ZoneLocations) LookupKeys(view, zone string) (string, bool) {
+	for _, key := range []string{view, zone} {
+		if location, ok := z[key]; ok && key != "" {
+			return location, true
+		}
+	}
+	return "", false
+}
+
+// zoneKeys returns the view and the zone of a line from the DNS export, in the form used by the mapping (empty when missing)
+func zoneKeys(line string) (string, string) {
+	keys := make([]string, 2)
+	for i, re := range []*regexp.Regexp{dnsViewRegex, dnsZoneRegex} {
+		if match := re.FindStringSubmatch(line); len(match) == 2 {
+			keys[i] = strings.ToLower(strings.TrimSuffix(match[1], "."))
+		}
+	}
+	return keys[0], keys[1]
+}
+
+// recordName returns the forward name of a line from the DNS export (empty when missing)
+func recordName(line string) string {
+	if match := dnsNameRegex.FindStringSubmatch(line); len(match) == 2 {
+		return strings.ToLower(strings.TrimSuffix(match[1], "."))
+	}
+	return ""
+}
+
+// LookupZone returns the location for a zone, e.x. from a zone transfer
+func (z ZoneLocations) LookupZone(zone string) (string, bool) {
+	location, ok := z[strings.ToLower(strings.TrimSuffix(zone, "."))]
+	return location, ok
+}
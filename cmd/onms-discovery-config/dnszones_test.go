@@ -0,0 +1,46 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestZoneLocations(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_zones")
+	if err != nil {
+		t.Fatalf("cannot create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "zones.txt")
+	os.WriteFile(file, []byte("# Branches\nbranch-view=Branch\nlab.example.com=Lab\n"), 0644)
+
+	locations, err := LoadZoneLocations(file)
+	if err != nil {
+		t.Fatalf("cannot load locations: %v", err)
+	}
+	if location, ok := locations.Lookup("ipv4addr: 10.0.0.1 view: branch-view zone: example.com"); !ok || location != "Branch" {
+		t.Errorf("the view should map to Branch: %s", location)
+	}
+	if location, ok := locations.Lookup("ipv4addr: 10.0.0.2 view: default zone: lab.example.com."); !ok || location != "Lab" {
+		t.Errorf("the zone should map to Lab: %s", location)
+	}
+	if _, ok := locations.Lookup("ipv4addr: 10.0.0.3"); ok {
+		t.Errorf("records without view or zone should not have a location")
+	}
+
+	if name := recordName("fqdn: Printer01.Example.com. ipv4addr: 10.0.0.4 view: default"); name != "printer01.example.com" {
+		t.Errorf("unexpected name: %s", name)
+	}
+	if name := recordName("ipv4addr: 10.0.0.4 view: default"); name != "" {
+		t.Errorf("records without name should not have one: %s", name)
+	}
+
+	os.WriteFile(file, []byte("invalid\n"), 0644)
+	if _, err := LoadZoneLocations(file); err == nil {
+		t.Errorf("invalid mappings should fail")
+	}
+}
@@ -0,0 +1,111 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Delivery of the summary of a run and the changes to the configuration via email,
+// for teams without webhook infrastructure
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+type EmailSender struct {
+	Server   string // host:port of the SMTP server; STARTTLS is used when the server supports it
+	User     string // Optional, for PLAIN authentication
+	Password string
+	From     string
+	To       []string
+}
+
+// Message returns the email with the headers, using CRLF as line separator
+func (e *EmailSender) Message(subject, body string, now time.Time) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	for _, line := range strings.Split(strings.TrimRight(body, "\n"), "\n") {
+		msg.WriteString(line + "\r\n")
+	}
+	return msg.Bytes()
+}
+
+// Send delivers the email to all the recipients
+func (e *EmailSender) Send(subject, body string) error {
+	var auth smtp.Auth
+	if e.User != "" {
+		host, _, err := net.SplitHostPort(e.Server)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP server %s: %v", e.Server, err)
+		}
+		auth = smtp.PlainAuth("", e.User, e.Password, host)
+	}
+	return smtp.SendMail(e.Server, auth, e.From, e.To, e.Message(subject, body, time.Now()))
+}
+
+// SummaryEmail returns the subject and the body of the email with the summary of a run and the differences with the current configuration
+func SummaryEmail(host string, dryRun bool, s *Summary, diff []string) (string, string) {
+	mode := "OpenNMS will be updated"
+	if dryRun {
+		mode = "dry-run, OpenNMS will not be updated"
+	}
+	subject := fmt.Sprintf("Discovery configuration from %s: %d changes", host, len(diff))
+	var body strings.Builder
+	fmt.Fprintf(&body, "Discovery configuration generated on %s at %s (%s)", host, s.Generation.Time, mode)
+	if s.Generation.Change != "" {
+		fmt.Fprintf(&body, " for change %s", s.Generation.Change)
+	}
+	body.WriteString(".\n\nChanges to the current configuration:\n\n")
+	if len(diff) == 0 {
+		body.WriteString("none\n")
+	}
+	for _, d := range diff {
+		body.WriteString(d + "\n")
+	}
+	fmt.Fprintf(&body, "\nSummary:\n\n%s\n", s.String())
+	return subject, body.String()
+}
+
+// emailSummary sends the summary of the run with the differences against the current configuration,
+// only when there are changes unless 'email-always' is enabled
+func emailSummary(opts *Options) {
+	if opts.EmailFrom == "" || opts.EmailTo == "" {
+		log.Printf("cannot send summary email: the sender and the recipients are required; use 'email-from' and 'email-to'")
+		return
+	}
+	current, err := loadConfiguration(discoveryConfigPath(opts.OnmsHome))
+	if err != nil {
+		log.Printf("the current configuration is not available, so the summary email reports the whole configuration as new: %v", err)
+		current = new(discovery.DiscoveryConfiguration)
+	}
+	diff := Drift(current, baseConfig)
+	if len(diff) == 0 && !opts.EmailAlways {
+		log.Printf("there are no changes to report via email")
+		return
+	}
+	sender := &EmailSender{
+		Server:   opts.SMTPServer,
+		User:     opts.SMTPUser,
+		Password: opts.SMTPPassword,
+		From:     opts.EmailFrom,
+		To:       splitNames(opts.EmailTo),
+	}
+	hostname, _ := os.Hostname()
+	subject, body := SummaryEmail(hostname, opts.DryRun, summary, diff)
+	if err := sender.Send(subject, body); err != nil {
+		log.Printf("cannot send summary email: %v", err)
+		return
+	}
+	log.Printf("summary email sent to %s", strings.Join(sender.To, ", "))
+}
@@ -0,0 +1,101 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpTestServer accepts a single email, and sends the recipients and the data through the channel
+func smtpTestServer(t *testing.T) (string, <-chan []string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	received := make(chan []string, 1)
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost ESMTP")
+		lines := make([]string, 0)
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO":
+				text.PrintfLine("250 localhost")
+			case "MAIL", "RCPT":
+				lines = append(lines, line)
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 Go ahead")
+				data, _ := text.ReadDotLines()
+				lines = append(lines, data...)
+				text.PrintfLine("250 OK")
+			case "QUIT":
+				text.PrintfLine("221 Bye")
+				received <- lines
+				return
+			default:
+				text.PrintfLine("502 Not implemented")
+			}
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestEmailSender(t *testing.T) {
+	server, received := smtpTestServer(t)
+	sender := &EmailSender{Server: server, From: "discovery@example.com", To: []string{"noc@example.com", "ops@example.com"}}
+	s := NewSummary()
+	s.Generation = GenerationInfo{Time: "2024-03-15T22:00:00Z", Change: "CHG000123"}
+	subject, body := SummaryEmail("server1", true, s, []string{"+ definition location=Default,foreign-source=: 10.0.0.1"})
+	if subject != "Discovery configuration from server1: 1 changes" {
+		t.Errorf("unexpected subject: %s", subject)
+	}
+	if !strings.Contains(body, "dry-run") || !strings.Contains(body, "for change CHG000123") {
+		t.Errorf("unexpected body: %s", body)
+	}
+	if err := sender.Send(subject, body); err != nil {
+		t.Fatalf("cannot send email: %v", err)
+	}
+	select {
+	case lines := <-received:
+		all := strings.Join(lines, "\n")
+		for _, expected := range []string{"MAIL FROM:<discovery@example.com>", "RCPT TO:<noc@example.com>", "RCPT TO:<ops@example.com>", "Subject: " + subject, "To: noc@example.com, ops@example.com", "+ definition location=Default,foreign-source=: 10.0.0.1"} {
+			if !strings.Contains(all, expected) {
+				t.Errorf("the email should contain '%s': %s", expected, all)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the email was not received")
+	}
+}
+
+func TestEmailMessage(t *testing.T) {
+	sender := &EmailSender{From: "a@example.com", To: []string{"b@example.com"}}
+	msg := sender.Message("test", "line1\nline2\n", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC))
+	r := textproto.NewReader(bufio.NewReader(strings.NewReader(string(msg))))
+	header, err := r.ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("invalid message: %v", err)
+	}
+	if header.Get("Date") != "Fri, 15 Mar 2024 00:00:00 +0000" || header.Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Errorf("unexpected headers: %v", header)
+	}
+	if !strings.HasSuffix(string(msg), "\r\n\r\nline1\r\nline2\r\n") {
+		t.Errorf("unexpected body: %q", string(msg))
+	}
+}
@@ -0,0 +1,62 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Transparent handling of the encodings used by exports from Windows systems (UTF-16 and UTF-8 with BOM)
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// detectEncoding guesses the encoding of UTF-16 content without BOM, based on the position of the NUL bytes,
+// as all the characters in the input files are expected to be ASCII.
+func detectEncoding(head []byte) *unicode.Endianness {
+	even, odd := 0, 0
+	for i, b := range head {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+	half := len(head) / 4
+	var endianness unicode.Endianness
+	switch {
+	case half == 0:
+		return nil
+	case odd > half && even == 0:
+		endianness = unicode.LittleEndian
+	case even > half && odd == 0:
+		endianness = unicode.BigEndian
+	default:
+		return nil
+	}
+	return &endianness
+}
+
+// decodeReader returns a reader that produces UTF-8 content without BOM, regardless of the encoding of the source
+func decodeReader(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(64)
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		br.Discard(3)
+		return br
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}), bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		decoder := unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder()
+		return transform.NewReader(br, decoder)
+	}
+	if endianness := detectEncoding(head); endianness != nil {
+		decoder := unicode.UTF16(*endianness, unicode.IgnoreBOM).NewDecoder()
+		return transform.NewReader(br, decoder)
+	}
+	return br
+}
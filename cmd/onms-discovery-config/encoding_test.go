@@ -0,0 +1,48 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+	"unicode/utf16"
+)
+
+func encodeUTF16(text string, bigEndian, bom bool) []byte {
+	buf := new(bytes.Buffer)
+	units := utf16.Encode([]rune(text))
+	if bom {
+		units = append([]uint16{0xFEFF}, units...)
+	}
+	for _, u := range units {
+		if bigEndian {
+			buf.Write([]byte{byte(u >> 8), byte(u)})
+		} else {
+			buf.Write([]byte{byte(u), byte(u >> 8)})
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeReader(t *testing.T) {
+	text := "10.0.0.1\r\n10.0.0.2\r\n"
+	inputs := map[string][]byte{
+		"plain":             []byte(text),
+		"utf-8 with bom":    append([]byte{0xEF, 0xBB, 0xBF}, text...),
+		"utf-16le with bom": encodeUTF16(text, false, true),
+		"utf-16be with bom": encodeUTF16(text, true, true),
+		"utf-16le no bom":   encodeUTF16(text, false, false),
+		"utf-16be no bom":   encodeUTF16(text, true, false),
+	}
+	for name, data := range inputs {
+		s := bufio.NewScanner(decodeReader(bytes.NewReader(data)))
+		lines := make([]string, 0)
+		for s.Scan() {
+			lines = append(lines, s.Text())
+		}
+		if len(lines) != 2 || lines[0] != "10.0.0.1" || lines[1] != "10.0.0.2" {
+			t.Errorf("%s: unexpected content %q", name, lines)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Exclusion of the nodes managed by other requisitions, so discovery doesn't rediscover them
+
+package main
+
+import (
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+// GetIPAddressesByForeignSource returns the unique IP addresses of the interfaces from the nodes of the given foreign sources
+func (c *RestClient) GetIPAddressesByForeignSource(foreignSources []string) ([]string, error) {
+	interfaces, err := c.searchIPInterfaces(foreignSources)
+	if err != nil {
+		return nil, err
+	}
+	return uniqueAddresses(interfaces), nil
+}
+
+// ExcludeAddresses adds an exclude range for each address covered by the include ranges of the definitions,
+// as the blacklist only applies to the specifics; it returns the number of exclude ranges added.
+func ExcludeAddresses(cfg *discovery.DiscoveryConfiguration, addresses []string) int {
+	count := 0
+	for i := range cfg.Definitions {
+		def := &cfg.Definitions[i]
+		covered := make([]string, 0)
+		for _, ip := range addresses {
+			if def.IncludeRangesContain(ip) && !def.ExcludeRangesContain(ip) {
+				covered = append(covered, ip)
+			}
+		}
+		for _, ip := range covered {
+			if err := def.AddExcludeRange(ip, ip); err == nil {
+				count++
+			}
+		}
+	}
+	return count
+}
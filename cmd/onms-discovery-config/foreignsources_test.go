@@ -0,0 +1,45 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestExcludeForeignSources(t *testing.T) {
+	mock := NewMockOpenNMS(MockData{Interfaces: []IPInterface{
+		{IPAddress: "10.0.0.1", ForeignSource: "Cloud"},
+		{IPAddress: "10.0.0.2", ForeignSource: "Servers"},
+		{IPAddress: "10.0.1.1", ForeignSource: "Edge"},
+		{IPAddress: "10.0.0.1", ForeignSource: "Cloud"},
+	}}, nil)
+	server := httptest.NewServer(mock)
+	defer server.Close()
+
+	client := NewRestClient(server.URL+"/opennms", "", "")
+	addresses, err := client.GetIPAddressesByForeignSource([]string{"Cloud", "Edge"})
+	if err != nil {
+		t.Fatalf("cannot get addresses: %v", err)
+	}
+	if strings.Join(addresses, ",") != "10.0.0.1,10.0.1.1" {
+		t.Errorf("only the unique addresses of the requested foreign sources should be returned: %v", addresses)
+	}
+
+	cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{{}, {}}}
+	cfg.Definitions[0].AddIncludeRange("10.0.0.1", "10.0.0.254")
+	cfg.Definitions[0].AddExcludeRange("10.0.1.1", "10.0.1.1")
+	cfg.Definitions[1].AddIncludeRange("10.0.1.1", "10.0.1.254")
+	if count := ExcludeAddresses(cfg, addresses); count != 2 {
+		t.Errorf("expected 2 exclude ranges, got %d", count)
+	}
+	if len(cfg.Definitions[0].ExcludeRanges) != 2 || !cfg.Definitions[0].ExcludeRangesContain("10.0.0.1") {
+		t.Errorf("the covered address should be excluded: %s", cfg.Definitions[0].String())
+	}
+	if len(cfg.Definitions[1].ExcludeRanges) != 1 || !cfg.Definitions[1].ExcludeRangesContain("10.0.1.1") {
+		t.Errorf("only the covered address should be excluded: %s", cfg.Definitions[1].String())
+	}
+}
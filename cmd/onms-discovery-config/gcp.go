@@ -0,0 +1,381 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Google Cloud Compute Engine as a source of the internal IP addresses of the instances and the VPC subnetworks
+// https://cloud.google.com/compute/docs/reference/rest/v1/instances/aggregatedList
+
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const gcpComputeScope = "https://www.googleapis.com/auth/compute.readonly"
+
+// gcpResources are the values accepted by -inc-gcp
+var gcpResources = []string{"instances", "subnets", "all"}
+
+// GCPServiceAccount is the content of the JSON key of a service account
+type GCPServiceAccount struct {
+	Type        string `json:"type"`
+	ProjectID   string `json:"project_id"`
+	PrivateKey  string `json:"private_key"`
+	ClientEmail string `json:"client_email"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// LoadGCPServiceAccount reads the JSON key of a service account, from the given file or GOOGLE_APPLICATION_CREDENTIALS;
+// it returns nil without a file, to use the credentials of the instance from the metadata server.
+func LoadGCPServiceAccount(fileName string) (*GCPServiceAccount, error) {
+	if fileName == "" {
+		if fileName = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); fileName == "" {
+			return nil, nil
+		}
+	}
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the GCP credentials: %v", err)
+	}
+	account := new(GCPServiceAccount)
+	if err := json.Unmarshal(data, account); err != nil {
+		return nil, fmt.Errorf("invalid GCP credentials %s: %v", fileName, err)
+	}
+	if account.Type != "service_account" || account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, fmt.Errorf("invalid GCP credentials %s: a service account key is required", fileName)
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return account, nil
+}
+
+type GCPClient struct {
+	Endpoint    string // e.x. https://compute.googleapis.com/compute/v1
+	MetadataURL string // e.x. http://metadata.google.internal/computeMetadata/v1
+	Project     string
+	Account     *GCPServiceAccount // Uses the metadata server when nil
+	Client      *http.Client
+	token       string
+	expires     time.Time
+}
+
+func NewGCPClient(project string, account *GCPServiceAccount) *GCPClient {
+	return &GCPClient{
+		Endpoint:    "https://compute.googleapis.com/compute/v1",
+		MetadataURL: "http://metadata.google.internal/computeMetadata/v1",
+		Project:     project,
+		Account:     account,
+		Client:      &http.Client{Timeout: 60 * time.Second, Transport: apiTransport},
+	}
+}
+
+// gcpClient returns the client and the label filters for the given resources of 'inc-gcp'
+func (o *Options) gcpClient(resources string) (*GCPClient, []TagFilter, error) {
+	valid := false
+	for _, r := range gcpResources {
+		valid = valid || r == resources
+	}
+	if !valid {
+		return nil, nil, fmt.Errorf("invalid GCP resources %s; valid resources are %s", resources, strings.Join(gcpResources, ", "))
+	}
+	labels, err := ParseTagFilter(o.GCPLabelFilter)
+	if err != nil {
+		return nil, nil, err
+	}
+	account, err := LoadGCPServiceAccount(o.GCPCredentials)
+	if err != nil {
+		return nil, nil, err
+	}
+	project := o.GCPProject
+	if project == "" {
+		project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+	if project == "" && account != nil {
+		project = account.ProjectID
+	}
+	if project == "" {
+		return nil, nil, fmt.Errorf("the GCP project is required; use 'gcp-project' or GOOGLE_CLOUD_PROJECT")
+	}
+	return NewGCPClient(project, account), labels, nil
+}
+
+// signJWT returns the assertion to request an access token for the service account
+func (a *GCPServiceAccount) signJWT(now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(a.PrivateKey))
+	if block == nil {
+		return "", fmt.Errorf("invalid private key for %s", a.ClientEmail)
+	}
+	var key *rsa.PrivateKey
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		var ok bool
+		if key, ok = parsed.(*rsa.PrivateKey); !ok {
+			return "", fmt.Errorf("the private key for %s is not an RSA key", a.ClientEmail)
+		}
+	} else if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		return "", fmt.Errorf("invalid private key for %s: %v", a.ClientEmail, err)
+	}
+	encode := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	claims := map[string]interface{}{
+		"iss":   a.ClientEmail,
+		"scope": gcpComputeScope,
+		"aud":   a.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+	content := encode(map[string]string{"alg": "RS256", "typ": "JWT"}) + "." + encode(claims)
+	hash := sha256.Sum256([]byte(content))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("cannot sign the token request for %s: %v", a.ClientEmail, err)
+	}
+	return content + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// authenticate gets an access token for the service account, or for the instance from the metadata server, reusing it until it expires
+func (c *GCPClient) authenticate() (string, error) {
+	if c.token != "" && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+	var req *http.Request
+	if c.Account != nil {
+		assertion, err := c.Account.signJWT(time.Now())
+		if err != nil {
+			return "", err
+		}
+		form := url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {assertion}}
+		if req, err = http.NewRequest(http.MethodPost, c.Account.TokenURI, strings.NewReader(form.Encode())); err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		var err error
+		path := strings.TrimSuffix(c.MetadataURL, "/") + "/instance/service-accounts/default/token?scopes=" + url.QueryEscape(gcpComputeScope)
+		if req, err = http.NewRequest(http.MethodGet, path, nil); err != nil {
+			return "", err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+	}
+	var resp *http.Response
+	err := lookupThrottle.Do(func() error {
+		var e error
+		resp, e = c.Client.Do(req)
+		return e
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return "", fmt.Errorf("cannot authenticate with GCP: %s: %s", resp.Status, string(body))
+	}
+	data := struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return "", fmt.Errorf("invalid response from GCP authentication: %v", err)
+	}
+	c.token = data.AccessToken
+	c.expires = time.Now().Add(time.Duration(data.ExpiresIn)*time.Second - time.Minute)
+	return c.token, nil
+}
+
+// list gets an aggregated list of the project following the pagination, passing every page of the response to the handler
+func (c *GCPClient) list(resource string, handle func(data []byte) error) error {
+	pageToken := ""
+	for {
+		token, err := c.authenticate()
+		if err != nil {
+			return err
+		}
+		query := url.Values{"maxResults": {"500"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		path := fmt.Sprintf("%s/projects/%s/aggregated/%s?%s", strings.TrimSuffix(c.Endpoint, "/"), url.PathEscape(c.Project), resource, query.Encode())
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		var resp *http.Response
+		err = lookupThrottle.Do(func() error {
+			var e error
+			resp, e = c.Client.Do(req)
+			return e
+		})
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("cannot read the %s of %s: %v", resource, c.Project, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("GET %s failed with %s: %s", path, resp.Status, string(data))
+		}
+		page := struct {
+			NextPageToken string `json:"nextPageToken"`
+		}{}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return fmt.Errorf("invalid response from GCP: %v", err)
+		}
+		if err := handle(data); err != nil {
+			return fmt.Errorf("invalid response from GCP: %v", err)
+		}
+		if pageToken = page.NextPageToken; pageToken == "" {
+			return nil
+		}
+	}
+}
+
+// gcpLabelsMatch returns true when the labels have one of the values of every filter
+func gcpLabelsMatch(labels map[string]string, filters []TagFilter) bool {
+	for _, f := range filters {
+		value, ok := labels[f.Key]
+		found := false
+		for _, v := range f.Values {
+			found = found || (ok && v == value)
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+type gcpInstance struct {
+	Status            string            `json:"status"`
+	Labels            map[string]string `json:"labels"`
+	NetworkInterfaces []struct {
+		NetworkIP   string `json:"networkIP"`
+		IPv6Address string `json:"ipv6Address"`
+		Subnetwork  string `json:"subnetwork"` // Self link
+	} `json:"networkInterfaces"`
+}
+
+// instances returns the running instances that match the label filters
+func (c *GCPClient) instances(filters []TagFilter) ([]gcpInstance, error) {
+	list := make([]gcpInstance, 0)
+	err := c.list("instances", func(data []byte) error {
+		page := struct {
+			Items map[string]struct {
+				Instances []gcpInstance `json:"instances"`
+			} `json:"items"`
+		}{}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		zones := make([]string, 0, len(page.Items))
+		for zone := range page.Items {
+			zones = append(zones, zone)
+		}
+		sort.Strings(zones)
+		for _, zone := range zones {
+			for _, instance := range page.Items[zone].Instances {
+				if instance.Status == "RUNNING" && gcpLabelsMatch(instance.Labels, filters) {
+					list = append(list, instance)
+				}
+			}
+		}
+		return nil
+	})
+	return list, err
+}
+
+// GetIPAddresses returns the internal IPv4 and IPv6 addresses of the running instances that match the label filters
+func (c *GCPClient) GetIPAddresses(filters []TagFilter) ([]string, error) {
+	instances, err := c.instances(filters)
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]string, 0)
+	for _, instance := range instances {
+		for _, intf := range instance.NetworkInterfaces {
+			for _, ip := range []string{intf.NetworkIP, intf.IPv6Address} {
+				if ip != "" {
+					addresses = append(addresses, ip)
+				}
+			}
+		}
+	}
+	return addresses, nil
+}
+
+// GetSubnets returns the primary and secondary CIDRs of the subnetworks; as subnetworks have no labels,
+// only the ones with running instances that match the label filters are returned when there are filters.
+func (c *GCPClient) GetSubnets(filters []TagFilter) ([]string, error) {
+	var used map[string]bool
+	if len(filters) > 0 {
+		instances, err := c.instances(filters)
+		if err != nil {
+			return nil, err
+		}
+		used = make(map[string]bool)
+		for _, instance := range instances {
+			for _, intf := range instance.NetworkInterfaces {
+				used[intf.Subnetwork] = true
+			}
+		}
+	}
+	cidrs := make([]string, 0)
+	err := c.list("subnetworks", func(data []byte) error {
+		page := struct {
+			Items map[string]struct {
+				Subnetworks []struct {
+					SelfLink          string `json:"selfLink"`
+					IPCidrRange       string `json:"ipCidrRange"`
+					SecondaryIPRanges []struct {
+						IPCidrRange string `json:"ipCidrRange"`
+					} `json:"secondaryIpRanges"`
+					InternalIPv6Prefix string `json:"internalIpv6Prefix"`
+				} `json:"subnetworks"`
+			} `json:"items"`
+		}{}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		regions := make([]string, 0, len(page.Items))
+		for region := range page.Items {
+			regions = append(regions, region)
+		}
+		sort.Strings(regions)
+		for _, region := range regions {
+			for _, subnet := range page.Items[region].Subnetworks {
+				if used != nil && !used[subnet.SelfLink] {
+					continue
+				}
+				if subnet.IPCidrRange != "" {
+					cidrs = append(cidrs, subnet.IPCidrRange)
+				}
+				for _, r := range subnet.SecondaryIPRanges {
+					cidrs = append(cidrs, r.IPCidrRange)
+				}
+				if subnet.InternalIPv6Prefix != "" {
+					cidrs = append(cidrs, subnet.InternalIPv6Prefix)
+				}
+			}
+		}
+		return nil
+	})
+	return cidrs, err
+}
@@ -0,0 +1,185 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const gcpInstancesPage1 = `{
+  "items": {
+    "zones/us-east1-b": {"instances": [
+      {"status": "RUNNING", "labels": {"env": "prod"}, "networkInterfaces": [{"networkIP": "10.0.0.2", "subnetwork": "https://x/subnetworks/a"}]},
+      {"status": "TERMINATED", "labels": {"env": "prod"}, "networkInterfaces": [{"networkIP": "10.0.0.3", "subnetwork": "https://x/subnetworks/a"}]}
+    ]},
+    "zones/us-west1-a": {"warning": {"code": "NO_RESULTS_ON_PAGE"}}
+  },
+  "nextPageToken": "page2"
+}`
+
+const gcpInstancesPage2 = `{
+  "items": {
+    "zones/us-east1-c": {"instances": [
+      {"status": "RUNNING", "labels": {"env": "dev"}, "networkInterfaces": [{"networkIP": "10.0.1.2", "subnetwork": "https://x/subnetworks/b"}]},
+      {"status": "RUNNING", "labels": {"env": "staging"}, "networkInterfaces": [{"networkIP": "10.0.0.4", "ipv6Address": "fd20::4", "subnetwork": "https://x/subnetworks/a"}]}
+    ]}
+  }
+}`
+
+const gcpSubnetworks = `{
+  "items": {
+    "regions/us-east1": {"subnetworks": [
+      {"selfLink": "https://x/subnetworks/a", "ipCidrRange": "10.0.0.0/24", "secondaryIpRanges": [{"ipCidrRange": "10.100.0.0/20"}], "internalIpv6Prefix": "fd20::/64"},
+      {"selfLink": "https://x/subnetworks/b", "ipCidrRange": "10.0.1.0/24"}
+    ]}
+  }
+}`
+
+func TestGCPLabelsMatch(t *testing.T) {
+	filters := []TagFilter{{Key: "env", Values: []string{"prod", "staging"}}, {Key: "discovery", Values: []string{"true"}}}
+	if !gcpLabelsMatch(map[string]string{"env": "staging", "discovery": "true"}, filters) {
+		t.Errorf("the labels should match")
+	}
+	if gcpLabelsMatch(map[string]string{"env": "staging"}, filters) {
+		t.Errorf("the labels should not match without discovery")
+	}
+	if !gcpLabelsMatch(nil, nil) {
+		t.Errorf("everything should match without filters")
+	}
+}
+
+func TestGCPClient(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	logins := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			r.ParseForm()
+			parts := strings.Split(r.Form.Get("assertion"), ".")
+			if len(parts) != 3 || r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hash[:], signature); err != nil {
+				t.Errorf("invalid signature: %v", err)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			claims := make(map[string]interface{})
+			payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+			json.Unmarshal(payload, &claims)
+			if claims["iss"] != "discovery@my-project.iam.gserviceaccount.com" || claims["scope"] != gcpComputeScope {
+				t.Errorf("invalid claims: %v", claims)
+			}
+			logins++
+			w.Write([]byte(`{"access_token":"my-token","expires_in":3600}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer my-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/projects/my-project/aggregated/instances":
+			if r.URL.Query().Get("pageToken") == "page2" {
+				w.Write([]byte(gcpInstancesPage2))
+			} else {
+				w.Write([]byte(gcpInstancesPage1))
+			}
+		case "/projects/my-project/aggregated/subnetworks":
+			w.Write([]byte(gcpSubnetworks))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	account := map[string]string{
+		"type":         "service_account",
+		"project_id":   "my-project",
+		"client_email": "discovery@my-project.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    server.URL + "/token",
+	}
+	fileName := filepath.Join(t.TempDir(), "key.json")
+	data, _ := json.Marshal(account)
+	if err := ioutil.WriteFile(fileName, data, 0600); err != nil {
+		t.Fatalf("cannot write key: %v", err)
+	}
+
+	opts := &Options{GCPCredentials: fileName, GCPLabelFilter: "env=prod|staging"}
+	if _, _, err := opts.gcpClient("vms"); err == nil {
+		t.Errorf("the resources should be invalid")
+	}
+	client, labels, err := opts.gcpClient("all")
+	if err != nil {
+		t.Fatalf("cannot create client: %v", err)
+	}
+	if client.Project != "my-project" {
+		t.Errorf("the project should come from the service account, got %s", client.Project)
+	}
+	client.Endpoint = server.URL
+
+	addresses, err := client.GetIPAddresses(labels)
+	if err != nil {
+		t.Fatalf("cannot get IP addresses: %v", err)
+	}
+	if strings.Join(addresses, ",") != "10.0.0.2,10.0.0.4,fd20::4" {
+		t.Errorf("unexpected IP addresses: %v", addresses)
+	}
+	subnets, err := client.GetSubnets(labels)
+	if err != nil {
+		t.Fatalf("cannot get subnets: %v", err)
+	}
+	if strings.Join(subnets, ",") != "10.0.0.0/24,10.100.0.0/20,fd20::/64" {
+		t.Errorf("unexpected subnets: %v", subnets)
+	}
+	subnets, err = client.GetSubnets(nil)
+	if err != nil {
+		t.Fatalf("cannot get subnets: %v", err)
+	}
+	if len(subnets) != 4 {
+		t.Errorf("expected all the subnets without filters, got %v", subnets)
+	}
+	if logins != 1 {
+		t.Errorf("the token should be reused, got %d logins", logins)
+	}
+}
+
+func TestGCPMetadataToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/instance/service-accounts/default/token" || r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"access_token":"instance-token","expires_in":3600}`))
+	}))
+	defer server.Close()
+	client := NewGCPClient("my-project", nil)
+	client.MetadataURL = server.URL
+	token, err := client.authenticate()
+	if err != nil {
+		t.Fatalf("cannot authenticate: %v", err)
+	}
+	if token != "instance-token" {
+		t.Errorf("unexpected token %s", token)
+	}
+}
@@ -0,0 +1,48 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Traceability of the generated configuration back to the build of the tool and the run that produced it
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Set at build time by goreleaser via ldflags
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
+)
+
+type GenerationInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Date    string `json:"date"`             // When the tool was built
+	Time    string `json:"time"`             // When the configuration was generated
+	Change  string `json:"change,omitempty"` // Change ticket of the run
+}
+
+// generation describes the current run, or the one that produced the applied artifact
+var generation GenerationInfo
+
+func NewGenerationInfo(t time.Time) GenerationInfo {
+	return GenerationInfo{
+		Version: version,
+		Commit:  commit,
+		Date:    date,
+		Time:    t.Format(time.RFC3339),
+	}
+}
+
+// Comment returns the XML comment added at the beginning of the deployed configuration; empty when unknown
+func (g GenerationInfo) Comment() string {
+	if g.Time == "" {
+		return ""
+	}
+	if g.Change != "" {
+		return fmt.Sprintf("<!-- Generated by onms-discovery-config %s (commit %s, built %s) at %s for change %s -->\n", g.Version, g.Commit, g.Date, g.Time, g.Change)
+	}
+	return fmt.Sprintf("<!-- Generated by onms-discovery-config %s (commit %s, built %s) at %s -->\n", g.Version, g.Commit, g.Date, g.Time)
+}
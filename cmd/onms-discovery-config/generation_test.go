@@ -0,0 +1,54 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestGenerationComment(t *testing.T) {
+	if comment := (GenerationInfo{}).Comment(); comment != "" {
+		t.Errorf("there should be no comment for an unknown generation: %s", comment)
+	}
+	info := NewGenerationInfo(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	info.Version, info.Commit, info.Date = "1.2.3", "abc123", "2024-01-01T00:00:00Z"
+	expected := "<!-- Generated by onms-discovery-config 1.2.3 (commit abc123, built 2024-01-01T00:00:00Z) at 2024-01-02T03:04:05Z -->\n"
+	if comment := info.Comment(); comment != expected {
+		t.Errorf("unexpected comment: %s", comment)
+	}
+	info.Change = "CHG000123"
+	if comment := info.Comment(); !strings.HasSuffix(comment, "at 2024-01-02T03:04:05Z for change CHG000123 -->\n") {
+		t.Errorf("the comment should contain the change ticket: %s", comment)
+	}
+}
+
+func TestUpdateOpenNMSWithComment(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(dir+"/etc", 0755)
+	os.WriteFile(dir+"/etc/discovery-configuration.xml", []byte("<discovery-configuration/>"), 0644)
+	generation = NewGenerationInfo(time.Now())
+	defer func() { generation = GenerationInfo{} }()
+
+	cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{{}}}
+	cfg.Definitions[0].AddSpecific("10.0.0.1")
+	policy := UpdatePolicy{Reload: func() error { return nil }}
+	if err := UpdateOpenNMSWithPolicy(cfg, dir, "127.0.0.1", 0, policy); err != nil {
+		t.Fatalf("cannot update configuration: %v", err)
+	}
+	data, _ := os.ReadFile(dir + "/etc/discovery-configuration.xml")
+	if !strings.HasPrefix(string(data), "<!-- Generated by onms-discovery-config dev") {
+		t.Errorf("the configuration should start with the generation comment: %s", string(data))
+	}
+	if err := xml.Unmarshal(data, new(discovery.DiscoveryConfiguration)); err != nil {
+		t.Errorf("the configuration should be valid: %v", err)
+	}
+	if err := UpdateOpenNMSWithPolicy(cfg, dir, "127.0.0.1", 0, policy); err != ErrNoChanges {
+		t.Errorf("the comment should not be considered a change: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Historical per-run metrics about the scope, appended to a time series file
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+// HistoryRecord is a sample of a metric from a given run
+type HistoryRecord struct {
+	Time   string   `json:"time"`
+	Metric string   `json:"metric"` // total, definition or source
+	Name   string   `json:"name"`   // The definition key or the source name
+	Value  *big.Int `json:"value"`
+}
+
+// NewHistoryRecords returns the metrics of the generated configuration: the total estimated addresses,
+// the estimated addresses per definition, and the added addresses per source.
+func NewHistoryRecords(time string, cfg *discovery.DiscoveryConfiguration, s *Summary) []HistoryRecord {
+	records := []HistoryRecord{{Time: time, Metric: "total", Value: cfg.GetTotalEstimatedAddresses()}}
+	for i := range cfg.Definitions {
+		def := &cfg.Definitions[i]
+		records = append(records, HistoryRecord{Time: time, Metric: "definition", Name: discovery.DefinitionKey(def), Value: def.GetTotalEstimatedAddresses()})
+	}
+	names := make([]string, 0, len(s.Sources))
+	for name := range s.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		records = append(records, HistoryRecord{Time: time, Metric: "source", Name: name, Value: big.NewInt(int64(s.Sources[name].Added))})
+	}
+	return records
+}
+
+// AppendHistory appends the records to the given file, as JSON lines when its extension is .json, or as CSV otherwise
+func AppendHistory(fileName string, records []HistoryRecord) error {
+	info, err := os.Stat(fileName)
+	isNew := err != nil || info.Size() == 0
+	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open history file: %v", err)
+	}
+	defer file.Close()
+	if strings.EqualFold(filepath.Ext(fileName), ".json") {
+		enc := json.NewEncoder(file)
+		for _, r := range records {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	w := csv.NewWriter(file)
+	if isNew {
+		w.Write([]string{"time", "metric", "name", "value"})
+	}
+	for _, r := range records {
+		w.Write([]string{r.Time, r.Metric, r.Name, r.Value.String()})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,71 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestHistory(t *testing.T) {
+	cfg := new(discovery.DiscoveryConfiguration)
+	def := discovery.Definition{Location: "Default"}
+	def.IncludeCIDR("10.0.0.0/24")
+	cfg.AddDefinition(def)
+	s := NewSummary()
+	s.Source("inc-list").Added = 5
+	records := NewHistoryRecords("2020-01-01T00:00:00Z", cfg, s)
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %v", records)
+	}
+	if records[1].Metric != "definition" || records[1].Name != "location=Default,foreign-source=" || records[1].Value.Cmp(records[0].Value) != 0 {
+		t.Errorf("unexpected definition record: %v", records[1])
+	}
+	if records[2].Metric != "source" || records[2].Name != "inc-list" || records[2].Value.Int64() != 5 {
+		t.Errorf("unexpected source record: %v", records[2])
+	}
+
+	dir, err := ioutil.TempDir(os.TempDir(), "_history")
+	if err != nil {
+		t.Fatalf("cannot create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	csvFile := filepath.Join(dir, "history.csv")
+	for i := 0; i < 2; i++ {
+		if err := AppendHistory(csvFile, records); err != nil {
+			t.Fatalf("cannot append history: %v", err)
+		}
+	}
+	data, _ := ioutil.ReadFile(csvFile)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 7 || lines[0] != "time,metric,name,value" {
+		t.Errorf("the header must be written once, followed by all the records: %v", lines)
+	}
+
+	jsonFile := filepath.Join(dir, "history.json")
+	for i := 0; i < 2; i++ {
+		if err := AppendHistory(jsonFile, records); err != nil {
+			t.Fatalf("cannot append history: %v", err)
+		}
+	}
+	file, _ := os.Open(jsonFile)
+	defer file.Close()
+	count := 0
+	for s := bufio.NewScanner(file); s.Scan(); count++ {
+		r := HistoryRecord{}
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			t.Errorf("invalid JSON line: %v", err)
+		}
+	}
+	if count != 6 {
+		t.Errorf("expected 6 JSON lines, got %d", count)
+	}
+}
@@ -0,0 +1,50 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Analysis of the impact of a new configuration against the current OpenNMS inventory
+
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+type ImpactReport struct {
+	Scope        *big.Int // Estimated number of addresses in the generated configuration
+	CurrentScope *big.Int // Estimated number of addresses in the current configuration (if known)
+	HasCurrent   bool     // Whether or not the current configuration was available
+	Monitored    int      // Addresses from the generated scope that belong to existing nodes
+	Unmonitored  *big.Int // Addresses from the generated scope that would be newly swept
+}
+
+func (r *ImpactReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "generated scope: %d addresses\n", r.Scope)
+	if r.HasCurrent {
+		fmt.Fprintf(&sb, "current scope: %d addresses (%+d)\n", r.CurrentScope, new(big.Int).Sub(r.Scope, r.CurrentScope))
+	}
+	fmt.Fprintf(&sb, "addresses already monitored by OpenNMS: %d\n", r.Monitored)
+	fmt.Fprintf(&sb, "currently unmonitored addresses that would be swept: %d", r.Unmonitored)
+	return sb.String()
+}
+
+// Impact cross-references the configuration against the addresses of the existing nodes
+func Impact(cfg *discovery.DiscoveryConfiguration, existing []string, current *discovery.DiscoveryConfiguration) *ImpactReport {
+	report := &ImpactReport{Scope: cfg.GetTotalEstimatedAddresses(), Unmonitored: big.NewInt(0)}
+	if current != nil {
+		report.HasCurrent = true
+		report.CurrentScope = current.GetTotalEstimatedAddresses()
+	}
+	for _, ip := range existing {
+		if cfg.Covers(ip) {
+			report.Monitored++
+		}
+	}
+	if monitored := big.NewInt(int64(report.Monitored)); monitored.Cmp(report.Scope) < 0 {
+		report.Unmonitored.Sub(report.Scope, monitored)
+	}
+	return report
+}
@@ -0,0 +1,34 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestImpact(t *testing.T) {
+	d := discovery.Definition{}
+	d.IncludeCIDR("192.168.0.0/24")
+	d.AddSpecific("10.0.0.1")
+	cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{d}}
+
+	c := discovery.Definition{}
+	c.AddSpecific("10.0.0.1")
+	current := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{c}}
+
+	report := Impact(cfg, []string{"192.168.0.10", "10.0.0.1", "172.16.0.1"}, current)
+	if report.Scope.Int64() != 255 {
+		t.Errorf("the scope should have 255 addresses: %d", report.Scope)
+	}
+	if !report.HasCurrent || report.CurrentScope.Int64() != 1 {
+		t.Errorf("the current scope should have 1 address: %d", report.CurrentScope)
+	}
+	if report.Monitored != 2 {
+		t.Errorf("there should be 2 monitored addresses: %d", report.Monitored)
+	}
+	if report.Unmonitored.Int64() != 253 {
+		t.Errorf("there should be 253 unmonitored addresses: %d", report.Unmonitored)
+	}
+}
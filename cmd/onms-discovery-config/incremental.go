@@ -0,0 +1,233 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Incremental engine of the daemon, applying the changes from the webhooks and the gRPC service to the last generated model
+
+package main
+
+import (
+	"log"
+	"net"
+	"sort"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+// IncrementalModel applies address and prefix changes to a generated configuration without rebuilding it.
+// Every change returns a new configuration that shares the untouched definitions with the previous one,
+// so the configurations returned before, still served by the query API, are never modified.
+type IncrementalModel struct {
+	Staging *StagingSettings // When set, the new addresses go to the staging definition of their location
+	cfg     *discovery.DiscoveryConfiguration
+	owners  map[string]int // Index of the definition of each specific
+}
+
+// pendingDefinition has the specifics to add and remove from a definition, applied in a single pass
+type pendingDefinition struct {
+	added   map[string]discovery.Specific
+	removed map[[net.IPv6len]byte]bool
+}
+
+// NewIncrementalModel creates the engine for a configuration generated by a full run
+func NewIncrementalModel(cfg *discovery.DiscoveryConfiguration) *IncrementalModel {
+	m := &IncrementalModel{cfg: cfg, owners: make(map[string]int)}
+	for i := range cfg.Definitions {
+		for _, s := range cfg.Definitions[i].Specifics {
+			if _, ok := m.owners[s.IP.String()]; !ok { // Discovery uses the first definition
+				m.owners[s.IP.String()] = i
+			}
+		}
+	}
+	return m
+}
+
+// Config returns the current configuration
+func (m *IncrementalModel) Config() *discovery.DiscoveryConfiguration {
+	return m.cfg
+}
+
+// Apply applies the changes in order, returning the new configuration and how many changes modified it.
+// The additions covered by the include ranges or part of the exclude ranges of their definition are ignored;
+// removing a prefix removes the include range added for it.
+func (m *IncrementalModel) Apply(changes []IPAMChange) (*discovery.DiscoveryConfiguration, int) {
+	cfg := *m.cfg
+	cfg.Definitions = append([]discovery.Definition{}, m.cfg.Definitions...)
+	pending := make(map[int]*pendingDefinition)
+	pendingFor := func(idx int) *pendingDefinition {
+		p, ok := pending[idx]
+		if !ok {
+			p = &pendingDefinition{added: make(map[string]discovery.Specific), removed: make(map[[net.IPv6len]byte]bool)}
+			pending[idx] = p
+		}
+		return p
+	}
+	remove := func(ip string) bool {
+		idx, ok := m.owners[ip]
+		if !ok {
+			return false
+		}
+		p := pendingFor(idx)
+		if _, ok := p.added[ip]; ok {
+			delete(p.added, ip)
+		} else {
+			p.removed[ipKey(net.ParseIP(ip))] = true
+		}
+		delete(m.owners, ip)
+		return true
+	}
+	ranges := make(map[int]bool) // Definitions whose include ranges were cloned
+	applied := 0
+	for _, c := range changes {
+		if c.Removed && !c.IsCIDR() {
+			if remove(c.Address) {
+				log.Printf("removing specific IP %s", c.Address)
+				applied++
+			}
+			continue
+		}
+		idx := definitionIndex(&cfg, c.Attributes())
+		def := &cfg.Definitions[idx]
+		if c.IsCIDR() {
+			begin, end, ok := cidrRange(c.Address)
+			if !ok {
+				log.Printf("ignoring invalid prefix %s", c.Address)
+				continue
+			}
+			if !ranges[idx] {
+				def.IncludeRanges = append([]discovery.IncludeRange{}, def.IncludeRanges...)
+				def.Reindex()
+				ranges[idx] = true
+			}
+			if c.Removed {
+				if removeIncludeRange(def, begin, end) {
+					log.Printf("removing CIDR %s", c.Address)
+					applied++
+				}
+			} else if !hasIncludeRange(def, begin, end) {
+				log.Printf("including CIDR %s", c.Address)
+				def.IncludeCIDRWithAttributes(c.Address, c.Attributes())
+				applied++
+			}
+			continue
+		}
+		if def.ExcludeRangesContain(c.Address) {
+			log.Printf("ignore [%s]: IP %s is part of exclude ranges", SkipInExcludeRange, c.Address)
+			continue
+		}
+		if def.IncludeRangesContain(c.Address) {
+			log.Printf("ignore [%s]: IP %s is part of include ranges", SkipCoveredByRange, c.Address)
+			continue
+		}
+		remove(c.Address) // Replaces the attributes, or moves it to another definition
+		specific := discovery.Specific{IP: net.ParseIP(c.Address)}
+		specific.SetAttributes(c.Attributes())
+		if m.Staging != nil {
+			idx = m.stagingDefinitionFor(&cfg, idx)
+			specific.ForeignSource = "" // The one from the staging definition applies
+		}
+		log.Printf("adding specific IP %s", c.Address)
+		pendingFor(idx).added[c.Address] = specific
+		m.owners[c.Address] = idx
+		applied++
+	}
+	for idx, p := range pending {
+		def := &cfg.Definitions[idx]
+		def.Specifics = mergeSpecifics(def.Specifics, p)
+	}
+	m.cfg = &cfg
+	return m.cfg, applied
+}
+
+// stagingDefinitionFor returns the index of the staging definition for the location of the given definition,
+// adding it when it doesn't exist
+func (m *IncrementalModel) stagingDefinitionFor(cfg *discovery.DiscoveryConfiguration, idx int) int {
+	for i := range cfg.Definitions {
+		if def := &cfg.Definitions[i]; def.ForeignSource == m.Staging.ForeignSource && def.Location == cfg.Definitions[idx].Location {
+			return i
+		}
+	}
+	defs := make([]discovery.Definition, 0, 1)
+	log.Printf("adding staging definition for location %s", cfg.Definitions[idx].Location)
+	cfg.AddDefinition(*stagingDefinitionFor(&defs, &cfg.Definitions[idx], *m.Staging))
+	return len(cfg.Definitions) - 1
+}
+
+// definitionIndex is like definitionFor, returning the index of the definition for the location and foreign-source
+func definitionIndex(cfg *discovery.DiscoveryConfiguration, attrs discovery.Attributes) int {
+	if len(cfg.Definitions) == 0 {
+		cfg.AddDefinition(discovery.Definition{Location: attrs.Location, ForeignSource: attrs.ForeignSource})
+		return 0
+	}
+	def := definitionFor(cfg, attrs)
+	for i := range cfg.Definitions {
+		if def == &cfg.Definitions[i] {
+			return i
+		}
+	}
+	return len(cfg.Definitions) - 1
+}
+
+// hasIncludeRange returns true when the definition has an include range with exactly the given addresses
+func hasIncludeRange(def *discovery.Definition, begin, end net.IP) bool {
+	for _, r := range def.IncludeRanges {
+		if r.Begin.Equal(begin) && r.End.Equal(end) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeIncludeRange removes the include ranges with exactly the given addresses, returning false when there are none
+func removeIncludeRange(def *discovery.Definition, begin, end net.IP) bool {
+	if !hasIncludeRange(def, begin, end) {
+		return false
+	}
+	ranges := def.IncludeRanges[:0]
+	for _, r := range def.IncludeRanges {
+		if !r.Begin.Equal(begin) || !r.End.Equal(end) {
+			ranges = append(ranges, r)
+		}
+	}
+	def.IncludeRanges = ranges
+	def.Reindex()
+	return true
+}
+
+// cidrRange returns the addresses of a CIDR as included by a definition, without the network and broadcast addresses
+func cidrRange(cidr string) (net.IP, net.IP, bool) {
+	def := new(discovery.Definition)
+	def.IncludeCIDR(cidr)
+	if len(def.IncludeRanges) == 0 {
+		return nil, nil, false
+	}
+	return def.IncludeRanges[0].Begin, def.IncludeRanges[0].End, true
+}
+
+// mergeSpecifics returns a new list with the specifics not removed and the added ones, keeping the order of a sorted list
+func mergeSpecifics(current []discovery.Specific, p *pendingDefinition) []discovery.Specific {
+	added := make([]discovery.Specific, 0, len(p.added))
+	for _, s := range p.added {
+		added = append(added, s)
+	}
+	sort.Slice(added, func(i, j int) bool { return iprange.Compare(added[i].IP, added[j].IP) < 0 })
+	list := make([]discovery.Specific, 0, len(current)+len(added))
+	j := 0
+	for _, s := range current {
+		if len(p.removed) > 0 && p.removed[ipKey(s.IP)] {
+			continue
+		}
+		for j < len(added) && iprange.Compare(added[j].IP, s.IP) < 0 {
+			list = append(list, added[j])
+			j++
+		}
+		list = append(list, s)
+	}
+	return append(list, added[j:]...)
+}
+
+// ipKey returns an IP address as a map key, without allocations
+func ipKey(ip net.IP) [net.IPv6len]byte {
+	var key [net.IPv6len]byte
+	copy(key[:], ip.To16())
+	return key
+}
@@ -0,0 +1,113 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+func TestIncrementalModel(t *testing.T) {
+	def := discovery.Definition{Location: "Default"}
+	def.AddSpecific("10.0.0.1")
+	def.AddSpecific("10.0.0.5")
+	def.IncludeCIDR("10.1.0.0/24")
+	def.ExcludeCIDR("10.2.0.0/24")
+	cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{def}}
+	m := NewIncrementalModel(cfg)
+
+	next, applied := m.Apply([]IPAMChange{
+		{Address: "10.0.0.3", Location: "Default"},
+		{Address: "10.1.0.7", Location: "Default"},  // Covered by the include range
+		{Address: "10.2.0.4", Location: "Default"},  // Part of the exclude range
+		{Address: "10.0.0.5", Removed: true},        // Removed
+		{Address: "10.0.0.9", Removed: true},        // Not present
+		{Address: "172.16.0.1", Location: "Remote"}, // New definition
+		{Address: "192.168.0.0/24", Location: "Default"},
+		{Address: "192.168.1.0/24", Location: "Default", Removed: true}, // Not present
+	})
+	if applied != 4 {
+		t.Errorf("unexpected number of applied changes: %d", applied)
+	}
+	if len(next.Definitions) != 2 || next.Definitions[1].Location != "Remote" || len(next.Definitions[1].Specifics) != 1 {
+		t.Fatalf("unexpected definitions: %+v", next.Definitions)
+	}
+	specifics := next.Definitions[0].Specifics
+	if len(specifics) != 2 || specifics[0].IP.String() != "10.0.0.1" || specifics[1].IP.String() != "10.0.0.3" {
+		t.Errorf("unexpected specifics: %+v", specifics)
+	}
+	if ranges := next.Definitions[0].IncludeRanges; len(ranges) != 2 || ranges[1].Begin.String() != "192.168.0.1" || ranges[1].End.String() != "192.168.0.254" {
+		t.Errorf("unexpected include ranges: %+v", ranges)
+	}
+	if len(cfg.Definitions) != 1 || len(cfg.Definitions[0].Specifics) != 2 || cfg.Definitions[0].Specifics[1].IP.String() != "10.0.0.5" || len(cfg.Definitions[0].IncludeRanges) != 1 {
+		t.Errorf("the original configuration should not change: %+v", cfg.Definitions)
+	}
+
+	last, applied := m.Apply([]IPAMChange{
+		{Address: "192.168.0.0/24", Location: "Default", Removed: true},
+		{Address: "172.16.0.1", Location: "Default", Retries: 2}, // Moved to another definition
+		{Address: "10.0.0.1", Removed: true},
+		{Address: "10.0.0.1", Location: "Default"}, // Added back
+	})
+	if applied != 4 || m.Config() != last {
+		t.Errorf("unexpected number of applied changes: %d", applied)
+	}
+	if ranges := last.Definitions[0].IncludeRanges; len(ranges) != 1 || len(next.Definitions[0].IncludeRanges) != 2 {
+		t.Errorf("unexpected include ranges: %+v", ranges)
+	}
+	specifics = last.Definitions[0].Specifics
+	if len(specifics) != 3 || specifics[2].IP.String() != "172.16.0.1" || specifics[2].Retries != 2 || len(last.Definitions[1].Specifics) != 0 {
+		t.Errorf("unexpected specifics: %+v", last.Definitions)
+	}
+	if len(next.Definitions[1].Specifics) != 1 {
+		t.Errorf("the previous configuration should not change: %+v", next.Definitions)
+	}
+}
+
+func TestIncrementalModelStaging(t *testing.T) {
+	def := discovery.Definition{Location: "Default", ForeignSource: "Servers", Detectors: []discovery.Detector{{Name: "ICMP"}, {Name: "SNMP"}}}
+	def.AddSpecific("10.0.0.1")
+	m := NewIncrementalModel(&discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{def}})
+	m.Staging = &StagingSettings{ForeignSource: "Staging", Runs: 3, Detectors: []string{"ICMP"}}
+
+	cfg, applied := m.Apply([]IPAMChange{
+		{Address: "10.0.0.2", Location: "Default", ForeignSource: "Servers"},
+		{Address: "10.0.0.3", Location: "Default", ForeignSource: "Servers"},
+		{Address: "10.0.0.0/24", Location: "Default", ForeignSource: "Servers"}, // Ranges are not staged
+	})
+	if applied != 3 || len(cfg.Definitions) != 2 {
+		t.Fatalf("unexpected configuration after %d changes: %+v", applied, cfg.Definitions)
+	}
+	staging := cfg.Definitions[1]
+	if staging.ForeignSource != "Staging" || len(staging.Specifics) != 2 || staging.Specifics[0].ForeignSource != "" || len(staging.Detectors) != 1 {
+		t.Errorf("unexpected staging definition: %+v", staging)
+	}
+	if len(cfg.Definitions[0].Specifics) != 1 || len(cfg.Definitions[0].IncludeRanges) != 1 {
+		t.Errorf("unexpected main definition: %+v", cfg.Definitions[0])
+	}
+}
+
+func BenchmarkIncrementalModel(b *testing.B) {
+	def := discovery.Definition{Specifics: make([]discovery.Specific, 0, 1<<20)}
+	start := net.ParseIP("10.0.0.0").To4()
+	for i := 0; i < 1<<20; i++ {
+		def.Specifics = append(def.Specifics, discovery.Specific{IP: iprange.Offset(start, int64(2*i))})
+	}
+	m := NewIncrementalModel(&discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{def}})
+	changes := make([]IPAMChange, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		changes = append(changes, IPAMChange{Address: fmt.Sprintf("10.%d.%d.%d", i%32, (i*7)%256, 1+2*(i%127))})
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range changes {
+			changes[j].Removed = i%2 == 1
+		}
+		m.Apply(changes)
+	}
+}
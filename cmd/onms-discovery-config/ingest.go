@@ -0,0 +1,234 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// gRPC service of the daemon where other services stream candidate addresses, kept in a file that generate reads via 'inc-ingest'
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/ingestpb"
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ingestSource is the name of the source of the candidates in the summary
+const ingestSource = "inc-ingest"
+
+// IngestCandidate is a candidate address received by the gRPC service, saved as a JSON line
+type IngestCandidate struct {
+	IP            string            `json:"ip"`
+	Location      string            `json:"location,omitempty"`
+	ForeignSource string            `json:"foreign-source,omitempty"`
+	Retries       int               `json:"retries,omitempty"`
+	Timeout       int               `json:"timeout,omitempty"`
+	Name          string            `json:"name,omitempty"`
+	MetaData      map[string]string `json:"meta-data,omitempty"`
+}
+
+// Attributes returns the attributes of the specific for the candidate
+func (c *IngestCandidate) Attributes() discovery.Attributes {
+	return discovery.Attributes{Location: c.Location, ForeignSource: c.ForeignSource, Retries: c.Retries, Timeout: c.Timeout}
+}
+
+// LoadIngestCandidates reads the candidates saved by the daemon, one JSON object per line
+func LoadIngestCandidates(fileName string) ([]*IngestCandidate, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening file: %v", err)
+	}
+	defer file.Close()
+	candidates := make([]*IngestCandidate, 0)
+	scanner := newScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		c := new(IngestCandidate)
+		if err := json.Unmarshal(scanner.Bytes(), c); err != nil {
+			return nil, fmt.Errorf("invalid candidate at line %d of %s: %v", line, fileName, err)
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, scanner.Err()
+}
+
+// SaveIngestCandidates replaces the file with the candidates, sorted by address
+func SaveIngestCandidates(fileName string, candidates []*IngestCandidate) error {
+	sort.Slice(candidates, func(i, j int) bool {
+		return iprange.Compare(net.ParseIP(candidates[i].IP), net.ParseIP(candidates[j].IP)) < 0
+	})
+	tmp := fileName + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("cannot save candidates: %v", err)
+	}
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, c := range candidates {
+		if err := enc.Encode(c); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot save candidates: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("cannot save candidates: %v", err)
+	}
+	return os.Rename(tmp, fileName)
+}
+
+// addIngestCandidates adds every candidate to the definition that matches its location and foreign-source,
+// with its name and meta-data for the requisitions
+func addIngestCandidates(cfg *discovery.DiscoveryConfiguration, streamed *streamedSet, candidates []*IngestCandidate) error {
+	defer delete(inputMetaData, ingestSource)
+	for _, c := range candidates {
+		inputMetaData[ingestSource] = c.MetaData
+		attrs := c.Attributes()
+		if err := addNamedSpecific(definitionFor(cfg, attrs), streamed, ingestSource, c.IP, c.Name, attrs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IngestService implements the gRPC service, keeping the candidates in a file for the runs of generate
+type IngestService struct {
+	ingestpb.UnimplementedIngestServer
+	Incremental bool // Whether or not to keep the received candidates until drained, for the incremental engine
+	file        string
+	generate    func(ctx context.Context, dryRun bool) (int, error) // Runs generate, returning the ID of the generation
+	candidates  map[string]*IngestCandidate
+	pending     []IPAMChange
+	mutex       sync.Mutex
+}
+
+// NewIngestService creates the service with the candidates from the file, which is created when it doesn't exist
+func NewIngestService(fileName string, generate func(ctx context.Context, dryRun bool) (int, error)) (*IngestService, error) {
+	s := &IngestService{file: fileName, generate: generate, candidates: make(map[string]*IngestCandidate)}
+	if _, err := os.Stat(fileName); os.IsNotExist(err) {
+		return s, s.save()
+	}
+	candidates, err := LoadIngestCandidates(fileName)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range candidates {
+		s.candidates[c.IP] = c
+	}
+	return s, nil
+}
+
+// Count returns the number of candidates
+func (s *IngestService) Count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.candidates)
+}
+
+// Drain returns the candidates received and removed since the last call as changes, when incremental
+func (s *IngestService) Drain() []IPAMChange {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	changes := s.pending
+	s.pending = nil
+	return changes
+}
+
+// save must be called with the lock
+func (s *IngestService) save() error {
+	list := make([]*IngestCandidate, 0, len(s.candidates))
+	for _, c := range s.candidates {
+		list = append(list, c)
+	}
+	return SaveIngestCandidates(s.file, list)
+}
+
+// queue keeps a change for the incremental engine; must be called with the lock
+func (s *IngestService) queue(c IPAMChange) {
+	if s.Incremental {
+		s.pending = append(s.pending, c)
+	}
+}
+
+// Stream receives candidates until the client closes the stream; the invalid ones are reported back.
+// A candidate replaces the one received before for the same address.
+func (s *IngestService) Stream(stream grpc.ClientStreamingServer[ingestpb.Candidate, ingestpb.IngestSummary]) error {
+	summary := &ingestpb.IngestSummary{Rejected: make([]string, 0)}
+	for {
+		c, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil { // The candidates received so far are kept
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			if saveErr := s.save(); saveErr != nil {
+				return status.Errorf(codes.Internal, "%v", saveErr)
+			}
+			return err
+		}
+		summary.Received++
+		ip, err := iprange.NormalizeIP(c.Ip)
+		if err != nil {
+			summary.Rejected = append(summary.Rejected, fmt.Sprintf("%s: %v", c.Ip, err))
+			continue
+		}
+		if c.Retries < 0 || c.Timeout < 0 {
+			summary.Rejected = append(summary.Rejected, fmt.Sprintf("%s: negative retries or timeout", c.Ip))
+			continue
+		}
+		s.mutex.Lock()
+		if c.Remove {
+			if _, ok := s.candidates[ip]; ok {
+				delete(s.candidates, ip)
+				s.queue(IPAMChange{Address: ip, Removed: true})
+				summary.Removed++
+			}
+		} else {
+			s.candidates[ip] = &IngestCandidate{
+				IP:            ip,
+				Location:      c.Location,
+				ForeignSource: c.ForeignSource,
+				Retries:       int(c.Retries),
+				Timeout:       int(c.Timeout),
+				Name:          c.Name,
+				MetaData:      c.MetaData,
+			}
+			s.queue(IPAMChange{Address: ip, Location: c.Location, ForeignSource: c.ForeignSource, Retries: int(c.Retries), Timeout: int(c.Timeout), Name: c.Name})
+			summary.Accepted++
+		}
+		s.mutex.Unlock()
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.save(); err != nil {
+		return status.Errorf(codes.Internal, "%v", err)
+	}
+	summary.Total = int64(len(s.candidates))
+	return stream.SendAndClose(summary)
+}
+
+// Generate runs generate with the candidates received so far
+func (s *IngestService) Generate(ctx context.Context, req *ingestpb.GenerateRequest) (*ingestpb.GenerateResponse, error) {
+	count := s.Count()
+	id, err := s.generate(ctx, req.DryRun)
+	if err != nil {
+		return nil, status.Errorf(codes.Aborted, "%v", err)
+	}
+	return &ingestpb.GenerateResponse{Generation: int64(id), Candidates: int64(count)}, nil
+}
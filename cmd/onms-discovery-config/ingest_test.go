@@ -0,0 +1,105 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/ingestpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestIngestService(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ingest.jsonl")
+	runs := make([]bool, 0)
+	service, err := NewIngestService(file, func(ctx context.Context, dryRun bool) (int, error) {
+		runs = append(runs, dryRun)
+		return 7, nil
+	})
+	if err != nil {
+		t.Fatalf("cannot create service: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	server := grpc.NewServer()
+	ingestpb.RegisterIngestServer(server, service)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("cannot connect: %v", err)
+	}
+	defer conn.Close()
+	client := ingestpb.NewIngestClient(conn)
+	send := func(candidates ...*ingestpb.Candidate) *ingestpb.IngestSummary {
+		stream, err := client.Stream(context.Background())
+		if err != nil {
+			t.Fatalf("cannot open stream: %v", err)
+		}
+		for _, c := range candidates {
+			if err := stream.Send(c); err != nil {
+				t.Fatalf("cannot send candidate: %v", err)
+			}
+		}
+		summary, err := stream.CloseAndRecv()
+		if err != nil {
+			t.Fatalf("cannot close stream: %v", err)
+		}
+		return summary
+	}
+
+	summary := send(
+		&ingestpb.Candidate{Ip: "10.0.0.1", Location: "Paris", Name: "router1", MetaData: map[string]string{"owner": "NetOps"}},
+		&ingestpb.Candidate{Ip: "10.0.0.2"},
+		&ingestpb.Candidate{Ip: "10.0.0.300"},
+		&ingestpb.Candidate{Ip: "10.0.0.3", Retries: -1},
+	)
+	if summary.Received != 4 || summary.Accepted != 2 || len(summary.Rejected) != 2 || summary.Total != 2 {
+		t.Errorf("unexpected summary: %v", summary)
+	}
+	summary = send(&ingestpb.Candidate{Ip: "10.0.0.2", Remove: true}, &ingestpb.Candidate{Ip: "2001:DB8::1", ForeignSource: "IPv6"})
+	if summary.Removed != 1 || summary.Accepted != 1 || summary.Total != 2 {
+		t.Errorf("unexpected summary: %v", summary)
+	}
+
+	response, err := client.Generate(context.Background(), &ingestpb.GenerateRequest{DryRun: true})
+	if err != nil || response.Generation != 7 || response.Candidates != 2 || len(runs) != 1 || !runs[0] {
+		t.Errorf("unexpected response: %v %v %v", response, err, runs)
+	}
+
+	candidates, err := LoadIngestCandidates(file)
+	if err != nil {
+		t.Fatalf("cannot load candidates: %v", err)
+	}
+	if len(candidates) != 2 || candidates[0].IP != "10.0.0.1" || candidates[0].MetaData["owner"] != "NetOps" || candidates[1].IP != "2001:db8::1" {
+		t.Fatalf("unexpected candidates: %+v", candidates)
+	}
+	restarted, err := NewIngestService(file, nil)
+	if err != nil || restarted.Count() != 2 {
+		t.Errorf("the candidates should be kept across restarts: %v", err)
+	}
+
+	reset := func() {
+		addressWhiteList, nodeMetaData, nodeLabels = make(map[string]string), make(map[string]map[string]string), make(map[string]string)
+	}
+	reset()
+	defer reset()
+	cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{{}}}
+	if err := addIngestCandidates(cfg, newStreamedSet(), candidates); err != nil {
+		t.Fatalf("cannot add candidates: %v", err)
+	}
+	if len(cfg.Definitions) != 3 || cfg.Definitions[1].Location != "Paris" || cfg.Definitions[2].ForeignSource != "IPv6" {
+		t.Errorf("each candidate should be in the definition of its location and foreign source: %+v", cfg.Definitions)
+	}
+	if nodeLabels["10.0.0.1"] != "router1" || nodeMetaData["10.0.0.1"]["owner"] != "NetOps" || len(nodeMetaData["2001:db8::1"]) != 0 {
+		t.Errorf("the name and meta-data of the candidates should be kept: %v %v", nodeLabels, nodeMetaData)
+	}
+}
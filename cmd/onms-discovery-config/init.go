@@ -0,0 +1,204 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Generation of annotated starter files for new deployments, based on a few questions
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ipamTypes are the sources of addresses supported by the starter files
+var ipamTypes = []string{"files", "netbox", "dns", "nnmi"}
+
+// nonAlphanumericRegex matches what is replaced in the location names to build file names
+var nonAlphanumericRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+type InitAnswers struct {
+	Locations []string // Names of the Minion locations; Default is the one of the OpenNMS server
+	IPAM      string   // Source of the addresses, one of ipamTypes
+}
+
+// PromptInit asks for the details of the deployment, using the defaults for empty answers
+func PromptInit(in io.Reader, out io.Writer) (*InitAnswers, error) {
+	s := bufio.NewScanner(in)
+	ask := func(question, defaultValue string) string {
+		fmt.Fprintf(out, "%s [%s]: ", question, defaultValue)
+		if s.Scan() {
+			if answer := strings.TrimSpace(s.Text()); answer != "" {
+				return answer
+			}
+		}
+		return defaultValue
+	}
+	answers := &InitAnswers{}
+	count, err := strconv.Atoi(ask("Number of locations", "1"))
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("the number of locations must be a positive integer")
+	}
+	for i := 1; i <= count; i++ {
+		defaultName := "Default"
+		if i > 1 {
+			defaultName = fmt.Sprintf("Location%d", i)
+		}
+		answers.Locations = append(answers.Locations, ask(fmt.Sprintf("Name of location %d", i), defaultName))
+	}
+	answers.IPAM = strings.ToLower(ask("IPAM type ("+strings.Join(ipamTypes, ", ")+")", ipamTypes[0]))
+	valid := false
+	for _, t := range ipamTypes {
+		valid = valid || t == answers.IPAM
+	}
+	if !valid {
+		return nil, fmt.Errorf("invalid IPAM type %s; valid types are %s", answers.IPAM, strings.Join(ipamTypes, ", "))
+	}
+	return answers, nil
+}
+
+// starterFile is the content of a file generated by init
+type starterFile struct {
+	Name    string
+	Content string
+}
+
+// fileName returns the base name of a file for a given location
+func fileName(location, suffix string) string {
+	return strings.Trim(nonAlphanumericRegex.ReplaceAllString(strings.ToLower(location), "-"), "-") + "-" + suffix
+}
+
+// StarterFiles returns the pipeline file, the detectors file, and the input and mapping files for the answers;
+// the paths within the files are relative to the given directory.
+func StarterFiles(dir string, answers *InitAnswers) []starterFile {
+	path := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+	files := make([]starterFile, 0)
+	var pipeline strings.Builder
+	fmt.Fprintf(&pipeline, "# Options for onms-discovery-config, using their names as keys (see the README or the -h output).\n")
+	fmt.Fprintf(&pipeline, "# Verify the file with: onms-discovery-config validate-pipeline %s\n", path("pipeline.yaml"))
+	fmt.Fprintf(&pipeline, "# Try it with: onms-discovery-config -config %s -dry-run\n\n", path("pipeline.yaml"))
+	fmt.Fprintf(&pipeline, "# Detectors of the definitions (see the file for the available presets)\n")
+	fmt.Fprintf(&pipeline, "detectors: %s\n\n", path("detectors.yaml"))
+
+	single := len(answers.Locations) == 1
+	perDefinition := !single && (answers.IPAM == "files" || answers.IPAM == "nnmi")
+	exclude := "exclude-cidrs.txt"
+	files = append(files, starterFile{exclude, ""})
+	attributes := "" // A single location other than the default is set via the attributes of the inputs
+	if single && answers.Locations[0] != "Default" {
+		attributes = ":location=" + answers.Locations[0]
+	}
+	switch answers.IPAM {
+	case "files", "nnmi":
+		definitions := make([]string, 0)
+		for _, location := range answers.Locations {
+			inputs := make([]string, 0)
+			if answers.IPAM == "files" {
+				cidrs, ips := fileName(location, "cidrs.txt"), fileName(location, "ips.txt")
+				files = append(files, starterFile{cidrs, ""}, starterFile{ips, ""})
+				inputs = append(inputs, "inc-cidr="+path(cidrs), "inc-list="+path(ips))
+			} else {
+				hex := fileName(location, "nnmi.txt")
+				files = append(files, starterFile{hex, ""})
+				inputs = append(inputs, "inc-hexnnmi="+path(hex))
+			}
+			if single {
+				fmt.Fprintf(&pipeline, "# Inputs, one entry per line (CIDRs for inc-cidr, IP addresses for inc-list, NNMi exports for inc-hexnnmi)\n")
+				for _, input := range inputs {
+					fmt.Fprintf(&pipeline, "%s%s\n", strings.Replace(input, "=", ": ", 1), attributes)
+				}
+			} else {
+				inputs = append(inputs, "exc-cidr="+path(exclude)) // The exclusions are specific to each definition
+				definitions = append(definitions, fmt.Sprintf("location=%s,foreign-source=%s,%s", location, location, strings.Join(inputs, ",")))
+			}
+		}
+		if !single {
+			fmt.Fprintf(&pipeline, "# One definition per location, each with its own input files (one entry per line), and CIDRs to skip\n")
+			fmt.Fprintf(&pipeline, "definition:\n")
+			for _, d := range definitions {
+				fmt.Fprintf(&pipeline, "  - %s\n", d)
+			}
+		}
+	case "netbox":
+		fmt.Fprintf(&pipeline, "# NetBox prefixes become include ranges, and IP addresses become specifics\n")
+		fmt.Fprintf(&pipeline, "inc-netbox: https://netbox.example.com%s\n", attributes)
+		fmt.Fprintf(&pipeline, "# Encrypt the token with: onms-discovery-config encrypt\n")
+		fmt.Fprintf(&pipeline, "netbox-token: CHANGE-ME\n")
+		fmt.Fprintf(&pipeline, "netbox-filter: status=active\n")
+		if !single {
+			catalog := "sites.csv"
+			var content strings.Builder
+			fmt.Fprintf(&content, "# One site,cidr,location,foreign-source entry per line; a site with multiple CIDRs uses multiple lines\n")
+			for _, location := range answers.Locations {
+				fmt.Fprintf(&content, "# %s-site,192.0.2.0/24,%s,%s\n", strings.ToLower(location), location, location)
+			}
+			files = append(files, starterFile{catalog, content.String()})
+			fmt.Fprintf(&pipeline, "\n# Sites to place the NetBox content into one definition per location\n")
+			fmt.Fprintf(&pipeline, "site-catalog: %s\n", path(catalog))
+		}
+	case "dns":
+		export, mapping := "dns-export.txt", "dns-locations.txt"
+		files = append(files, starterFile{export, ""})
+		fmt.Fprintf(&pipeline, "# DNS export with the addresses; e.x. ipv4addr=10.0.0.1\n")
+		fmt.Fprintf(&pipeline, "inc-dns: %s%s\n", path(export), attributes)
+		if !single {
+			var content strings.Builder
+			fmt.Fprintf(&content, "# One view=location or zone=location entry per line\n")
+			for _, location := range answers.Locations {
+				fmt.Fprintf(&content, "# %s-view=%s\n", strings.ToLower(location), location)
+			}
+			files = append(files, starterFile{mapping, content.String()})
+			fmt.Fprintf(&pipeline, "# Locations of the addresses based on their DNS views or zones\n")
+			fmt.Fprintf(&pipeline, "inc-dns-locations: %s\n", path(mapping))
+		}
+	}
+
+	if !perDefinition {
+		fmt.Fprintf(&pipeline, "\n# Addresses to skip, one CIDR per line\n")
+		fmt.Fprintf(&pipeline, "exc-cidr: %s\n", path(exclude))
+	}
+	fmt.Fprintf(&pipeline, "\n# Statistics about the processed sources\n")
+	fmt.Fprintf(&pipeline, "summary: %s\n", path("summary.json"))
+	fmt.Fprintf(&pipeline, "# Uncomment to combine the specifics and include ranges into the smallest set of ranges\n")
+	fmt.Fprintf(&pipeline, "# optimize: true\n")
+
+	var detectors strings.Builder
+	fmt.Fprintf(&detectors, "# Detectors of the default definition, and of the additional ones by name.\n")
+	fmt.Fprintf(&detectors, "# Each detector is either based on a preset (%s), or explicit via name, class, and parameters.\n", strings.Join(DetectorPresets(), ", "))
+	fmt.Fprintf(&detectors, "default:\n  - preset: icmp\n  - preset: snmp\n")
+	if perDefinition {
+		fmt.Fprintf(&detectors, "# definitions:\n#   %s:\n#     - preset: snmp\n#     - preset: ssh\n", answers.Locations[0])
+	}
+
+	return append([]starterFile{{"pipeline.yaml", pipeline.String()}, {"detectors.yaml", detectors.String()}}, files...)
+}
+
+// WriteStarterFiles writes the starter files into the given directory, refusing to overwrite existing files unless forced
+func WriteStarterFiles(dir string, answers *InitAnswers, force bool) ([]string, error) {
+	files := StarterFiles(dir, answers)
+	if !force {
+		for _, f := range files {
+			if _, err := os.Stat(filepath.Join(dir, f.Name)); err == nil {
+				return nil, fmt.Errorf("%s already exists; use -force to overwrite it", filepath.Join(dir, f.Name))
+			}
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create directory %s: %v", dir, err)
+	}
+	written := make([]string, 0, len(files))
+	for _, f := range files {
+		name := filepath.Join(dir, f.Name)
+		if err := os.WriteFile(name, []byte(f.Content), 0644); err != nil {
+			return written, fmt.Errorf("cannot write %s: %v", name, err)
+		}
+		written = append(written, name)
+	}
+	return written, nil
+}
@@ -0,0 +1,67 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPromptInit(t *testing.T) {
+	answers, err := PromptInit(strings.NewReader("2\nParis\n\nNetBox\n"), ioutil.Discard)
+	if err != nil {
+		t.Fatalf("cannot get answers: %v", err)
+	}
+	if strings.Join(answers.Locations, ",") != "Paris,Location2" || answers.IPAM != "netbox" {
+		t.Errorf("incorrect answers: %+v", answers)
+	}
+	if answers, err = PromptInit(strings.NewReader(""), ioutil.Discard); err != nil || len(answers.Locations) != 1 || answers.Locations[0] != "Default" || answers.IPAM != "files" {
+		t.Errorf("the defaults should be used without answers: %+v %v", answers, err)
+	}
+	if _, err := PromptInit(strings.NewReader("0\n"), ioutil.Discard); err == nil {
+		t.Errorf("the number of locations should be positive")
+	}
+	if _, err := PromptInit(strings.NewReader("1\n\nexcel\n"), ioutil.Discard); err == nil {
+		t.Errorf("excel should not be a valid IPAM type")
+	}
+}
+
+func TestWriteStarterFiles(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_init")
+	if err != nil {
+		t.Fatalf("cannot create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, ipam := range ipamTypes {
+		for _, locations := range [][]string{{"Default"}, {"Paris", "New York"}} {
+			target := filepath.Join(dir, ipam, strings.Join(locations, "_"))
+			answers := &InitAnswers{Locations: locations, IPAM: ipam}
+			if _, err := WriteStarterFiles(target, answers, false); err != nil {
+				t.Fatalf("%s: cannot write starter files: %v", target, err)
+			}
+			if errs := ValidatePipeline(filepath.Join(target, "pipeline.yaml")); len(errs) != 0 {
+				t.Errorf("%s: the pipeline should be valid: %v", target, errs)
+			}
+			if _, err := LoadDetectorsFile(filepath.Join(target, "detectors.yaml")); err != nil {
+				t.Errorf("%s: the detectors should be valid: %v", target, err)
+			}
+		}
+	}
+
+	target := filepath.Join(dir, "files", "Paris_New York")
+	data, _ := ioutil.ReadFile(filepath.Join(target, "pipeline.yaml"))
+	if !strings.Contains(string(data), "location=New York,foreign-source=New York,inc-cidr="+filepath.Join(target, "new-york-cidrs.txt")) {
+		t.Errorf("there should be a definition for New York:\n%s", string(data))
+	}
+	answers := &InitAnswers{Locations: []string{"Paris", "New York"}, IPAM: "files"}
+	if _, err := WriteStarterFiles(target, answers, false); err == nil {
+		t.Errorf("the existing files should not be overwritten")
+	}
+	if _, err := WriteStarterFiles(target, answers, true); err != nil {
+		t.Errorf("the existing files should be overwritten when forced: %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Helper functions to parse input file specifications with optional attributes;
+// e.x. site-a.txt:retries=2,timeout=5000,location=SiteA,foreign-source=SiteA,meta.owner=NetOps
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+type InputFile struct {
+	Path       string
+	Attributes discovery.Attributes
+	MetaData   map[string]string // Node meta-data of the addresses in the requisitions, from the meta.<key> attributes
+}
+
+// inputSchemes are the schemes of the URLs accepted as inputs
+var inputSchemes = []string{"http://", "https://", "file:"}
+
+// attributeKey matches the name of the first attribute, to tell the annotations apart from a Windows drive letter or a URL
+var attributeKey = regexp.MustCompile(`^\s*[a-zA-Z][\w.-]*\s*=`)
+
+// ParseInputFile parses the path or URL of an input with optional attributes after the last colon, which requires
+// the path to be an existing file or a URL, as the values can contain any character; e.x. list.txt:location=DC/East
+func ParseInputFile(spec string) (*InputFile, error) {
+	return ParseInputTarget(spec, func(path string) error {
+		for _, scheme := range inputSchemes {
+			if strings.HasPrefix(strings.ToLower(path), scheme) {
+				return nil
+			}
+		}
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			return fmt.Errorf("%s is neither an existing file nor a URL", path)
+		}
+		return nil
+	})
+}
+
+// ParseInputSelector is like ParseInputFile, for the inputs that select what to include by name; e.x. subnets:location=AWS
+func ParseInputSelector(spec string, names ...string) (*InputFile, error) {
+	return ParseInputTarget(spec, func(path string) error {
+		for _, name := range names {
+			if path == name {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid value '%s'; valid values are %s", path, strings.Join(names, ", "))
+	})
+}
+
+// ParseInputTarget is like ParseInputFile, validating what comes before the attributes through the given function
+func ParseInputTarget(spec string, valid func(path string) error) (*InputFile, error) {
+	input := &InputFile{Path: spec}
+	idx := strings.LastIndex(spec, ":")
+	if idx < 0 || !attributeKey.MatchString(spec[idx+1:]) { // No annotations (or a Windows drive letter, or a URL)
+		return input, nil
+	}
+	if err := valid(spec[:idx]); err != nil {
+		return nil, fmt.Errorf("invalid input %s: %v", spec, err)
+	}
+	input.Path = spec[:idx]
+	for _, entry := range strings.Split(spec[idx+1:], ",") {
+		pair := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("invalid attribute '%s' for %s", entry, input.Path)
+		}
+		key, value := strings.TrimSpace(pair[0]), strings.TrimSpace(pair[1])
+		switch key {
+		case "retries":
+			n, err := ParseNumber(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid retries '%s' for %s", value, input.Path)
+			}
+			input.Attributes.Retries = n
+		case "timeout":
+			n, err := ParseNumber(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timeout '%s' for %s", value, input.Path)
+			}
+			input.Attributes.Timeout = n
+		case "location":
+			input.Attributes.Location = value
+		case "foreign-source":
+			input.Attributes.ForeignSource = value
+		default:
+			if name := strings.TrimPrefix(key, "meta."); name != key && name != "" {
+				if input.MetaData == nil {
+					input.MetaData = make(map[string]string)
+				}
+				input.MetaData[name] = value
+				continue
+			}
+			return nil, fmt.Errorf("unknown attribute '%s' for %s", key, input.Path)
+		}
+	}
+	return input, nil
+}
+
+// thousandsSeparators are the separators used by the locales of the exports; e.x. 5,000, 5.000, 5'000 or 5 000
+const thousandsSeparators = ",.' _\u00a0\u202f"
+
+// ParseNumber parses a non-negative integer regardless of the locale of the source. A thousands separator is accepted
+// when it is used consistently between groups of 3 digits, and a trailing semicolon is ignored. Decimals are rejected,
+// as 2.5 and 2,5 are ambiguous.
+func ParseNumber(value string) (int, error) {
+	v := strings.TrimRight(strings.TrimSpace(value), ";")
+	sep := rune(-1)
+	groups := strings.FieldsFunc(v, func(r rune) bool {
+		if r >= '0' && r <= '9' {
+			return false
+		}
+		if sep == -1 {
+			sep = r
+		}
+		return true
+	})
+	if sep != -1 {
+		if !strings.ContainsRune(thousandsSeparators, sep) || strings.Count(v, string(sep)) != len(groups)-1 || len(groups[0]) > 3 {
+			return 0, fmt.Errorf("invalid number %s", value)
+		}
+		for _, g := range groups[1:] {
+			if len(g) != 3 {
+				return 0, fmt.Errorf("invalid number %s", value)
+			}
+		}
+	}
+	n, err := strconv.Atoi(strings.Join(groups, ""))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid number %s", value)
+	}
+	return n, nil
+}
+
+type TagFilter struct {
+	Key    string
+	Values []string // Alternative values
+}
+
+// ParseTagFilter parses the tags to select cloud resources; e.x. discovery=true,env=prod|staging
+func ParseTagFilter(filter string) ([]TagFilter, error) {
+	tags := make([]TagFilter, 0)
+	if strings.TrimSpace(filter) == "" {
+		return tags, nil
+	}
+	for _, entry := range strings.Split(filter, ",") {
+		pair := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(pair) != 2 || pair[0] == "" {
+			return nil, fmt.Errorf("invalid tag filter '%s'; expected key=value", entry)
+		}
+		tags = append(tags, TagFilter{Key: pair[0], Values: strings.Split(pair[1], "|")})
+	}
+	return tags, nil
+}
+
+// Names of the sources that can add specifics
+var specificSources = []string{"inc-list", "inc-csv", "inc-dns", "inc-axfr", "inc-hexnnmi", "inc-dhcp-leases", "inc-nmap", "inc-kea", "inc-netbox", "inc-aws", "inc-azure", "inc-gcp", "inc-k8s", "inc-vsphere"}
+
+// SourcePrecedence defines which source wins when the same address arrives from multiple sources with different metadata.
+// Sources not listed have the lowest precedence, meaning the first one wins.
+type SourcePrecedence []string
+
+func ParseSourcePrecedence(value string) (SourcePrecedence, error) {
+	precedence := make(SourcePrecedence, 0)
+	if strings.TrimSpace(value) == "" {
+		return precedence, nil
+	}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		valid := false
+		for _, source := range specificSources {
+			if name == source {
+				valid = true
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid source %s; valid sources are %s", name, strings.Join(specificSources, ", "))
+		}
+		precedence = append(precedence, name)
+	}
+	return precedence, nil
+}
+
+func (p SourcePrecedence) rank(source string) int {
+	for i, name := range p {
+		if name == source {
+			return i
+		}
+	}
+	return len(p)
+}
+
+// Prefers returns true when source a has a higher precedence than source b
+func (p SourcePrecedence) Prefers(a, b string) bool {
+	return p.rank(a) < p.rank(b)
+}
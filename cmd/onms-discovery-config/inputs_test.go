@@ -0,0 +1,172 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestParseInputFile(t *testing.T) {
+	dir := t.TempDir()
+	siteA, siteB := filepath.Join(dir, "site-a.txt"), filepath.Join(dir, "site-b.txt")
+	for _, path := range []string{siteA, siteB} {
+		if err := ioutil.WriteFile(path, []byte("10.0.0.1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	input, err := ParseInputFile(siteA + ":retries=2,timeout=5000")
+	if err != nil {
+		t.Fatalf("cannot parse input: %v", err)
+	}
+	if input.Path != siteA {
+		t.Errorf("incorrect path: %s", input.Path)
+	}
+	if input.Attributes.Retries != 2 || input.Attributes.Timeout != 5000 {
+		t.Errorf("incorrect attributes: %v", input.Attributes)
+	}
+
+	input, err = ParseInputFile(`C:\data\site-b.txt`)
+	if err != nil {
+		t.Fatalf("cannot parse input: %v", err)
+	}
+	if input.Path != `C:\data\site-b.txt` {
+		t.Errorf("incorrect path: %s", input.Path)
+	}
+
+	input, err = ParseInputFile(siteB + ":location=Branch,foreign-source=Branches")
+	if err != nil {
+		t.Fatalf("cannot parse input: %v", err)
+	}
+	if input.Attributes.Location != "Branch" || input.Attributes.ForeignSource != "Branches" {
+		t.Errorf("incorrect attributes: %v", input.Attributes)
+	}
+
+	input, err = ParseInputFile(siteB + ":location=DC/East,foreign-source=Servers?Linux&Windows")
+	if err != nil {
+		t.Fatalf("cannot parse input: %v", err)
+	}
+	if input.Path != siteB || input.Attributes.Location != "DC/East" || input.Attributes.ForeignSource != "Servers?Linux&Windows" {
+		t.Errorf("values with URL characters should be accepted: %s %v", input.Path, input.Attributes)
+	}
+
+	input, err = ParseInputFile("http://server:8080/ips?format=txt")
+	if err != nil || input.Path != "http://server:8080/ips?format=txt" {
+		t.Errorf("URLs without annotations should be preserved: %v", input)
+	}
+	input, err = ParseInputFile("http://server:8080/ips?format=txt:location=DC/East")
+	if err != nil || input.Path != "http://server:8080/ips?format=txt" || input.Attributes.Location != "DC/East" {
+		t.Errorf("URLs with annotations should be split at the last colon: %v %v", input, err)
+	}
+
+	if _, err := ParseInputFile(filepath.Join(dir, "missing.txt") + ":location=DC/East"); err == nil {
+		t.Errorf("the annotations of a file that doesn't exist should fail")
+	}
+	if _, err := ParseInputFile(dir + ":location=DC"); err == nil {
+		t.Errorf("the annotations of a directory should fail")
+	}
+	if _, err := ParseInputFile(siteA + ":retries=two"); err == nil {
+		t.Errorf("invalid retries should fail")
+	}
+	if _, err := ParseInputFile(siteA + ":color=red"); err == nil {
+		t.Errorf("unknown attributes should fail")
+	}
+}
+
+func TestParseInputSelector(t *testing.T) {
+	input, err := ParseInputSelector("subnets:location=AWS/East", awsResources...)
+	if err != nil {
+		t.Fatalf("cannot parse input: %v", err)
+	}
+	if input.Path != "subnets" || input.Attributes.Location != "AWS/East" {
+		t.Errorf("incorrect input: %s %v", input.Path, input.Attributes)
+	}
+	if _, err := ParseInputSelector("networks:location=AWS", awsResources...); err == nil {
+		t.Errorf("unknown selectors with annotations should fail")
+	}
+}
+
+func TestSourcePrecedence(t *testing.T) {
+	if _, err := ParseSourcePrecedence("inc-list,netbox"); err == nil {
+		t.Errorf("netbox should not be a valid source")
+	}
+	p, err := ParseSourcePrecedence("inc-dns, inc-list")
+	if err != nil {
+		t.Fatalf("cannot parse precedence: %v", err)
+	}
+	if !p.Prefers("inc-dns", "inc-list") {
+		t.Errorf("inc-dns should be preferred over inc-list")
+	}
+	if !p.Prefers("inc-list", "inc-hexnnmi") {
+		t.Errorf("inc-list should be preferred over inc-hexnnmi")
+	}
+	if p.Prefers("inc-hexnnmi", "inc-list") {
+		t.Errorf("inc-hexnnmi should not be preferred over inc-list")
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	cases := map[string]int{
+		"5000":       5000,
+		" 5000 ":     5000,
+		"5000;":      5000,
+		"5,000":      5000,
+		"5.000":      5000,
+		"5'000":      5000,
+		"5 000":      5000,
+		"5\u00a0000": 5000,
+		"1,000,000":  1000000,
+		"0":          0,
+		"007":        7,
+		"12_345":     12345,
+		"1.234.567":  1234567,
+	}
+	for value, expected := range cases {
+		if n, err := ParseNumber(value); err != nil || n != expected {
+			t.Errorf("unexpected number for %q: %d %v", value, n, err)
+		}
+	}
+	for _, value := range []string{"", "2.5", "2,5", "5,00", "5000,000", "1,000.000", "5/000", "-1", "five", "1,,000", ",000"} {
+		if n, err := ParseNumber(value); err == nil {
+			t.Errorf("%q should be invalid, got %d", value, n)
+		}
+	}
+}
+
+func TestNormalizedInputs(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_normalize")
+	if err != nil {
+		t.Fatalf("cannot create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	ips := filepath.Join(dir, "ips.txt")
+	ioutil.WriteFile(ips, []byte("010.000.000.001\n10.0.0.1:161\n10.0.0.2;\n\"10.0.0.3\"\n[2001:DB8::1]:161\n"), 0644)
+	excluded := filepath.Join(dir, "excluded.txt")
+	ioutil.WriteFile(excluded, []byte("010.000.000.002\n"), 0644)
+	cidrs := filepath.Join(dir, "cidrs.txt")
+	ioutil.WriteFile(cidrs, []byte("192.168.001.000/24,\n"), 0644)
+
+	addressBlackList = make(map[string]bool)
+	addressWhiteList = make(map[string]string)
+	summary = NewSummary()
+	opts := &Options{IncludeList: ips, ExcludeList: excluded, IncludeCIDR: cidrs}
+	def := &discovery.Definition{}
+	if err := buildDefinition(opts, def, newStreamedSet()); err != nil {
+		t.Fatalf("cannot build definition: %v", err)
+	}
+	if len(def.IncludeRanges) != 1 || def.IncludeRanges[0].Begin.String() != "192.168.1.1" {
+		t.Errorf("the CIDR should be normalized: %s", def.String())
+	}
+	specifics := make([]string, 0)
+	for _, s := range def.Specifics {
+		specifics = append(specifics, s.IP.String())
+	}
+	if len(specifics) != 3 || specifics[0] != "10.0.0.1" || specifics[1] != "10.0.0.3" || specifics[2] != "2001:db8::1" {
+		t.Errorf("the addresses should be normalized, deduplicated and excluded: %v", specifics)
+	}
+}
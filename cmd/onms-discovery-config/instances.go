@@ -0,0 +1,155 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Support for pushing the configuration to multiple OpenNMS instances,
+// each of them receiving only the content for the locations it handles.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+type Instance struct {
+	Name      string
+	Home      string
+	Host      string // Address of eventd; defaults to 'onms-host'
+	Port      int
+	Locations []string // Empty means all locations
+}
+
+// ParseInstance parses an instance definition; e.x. name=east,home=/mnt/east/opennms,host=10.0.0.5,port=5817,locations=Paris;Berlin
+func ParseInstance(spec string) (*Instance, error) {
+	instance := &Instance{Port: 5817}
+	for _, entry := range strings.Split(spec, ",") {
+		pair := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("invalid instance attribute '%s'", entry)
+		}
+		key, value := strings.TrimSpace(pair[0]), strings.TrimSpace(pair[1])
+		switch key {
+		case "name":
+			instance.Name = value
+		case "home":
+			instance.Home = value
+		case "host":
+			instance.Host = value
+		case "port":
+			port, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid instance port '%s'", value)
+			}
+			instance.Port = port
+		case "locations":
+			for _, location := range strings.Split(value, ";") {
+				if location = strings.TrimSpace(location); location != "" {
+					instance.Locations = append(instance.Locations, location)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unknown instance attribute '%s'", key)
+		}
+	}
+	if instance.Home == "" {
+		return nil, fmt.Errorf("the home path is required for instance '%s'", spec)
+	}
+	if instance.Name == "" {
+		instance.Name = instance.Home
+	}
+	return instance, nil
+}
+
+// Handles returns true when the instance is responsible for the given location; an empty location means Default
+func (i *Instance) Handles(location string) bool {
+	if len(i.Locations) == 0 {
+		return true
+	}
+	if location == "" {
+		location = "Default"
+	}
+	for _, l := range i.Locations {
+		if l == location {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterByInstance returns a copy of the configuration with only the content for the locations handled by the instance.
+// Elements without a location inherit it from their definition. Definitions without includes are discarded.
+func FilterByInstance(cfg *discovery.DiscoveryConfiguration, instance *Instance) *discovery.DiscoveryConfiguration {
+	filtered := *cfg
+	filtered.Definitions = make([]discovery.Definition, 0)
+	for _, def := range cfg.Definitions {
+		effective := func(location string) string {
+			if location == "" {
+				return def.Location
+			}
+			return location
+		}
+		d := def
+		d.Specifics = make([]discovery.Specific, 0)
+		d.IncludeRanges = make([]discovery.IncludeRange, 0)
+		d.ExcludeRanges = make([]discovery.ExcludeRange, 0)
+		d.IncludeURLs = make([]discovery.IncludeURL, 0)
+		for _, s := range def.Specifics {
+			if instance.Handles(effective(s.Location)) {
+				d.Specifics = append(d.Specifics, s)
+			}
+		}
+		for _, r := range def.IncludeRanges {
+			if instance.Handles(effective(r.Location)) {
+				d.IncludeRanges = append(d.IncludeRanges, r)
+			}
+		}
+		for _, u := range def.IncludeURLs {
+			if instance.Handles(effective(u.Location)) {
+				d.IncludeURLs = append(d.IncludeURLs, u)
+			}
+		}
+		if len(d.Specifics) == 0 && len(d.IncludeRanges) == 0 && len(d.IncludeURLs) == 0 {
+			continue
+		}
+		for _, r := range def.ExcludeRanges {
+			if instance.Handles(effective(r.Location)) {
+				d.ExcludeRanges = append(d.ExcludeRanges, r)
+			}
+		}
+		filtered.Definitions = append(filtered.Definitions, d)
+	}
+	return &filtered
+}
+
+// deployTarget is an OpenNMS instance with the configuration to deploy on it
+type deployTarget struct {
+	instance *Instance
+	cfg      *discovery.DiscoveryConfiguration
+}
+
+// deployTargets returns the OpenNMS instances to update, each one with its content: the one of the tenants using it,
+// or the one for the locations it handles
+func (o *Options) deployTargets(cfg *discovery.DiscoveryConfiguration) ([]deployTarget, error) {
+	if len(o.Tenants) > 0 {
+		tenants, err := o.GetTenants()
+		if err != nil {
+			return nil, err
+		}
+		return tenantTargets(cfg, tenants), nil
+	}
+	instances, err := o.GetInstances()
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]deployTarget, 0, len(instances))
+	for _, instance := range instances {
+		instanceConfig := cfg
+		if len(instance.Locations) > 0 {
+			instanceConfig = FilterByInstance(cfg, instance)
+		}
+		targets = append(targets, deployTarget{instance, instanceConfig})
+	}
+	return targets, nil
+}
@@ -0,0 +1,75 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestParseInstance(t *testing.T) {
+	instance, err := ParseInstance("name=east,home=/mnt/east/opennms,port=5818,locations=Paris;Berlin")
+	if err != nil {
+		t.Fatalf("cannot parse instance: %v", err)
+	}
+	if instance.Name != "east" || instance.Home != "/mnt/east/opennms" || instance.Port != 5818 {
+		t.Errorf("incorrect instance: %v", instance)
+	}
+	if len(instance.Locations) != 2 || !instance.Handles("Berlin") || instance.Handles("Default") {
+		t.Errorf("incorrect locations: %v", instance.Locations)
+	}
+	opts := &Options{OnmsHost: "10.0.0.1", Instances: StringList{"home=/mnt/east/opennms", "home=/mnt/west/opennms,host=10.0.0.2"}}
+	instances, err := opts.GetInstances()
+	if err != nil {
+		t.Fatalf("cannot get instances: %v", err)
+	}
+	if instances[0].Host != "10.0.0.1" || instances[1].Host != "10.0.0.2" {
+		t.Errorf("the host should default to onms-host: %v, %v", instances[0], instances[1])
+	}
+	if _, err := ParseInstance("name=west"); err == nil {
+		t.Errorf("an instance without home should fail")
+	}
+	if _, err := ParseInstance("home=/opt/opennms,color=red"); err == nil {
+		t.Errorf("unknown attributes should fail")
+	}
+}
+
+func TestFilterByInstance(t *testing.T) {
+	def := discovery.Definition{}
+	def.IncludeCIDRWithAttributes("192.168.0.0/24", discovery.Attributes{Location: "Paris"})
+	def.IncludeCIDR("192.168.1.0/24")
+	def.AddSpecificWithAttributes("10.0.0.1", discovery.Attributes{Location: "Berlin"})
+	def.AddSpecific("10.0.0.2")
+	def.ExcludeCIDR("192.168.0.0/28")
+	cfg := discovery.DiscoveryConfiguration{
+		Definitions: []discovery.Definition{def},
+	}
+
+	paris := FilterByInstance(&cfg, &Instance{Locations: []string{"Paris"}})
+	if len(paris.Definitions) != 1 {
+		t.Fatalf("there should be one definition for Paris")
+	}
+	d := paris.Definitions[0]
+	if len(d.IncludeRanges) != 1 || len(d.Specifics) != 0 {
+		t.Errorf("incorrect content for Paris: %s", paris.String())
+	}
+	if len(d.ExcludeRanges) != 0 {
+		t.Errorf("exclude ranges for the Default location should not be sent to Paris")
+	}
+
+	defaults := FilterByInstance(&cfg, &Instance{Locations: []string{"Default"}})
+	d = defaults.Definitions[0]
+	if len(d.IncludeRanges) != 1 || len(d.Specifics) != 1 || len(d.ExcludeRanges) != 1 {
+		t.Errorf("incorrect content for Default: %s", defaults.String())
+	}
+
+	if none := FilterByInstance(&cfg, &Instance{Locations: []string{"London"}}); len(none.Definitions) != 0 {
+		t.Errorf("there should be no definitions for London")
+	}
+
+	all := FilterByInstance(&cfg, &Instance{})
+	if len(all.Definitions[0].IncludeRanges) != 2 || len(all.Definitions[0].Specifics) != 2 {
+		t.Errorf("an instance without locations should get everything")
+	}
+}
@@ -0,0 +1,113 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// IP math operations exposed as a command, to help operators building input files
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+// ErrNotContained is returned by the contains operation when the address or range is not part of the range
+var ErrNotContained = errors.New("not contained")
+
+const ipToolUsage = `operations:
+  cidr-to-range <cidr>              Range of a CIDR, including the network and broadcast addresses
+  range-to-cidrs <begin-end>        Smallest list of CIDRs that cover a range
+  count <cidr|begin-end|ip>...      Number of addresses of the CIDRs or ranges
+  contains <cidr|begin-end> <cidr|begin-end|ip>
+                                    Whether or not the second argument is part of the first one
+  next <ip> [n]                     Address n positions after the given one (1 by default)
+  prev <ip> [n]                     Address n positions before the given one (1 by default)`
+
+// IPTool runs an IP math operation, and returns its output
+func IPTool(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("an operation is required\n%s", ipToolUsage)
+	}
+	operation, args := args[0], args[1:]
+	switch operation {
+	case "cidr-to-range":
+		if len(args) != 1 || !strings.Contains(args[0], "/") {
+			return "", fmt.Errorf("usage: cidr-to-range <cidr>")
+		}
+		r, err := iprange.ParseRange(args[0])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s-%s", r.Begin, r.End), nil
+	case "range-to-cidrs":
+		if len(args) != 1 {
+			return "", fmt.Errorf("usage: range-to-cidrs <begin-end>")
+		}
+		r, err := iprange.ParseRange(args[0])
+		if err != nil {
+			return "", err
+		}
+		return strings.Join(iprange.RangeToCIDRs(r), "\n"), nil
+	case "count":
+		if len(args) == 0 {
+			return "", fmt.Errorf("usage: count <cidr|begin-end|ip>...")
+		}
+		set := new(iprange.IPAddressRangeSet) // Overlapping arguments are counted once
+		for _, arg := range args {
+			r, err := iprange.ParseRange(arg)
+			if err != nil {
+				return "", err
+			}
+			set.Add(r)
+		}
+		total := big.NewInt(0)
+		for _, r := range set.Get() {
+			total.Add(total, r.Size())
+		}
+		return total.String(), nil
+	case "contains":
+		if len(args) != 2 {
+			return "", fmt.Errorf("usage: contains <cidr|begin-end> <cidr|begin-end|ip>")
+		}
+		outer, err := iprange.ParseRange(args[0])
+		if err != nil {
+			return "", err
+		}
+		inner, err := iprange.ParseRange(args[1])
+		if err != nil {
+			return "", err
+		}
+		if (outer.Begin.To4() == nil) != (inner.Begin.To4() == nil) || !outer.Contains(inner.Begin) || !outer.Contains(inner.End) {
+			return "", ErrNotContained
+		}
+		return "true", nil
+	case "next", "prev":
+		if len(args) < 1 || len(args) > 2 {
+			return "", fmt.Errorf("usage: %s <ip> [n]", operation)
+		}
+		ip := net.ParseIP(args[0])
+		if ip == nil {
+			return "", fmt.Errorf("invalid IP address %s", args[0])
+		}
+		n := int64(1)
+		if len(args) == 2 {
+			var err error
+			if n, err = strconv.ParseInt(args[1], 10, 64); err != nil || n < 0 {
+				return "", fmt.Errorf("invalid offset %s", args[1])
+			}
+		}
+		if operation == "prev" {
+			n = -n
+		}
+		result, ok := iprange.CheckedOffset(ip, n)
+		if !ok {
+			return "", fmt.Errorf("the result is outside of the address space")
+		}
+		return result.String(), nil
+	}
+	return "", fmt.Errorf("unknown operation %s\n%s", operation, ipToolUsage)
+}
@@ -0,0 +1,45 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIPTool(t *testing.T) {
+	tests := []struct {
+		args   []string
+		output string
+	}{
+		{[]string{"cidr-to-range", "10.0.0.0/24"}, "10.0.0.0-10.0.0.255"},
+		{[]string{"cidr-to-range", "2001:db8::/126"}, "2001:db8::-2001:db8::3"},
+		{[]string{"range-to-cidrs", "10.0.0.0-10.0.0.255"}, "10.0.0.0/24"},
+		{[]string{"range-to-cidrs", "10.0.0.1-10.0.0.10"}, "10.0.0.1/32\n10.0.0.2/31\n10.0.0.4/30\n10.0.0.8/31\n10.0.0.10/32"},
+		{[]string{"range-to-cidrs", "2001:db8::-2001:db8::ffff"}, "2001:db8::/112"},
+		{[]string{"count", "10.0.0.0/24", "10.0.0.10-10.0.1.9", "192.168.0.1"}, "267"},
+		{[]string{"count", "2001:db8::/64"}, "18446744073709551616"},
+		{[]string{"contains", "10.0.0.0/24", "10.0.0.5"}, "true"},
+		{[]string{"contains", "10.0.0.0/24", "10.0.0.0/25"}, "true"},
+		{[]string{"next", "10.0.0.255"}, "10.0.1.0"},
+		{[]string{"prev", "10.0.1.0", "256"}, "10.0.0.0"},
+		{[]string{"next", "2001:db8::ffff"}, "2001:db8::1:0"},
+	}
+	for _, test := range tests {
+		output, err := IPTool(test.args)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.args, err)
+		} else if output != test.output {
+			t.Errorf("%v: expected %q, got %q", test.args, test.output, output)
+		}
+	}
+
+	if _, err := IPTool([]string{"contains", "10.0.0.0/24", "10.0.1.5"}); !errors.Is(err, ErrNotContained) {
+		t.Errorf("expected ErrNotContained, got %v", err)
+	}
+	for _, args := range [][]string{{}, {"unknown"}, {"next", "255.255.255.255"}, {"prev", "0.0.0.0"}, {"range-to-cidrs", "10.0.0.10-10.0.0.1"}} {
+		if _, err := IPTool(args); err == nil {
+			t.Errorf("%v should fail", args)
+		}
+	}
+}
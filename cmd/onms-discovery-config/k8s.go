@@ -0,0 +1,186 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Kubernetes as a source of the internal IP addresses of the nodes and the external IP addresses of the services, via client-go
+// https://kubernetes.io/docs/reference/kubernetes-api/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// k8sServiceAccountDir contains the credentials of the pod when running inside a cluster
+var k8sServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// k8sResources are the values accepted by -inc-k8s
+var k8sResources = []string{"nodes", "services", "all"}
+
+type K8sClient struct {
+	Server    string // e.x. https://10.0.0.1:6443
+	clientset kubernetes.Interface
+}
+
+// NewK8sClient creates a client for the API server of the given configuration, sending the requests through apiTransport.
+// The credential plugins of the configuration don't run when replaying the API responses.
+func NewK8sClient(config *rest.Config) (*K8sClient, error) {
+	config = rest.CopyConfig(config)
+	if config.AuthProvider != nil {
+		return nil, fmt.Errorf("the credentials require the auth-provider plugin %s, which is not supported; use its exec credential plugin or a service account token instead", config.AuthProvider.Name)
+	}
+	if r, ok := apiTransport.(*APIRecorder); ok {
+		if r.Replay {
+			config.ExecProvider = nil
+		}
+		config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+			recorder := *r
+			recorder.Transport = rt
+			return &recorder
+		}
+	}
+	config.Timeout = 60 * time.Second
+	config.ContentType = "application/json"
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create the Kubernetes client: %v", err)
+	}
+	return &K8sClient{Server: config.Host, clientset: clientset}, nil
+}
+
+// inClusterK8sConfig returns the configuration with the service account of the pod running the tool, like rest.InClusterConfig
+func inClusterK8sConfig() (*rest.Config, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running inside a Kubernetes cluster")
+	}
+	tokenFile := filepath.Join(k8sServiceAccountDir, "token")
+	if _, err := os.Stat(tokenFile); err != nil {
+		return nil, fmt.Errorf("cannot read the service account token: %v", err)
+	}
+	return &rest.Config{
+		Host:            "https://" + net.JoinHostPort(host, port),
+		BearerTokenFile: tokenFile,
+		TLSClientConfig: rest.TLSClientConfig{CAFile: filepath.Join(k8sServiceAccountDir, "ca.crt")},
+	}, nil
+}
+
+// LoadKubeConfig creates a client for the given context of a kubeconfig file, or its current context when empty.
+// The static credentials (tokens, client certificates and basic authentication) and the exec credential plugins
+// are supported, but not the deprecated auth-provider plugins.
+func LoadKubeConfig(fileName, context string) (*K8sClient, error) {
+	rules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: fileName}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: context}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig %s: %v", fileName, err)
+	}
+	return NewK8sClient(config)
+}
+
+// k8sClient returns the client for the given resources of 'inc-k8s', using the kubeconfig, or the service account when running inside a cluster
+func (o *Options) k8sClient(resources string) (*K8sClient, error) {
+	valid := false
+	for _, r := range k8sResources {
+		valid = valid || r == resources
+	}
+	if !valid {
+		return nil, fmt.Errorf("invalid Kubernetes resources %s; valid resources are %s", resources, strings.Join(k8sResources, ", "))
+	}
+	fileName := o.K8sConfig
+	if fileName == "" {
+		fileName = strings.Split(os.Getenv("KUBECONFIG"), string(os.PathListSeparator))[0]
+	}
+	if fileName == "" && os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		config, err := inClusterK8sConfig()
+		if err != nil {
+			return nil, err
+		}
+		return NewK8sClient(config)
+	}
+	if fileName == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("cannot find kubeconfig: %v", err)
+		}
+		fileName = filepath.Join(home, ".kube", "config")
+	}
+	return LoadKubeConfig(fileName, o.K8sContext)
+}
+
+// list gets the objects of a collection that match the label selector following the pagination, passing every page to the handler,
+// which returns the continue token of the page
+func (c *K8sClient) list(selector string, get func(opts metav1.ListOptions) (string, error)) error {
+	opts := metav1.ListOptions{LabelSelector: selector, Limit: 500}
+	for {
+		next := ""
+		err := lookupThrottle.Do(func() error {
+			var e error
+			next, e = get(opts)
+			return e
+		})
+		if err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		opts.Continue = next
+	}
+}
+
+// GetNodeAddresses returns the internal IP addresses of the nodes that match the label selector
+func (c *K8sClient) GetNodeAddresses(selector string) ([]string, error) {
+	addresses := make([]string, 0)
+	err := c.list(selector, func(opts metav1.ListOptions) (string, error) {
+		nodes, err := c.clientset.CoreV1().Nodes().List(context.Background(), opts)
+		if err != nil {
+			return "", err
+		}
+		for _, node := range nodes.Items {
+			for _, a := range node.Status.Addresses {
+				if a.Type == corev1.NodeInternalIP && a.Address != "" {
+					addresses = append(addresses, a.Address)
+				}
+			}
+		}
+		return nodes.Continue, nil
+	})
+	return addresses, err
+}
+
+// GetServiceAddresses returns the load balancer and external IP addresses of the LoadBalancer and NodePort services that match the label selector;
+// the NodePort services are also reachable through the addresses of the nodes.
+func (c *K8sClient) GetServiceAddresses(selector string) ([]string, error) {
+	addresses := make([]string, 0)
+	err := c.list(selector, func(opts metav1.ListOptions) (string, error) {
+		services, err := c.clientset.CoreV1().Services(metav1.NamespaceAll).List(context.Background(), opts)
+		if err != nil {
+			return "", err
+		}
+		for _, svc := range services.Items {
+			if svc.Spec.Type != corev1.ServiceTypeLoadBalancer && svc.Spec.Type != corev1.ServiceTypeNodePort {
+				continue
+			}
+			for _, ingress := range svc.Status.LoadBalancer.Ingress {
+				if ingress.IP != "" { // Some load balancers only have a hostname
+					addresses = append(addresses, ingress.IP)
+				}
+			}
+			addresses = append(addresses, svc.Spec.ExternalIPs...)
+		}
+		return services.Continue, nil
+	})
+	return addresses, err
+}
@@ -0,0 +1,235 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func k8sTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer my-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("labelSelector") != "discovery=true" {
+			t.Errorf("the label selector was not applied: %s", r.URL.RawQuery)
+		}
+		switch r.URL.Path {
+		case "/api/v1/nodes":
+			if r.URL.Query().Get("continue") == "" {
+				w.Write([]byte(`{"metadata":{"continue":"next"},"items":[{"status":{"addresses":[{"type":"Hostname","address":"node1"},{"type":"InternalIP","address":"10.0.0.11"},{"type":"ExternalIP","address":"34.1.1.1"}]}}]}`))
+			} else {
+				w.Write([]byte(`{"metadata":{},"items":[{"status":{"addresses":[{"type":"InternalIP","address":"10.0.0.12"},{"type":"InternalIP","address":"fd00::12"}]}}]}`))
+			}
+		case "/api/v1/services":
+			w.Write([]byte(`{"metadata":{},"items":[
+				{"spec":{"type":"ClusterIP","clusterIP":"10.96.0.1"}},
+				{"spec":{"type":"LoadBalancer"},"status":{"loadBalancer":{"ingress":[{"ip":"10.0.1.100"},{"hostname":"lb.example.com"}]}}},
+				{"spec":{"type":"NodePort","externalIPs":["10.0.1.200"]}}
+			]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestK8sClient(t *testing.T) {
+	server := k8sTestServer(t)
+	defer server.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "token"), []byte("my-token\n"), 0600); err != nil {
+		t.Fatalf("cannot write token: %v", err)
+	}
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+current-context: prod
+clusters:
+- name: prod-cluster
+  cluster:
+    server: %s
+    certificate-authority-data: %s
+contexts:
+- name: prod
+  context:
+    cluster: prod-cluster
+    user: discovery
+- name: legacy
+  context:
+    cluster: prod-cluster
+    user: gcp
+users:
+- name: discovery
+  user:
+    tokenFile: token
+- name: gcp
+  user:
+    auth-provider:
+      name: gcp
+`, server.URL, base64.StdEncoding.EncodeToString(ca))
+	fileName := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(fileName, []byte(kubeconfig), 0600); err != nil {
+		t.Fatalf("cannot write kubeconfig: %v", err)
+	}
+
+	opts := &Options{K8sConfig: fileName}
+	if _, err := opts.k8sClient("pods"); err == nil {
+		t.Errorf("the resources should be invalid")
+	}
+	opts.K8sContext = "legacy"
+	if _, err := opts.k8sClient("all"); err == nil || !strings.Contains(err.Error(), "auth-provider") {
+		t.Errorf("the auth-provider plugin should not be supported, got %v", err)
+	}
+	opts.K8sContext = ""
+	client, err := opts.k8sClient("all")
+	if err != nil {
+		t.Fatalf("cannot create client: %v", err)
+	}
+	nodes, err := client.GetNodeAddresses("discovery=true")
+	if err != nil {
+		t.Fatalf("cannot get nodes: %v", err)
+	}
+	if strings.Join(nodes, ",") != "10.0.0.11,10.0.0.12,fd00::12" {
+		t.Errorf("unexpected node addresses: %v", nodes)
+	}
+	services, err := client.GetServiceAddresses("discovery=true")
+	if err != nil {
+		t.Fatalf("cannot get services: %v", err)
+	}
+	if strings.Join(services, ",") != "10.0.1.100,10.0.1.200" {
+		t.Errorf("unexpected service addresses: %v", services)
+	}
+}
+
+func TestK8sInCluster(t *testing.T) {
+	server := k8sTestServer(t)
+	defer server.Close()
+	dir := t.TempDir()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	ioutil.WriteFile(filepath.Join(dir, "ca.crt"), ca, 0600)
+	ioutil.WriteFile(filepath.Join(dir, "token"), []byte("my-token"), 0600)
+	defer func(old string) { k8sServiceAccountDir = old }(k8sServiceAccountDir)
+	k8sServiceAccountDir = dir
+
+	u, _ := url.Parse(server.URL)
+	t.Setenv("KUBECONFIG", "")
+	t.Setenv("KUBERNETES_SERVICE_HOST", u.Hostname())
+	t.Setenv("KUBERNETES_SERVICE_PORT", u.Port())
+	client, err := new(Options).k8sClient("nodes")
+	if err != nil {
+		t.Fatalf("cannot create client: %v", err)
+	}
+	nodes, err := client.GetNodeAddresses("discovery=true")
+	if err != nil {
+		t.Fatalf("cannot get nodes: %v", err)
+	}
+	if len(nodes) != 3 {
+		t.Errorf("unexpected node addresses: %v", nodes)
+	}
+}
+
+func TestK8sExecCredential(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test plugin is a shell script")
+	}
+	server := k8sTestServer(t)
+	defer server.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	dir := t.TempDir()
+	plugin := `#!/bin/sh
+echo run >> "$(dirname "$0")/calls"
+case "$KUBERNETES_EXEC_INFO" in
+  *'"server":"` + server.URL + `"'*'"interactive":false'*) ;;
+  *) echo "unexpected exec info: $KUBERNETES_EXEC_INFO" >&2; exit 1 ;;
+esac
+echo '{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential","status":{"token":"'$PLUGIN_TOKEN'","expirationTimestamp":"` + time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `"}}'
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "plugin.sh"), []byte(plugin), 0700); err != nil {
+		t.Fatalf("cannot write plugin: %v", err)
+	}
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+current-context: cloud
+clusters:
+- name: cloud-cluster
+  cluster:
+    server: %s
+    certificate-authority-data: %s
+contexts:
+- name: cloud
+  context:
+    cluster: cloud-cluster
+    user: plugin
+- name: failing
+  context:
+    cluster: cloud-cluster
+    user: failing
+- name: old
+  context:
+    cluster: cloud-cluster
+    user: old
+users:
+- name: plugin
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1
+      command: ./plugin.sh
+      env:
+      - name: PLUGIN_TOKEN
+        value: my-token
+      provideClusterInfo: true
+      interactiveMode: IfAvailable
+- name: failing
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: ./plugin.sh
+- name: old
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1alpha1
+      command: ./plugin.sh
+`, server.URL, base64.StdEncoding.EncodeToString(ca))
+	fileName := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(fileName, []byte(kubeconfig), 0600); err != nil {
+		t.Fatalf("cannot write kubeconfig: %v", err)
+	}
+
+	client, err := LoadKubeConfig(fileName, "")
+	if err != nil {
+		t.Fatalf("cannot create client: %v", err)
+	}
+	if nodes, err := client.GetNodeAddresses("discovery=true"); err != nil || len(nodes) != 3 {
+		t.Fatalf("cannot get nodes with the token from the plugin: %v %v", nodes, err)
+	}
+	if _, err := client.GetServiceAddresses("discovery=true"); err != nil {
+		t.Fatalf("cannot get services with the token from the plugin: %v", err)
+	}
+	if calls, _ := ioutil.ReadFile(filepath.Join(dir, "calls")); strings.Count(string(calls), "run") != 1 {
+		t.Errorf("the credential should be cached until it expires, got %d runs", strings.Count(string(calls), "run"))
+	}
+
+	client, err = LoadKubeConfig(fileName, "failing")
+	if err != nil {
+		t.Fatalf("cannot create client: %v", err)
+	}
+	if _, err := client.GetNodeAddresses("discovery=true"); err == nil || !strings.Contains(err.Error(), "exit code 1") {
+		t.Errorf("the failure of the plugin should be reported, got %v", err)
+	}
+	if _, err := LoadKubeConfig(fileName, "old"); err == nil || !strings.Contains(err.Error(), "invalid apiVersion") {
+		t.Errorf("the plugin version should be invalid, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Alternative way to reload the Discovery daemon via the Karaf SSH shell, for when TCP 5817 is not reachable
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type KarafShell struct {
+	Address  string // host:port of the Karaf SSH shell, usually port 8101
+	User     string
+	Password string
+	HostKey  string // Expected SHA256 fingerprint of the host key; required unless Insecure is set
+	Insecure bool   // Whether or not to accept any host key when HostKey is empty
+	Command  string
+	Timeout  time.Duration
+}
+
+func (k *KarafShell) hostKeyCallback() ssh.HostKeyCallback {
+	if k.HostKey == "" {
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if !k.Insecure {
+				return fmt.Errorf("the host key %s for %s cannot be verified; use -karaf-host-key, or -karaf-insecure to accept any key", ssh.FingerprintSHA256(key), hostname)
+			}
+			log.Printf("warning: accepting Karaf host key %s without verification", ssh.FingerprintSHA256(key))
+			return nil
+		}
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if fingerprint := ssh.FingerprintSHA256(key); fingerprint != k.HostKey {
+			return fmt.Errorf("unexpected host key %s for %s", fingerprint, hostname)
+		}
+		return nil
+	}
+}
+
+// Reload executes the reload command on the Karaf shell
+func (k *KarafShell) Reload() error {
+	config := &ssh.ClientConfig{
+		User:            k.User,
+		Auth:            []ssh.AuthMethod{ssh.Password(k.Password)},
+		HostKeyCallback: k.hostKeyCallback(),
+		Timeout:         k.Timeout,
+	}
+	client, err := ssh.Dial("tcp", k.Address, config)
+	if err != nil {
+		return fmt.Errorf("cannot connect to the Karaf shell at %s: %v", k.Address, err)
+	}
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("cannot open a session with the Karaf shell: %v", err)
+	}
+	defer session.Close()
+	output, err := session.CombinedOutput(k.Command)
+	if err != nil {
+		return fmt.Errorf("cannot execute '%s' on the Karaf shell: %v %s", k.Command, err, strings.TrimSpace(string(output)))
+	}
+	// Karaf doesn't always set an exit status when a command fails
+	if text := strings.TrimSpace(string(output)); strings.HasPrefix(text, "Error") || strings.Contains(text, "Command not found") {
+		return fmt.Errorf("cannot execute '%s' on the Karaf shell: %s", k.Command, text)
+	}
+	return nil
+}
+
+func (o *Options) karafShell() *KarafShell {
+	return &KarafShell{
+		Address:  o.KarafAddress,
+		User:     o.KarafUser,
+		Password: o.KarafPassword,
+		HostKey:  o.KarafHostKey,
+		Insecure: o.KarafInsecure,
+		Command:  o.KarafCommand,
+		Timeout:  30 * time.Second,
+	}
+}
@@ -0,0 +1,106 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// startKarafServer starts an SSH server that accepts a single exec request, replying with the given output
+func startKarafServer(t *testing.T, output string, commands chan<- string) (string, ssh.PublicKey) {
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatalf("cannot create host key: %v", err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == "admin" && string(pass) == "admin" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("invalid credentials")
+		},
+	}
+	config.AddHostKey(signer)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot create SSH server: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		_, chans, reqs, err := ssh.NewServerConn(conn, config)
+		if err != nil {
+			return
+		}
+		go ssh.DiscardRequests(reqs)
+		for newChannel := range chans {
+			channel, requests, _ := newChannel.Accept()
+			for req := range requests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				var payload struct{ Command string }
+				ssh.Unmarshal(req.Payload, &payload)
+				commands <- payload.Command
+				req.Reply(true, nil)
+				channel.Write([]byte(output))
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+				channel.Close()
+			}
+		}
+	}()
+	return ln.Addr().String(), signer.PublicKey()
+}
+
+func TestKarafReload(t *testing.T) {
+	commands := make(chan string, 1)
+	address, key := startKarafServer(t, "", commands)
+	shell := &KarafShell{
+		Address:  address,
+		User:     "admin",
+		Password: "admin",
+		HostKey:  ssh.FingerprintSHA256(key),
+		Command:  "opennms:reload-daemon discovery",
+		Timeout:  5 * time.Second,
+	}
+	if err := shell.Reload(); err != nil {
+		t.Fatalf("cannot reload via Karaf: %v", err)
+	}
+	if cmd := <-commands; cmd != "opennms:reload-daemon discovery" {
+		t.Errorf("unexpected command: %s", cmd)
+	}
+}
+
+func TestKarafReloadFailures(t *testing.T) {
+	commands := make(chan string, 1)
+	address, _ := startKarafServer(t, "Error executing command: unknown daemon", commands)
+	shell := &KarafShell{Address: address, User: "admin", Password: "admin", HostKey: "SHA256:invalid", Command: "opennms:reload-daemon discovery", Timeout: 5 * time.Second}
+	if err := shell.Reload(); err == nil {
+		t.Errorf("an unexpected host key should fail")
+	}
+
+	address, _ = startKarafServer(t, "Error executing command: unknown daemon", commands)
+	shell.Address, shell.HostKey = address, ""
+	if err := shell.Reload(); err == nil || !strings.Contains(err.Error(), "cannot be verified") {
+		t.Errorf("an unverified host key should fail without karaf-insecure: %v", err)
+	}
+
+	address, _ = startKarafServer(t, "Error executing command: unknown daemon", commands)
+	shell.Address, shell.Insecure = address, true
+	if err := shell.Reload(); err == nil {
+		t.Errorf("a command that reports an error should fail")
+	}
+}
@@ -0,0 +1,157 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Kea DHCP as a source of the IP addresses of the active leases, via the REST API of the Control Agent
+// https://kea.readthedocs.io/en/latest/arm/hooks.html#libdhcp-lease-cmds-so-lease-commands-for-easier-lease-management
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// keaLeases are the values accepted by -kea-leases
+var keaLeases = []string{"ipv4", "ipv6", "all"}
+
+const (
+	keaResultSuccess = 0
+	keaResultEmpty   = 3
+	keaStateDefault  = 0 // The lease is assigned; other states are declined or expired-reclaimed
+)
+
+type KeaClient struct {
+	URL      string // e.x. https://kea.example.com:8000
+	User     string // Optional, for basic authentication
+	Password string
+	Client   *http.Client
+}
+
+// NewKeaClient returns a client for the Control Agent, with optional TLS settings (e.x. a client certificate)
+func NewKeaClient(url, user, password string, tlsConfig *tls.Config) *KeaClient {
+	transport := apiTransport
+	if tlsConfig != nil {
+		transport = apiTransportWithTLS(tlsConfig)
+	}
+	return &KeaClient{
+		URL:      url,
+		User:     user,
+		Password: password,
+		Client:   &http.Client{Timeout: 60 * time.Second, Transport: transport},
+	}
+}
+
+// keaTLSConfig returns the TLS settings from the options, or nil when there is nothing to customize
+func (o *Options) keaTLSConfig() (*tls.Config, error) {
+	if o.KeaCert == "" && o.KeaCA == "" {
+		return nil, nil
+	}
+	return loadTLSConfig("Kea", o.KeaCert, o.KeaKey, "kea-key", o.KeaCA)
+}
+
+// keaClient returns the client for the URL of 'inc-kea' based on the options
+func (o *Options) keaClient(url string) (*KeaClient, error) {
+	valid := false
+	for _, l := range keaLeases {
+		valid = valid || l == o.KeaLeases
+	}
+	if !valid {
+		return nil, fmt.Errorf("invalid Kea leases %s; valid leases are %s", o.KeaLeases, strings.Join(keaLeases, ", "))
+	}
+	tlsConfig, err := o.keaTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	return NewKeaClient(url, o.KeaUser, o.KeaPassword, tlsConfig), nil
+}
+
+// command sends a command to the given service through the Control Agent, and decodes the arguments of the response
+func (c *KeaClient) command(command, service string, arguments interface{}) error {
+	data, _ := json.Marshal(map[string]interface{}{"command": command, "service": []string{service}})
+	req, err := http.NewRequest(http.MethodPost, c.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.User != "" {
+		req.SetBasicAuth(c.User, c.Password)
+	}
+	var resp *http.Response
+	err = lookupThrottle.Do(func() error {
+		var e error
+		resp, e = c.Client.Do(req)
+		return e
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("POST %s failed with %s: %s", command, resp.Status, string(body))
+	}
+	// There is one response per service
+	responses := make([]struct {
+		Result    int             `json:"result"`
+		Text      string          `json:"text"`
+		Arguments json.RawMessage `json:"arguments"`
+	}, 0)
+	if err := json.NewDecoder(resp.Body).Decode(&responses); err != nil {
+		return fmt.Errorf("invalid response from %s: %v", command, err)
+	}
+	if len(responses) == 0 {
+		return fmt.Errorf("empty response from %s", command)
+	}
+	switch r := responses[0]; r.Result {
+	case keaResultSuccess:
+		if err := json.Unmarshal(r.Arguments, arguments); err != nil {
+			return fmt.Errorf("invalid response from %s: %v", command, err)
+		}
+	case keaResultEmpty:
+	default:
+		return fmt.Errorf("%s failed with result %d: %s", command, r.Result, r.Text)
+	}
+	return nil
+}
+
+// GetLeases returns the addresses of the assigned leases that have not expired, for 'ipv4', 'ipv6' or 'all'
+func (c *KeaClient) GetLeases(family string, now time.Time) ([]string, error) {
+	commands := []struct {
+		family, command, service string
+	}{
+		{"ipv4", "lease4-get-all", "dhcp4"},
+		{"ipv6", "lease6-get-all", "dhcp6"},
+	}
+	addresses := make([]string, 0)
+	for _, cmd := range commands {
+		if family != "all" && family != cmd.family {
+			continue
+		}
+		data := struct {
+			Leases []struct {
+				Address  string `json:"ip-address"`
+				State    int    `json:"state"`
+				CLTT     int64  `json:"cltt"`      // Client last transaction time
+				Lifetime int64  `json:"valid-lft"` // Valid lifetime in seconds
+			} `json:"leases"`
+		}{}
+		if err := c.command(cmd.command, cmd.service, &data); err != nil {
+			return nil, err
+		}
+		for _, lease := range data.Leases {
+			if lease.State != keaStateDefault {
+				continue
+			}
+			if lease.Lifetime > 0 && !time.Unix(lease.CLTT+lease.Lifetime, 0).After(now) {
+				continue
+			}
+			addresses = append(addresses, lease.Address)
+		}
+	}
+	return addresses, nil
+}
@@ -0,0 +1,75 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeaClient(t *testing.T) {
+	now := time.Unix(1710500000, 0)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, _ := r.BasicAuth(); user != "kea" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		cmd := struct {
+			Command string   `json:"command"`
+			Service []string `json:"service"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&cmd)
+		switch cmd.Command {
+		case "lease4-get-all":
+			if len(cmd.Service) != 1 || cmd.Service[0] != "dhcp4" {
+				t.Errorf("unexpected service: %v", cmd.Service)
+			}
+			w.Write([]byte(`[{"result":0,"text":"3 IPv4 lease(s) found.","arguments":{"leases":[
+				{"ip-address":"10.0.0.10","state":0,"cltt":1710499000,"valid-lft":3600},
+				{"ip-address":"10.0.0.11","state":1,"cltt":1710499000,"valid-lft":3600},
+				{"ip-address":"10.0.0.12","state":0,"cltt":1710400000,"valid-lft":3600}
+			]}}]`))
+		case "lease6-get-all":
+			w.Write([]byte(`[{"result":3,"text":"0 IPv6 lease(s) found."}]`))
+		default:
+			w.Write([]byte(`[{"result":2,"text":"'` + cmd.Command + `' command not supported."}]`))
+		}
+	}))
+	defer server.Close()
+
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	opts := &Options{KeaLeases: "v4", KeaUser: "kea", KeaPassword: "secret", KeaCA: ca}
+	if _, err := opts.keaClient(server.URL); err == nil {
+		t.Errorf("the leases should be invalid")
+	}
+	opts.KeaLeases = "all"
+	opts.KeaCert = "client.pem"
+	if _, err := opts.keaClient(server.URL); err == nil {
+		t.Errorf("the private key of the client certificate should be required")
+	}
+	opts.KeaCert = ""
+	client, err := opts.keaClient(server.URL)
+	if err != nil {
+		t.Fatalf("cannot create client: %v", err)
+	}
+	addresses, err := client.GetLeases(opts.KeaLeases, now)
+	if err != nil {
+		t.Fatalf("cannot get leases: %v", err)
+	}
+	if strings.Join(addresses, ",") != "10.0.0.10" {
+		t.Errorf("only the assigned leases that have not expired should be included: %v", addresses)
+	}
+
+	client.Password = "wrong"
+	if _, err := client.GetLeases("ipv4", now); err == nil {
+		t.Errorf("the authentication should fail")
+	}
+}
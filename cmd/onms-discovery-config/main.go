@@ -0,0 +1,2437 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/events"
+	"github.com/agalue/onms-discovery-config/pkg/ingestpb"
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+	"google.golang.org/grpc"
+)
+
+var addressWhiteList = make(map[string]string) // Temporary map to avoid duplicates (and track the source of each address)
+var addressBlackList = make(map[string]bool)   // Temporary map to facilitate excluding addresses
+var duplicatePolicy = DuplicateWarn            // How to handle addresses that were already included
+var sourcePrecedence = SourcePrecedence{}      // Which source wins when an address has conflicting metadata
+var nameFilter *NameFilter                     // Optional exclusion of addresses based on their names
+var lookupThrottle *Throttle                   // Limits shared by all the external lookups
+var scannerBufferSize = 16 * 1024 * 1024       // Maximum length of a line from the input files
+var prefixLimit = PrefixLimit{}                // Sanity check for overly broad include CIDRs
+var summary = NewSummary()                     // Statistics about the processed sources
+var stagingState *StagingState                 // Runs in which each address was seen, when 'staging-state' is provided
+
+// Default configuration for Discoverd
+var baseConfig = &discovery.DiscoveryConfiguration{
+	InitialSleepTime: 30000,
+	RestartSleepTime: 86400000,
+	Retries:          1,
+	Timeout:          2000,
+	PacketsPerSecond: 10, // Rate limit how many ICMP requests are going out when pinging (large) ranges
+	Definitions: []discovery.Definition{
+		{
+			Detectors: []discovery.Detector{
+				{
+					Name:  "ReverseDNS",
+					Class: "org.opennms.netmgt.provision.detector.rdns.ReverseDNSLookupDetector",
+				},
+				{
+					Name:  "SNMP",
+					Class: "org.opennms.netmgt.provision.detector.snmp.SnmpDetector",
+					Parameters: []discovery.Parameter{
+						{
+							Key:   "useSnmpProfiles",
+							Value: "true",
+						},
+					},
+				},
+			},
+		},
+	},
+}
+
+// Options holds the command line arguments shared by all the commands
+type Options struct {
+	DryRun             bool
+	Force              bool
+	MergeManual        bool
+	Impact             bool
+	RemovalReport      bool
+	RemovalWebhook     string
+	RestURL            string
+	RestUser           string
+	RestPassword       string
+	RestToken          string
+	OnmsVersion        string
+	MaxScopeChange     float64
+	MaxAdditions       int
+	AdditionBatch      int
+	ScopeChangeUEI     string
+	ScopeChangeWebhook string
+	SMTPServer         string
+	SMTPUser           string
+	SMTPPassword       string
+	EmailFrom          string
+	EmailTo            string
+	EmailAlways        bool
+	RestPush           bool
+	Optimize           bool
+	SubtractExcludes   bool
+	MergeExisting      bool
+	NotifyRetries      int
+	NotifyDelay        time.Duration
+	NoRollback         bool
+	KarafAddress       string
+	KarafUser          string
+	KarafPassword      string
+	KarafHostKey       string
+	KarafInsecure      bool
+	KarafCommand       string
+	OnmsPort           int
+	OnmsHost           string
+	OnmsTLS            bool
+	OnmsCert           string
+	OnmsKey            string
+	OnmsCA             string
+	OnmsTimeout        time.Duration
+	OnmsHome           string
+	ConfigFile         string
+	IncludeCIDR        string
+	ExcludeCIDR        string
+	IncludeList        string
+	ExcludeList        string
+	ExcludeOutages     bool
+	ExcludeNodes       bool
+	ExcludeSources     string
+	StrictRanges       bool
+	MaxPrefixV4        int
+	MaxPrefixV6        int
+	AllowBroadCIDR     bool
+	IncludeDNS         string
+	IncludeNNMiHex     string
+	IncludeDHCP        string
+	DHCPMinAge         time.Duration
+	IncludeCSV         string
+	IncludeIngest      string
+	IncludeWebhooks    string
+	IncludeNmap        string
+	NmapPorts          string
+	IncludeKea         string
+	KeaLeases          string
+	KeaUser            string
+	KeaPassword        string
+	KeaCert            string
+	KeaKey             string
+	KeaCA              string
+	SiteCatalog        string
+	IncludeNetBox      string
+	NetBoxToken        string
+	NetBoxFilter       string
+	IncludeAWS         string
+	AWSProfile         string
+	AWSRegion          string
+	AWSTagFilter       string
+	IncludeAzure       string
+	AzureTenant        string
+	AzureClientID      string
+	AzureSecret        string
+	AzureSubscriptions string
+	AzureResourceGroup string
+	AzureTagFilter     string
+	IncludeGCP         string
+	GCPProject         string
+	GCPCredentials     string
+	GCPLabelFilter     string
+	IncludeK8s         string
+	K8sConfig          string
+	K8sContext         string
+	K8sSelector        string
+	IncludeVSphere     string
+	VSphereUser        string
+	VSphereSecret      string
+	VSphereDatacenters string
+	VSphereClusters    string
+	VSphereFolders     string
+	VSphereResources   string
+	VSphereInsecure    bool
+	DNSLocations       string
+	IncludeAXFR        StringList
+	AXFRKey            string
+	FailureUEI         string
+	FailureSev         string
+	HeartbeatUEI       string
+	Duplicates         string
+	Precedence         string
+	SummaryFile        string
+	RejectsFile        string
+	HistoryFile        string
+	OutputFormat       string
+	DetectorsFile      string
+	OutputFile         string
+	ArtifactFile       string
+	SigningKey         string
+	BundleFile         string
+	NamePatterns       StringList
+	Instances          StringList
+	Tenants            StringList
+	Definitions        StringList
+	IncludeURLs        StringList
+	URLUser            string
+	URLPassword        string
+	ProbeURLs          bool
+	SplitFamilies      bool
+	SortDefinitions    bool
+	IPv6Retries        int
+	IPv6Timeout        int
+	IPv6Detectors      string
+	StagingFile        string
+	StagingRuns        int
+	StagingSource      string
+	StagingDetectors   string
+	LookupWorkers      int
+	LookupQPS          float64
+	LookupJitter       time.Duration
+	MaxLineSize        int
+	StreamLists        bool
+	CacheFile          string
+	CacheTTL           time.Duration
+	RefreshCache       bool
+	RecordAPIs         string
+	ReplayAPIs         string
+	Timezone           string
+	Blackouts          StringList
+	Variants           StringList
+	RequisitionDir     string
+	RequisitionImport  bool
+	RequisitionSource  string
+	RequisitionContext string
+	Change             string
+	ServiceNowURL      string
+	ServiceNowUser     string
+	ServiceNowPassword string
+	ServiceNowStates   string
+	provisioned        []string // Addresses of the existing nodes, when 'exclude-existing-nodes' is enabled
+	managed            []string // Addresses of the nodes from 'exc-foreign-sources'
+	location           *time.Location
+	blackouts          []*Blackout
+	variants           []*Variant
+}
+
+// GetInstances returns the OpenNMS instances to update; by default, the one from -onms-home and -onms-port
+func (o *Options) GetInstances() ([]*Instance, error) {
+	if len(o.Instances) == 0 {
+		return []*Instance{{Name: o.OnmsHome, Home: o.OnmsHome, Host: o.OnmsHost, Port: o.OnmsPort}}, nil
+	}
+	instances := make([]*Instance, 0, len(o.Instances))
+	for _, spec := range o.Instances {
+		instance, err := ParseInstance(spec)
+		if err != nil {
+			return nil, err
+		}
+		if instance.Host == "" {
+			instance.Host = o.OnmsHost
+		}
+		instances = append(instances, instance)
+	}
+	return instances, nil
+}
+
+func (o *Options) Register(fs *flag.FlagSet) {
+	fs.StringVar(&o.ConfigFile, "config", "", "Path to a YAML or JSON file with the options, using their names as keys; the command line overrides the file")
+	fs.StringVar(&o.IncludeCIDR, "inc-cidr", "", "Path to a file with a list of CIDRs to include in the configuration; accepts optional attributes, e.x. file.txt:retries=2,timeout=5000")
+	fs.StringVar(&o.ExcludeCIDR, "exc-cidr", "", "Path to a file with a list of CIDRs to exclude in the configuration")
+	fs.StringVar(&o.IncludeList, "inc-list", "", "Path to a file with a list of IP addresses to include in the configuration (excluding 'exc-list')")
+	fs.StringVar(&o.ExcludeList, "exc-list", "", "Path to a file with a list of IP addresses to exclude; affects 'inc-list', 'inc-dns' and 'inc-hexnnmi'")
+	fs.BoolVar(&o.ExcludeNodes, "exclude-existing-nodes", false, "Whether or not to blacklist the addresses of the nodes already provisioned in OpenNMS; affects the same sources as 'exc-list' and requires 'rest-url'")
+	fs.StringVar(&o.ExcludeSources, "exc-foreign-sources", "", "Comma separated list of foreign sources (requisitions) whose nodes should not be rediscovered; their addresses are blacklisted and excluded from the include ranges, and it requires 'rest-url'")
+	fs.BoolVar(&o.ExcludeOutages, "exc-outages", false, "Whether or not to exclude the addresses under active scheduled outages in OpenNMS; requires 'rest-url'")
+	fs.StringVar(&o.IncludeDNS, "inc-dns", "", "Path to a file with a list of IP addresses to include in the configuration; e.x. ipv4addr=10.0.0.1")
+	fs.StringVar(&o.DNSLocations, "inc-dns-locations", "", "Path to a file that maps DNS views or zones from 'inc-dns' (and the zones from 'inc-axfr') to locations; e.x. branch-view=Branch")
+	fs.Var(&o.IncludeAXFR, "inc-axfr", "DNS zone to transfer (AXFR) from a server, as zone@server[:port], to include the IP addresses of its A and AAAA records; can be specified multiple times and accepts optional attributes")
+	fs.StringVar(&o.AXFRKey, "axfr-tsig-key", "", "TSIG key to sign the zone transfers with HMAC-SHA256, as name:secret (base64)")
+	fs.StringVar(&o.IncludeNNMiHex, "inc-hexnnmi", "", "Path to a file with a list of IP addresses in Hex format from NNMi")
+	fs.StringVar(&o.IncludeDHCP, "inc-dhcp-leases", "", "Path to an ISC DHCP lease file (dhcpd.leases) to include the IP addresses of the active leases")
+	fs.DurationVar(&o.DHCPMinAge, "dhcp-min-lease-age", 0, "Minimum time since the start of a lease from 'inc-dhcp-leases' to include its IP address, to skip transient clients; e.x. 24h")
+	fs.StringVar(&o.IncludeCSV, "inc-csv", "", "Path to a CSV file with one ip_or_cidr,location,foreign-source,retries,timeout entry per line; each entry is added to the definition of its location and foreign-source, which is created when needed")
+	fs.StringVar(&o.IncludeIngest, "inc-ingest", "", "Path to the file with the candidates received by the gRPC service of the daemon (JSON lines); each one is added like the entries of 'inc-csv', with its name and meta-data for the requisitions")
+	fs.StringVar(&o.IncludeWebhooks, "inc-webhooks", "", "Path to the file with the addresses and prefixes announced by the webhooks received by the daemon (JSON lines); each one is added like the entries of 'inc-csv'")
+	fs.StringVar(&o.IncludeNmap, "inc-nmap", "", "Path to the XML output of an Nmap scan (nmap -oX) to include the IP addresses of the hosts that are up")
+	fs.StringVar(&o.NmapPorts, "nmap-ports", "", "Comma-separated list of ports to include only the hosts from 'inc-nmap' with any of them open, with optional protocol; e.x. 22,161/udp")
+	fs.StringVar(&o.IncludeKea, "inc-kea", "", "URL of the Kea Control Agent to include the IP addresses of the active leases; accepts optional attributes")
+	fs.StringVar(&o.KeaLeases, "kea-leases", "ipv4", "Kea leases to include: 'ipv4' for the DHCPv4 leases, 'ipv6' for the DHCPv6 leases, or 'all'")
+	fs.StringVar(&o.KeaUser, "kea-user", "", "Username to access the Kea Control Agent (optional)")
+	fs.StringVar(&o.KeaPassword, "kea-password", "", "Password to access the Kea Control Agent")
+	fs.StringVar(&o.KeaCert, "kea-cert", "", "Path to the TLS client certificate (PEM) to access the Kea Control Agent")
+	fs.StringVar(&o.KeaKey, "kea-key", "", "Path to the private key (PEM) of the TLS client certificate for the Kea Control Agent")
+	fs.StringVar(&o.KeaCA, "kea-ca", "", "Path to the CA (PEM) to verify the TLS certificate of the Kea Control Agent")
+	fs.StringVar(&o.IncludeNetBox, "inc-netbox", "", "URL of NetBox to include the prefixes and IP addresses that match 'netbox-filter'; accepts optional attributes")
+	fs.StringVar(&o.NetBoxToken, "netbox-token", "", "API token to access NetBox")
+	fs.StringVar(&o.NetBoxFilter, "netbox-filter", "status=active", "Filter for the NetBox prefixes and IP addresses as a query string; e.x. tenant=acme&tag=discovery&status=active")
+	fs.StringVar(&o.IncludeAWS, "inc-aws", "", "AWS EC2 resources to include: 'instances' for the private IP addresses of the running instances, 'subnets' for the VPC subnets, or 'all'; accepts optional attributes")
+	fs.StringVar(&o.AWSProfile, "aws-profile", "", "Profile of the AWS shared credentials file; defaults to the AWS_* environment variables, or AWS_PROFILE")
+	fs.StringVar(&o.AWSRegion, "aws-region", "", "AWS region for 'inc-aws'; defaults to AWS_REGION")
+	fs.StringVar(&o.AWSTagFilter, "aws-tag-filter", "", "Tags of the AWS instances and subnets to include; e.x. discovery=true,env=prod|staging")
+	fs.StringVar(&o.IncludeAzure, "inc-azure", "", "Azure resources to include via Resource Graph: 'vms' for the private IP addresses of the VMs, 'subnets' for the VNet subnets, or 'all'; accepts optional attributes")
+	fs.StringVar(&o.AzureTenant, "azure-tenant", "", "Azure tenant ID of the service principal; defaults to AZURE_TENANT_ID")
+	fs.StringVar(&o.AzureClientID, "azure-client-id", "", "Azure client ID of the service principal; defaults to AZURE_CLIENT_ID")
+	fs.StringVar(&o.AzureSecret, "azure-client-secret", "", "Azure client secret of the service principal; defaults to AZURE_CLIENT_SECRET")
+	fs.StringVar(&o.AzureSubscriptions, "azure-subscriptions", "", "Comma separated list of Azure subscription IDs; defaults to all the subscriptions the service principal can access")
+	fs.StringVar(&o.AzureResourceGroup, "azure-resource-group", "", "Azure resource group of the VMs and virtual networks to include")
+	fs.StringVar(&o.AzureTagFilter, "azure-tag-filter", "", "Tags of the Azure VMs and virtual networks to include; e.x. discovery=true,env=prod|staging")
+	fs.StringVar(&o.IncludeGCP, "inc-gcp", "", "GCP Compute Engine resources to include: 'instances' for the internal IP addresses of the running instances, 'subnets' for the VPC subnetworks, or 'all'; accepts optional attributes")
+	fs.StringVar(&o.GCPProject, "gcp-project", "", "GCP project for 'inc-gcp'; defaults to GOOGLE_CLOUD_PROJECT, or the project of the service account")
+	fs.StringVar(&o.GCPCredentials, "gcp-credentials", "", "Path to the JSON key of a GCP service account; defaults to GOOGLE_APPLICATION_CREDENTIALS, or the metadata server when running on GCP")
+	fs.StringVar(&o.GCPLabelFilter, "gcp-label-filter", "", "Labels of the GCP instances to include; e.x. discovery=true,env=prod|staging")
+	fs.StringVar(&o.IncludeK8s, "inc-k8s", "", "Kubernetes resources to include: 'nodes' for the internal IP addresses of the nodes, 'services' for the IP addresses of the LoadBalancer and NodePort services, or 'all'; accepts optional attributes")
+	fs.StringVar(&o.K8sConfig, "k8s-kubeconfig", "", "Path to the kubeconfig for 'inc-k8s'; defaults to KUBECONFIG, the service account when running inside a cluster, or ~/.kube/config")
+	fs.StringVar(&o.K8sContext, "k8s-context", "", "Context of the kubeconfig; defaults to the current context")
+	fs.StringVar(&o.K8sSelector, "k8s-selector", "", "Label selector for the Kubernetes nodes and services; e.x. discovery=true,env in (prod,staging)")
+	fs.StringVar(&o.IncludeVSphere, "inc-vsphere", "", "URL of the VMware vCenter to include the guest IP addresses of the powered on VMs and the management addresses of the ESXi hosts; accepts optional attributes")
+	fs.StringVar(&o.VSphereUser, "vsphere-user", "", "Username to access vCenter")
+	fs.StringVar(&o.VSphereSecret, "vsphere-password", "", "Password to access vCenter")
+	fs.StringVar(&o.VSphereDatacenters, "vsphere-datacenter", "", "Comma separated list of the vSphere datacenters of the VMs and hosts to include")
+	fs.StringVar(&o.VSphereClusters, "vsphere-cluster", "", "Comma separated list of the vSphere clusters of the VMs and hosts to include")
+	fs.StringVar(&o.VSphereFolders, "vsphere-folder", "", "Comma separated list of the vSphere VM folders of the VMs to include")
+	fs.StringVar(&o.VSphereResources, "vsphere-resources", "all", "vSphere resources to include: 'vms' for the guest IP addresses reported by VMware Tools, 'hosts' for the management addresses of the ESXi hosts, or 'all'")
+	fs.BoolVar(&o.VSphereInsecure, "vsphere-insecure", false, "Whether or not to skip the verification of the TLS certificate of vCenter")
+	fs.StringVar(&o.SiteCatalog, "site-catalog", "", "Path to a file with the site catalog (site,cidr,location,foreign-source per line) to generate one definition per site")
+	fs.Var(&o.IncludeURLs, "inc-url", "URL with a list of IP addresses for OpenNMS to include via include-url; can be specified multiple times and accepts optional attributes")
+	fs.StringVar(&o.URLUser, "inc-url-user", "", "Username to embed into the HTTP(s) URLs from 'inc-url'")
+	fs.StringVar(&o.URLPassword, "inc-url-password", "", "Password to embed into the HTTP(s) URLs from 'inc-url'")
+	fs.BoolVar(&o.ProbeURLs, "inc-url-probe", false, "Whether or not to verify that the HTTP(s) URLs from 'inc-url' are reachable")
+	fs.Var(&o.NamePatterns, "exc-dns-pattern", "Regular expression to exclude addresses whose reverse DNS name matches it; can be specified multiple times")
+	fs.StringVar(&o.OnmsHome, "onms-home", defaultOnmsHome(runtime.GOOS), "Home path to OpenNMS; defaults to $"+OnmsHomeEnvVariable+" when defined")
+	fs.IntVar(&o.OnmsPort, "onms-port", 5817, "The TCP Port to send events to OpenNMS")
+	fs.StringVar(&o.OnmsHost, "onms-host", "127.0.0.1", "The address of the OpenNMS server to send events to, when 'onms-home' is a shared mount of a remote server")
+	fs.BoolVar(&o.OnmsTLS, "onms-tls", false, "Whether or not to send the events via TLS, e.x. when eventd is behind a TLS-terminating proxy")
+	fs.StringVar(&o.OnmsCert, "onms-cert", "", "Path to the TLS client certificate (PEM) to send the events; implies 'onms-tls'")
+	fs.StringVar(&o.OnmsKey, "onms-key", "", "Path to the private key (PEM) of the TLS client certificate to send the events")
+	fs.StringVar(&o.OnmsCA, "onms-ca", "", "Path to the CA (PEM) to verify the TLS certificate of eventd or its proxy; implies 'onms-tls'")
+	fs.DurationVar(&o.OnmsTimeout, "onms-timeout", events.DefaultTimeout, "Maximum time to connect and send the events to OpenNMS")
+	fs.IntVar(&o.LookupWorkers, "lookup-concurrency", 4, "Maximum number of concurrent external lookups (DNS, ReST APIs); 0 for unlimited")
+	fs.Float64Var(&o.LookupQPS, "lookup-qps", 50, "Maximum number of external lookups (DNS, ReST APIs) per second; 0 for unlimited")
+	fs.DurationVar(&o.LookupJitter, "lookup-jitter", 0, "Maximum random delay added to each external lookup; e.x. 50ms")
+	fs.IntVar(&o.MaxLineSize, "max-line-size", scannerBufferSize, "Maximum length in bytes of a line from the input files")
+	fs.BoolVar(&o.StreamLists, "stream-lists", false, "Whether or not to combine the addresses from 'inc-list' into ranges while reading them, to process lists with millions of addresses with bounded memory (consecutive addresses become include ranges); opt-in, as otherwise each address is tracked in memory")
+	fs.StringVar(&o.CacheFile, "cache-file", "", "Path to a file to cache the results of the external lookups and the decoded content of the NNMi and DNS files across runs (disabled by default)")
+	fs.DurationVar(&o.CacheTTL, "cache-ttl", 24*time.Hour, "How long the cached results of the external lookups are valid")
+	fs.BoolVar(&o.RefreshCache, "refresh-cache", false, "Ignore the cached results and perform all the external lookups again")
+	fs.StringVar(&o.RecordAPIs, "record-apis", "", "Path to a directory to save the raw responses from the API sources (OpenNMS, NetBox, AWS, Azure, GCP, Kubernetes, vSphere and Kea), without the credentials")
+	fs.StringVar(&o.ReplayAPIs, "replay-apis", "", "Path to a directory with the responses saved via 'record-apis', to use them instead of contacting the API sources")
+	fs.StringVar(&o.RestURL, "rest-url", "", "Base URL of the OpenNMS ReST API; e.x. http://localhost:8980/opennms")
+	fs.StringVar(&o.RestUser, "rest-user", "admin", "Username to access the OpenNMS ReST API")
+	fs.StringVar(&o.RestPassword, "rest-password", "admin", "Password to access the OpenNMS ReST API")
+	fs.StringVar(&o.RestToken, "rest-token", "", "Bearer token to access the OpenNMS ReST API, instead of the user and password")
+	fs.StringVar(&o.OnmsVersion, "onms-version", "", "Version of the target OpenNMS release (e.x. 23.0.4 or 2019.1.20), to adapt the configuration, the endpoints and the events to it; detected via 'rest-url' when not set")
+	fs.BoolVar(&o.RestPush, "rest-push", false, "Update the configuration and reload Discovery through the ReST API ('rest-url' required), falling back to the files when it fails and they are available")
+	fs.Var(&o.Instances, "instance", "OpenNMS instance to update instead of 'onms-home'; can be specified multiple times; e.x. name=east,home=/mnt/east/opennms,host=10.0.0.5,port=5817,locations=Paris;Berlin (the host defaults to 'onms-host')")
+	fs.Var(&o.Tenants, "tenant", "Tenant with its own definitions (via their 'tenant' attribute), exclusions, foreign-source prefix, and output file or OpenNMS instance, isolated from the others; can be specified multiple times; e.x. name=acme,prefix=ACME-,locations=Paris;Berlin,exc-cidr=/tmp/acme_exc.txt,output=/tmp/acme.xml,home=/mnt/acme/opennms,host=10.0.0.5,port=5817")
+	fs.StringVar(&o.DetectorsFile, "detectors", "", "Path to a YAML or JSON file with the detectors of the default definition and the additional ones, either explicit or based on the presets: "+strings.Join(DetectorPresets(), ", "))
+	fs.Var(&o.Definitions, "definition", "Additional definition with its own settings and input files; can be specified multiple times; e.x. name=paris,priority=10,location=Paris,foreign-source=Paris,retries=2,timeout=5000,detectors=DNS;SNMP,inc-cidr=/tmp/paris_cidrs.txt,inc-list=/tmp/paris_ips.txt")
+	fs.StringVar(&o.FailureUEI, "failure-uei", "", "When set, the UEI of the event to send to OpenNMS when the run fails")
+	fs.StringVar(&o.FailureSev, "failure-severity", "Major", "The severity of the failure event")
+	fs.StringVar(&o.HeartbeatUEI, "heartbeat-uei", "", "When set, the UEI of the event to send to OpenNMS after a successful run")
+
+	fs.IntVar(&baseConfig.InitialSleepTime, "disc-initial-sleep-time", baseConfig.InitialSleepTime, "Discoverd Initial Sleep/Pause Time after discovery starts up (in milliseconds)")
+	fs.IntVar(&baseConfig.RestartSleepTime, "disc-restart-sleep-time", baseConfig.RestartSleepTime, "Discoverd Restart Sleep/Pause Time between discovery passes (in milliseconds)")
+	fs.IntVar(&baseConfig.Retries, "disc-retries", baseConfig.Retries, "Discoverd Ping Retries")
+	fs.IntVar(&baseConfig.Timeout, "disc-timeout", baseConfig.Timeout, "Discoverd Ping Timeout")
+	fs.IntVar(&baseConfig.PacketsPerSecond, "disc-packets-per-second", baseConfig.PacketsPerSecond, "Discoverd Packets Per Second (rate limit how many ICMP requests are going out)")
+
+	fs.BoolVar(&o.StrictRanges, "strict-ranges", false, "Whether or not to reject ranges whose end comes before their beginning, instead of swapping the boundaries")
+	fs.IntVar(&o.MaxPrefixV4, "max-prefix-v4", 16, "Shortest IPv4 prefix length accepted for include CIDRs without 'allow-broad-cidr' (0 to disable)")
+	fs.IntVar(&o.MaxPrefixV6, "max-prefix-v6", 48, "Shortest IPv6 prefix length accepted for include CIDRs without 'allow-broad-cidr' (0 to disable)")
+	fs.BoolVar(&o.AllowBroadCIDR, "allow-broad-cidr", false, "Confirm that include CIDRs broader than 'max-prefix-v4' or 'max-prefix-v6' are intended")
+	fs.StringVar(&o.Duplicates, "duplicates", string(DuplicateWarn), "How to handle addresses already included by other sources or ranges: warn, skip or error")
+	fs.StringVar(&o.Precedence, "source-precedence", "", "Comma separated list of sources (inc-list, inc-dns, inc-hexnnmi) in order of precedence to resolve addresses with conflicting metadata (defaults to first-wins)")
+	fs.StringVar(&o.SummaryFile, "summary", "", "Path to a JSON file to save the statistics about the processed sources")
+	fs.StringVar(&o.ArtifactFile, "artifact", "", "Path to the signed artifact; generate saves it instead of updating OpenNMS, and apply deploys it")
+	fs.StringVar(&o.SigningKey, "signing-key", "", "Path to the private key to sign the artifact (see the keygen command)")
+	fs.StringVar(&o.BundleFile, "bundle", "", "Path to a tar.gz file to save the generated configuration, the summary, and the hashes of the inputs (plus the signed artifact when 'signing-key' is provided)")
+	fs.StringVar(&o.RejectsFile, "rejects", "", "Path to a CSV file to save the skipped addresses with their source and reason")
+	fs.StringVar(&o.OutputFormat, "output-format", discovery.DefaultFormat, "Format of the generated configuration to display and save in 'output': "+strings.Join(discovery.Formats(), ", "))
+	fs.StringVar(&o.OutputFile, "output", "", "Path to a file to save the generated configuration in 'output-format', for other tools to consume")
+	fs.StringVar(&o.RequisitionDir, "requisition-dir", "", "Directory to save a requisition per foreign source, with a node per specific and the meta.<key> attributes of its input as meta-data, instead of updating Discovery")
+	fs.BoolVar(&o.RequisitionImport, "requisition-import", false, "Whether or not to import the requisitions through 'rest-url' instead of updating Discovery")
+	fs.StringVar(&o.RequisitionSource, "requisition-source", "Discovery", "Foreign source of the requisition for the specifics without one")
+	fs.StringVar(&o.RequisitionContext, "requisition-meta-context", DefaultMetaDataContext, "Context of the node meta-data in the requisitions")
+	fs.StringVar(&o.HistoryFile, "history", "", "Path to a file to append the metrics of each run as a time series (JSON lines for .json files, CSV otherwise)")
+
+	fs.BoolVar(&o.SplitFamilies, "split-families", false, "Whether or not to move the IPv6 content into separate definitions")
+	fs.BoolVar(&o.SortDefinitions, "sort-definitions", false, "Whether or not to order the definitions with the same priority by name, location and foreign source, so the output is stable across runs (the unnamed definitions come first)")
+	fs.IntVar(&o.IPv6Retries, "ipv6-retries", 0, "Ping retries for the IPv6 definitions when 'split-families' is enabled (0 to inherit)")
+	fs.IntVar(&o.IPv6Timeout, "ipv6-timeout", 0, "Ping timeout for the IPv6 definitions when 'split-families' is enabled (0 to inherit)")
+	fs.StringVar(&o.IPv6Detectors, "ipv6-detectors", "", "Comma separated list of detector names to keep on the IPv6 definitions when 'split-families' is enabled (empty for all)")
+
+	fs.StringVar(&o.StagingFile, "staging-state", "", "Path to a JSON file to track the runs in which each address was seen, to place the new addresses in staging definitions before promoting them (a missing file promotes all the current addresses)")
+	fs.IntVar(&o.StagingRuns, "staging-runs", 3, "Number of runs a new address stays in the staging definition of its location when 'staging-state' is provided")
+	fs.StringVar(&o.StagingSource, "staging-foreign-source", "Staging", "Foreign source of the staging definitions")
+	fs.StringVar(&o.StagingDetectors, "staging-detectors", "", "Comma separated list of detector names to keep on the staging definitions (empty for none, so only ICMP is used)")
+
+	fs.IntVar(&o.MaxAdditions, "max-additions", 0, "When the generated configuration adds more than this number of addresses to the current one from 'onms-home', add only 'addition-batch' of them and defer the rest to the next runs (0 to disable)")
+	fs.IntVar(&o.AdditionBatch, "addition-batch", 0, "Number of new addresses to add per run when 'max-additions' is exceeded (0 for 'max-additions')")
+	fs.Float64Var(&o.MaxScopeChange, "max-scope-change", 0, "Warn when the number of addresses changes more than this percentage compared to the current configuration (0 to disable)")
+	fs.StringVar(&o.ScopeChangeUEI, "scope-change-uei", "", "UEI of the event to send to OpenNMS when the scope changes more than 'max-scope-change'")
+	fs.StringVar(&o.ScopeChangeWebhook, "scope-change-webhook", "", "URL to post the scope change as JSON when it exceeds 'max-scope-change'")
+
+	fs.StringVar(&o.SMTPServer, "smtp-server", "", "SMTP server as host:port to email the summary of each run and the changes to the configuration; STARTTLS is used when available")
+	fs.StringVar(&o.SMTPUser, "smtp-user", "", "Username to authenticate with the SMTP server (optional)")
+	fs.StringVar(&o.SMTPPassword, "smtp-password", "", "Password to authenticate with the SMTP server")
+	fs.StringVar(&o.EmailFrom, "email-from", "", "Sender of the summary email")
+	fs.StringVar(&o.EmailTo, "email-to", "", "Comma separated list of recipients of the summary email")
+	fs.BoolVar(&o.EmailAlways, "email-always", false, "Whether or not to email the summary even when the configuration has no changes")
+
+	fs.BoolVar(&o.DryRun, "dry-run", false, "Whether or not to update OpenNMS configuration")
+	fs.Var(&o.Blackouts, "blackout", "Window in which OpenNMS must not be updated, evaluated in 'timezone'; can be specified multiple times; e.x. mon-fri 08:00-18:00")
+	fs.Var(&o.Variants, "variant", "Configuration variant of the daemon, whose configuration file replaces 'config' while any of its windows is active, evaluated in 'timezone'; can be specified multiple times, and the first active one wins; e.x. name=weekend,window=sat;sun 00:00-24:00,config=/etc/weekend.yaml")
+	fs.StringVar(&o.Timezone, "timezone", "", "IANA time zone to evaluate the blackout windows, the windows of the variants, and the schedule of the daemon; e.x. America/New_York (defaults to the local time zone)")
+	fs.StringVar(&o.Change, "change", "", "ID of the change ticket of the run (e.x. CHG000123), recorded with the generation and added to the events sent to OpenNMS")
+	fs.StringVar(&o.ServiceNowURL, "servicenow-url", "", "URL of ServiceNow to validate the change ticket before updating OpenNMS; when set, the change ticket is required")
+	fs.StringVar(&o.ServiceNowUser, "servicenow-user", "", "Username to access ServiceNow")
+	fs.StringVar(&o.ServiceNowPassword, "servicenow-password", "", "Password to access ServiceNow")
+	fs.StringVar(&o.ServiceNowStates, "servicenow-states", "Scheduled,Implement", "Comma separated list of the states of the change ticket that permit updating OpenNMS")
+	fs.BoolVar(&o.Force, "force", false, "Whether or not to overwrite the configuration when it was modified outside of this tool since the last deployment")
+	fs.BoolVar(&o.MergeManual, "merge-manual", false, "Whether or not to merge the changes applied outside of this tool since the last deployment into the generated configuration")
+	fs.BoolVar(&o.Impact, "impact", false, "On dry-run, cross-reference the generated scope against the existing nodes via ReST ('rest-url' required)")
+	fs.BoolVar(&o.RemovalReport, "removal-report", false, "Report the existing nodes that fall inside the scope removed from the current configuration ('rest-url' required)")
+	fs.StringVar(&o.RemovalWebhook, "removal-webhook", "", "URL to post the removal report as JSON when there are nodes in the removed scope (ignored on dry-run)")
+	fs.IntVar(&o.NotifyRetries, "notify-retries", DefaultUpdatePolicy.Retries, "Number of additional attempts to send the events to OpenNMS, like the reload event after updating the configuration")
+	fs.DurationVar(&o.NotifyDelay, "notify-retry-delay", DefaultUpdatePolicy.Delay, "Time to wait before retrying to send the events to OpenNMS, doubled after each attempt")
+	fs.BoolVar(&o.NoRollback, "no-rollback", false, "Keep the updated configuration even when the reload event cannot be sent to OpenNMS (by default, the previous one is restored)")
+	fs.StringVar(&o.KarafAddress, "karaf-address", "", "Address (host:port) of the Karaf SSH shell to reload Discovery, instead of sending an event via TCP; e.x. 127.0.0.1:8101")
+	fs.StringVar(&o.KarafUser, "karaf-user", "admin", "User to access the Karaf SSH shell")
+	fs.StringVar(&o.KarafPassword, "karaf-password", "admin", "Password to access the Karaf SSH shell")
+	fs.StringVar(&o.KarafHostKey, "karaf-host-key", "", "SHA256 fingerprint of the host key of the Karaf SSH shell (required unless 'karaf-insecure' is set)")
+	fs.BoolVar(&o.KarafInsecure, "karaf-insecure", false, "Accept any host key of the Karaf SSH shell when 'karaf-host-key' is not provided")
+	fs.StringVar(&o.KarafCommand, "karaf-command", "opennms:reload-daemon discovery", "Command to reload Discovery via the Karaf SSH shell")
+	fs.BoolVar(&o.Optimize, "optimize", false, "Whether or not to optimize the configuration to reduce its size (this can be computationally expensive)")
+	fs.BoolVar(&o.SubtractExcludes, "subtract-excludes", false, "Whether or not to optimize the configuration and carve the exclude ranges out of the include ranges, removing them (implies 'optimize')")
+	fs.BoolVar(&o.MergeExisting, "merge-existing", false, "Whether or not to merge the generated entries into the current configuration from 'onms-home', preserving its content, instead of replacing it")
+}
+
+// Warning: ensure CIDRs and black-lists are loaded and processed before using this method
+func addSpecific(def *discovery.Definition, streamed *streamedSet, source string, ip string, attrs discovery.Attributes) error {
+	return addNamedSpecific(def, streamed, source, ip, "", attrs)
+}
+
+// addNamedSpecific is like addSpecific, for the inputs that know the forward name of the IP address (empty when unknown)
+func addNamedSpecific(def *discovery.Definition, streamed *streamedSet, source string, ip string, name string, attrs discovery.Attributes) error {
+	stats := summary.Source(source)
+	stats.Processed++
+	normalized, err := iprange.NormalizeIP(ip)
+	if err != nil { // Not an IP Address
+		log.Printf("ignore [%s]: '%s' is not a valid IP address", SkipInvalidIP, ip)
+		summary.Skip(source, ip, SkipInvalidIP)
+		return nil
+	}
+	ip = normalized
+	if _, ok := addressBlackList[ip]; ok {
+		log.Printf("ignore [%s]: IP %s is blacklisted", SkipBlacklisted, ip)
+		summary.Skip(source, ip, SkipBlacklisted)
+		return nil
+	}
+	if def.ExcludeRangesContain(ip) {
+		log.Printf("ignore [%s]: IP %s is part of exclude ranges", SkipInExcludeRange, ip)
+		summary.Skip(source, ip, SkipInExcludeRange)
+		return nil
+	}
+	if def.IncludeRangesContain(ip) {
+		summary.Skip(source, ip, SkipCoveredByRange)
+		return duplicatePolicy.Apply(fmt.Sprintf("[%s] IP %s from %s is part of include ranges", SkipCoveredByRange, ip, source))
+	}
+	if previous, ok := streamed.previousSource(ip); !ok {
+		if name, match := nameFilter.Match(ip, name); match {
+			log.Printf("ignore [%s]: IP %s is excluded by its name %s", SkipExcludedByName, ip, name)
+			summary.Skip(source, ip, SkipExcludedByName)
+			return nil
+		}
+		log.Printf("adding sepcific IP %s", ip)
+		def.AddSpecificWithAttributes(ip, attrs)
+		addressWhiteList[ip] = source
+		recordNode(ip, source, name)
+		stats.Added++
+	} else {
+		summary.Skip(source, ip, SkipDuplicate)
+		if existing := def.GetSpecific(ip); existing != nil && existing.Attributes() != attrs {
+			summary.AddConflict(source)
+			if sourcePrecedence.Prefers(source, previous) {
+				log.Printf("conflict: IP %s from %s overrides the metadata from %s", ip, source, previous)
+				existing.SetAttributes(attrs)
+				addressWhiteList[ip] = source
+				recordNode(ip, source, name)
+			} else {
+				log.Printf("conflict: IP %s from %s keeps the metadata from %s", ip, source, previous)
+			}
+		}
+		return duplicatePolicy.Apply(fmt.Sprintf("[%s] IP %s from %s already included from %s", SkipDuplicate, ip, source, previous))
+	}
+	return nil
+}
+
+func getScanner(fileName string) (*bufio.Scanner, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening file: %v", err)
+	}
+	return newScanner(decodeReader(file)), nil
+}
+
+// normalizeCIDR returns the canonical form of a CIDR from an input file, or the trimmed line when it is invalid
+func normalizeCIDR(line string) string {
+	if cidr, err := iprange.NormalizeCIDR(line); err == nil {
+		return cidr
+	}
+	return strings.TrimSpace(line)
+}
+
+// newScanner returns a line scanner able to handle lines of up to scannerBufferSize bytes
+func newScanner(r io.Reader) *bufio.Scanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), scannerBufferSize)
+	return s
+}
+
+// buildConfiguration processes all the input files and populates baseConfig
+func buildConfiguration(opts *Options) error {
+	if err := opts.DecryptSecrets(); err != nil {
+		return err
+	}
+	if err := opts.setupRecorder(); err != nil {
+		return err
+	}
+	policy, err := ParseDuplicatePolicy(opts.Duplicates)
+	if err != nil {
+		return err
+	}
+	duplicatePolicy = policy
+	discovery.DefaultRangeValidator = &discovery.RangeValidator{Strict: opts.StrictRanges, Logf: log.Printf}
+	prefixLimit = PrefixLimit{IPv4: opts.MaxPrefixV4, IPv6: opts.MaxPrefixV6, Allow: opts.AllowBroadCIDR}
+	precedence, err := ParseSourcePrecedence(opts.Precedence)
+	if err != nil {
+		return err
+	}
+	sourcePrecedence = precedence
+	if opts.MaxLineSize > 0 {
+		scannerBufferSize = opts.MaxLineSize
+	}
+	lookupThrottle = NewThrottle(opts.LookupWorkers, opts.LookupQPS, opts.LookupJitter)
+	var cache *LookupCache
+	if opts.CacheFile != "" {
+		if cache, err = LoadLookupCache(opts.CacheFile, opts.CacheTTL, opts.RefreshCache); err != nil {
+			return err
+		}
+	}
+	decodeCache = cache
+	if len(opts.NamePatterns) > 0 {
+		if nameFilter, err = NewNameFilter(opts.NamePatterns); err != nil {
+			return err
+		}
+		nameFilter.Cache = cache
+	}
+	if opts.ExcludeNodes {
+		if opts.RestURL == "" {
+			return fmt.Errorf("excluding the existing nodes requires 'rest-url'")
+		}
+		log.Printf("processing existing nodes from %s", opts.RestURL)
+		if opts.provisioned, err = opts.restClient().GetIPAddresses(); err != nil {
+			return fmt.Errorf("cannot get IP addresses from OpenNMS: %v", err)
+		}
+		log.Printf("excluding %d IP addresses from existing nodes", len(opts.provisioned))
+	}
+	if opts.ExcludeSources != "" {
+		if opts.RestURL == "" {
+			return fmt.Errorf("excluding foreign sources requires 'rest-url'")
+		}
+		log.Printf("processing the nodes of the foreign sources %s from %s", opts.ExcludeSources, opts.RestURL)
+		if opts.managed, err = opts.restClient().GetIPAddressesByForeignSource(splitNames(opts.ExcludeSources)); err != nil {
+			return fmt.Errorf("cannot get IP addresses from OpenNMS: %v", err)
+		}
+		log.Printf("excluding %d IP addresses from the nodes of %s", len(opts.managed), opts.ExcludeSources)
+	}
+	definitions, err := opts.GetDefinitions()
+	if err != nil {
+		return err
+	}
+	tenants, err := opts.GetTenants()
+	if err != nil {
+		return err
+	}
+	if len(tenants) > 0 {
+		if err := opts.checkTenants(tenants, definitions); err != nil {
+			return err
+		}
+	}
+	detectors := &DetectorsFile{}
+	if opts.DetectorsFile != "" {
+		log.Printf("processing Detectors %s", opts.DetectorsFile)
+		if detectors, err = LoadDetectorsFile(opts.DetectorsFile); err != nil {
+			return err
+		}
+		for name := range detectors.Definitions {
+			found := false
+			for _, spec := range definitions {
+				found = found || spec.Name == name
+			}
+			if !found {
+				return fmt.Errorf("the detectors file %s refers to an unknown definition %s", opts.DetectorsFile, name)
+			}
+		}
+		if d, _ := detectors.Get(""); d != nil {
+			baseConfig.Definitions[0].Detectors = d
+		}
+	}
+	streamed := newStreamedSet()
+	if err := buildDefinition(opts, &baseConfig.Definitions[0], streamed); err != nil {
+		return err
+	}
+	if len(tenants) > 0 && !baseConfig.Definitions[0].IsEmpty() {
+		return fmt.Errorf("the inputs outside of the definitions don't belong to any tenant")
+	}
+	globalBlackList := addressBlackList
+	globalWhiteList := addressWhiteList
+	tenantWhiteLists := make(map[string]map[string]string) // The duplicates are specific to each tenant
+	for _, spec := range definitions {
+		log.Printf("processing definition %s", spec.Name)
+		addressBlackList = make(map[string]bool) // The exclusions are specific to each definition
+		def := spec.NewDefinition(&baseConfig.Definitions[0])
+		if d, _ := detectors.Get(spec.Name); d != nil {
+			def.Detectors = d
+		}
+		tenant := tenants[spec.Tenant]
+		if tenant != nil {
+			if tenantWhiteLists[tenant.Name] == nil {
+				tenantWhiteLists[tenant.Name] = make(map[string]string)
+			}
+			addressWhiteList = tenantWhiteLists[tenant.Name]
+			if err := tenant.prepare(&def); err != nil {
+				return fmt.Errorf("definition %s: %v", spec.Name, err)
+			}
+		}
+		if err := buildDefinition(spec.Options(opts), &def, streamed); err != nil {
+			return fmt.Errorf("definition %s: %v", spec.Name, err)
+		}
+		if tenant != nil {
+			tenant.Apply(&def)
+		}
+		baseConfig.AddDefinition(def)
+	}
+	addressWhiteList = globalWhiteList
+	if opts.IncludeCSV != "" {
+		log.Printf("processing CSV %s", opts.IncludeCSV)
+		entries, err := LoadCSVEntries(opts.IncludeCSV)
+		if err != nil {
+			return err
+		}
+		addressBlackList = globalBlackList
+		if err := addCSVEntries(baseConfig, streamed, entries); err != nil {
+			return err
+		}
+	}
+	if opts.IncludeIngest != "" {
+		log.Printf("processing ingested candidates %s", opts.IncludeIngest)
+		candidates, err := LoadIngestCandidates(opts.IncludeIngest)
+		if err != nil {
+			return err
+		}
+		addressBlackList = globalBlackList
+		if err := addIngestCandidates(baseConfig, streamed, candidates); err != nil {
+			return err
+		}
+	}
+	if opts.IncludeWebhooks != "" {
+		log.Printf("processing webhook entries %s", opts.IncludeWebhooks)
+		entries, err := LoadWebhookEntries(opts.IncludeWebhooks)
+		if err != nil {
+			return err
+		}
+		addressBlackList = globalBlackList
+		if err := addWebhookEntries(baseConfig, streamed, entries); err != nil {
+			return err
+		}
+	}
+	if len(definitions) > 0 && baseConfig.Definitions[0].IsEmpty() {
+		baseConfig.Definitions = baseConfig.Definitions[1:] // Only the additional definitions have content
+	}
+
+	if opts.SiteCatalog != "" {
+		log.Printf("processing Site Catalog %s", opts.SiteCatalog)
+		catalog, err := LoadSiteCatalog(opts.SiteCatalog)
+		if err != nil {
+			return err
+		}
+		for _, site := range catalog {
+			for _, cidr := range site.CIDRs {
+				if err := prefixLimit.Check(cidr); err != nil {
+					return fmt.Errorf("site %s: %v", site.Name, err)
+				}
+			}
+		}
+		summary.Unclaimed = catalog.Apply(baseConfig)
+		for _, entry := range summary.Unclaimed {
+			log.Printf("warning: %s is not claimed by any site", entry)
+		}
+	}
+
+	if len(opts.managed) > 0 {
+		count := ExcludeAddresses(baseConfig, opts.managed)
+		log.Printf("%d IP addresses from the nodes of %s excluded from the include ranges", count, opts.ExcludeSources)
+	}
+
+	if opts.StagingFile != "" {
+		if opts.StagingRuns < 1 {
+			return fmt.Errorf("the number of staging runs must be positive")
+		}
+		if stagingState, err = LoadStagingState(opts.StagingFile); err != nil {
+			return err
+		}
+		settings := StagingSettings{ForeignSource: opts.StagingSource, Runs: opts.StagingRuns, Detectors: splitNames(opts.StagingDetectors)}
+		summary.Staged = stagingState.Stage(baseConfig, settings)
+		log.Printf("%d new addresses placed in the staging definitions for %s", len(summary.Staged), opts.StagingSource)
+	}
+
+	// Sort and optimize configuration by combining subsets of specifics into ranges when applicable
+
+	if opts.SubtractExcludes {
+		log.Printf("optimizing configuration and subtracting the exclude ranges (this can take a while, be patient)...")
+		baseConfig.MergeWithSubtraction()
+	} else if opts.Optimize {
+		log.Printf("optimizing configuration (this can take a while, be patient)...")
+		baseConfig.Merge()
+	} else {
+		log.Printf("sorting configuration...")
+		baseConfig.Sort()
+	}
+
+	if opts.SplitFamilies {
+		log.Printf("moving IPv6 content into separate definitions")
+		settings := discovery.FamilySettings{Retries: opts.IPv6Retries, Timeout: opts.IPv6Timeout}
+		for _, name := range strings.Split(opts.IPv6Detectors, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				settings.Detectors = append(settings.Detectors, name)
+			}
+		}
+		baseConfig.SplitFamilies(settings)
+	}
+
+	if opts.SortDefinitions {
+		log.Printf("sorting definitions by priority and name...")
+		baseConfig.SortDefinitions()
+	} else {
+		baseConfig.OrderDefinitions()
+	}
+
+	if len(tenants) > 0 {
+		if err := CheckTenantIsolation(baseConfig, tenants); err != nil {
+			return err
+		}
+	}
+
+	summary.SwappedRanges, summary.DroppedRanges = discovery.DefaultRangeValidator.Counts()
+	if err := cache.Save(); err != nil {
+		log.Printf("cannot save lookup cache: %v", err)
+	}
+	return nil
+}
+
+// includeCloudResources adds the subnets from a cloud provider as include ranges, and the addresses as specifics
+func includeCloudResources(def *discovery.Definition, streamed *streamedSet, source string, attrs discovery.Attributes, subnets, addresses []string) error {
+	for _, cidr := range subnets {
+		if err := prefixLimit.Check(cidr); err != nil {
+			return err
+		}
+		log.Printf("including CIDR %s", cidr)
+		def.IncludeCIDRWithAttributes(cidr, attrs)
+	}
+	for _, ip := range addresses {
+		if err := addSpecific(def, streamed, source, ip, attrs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildDefinition processes the input files of the options and populates the given definition
+func buildDefinition(opts *Options, def *discovery.Definition, streamed *streamedSet) error {
+	// Adding exclusions first to populate the local maps to optimize the inclusion of specifics
+
+	if opts.ExcludeCIDR != "" {
+		log.Printf("processing Exclude CIDR %s", opts.ExcludeCIDR)
+		s, err := getScanner(opts.ExcludeCIDR)
+		if err != nil {
+			return err
+		}
+		for s.Scan() {
+			cidr := normalizeCIDR(s.Text())
+			log.Printf("excluding CIDR %s", cidr)
+			def.ExcludeCIDR(cidr)
+		}
+		if err := s.Err(); err != nil {
+			return fmt.Errorf("cannot read %s: %v", opts.ExcludeCIDR, err)
+		}
+	}
+
+	if opts.ExcludeList != "" {
+		log.Printf("processing Exclude List %s", opts.ExcludeList)
+		s, err := getScanner(opts.ExcludeList)
+		if err != nil {
+			return err
+		}
+		for s.Scan() {
+			ip, err := iprange.NormalizeIP(s.Text())
+			if err != nil { // Not an IP Address
+				log.Printf("ignore: %s is not a valid IP address", strings.TrimSpace(s.Text()))
+			} else {
+				log.Printf("excluding IP %s", ip)
+				addressBlackList[ip] = true
+			}
+		}
+		if err := s.Err(); err != nil {
+			return fmt.Errorf("cannot read %s: %v", opts.ExcludeList, err)
+		}
+	}
+
+	for _, ip := range opts.provisioned {
+		addressBlackList[ip] = true
+	}
+	for _, ip := range opts.managed {
+		addressBlackList[ip] = true
+	}
+
+	if opts.ExcludeOutages {
+		if opts.RestURL == "" {
+			return fmt.Errorf("the scheduled outages require 'rest-url'")
+		}
+		log.Printf("processing Scheduled Outages from %s", opts.RestURL)
+		addresses, err := opts.restClient().GetAddressesUnderOutage(time.Now())
+		if err != nil {
+			return fmt.Errorf("cannot get scheduled outages: %v", err)
+		}
+		for _, ip := range addresses {
+			log.Printf("excluding IP %s under maintenance", ip)
+			def.AddExcludeRange(ip, ip)
+		}
+	}
+
+	// Processing sources for IP inclusion
+
+	if opts.IncludeCIDR != "" {
+		input, err := ParseInputFile(opts.IncludeCIDR)
+		if err != nil {
+			return err
+		}
+		log.Printf("processing Include CIDR %s", input.Path)
+		s, err := getScanner(input.Path)
+		if err != nil {
+			return err
+		}
+		for s.Scan() {
+			cidr := normalizeCIDR(s.Text())
+			if err := prefixLimit.Check(cidr); err != nil {
+				return err
+			}
+			log.Printf("including CIDR %s", cidr)
+			def.IncludeCIDRWithAttributes(cidr, input.Attributes)
+		}
+		if err := s.Err(); err != nil {
+			return fmt.Errorf("cannot read %s: %v", input.Path, err)
+		}
+	}
+
+	if opts.IncludeNetBox != "" {
+		input, err := ParseInputFile(opts.IncludeNetBox)
+		if err != nil {
+			return err
+		}
+		setInputMetaData("inc-netbox", input)
+		log.Printf("processing NetBox %s", input.Path)
+		client := NewNetBoxClient(input.Path, opts.NetBoxToken)
+		prefixes, err := client.GetPrefixes(opts.NetBoxFilter)
+		if err != nil {
+			return fmt.Errorf("cannot get prefixes from NetBox: %v", err)
+		}
+		for _, cidr := range prefixes {
+			if err := prefixLimit.Check(cidr); err != nil {
+				return err
+			}
+			log.Printf("including CIDR %s", cidr)
+			def.IncludeCIDRWithAttributes(cidr, input.Attributes)
+		}
+		addresses, err := client.GetIPAddresses(opts.NetBoxFilter)
+		if err != nil {
+			return fmt.Errorf("cannot get IP addresses from NetBox: %v", err)
+		}
+		for _, ip := range addresses {
+			if err := addSpecific(def, streamed, "inc-netbox", ip, input.Attributes); err != nil {
+				return err
+			}
+		}
+	}
+
+	if opts.IncludeAWS != "" {
+		input, err := ParseInputSelector(opts.IncludeAWS, awsResources...)
+		if err != nil {
+			return err
+		}
+		setInputMetaData("inc-aws", input)
+		client, filters, err := opts.awsClient(input.Path)
+		if err != nil {
+			return err
+		}
+		log.Printf("processing AWS %s from %s", input.Path, client.Region)
+		var subnets, addresses []string
+		if input.Path != "instances" {
+			if subnets, err = client.GetSubnets(filters); err != nil {
+				return fmt.Errorf("cannot get subnets from AWS: %v", err)
+			}
+		}
+		if input.Path != "subnets" {
+			if addresses, err = client.GetIPAddresses(filters); err != nil {
+				return fmt.Errorf("cannot get IP addresses from AWS: %v", err)
+			}
+		}
+		if err := includeCloudResources(def, streamed, "inc-aws", input.Attributes, subnets, addresses); err != nil {
+			return err
+		}
+	}
+
+	if opts.IncludeAzure != "" {
+		input, err := ParseInputSelector(opts.IncludeAzure, azureResources...)
+		if err != nil {
+			return err
+		}
+		setInputMetaData("inc-azure", input)
+		client, filter, err := opts.azureClient(input.Path)
+		if err != nil {
+			return err
+		}
+		log.Printf("processing Azure %s", input.Path)
+		var subnets, addresses []string
+		if input.Path != "vms" {
+			if subnets, err = client.GetSubnets(filter); err != nil {
+				return fmt.Errorf("cannot get subnets from Azure: %v", err)
+			}
+		}
+		if input.Path != "subnets" {
+			if addresses, err = client.GetIPAddresses(filter); err != nil {
+				return fmt.Errorf("cannot get IP addresses from Azure: %v", err)
+			}
+		}
+		if err := includeCloudResources(def, streamed, "inc-azure", input.Attributes, subnets, addresses); err != nil {
+			return err
+		}
+	}
+
+	if opts.IncludeGCP != "" {
+		input, err := ParseInputSelector(opts.IncludeGCP, gcpResources...)
+		if err != nil {
+			return err
+		}
+		setInputMetaData("inc-gcp", input)
+		client, labels, err := opts.gcpClient(input.Path)
+		if err != nil {
+			return err
+		}
+		log.Printf("processing GCP %s from %s", input.Path, client.Project)
+		var subnets, addresses []string
+		if input.Path != "instances" {
+			if subnets, err = client.GetSubnets(labels); err != nil {
+				return fmt.Errorf("cannot get subnets from GCP: %v", err)
+			}
+		}
+		if input.Path != "subnets" {
+			if addresses, err = client.GetIPAddresses(labels); err != nil {
+				return fmt.Errorf("cannot get IP addresses from GCP: %v", err)
+			}
+		}
+		if err := includeCloudResources(def, streamed, "inc-gcp", input.Attributes, subnets, addresses); err != nil {
+			return err
+		}
+	}
+
+	if opts.IncludeK8s != "" {
+		input, err := ParseInputSelector(opts.IncludeK8s, k8sResources...)
+		if err != nil {
+			return err
+		}
+		setInputMetaData("inc-k8s", input)
+		client, err := opts.k8sClient(input.Path)
+		if err != nil {
+			return err
+		}
+		log.Printf("processing Kubernetes %s from %s", input.Path, client.Server)
+		var addresses []string
+		if input.Path != "services" {
+			if addresses, err = client.GetNodeAddresses(opts.K8sSelector); err != nil {
+				return fmt.Errorf("cannot get nodes from Kubernetes: %v", err)
+			}
+		}
+		if input.Path != "nodes" {
+			services, err := client.GetServiceAddresses(opts.K8sSelector)
+			if err != nil {
+				return fmt.Errorf("cannot get services from Kubernetes: %v", err)
+			}
+			seen := make(map[string]bool) // The external IP addresses can be the ones of the nodes
+			for _, ip := range addresses {
+				seen[ip] = true
+			}
+			for _, ip := range services {
+				if !seen[ip] {
+					seen[ip] = true
+					addresses = append(addresses, ip)
+				}
+			}
+		}
+		if err := includeCloudResources(def, streamed, "inc-k8s", input.Attributes, nil, addresses); err != nil {
+			return err
+		}
+	}
+
+	if opts.IncludeVSphere != "" {
+		input, err := ParseInputFile(opts.IncludeVSphere)
+		if err != nil {
+			return err
+		}
+		setInputMetaData("inc-vsphere", input)
+		client, filter, err := opts.vsphereClient(input.Path)
+		if err != nil {
+			return err
+		}
+		defer client.Logout()
+		log.Printf("processing vSphere %s from %s", opts.VSphereResources, client.URL)
+		var addresses []string
+		if opts.VSphereResources != "hosts" {
+			if addresses, err = client.GetGuestAddresses(filter); err != nil {
+				return fmt.Errorf("cannot get VMs from vSphere: %v", err)
+			}
+		}
+		if opts.VSphereResources != "vms" {
+			hosts, err := client.GetHostAddresses(filter)
+			if err != nil {
+				return fmt.Errorf("cannot get hosts from vSphere: %v", err)
+			}
+			addresses = append(addresses, hosts...)
+		}
+		if err := includeCloudResources(def, streamed, "inc-vsphere", input.Attributes, nil, addresses); err != nil {
+			return err
+		}
+	}
+
+	if opts.IncludeList != "" {
+		input, err := ParseInputFile(opts.IncludeList)
+		if err != nil {
+			return err
+		}
+		setInputMetaData("inc-list", input)
+		log.Printf("processing Include List %s", input.Path)
+		if opts.StreamLists {
+			file, err := os.Open(input.Path)
+			if err != nil {
+				return fmt.Errorf("failed opening file: %v", err)
+			}
+			err = streamSpecifics(def, streamed, "inc-list", decodeReader(file), input.Attributes)
+			file.Close()
+			if err != nil {
+				return err
+			}
+		} else {
+			s, err := getScanner(input.Path)
+			if err != nil {
+				return err
+			}
+			for s.Scan() {
+				ip := strings.TrimSpace(s.Text())
+				if err := addSpecific(def, streamed, "inc-list", ip, input.Attributes); err != nil {
+					return err
+				}
+			}
+			if err := s.Err(); err != nil {
+				return fmt.Errorf("cannot read %s: %v", input.Path, err)
+			}
+		}
+	}
+
+	if opts.IncludeDNS != "" {
+		input, err := ParseInputFile(opts.IncludeDNS)
+		if err != nil {
+			return err
+		}
+		setInputMetaData("inc-dns", input)
+		log.Printf("processing DNS File %s", input.Path)
+		re := regexp.MustCompile(`ipv4addr: (\d+\.\d+\.\d+\.\d+)`)
+		var zones ZoneLocations
+		if opts.DNSLocations != "" {
+			if zones, err = LoadZoneLocations(opts.DNSLocations); err != nil {
+				return err
+			}
+		}
+		// Each match is decoded with its view, zone and name separated by tabs, so the locations are resolved with the current mapping
+		decode := func(line string) []string {
+			line = strings.TrimSpace(line)
+			if match := re.FindStringSubmatch(line); len(match) == 2 {
+				view, zone := zoneKeys(line)
+				return []string{match[1] + "\t" + view + "\t" + zone + "\t" + recordName(line)}
+			}
+			return nil
+		}
+		err = decodeFile("inc-dns", input.Path, decode, func(value string) error {
+			fields := strings.SplitN(value, "\t", 4)
+			for len(fields) < 4 { // Decoded by a previous version
+				fields = append(fields, "")
+			}
+			attrs := input.Attributes
+			if location, ok := zones.LookupKeys(fields[1], fields[2]); ok {
+				attrs.Location = location
+			}
+			return addNamedSpecific(def, streamed, "inc-dns", fields[0], fields[3], attrs)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(opts.IncludeAXFR) > 0 {
+		var key *TSIGKey
+		var zones ZoneLocations
+		var err error
+		if opts.AXFRKey != "" {
+			if key, err = ParseTSIGKey(opts.AXFRKey); err != nil {
+				return err
+			}
+		}
+		if opts.DNSLocations != "" {
+			if zones, err = LoadZoneLocations(opts.DNSLocations); err != nil {
+				return err
+			}
+		}
+		for _, spec := range opts.IncludeAXFR {
+			input, err := ParseInputTarget(spec, func(target string) error {
+				_, _, err := ParseAXFRTarget(target)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			setInputMetaData("inc-axfr", input)
+			zone, server, err := ParseAXFRTarget(input.Path)
+			if err != nil {
+				return err
+			}
+			log.Printf("processing DNS zone %s from %s", zone, server)
+			var addresses []string
+			err = lookupThrottle.Do(func() error {
+				var e error
+				addresses, e = ZoneTransfer(zone, server, key)
+				return e
+			})
+			if err != nil {
+				return fmt.Errorf("cannot transfer zone %s from %s: %v", zone, server, err)
+			}
+			attrs := input.Attributes
+			if location, ok := zones.LookupZone(zone); ok {
+				attrs.Location = location
+			}
+			for _, ip := range addresses {
+				if err := addSpecific(def, streamed, "inc-axfr", ip, attrs); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if opts.IncludeNNMiHex != "" {
+		input, err := ParseInputFile(opts.IncludeNNMiHex)
+		if err != nil {
+			return err
+		}
+		setInputMetaData("inc-hexnnmi", input)
+		log.Printf("processing NNMi Hex File %s", input.Path)
+		decode := func(line string) []string {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				return nil
+			}
+			ip, err := DecodeNNMiHex(line)
+			if err != nil {
+				log.Printf("%v", err)
+				ip = line // Reported as an invalid IP address
+			}
+			return []string{ip}
+		}
+		err = decodeFile("inc-hexnnmi", input.Path, decode, func(ip string) error {
+			return addSpecific(def, streamed, "inc-hexnnmi", ip, input.Attributes)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.IncludeDHCP != "" {
+		input, err := ParseInputFile(opts.IncludeDHCP)
+		if err != nil {
+			return err
+		}
+		setInputMetaData("inc-dhcp-leases", input)
+		log.Printf("processing DHCP leases %s", input.Path)
+		s, err := getScanner(input.Path)
+		if err != nil {
+			return err
+		}
+		leases, err := ParseDHCPLeases(s)
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %v", input.Path, err)
+		}
+		now := time.Now()
+		for _, lease := range leases {
+			if lease.Active(now, opts.DHCPMinAge) {
+				if err := addSpecific(def, streamed, "inc-dhcp-leases", lease.IP, input.Attributes); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if opts.IncludeNmap != "" {
+		input, err := ParseInputFile(opts.IncludeNmap)
+		if err != nil {
+			return err
+		}
+		setInputMetaData("inc-nmap", input)
+		ports, err := ParseNmapPorts(opts.NmapPorts)
+		if err != nil {
+			return err
+		}
+		log.Printf("processing Nmap scan %s", input.Path)
+		file, err := os.Open(input.Path)
+		if err != nil {
+			return fmt.Errorf("failed opening file: %v", err)
+		}
+		hosts, err := ParseNmapXML(decodeReader(file))
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %v", input.Path, err)
+		}
+		for _, host := range hosts {
+			if len(ports) > 0 && !host.HasOpenPort(ports) {
+				continue
+			}
+			for _, ip := range host.Addresses {
+				if err := addSpecific(def, streamed, "inc-nmap", ip, input.Attributes); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if opts.IncludeKea != "" {
+		input, err := ParseInputFile(opts.IncludeKea)
+		if err != nil {
+			return err
+		}
+		setInputMetaData("inc-kea", input)
+		client, err := opts.keaClient(input.Path)
+		if err != nil {
+			return err
+		}
+		log.Printf("processing Kea %s leases from %s", opts.KeaLeases, client.URL)
+		addresses, err := client.GetLeases(opts.KeaLeases, time.Now())
+		if err != nil {
+			return fmt.Errorf("cannot get leases from Kea: %v", err)
+		}
+		for _, ip := range addresses {
+			if err := addSpecific(def, streamed, "inc-kea", ip, input.Attributes); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, spec := range opts.IncludeURLs {
+		input, err := ParseInputFile(spec)
+		if err != nil {
+			return err
+		}
+		u, err := BuildIncludeURL(input.Path, opts.URLUser, opts.URLPassword)
+		if err != nil {
+			return err
+		}
+		if opts.ProbeURLs {
+			count, err := ProbeIncludeURL(u)
+			if err != nil {
+				return fmt.Errorf("include URL %s is not reachable: %v", input.Path, err)
+			}
+			log.Printf("include URL %s contains %d IP addresses", input.Path, count)
+		}
+		log.Printf("including URL %s", input.Path)
+		def.AddIncludeURLWithAttributes(u, input.Attributes)
+	}
+	return nil
+}
+
+// limitAdditions defers the new addresses beyond the batch size to the next runs, when there are too many of them
+func limitAdditions(opts *Options) {
+	current, err := loadConfiguration(discoveryConfigPath(opts.OnmsHome))
+	if errors.Is(err, os.ErrNotExist) {
+		current = nil // All the addresses are new
+	} else if err != nil {
+		log.Printf("cannot limit the additions as the current configuration is not available: %v", err)
+		return
+	}
+	batch := opts.AdditionBatch
+	if batch <= 0 {
+		batch = opts.MaxAdditions
+	}
+	added, deferred := baseConfig.LimitAdditions(current, opts.MaxAdditions, batch)
+	if deferred.Sign() > 0 {
+		log.Printf("warning: the configuration adds %s addresses, more than %d; %s of them are deferred to the next runs", added, opts.MaxAdditions, deferred)
+	}
+	summary.DeferredAddresses = deferred
+}
+
+// mergeExisting merges the generated configuration into the current one, reporting only the delta
+func mergeExisting(opts *Options) error {
+	current, err := loadConfiguration(discoveryConfigPath(opts.OnmsHome))
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("there is no current configuration to merge with")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot load the current configuration: %v", err)
+	}
+	merged, delta := discovery.MergeExisting(current, baseConfig)
+	for _, d := range delta {
+		log.Printf("%s", d)
+	}
+	log.Printf("%d new entries merged into the current configuration", len(delta))
+	summary.Delta = delta
+	baseConfig = merged
+	return nil
+}
+
+func usage(fs *flag.FlagSet) func() {
+	return func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: %s [command] [options]\n\n", os.Args[0])
+		fmt.Fprintf(out, "Commands:\n")
+		fmt.Fprintf(out, "  generate   Generate the configuration and update OpenNMS (default)\n")
+		fmt.Fprintf(out, "  coverage   Compare the generated configuration against an inventory\n")
+		fmt.Fprintf(out, "  lint       Check the generated configuration, or an existing one, against the lint rules; e.x. lint [options] [discovery-configuration.xml]\n")
+		fmt.Fprintf(out, "  analyze    Report the statistics and findings of any discovery configuration; e.x. analyze [options] discovery-configuration.xml\n")
+		fmt.Fprintf(out, "  simulate   Ping a random sample of the generated scope to estimate responders\n")
+		fmt.Fprintf(out, "  plan       Report how each Discovery pass is shared across the definitions, with suggested rates\n")
+		fmt.Fprintf(out, "  audit      Compare the live configuration of each OpenNMS instance against the last recorded generation\n")
+		fmt.Fprintf(out, "  encrypt    Encrypt a secret read from the standard input, to be used as the value of the credential options\n")
+		fmt.Fprintf(out, "  apply      Verify a signed artifact and deploy its configuration to OpenNMS\n")
+		fmt.Fprintf(out, "  keygen     Generate the key pair to sign and verify the artifacts\n")
+		fmt.Fprintf(out, "  iptool     IP math operations, like cidr-to-range or range-to-cidrs\n")
+		fmt.Fprintf(out, "  init       Create annotated starter files for a new deployment\n")
+		fmt.Fprintf(out, "  validate-pipeline  Validate a configuration file for '-config', or print its JSON Schema\n")
+		fmt.Fprintf(out, "  send-suspects  Send a newSuspect event to OpenNMS for each specific of the generated configuration\n")
+		fmt.Fprintf(out, "  soak-test  Send synthetic events to eventd to benchmark how OpenNMS handles them\n")
+		fmt.Fprintf(out, "  mock-onms  Run a mock OpenNMS with eventd and the ReST API used by the tool, recording what it receives\n")
+		fmt.Fprintf(out, "  daemon     Run generate on a schedule and when the active variant changes, skipping the blackout windows; e.x. daemon -schedule '0 22 * * *' -- [generate options]\n")
+		fmt.Fprintf(out, "  version    Display the version, the git commit, and the build date\n\n")
+		fmt.Fprintf(out, "Options:\n")
+		fs.PrintDefaults()
+	}
+}
+
+func runGenerate(args []string) {
+	opts := new(Options)
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	fs.Usage = usage(fs)
+	opts.Register(fs)
+	opts.Parse(fs, args)
+
+	serializer, err := discovery.GetSerializer(opts.OutputFormat)
+	if err != nil {
+		opts.Fail(err)
+	}
+	if err := opts.parseChangeWindows(); err != nil {
+		opts.Fail(err)
+	}
+	if err := detectTarget(opts); err != nil {
+		opts.Fail(err)
+	}
+
+	start := time.Now()
+	generation = NewGenerationInfo(start)
+	generation.Change = opts.Change
+	summary.Generation = generation
+	if opts.RejectsFile != "" {
+		if err := summary.OpenRejects(opts.RejectsFile); err != nil {
+			opts.Fail(err)
+		}
+	}
+	if err := buildConfiguration(opts); err != nil {
+		opts.Fail(err)
+	}
+	if opts.MaxAdditions > 0 {
+		limitAdditions(opts)
+	}
+	if opts.MergeExisting {
+		if opts.StagingFile != "" {
+			opts.Fail(errors.New("'staging-state' cannot be used with 'merge-existing', as the current staging definitions would be kept"))
+		}
+		if err := mergeExisting(opts); err != nil {
+			opts.Fail(err)
+		}
+	}
+
+	// Conditionally update OpenNMS (if necessary)
+
+	output, err := serializer.Serialize(baseConfig)
+	if err != nil {
+		opts.Fail(fmt.Errorf("cannot serialize configuration: %v", err))
+	}
+	log.Printf("generated configuration:\n%s", output)
+	if opts.OutputFile != "" {
+		if err := os.WriteFile(opts.OutputFile, output, 0644); err != nil {
+			opts.Fail(fmt.Errorf("cannot save configuration: %v", err))
+		}
+	}
+	if len(opts.Tenants) > 0 {
+		if err := saveTenantOutputs(opts, baseConfig, serializer); err != nil {
+			opts.Fail(err)
+		}
+	}
+	summary.EstimatedAddresses = baseConfig.GetTotalEstimatedAddresses()
+	summary.Classes = baseConfig.Classify()
+	if count := summary.Classes[iprange.ClassPublic]; count != nil && count.Sign() > 0 {
+		log.Printf("warning: the scope contains %s public IPv4 addresses", count.String())
+	}
+	summary.Families = baseConfig.FamilyStats()
+	for i, f := range summary.Families {
+		if f.SweepsIPv6() {
+			log.Printf("warning: definition %d (%s) sweeps %d IPv6 ranges with %s addresses; add the IPv6 addresses as specifics instead", i+1, f.Location, f.IPv6Ranges, f.IPv6Addresses)
+		}
+	}
+	log.Printf("the estimated number of IP addresses to check is about %d", summary.EstimatedAddresses)
+	log.Printf("summary:\n%s", summary.String())
+	if opts.SummaryFile != "" {
+		if err := summary.Save(opts.SummaryFile); err != nil {
+			log.Printf("cannot save summary: %v", err)
+		}
+	}
+	if err := summary.CloseRejects(); err != nil {
+		log.Printf("cannot save rejects: %v", err)
+	}
+	if stagingState != nil && !opts.DryRun {
+		if err := stagingState.Save(); err != nil {
+			log.Printf("cannot save staging state: %v", err)
+		}
+	}
+	if opts.HistoryFile != "" {
+		if err := AppendHistory(opts.HistoryFile, NewHistoryRecords(generation.Time, baseConfig, summary)); err != nil {
+			log.Printf("cannot save history: %v", err)
+		}
+	}
+	if opts.MaxScopeChange > 0 {
+		checkScopeChange(opts)
+	}
+	if opts.BundleFile != "" {
+		if err := saveBundle(opts); err != nil {
+			opts.Fail(err)
+		}
+		log.Printf("bundle saved at %s", opts.BundleFile)
+	}
+	if opts.DryRun && opts.Impact {
+		if opts.RestURL == "" {
+			opts.Fail(errors.New("the ReST URL is required for the impact analysis"))
+		}
+		log.Printf("analyzing impact against the nodes from %s", opts.RestURL)
+		existing, err := opts.restClient().GetIPAddresses()
+		if err != nil {
+			opts.Fail(fmt.Errorf("cannot get IP addresses from OpenNMS: %v", err))
+		}
+		current, err := loadConfiguration(discoveryConfigPath(opts.OnmsHome))
+		if err != nil {
+			log.Printf("the current configuration is not available: %v", err)
+		}
+		log.Printf("impact analysis:\n%s", Impact(baseConfig, existing, current).String())
+	}
+	if opts.RemovalReport {
+		reportRemovals(opts)
+	}
+	if opts.SMTPServer != "" {
+		emailSummary(opts)
+	}
+	if opts.RequisitionDir != "" || opts.RequisitionImport {
+		if err := deployRequisitions(opts); err != nil {
+			opts.Fail(err)
+		}
+		return
+	}
+	if opts.ArtifactFile != "" {
+		if err := saveArtifact(opts); err != nil {
+			opts.Fail(err)
+		}
+		return
+	}
+	if !opts.DryRun {
+		deployInstances(opts, start)
+	}
+}
+
+// deployInstances updates the configuration on every OpenNMS instance, and reports the outcome
+func deployInstances(opts *Options, start time.Time) {
+	if b := opts.activeBlackout(time.Now()); b != nil {
+		log.Printf("OpenNMS was not updated, as the blackout window '%s' is active in %s", b.Spec, opts.location)
+		return
+	}
+	err := deployConfiguration(opts, baseConfig)
+	if err != nil && !errors.Is(err, ErrNoChanges) {
+		opts.Fail(err)
+	}
+	opts.Heartbeat(time.Since(start))
+	if err != nil {
+		opts.Fail(err)
+	}
+}
+
+// deployConfiguration updates the given configuration on every OpenNMS instance, returning ErrNoChanges when none changed
+func deployConfiguration(opts *Options, cfg *discovery.DiscoveryConfiguration) error {
+	if err := opts.validateChange(time.Now()); err != nil {
+		return err
+	}
+	targets, err := opts.deployTargets(cfg)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 { // Only the output files of the tenants
+		log.Printf("none of the tenants has an OpenNMS instance to update")
+		return nil
+	}
+	changed := false
+	failures := make([]string, 0)
+	for _, target := range targets {
+		err := opts.deploy(target.cfg, target.instance)
+		switch {
+		case err == nil:
+			changed = true
+		case errors.Is(err, ErrNoChanges):
+			log.Printf("instance %s: %v", target.instance.Name, err)
+		default:
+			failures = append(failures, fmt.Sprintf("instance %s: %v", target.instance.Name, err))
+		}
+	}
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	if !changed {
+		return ErrNoChanges
+	}
+	return nil
+}
+
+// saveConfiguration writes the configuration to the output file in the output format
+func saveConfiguration(opts *Options, cfg *discovery.DiscoveryConfiguration) error {
+	serializer, err := discovery.GetSerializer(opts.OutputFormat)
+	if err != nil {
+		return err
+	}
+	output, err := serializer.Serialize(cfg)
+	if err != nil {
+		return fmt.Errorf("cannot serialize configuration: %v", err)
+	}
+	if err := os.WriteFile(opts.OutputFile, output, 0644); err != nil {
+		return fmt.Errorf("cannot save configuration: %v", err)
+	}
+	return nil
+}
+
+// signArtifact returns the generated configuration and the summary as a signed artifact
+func signArtifact(opts *Options) (*SignedArtifact, error) {
+	if opts.SigningKey == "" {
+		return nil, fmt.Errorf("the signing key is required to generate an artifact")
+	}
+	key, err := LoadPrivateKey(opts.SigningKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load signing key: %v", err)
+	}
+	signed, err := NewArtifact(baseConfig, summary).Sign(key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot sign artifact: %v", err)
+	}
+	return signed, nil
+}
+
+// saveArtifact saves the signed artifact, to be deployed via apply
+func saveArtifact(opts *Options) error {
+	signed, err := signArtifact(opts)
+	if err != nil {
+		return err
+	}
+	if err := signed.Save(opts.ArtifactFile); err != nil {
+		return fmt.Errorf("cannot save artifact: %v", err)
+	}
+	log.Printf("signed artifact saved at %s; OpenNMS was not updated", opts.ArtifactFile)
+	return nil
+}
+
+// reportRemovals reports the existing nodes in the scope removed from the current configuration
+func reportRemovals(opts *Options) {
+	if opts.RestURL == "" {
+		opts.Fail(errors.New("the ReST URL is required for the removal report"))
+	}
+	current, err := loadConfiguration(discoveryConfigPath(opts.OnmsHome))
+	if err != nil {
+		log.Printf("cannot generate the removal report as the current configuration is not available: %v", err)
+		return
+	}
+	interfaces, err := opts.restClient().GetIPInterfaces()
+	if err != nil {
+		opts.Fail(fmt.Errorf("cannot get IP interfaces from OpenNMS: %v", err))
+	}
+	report := Removed(baseConfig, current, interfaces)
+	log.Printf("there are %d interfaces from existing nodes in the removed scope", len(report.Interfaces))
+	for _, intf := range report.Interfaces {
+		log.Printf("removed from scope: IP %s from node %d", intf.IPAddress, intf.NodeID)
+	}
+	if opts.RemovalWebhook != "" && !opts.DryRun && len(report.Interfaces) > 0 {
+		if err := report.Send(opts.RemovalWebhook); err != nil {
+			log.Printf("cannot send removal report: %v", err)
+		}
+	}
+}
+
+func runApply(args []string) {
+	var verifyKey string
+	opts := new(Options)
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	fs.Usage = usage(fs)
+	opts.Register(fs)
+	fs.StringVar(&verifyKey, "verify-key", "", "Path to the public key to verify the signature of the artifact")
+	opts.Parse(fs, args)
+	if err := opts.parseChangeWindows(); err != nil {
+		opts.Fail(err)
+	}
+	if err := detectTarget(opts); err != nil {
+		opts.Fail(err)
+	}
+
+	start := time.Now()
+	if opts.ArtifactFile == "" || verifyKey == "" {
+		opts.Fail(errors.New("the artifact and the key to verify it are required for the apply command"))
+	}
+	key, err := LoadPublicKey(verifyKey)
+	if err != nil {
+		opts.Fail(fmt.Errorf("cannot load verification key: %v", err))
+	}
+	signed, err := LoadSignedArtifact(opts.ArtifactFile)
+	if err != nil {
+		opts.Fail(err)
+	}
+	artifact, err := signed.Verify(key)
+	if err != nil {
+		opts.Fail(fmt.Errorf("cannot verify artifact %s: %v", opts.ArtifactFile, err))
+	}
+	if baseConfig, err = artifact.Config(); err != nil {
+		opts.Fail(err)
+	}
+	log.Printf("applying artifact generated on %s at %s:\n%s", artifact.Host, artifact.Time, baseConfig.String())
+	if artifact.Summary != nil {
+		generation = artifact.Summary.Generation
+		log.Printf("summary:\n%s", artifact.Summary.String())
+	}
+	if opts.Change != "" {
+		generation.Change = opts.Change
+	}
+	if err := opts.DecryptSecrets(); err != nil {
+		opts.Fail(err)
+	}
+	if !opts.DryRun {
+		deployInstances(opts, start)
+	}
+}
+
+func runKeygen(args []string) {
+	var name string
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	fs.Usage = usage(fs)
+	fs.StringVar(&name, "name", "discovery", "Base name of the key files; the private key goes to name.key and the public key to name.pub")
+	fs.Parse(args)
+
+	if err := GenerateKeys(name); err != nil {
+		log.Fatalf("cannot generate keys: %v", err)
+	}
+	log.Printf("keys saved at %s.key and %s.pub", name, name)
+}
+
+func runPlan(args []string) {
+	var targets string
+	opts := new(Options)
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	fs.Usage = usage(fs)
+	opts.Register(fs)
+	fs.StringVar(&targets, "target-pass", "1h,6h,24h", "Comma separated list of target pass durations to suggest packets-per-second and chunk-size")
+	opts.Parse(fs, args)
+
+	durations := make([]time.Duration, 0)
+	for _, t := range strings.Split(targets, ",") {
+		if t = strings.TrimSpace(t); t == "" {
+			continue
+		}
+		d, err := time.ParseDuration(t)
+		if err != nil || d <= 0 {
+			opts.Fail(fmt.Errorf("invalid target pass duration %s", t))
+		}
+		durations = append(durations, d)
+	}
+	if err := buildConfiguration(opts); err != nil {
+		opts.Fail(err)
+	}
+	fmt.Println(Plan(baseConfig, durations).String())
+}
+
+func runCoverage(args []string) {
+	var inventory string
+	opts := new(Options)
+	fs := flag.NewFlagSet("coverage", flag.ExitOnError)
+	fs.Usage = usage(fs)
+	opts.Register(fs)
+	fs.StringVar(&inventory, "inventory", "", "Path to a file with the authoritative list of IP addresses expected to be discovered")
+	opts.Parse(fs, args)
+
+	if inventory == "" {
+		opts.Fail(errors.New("the inventory file is required for the coverage command"))
+	}
+	if err := buildConfiguration(opts); err != nil {
+		opts.Fail(err)
+	}
+
+	log.Printf("processing inventory %s", inventory)
+	addresses := make([]string, 0)
+	s, err := getScanner(inventory)
+	if err != nil {
+		opts.Fail(err)
+	}
+	for s.Scan() {
+		if ip := strings.TrimSpace(s.Text()); ip != "" {
+			addresses = append(addresses, ip)
+		}
+	}
+	if err := s.Err(); err != nil {
+		opts.Fail(fmt.Errorf("cannot read %s: %v", inventory, err))
+	}
+	report := baseConfig.Coverage(addresses)
+	fmt.Println(report.String())
+	if len(report.Uncovered) > 0 {
+		os.Exit(1)
+	}
+}
+
+func runLint(args []string) {
+	var disable, minSeverity, failOn string
+	var skipURLs, asJSON bool
+	opts := new(Options)
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	fs.Usage = usage(fs)
+	opts.Register(fs)
+	fs.StringVar(&disable, "disable", "", "Comma-separated list of lint rules to skip")
+	fs.StringVar(&minSeverity, "min-severity", string(discovery.SeverityInfo), "Minimum severity of the findings to report: info, warning or error")
+	fs.StringVar(&failOn, "fail-on", string(discovery.SeverityError), "Minimum severity of the findings that cause a non-zero exit status: info, warning or error")
+	fs.BoolVar(&skipURLs, "skip-urls", false, "Whether or not to skip the verification of the include URLs")
+	fs.BoolVar(&asJSON, "json", false, "Whether or not to print the findings as JSON")
+	opts.Parse(fs, args)
+
+	min, err := discovery.ParseSeverity(minSeverity)
+	if err != nil {
+		opts.Fail(err)
+	}
+	fail, err := discovery.ParseSeverity(failOn)
+	if err != nil {
+		opts.Fail(err)
+	}
+	linter := discovery.NewLinter(CheckIncludeURL)
+	if skipURLs {
+		linter.CheckURL = nil
+	}
+	if err := linter.Disable(splitNames(disable)); err != nil {
+		opts.Fail(err)
+	}
+	var cfg *discovery.DiscoveryConfiguration
+	if fs.NArg() == 1 { // An existing configuration; otherwise, the one generated from the options
+		if cfg, err = LoadAnyConfiguration(fs.Arg(0)); err != nil {
+			opts.Fail(err)
+		}
+	} else {
+		if err := buildConfiguration(opts); err != nil {
+			opts.Fail(err)
+		}
+		cfg = baseConfig
+	}
+	findings := linter.Lint(cfg, min)
+	if asJSON {
+		data, _ := json.MarshalIndent(findings, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		for _, f := range findings {
+			fmt.Println(f.String())
+		}
+	}
+	for _, f := range findings {
+		if f.Severity.AtLeast(fail) {
+			os.Exit(1)
+		}
+	}
+}
+
+func runAnalyze(args []string) {
+	var maxRangeSize int64
+	var asJSON bool
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	fs.Usage = usage(fs)
+	fs.Int64Var(&maxRangeSize, "max-range-size", discovery.DefaultMaxRangeSize.Int64(), "Number of addresses above which an include range is reported as oversized")
+	fs.BoolVar(&asJSON, "json", false, "Whether or not to print the analysis as JSON")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		log.Fatal("the discovery configuration to analyze is required")
+	}
+	cfg, err := LoadAnyConfiguration(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	analysis := cfg.Analyze(big.NewInt(maxRangeSize))
+	if asJSON {
+		data, _ := json.MarshalIndent(analysis, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Print(analysis.String())
+}
+
+func runSimulate(args []string) {
+	var size, pps, maxEvents int
+	opts := new(Options)
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	fs.Usage = usage(fs)
+	opts.Register(fs)
+	fs.IntVar(&size, "sample-size", 100, "Number of random addresses to ping from the generated scope")
+	fs.IntVar(&pps, "sample-pps", 10, "Maximum number of addresses to ping per second")
+	fs.IntVar(&maxEvents, "max-events-per-pass", 0, "Warn when the expected number of newSuspect events per pass exceeds this value (0 to disable)")
+	opts.Parse(fs, args)
+
+	if err := buildConfiguration(opts); err != nil {
+		opts.Fail(err)
+	}
+
+	log.Printf("pinging %d random addresses at %d per second", size, pps)
+	report := Simulate(baseConfig, new(ICMPPinger), size, pps, rand.New(rand.NewSource(time.Now().UnixNano())))
+	fmt.Println(report.String())
+	if maxEvents > 0 && report.ExpectedResponders.Cmp(big.NewInt(int64(maxEvents))) > 0 {
+		log.Printf("warning: the expected number of newSuspect events per pass (%d) exceeds the limit of %d", report.ExpectedResponders, maxEvents)
+		os.Exit(2)
+	}
+}
+
+func runAudit(args []string) {
+	opts := new(Options)
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	fs.Usage = usage(fs)
+	opts.Register(fs)
+	opts.Parse(fs, args)
+
+	instances, err := opts.GetInstances()
+	if err != nil {
+		opts.Fail(err)
+	}
+	drifted := false
+	for _, instance := range instances {
+		diff, err := Audit(instance.Home)
+		if err != nil {
+			opts.Fail(fmt.Errorf("instance %s: %v", instance.Name, err))
+		}
+		if len(diff) == 0 {
+			log.Printf("instance %s: the discovery configuration matches the last recorded generation", instance.Name)
+			continue
+		}
+		drifted = true
+		log.Printf("instance %s: the discovery configuration has drifted from the last recorded generation", instance.Name)
+		for _, d := range diff {
+			fmt.Printf("%s: %s\n", instance.Name, d)
+		}
+	}
+	if drifted {
+		os.Exit(1)
+	}
+}
+
+func runEncrypt(args []string) {
+	fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	fs.Usage = usage(fs)
+	fs.Parse(args)
+
+	s := bufio.NewScanner(os.Stdin)
+	if !s.Scan() {
+		log.Fatal("the secret to encrypt is expected from the standard input")
+	}
+	value, err := EncryptValue(s.Text())
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(value)
+}
+
+func runInit(args []string) {
+	var dir string
+	var force bool
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	fs.Usage = usage(fs)
+	fs.StringVar(&dir, "dir", ".", "Directory to write the starter files")
+	fs.BoolVar(&force, "force", false, "Whether or not to overwrite the existing files")
+	fs.Parse(args)
+
+	answers, err := PromptInit(os.Stdin, os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+	written, err := WriteStarterFiles(dir, answers, force)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, name := range written {
+		log.Printf("created %s", name)
+	}
+	log.Printf("review the files, add your inputs, and then run: %s validate-pipeline %s", os.Args[0], filepath.Join(dir, "pipeline.yaml"))
+}
+
+func runValidatePipeline(args []string) {
+	var printSchema bool
+	fs := flag.NewFlagSet("validate-pipeline", flag.ExitOnError)
+	fs.Usage = usage(fs)
+	fs.BoolVar(&printSchema, "print-schema", false, "Print the JSON Schema of the configuration file instead of validating one")
+	fs.Parse(args)
+
+	if printSchema {
+		schema, err := ConfigSchema()
+		if err != nil {
+			log.Fatalf("cannot generate schema: %v", err)
+		}
+		fmt.Println(string(schema))
+		return
+	}
+	if fs.NArg() != 1 {
+		log.Fatal("the configuration file to validate is required")
+	}
+	errs := ValidatePipeline(fs.Arg(0))
+	for _, err := range errs {
+		log.Printf("error: %v", err)
+	}
+	if len(errs) > 0 {
+		os.Exit(1)
+	}
+	log.Printf("%s is valid", fs.Arg(0))
+}
+
+func runSendSuspects(args []string) {
+	var batchSize int
+	var delay time.Duration
+	opts := new(Options)
+	fs := flag.NewFlagSet("send-suspects", flag.ExitOnError)
+	fs.Usage = usage(fs)
+	opts.Register(fs)
+	fs.IntVar(&batchSize, "batch-size", 100, "Maximum number of events per connection to eventd (0 to send all of them at once)")
+	fs.DurationVar(&delay, "batch-delay", time.Second, "Time to wait between batches")
+	opts.Parse(fs, args)
+
+	if err := buildConfiguration(opts); err != nil {
+		opts.Fail(err)
+	}
+	if opts.DryRun {
+		log.Printf("%d newSuspect events would be sent to %s:%d", len(NewSuspects(baseConfig)), opts.OnmsHost, opts.OnmsPort)
+		return
+	}
+	sent, err := opts.SendNewSuspects(baseConfig, batchSize, delay)
+	if err != nil {
+		opts.Fail(fmt.Errorf("%v; %d events were delivered before the failure", err, sent))
+	}
+	log.Printf("%d newSuspect events sent to %s:%d", sent, opts.OnmsHost, opts.OnmsPort)
+}
+
+func runSoakTest(args []string) {
+	var target string
+	var port int
+	opts := new(SoakOptions)
+	fs := flag.NewFlagSet("soak-test", flag.ExitOnError)
+	fs.Usage = usage(fs)
+	fs.StringVar(&target, "target", "127.0.0.1", "The address of the OpenNMS server that runs eventd")
+	fs.IntVar(&port, "port", 5817, "The TCP Port to send events to OpenNMS")
+	fs.IntVar(&opts.Events, "events", 1000, "Total number of events to send")
+	fs.Float64Var(&opts.Rate, "rate", 100, "Maximum number of events per second (0 for no limit)")
+	fs.IntVar(&opts.BatchSize, "batch-size", 10, "Number of events to send per connection")
+	fs.IntVar(&opts.Workers, "workers", 1, "Number of concurrent connections")
+	fs.StringVar(&opts.UEI, "uei", soakTestUEI, "The UEI of the events; use uei.opennms.org/internal/discovery/newSuspect to include the load of provisioning")
+	fs.StringVar(&opts.Network, "network", "198.18.0.0/15", "CIDR or range for the interface of the events, cycling through its addresses")
+	fs.Parse(args)
+
+	log.Printf("sending %d events with UEI %s to %s:%d", opts.Events, opts.UEI, target, port)
+	report, err := Soak(opts, func(batch *events.Log) error {
+		return batch.Send(target, port)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(report.String())
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func runMockOpenNMS(args []string) {
+	var eventdPort, httpPort int
+	var dataFile, recordFile, user, password string
+	fs := flag.NewFlagSet("mock-onms", flag.ExitOnError)
+	fs.Usage = usage(fs)
+	fs.IntVar(&eventdPort, "eventd-port", 5817, "The TCP Port to receive events")
+	fs.IntVar(&httpPort, "http-port", 8980, "The TCP Port of the ReST API, served under /opennms")
+	fs.StringVar(&dataFile, "data", "", "Path to a JSON file with the interfaces, scheduled outages and configuration files to serve")
+	fs.StringVar(&recordFile, "record", "", "Path to a file to append what the mock receives as JSON lines")
+	fs.StringVar(&user, "user", "", "The user to access the ReST API; no authentication when empty")
+	fs.StringVar(&password, "password", "", "The password to access the ReST API")
+	fs.Parse(args)
+
+	data := MockData{}
+	if dataFile != "" {
+		var err error
+		if data, err = LoadMockData(dataFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	var record io.Writer
+	if recordFile != "" {
+		f, err := os.OpenFile(recordFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Fatalf("cannot open %s: %v", recordFile, err)
+		}
+		defer f.Close()
+		record = f
+	}
+	mock := NewMockOpenNMS(data, record)
+	mock.User, mock.Password = user, password
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", eventdPort))
+	if err != nil {
+		log.Fatalf("cannot listen for events: %v", err)
+	}
+	go func() {
+		log.Fatal(mock.ServeEventd(listener))
+	}()
+	log.Printf("listening for events on port %d, and for ReST requests on http://localhost:%d/opennms", eventdPort, httpPort)
+	log.Printf("what the mock receives is available at http://localhost:%d/mock/records", httpPort)
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", httpPort), mock))
+}
+
+func runDaemon(args []string) {
+	var expr, listen, grpcListen, ingestFile, webhookSecret, webhookFile string
+	var runAtStart, webhooks, incremental bool
+	var keepGenerations int
+	var quietPeriod time.Duration
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	fs.Usage = usage(fs)
+	fs.StringVar(&expr, "schedule", "0 * * * *", "Cron expression (minute, hour, day of month, month, day of week) for the runs, evaluated in the 'timezone' of the generate options")
+	fs.BoolVar(&runAtStart, "run-at-start", false, "Whether or not to run generate when the daemon starts, besides the schedule")
+	fs.StringVar(&listen, "listen", "", "Address to serve the JSON query API over the generated configurations (e.g., ':8990'); disabled when empty")
+	fs.IntVar(&keepGenerations, "keep-generations", DefaultKeepGenerations, "Number of generated configurations to keep in memory for the query API")
+	fs.StringVar(&grpcListen, "grpc-listen", "", "Address to serve the gRPC service to stream candidate addresses and request runs (e.g., ':8991'); disabled when empty")
+	fs.StringVar(&ingestFile, "ingest-file", "", "Path to the file to keep the candidates received via gRPC across restarts; a temporary file when empty")
+	fs.BoolVar(&webhooks, "webhooks", false, "Whether or not to receive webhooks from NetBox, phpIPAM, or custom tools announcing address and prefix changes, at /webhooks on 'listen'")
+	fs.StringVar(&webhookSecret, "webhook-secret", "", "Shared secret to verify the webhooks, as the NetBox signature or a bearer token; no verification when empty")
+	fs.StringVar(&webhookFile, "webhook-file", "", "Path to the file to keep the addresses and prefixes announced by the webhooks across restarts; a temporary file when empty")
+	fs.DurationVar(&quietPeriod, "quiet-period", DefaultQuietPeriod, "Time without webhooks to wait before running generate with the announced changes")
+	fs.BoolVar(&incremental, "incremental", false, "Whether or not to apply the changes from the webhooks and the gRPC service to the last generated configuration and deploy it, instead of running generate; the scheduled runs still rebuild it from scratch")
+	fs.Parse(args)
+
+	// The remaining arguments are the options of generate, which runs as a child process so every run starts from a clean state
+	generateArgs := append([]string{"generate"}, fs.Args()...)
+	opts := new(Options)
+	generateFlags := flag.NewFlagSet("generate", flag.ExitOnError)
+	opts.Register(generateFlags)
+	opts.Parse(generateFlags, fs.Args())
+	if err := opts.parseChangeWindows(); err != nil {
+		opts.Fail(err)
+	}
+	if incremental {
+		if !webhooks && grpcListen == "" {
+			opts.Fail(errors.New("'incremental' requires 'webhooks' or 'grpc-listen'"))
+		}
+		if opts.RequisitionDir != "" || opts.RequisitionImport || opts.ArtifactFile != "" {
+			opts.Fail(errors.New("'incremental' deploys the discovery configuration, so it cannot generate requisitions or artifacts"))
+		}
+		if len(opts.Tenants) > 0 {
+			opts.Fail(errors.New("'incremental' cannot be used with tenants, as the changes are not assigned to a tenant"))
+		}
+		if err := detectTarget(opts); err != nil {
+			opts.Fail(err)
+		}
+	}
+	schedule, err := ParseSchedule(expr)
+	if err != nil {
+		opts.Fail(err)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		opts.Fail(fmt.Errorf("cannot find the executable: %v", err))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	mux := http.NewServeMux()
+	changes := make(chan struct{}, 1) // Signals the changes from the webhooks
+	var receiver *WebhookReceiver
+	if webhooks {
+		if listen == "" {
+			opts.Fail(errors.New("'webhooks' requires 'listen'"))
+		}
+		if webhookFile == "" {
+			webhookFile = filepath.Join(os.TempDir(), fmt.Sprintf("onms-discovery-config-%d-webhooks.jsonl", os.Getpid()))
+			defer os.Remove(webhookFile)
+		}
+		generateArgs = append(generateArgs, "-inc-webhooks", webhookFile)
+		receiver, err = NewWebhookReceiver(webhookFile, func() {
+			select {
+			case changes <- struct{}{}:
+			default: // Already signaled
+			}
+		})
+		if err != nil {
+			opts.Fail(err)
+		}
+		receiver.Secret = webhookSecret
+		receiver.Incremental = incremental
+		mux.Handle("/webhooks/", receiver)
+		log.Printf("receiving webhooks with %d addresses and prefixes from %s", receiver.Count(), webhookFile)
+	}
+	var model *ModelServer
+	var engine *IncrementalModel
+	newEngine := func(cfg *discovery.DiscoveryConfiguration) {
+		engine = NewIncrementalModel(cfg)
+		if opts.StagingFile != "" {
+			engine.Staging = &StagingSettings{ForeignSource: opts.StagingSource, Runs: opts.StagingRuns, Detectors: splitNames(opts.StagingDetectors)}
+		}
+	}
+	modelFile := opts.OutputFile // The generated configuration, loaded after each run for the query API and the incremental engine
+	if listen != "" || incremental {
+		if modelFile == "" {
+			modelFile = filepath.Join(os.TempDir(), fmt.Sprintf("onms-discovery-config-%d.xml", os.Getpid()))
+			defer os.Remove(modelFile)
+			generateArgs = append(generateArgs, "-output", modelFile, "-output-format", discovery.DefaultFormat)
+		} else if !strings.EqualFold(opts.OutputFormat, discovery.DefaultFormat) {
+			opts.Fail(fmt.Errorf("'listen' and 'incremental' require the 'output' in the %s format", discovery.DefaultFormat))
+		}
+	}
+	if listen != "" {
+		model = NewModelServer(keepGenerations)
+		mux.Handle("/api/", model)
+		server := &http.Server{Addr: listen, Handler: mux}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				opts.Fail(fmt.Errorf("cannot serve the query API: %v", err))
+			}
+		}()
+		defer server.Shutdown(context.Background())
+		log.Printf("serving the query API on %s", listen)
+	}
+	if model != nil || incremental {
+		if cfg, err := LoadAnyConfiguration(modelFile); err == nil { // From a previous run
+			if model != nil {
+				model.Add(cfg, variantName(opts.activeVariant(time.Now())))
+			}
+			if incremental {
+				newEngine(cfg)
+			}
+		}
+	}
+	var service *IngestService
+	drain := func() []IPAMChange { // The changes received since the last run or update, for the incremental engine
+		list := make([]IPAMChange, 0)
+		if receiver != nil {
+			list = append(list, receiver.Drain()...)
+		}
+		if service != nil {
+			list = append(list, service.Drain()...)
+		}
+		return list
+	}
+	var applied *Variant // The variant of the last run that was not skipped
+	run := func(variant *Variant, dryRun bool) (int, error) {
+		if b := opts.activeBlackout(time.Now()); b != nil && !opts.DryRun && !dryRun {
+			log.Printf("skipping run, as the blackout window '%s' is active in %s", b.Spec, opts.location)
+			return 0, fmt.Errorf("the blackout window '%s' is active", b.Spec)
+		}
+		args := append([]string{}, generateArgs...)
+		if variant != nil { // The last config wins, and the options on the command line still take precedence over it
+			args = append(args, "-config", variant.ConfigFile)
+		}
+		if dryRun {
+			args = append(args, "-dry-run")
+		} else {
+			applied = variant
+			drain() // The run includes the changes received so far
+		}
+		log.Printf("running generate with the %s variant", variantName(variant))
+		cmd := exec.CommandContext(ctx, executable, args...)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		var saved time.Time // When the configuration was saved before the run
+		if info, err := os.Stat(modelFile); err == nil {
+			saved = info.ModTime()
+		}
+		err := cmd.Run()
+		if incremental && !dryRun { // The configuration is saved before updating OpenNMS, so it applies even when nothing changed
+			if info, statErr := os.Stat(modelFile); statErr == nil && !info.ModTime().Equal(saved) {
+				if cfg, loadErr := LoadAnyConfiguration(modelFile); loadErr == nil {
+					newEngine(cfg)
+				}
+			}
+		}
+		if err != nil {
+			log.Printf("run finished with %v", err)
+			return 0, fmt.Errorf("run finished with %v", err)
+		}
+		if model == nil {
+			return 0, nil
+		}
+		cfg, err := LoadAnyConfiguration(modelFile)
+		if err != nil {
+			log.Printf("cannot load the generated configuration: %v", err)
+			return 0, err
+		}
+		g := model.Add(cfg, variantName(variant))
+		log.Printf("generation %d available through the query API", g.ID)
+		return g.ID, nil
+	}
+
+	// update applies the changes received since the last run or update to the configuration, and deploys it with the
+	// options of the variant; it runs generate when there is no configuration yet, or when the variant changed
+	update := func(variant *Variant) (int, error) {
+		if engine == nil || variant != applied {
+			return run(variant, false)
+		}
+		if b := opts.activeBlackout(time.Now()); b != nil && !opts.DryRun {
+			log.Printf("skipping update, as the blackout window '%s' is active in %s", b.Spec, opts.location)
+			return 0, fmt.Errorf("the blackout window '%s' is active", b.Spec)
+		}
+		start := time.Now()
+		list := drain()
+		cfg, count := engine.Apply(list)
+		log.Printf("%d of %d changes applied to the configuration in %s", count, len(list), time.Since(start))
+		if count == 0 {
+			if model != nil && model.Generation(0) != nil {
+				return model.Generation(0).ID, nil
+			}
+			return 0, nil
+		}
+		deployOpts := opts
+		if variant != nil { // As in the runs, the options on the command line take precedence over the config of the variant
+			deployOpts = new(Options)
+			variantFlags := flag.NewFlagSet("generate", flag.ExitOnError)
+			deployOpts.Register(variantFlags)
+			deployOpts.Parse(variantFlags, append(append([]string{}, fs.Args()...), "-config", variant.ConfigFile))
+			if err := deployOpts.parseChangeWindows(); err != nil {
+				return 0, err
+			}
+		}
+		baseConfig, generation = cfg, NewGenerationInfo(start)
+		generation.Change = deployOpts.Change
+		if deployOpts.OutputFile != "" {
+			if err := saveConfiguration(deployOpts, cfg); err != nil {
+				log.Printf("%v", err)
+				return 0, err
+			}
+		}
+		if !deployOpts.DryRun {
+			err := deployConfiguration(deployOpts, cfg)
+			switch {
+			case err == nil:
+				deployOpts.Heartbeat(time.Since(start))
+			case errors.Is(err, ErrNoChanges):
+				log.Printf("%v", err)
+			default:
+				log.Printf("update finished with %v", err)
+				return 0, err
+			}
+		}
+		if model == nil {
+			return 0, nil
+		}
+		g := model.Add(cfg, variantName(variant))
+		log.Printf("generation %d available through the query API", g.ID)
+		return g.ID, nil
+	}
+
+	// The runs requested via gRPC are executed by the main loop, so they never overlap with the scheduled ones
+	type runRequest struct {
+		dryRun bool
+		done   chan error
+		id     int
+	}
+	requests := make(chan *runRequest)
+	if grpcListen != "" {
+		if ingestFile == "" {
+			ingestFile = filepath.Join(os.TempDir(), fmt.Sprintf("onms-discovery-config-%d.jsonl", os.Getpid()))
+			defer os.Remove(ingestFile)
+		}
+		generateArgs = append(generateArgs, "-inc-ingest", ingestFile)
+		service, err = NewIngestService(ingestFile, func(rctx context.Context, dryRun bool) (int, error) {
+			req := &runRequest{dryRun: dryRun, done: make(chan error, 1)}
+			select {
+			case requests <- req:
+			case <-rctx.Done():
+				return 0, rctx.Err()
+			case <-ctx.Done():
+				return 0, errors.New("the daemon is stopping")
+			}
+			err := <-req.done
+			return req.id, err
+		})
+		if err != nil {
+			opts.Fail(err)
+		}
+		service.Incremental = incremental
+		listener, err := net.Listen("tcp", grpcListen)
+		if err != nil {
+			opts.Fail(fmt.Errorf("cannot serve the gRPC service: %v", err))
+		}
+		server := grpc.NewServer()
+		ingestpb.RegisterIngestServer(server, service)
+		go server.Serve(listener)
+		defer server.Stop()
+		log.Printf("serving the gRPC service on %s with %d candidates from %s", grpcListen, service.Count(), ingestFile)
+	}
+	if runAtStart {
+		run(opts.activeVariant(time.Now()), false)
+	} else {
+		applied = opts.activeVariant(time.Now())
+	}
+	var switches <-chan time.Time // The windows of the variants are checked every minute, so a switch is pushed right away
+	if len(opts.variants) > 0 {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		switches = ticker.C
+	}
+	var next time.Time
+	var quiet <-chan time.Time
+	for {
+		if n := schedule.Next(time.Now().In(opts.location)); !n.Equal(next) {
+			if next = n; next.IsZero() {
+				opts.Fail(fmt.Errorf("the schedule '%s' never runs", expr))
+			}
+			log.Printf("next run at %s", next.Format(time.RFC3339))
+		}
+		select {
+		case <-ctx.Done():
+			log.Printf("stopping daemon")
+			return
+		case <-time.After(time.Until(next)):
+			run(opts.activeVariant(time.Now()), false)
+		case <-switches:
+			if v := opts.activeVariant(time.Now()); v != applied {
+				log.Printf("switching from the %s variant to the %s variant", variantName(applied), variantName(v))
+				run(v, false)
+			}
+		case <-changes: // The run waits for a quiet period, so a burst of webhooks triggers a single one
+			quiet = time.After(quietPeriod)
+		case <-quiet:
+			quiet = nil
+			if incremental {
+				log.Printf("applying the changes from the webhooks")
+				update(opts.activeVariant(time.Now()))
+			} else {
+				log.Printf("running generate with the changes from the webhooks")
+				run(opts.activeVariant(time.Now()), false)
+			}
+		case req := <-requests:
+			log.Printf("run requested via gRPC")
+			var id int
+			var err error
+			if incremental && !req.dryRun {
+				id, err = update(opts.activeVariant(time.Now()))
+			} else {
+				id, err = run(opts.activeVariant(time.Now()), req.dryRun)
+			}
+			req.id = id
+			req.done <- err
+		}
+	}
+}
+
+func runIPTool(args []string) {
+	output, err := IPTool(args)
+	if errors.Is(err, ErrNotContained) {
+		fmt.Println("false")
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(output)
+}
+
+func main() {
+	log.SetOutput(os.Stdout)
+	args := os.Args[1:]
+	command := "generate"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	switch command {
+	case "generate":
+		runGenerate(args)
+	case "coverage":
+		runCoverage(args)
+	case "analyze":
+		runAnalyze(args)
+	case "lint":
+		runLint(args)
+	case "simulate":
+		runSimulate(args)
+	case "plan":
+		runPlan(args)
+	case "audit":
+		runAudit(args)
+	case "encrypt":
+		runEncrypt(args)
+	case "apply":
+		runApply(args)
+	case "keygen":
+		runKeygen(args)
+	case "iptool":
+		runIPTool(args)
+	case "init":
+		runInit(args)
+	case "validate-pipeline":
+		runValidatePipeline(args)
+	case "send-suspects":
+		runSendSuspects(args)
+	case "soak-test":
+		runSoakTest(args)
+	case "mock-onms":
+		runMockOpenNMS(args)
+	case "daemon":
+		runDaemon(args)
+	case "version":
+		fmt.Printf("onms-discovery-config %s (commit %s, built %s)\n", version, commit, date)
+	default:
+		log.Fatalf("unknown command %s", command)
+	}
+}
@@ -0,0 +1,230 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Mock OpenNMS server with eventd and the ReST endpoints used by the tool, to test pipelines end-to-end without a full install
+
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/events"
+)
+
+// MockData is the content served by the mock, loaded from a JSON file
+type MockData struct {
+	Interfaces []IPInterface     `json:"interfaces,omitempty"`
+	Outages    []ScheduledOutage `json:"outages,omitempty"`
+	Files      map[string]string `json:"files,omitempty"` // Content of $OPENNMS_HOME/etc by file name
+	Info       *SystemInfo       `json:"info,omitempty"`  // Release reported by /rest/info, the latest Horizon by default
+}
+
+// MockRecord is something received by the mock
+type MockRecord struct {
+	Time    time.Time     `json:"time"`
+	Via     string        `json:"via"` // eventd or rest
+	Request string        `json:"request,omitempty"`
+	Event   *events.Event `json:"event,omitempty"`
+	File    string        `json:"file,omitempty"`
+	Content string        `json:"content,omitempty"`
+}
+
+type MockOpenNMS struct {
+	User     string // Credentials for the ReST API; no authentication when empty
+	Password string
+	data     MockData
+	records  []MockRecord
+	record   io.Writer // Optional destination of the records as JSON lines
+	mutex    sync.Mutex
+}
+
+// NewMockOpenNMS creates a mock serving the given data, writing what it receives into record when it is not nil
+func NewMockOpenNMS(data MockData, record io.Writer) *MockOpenNMS {
+	if data.Outages == nil {
+		data.Outages = make([]ScheduledOutage, 0)
+	}
+	if data.Files == nil {
+		data.Files = make(map[string]string)
+	}
+	if data.Info == nil {
+		data.Info = &SystemInfo{DisplayVersion: "33.0.0", Version: "33.0.0", PackageName: "opennms", PackageDescription: "OpenNMS"}
+	}
+	if _, ok := data.Files[discoveryConfigFile]; !ok { // Like a fresh install
+		data.Files[discoveryConfigFile] = new(discovery.DiscoveryConfiguration).String()
+	}
+	return &MockOpenNMS{data: data, record: record}
+}
+
+// LoadMockData reads the content to serve from a JSON file
+func LoadMockData(fileName string) (MockData, error) {
+	data := MockData{}
+	bytes, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return data, fmt.Errorf("cannot read %s: %v", fileName, err)
+	}
+	if err := json.Unmarshal(bytes, &data); err != nil {
+		return data, fmt.Errorf("invalid mock data %s: %v", fileName, err)
+	}
+	return data, nil
+}
+
+func (m *MockOpenNMS) add(r MockRecord) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	r.Time = time.Now()
+	m.records = append(m.records, r)
+	if r.Event != nil {
+		log.Printf("received event %s via %s", r.Event.UEI, r.Via)
+	} else {
+		log.Printf("received %s", r.Request)
+	}
+	if m.record != nil {
+		line, _ := json.Marshal(r)
+		m.record.Write(append(line, '\n'))
+	}
+}
+
+// Records returns what the mock has received so far
+func (m *MockOpenNMS) Records() []MockRecord {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]MockRecord{}, m.records...)
+}
+
+// Events returns the events received via eventd and the ReST API
+func (m *MockOpenNMS) Events() []events.Event {
+	list := make([]events.Event, 0)
+	for _, r := range m.Records() {
+		if r.Event != nil {
+			list = append(list, *r.Event)
+		}
+	}
+	return list
+}
+
+// File returns the current content of a file from $OPENNMS_HOME/etc
+func (m *MockOpenNMS) File(name string) (string, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	content, ok := m.data.Files[name]
+	return content, ok
+}
+
+// ServeEventd accepts connections with events logs like eventd, until the listener is closed
+func (m *MockOpenNMS) ServeEventd(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			data, err := ioutil.ReadAll(conn)
+			if err != nil {
+				log.Printf("cannot read events from %s: %v", conn.RemoteAddr(), err)
+				return
+			}
+			eventLog := new(events.Log)
+			if err := xml.Unmarshal(data, eventLog); err != nil {
+				log.Printf("invalid events from %s: %v", conn.RemoteAddr(), err)
+				return
+			}
+			for i := range eventLog.Events {
+				m.add(MockRecord{Via: "eventd", Event: &eventLog.Events[i]})
+			}
+		}()
+	}
+}
+
+// ServeHTTP implements the ReST endpoints under /opennms, and /mock/records to retrieve what was received
+func (m *MockOpenNMS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/mock/records" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(m.Records())
+		return
+	}
+	if m.User != "" {
+		if user, pass, ok := r.BasicAuth(); !ok || user != m.User || pass != m.Password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+	request := r.Method + " " + r.URL.RequestURI()
+	switch path := strings.TrimPrefix(r.URL.Path, "/opennms"); {
+	case path == "/api/v2/ipinterfaces" && r.Method == http.MethodGet:
+		m.add(MockRecord{Via: "rest", Request: request})
+		interfaces := filterInterfaces(m.data.Interfaces, r.URL.Query().Get("_s"))
+		if len(interfaces) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		count := len(interfaces)
+		json.NewEncoder(w).Encode(map[string]interface{}{"count": count, "totalCount": count, "offset": 0, "ipInterface": interfaces})
+	case path == "/rest/info" && r.Method == http.MethodGet:
+		m.add(MockRecord{Via: "rest", Request: request})
+		json.NewEncoder(w).Encode(m.data.Info)
+	case path == "/rest/sched-outages" && r.Method == http.MethodGet:
+		m.add(MockRecord{Via: "rest", Request: request})
+		json.NewEncoder(w).Encode(map[string]interface{}{"outage": m.data.Outages})
+	case path == "/rest/filesystem/contents" && r.Method == http.MethodGet:
+		m.add(MockRecord{Via: "rest", Request: request, File: r.URL.Query().Get("f")})
+		content, ok := m.File(r.URL.Query().Get("f"))
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(content))
+	case path == "/rest/filesystem/contents" && r.Method == http.MethodPost:
+		file, _, err := r.FormFile("upload")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		content, _ := ioutil.ReadAll(file)
+		name := r.URL.Query().Get("f")
+		m.mutex.Lock()
+		m.data.Files[name] = string(content)
+		m.mutex.Unlock()
+		m.add(MockRecord{Via: "rest", Request: request, File: name, Content: string(content)})
+	case path == "/rest/events" && r.Method == http.MethodPost:
+		event := new(events.Event)
+		if err := xml.NewDecoder(r.Body).Decode(event); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		m.add(MockRecord{Via: "rest", Request: request, Event: event})
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		m.add(MockRecord{Via: "rest", Request: request})
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// filterInterfaces returns the interfaces that match a FIQL search; only node.foreignSource==name conditions
+// combined with commas (OR) are supported, as used by the tool
+func filterInterfaces(interfaces []IPInterface, search string) []IPInterface {
+	if search == "" {
+		return interfaces
+	}
+	sources := make(map[string]bool)
+	for _, condition := range strings.Split(search, ",") {
+		sources[strings.TrimPrefix(condition, "node.foreignSource==")] = true
+	}
+	filtered := make([]IPInterface, 0)
+	for _, intf := range interfaces {
+		if sources[intf.ForeignSource] {
+			filtered = append(filtered, intf)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,85 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/events"
+)
+
+func TestMockOpenNMS(t *testing.T) {
+	data := MockData{
+		Interfaces: []IPInterface{{IPAddress: "10.0.0.1", NodeID: 1}, {IPAddress: "10.0.0.2", NodeID: 2}},
+		Outages:    []ScheduledOutage{{Name: "always", Type: "daily", Times: []OutageTime{{Begins: "00:00:00", Ends: "00:00:00"}}, Nodes: []OutageNode{{ID: 2}}}},
+	}
+	var record bytes.Buffer
+	mock := NewMockOpenNMS(data, &record)
+	mock.User, mock.Password = "admin", "admin"
+	server := httptest.NewServer(mock)
+	defer server.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	defer listener.Close()
+	go mock.ServeEventd(listener)
+
+	client := NewRestClient(server.URL+"/opennms", "admin", "admin")
+	if addresses, err := client.GetIPAddresses(); err != nil || strings.Join(addresses, ",") != "10.0.0.1,10.0.0.2" {
+		t.Errorf("unexpected addresses: %v %v", addresses, err)
+	}
+	if addresses, err := client.GetAddressesUnderOutage(time.Now()); err != nil || strings.Join(addresses, ",") != "10.0.0.2" {
+		t.Errorf("unexpected addresses under outage: %v %v", addresses, err)
+	}
+
+	def := discovery.Definition{}
+	def.AddSpecific("10.0.0.10")
+	cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{def}}
+	if err := UpdateOpenNMSViaRest(cfg, client, UpdatePolicy{}); err != nil {
+		t.Fatalf("cannot update the configuration: %v", err)
+	}
+	if content, _ := mock.File(discoveryConfigFile); !strings.Contains(content, "10.0.0.10") {
+		t.Errorf("the configuration was not updated: %s", content)
+	}
+
+	eventLog := new(events.Log)
+	eventLog.Add(events.Event{UEI: soakTestUEI})
+	if err := eventLog.Send("127.0.0.1", listener.Addr().(*net.TCPAddr).Port); err != nil {
+		t.Fatalf("cannot send event: %v", err)
+	}
+	for i := 0; i < 100 && len(mock.Events()) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	received := mock.Events()
+	if len(received) != 2 || received[0].UEI != "uei.opennms.org/internal/reloadDaemonConfig" || received[1].UEI != soakTestUEI {
+		t.Errorf("unexpected events: %+v", received)
+	}
+
+	resp, err := http.Get(server.URL + "/mock/records")
+	if err != nil {
+		t.Fatalf("cannot get records: %v", err)
+	}
+	defer resp.Body.Close()
+	records := make([]MockRecord, 0)
+	json.NewDecoder(resp.Body).Decode(&records)
+	if len(records) != 7 || records[len(records)-1].Via != "eventd" { // Interfaces twice, outages, and the files and events
+		t.Errorf("unexpected records: %+v", records)
+	}
+	if lines := strings.Count(record.String(), "\n"); lines != len(records) {
+		t.Errorf("every record should be written: %d", lines)
+	}
+
+	client.Password = "invalid"
+	if _, err := client.GetIPAddresses(); err == nil {
+		t.Errorf("invalid credentials should fail")
+	}
+}
@@ -0,0 +1,107 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Name based exclusion, via the forward names known by the inputs or reverse DNS (PTR) lookups of the candidate addresses
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// StringList is a flag that can be specified multiple times
+type StringList []string
+
+func (l *StringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *StringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+type NameFilter struct {
+	Patterns []*regexp.Regexp
+	Timeout  time.Duration
+	Lookup   func(ctx context.Context, addr string) ([]string, error)
+	Cache    *LookupCache
+}
+
+func NewNameFilter(patterns []string) (*NameFilter, error) {
+	filter := &NameFilter{
+		Patterns: make([]*regexp.Regexp, 0, len(patterns)),
+		Timeout:  5 * time.Second,
+		Lookup:   net.DefaultResolver.LookupAddr,
+	}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid name pattern %s: %v", p, err)
+		}
+		filter.Patterns = append(filter.Patterns, re)
+	}
+	return filter, nil
+}
+
+// Match returns the name of the IP address that matches any of the patterns, if any.
+// The known names (e.x. the forward name from the DNS export) are checked first, without DNS lookups, and then the PTR names.
+// Addresses without PTR records only match by their known names.
+func (f *NameFilter) Match(ip string, known ...string) (string, bool) {
+	if f == nil || len(f.Patterns) == 0 {
+		return "", false
+	}
+	if name, ok := f.matchAny(known); ok {
+		return name, true
+	}
+	names, err := f.lookup(ip)
+	if err != nil {
+		return "", false
+	}
+	return f.matchAny(names)
+}
+
+// matchAny returns the first name that matches any of the patterns
+func (f *NameFilter) matchAny(names []string) (string, bool) {
+	for _, name := range names {
+		name = strings.TrimSuffix(name, ".")
+		if name == "" {
+			continue
+		}
+		for _, re := range f.Patterns {
+			if re.MatchString(name) {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// lookup returns the PTR names of the IP address, from the cache when possible.
+// Addresses without PTR records are cached as well, but other failures are not.
+func (f *NameFilter) lookup(ip string) ([]string, error) {
+	key := "ptr:" + ip
+	if names, ok := f.Cache.Get(key); ok {
+		return names, nil
+	}
+	var names []string
+	err := lookupThrottle.Do(func() error {
+		var e error
+		ctx, cancel := context.WithTimeout(context.Background(), f.Timeout)
+		defer cancel()
+		names, e = f.Lookup(ctx, ip)
+		return e
+	})
+	if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+		names, err = []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.Cache.Put(key, names)
+	return names, nil
+}
@@ -0,0 +1,74 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNameFilter(t *testing.T) {
+	filter, err := NewNameFilter([]string{`.*-mgmt-ilo.*`, `^printer\d+\.`})
+	if err != nil {
+		t.Fatalf("cannot create filter: %v", err)
+	}
+	filter.Lookup = func(ctx context.Context, addr string) ([]string, error) {
+		switch addr {
+		case "10.0.0.1":
+			return []string{"srv01-mgmt-ilo.example.com."}, nil
+		case "10.0.0.2":
+			return []string{"printer01.example.com."}, nil
+		case "10.0.0.3":
+			return []string{"srv01.example.com."}, nil
+		}
+		return nil, errors.New("not found")
+	}
+	if name, ok := filter.Match("10.0.0.1"); !ok || name != "srv01-mgmt-ilo.example.com" {
+		t.Errorf("10.0.0.1 should match: %s", name)
+	}
+	if _, ok := filter.Match("10.0.0.2"); !ok {
+		t.Errorf("10.0.0.2 should match")
+	}
+	if _, ok := filter.Match("10.0.0.3"); ok {
+		t.Errorf("10.0.0.3 should not match")
+	}
+	if _, ok := filter.Match("10.0.0.4"); ok {
+		t.Errorf("10.0.0.4 should not match")
+	}
+	if name, ok := filter.Match("10.0.0.4", "srv04-mgmt-ilo.example.com"); !ok || name != "srv04-mgmt-ilo.example.com" {
+		t.Errorf("10.0.0.4 should match by its known name: %s", name)
+	}
+	if _, ok := filter.Match("10.0.0.3", ""); ok {
+		t.Errorf("10.0.0.3 should not match with an unknown name")
+	}
+	if _, err := NewNameFilter([]string{"(unclosed"}); err == nil {
+		t.Errorf("invalid patterns should fail")
+	}
+}
+
+func TestNameFilterCache(t *testing.T) {
+	filter, _ := NewNameFilter([]string{`-ilo`})
+	filter.Cache = &LookupCache{Entries: make(map[string]CacheEntry), TTL: time.Hour}
+	lookups := 0
+	filter.Lookup = func(ctx context.Context, addr string) ([]string, error) {
+		lookups++
+		if addr == "10.0.0.2" {
+			return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+		}
+		return []string{"srv01-ilo.example.com."}, nil
+	}
+	for i := 0; i < 3; i++ {
+		if _, ok := filter.Match("10.0.0.1"); !ok {
+			t.Errorf("10.0.0.1 should match")
+		}
+		if _, ok := filter.Match("10.0.0.2"); ok {
+			t.Errorf("10.0.0.2 should not match")
+		}
+	}
+	if lookups != 2 {
+		t.Errorf("each address should be resolved only once: %d", lookups)
+	}
+}
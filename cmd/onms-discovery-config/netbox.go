@@ -0,0 +1,114 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// NetBox IPAM as a source of prefixes and IP addresses
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type NetBoxClient struct {
+	URL    string // e.x. https://netbox.example.com
+	Token  string
+	Client *http.Client
+}
+
+func NewNetBoxClient(url, token string) *NetBoxClient {
+	return &NetBoxClient{
+		URL:    strings.TrimSuffix(url, "/"),
+		Token:  token,
+		Client: &http.Client{Timeout: 60 * time.Second, Transport: apiTransport},
+	}
+}
+
+// list retrieves all the pages of a NetBox collection with the given filter (as a query string), e.x. tenant=acme&status=active
+func (c *NetBoxClient) list(path, filter string, add func(result map[string]interface{})) error {
+	query, err := url.ParseQuery(filter)
+	if err != nil {
+		return fmt.Errorf("invalid NetBox filter %s: %v", filter, err)
+	}
+	query.Set("limit", "1000")
+	next := c.URL + path + "?" + query.Encode()
+	for next != "" {
+		req, err := http.NewRequest(http.MethodGet, next, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		if c.Token != "" {
+			req.Header.Set("Authorization", "Token "+c.Token)
+		}
+		var resp *http.Response
+		err = lookupThrottle.Do(func() error {
+			var e error
+			resp, e = c.Client.Do(req)
+			return e
+		})
+		if err != nil {
+			return err
+		}
+		page := struct {
+			Next    string                   `json:"next"`
+			Results []map[string]interface{} `json:"results"`
+		}{}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			return fmt.Errorf("GET %s failed with %s: %s", path, resp.Status, string(body))
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("invalid response from %s: %v", path, err)
+		}
+		for _, result := range page.Results {
+			add(result)
+		}
+		if next, err = c.nextPage(path, page.Next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextPage returns the URL of the next page, keeping only the query of the link from NetBox (with the offset and limit);
+// behind a TLS-terminating proxy, the link can use http:// or an internal host, and the token must only be sent to c.URL.
+func (c *NetBoxClient) nextPage(path, link string) (string, error) {
+	if link == "" {
+		return "", nil
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", fmt.Errorf("invalid next page %s from NetBox: %v", link, err)
+	}
+	return c.URL + path + "?" + u.RawQuery, nil
+}
+
+// GetPrefixes returns the prefixes (CIDRs) that match the filter
+func (c *NetBoxClient) GetPrefixes(filter string) ([]string, error) {
+	prefixes := make([]string, 0)
+	err := c.list("/api/ipam/prefixes/", filter, func(result map[string]interface{}) {
+		if prefix, ok := result["prefix"].(string); ok {
+			prefixes = append(prefixes, prefix)
+		}
+	})
+	return prefixes, err
+}
+
+// GetIPAddresses returns the IP addresses (without the mask) that match the filter
+func (c *NetBoxClient) GetIPAddresses(filter string) ([]string, error) {
+	addresses := make([]string, 0)
+	err := c.list("/api/ipam/ip-addresses/", filter, func(result map[string]interface{}) {
+		if address, ok := result["address"].(string); ok {
+			addresses = append(addresses, strings.SplitN(address, "/", 2)[0])
+		}
+	})
+	return addresses, err
+}
@@ -0,0 +1,55 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNetBoxClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token my-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Query().Get("tenant") != "acme" || r.URL.Query().Get("status") != "active" {
+			t.Errorf("the filter was not applied: %s", r.URL.RawQuery)
+		}
+		switch r.URL.Path {
+		case "/api/ipam/prefixes/":
+			w.Write([]byte(`{"next":null,"results":[{"prefix":"10.0.0.0/24"},{"prefix":"2001:db8::/126"}]}`))
+		case "/api/ipam/ip-addresses/":
+			if r.URL.Query().Get("offset") == "" {
+				// Behind a TLS-terminating proxy, the links use the internal address
+				w.Write([]byte(`{"next":"http://netbox.internal:8080/api/ipam/ip-addresses/?tenant=acme&status=active&offset=1","results":[{"address":"192.168.0.1/24"}]}`))
+			} else {
+				w.Write([]byte(`{"next":null,"results":[{"address":"192.168.0.2/32"}]}`))
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewNetBoxClient(server.URL+"/", "my-token")
+	prefixes, err := client.GetPrefixes("tenant=acme&status=active")
+	if err != nil {
+		t.Fatalf("cannot get prefixes: %v", err)
+	}
+	if len(prefixes) != 2 || prefixes[0] != "10.0.0.0/24" {
+		t.Errorf("unexpected prefixes: %v", prefixes)
+	}
+	addresses, err := client.GetIPAddresses("tenant=acme&status=active")
+	if err != nil {
+		t.Fatalf("cannot get IP addresses: %v", err)
+	}
+	if len(addresses) != 2 || addresses[0] != "192.168.0.1" || addresses[1] != "192.168.0.2" {
+		t.Errorf("unexpected IP addresses: %v", addresses)
+	}
+	client.Token = "invalid"
+	if _, err := client.GetPrefixes("tenant=acme&status=active"); err == nil {
+		t.Errorf("invalid credentials should fail")
+	}
+}
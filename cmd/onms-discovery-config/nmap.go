@@ -0,0 +1,101 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Nmap XML output (nmap -oX) as a source of the IP addresses of the hosts that are up
+// https://nmap.org/book/output-formats-xml-output.html
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// NmapHost is a host that is up according to a scan
+type NmapHost struct {
+	Addresses []string // IPv4 and IPv6 addresses
+	OpenPorts []string // e.x. 22/tcp
+}
+
+// HasOpenPort returns true when any of the ports is open; ports without protocol (e.x. 22) match TCP and UDP
+func (h *NmapHost) HasOpenPort(ports []string) bool {
+	for _, port := range ports {
+		for _, open := range h.OpenPorts {
+			if open == port || strings.HasPrefix(open, port+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ParseNmapPorts validates a comma-separated list of ports, each with an optional protocol; e.x. 22,161/udp
+func ParseNmapPorts(list string) ([]string, error) {
+	ports := splitNames(strings.ToLower(list))
+	for _, port := range ports {
+		parts := strings.SplitN(port, "/", 2)
+		if n, err := strconv.Atoi(parts[0]); err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("invalid Nmap port %s", port)
+		}
+		if len(parts) == 2 && parts[1] != "tcp" && parts[1] != "udp" && parts[1] != "sctp" {
+			return nil, fmt.Errorf("invalid protocol for Nmap port %s; valid protocols are tcp, udp and sctp", port)
+		}
+	}
+	return ports, nil
+}
+
+// ParseNmapXML returns the hosts that are up, decoding one host at a time as the output of big scans can be huge
+func ParseNmapXML(r io.Reader) ([]*NmapHost, error) {
+	hosts := make([]*NmapHost, 0)
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid Nmap XML: %v", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "host" {
+			continue
+		}
+		host := struct {
+			Status struct {
+				State string `xml:"state,attr"`
+			} `xml:"status"`
+			Addresses []struct {
+				Addr string `xml:"addr,attr"`
+				Type string `xml:"addrtype,attr"`
+			} `xml:"address"`
+			Ports []struct {
+				Protocol string `xml:"protocol,attr"`
+				ID       string `xml:"portid,attr"`
+				State    struct {
+					State string `xml:"state,attr"`
+				} `xml:"state"`
+			} `xml:"ports>port"`
+		}{}
+		if err := decoder.DecodeElement(&host, &start); err != nil {
+			return nil, fmt.Errorf("invalid Nmap host: %v", err)
+		}
+		if host.Status.State != "up" {
+			continue
+		}
+		h := &NmapHost{Addresses: make([]string, 0), OpenPorts: make([]string, 0)}
+		for _, a := range host.Addresses {
+			if a.Type == "ipv4" || a.Type == "ipv6" { // Skip MAC addresses
+				h.Addresses = append(h.Addresses, a.Addr)
+			}
+		}
+		for _, p := range host.Ports {
+			if p.State.State == "open" {
+				h.OpenPorts = append(h.OpenPorts, p.ID+"/"+p.Protocol)
+			}
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
@@ -0,0 +1,75 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const nmapScan = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE nmaprun>
+<nmaprun scanner="nmap" args="nmap -oX scan.xml 10.0.0.0/29" version="7.94">
+  <host starttime="1700000000" endtime="1700000010">
+    <status state="up" reason="arp-response"/>
+    <address addr="10.0.0.1" addrtype="ipv4"/>
+    <address addr="00:11:22:33:44:55" addrtype="mac" vendor="Cisco"/>
+    <ports>
+      <extraports state="closed" count="998"/>
+      <port protocol="tcp" portid="22"><state state="open" reason="syn-ack"/><service name="ssh"/></port>
+      <port protocol="udp" portid="161"><state state="open" reason="udp-response"/><service name="snmp"/></port>
+    </ports>
+  </host>
+  <host>
+    <status state="down" reason="no-response"/>
+    <address addr="10.0.0.2" addrtype="ipv4"/>
+  </host>
+  <host>
+    <status state="up" reason="echo-reply"/>
+    <address addr="10.0.0.3" addrtype="ipv4"/>
+    <address addr="2001:db8::3" addrtype="ipv6"/>
+    <ports>
+      <port protocol="tcp" portid="22"><state state="filtered" reason="no-response"/></port>
+      <port protocol="tcp" portid="443"><state state="open" reason="syn-ack"/></port>
+    </ports>
+  </host>
+  <runstats><finished time="1700000020"/><hosts up="2" down="1" total="3"/></runstats>
+</nmaprun>`
+
+func TestParseNmapXML(t *testing.T) {
+	hosts, err := ParseNmapXML(strings.NewReader(nmapScan))
+	if err != nil {
+		t.Fatalf("cannot parse scan: %v", err)
+	}
+	if len(hosts) != 2 {
+		t.Fatalf("there should be 2 hosts up: %+v", hosts)
+	}
+	if strings.Join(hosts[0].Addresses, ",") != "10.0.0.1" || strings.Join(hosts[0].OpenPorts, ",") != "22/tcp,161/udp" {
+		t.Errorf("unexpected host: %+v", hosts[0])
+	}
+	if strings.Join(hosts[1].Addresses, ",") != "10.0.0.3,2001:db8::3" || strings.Join(hosts[1].OpenPorts, ",") != "443/tcp" {
+		t.Errorf("unexpected host: %+v", hosts[1])
+	}
+	if _, err := ParseNmapXML(strings.NewReader("<nmaprun><host>")); err == nil {
+		t.Errorf("the scan should be invalid")
+	}
+}
+
+func TestNmapPorts(t *testing.T) {
+	ports, err := ParseNmapPorts("22, 161/UDP")
+	if err != nil {
+		t.Fatalf("cannot parse ports: %v", err)
+	}
+	host := &NmapHost{OpenPorts: []string{"22/tcp", "443/tcp"}}
+	if !host.HasOpenPort(ports) {
+		t.Errorf("port 22 should match any protocol")
+	}
+	if host.HasOpenPort([]string{"161/udp", "22/udp"}) || host.HasOpenPort([]string{"44"}) {
+		t.Errorf("the ports should not match")
+	}
+	for _, list := range []string{"ssh", "0", "70000", "161/icmp"} {
+		if _, err := ParseNmapPorts(list); err == nil {
+			t.Errorf("ports %s should be invalid", list)
+		}
+	}
+}
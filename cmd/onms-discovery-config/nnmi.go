@@ -0,0 +1,33 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Decoder for the IP addresses in hexadecimal format exported from HP NNMi
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// DecodeNNMiHex converts an address in hex format into its textual representation.
+// IPv4 addresses are taken from the last 8 hex digits, and IPv6 addresses from the last 32 when the line has at least that many.
+// A 0x prefix and the usual separators (colons, dashes, dots and spaces) are ignored.
+func DecodeNNMiHex(line string) (string, error) {
+	text := strings.TrimSpace(line)
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "0x"), "0X")
+	text = strings.NewReplacer(":", "", "-", "", ".", "", " ", "").Replace(text)
+	size := net.IPv4len * 2
+	if len(text) >= net.IPv6len*2 {
+		size = net.IPv6len * 2
+	}
+	if len(text) < size {
+		return "", fmt.Errorf("'%s' is too short for an IP address in hex format", line)
+	}
+	data, err := hex.DecodeString(text[len(text)-size:])
+	if err != nil {
+		return "", fmt.Errorf("'%s' is not a valid IP address in hex format: %v", line, err)
+	}
+	return net.IP(data).String(), nil
+}
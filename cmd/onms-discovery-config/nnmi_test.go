@@ -0,0 +1,32 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"testing"
+)
+
+func TestDecodeNNMiHex(t *testing.T) {
+	valid := map[string]string{
+		"0A000001":                         "10.0.0.1",
+		"c0a8010a":                         "192.168.1.10",
+		"0xC0A8010A":                       "192.168.1.10",
+		"  0000C0A8010A\r":                 "192.168.1.10",
+		"20010db8000000000000000000000001": "2001:db8::1",
+		"2001:0db8:0000:0000:0000:0000:0000:0001": "2001:db8::1",
+		"0xFE800000000000000202B3FFFE1E8329":      "fe80::202:b3ff:fe1e:8329",
+	}
+	for input, expected := range valid {
+		ip, err := DecodeNNMiHex(input)
+		if err != nil {
+			t.Errorf("cannot decode %s: %v", input, err)
+		} else if ip != expected {
+			t.Errorf("%s should be decoded as %s: %s", input, expected, ip)
+		}
+	}
+	for _, input := range []string{"", "0A00", "0A0000ZZ", "not an address"} {
+		if ip, err := DecodeNNMiHex(input); err == nil {
+			t.Errorf("%s should be invalid: %s", input, ip)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Helper functions to report the outcome of a run to OpenNMS
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/events"
+)
+
+// Fail sends the failure event to OpenNMS when configured, and then terminates the program
+func (o *Options) Fail(err error) {
+	if o.FailureUEI != "" && !errors.Is(err, ErrNoChanges) {
+		log.Printf("sending failure event %s to OpenNMS", o.FailureUEI)
+		event := events.Event{UEI: o.FailureUEI}
+		if severity, e := events.ParseSeverity(o.FailureSev); e == nil {
+			event.SetSeverity(severity)
+		} else {
+			log.Printf("ignoring failure event severity: %v", e)
+		}
+		event.SetLogMsg(fmt.Sprintf("Discovery configuration generation failed: %v", err), events.LogDestLogAndDisplay)
+		event.AddParam("reason", err.Error())
+		if e := o.sendEvent(event); e != nil {
+			log.Printf("cannot send failure event: %v", e)
+		}
+	}
+	log.Fatal(err)
+}
+
+// Heartbeat sends the heartbeat event to OpenNMS when configured
+func (o *Options) Heartbeat(duration time.Duration) {
+	if o.HeartbeatUEI == "" {
+		return
+	}
+	log.Printf("sending heartbeat event %s to OpenNMS", o.HeartbeatUEI)
+	event := events.Event{UEI: o.HeartbeatUEI}
+	event.SetLogMsg("Discovery configuration generated", events.LogDestLogAndDisplay)
+	event.AddParam("totalAddresses", baseConfig.GetTotalEstimatedAddresses().String())
+	event.AddParam("definitions", strconv.Itoa(len(baseConfig.Definitions)))
+	event.AddParam("duration", strconv.FormatInt(duration.Milliseconds(), 10))
+	if err := o.sendEvent(event); err != nil {
+		log.Printf("cannot send heartbeat event: %v", err)
+	}
+}
+
+func (o *Options) sendEvent(event events.Event) error {
+	hostname, _ := os.Hostname()
+	event.Source = "DiscoverConfigGenerator"
+	event.Time = time.Now().Format(time.RFC3339)
+	event.Host = hostname
+	if generation.Change != "" {
+		event.AddParam("changeTicket", generation.Change)
+	}
+	opts, err := o.eventOptions()
+	if err != nil {
+		return err
+	}
+	opts.Retries, opts.Backoff = o.NotifyRetries, o.NotifyDelay
+	events := new(events.Log)
+	events.Add(event)
+	return events.SendWithOptions(o.OnmsHost, o.OnmsPort, opts)
+}
+
+// eventOptions returns the settings of the connection to eventd
+func (o *Options) eventOptions() (events.SendOptions, error) {
+	opts := events.SendOptions{Timeout: o.OnmsTimeout}
+	if o.OnmsTLS || o.OnmsCert != "" || o.OnmsCA != "" {
+		tlsConfig, err := loadTLSConfig("eventd", o.OnmsCert, o.OnmsKey, "onms-key", o.OnmsCA)
+		if err != nil {
+			return opts, err
+		}
+		opts.TLS = tlsConfig
+	}
+	return opts, nil
+}
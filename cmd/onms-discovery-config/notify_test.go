@@ -0,0 +1,46 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/events"
+)
+
+func TestHeartbeat(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot create TCP server: %v", err)
+	}
+	defer ln.Close()
+
+	opts := &Options{OnmsHost: "127.0.0.1", OnmsPort: ln.Addr().(*net.TCPAddr).Port, HeartbeatUEI: "uei.opennms.org/test/heartbeat"}
+	go opts.Heartbeat(5 * time.Second)
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("cannot accept connections: %v", err)
+	}
+	defer conn.Close()
+
+	buf, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Errorf("cannot read content: %v", err)
+	}
+	received := new(events.Log)
+	xml.Unmarshal(buf, received)
+	if len(received.Events) != 1 || received.Events[0].UEI != "uei.opennms.org/test/heartbeat" {
+		t.Fatalf("incorrect message received: %s", string(buf))
+	}
+	if len(received.Events[0].Parameters) != 3 {
+		t.Errorf("the heartbeat event should have 3 parameters")
+	}
+	if received.Events[0].Parameters[2].Value.Content != "5000" {
+		t.Errorf("incorrect duration: %s", received.Events[0].Parameters[2].Value.Content)
+	}
+}
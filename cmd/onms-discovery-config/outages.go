@@ -0,0 +1,125 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Scheduled outages from OpenNMS, to exclude the addresses under maintenance
+
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+type OutageTime struct {
+	Day    string `json:"day,omitempty"` // Day of the week for weekly outages, or day of the month for monthly outages
+	Begins string `json:"begins"`
+	Ends   string `json:"ends"`
+}
+
+type OutageNode struct {
+	ID int `json:"id"`
+}
+
+type OutageInterface struct {
+	Address string `json:"address"`
+}
+
+type ScheduledOutage struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"` // specific, daily, weekly or monthly
+	Times      []OutageTime      `json:"time"`
+	Nodes      []OutageNode      `json:"node,omitempty"`
+	Interfaces []OutageInterface `json:"interface,omitempty"`
+}
+
+const (
+	outageDateLayout = "02-Jan-2006 15:04:05"
+	outageTimeLayout = "15:04:05"
+)
+
+// inWindow verifies if the time of the day is between begins and ends, handling windows that cross midnight
+func inWindow(now time.Time, begins, ends string) bool {
+	b, err1 := time.Parse(outageTimeLayout, begins)
+	e, err2 := time.Parse(outageTimeLayout, ends)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	t := now.Hour()*3600 + now.Minute()*60 + now.Second()
+	start := b.Hour()*3600 + b.Minute()*60 + b.Second()
+	end := e.Hour()*3600 + e.Minute()*60 + e.Second()
+	if end <= start {
+		return t >= start || t < end
+	}
+	return t >= start && t < end
+}
+
+// IsActive returns true when any of the time windows of the outage contains the given time
+func (o *ScheduledOutage) IsActive(now time.Time) bool {
+	for _, t := range o.Times {
+		switch o.Type {
+		case "specific":
+			b, err1 := time.ParseInLocation(outageDateLayout, t.Begins, now.Location())
+			e, err2 := time.ParseInLocation(outageDateLayout, t.Ends, now.Location())
+			if err1 == nil && err2 == nil && !now.Before(b) && now.Before(e) {
+				return true
+			}
+		case "daily":
+			if inWindow(now, t.Begins, t.Ends) {
+				return true
+			}
+		case "weekly":
+			if strings.EqualFold(t.Day, now.Weekday().String()) && inWindow(now, t.Begins, t.Ends) {
+				return true
+			}
+		case "monthly":
+			if day, err := strconv.Atoi(t.Day); err == nil && day == now.Day() && inWindow(now, t.Begins, t.Ends) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetScheduledOutages returns all the scheduled outages defined in OpenNMS
+func (c *RestClient) GetScheduledOutages() ([]ScheduledOutage, error) {
+	data := struct {
+		Outages []ScheduledOutage `json:"outage"`
+	}{}
+	if err := c.Get("/rest/sched-outages", &data); err != nil {
+		return nil, err
+	}
+	return data.Outages, nil
+}
+
+// GetAddressesUnderOutage returns the IP addresses of the interfaces and nodes affected by the outages active at the given time
+func (c *RestClient) GetAddressesUnderOutage(now time.Time) ([]string, error) {
+	outages, err := c.GetScheduledOutages()
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]string, 0)
+	nodes := make(map[int]bool)
+	for _, o := range outages {
+		if !o.IsActive(now) {
+			continue
+		}
+		for _, intf := range o.Interfaces {
+			addresses = append(addresses, intf.Address)
+		}
+		for _, node := range o.Nodes {
+			nodes[node.ID] = true
+		}
+	}
+	if len(nodes) > 0 {
+		interfaces, err := c.GetIPInterfaces()
+		if err != nil {
+			return nil, err
+		}
+		for _, intf := range interfaces {
+			if nodes[intf.NodeID] {
+				addresses = append(addresses, intf.IPAddress)
+			}
+		}
+	}
+	return addresses, nil
+}
@@ -0,0 +1,61 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestScheduledOutageIsActive(t *testing.T) {
+	now := time.Date(2020, time.March, 10, 23, 30, 0, 0, time.UTC) // Tuesday
+	tests := map[string]struct {
+		outage ScheduledOutage
+		active bool
+	}{
+		"specific":             {ScheduledOutage{Type: "specific", Times: []OutageTime{{Begins: "10-Mar-2020 20:00:00", Ends: "11-Mar-2020 02:00:00"}}}, true},
+		"specific expired":     {ScheduledOutage{Type: "specific", Times: []OutageTime{{Begins: "09-Mar-2020 20:00:00", Ends: "10-Mar-2020 02:00:00"}}}, false},
+		"daily":                {ScheduledOutage{Type: "daily", Times: []OutageTime{{Begins: "23:00:00", Ends: "23:59:59"}}}, true},
+		"daily cross midnight": {ScheduledOutage{Type: "daily", Times: []OutageTime{{Begins: "22:00:00", Ends: "04:00:00"}}}, true},
+		"daily inactive":       {ScheduledOutage{Type: "daily", Times: []OutageTime{{Begins: "01:00:00", Ends: "04:00:00"}}}, false},
+		"weekly":               {ScheduledOutage{Type: "weekly", Times: []OutageTime{{Day: "tuesday", Begins: "20:00:00", Ends: "23:59:59"}}}, true},
+		"weekly other day":     {ScheduledOutage{Type: "weekly", Times: []OutageTime{{Day: "monday", Begins: "20:00:00", Ends: "23:59:59"}}}, false},
+		"monthly":              {ScheduledOutage{Type: "monthly", Times: []OutageTime{{Day: "10", Begins: "20:00:00", Ends: "23:59:59"}}}, true},
+		"monthly other day":    {ScheduledOutage{Type: "monthly", Times: []OutageTime{{Day: "1", Begins: "20:00:00", Ends: "23:59:59"}}}, false},
+	}
+	for name, test := range tests {
+		if active := test.outage.IsActive(now); active != test.active {
+			t.Errorf("%s: expected active=%v, got %v", name, test.active, active)
+		}
+	}
+}
+
+func TestGetAddressesUnderOutage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/sched-outages":
+			w.Write([]byte(`{"outage":[
+				{"name":"branch","type":"daily","time":[{"begins":"00:00:00","ends":"23:59:59"}],"node":[{"id":1}],"interface":[{"address":"10.0.0.1"}]},
+				{"name":"old","type":"specific","time":[{"begins":"01-Jan-2000 00:00:00","ends":"02-Jan-2000 00:00:00"}],"interface":[{"address":"10.0.0.2"}]}
+			]}`))
+		case "/api/v2/ipinterfaces":
+			w.Write([]byte(`{"ipInterface":[{"ipAddress":"10.1.0.1","nodeId":1},{"ipAddress":"10.1.0.2","nodeId":2}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewRestClient(server.URL, "admin", "admin")
+	addresses, err := client.GetAddressesUnderOutage(time.Date(2020, time.March, 10, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("cannot get addresses: %v", err)
+	}
+	sort.Strings(addresses)
+	if len(addresses) != 2 || addresses[0] != "10.0.0.1" || addresses[1] != "10.1.0.1" {
+		t.Errorf("unexpected addresses: %v", addresses)
+	}
+}
@@ -0,0 +1,125 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Planning of the global packets-per-second rate, which is shared by all the definitions
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+type DefinitionPlan struct {
+	Location      string
+	ForeignSource string
+	Addresses     *big.Int
+	Share         float64       // Fraction of each pass spent on the definition
+	Duration      time.Duration // Time spent on the definition at the global rate
+}
+
+type PassSuggestion struct {
+	Target           time.Duration
+	PacketsPerSecond int
+	ChunkSize        int
+}
+
+type PlanReport struct {
+	PacketsPerSecond int
+	Addresses        *big.Int
+	PassDuration     time.Duration
+	RestartSleepTime time.Duration
+	Definitions      []DefinitionPlan
+	Suggestions      []PassSuggestion
+}
+
+func (r *PlanReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "global rate: %d packets per second, addresses: %d, estimated pass duration: %s\n", r.PacketsPerSecond, r.Addresses, r.PassDuration)
+	for _, d := range r.Definitions {
+		location := d.Location
+		if location == "" {
+			location = "Default"
+		}
+		fmt.Fprintf(&sb, "definition location=%s, foreign-source=%s: addresses=%d, share=%.2f%%, duration=%s\n", location, d.ForeignSource, d.Addresses, d.Share*100, d.Duration)
+	}
+	if r.RestartSleepTime > 0 && r.PassDuration > r.RestartSleepTime {
+		fmt.Fprintf(&sb, "warning: the pass takes longer than the restart sleep time (%s)\n", r.RestartSleepTime)
+	}
+	for _, s := range r.Suggestions {
+		fmt.Fprintf(&sb, "for a pass of %s: packets-per-second=%d, chunk-size=%d\n", s.Target, s.PacketsPerSecond, s.ChunkSize)
+	}
+	return sb.String()
+}
+
+// sweepDuration returns the time required to ping the addresses at the given rate, ignoring retries
+func sweepDuration(addresses *big.Int, pps int) time.Duration {
+	if pps <= 0 {
+		return 0
+	}
+	count, _ := new(big.Float).SetInt(addresses).Float64()
+	duration := count / float64(pps) * float64(time.Second)
+	if duration > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(duration).Round(time.Second)
+}
+
+// SuggestPass returns the rate required to complete a pass over the addresses within the target duration.
+// The chunk size covers about 10 seconds of work at that rate, bounded between 100 and 10000 addresses.
+func SuggestPass(addresses *big.Int, target time.Duration) PassSuggestion {
+	count, _ := new(big.Float).SetInt(addresses).Float64()
+	rate := math.Ceil(count / target.Seconds())
+	if rate > math.MaxInt32 {
+		rate = math.MaxInt32
+	}
+	pps := int(rate)
+	if pps < 1 {
+		pps = 1
+	}
+	chunk := pps * 10
+	if chunk < 100 {
+		chunk = 100
+	}
+	if chunk > 10000 {
+		chunk = 10000
+	}
+	return PassSuggestion{Target: target, PacketsPerSecond: pps, ChunkSize: chunk}
+}
+
+// Plan reports how the global packets-per-second rate is shared across the definitions,
+// as Discovery pings all the addresses sequentially at that rate, and suggests settings for the target pass durations.
+func Plan(cfg *discovery.DiscoveryConfiguration, targets []time.Duration) *PlanReport {
+	report := &PlanReport{
+		PacketsPerSecond: cfg.PacketsPerSecond,
+		RestartSleepTime: time.Duration(cfg.RestartSleepTime) * time.Millisecond,
+		Definitions:      make([]DefinitionPlan, 0, len(cfg.Definitions)),
+		Suggestions:      make([]PassSuggestion, 0, len(targets)),
+		Addresses:        big.NewInt(0),
+	}
+	for _, def := range cfg.Definitions {
+		count := def.GetTotalEstimatedAddresses()
+		report.Addresses.Add(report.Addresses, count)
+		report.Definitions = append(report.Definitions, DefinitionPlan{
+			Location:      def.Location,
+			ForeignSource: def.ForeignSource,
+			Addresses:     count,
+			Duration:      sweepDuration(count, cfg.PacketsPerSecond),
+		})
+	}
+	for i := range report.Definitions {
+		if report.Addresses.Sign() > 0 {
+			share := new(big.Float).SetInt(report.Definitions[i].Addresses)
+			report.Definitions[i].Share, _ = share.Quo(share, new(big.Float).SetInt(report.Addresses)).Float64()
+		}
+	}
+	report.PassDuration = sweepDuration(report.Addresses, cfg.PacketsPerSecond)
+	for _, target := range targets {
+		report.Suggestions = append(report.Suggestions, SuggestPass(report.Addresses, target))
+	}
+	return report
+}
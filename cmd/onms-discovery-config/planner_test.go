@@ -0,0 +1,44 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestPlan(t *testing.T) {
+	def1 := discovery.Definition{Location: "Berlin"}
+	def1.IncludeCIDR("192.168.0.0/24") // 254 addresses
+	def1.AddSpecific("10.0.0.1")
+	def2 := discovery.Definition{Location: "Paris"}
+	def2.IncludeCIDR("192.168.1.0/25") // 126 addresses
+	def2.AddSpecific("10.0.0.2")
+	cfg := discovery.DiscoveryConfiguration{
+		PacketsPerSecond: 10,
+		RestartSleepTime: 10000,
+		Definitions:      []discovery.Definition{def1, def2},
+	}
+	report := Plan(&cfg, []time.Duration{time.Minute, time.Hour})
+	if report.Addresses.Int64() != 382 {
+		t.Errorf("there should be 382 addresses: %d", report.Addresses)
+	}
+	if report.PassDuration != 38*time.Second {
+		t.Errorf("the pass should take 38s: %s", report.PassDuration)
+	}
+	if d := report.Definitions[1]; d.Addresses.Int64() != 127 || d.Duration != 13*time.Second || d.Share < 0.33 || d.Share > 0.34 {
+		t.Errorf("unexpected plan for Paris: %+v", d)
+	}
+	if s := report.Suggestions[0]; s.PacketsPerSecond != 7 || s.ChunkSize != 100 {
+		t.Errorf("unexpected suggestion for 1m: %+v", s)
+	}
+	if s := report.Suggestions[1]; s.PacketsPerSecond != 1 {
+		t.Errorf("unexpected suggestion for 1h: %+v", s)
+	}
+	if s := SuggestPass(big.NewInt(100000000), time.Hour); s.PacketsPerSecond != 27778 || s.ChunkSize != 10000 {
+		t.Errorf("unexpected suggestion for a large scope: %+v", s)
+	}
+}
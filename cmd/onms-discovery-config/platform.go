@@ -0,0 +1,74 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Differences between platforms, so the tool runs on Linux, macOS and Windows collector hosts
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// OnmsHomeEnvVariable overrides the default of 'onms-home'
+const OnmsHomeEnvVariable = "OPENNMS_HOME"
+
+// defaultOnmsHome returns $OPENNMS_HOME when defined, or the default installation path of OpenNMS for the platform
+func defaultOnmsHome(goos string) string {
+	if home := os.Getenv(OnmsHomeEnvVariable); home != "" {
+		return home
+	}
+	return platformOnmsHome(goos)
+}
+
+// platformOnmsHome returns the default installation path of OpenNMS for the platform
+func platformOnmsHome(goos string) string {
+	if goos == "windows" {
+		return `C:\Program Files\OpenNMS`
+	}
+	return "/opt/opennms"
+}
+
+// checkOnmsHome verifies that the configuration directory of OpenNMS exists, to fail with a clear message on hosts
+// where OpenNMS was installed elsewhere (e.x. on Windows or macOS)
+func checkOnmsHome(onmsHomePath string) error {
+	etc := filepath.Join(onmsHomePath, "etc")
+	info, err := os.Stat(etc)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && !info.IsDir()) {
+		return fmt.Errorf("%s is not the home of OpenNMS, as %s doesn't exist; use 'onms-home' or set %s", onmsHomePath, etc, OnmsHomeEnvVariable)
+	}
+	return err
+}
+
+// fileMode returns the permissions of an existing file, so rewriting it keeps them, or the given mode when it doesn't exist
+func fileMode(fileName string, mode os.FileMode) os.FileMode {
+	if info, err := os.Stat(fileName); err == nil {
+		return info.Mode().Perm()
+	}
+	return mode
+}
+
+// checkPrivateFile warns when a file with secrets can be read by other users; it is skipped on Windows,
+// where the access is controlled by ACLs and the permission bits are meaningless
+func checkPrivateFile(fileName string) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	if info, err := os.Stat(fileName); err == nil && info.Mode().Perm()&0077 != 0 {
+		log.Printf("warning: %s can be accessed by other users (mode %s); use 'chmod 600 %s'", fileName, info.Mode().Perm(), fileName)
+	}
+}
+
+// icmpHint explains the privileges required to send ICMP requests on the current platform
+func icmpHint(goos string) string {
+	switch goos {
+	case "windows":
+		return "on Windows, run it as Administrator"
+	case "darwin":
+		return "on macOS, unprivileged ICMP sockets are supported, so verify the firewall or run it as root"
+	}
+	return "run it as root, or allow unprivileged ICMP sockets via 'sysctl net.ipv4.ping_group_range'"
+}
@@ -0,0 +1,65 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDefaultOnmsHome(t *testing.T) {
+	os.Unsetenv(OnmsHomeEnvVariable)
+	if home := defaultOnmsHome("linux"); home != "/opt/opennms" {
+		t.Errorf("unexpected home for linux: %s", home)
+	}
+	if home := defaultOnmsHome("windows"); home != `C:\Program Files\OpenNMS` {
+		t.Errorf("unexpected home for windows: %s", home)
+	}
+	os.Setenv(OnmsHomeEnvVariable, "/usr/local/opennms")
+	defer os.Unsetenv(OnmsHomeEnvVariable)
+	if home := defaultOnmsHome("darwin"); home != "/usr/local/opennms" {
+		t.Errorf("the environment should override the default: %s", home)
+	}
+	if home := platformOnmsHome("darwin"); home != "/opt/opennms" {
+		t.Errorf("the environment should not change the default of the platform: %s", home)
+	}
+}
+
+func TestCheckOnmsHome(t *testing.T) {
+	dir, err := ioutil.TempDir("", "platform")
+	if err != nil {
+		t.Fatalf("cannot create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := checkOnmsHome(dir); err == nil || !strings.Contains(err.Error(), OnmsHomeEnvVariable) {
+		t.Errorf("the home should be invalid, got %v", err)
+	}
+	os.Mkdir(filepath.Join(dir, "etc"), 0755)
+	if err := checkOnmsHome(dir); err != nil {
+		t.Errorf("the home should be valid: %v", err)
+	}
+}
+
+func TestFileMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "platform")
+	if err != nil {
+		t.Fatalf("cannot create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "discovery-configuration.xml")
+	if mode := fileMode(fileName, 0644); mode != 0644 {
+		t.Errorf("unexpected mode for a missing file: %s", mode)
+	}
+	if runtime.GOOS == "windows" {
+		return // Only the read-only attribute is supported
+	}
+	ioutil.WriteFile(fileName, []byte("<discovery-configuration/>"), 0640)
+	os.Chmod(fileName, 0640)
+	if mode := fileMode(fileName, 0644); mode != 0640 {
+		t.Errorf("the mode of the existing file should be kept: %s", mode)
+	}
+}
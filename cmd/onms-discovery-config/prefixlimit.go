@@ -0,0 +1,39 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Sanity checks for overly broad CIDRs, like 10.0.0.0/8 instead of 10.0.0.0/28
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+)
+
+type PrefixLimit struct {
+	IPv4  int  // Shortest IPv4 prefix length accepted without confirmation
+	IPv6  int  // Shortest IPv6 prefix length accepted without confirmation
+	Allow bool // Whether or not broader CIDRs were confirmed
+}
+
+// Check verifies that the CIDR is not broader than the limit for its address family.
+// Broader CIDRs fail unless they were confirmed, in which case a warning is logged.
+func (l PrefixLimit) Check(cidr string) error {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil // Invalid CIDRs are handled elsewhere
+	}
+	ones, _ := ipnet.Mask.Size()
+	limit := l.IPv6
+	if ipnet.IP.To4() != nil {
+		limit = l.IPv4
+	}
+	if limit <= 0 || ones >= limit {
+		return nil
+	}
+	if l.Allow {
+		log.Printf("warning: CIDR %s is broader than /%d", cidr, limit)
+		return nil
+	}
+	return fmt.Errorf("CIDR %s is broader than /%d; use -allow-broad-cidr to confirm it", cidr, limit)
+}
@@ -0,0 +1,29 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import "testing"
+
+func TestPrefixLimit(t *testing.T) {
+	limit := PrefixLimit{IPv4: 16, IPv6: 48}
+	tests := map[string]bool{
+		"10.0.0.0/28":   true,
+		"172.16.0.0/16": true,
+		"10.0.0.0/8":    false,
+		"2001:db8::/64": true,
+		"2001:db8::/32": false,
+		"not-a-cidr":    true,
+	}
+	for cidr, valid := range tests {
+		if err := limit.Check(cidr); (err == nil) != valid {
+			t.Errorf("%s: expected valid=%v, got %v", cidr, valid, err)
+		}
+	}
+	limit.Allow = true
+	if err := limit.Check("10.0.0.0/8"); err != nil {
+		t.Errorf("confirmed CIDRs should be accepted: %v", err)
+	}
+	if err := (PrefixLimit{}).Check("0.0.0.0/0"); err != nil {
+		t.Errorf("a zero limit should disable the check: %v", err)
+	}
+}
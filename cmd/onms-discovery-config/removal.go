@@ -0,0 +1,61 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Report of the existing nodes that fall inside the scope removed from the configuration
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+type RemovalReport struct {
+	Host       string        `json:"host"`
+	Time       string        `json:"time"`
+	Interfaces []IPInterface `json:"interfaces"` // Interfaces of existing nodes that are no longer part of the scope
+}
+
+// Removed returns the interfaces covered by the current configuration that are not covered by the generated one
+func Removed(cfg *discovery.DiscoveryConfiguration, current *discovery.DiscoveryConfiguration, interfaces []IPInterface) *RemovalReport {
+	hostname, _ := os.Hostname()
+	report := &RemovalReport{
+		Host:       hostname,
+		Time:       time.Now().Format(time.RFC3339),
+		Interfaces: make([]IPInterface, 0),
+	}
+	for _, intf := range interfaces {
+		if current.Covers(intf.IPAddress) && !cfg.Covers(intf.IPAddress) {
+			report.Interfaces = append(report.Interfaces, intf)
+		}
+	}
+	return report
+}
+
+// Send posts the report as JSON to the given webhook URL
+func (r *RemovalReport) Send(url string) error {
+	return postJSON(url, r)
+}
+
+// postJSON sends the object as JSON to a webhook
+func postJSON(url string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s failed with %s", url, resp.Status)
+	}
+	return nil
+}
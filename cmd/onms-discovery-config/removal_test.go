@@ -0,0 +1,51 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestRemoved(t *testing.T) {
+	c := discovery.Definition{}
+	c.IncludeCIDR("192.168.0.0/24")
+	c.IncludeCIDR("192.168.1.0/24")
+	c.AddSpecific("10.0.0.1")
+	current := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{c}}
+
+	g := discovery.Definition{}
+	g.IncludeCIDR("192.168.0.0/24")
+	generated := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{g}}
+
+	interfaces := []IPInterface{
+		{IPAddress: "192.168.0.10", NodeID: 1},
+		{IPAddress: "192.168.1.10", NodeID: 2},
+		{IPAddress: "10.0.0.1", NodeID: 3},
+		{IPAddress: "172.16.0.1", NodeID: 4},
+	}
+	report := Removed(generated, current, interfaces)
+	if len(report.Interfaces) != 2 {
+		t.Fatalf("there should be 2 interfaces in the removed scope: %v", report.Interfaces)
+	}
+	if report.Interfaces[0].NodeID != 2 || report.Interfaces[1].NodeID != 3 {
+		t.Errorf("incorrect interfaces: %v", report.Interfaces)
+	}
+
+	received := new(RemovalReport)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(received)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	if err := report.Send(server.URL); err != nil {
+		t.Fatalf("cannot send report: %v", err)
+	}
+	if len(received.Interfaces) != 2 {
+		t.Errorf("incorrect report received: %v", received)
+	}
+}
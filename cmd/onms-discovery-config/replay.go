@@ -0,0 +1,187 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Recording of the raw responses from the API sources, and replay of the recordings,
+// to reproduce the outcome of a past run without access to the APIs (or after they changed)
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// apiTransport is used by the clients of the API sources (OpenNMS, NetBox, AWS, Azure, GCP, Kubernetes, vSphere and Kea)
+var apiTransport http.RoundTripper = http.DefaultTransport
+
+// apiTransportWithTLS returns a transport like apiTransport, but with its own TLS settings to reach the API
+func apiTransportWithTLS(tlsConfig *tls.Config) http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if r, ok := apiTransport.(*APIRecorder); ok {
+		recorder := *r
+		recorder.Transport = transport
+		return &recorder
+	}
+	return transport
+}
+
+// secretFields are removed from the requests to identify them, and redacted from the recorded responses
+var secretFields = []string{"client_secret", "assertion", "access_token"}
+
+// secretPaths are the URL paths whose responses are the credentials themselves (e.x. the session of vSphere), so they are entirely redacted
+var secretPaths = []string{"/api/session"}
+
+type Recording struct {
+	Time        time.Time `json:"time"`
+	Method      string    `json:"method"`
+	URL         string    `json:"url"`
+	Status      int       `json:"status"`
+	ContentType string    `json:"contentType,omitempty"`
+	Body        string    `json:"body"`
+}
+
+// APIRecorder saves the responses of the API requests into a directory, or replays them from it
+type APIRecorder struct {
+	Dir       string
+	Replay    bool
+	Transport http.RoundTripper // Used to reach the APIs while recording
+}
+
+// recordingKey identifies a request by its method, URL and body, ignoring the credentials
+func recordingKey(req *http.Request, body []byte) string {
+	u := *req.URL
+	u.User = nil
+	if form, err := url.ParseQuery(string(body)); err == nil && strings.Contains(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		for _, field := range secretFields {
+			form.Del(field)
+		}
+		body = []byte(form.Encode())
+	}
+	return Checksum([]byte(req.Method + "\n" + u.String() + "\n" + string(body)))[:16]
+}
+
+// redact replaces the values of the secret fields of a JSON object
+func redact(body []byte) []byte {
+	data := make(map[string]interface{})
+	if err := json.Unmarshal(body, &data); err != nil {
+		return body
+	}
+	changed := false
+	for _, field := range secretFields {
+		if _, ok := data[field]; ok {
+			data[field] = "redacted"
+			changed = true
+		}
+	}
+	if !changed {
+		return body
+	}
+	redacted, _ := json.Marshal(data)
+	return redacted
+}
+
+func (r *APIRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	fileName := filepath.Join(r.Dir, recordingKey(req, body)+".json")
+	if r.Replay {
+		data, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return nil, fmt.Errorf("no recording of %s %s in %s", req.Method, req.URL.Redacted(), r.Dir)
+		}
+		rec := new(Recording)
+		if err := json.Unmarshal(data, rec); err != nil {
+			return nil, fmt.Errorf("invalid recording %s: %v", fileName, err)
+		}
+		resp := &http.Response{
+			Status:     fmt.Sprintf("%d %s", rec.Status, http.StatusText(rec.Status)),
+			StatusCode: rec.Status,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(strings.NewReader(rec.Body)),
+			Request:    req,
+		}
+		if rec.ContentType != "" {
+			resp.Header.Set("Content-Type", rec.ContentType)
+		}
+		return resp, nil
+	}
+
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	u := *req.URL
+	u.User = nil
+	recorded := redact(data)
+	for _, path := range secretPaths {
+		if u.Path == path {
+			recorded = []byte(`"redacted"`)
+		}
+	}
+	rec := Recording{
+		Time:        time.Now(),
+		Method:      req.Method,
+		URL:         u.String(),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        string(recorded),
+	}
+	recData, _ := json.MarshalIndent(rec, "", "  ")
+	if err := ioutil.WriteFile(fileName, recData, 0600); err != nil {
+		log.Printf("cannot record %s %s: %v", req.Method, u.String(), err)
+	}
+	return resp, nil
+}
+
+// setupRecorder installs the recorder for the API sources based on the options
+func (o *Options) setupRecorder() error {
+	apiTransport = http.DefaultTransport
+	if o.RecordAPIs != "" && o.ReplayAPIs != "" {
+		return fmt.Errorf("'record-apis' and 'replay-apis' are mutually exclusive")
+	}
+	if o.RecordAPIs != "" {
+		if err := os.MkdirAll(o.RecordAPIs, 0700); err != nil {
+			return fmt.Errorf("cannot create directory %s: %v", o.RecordAPIs, err)
+		}
+		log.Printf("recording the responses from the API sources into %s", o.RecordAPIs)
+		apiTransport = &APIRecorder{Dir: o.RecordAPIs}
+	}
+	if o.ReplayAPIs != "" {
+		if _, err := os.Stat(o.ReplayAPIs); err != nil {
+			return fmt.Errorf("cannot find recordings: %v", err)
+		}
+		log.Printf("replaying the responses of the API sources from %s", o.ReplayAPIs)
+		apiTransport = &APIRecorder{Dir: o.ReplayAPIs, Replay: true}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_recordings")
+	if err != nil {
+		t.Fatalf("cannot create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() { apiTransport = http.DefaultTransport }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/ipam/prefixes/":
+			w.Write([]byte(`{"next":null,"results":[{"prefix":"10.0.0.0/24"}]}`))
+		case "/tenant/oauth2/v2.0/token":
+			w.Write([]byte(`{"access_token":"my-token","expires_in":3600}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	if err := (&Options{RecordAPIs: dir}).setupRecorder(); err != nil {
+		t.Fatalf("cannot setup recorder: %v", err)
+	}
+	prefixes, err := NewNetBoxClient(server.URL, "my-token").GetPrefixes("status=active")
+	if err != nil || len(prefixes) != 1 {
+		t.Fatalf("cannot get prefixes: %v %v", prefixes, err)
+	}
+	form := url.Values{"client_id": {"id"}, "client_secret": {"secret"}}
+	resp, err := (&http.Client{Transport: apiTransport}).PostForm(server.URL+"/tenant/oauth2/v2.0/token", form)
+	if err != nil {
+		t.Fatalf("cannot get token: %v", err)
+	}
+	resp.Body.Close()
+	server.Close()
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 2 {
+		t.Fatalf("there should be 2 recordings: %d", len(files))
+	}
+	for _, f := range files {
+		data, _ := ioutil.ReadFile(dir + "/" + f.Name())
+		if strings.Contains(string(data), "my-token") || strings.Contains(string(data), "secret") {
+			t.Errorf("the credentials should not be recorded: %s", string(data))
+		}
+	}
+
+	if err := (&Options{ReplayAPIs: dir}).setupRecorder(); err != nil {
+		t.Fatalf("cannot setup replay: %v", err)
+	}
+	client := NewNetBoxClient(server.URL, "another-token")
+	if replayed, err := client.GetPrefixes("status=active"); err != nil || len(replayed) != 1 || replayed[0] != "10.0.0.0/24" {
+		t.Errorf("the prefixes should be replayed: %v %v", replayed, err)
+	}
+	form.Set("client_secret", "another-secret")
+	if resp, err := (&http.Client{Transport: apiTransport}).PostForm(server.URL+"/tenant/oauth2/v2.0/token", form); err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("the token request should be replayed regardless of the secret: %v", err)
+	}
+	if _, err := client.GetPrefixes("status=reserved"); err == nil || !strings.Contains(err.Error(), "no recording") {
+		t.Errorf("requests without recordings should fail: %v", err)
+	}
+
+	if err := (&Options{RecordAPIs: dir, ReplayAPIs: dir}).setupRecorder(); err == nil {
+		t.Errorf("recording and replaying should be mutually exclusive")
+	}
+}
+
+func TestAPITransportWithTLS(t *testing.T) {
+	defer func(old http.RoundTripper) { apiTransport = old }(apiTransport)
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	apiTransport = http.DefaultTransport
+	if transport, ok := apiTransportWithTLS(tlsConfig).(*http.Transport); !ok || transport.TLSClientConfig != tlsConfig {
+		t.Errorf("the transport should use the TLS settings")
+	}
+	apiTransport = &APIRecorder{Dir: t.TempDir()}
+	recorder, ok := apiTransportWithTLS(tlsConfig).(*APIRecorder)
+	if !ok {
+		t.Fatalf("the recorder should be kept")
+	}
+	if transport, ok := recorder.Transport.(*http.Transport); !ok || transport.TLSClientConfig != tlsConfig {
+		t.Errorf("the recorder should use the TLS settings")
+	}
+	if apiTransport.(*APIRecorder).Transport != nil {
+		t.Errorf("the shared recorder should not change")
+	}
+}
@@ -0,0 +1,231 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Requisitions with a node per specific, carrying the tags of the inputs as node meta-data, as an alternative to Discovery
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+// DefaultMetaDataContext is the context of the node meta-data defined in the requisitions
+const DefaultMetaDataContext = "requisition"
+
+// inputMetaData has the tags of the input being processed by source, and nodeMetaData the tags of each added address
+var inputMetaData = make(map[string]map[string]string)
+var nodeMetaData = make(map[string]map[string]string)
+
+// nodeLabels has the forward name of the added addresses, when the input knows it
+var nodeLabels = make(map[string]string)
+
+// setInputMetaData sets the tags of the addresses that the given source adds from now on
+func setInputMetaData(source string, input *InputFile) {
+	inputMetaData[source] = input.MetaData
+}
+
+// recordNode keeps the tags of the source and the name of an address added to the configuration
+func recordNode(ip, source, name string) {
+	nodeMetaData[ip] = inputMetaData[source]
+	if name != "" {
+		nodeLabels[ip] = name
+	}
+}
+
+type RequisitionMetaData struct {
+	XMLName xml.Name `xml:"meta-data"`
+	Context string   `xml:"context,attr"`
+	Key     string   `xml:"key,attr"`
+	Value   string   `xml:"value,attr"`
+}
+
+type RequisitionInterface struct {
+	XMLName     xml.Name `xml:"interface"`
+	IPAddress   string   `xml:"ip-addr,attr"`
+	SnmpPrimary string   `xml:"snmp-primary,attr"`
+	Status      int      `xml:"status,attr"`
+}
+
+type RequisitionNode struct {
+	XMLName    xml.Name               `xml:"node"`
+	ForeignID  string                 `xml:"foreign-id,attr"`
+	NodeLabel  string                 `xml:"node-label,attr"`
+	Location   string                 `xml:"location,attr,omitempty"`
+	Interfaces []RequisitionInterface `xml:"interface"`
+	MetaData   []RequisitionMetaData  `xml:"meta-data,omitempty"`
+}
+
+// Requisition is the model-import of a foreign source
+type Requisition struct {
+	XMLName       xml.Name          `xml:"http://xmlns.opennms.org/xsd/config/model-import model-import"`
+	ForeignSource string            `xml:"foreign-source,attr"`
+	DateStamp     string            `xml:"date-stamp,attr,omitempty"`
+	Nodes         []RequisitionNode `xml:"node"`
+}
+
+// RequisitionSettings controls how the specifics are turned into nodes
+type RequisitionSettings struct {
+	ForeignSource string // For the specifics without a foreign source
+	Context       string // Context of the meta-data; DefaultMetaDataContext when empty
+}
+
+// foreignID returns a foreign ID for an address, as the colons of the IPv6 addresses are not accepted
+func foreignID(ip string) string {
+	return strings.ReplaceAll(ip, ":", "-")
+}
+
+// BuildRequisitions returns a requisition per foreign source, sorted by name, with a node per specific of the configuration.
+// The foreign source and the location of each node are the ones of the specific or its definition, and its meta-data
+// are the tags of the input that added it.
+func BuildRequisitions(cfg *discovery.DiscoveryConfiguration, settings RequisitionSettings, metaData map[string]map[string]string, labels map[string]string) []*Requisition {
+	context := settings.Context
+	if context == "" {
+		context = DefaultMetaDataContext
+	}
+	now := time.Now().Format(time.RFC3339)
+	bySource := make(map[string]*Requisition)
+	seen := make(map[string]bool)
+	for _, d := range cfg.Definitions {
+		for _, s := range d.Specifics {
+			ip := s.IP.String()
+			source := s.ForeignSource
+			if source == "" {
+				source = d.ForeignSource
+			}
+			if source == "" {
+				source = settings.ForeignSource
+			}
+			if seen[source+"/"+ip] {
+				continue
+			}
+			seen[source+"/"+ip] = true
+			req, ok := bySource[source]
+			if !ok {
+				req = &Requisition{ForeignSource: source, DateStamp: now}
+				bySource[source] = req
+			}
+			node := RequisitionNode{
+				ForeignID:  foreignID(ip),
+				NodeLabel:  ip,
+				Location:   s.Location,
+				Interfaces: []RequisitionInterface{{IPAddress: ip, SnmpPrimary: "P", Status: 1}},
+			}
+			if node.Location == "" {
+				node.Location = d.Location
+			}
+			if label, ok := labels[ip]; ok {
+				node.NodeLabel = label
+			}
+			keys := make([]string, 0, len(metaData[ip]))
+			for key := range metaData[ip] {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				node.MetaData = append(node.MetaData, RequisitionMetaData{Context: context, Key: key, Value: metaData[ip][key]})
+			}
+			req.Nodes = append(req.Nodes, node)
+		}
+	}
+	list := make([]*Requisition, 0, len(bySource))
+	for _, req := range bySource {
+		list = append(list, req)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ForeignSource < list[j].ForeignSource })
+	return list
+}
+
+// String returns the requisition as XML
+func (r *Requisition) String() string {
+	data, err := xml.MarshalIndent(r, "", "   ")
+	if err != nil {
+		return fmt.Sprintf("cannot marshal requisition %s: %v", r.ForeignSource, err)
+	}
+	return string(data)
+}
+
+// SaveRequisitions writes each requisition to the directory, named after its foreign source
+func SaveRequisitions(dir string, requisitions []*Requisition) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create directory %s: %v", dir, err)
+	}
+	for _, req := range requisitions {
+		if strings.ContainsAny(req.ForeignSource, `/\`) {
+			return fmt.Errorf("invalid foreign source %s for a file name", req.ForeignSource)
+		}
+		file := filepath.Join(dir, req.ForeignSource+".xml")
+		if err := ioutil.WriteFile(file, []byte(xml.Header+req.String()+"\n"), 0644); err != nil {
+			return fmt.Errorf("cannot save requisition %s: %v", req.ForeignSource, err)
+		}
+	}
+	return nil
+}
+
+// PushRequisition replaces the requisition in OpenNMS via /rest/requisitions, and then imports it
+func (c *RestClient) PushRequisition(req *Requisition) error {
+	data, err := xml.Marshal(req)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Do(http.MethodPost, "/rest/requisitions", "application/xml", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	msg, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("POST /rest/requisitions failed with %s: %s", resp.Status, string(msg))
+	}
+	path := "/rest/requisitions/" + url.PathEscape(req.ForeignSource) + "/import?rescanExisting=false"
+	resp, err = c.Do(http.MethodPut, path, "", nil)
+	if err != nil {
+		return err
+	}
+	msg, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("PUT %s failed with %s: %s", path, resp.Status, string(msg))
+	}
+	return nil
+}
+
+// deployRequisitions saves or imports the requisitions built from the generated configuration, instead of updating Discovery
+func deployRequisitions(opts *Options) error {
+	settings := RequisitionSettings{ForeignSource: opts.RequisitionSource, Context: opts.RequisitionContext}
+	requisitions := BuildRequisitions(baseConfig, settings, nodeMetaData, nodeLabels)
+	for _, req := range requisitions {
+		log.Printf("requisition %s with %d nodes", req.ForeignSource, len(req.Nodes))
+	}
+	if opts.RequisitionDir != "" {
+		if err := SaveRequisitions(opts.RequisitionDir, requisitions); err != nil {
+			return err
+		}
+		log.Printf("%d requisitions saved at %s", len(requisitions), opts.RequisitionDir)
+	}
+	if opts.DryRun || !opts.RequisitionImport {
+		return nil
+	}
+	if opts.RestURL == "" {
+		return fmt.Errorf("the ReST URL is required to import the requisitions")
+	}
+	client := opts.restClient()
+	for _, req := range requisitions {
+		if err := client.PushRequisition(req); err != nil {
+			return fmt.Errorf("cannot import requisition %s: %v", req.ForeignSource, err)
+		}
+		log.Printf("requisition %s imported", req.ForeignSource)
+	}
+	return nil
+}
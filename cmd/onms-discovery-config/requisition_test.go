@@ -0,0 +1,116 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestBuildRequisitions(t *testing.T) {
+	cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{
+		{Location: "Paris", Specifics: []discovery.Specific{
+			{IP: net.ParseIP("10.0.0.1")},
+			{IP: net.ParseIP("10.0.0.2"), ForeignSource: "Servers"},
+		}},
+		{ForeignSource: "Servers", Specifics: []discovery.Specific{{IP: net.ParseIP("2001:db8::1")}}},
+	}}
+	metaData := map[string]map[string]string{"10.0.0.1": {"owner": "NetOps", "site": "DC/East"}}
+	labels := map[string]string{"10.0.0.2": "web01.example.com"}
+	requisitions := BuildRequisitions(cfg, RequisitionSettings{ForeignSource: "Discovery"}, metaData, labels)
+	if len(requisitions) != 2 || requisitions[0].ForeignSource != "Discovery" || requisitions[1].ForeignSource != "Servers" {
+		t.Fatalf("there should be a requisition per foreign source: %v", requisitions)
+	}
+	node := requisitions[0].Nodes[0]
+	if node.ForeignID != "10.0.0.1" || node.Location != "Paris" || len(node.Interfaces) != 1 || node.Interfaces[0].IPAddress != "10.0.0.1" {
+		t.Errorf("unexpected node: %+v", node)
+	}
+	if len(node.MetaData) != 2 || node.MetaData[0] != (RequisitionMetaData{Context: DefaultMetaDataContext, Key: "owner", Value: "NetOps"}) || node.MetaData[1].Value != "DC/East" {
+		t.Errorf("the tags of the input should be the meta-data of the node: %+v", node.MetaData)
+	}
+	servers := requisitions[1].Nodes
+	if len(servers) != 2 || servers[0].NodeLabel != "web01.example.com" || servers[1].ForeignID != "2001-db8--1" {
+		t.Errorf("unexpected nodes: %+v", servers)
+	}
+
+	dir := t.TempDir()
+	if err := SaveRequisitions(dir, requisitions); err != nil {
+		t.Fatalf("cannot save requisitions: %v", err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "Discovery.xml"))
+	if err != nil {
+		t.Fatalf("cannot read requisition: %v", err)
+	}
+	if !strings.Contains(string(data), `<meta-data context="requisition" key="owner" value="NetOps"></meta-data>`) {
+		t.Errorf("the meta-data should be in the requisition: %s", data)
+	}
+	parsed := &Requisition{}
+	if err := xml.Unmarshal(data, parsed); err != nil || len(parsed.Nodes) != 1 {
+		t.Errorf("cannot parse requisition: %v", err)
+	}
+}
+
+func TestInputMetaData(t *testing.T) {
+	dir := t.TempDir()
+	list := filepath.Join(dir, "list.txt")
+	ioutil.WriteFile(list, []byte("10.0.0.1\n"), 0644)
+	input, err := ParseInputFile(list + ":meta.owner=NetOps,location=Paris,meta.site=DC/East")
+	if err != nil {
+		t.Fatalf("cannot parse input: %v", err)
+	}
+	if input.Path != list || input.Attributes.Location != "Paris" || input.MetaData["owner"] != "NetOps" || input.MetaData["site"] != "DC/East" {
+		t.Errorf("unexpected input: %+v", input)
+	}
+	if _, err := ParseInputFile(list + ":meta.=x"); err == nil {
+		t.Errorf("an empty meta-data key should fail")
+	}
+
+	defer func() {
+		nodeMetaData, nodeLabels, inputMetaData = make(map[string]map[string]string), make(map[string]string), make(map[string]map[string]string)
+	}()
+	setInputMetaData("inc-list", input)
+	recordNode("10.0.0.1", "inc-list", "")
+	setInputMetaData("inc-list", &InputFile{Path: list})
+	recordNode("10.0.0.2", "inc-list", "router.example.com")
+	if nodeMetaData["10.0.0.1"]["owner"] != "NetOps" || len(nodeMetaData["10.0.0.2"]) != 0 || nodeLabels["10.0.0.2"] != "router.example.com" {
+		t.Errorf("the tags should follow the input being processed: %v %v", nodeMetaData, nodeLabels)
+	}
+}
+
+func TestPushRequisition(t *testing.T) {
+	var received Requisition
+	imported := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/requisitions":
+			data, _ := ioutil.ReadAll(r.Body)
+			xml.Unmarshal(data, &received)
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/import"):
+			imported = r.URL.Path
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	req := &Requisition{ForeignSource: "Branch Offices", Nodes: []RequisitionNode{{ForeignID: "10.0.0.1", NodeLabel: "10.0.0.1"}}}
+	if err := NewRestClient(server.URL, "", "").PushRequisition(req); err != nil {
+		t.Fatalf("cannot push requisition: %v", err)
+	}
+	if received.ForeignSource != "Branch Offices" || len(received.Nodes) != 1 {
+		t.Errorf("the requisition was not received: %+v", received)
+	}
+	if imported != "/rest/requisitions/Branch Offices/import" {
+		t.Errorf("the requisition was not imported: %s", imported)
+	}
+}
@@ -0,0 +1,174 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Minimal client for the OpenNMS ReST API
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type RestClient struct {
+	URL      string // e.x. http://localhost:8980/opennms
+	User     string
+	Password string
+	Token    string // Bearer token, used instead of the user and password when provided
+	Client   *http.Client
+}
+
+func NewRestClient(url, user, password string) *RestClient {
+	return &RestClient{
+		URL:      strings.TrimSuffix(url, "/"),
+		User:     user,
+		Password: password,
+		Client:   &http.Client{Timeout: 60 * time.Second, Transport: apiTransport},
+	}
+}
+
+// restClient returns the ReST client based on the options
+func (o *Options) restClient() *RestClient {
+	client := NewRestClient(o.RestURL, o.RestUser, o.RestPassword)
+	client.Token = o.RestToken
+	return client
+}
+
+// Do sends a request to the given path (relative to the base URL) with the credentials, honoring the lookup limits
+func (c *RestClient) Do(method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.URL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	} else if c.User != "" {
+		req.SetBasicAuth(c.User, c.Password)
+	}
+	var resp *http.Response
+	err = lookupThrottle.Do(func() error {
+		var e error
+		resp, e = c.Client.Do(req)
+		return e
+	})
+	return resp, err
+}
+
+// Get sends a GET request to the given path (relative to the base URL) and decodes the JSON response into target
+func (c *RestClient) Get(path string, target interface{}) error {
+	resp, err := c.Do(http.MethodGet, path, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("GET %s failed with %s: %s", path, resp.Status, string(body))
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+type IPInterface struct {
+	IPAddress     string `json:"ipAddress"`
+	NodeID        int    `json:"nodeId,omitempty"`
+	ForeignSource string `json:"foreignSource,omitempty"` // Only for mock-onms, as OpenNMS filters by it on the server side
+}
+
+// GetIPInterfaces returns all the IP interfaces from the provisioned nodes
+func (c *RestClient) GetIPInterfaces() ([]IPInterface, error) {
+	return c.searchIPInterfaces(nil)
+}
+
+// searchIPInterfaces returns the IP interfaces from the nodes of the given foreign sources, or all of them when empty;
+// the releases without the v2 API are queried node by node
+func (c *RestClient) searchIPInterfaces(foreignSources []string) ([]IPInterface, error) {
+	if !target.Supports(FeatureIPInterfacesAPI) {
+		return c.searchIPInterfacesV1(foreignSources)
+	}
+	conditions := make([]string, 0, len(foreignSources))
+	for _, fs := range foreignSources {
+		conditions = append(conditions, "node.foreignSource=="+fs)
+	}
+	data := struct {
+		Interfaces []IPInterface `json:"ipInterface"`
+	}{}
+	path := "/api/v2/ipinterfaces?limit=0"
+	if len(conditions) > 0 {
+		path += "&_s=" + url.QueryEscape(strings.Join(conditions, ",")) // Comma is OR in FIQL
+	}
+	if err := c.Get(path, &data); err != nil {
+		return nil, err
+	}
+	return data.Interfaces, nil
+}
+
+// searchIPInterfacesV1 is like searchIPInterfaces, through /rest/nodes
+func (c *RestClient) searchIPInterfacesV1(foreignSources []string) ([]IPInterface, error) {
+	paths := []string{"/rest/nodes?limit=0"}
+	if len(foreignSources) > 0 {
+		paths = paths[:0]
+		for _, fs := range foreignSources {
+			paths = append(paths, "/rest/nodes?limit=0&foreignSource="+url.QueryEscape(fs))
+		}
+	}
+	interfaces := make([]IPInterface, 0)
+	for _, path := range paths {
+		nodes := struct {
+			Nodes []struct {
+				ID            json.Number `json:"id"`
+				ForeignSource string      `json:"foreignSource"`
+			} `json:"node"`
+		}{}
+		if err := c.Get(path, &nodes); err != nil {
+			return nil, err
+		}
+		for _, node := range nodes.Nodes {
+			data := struct {
+				Interfaces []IPInterface `json:"ipInterface"`
+			}{}
+			if err := c.Get("/rest/nodes/"+node.ID.String()+"/ipinterfaces?limit=0", &data); err != nil {
+				return nil, err
+			}
+			id, _ := node.ID.Int64()
+			for _, intf := range data.Interfaces {
+				intf.NodeID = int(id)
+				intf.ForeignSource = node.ForeignSource
+				interfaces = append(interfaces, intf)
+			}
+		}
+	}
+	return interfaces, nil
+}
+
+// GetIPAddresses returns the unique IP addresses of all the interfaces from the provisioned nodes
+func (c *RestClient) GetIPAddresses() ([]string, error) {
+	interfaces, err := c.GetIPInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	return uniqueAddresses(interfaces), nil
+}
+
+func uniqueAddresses(interfaces []IPInterface) []string {
+	addresses := make([]string, 0, len(interfaces))
+	seen := make(map[string]bool)
+	for _, intf := range interfaces {
+		if !seen[intf.IPAddress] {
+			seen[intf.IPAddress] = true
+			addresses = append(addresses, intf.IPAddress)
+		}
+	}
+	return addresses
+}
@@ -0,0 +1,68 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestGetIPAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "admin" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/opennms/api/v2/ipinterfaces" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"count":3,"totalCount":3,"offset":0,"ipInterface":[{"ipAddress":"10.0.0.1"},{"ipAddress":"10.0.0.2"},{"ipAddress":"10.0.0.1"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewRestClient(server.URL+"/opennms/", "admin", "admin")
+	addresses, err := client.GetIPAddresses()
+	if err != nil {
+		t.Fatalf("cannot get addresses: %v", err)
+	}
+	if len(addresses) != 2 {
+		t.Errorf("there should be 2 unique addresses: %v", addresses)
+	}
+
+	client.Password = "wrong"
+	if _, err := client.GetIPAddresses(); err == nil {
+		t.Errorf("the request should fail with wrong credentials")
+	}
+}
+
+func TestExcludeExistingNodes(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_nodes")
+	if err != nil {
+		t.Fatalf("cannot create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	list := filepath.Join(dir, "ips.txt")
+	ioutil.WriteFile(list, []byte("10.0.0.1\n10.0.0.3\n"), 0644)
+
+	addressBlackList = make(map[string]bool)
+	addressWhiteList = make(map[string]string)
+	summary = NewSummary()
+	opts := &Options{IncludeList: list, provisioned: []string{"10.0.0.1", "10.0.0.2"}}
+	def := &discovery.Definition{}
+	if err := buildDefinition(opts, def, newStreamedSet()); err != nil {
+		t.Fatalf("cannot build definition: %v", err)
+	}
+	if len(def.Specifics) != 1 || def.Specifics[0].IP.String() != "10.0.0.3" {
+		t.Errorf("the addresses of the existing nodes should be skipped: %s", def.String())
+	}
+	if summary.Skipped[SkipBlacklisted] != 1 {
+		t.Errorf("the existing node should be reported as blacklisted: %v", summary.Skipped)
+	}
+}
@@ -0,0 +1,168 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Update of the discovery configuration through the OpenNMS ReST API, for when the tool runs off-box
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/events"
+)
+
+const discoveryConfigFile = "discovery-configuration.xml"
+
+// The copy of the last configuration deployed via ReST, kept next to it, as the base to detect and merge the manual changes
+const deployedConfigFile = discoveryConfigFile + ".deployed"
+
+// GetFile returns the content of a configuration file from $OPENNMS_HOME/etc via /rest/filesystem;
+// the error wraps os.ErrNotExist when the file doesn't exist.
+func (c *RestClient) GetFile(name string) ([]byte, error) {
+	path := "/rest/filesystem/contents?f=" + url.QueryEscape(name)
+	resp, err := c.Do(http.MethodGet, path, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("GET %s failed: %w", path, os.ErrNotExist)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s failed with %s: %s", path, resp.Status, string(body))
+	}
+	return body, nil
+}
+
+// PutFile replaces the content of a configuration file from $OPENNMS_HOME/etc via /rest/filesystem
+func (c *RestClient) PutFile(name string, data []byte) error {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("upload", name)
+	if err != nil {
+		return err
+	}
+	part.Write(data)
+	if err := w.Close(); err != nil {
+		return err
+	}
+	path := "/rest/filesystem/contents?f=" + url.QueryEscape(name)
+	resp, err := c.Do(http.MethodPost, path, w.FormDataContentType(), &body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("POST %s failed with %s: %s", path, resp.Status, string(msg))
+	}
+	return nil
+}
+
+// SendEvent sends an event via /rest/events
+func (c *RestClient) SendEvent(event events.Event) error {
+	data, err := xml.Marshal(event)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Do(http.MethodPost, "/rest/events", "application/xml", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusNoContent {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("POST /rest/events failed with %s: %s", resp.Status, string(msg))
+	}
+	return nil
+}
+
+// UpdateOpenNMSViaRest writes the configuration and asks Discovery to reload it through the ReST API,
+// with the same transactional semantics of UpdateOpenNMSWithPolicy.
+func UpdateOpenNMSViaRest(cfg *discovery.DiscoveryConfiguration, client *RestClient, policy UpdatePolicy) error {
+	currentBytes, err := client.GetFile(discoveryConfigFile)
+	if err != nil {
+		return fmt.Errorf("cannot read discovery configuration: %v", err)
+	}
+	output := renderConfiguration(cfg)
+	if output == "" {
+		return fmt.Errorf("cannot render the discovery configuration")
+	}
+	if output == renderCurrent(currentBytes) {
+		return ErrNoChanges
+	}
+	if err := client.PutFile(discoveryConfigFile, []byte(generation.Comment()+output)); err != nil {
+		return fmt.Errorf("cannot write discovery configuration: %v", err)
+	}
+	reload := policy.Reload
+	if reload == nil {
+		reload = func() error { return client.SendEvent(reloadEvent()) }
+	}
+	rollback := func() error { return client.PutFile(discoveryConfigFile, currentBytes) }
+	return reloadOrRollback(reload, rollback, policy)
+}
+
+// VerifyDeployedViaRest compares the current configuration against the copy of the last deployment via ReST.
+// Nothing is verified when there are no records from a previous deployment.
+func VerifyDeployedViaRest(client *RestClient) error {
+	deployed, err := client.GetFile(deployedConfigFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read the last deployed configuration: %v", err)
+	}
+	current, err := client.GetFile(discoveryConfigFile)
+	if err != nil {
+		return fmt.Errorf("cannot read discovery configuration: %v", err)
+	}
+	if Checksum(current) != Checksum(deployed) {
+		return ErrManualChanges
+	}
+	return nil
+}
+
+// RecordDeployedViaRest saves a copy of the current configuration next to it, via ReST
+func RecordDeployedViaRest(client *RestClient) error {
+	current, err := client.GetFile(discoveryConfigFile)
+	if err != nil {
+		return fmt.Errorf("cannot read discovery configuration: %v", err)
+	}
+	if err := client.PutFile(deployedConfigFile, current); err != nil {
+		return fmt.Errorf("cannot save a copy of the deployed configuration: %v", err)
+	}
+	return nil
+}
+
+// MergeManualChangesViaRest is like MergeManualChanges, for the configuration deployed via ReST
+func MergeManualChangesViaRest(client *RestClient, generated *discovery.DiscoveryConfiguration) (*discovery.DiscoveryConfiguration, []string, error) {
+	data, err := client.GetFile(deployedConfigFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot load the last deployed configuration: %v", err)
+	}
+	base, err := parseConfiguration(deployedConfigFile, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if data, err = client.GetFile(discoveryConfigFile); err != nil {
+		return nil, nil, fmt.Errorf("cannot load the current configuration: %v", err)
+	}
+	current, err := parseConfiguration(discoveryConfigFile, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	merged, conflicts := discovery.ThreeWayMerge(base, current, generated)
+	return merged, conflicts, nil
+}
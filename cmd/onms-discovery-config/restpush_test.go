@@ -0,0 +1,140 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/events"
+)
+
+// mockFilesystem emulates /rest/filesystem with the given files, and /rest/events, failing the events when required
+func mockFilesystem(t *testing.T, files map[string]string, sent *[]events.Event, failEvents bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer my-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/rest/filesystem/contents" && r.Method == http.MethodGet:
+			content, ok := files[r.URL.Query().Get("f")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(content))
+		case r.URL.Path == "/rest/filesystem/contents" && r.Method == http.MethodPost:
+			file, _, err := r.FormFile("upload")
+			if err != nil {
+				t.Errorf("cannot get uploaded file: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			data, _ := ioutil.ReadAll(file)
+			files[r.URL.Query().Get("f")] = string(data)
+		case r.URL.Path == "/rest/events" && r.Method == http.MethodPost:
+			if failEvents {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			data, _ := ioutil.ReadAll(r.Body)
+			event := events.Event{}
+			xml.Unmarshal(data, &event)
+			*sent = append(*sent, event)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestUpdateOpenNMSViaRest(t *testing.T) {
+	files := map[string]string{discoveryConfigFile: "<discovery-configuration/>"}
+	sent := make([]events.Event, 0)
+	server := mockFilesystem(t, files, &sent, false)
+	defer server.Close()
+
+	client := NewRestClient(server.URL, "", "")
+	client.Token = "my-token"
+	cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{{}}}
+	cfg.Definitions[0].AddSpecific("10.0.0.1")
+	if err := UpdateOpenNMSViaRest(cfg, client, UpdatePolicy{}); err != nil {
+		t.Fatalf("cannot update configuration: %v", err)
+	}
+	if !strings.Contains(files[discoveryConfigFile], "10.0.0.1") {
+		t.Errorf("the configuration was not updated: %s", files[discoveryConfigFile])
+	}
+	if len(sent) != 1 || sent[0].UEI != "uei.opennms.org/internal/reloadDaemonConfig" {
+		t.Errorf("the reload event was not sent: %v", sent)
+	}
+	if err := UpdateOpenNMSViaRest(cfg, client, UpdatePolicy{}); err != ErrNoChanges {
+		t.Errorf("there should be no changes: %v", err)
+	}
+}
+
+func TestUpdateOpenNMSViaRestRollback(t *testing.T) {
+	files := map[string]string{discoveryConfigFile: "<discovery-configuration/>"}
+	sent := make([]events.Event, 0)
+	server := mockFilesystem(t, files, &sent, true)
+	defer server.Close()
+
+	client := NewRestClient(server.URL, "", "")
+	client.Token = "my-token"
+	cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{{}}}
+	cfg.Definitions[0].AddSpecific("10.0.0.1")
+	if err := UpdateOpenNMSViaRest(cfg, client, UpdatePolicy{Retries: 1, Rollback: true}); err == nil {
+		t.Fatalf("the update should fail")
+	}
+	if files[discoveryConfigFile] != "<discovery-configuration/>" {
+		t.Errorf("the previous configuration should be restored: %s", files[discoveryConfigFile])
+	}
+}
+
+func TestDeployViaRestManualChanges(t *testing.T) {
+	files := map[string]string{discoveryConfigFile: "<discovery-configuration/>"}
+	sent := make([]events.Event, 0)
+	server := mockFilesystem(t, files, &sent, false)
+	defer server.Close()
+
+	opts := &Options{RestPush: true, RestURL: server.URL, RestToken: "my-token"}
+	cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{{}}}
+	cfg.Definitions[0].AddSpecific("10.0.0.1")
+	if err := opts.deployViaRest(cfg); err != nil {
+		t.Fatalf("cannot deploy configuration: %v", err)
+	}
+	if files[deployedConfigFile] != files[discoveryConfigFile] {
+		t.Errorf("the deployed configuration should be recorded: %s", files[deployedConfigFile])
+	}
+
+	manual := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{{}}}
+	manual.Definitions[0].AddSpecific("10.0.0.1")
+	manual.Definitions[0].AddSpecific("10.0.0.100")
+	files[discoveryConfigFile] = manual.String()
+	cfg.Definitions[0].AddSpecific("10.0.0.2")
+	if err := opts.deployViaRest(cfg); !errors.Is(err, ErrManualChanges) {
+		t.Fatalf("the manual changes should be detected: %v", err)
+	}
+	if files[discoveryConfigFile] != manual.String() {
+		t.Errorf("the manual changes should not be overwritten")
+	}
+
+	opts.MergeManual = true
+	if err := opts.deployViaRest(cfg); err != nil {
+		t.Fatalf("cannot deploy the merged configuration: %v", err)
+	}
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.100"} {
+		if !strings.Contains(files[discoveryConfigFile], ip) {
+			t.Errorf("the merged configuration should contain %s: %s", ip, files[discoveryConfigFile])
+		}
+	}
+	if err := VerifyDeployedViaRest(opts.restClient()); err != nil {
+		t.Errorf("the merged configuration should be recorded: %v", err)
+	}
+}
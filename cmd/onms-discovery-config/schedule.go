@@ -0,0 +1,315 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Schedule expressions and blackout windows evaluated in a time zone, so OpenNMS is only updated during approved change windows
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+var dayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// parseCronValue parses a number or a name, where names[i] corresponds to min+i
+func parseCronValue(value string, min int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(value, name) {
+			return min + i, nil
+		}
+	}
+	return strconv.Atoi(value)
+}
+
+// parseCronField parses a comma separated list of values, ranges and steps (e.x. 1,5-10,*/15) into the allowed values between min and max
+func parseCronField(field string, min, max int, names []string) ([]bool, error) {
+	allowed := make([]bool, max+1)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[idx+1:]); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in '%s'", part)
+			}
+			part = part[:idx]
+		}
+		begin, end := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if begin, err = parseCronValue(bounds[0], min, names); err != nil {
+				return nil, fmt.Errorf("invalid value '%s'", bounds[0])
+			}
+			end = begin
+			if len(bounds) == 2 {
+				if end, err = parseCronValue(bounds[1], min, names); err != nil {
+					return nil, fmt.Errorf("invalid value '%s'", bounds[1])
+				}
+			} else if step > 1 {
+				end = max
+			}
+		}
+		if begin < min || end > max || begin > end {
+			return nil, fmt.Errorf("invalid range '%s'; values must be between %d and %d", part, min, max)
+		}
+		for v := begin; v <= end; v += step {
+			allowed[v] = true
+		}
+	}
+	return allowed, nil
+}
+
+// Schedule is a cron expression with minute, hour, day of month, month and day of week
+type Schedule struct {
+	Expression string
+	minutes    []bool
+	hours      []bool
+	days       []bool
+	months     []bool
+	weekdays   []bool
+	anyDay     bool // The day of month is not restricted
+	anyWeekday bool // The day of week is not restricted
+}
+
+// ParseSchedule parses a cron expression; like cron, when both the day of month and the day of week are restricted, either can match
+func ParseSchedule(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule '%s'; expected minute, hour, day of month, month and day of week", expr)
+	}
+	s := &Schedule{Expression: expr, anyDay: fields[2] == "*", anyWeekday: fields[4] == "*"}
+	var err error
+	if s.minutes, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("invalid minute in schedule '%s': %v", expr, err)
+	}
+	if s.hours, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("invalid hour in schedule '%s': %v", expr, err)
+	}
+	if s.days, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("invalid day of month in schedule '%s': %v", expr, err)
+	}
+	if s.months, err = parseCronField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("invalid month in schedule '%s': %v", expr, err)
+	}
+	if s.weekdays, err = parseCronField(fields[4], 0, 7, dayNames); err != nil {
+		return nil, fmt.Errorf("invalid day of week in schedule '%s': %v", expr, err)
+	}
+	s.weekdays[0] = s.weekdays[0] || s.weekdays[7] // Both 0 and 7 are Sunday
+	return s, nil
+}
+
+func (s *Schedule) matchesDay(t time.Time) bool {
+	day, weekday := s.days[t.Day()], s.weekdays[t.Weekday()]
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	}
+	return day || weekday
+}
+
+// Next returns the first time after the given one that matches the schedule, in the location of the given time;
+// it returns the zero time when nothing matches within the next five years (e.x. February 30th).
+func (s *Schedule) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !s.months[t.Month()]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !s.hours[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case !s.minutes[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Blackout is a daily window in which OpenNMS must not be updated; it can cross midnight (e.x. 22:00-02:00)
+type Blackout struct {
+	Spec     string
+	weekdays []bool        // Days in which the window starts
+	start    time.Duration // Offset from midnight
+	end      time.Duration
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		if value != "24:00" {
+			return 0, fmt.Errorf("invalid time '%s'; expected HH:MM", value)
+		}
+		return 24 * time.Hour, nil
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// ParseBlackout parses a window with optional days and the start and end times; e.x. 'mon-fri 08:00-18:00', 'sat,sun 00:00-24:00' or '22:00-02:00' for every day
+func ParseBlackout(spec string) (*Blackout, error) {
+	fields := strings.Fields(spec)
+	days := "*"
+	switch len(fields) {
+	case 1:
+	case 2:
+		days = fields[0]
+	default:
+		return nil, fmt.Errorf("invalid blackout '%s'; expected [days] HH:MM-HH:MM", spec)
+	}
+	b := &Blackout{Spec: spec}
+	var err error
+	if b.weekdays, err = parseCronField(days, 0, 6, dayNames); err != nil {
+		return nil, fmt.Errorf("invalid days in blackout '%s': %v", spec, err)
+	}
+	times := strings.SplitN(fields[len(fields)-1], "-", 2)
+	if len(times) != 2 {
+		return nil, fmt.Errorf("invalid blackout '%s'; expected [days] HH:MM-HH:MM", spec)
+	}
+	if b.start, err = parseTimeOfDay(times[0]); err != nil {
+		return nil, fmt.Errorf("invalid blackout '%s': %v", spec, err)
+	}
+	if b.end, err = parseTimeOfDay(times[1]); err != nil {
+		return nil, fmt.Errorf("invalid blackout '%s': %v", spec, err)
+	}
+	if b.start == b.end {
+		return nil, fmt.Errorf("invalid blackout '%s'; the window is empty", spec)
+	}
+	return b, nil
+}
+
+// Contains returns true when the time, in the location to evaluate the window, falls inside it
+func (b *Blackout) Contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if b.start < b.end {
+		return b.weekdays[t.Weekday()] && offset >= b.start && offset < b.end
+	}
+	yesterday := (t.Weekday() + 6) % 7
+	return (b.weekdays[t.Weekday()] && offset >= b.start) || (b.weekdays[yesterday] && offset < b.end)
+}
+
+// Variant is a configuration the daemon applies while any of its windows is active; e.x. an aggressive sweep on weekends
+type Variant struct {
+	Name       string
+	ConfigFile string      // Replaces the configuration file of the options of generate
+	windows    []*Blackout // Parsed like the blackout windows
+}
+
+// ParseVariant parses a variant with one or more windows, whose days are separated by semicolons;
+// e.x. name=weekend,window=sat;sun 00:00-24:00,config=/etc/weekend.yaml
+func ParseVariant(spec string) (*Variant, error) {
+	v := &Variant{}
+	for _, entry := range strings.Split(spec, ",") {
+		pair := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("invalid variant attribute '%s'", entry)
+		}
+		key, value := strings.TrimSpace(pair[0]), strings.TrimSpace(pair[1])
+		switch key {
+		case "name":
+			v.Name = value
+		case "config":
+			v.ConfigFile = value
+		case "window":
+			w, err := ParseBlackout(strings.ReplaceAll(value, ";", ","))
+			if err != nil {
+				return nil, fmt.Errorf("invalid window in variant '%s': %v", spec, err)
+			}
+			v.windows = append(v.windows, w)
+		default:
+			return nil, fmt.Errorf("unknown variant attribute '%s'", key)
+		}
+	}
+	if v.Name == "" || v.ConfigFile == "" || len(v.windows) == 0 {
+		return nil, fmt.Errorf("the name, the config and at least one window are required for variant '%s'", spec)
+	}
+	return v, nil
+}
+
+// Active returns true when the time, in the location to evaluate the windows, falls inside any of the windows
+func (v *Variant) Active(t time.Time) bool {
+	for _, w := range v.windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseChangeWindows validates the time zone, the blackout windows and the variants of the options
+func (o *Options) parseChangeWindows() error {
+	o.location = time.Local
+	if o.Timezone != "" {
+		loc, err := time.LoadLocation(o.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid time zone %s: %v", o.Timezone, err)
+		}
+		o.location = loc
+	}
+	o.blackouts = make([]*Blackout, 0, len(o.Blackouts))
+	for _, spec := range o.Blackouts {
+		b, err := ParseBlackout(spec)
+		if err != nil {
+			return err
+		}
+		o.blackouts = append(o.blackouts, b)
+	}
+	o.variants = make([]*Variant, 0, len(o.Variants))
+	for _, spec := range o.Variants {
+		v, err := ParseVariant(spec)
+		if err != nil {
+			return err
+		}
+		for _, other := range o.variants {
+			if other.Name == v.Name {
+				return fmt.Errorf("duplicate variant %s", v.Name)
+			}
+		}
+		o.variants = append(o.variants, v)
+	}
+	return nil
+}
+
+// activeBlackout returns the blackout window that contains the given time, or nil when OpenNMS can be updated
+func (o *Options) activeBlackout(now time.Time) *Blackout {
+	if o.location == nil {
+		o.location = time.Local
+	}
+	for _, b := range o.blackouts {
+		if b.Contains(now.In(o.location)) {
+			return b
+		}
+	}
+	return nil
+}
+
+// activeVariant returns the first variant active at the given time, or nil when the options of generate apply as they are
+func (o *Options) activeVariant(now time.Time) *Variant {
+	if o.location == nil {
+		o.location = time.Local
+	}
+	for _, v := range o.variants {
+		if v.Active(now.In(o.location)) {
+			return v
+		}
+	}
+	return nil
+}
+
+// variantName returns the name of a variant, or default for the options of generate
+func variantName(v *Variant) string {
+	if v == nil {
+		return "default"
+	}
+	return v.Name
+}
@@ -0,0 +1,148 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	for _, expr := range []string{"* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "* * 0 * *", "* * * foo *", "5-1 * * * *"} {
+		if _, err := ParseSchedule(expr); err == nil {
+			t.Errorf("schedule '%s' should be invalid", expr)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	utc := time.Date(2024, 3, 15, 10, 7, 30, 0, time.UTC) // Friday
+	cases := []struct {
+		expr     string
+		after    time.Time
+		expected time.Time
+	}{
+		{"*/15 * * * *", utc, time.Date(2024, 3, 15, 10, 15, 0, 0, time.UTC)},
+		{"0 22 * * mon-fri", utc, time.Date(2024, 3, 15, 22, 0, 0, 0, time.UTC)},
+		{"0 22 * * sat,sun", utc, time.Date(2024, 3, 16, 22, 0, 0, 0, time.UTC)},
+		{"30 2 1 * *", utc, time.Date(2024, 4, 1, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1 jan *", utc, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 3 13 * 5", utc, time.Date(2024, 3, 22, 3, 0, 0, 0, time.UTC)}, // Either the 13th or a Friday
+		{"0 12 * * 7", utc, time.Date(2024, 3, 17, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", utc, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		s, err := ParseSchedule(c.expr)
+		if err != nil {
+			t.Fatalf("cannot parse '%s': %v", c.expr, err)
+		}
+		if next := s.Next(c.after); !next.Equal(c.expected) {
+			t.Errorf("next for '%s' should be %s, got %s", c.expr, c.expected, next)
+		}
+	}
+
+	s, _ := ParseSchedule("0 0 30 2 *")
+	if !s.Next(utc).IsZero() {
+		t.Errorf("February 30th should never run")
+	}
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	s, _ = ParseSchedule("0 22 * * *")
+	next := s.Next(utc.In(tokyo)) // 19:07 in Tokyo
+	if expected := time.Date(2024, 3, 15, 13, 0, 0, 0, time.UTC); !next.Equal(expected) {
+		t.Errorf("the schedule should be evaluated in the time zone, expected %s, got %s", expected, next.UTC())
+	}
+}
+
+func TestBlackout(t *testing.T) {
+	for _, spec := range []string{"", "mon-fri", "mon-fri 08:00", "mon-fri 8am-6pm", "mon-foo 08:00-18:00", "10:00-10:00", "a b c"} {
+		if _, err := ParseBlackout(spec); err == nil {
+			t.Errorf("blackout '%s' should be invalid", spec)
+		}
+	}
+
+	b, err := ParseBlackout("mon-fri 08:00-18:00")
+	if err != nil {
+		t.Fatalf("cannot parse blackout: %v", err)
+	}
+	friday := func(hour, min int) time.Time { return time.Date(2024, 3, 15, hour, min, 0, 0, time.UTC) }
+	if !b.Contains(friday(8, 0)) || !b.Contains(friday(17, 59)) || b.Contains(friday(18, 0)) || b.Contains(friday(7, 59)) {
+		t.Errorf("incorrect business hours")
+	}
+	if b.Contains(friday(12, 0).AddDate(0, 0, 1)) {
+		t.Errorf("Saturday should not be in the window")
+	}
+
+	b, err = ParseBlackout("fri 22:00-02:00")
+	if err != nil {
+		t.Fatalf("cannot parse blackout: %v", err)
+	}
+	if !b.Contains(friday(23, 0)) || !b.Contains(friday(1, 0).AddDate(0, 0, 1)) || b.Contains(friday(1, 0)) || b.Contains(friday(2, 0).AddDate(0, 0, 1)) {
+		t.Errorf("incorrect window across midnight")
+	}
+
+	b, err = ParseBlackout("sat,sun 00:00-24:00")
+	if err != nil {
+		t.Fatalf("cannot parse blackout: %v", err)
+	}
+	if !b.Contains(friday(23, 59).AddDate(0, 0, 2)) || b.Contains(friday(23, 59)) {
+		t.Errorf("incorrect weekend window")
+	}
+}
+
+func TestActiveBlackout(t *testing.T) {
+	opts := &Options{Timezone: "America/New_York", Blackouts: StringList{"mon-fri 08:00-18:00"}}
+	if err := opts.parseChangeWindows(); err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	// 13:00 UTC is 09:00 in New York (EDT)
+	if b := opts.activeBlackout(time.Date(2024, 6, 14, 13, 0, 0, 0, time.UTC)); b == nil {
+		t.Errorf("the blackout should be active during business hours in New York")
+	}
+	if b := opts.activeBlackout(time.Date(2024, 6, 14, 11, 0, 0, 0, time.UTC)); b != nil {
+		t.Errorf("the blackout should not be active at 07:00 in New York")
+	}
+
+	opts = &Options{Timezone: "Mars/Olympus"}
+	if err := opts.parseChangeWindows(); err == nil {
+		t.Errorf("the time zone should be invalid")
+	}
+	opts = &Options{Blackouts: StringList{"someday"}}
+	if err := opts.parseChangeWindows(); err == nil {
+		t.Errorf("the blackout should be invalid")
+	}
+}
+
+func TestVariants(t *testing.T) {
+	for _, spec := range []string{"name=weekend,config=/tmp/weekend.yaml", "name=weekend,window=sat;sun 00:00-24:00", "name=weekend,window=someday,config=/tmp/weekend.yaml", "name=weekend,color=red,window=sat 00:00-24:00,config=/tmp/weekend.yaml"} {
+		if _, err := ParseVariant(spec); err == nil {
+			t.Errorf("variant '%s' should be invalid", spec)
+		}
+	}
+	opts := &Options{Variants: StringList{
+		"name=weekend,window=sat;sun 00:00-24:00,config=/tmp/weekend.yaml",
+		"name=nights,window=mon-thu 22:00-06:00,window=fri 22:00-24:00,config=/tmp/nights.yaml",
+	}}
+	if err := opts.parseChangeWindows(); err != nil {
+		t.Fatalf("cannot parse variants: %v", err)
+	}
+	for when, expected := range map[time.Time]string{
+		time.Date(2024, 6, 15, 12, 0, 0, 0, time.Local): "weekend", // Saturday
+		time.Date(2024, 6, 17, 23, 0, 0, 0, time.Local): "nights",  // Monday
+		time.Date(2024, 6, 18, 5, 0, 0, 0, time.Local):  "nights",  // Tuesday, from the window of Monday
+		time.Date(2024, 6, 18, 12, 0, 0, 0, time.Local): "default", // Tuesday
+		time.Date(2024, 6, 14, 23, 0, 0, 0, time.Local): "nights",  // Friday
+	} {
+		if name := variantName(opts.activeVariant(when)); name != expected {
+			t.Errorf("the %s variant should be active at %s, got %s", expected, when, name)
+		}
+	}
+
+	opts = &Options{Variants: StringList{"name=a,window=sat 00:00-24:00,config=/tmp/a.yaml", "name=a,window=sun 00:00-24:00,config=/tmp/b.yaml"}}
+	if err := opts.parseChangeWindows(); err == nil {
+		t.Errorf("duplicate variants should be invalid")
+	}
+}
@@ -0,0 +1,175 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// JSON Schema and validation of the configuration file, to catch mistakes in automation before running it
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+// durationPattern matches the values accepted by time.ParseDuration
+const durationPattern = `^(0|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`
+
+// schemaProperty returns the JSON Schema of the value of a flag
+func schemaProperty(f *flag.Flag) map[string]interface{} {
+	prop := map[string]interface{}{"description": f.Usage}
+	getter, ok := f.Value.(flag.Getter)
+	if f.Name == "definition" { // Accepts a map with the attributes, besides the string
+		item := map[string]interface{}{"oneOf": []interface{}{map[string]interface{}{"type": "string"}, definitionSchema()}}
+		prop["oneOf"] = []interface{}{item, map[string]interface{}{"type": "array", "items": item}}
+		return prop
+	}
+	if !ok { // Options that can be specified multiple times
+		prop["oneOf"] = []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		}
+		return prop
+	}
+	switch getter.Get().(type) {
+	case bool:
+		prop["type"] = "boolean"
+		if v, err := strconv.ParseBool(f.DefValue); err == nil {
+			prop["default"] = v
+		}
+	case int:
+		prop["type"] = "integer"
+		if v, err := strconv.Atoi(f.DefValue); err == nil {
+			prop["default"] = v
+		}
+	case float64:
+		prop["type"] = "number"
+		if v, err := strconv.ParseFloat(f.DefValue, 64); err == nil {
+			prop["default"] = v
+		}
+	case time.Duration:
+		prop["type"] = "string"
+		prop["pattern"] = durationPattern
+		prop["default"] = f.DefValue
+	default:
+		prop["type"] = "string"
+		if f.DefValue != "" {
+			prop["default"] = f.DefValue
+		}
+	}
+	switch f.Name {
+	case "onms-home": // The flag default includes $OPENNMS_HOME of the host generating the schema
+		prop["default"] = platformOnmsHome("linux")
+		prop["description"] = fmt.Sprintf("%s; otherwise %s, or %s on Windows", f.Usage, platformOnmsHome("linux"), platformOnmsHome("windows"))
+	case "duplicates":
+		prop["enum"] = []DuplicatePolicy{DuplicateWarn, DuplicateSkip, DuplicateError}
+	case "output-format":
+		prop["enum"] = discovery.Formats()
+	}
+	return prop
+}
+
+// definitionSchema returns the JSON Schema of a definition passed as a map in the configuration file
+func definitionSchema() map[string]interface{} {
+	properties := map[string]interface{}{
+		"name":           map[string]interface{}{"type": "string"},
+		"priority":       map[string]interface{}{"type": "integer", "description": "Position in the output, higher first; the default definition has priority 0"},
+		"tenant":         map[string]interface{}{"type": "string", "description": "Name of the tenant that owns the definition"},
+		"location":       map[string]interface{}{"type": "string"},
+		"foreign-source": map[string]interface{}{"type": "string"},
+		"retries":        map[string]interface{}{"type": "integer", "minimum": 0},
+		"timeout":        map[string]interface{}{"type": "integer", "minimum": 0},
+		"detectors": map[string]interface{}{"oneOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		}},
+	}
+	for _, input := range definitionInputs {
+		properties[input] = map[string]interface{}{"type": "string"}
+	}
+	return map[string]interface{}{"type": "object", "additionalProperties": false, "properties": properties}
+}
+
+// ConfigSchema returns the JSON Schema of the configuration file passed via -config
+func ConfigSchema() ([]byte, error) {
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	new(Options).Register(fs)
+	properties := make(map[string]interface{})
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name != "config" {
+			properties[f.Name] = schemaProperty(f)
+		}
+	})
+	schema := map[string]interface{}{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"title":                "onms-discovery-config configuration file",
+		"description":          "Options of onms-discovery-config, using their names as keys",
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties":           properties,
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// ValidatePipeline verifies the configuration file, and the files it refers to, without processing the inputs
+func ValidatePipeline(fileName string) []error {
+	return validatePipeline(fileName, map[string]bool{})
+}
+
+// validatePipeline validates a configuration file and the ones of its variants, which are validated only once
+func validatePipeline(fileName string, visited map[string]bool) []error {
+	visited[fileName] = true
+	opts := new(Options)
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	opts.Register(fs)
+	if err := LoadConfigFile(fs, fileName); err != nil {
+		return []error{err}
+	}
+	errs := make([]error, 0)
+	if _, err := ParseDuplicatePolicy(opts.Duplicates); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := ParseSourcePrecedence(opts.Precedence); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := discovery.GetSerializer(opts.OutputFormat); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := opts.GetInstances(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := opts.GetTenants(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := opts.inputHashes(); err != nil {
+		errs = append(errs, err)
+	}
+	if definitions, err := opts.GetDefinitions(); err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, spec := range definitions {
+			if _, err := spec.Options(opts).inputHashes(); err != nil {
+				errs = append(errs, fmt.Errorf("definition %s: %v", spec.Name, err))
+			}
+		}
+	}
+	if opts.DetectorsFile != "" {
+		if _, err := LoadDetectorsFile(opts.DetectorsFile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := opts.parseChangeWindows(); err != nil {
+		errs = append(errs, err)
+	}
+	for _, v := range opts.variants {
+		if visited[v.ConfigFile] {
+			continue
+		}
+		for _, err := range validatePipeline(v.ConfigFile, visited) {
+			errs = append(errs, fmt.Errorf("variant %s: %v", v.Name, err))
+		}
+	}
+	return errs
+}
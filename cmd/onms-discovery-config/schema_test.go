@@ -0,0 +1,84 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigSchema(t *testing.T) {
+	data, err := ConfigSchema()
+	if err != nil {
+		t.Fatalf("cannot generate schema: %v", err)
+	}
+	schema := struct {
+		Properties map[string]map[string]interface{} `json:"properties"`
+	}{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("cannot parse schema: %v", err)
+	}
+	if _, ok := schema.Properties["config"]; ok {
+		t.Errorf("the config option should not be part of the schema")
+	}
+	types := map[string]string{"inc-cidr": "string", "dry-run": "boolean", "onms-port": "integer", "lookup-qps": "number"}
+	for name, kind := range types {
+		if schema.Properties[name]["type"] != kind {
+			t.Errorf("%s should be %s: %v", name, kind, schema.Properties[name])
+		}
+	}
+	if _, ok := schema.Properties["inc-url"]["oneOf"]; !ok {
+		t.Errorf("inc-url should accept a list: %v", schema.Properties["inc-url"])
+	}
+	if schema.Properties["cache-ttl"]["pattern"] != durationPattern {
+		t.Errorf("cache-ttl should be a duration: %v", schema.Properties["cache-ttl"])
+	}
+
+	shipped, err := ioutil.ReadFile(filepath.Join("..", "..", "schema", "config.schema.json"))
+	if err != nil {
+		t.Fatalf("cannot read the shipped schema: %v", err)
+	}
+	if strings.TrimSpace(string(shipped)) != string(data) {
+		t.Errorf("the shipped schema is outdated; regenerate it with: go run ./cmd/onms-discovery-config validate-pipeline -print-schema > schema/config.schema.json")
+	}
+}
+
+func TestValidatePipeline(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_pipeline")
+	if err != nil {
+		t.Fatalf("cannot create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cidrs := filepath.Join(dir, "cidrs.txt")
+	ioutil.WriteFile(cidrs, []byte("10.0.0.0/24\n"), 0644)
+	valid := filepath.Join(dir, "valid.yaml")
+	ioutil.WriteFile(valid, []byte("inc-cidr: "+cidrs+":retries=2\ndefinition:\n  - location=Paris,inc-cidr="+cidrs+"\noutput-format: json\n"), 0644)
+	if errs := ValidatePipeline(valid); len(errs) != 0 {
+		t.Errorf("the pipeline should be valid: %v", errs)
+	}
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	ioutil.WriteFile(invalid, []byte("inc-list: "+filepath.Join(dir, "missing.txt")+"\nduplicates: ignore\noutput-format: csv\ndefinition: location=Paris,inc-cidr=/missing.txt\n"), 0644)
+	if errs := ValidatePipeline(invalid); len(errs) != 4 {
+		t.Errorf("there should be 4 errors: %v", errs)
+	}
+
+	weekend := filepath.Join(dir, "weekend.yaml")
+	ioutil.WriteFile(weekend, []byte("inc-list: "+filepath.Join(dir, "missing.txt")+"\nvariant: name=base,window=mon 00:00-24:00,config="+valid+"\n"), 0644)
+	variants := filepath.Join(dir, "variants.yaml")
+	ioutil.WriteFile(variants, []byte("inc-cidr: "+cidrs+"\nvariant: name=weekend,window=sat;sun 00:00-24:00,config="+weekend+"\n"), 0644)
+	if errs := ValidatePipeline(variants); len(errs) != 1 || !strings.Contains(errs[0].Error(), "variant weekend") {
+		t.Errorf("the configuration files of the variants should be validated: %v", errs)
+	}
+
+	unknown := filepath.Join(dir, "unknown.yaml")
+	ioutil.WriteFile(unknown, []byte("inc-cidrs: "+cidrs+"\n"), 0644)
+	if errs := ValidatePipeline(unknown); len(errs) != 1 {
+		t.Errorf("unknown options should be rejected: %v", errs)
+	}
+}
@@ -0,0 +1,82 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Detection of abrupt changes in the size of the scope, to catch corrupted inventories before deploying them
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/events"
+)
+
+type ScopeChange struct {
+	Host     string   `json:"host"`
+	Time     string   `json:"time"`
+	Previous *big.Int `json:"previous"` // Estimated addresses of the current configuration
+	Current  *big.Int `json:"current"`  // Estimated addresses of the generated configuration
+	Change   float64  `json:"change"`   // Percentage of change relative to the previous scope
+}
+
+func NewScopeChange(previous, current *big.Int) *ScopeChange {
+	hostname, _ := os.Hostname()
+	change := &ScopeChange{
+		Host:     hostname,
+		Time:     time.Now().Format(time.RFC3339),
+		Previous: previous,
+		Current:  current,
+	}
+	if previous.Sign() > 0 { // There is no baseline for an empty configuration
+		diff := new(big.Float).SetInt(new(big.Int).Sub(current, previous))
+		change.Change, _ = diff.Quo(diff, new(big.Float).SetInt(previous)).Float64()
+		change.Change *= 100
+	}
+	return change
+}
+
+// Exceeds returns true when the scope grew or shrank more than the given percentage
+func (c *ScopeChange) Exceeds(maxPercent float64) bool {
+	return math.Abs(c.Change) > maxPercent
+}
+
+func (c *ScopeChange) String() string {
+	return fmt.Sprintf("the scope changed from %d to %d addresses (%+.2f%%)", c.Previous, c.Current, c.Change)
+}
+
+// checkScopeChange compares the generated scope against the current configuration,
+// and reports when the change exceeds the configured rate via event and/or webhook.
+func checkScopeChange(opts *Options) {
+	current, err := loadConfiguration(discoveryConfigPath(opts.OnmsHome))
+	if err != nil {
+		log.Printf("cannot verify the scope change as the current configuration is not available: %v", err)
+		return
+	}
+	change := NewScopeChange(current.GetTotalEstimatedAddresses(), summary.EstimatedAddresses)
+	if !change.Exceeds(opts.MaxScopeChange) {
+		log.Printf("%s", change.String())
+		return
+	}
+	log.Printf("warning: %s, which exceeds the limit of %.2f%%", change.String(), opts.MaxScopeChange)
+	if opts.ScopeChangeUEI != "" {
+		event := events.Event{UEI: opts.ScopeChangeUEI}
+		event.SetSeverity(events.SeverityWarning)
+		event.SetLogMsg(fmt.Sprintf("Discovery scope change: %s", change.String()), events.LogDestLogAndDisplay)
+		event.AddParam("previous", change.Previous.String())
+		event.AddParam("current", change.Current.String())
+		event.AddParam("change", strconv.FormatFloat(change.Change, 'f', 2, 64))
+		if err := opts.sendEvent(event); err != nil {
+			log.Printf("cannot send scope change event: %v", err)
+		}
+	}
+	if opts.ScopeChangeWebhook != "" {
+		if err := postJSON(opts.ScopeChangeWebhook, change); err != nil {
+			log.Printf("cannot send scope change report: %v", err)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestScopeChange(t *testing.T) {
+	change := NewScopeChange(big.NewInt(1000), big.NewInt(1500))
+	if change.Change != 50 {
+		t.Errorf("the change should be 50%%: %f", change.Change)
+	}
+	if !change.Exceeds(20) || change.Exceeds(50) {
+		t.Errorf("the change should only exceed 20%%")
+	}
+	if change = NewScopeChange(big.NewInt(1000), big.NewInt(100)); !change.Exceeds(80) {
+		t.Errorf("shrinking 90%% should exceed 80%%: %f", change.Change)
+	}
+	if change = NewScopeChange(big.NewInt(0), big.NewInt(100)); change.Exceeds(1) {
+		t.Errorf("an empty configuration should not be considered a baseline")
+	}
+	if _, err := json.Marshal(change); err != nil {
+		t.Errorf("cannot encode scope change: %v", err)
+	}
+}
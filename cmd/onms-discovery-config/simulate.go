@@ -0,0 +1,217 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Simulation of a discovery pass by pinging a random sample of the configured scope,
+// to estimate the number of responders and the volume of discovery events before enabling a big sweep.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"math/rand"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+type Pinger interface {
+	Ping(ip net.IP, timeout time.Duration, retries int) bool
+}
+
+type SimulationReport struct {
+	Scope              *big.Int // Estimated number of addresses to check
+	Sampled            int      // Number of addresses pinged
+	Responders         int      // Number of sampled addresses that replied
+	ResponseRate       float64  // Responders over Sampled
+	ExpectedResponders *big.Int // Extrapolated number of responders for the whole scope
+	PassesPerDay       float64  // Number of discovery passes per day based on the restart sleep time
+	ExpectedEvents     *big.Int // Extrapolated number of newSuspect events per day
+}
+
+func (r *SimulationReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "estimated scope: %d addresses\n", r.Scope)
+	fmt.Fprintf(&sb, "sampled addresses: %d, responders: %d, response rate: %.2f%%\n", r.Sampled, r.Responders, r.ResponseRate*100)
+	fmt.Fprintf(&sb, "expected responders per pass: %d\n", r.ExpectedResponders)
+	fmt.Fprintf(&sb, "expected newSuspect events per day: %d (%.2f passes per day)\n", r.ExpectedEvents, r.PassesPerDay)
+	return sb.String()
+}
+
+// Sample returns up to size random addresses from the scope of the configuration, ignoring excluded addresses
+func Sample(cfg *discovery.DiscoveryConfiguration, size int, rnd *rand.Rand) []net.IP {
+	type candidate struct {
+		def   *discovery.Definition
+		begin *big.Int
+		size  *big.Int
+		ipv4  bool
+	}
+	candidates := make([]candidate, 0)
+	total := big.NewInt(0)
+	add := func(def *discovery.Definition, ipr iprange.IPAddressRange) {
+		begin := iprange.IP2Int(ipr.Begin)
+		size := new(big.Int).Sub(iprange.IP2Int(ipr.End), begin)
+		size.Add(size, big.NewInt(1))
+		candidates = append(candidates, candidate{def, begin, size, ipr.Begin.To4() != nil})
+		total.Add(total, size)
+	}
+	for i := range cfg.Definitions {
+		def := &cfg.Definitions[i]
+		for _, r := range def.IncludeRanges {
+			add(def, r.ToIPAddressRange())
+		}
+		for _, s := range def.Specifics {
+			add(def, s.ToIPAddressRange())
+		}
+	}
+	sample := make([]net.IP, 0, size)
+	if total.Sign() == 0 {
+		return sample
+	}
+	seen := make(map[string]bool)
+	for attempts := 0; len(sample) < size && attempts < size*10; attempts++ {
+		n := new(big.Int).Rand(rnd, total)
+		for _, c := range candidates {
+			if n.Cmp(c.size) >= 0 {
+				n.Sub(n, c.size)
+				continue
+			}
+			ipInt := n.Add(n, c.begin)
+			ip := iprange.IntToIP(ipInt, c.ipv4)
+			if !c.def.ExcludeRangesContain(ip.String()) && !seen[ip.String()] {
+				seen[ip.String()] = true
+				sample = append(sample, ip)
+			}
+			break
+		}
+	}
+	return sample
+}
+
+// scale returns n multiplied by the given factor, rounded down
+func scale(n *big.Int, factor float64) *big.Int {
+	result, _ := new(big.Float).Mul(new(big.Float).SetInt(n), big.NewFloat(factor)).Int(nil)
+	return result
+}
+
+// Simulate pings a random sample of the configured scope at the given rate, and extrapolates the results
+func Simulate(cfg *discovery.DiscoveryConfiguration, pinger Pinger, size int, pps int, rnd *rand.Rand) *SimulationReport {
+	report := &SimulationReport{Scope: cfg.GetTotalEstimatedAddresses()}
+	sample := Sample(cfg, size, rnd)
+	var interval time.Duration
+	if pps > 0 {
+		interval = time.Second / time.Duration(pps)
+	}
+	timeout := time.Duration(cfg.Timeout) * time.Millisecond
+	for i, ip := range sample {
+		if i > 0 && interval > 0 {
+			time.Sleep(interval)
+		}
+		if pinger.Ping(ip, timeout, cfg.Retries) {
+			log.Printf("address %s replied", ip)
+			report.Responders++
+		}
+	}
+	report.Sampled = len(sample)
+	if report.Sampled > 0 {
+		report.ResponseRate = float64(report.Responders) / float64(report.Sampled)
+	}
+	report.ExpectedResponders = scale(report.Scope, report.ResponseRate)
+	if cfg.RestartSleepTime > 0 {
+		report.PassesPerDay = float64(24*time.Hour/time.Millisecond) / float64(cfg.RestartSleepTime)
+	}
+	report.ExpectedEvents = scale(report.ExpectedResponders, report.PassesPerDay)
+	return report
+}
+
+// ICMPPinger sends ICMP Echo Requests, using unprivileged sockets when the OS allows it
+type ICMPPinger struct {
+	seq int
+}
+
+func (p *ICMPPinger) Ping(ip net.IP, timeout time.Duration, retries int) bool {
+	for i := 0; i <= retries; i++ {
+		ok, err := p.ping(ip, timeout)
+		if err != nil {
+			log.Printf("cannot ping %s: %v", ip, err)
+			return false
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *ICMPPinger) ping(ip net.IP, timeout time.Duration) (bool, error) {
+	proto := 1 // ICMP for IPv4
+	var request, reply icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	networks := []string{"udp4", "ip4:icmp"}
+	if ip.To4() == nil {
+		proto = 58 // ICMP for IPv6
+		request, reply = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+		networks = []string{"udp6", "ip6:ipv6-icmp"}
+	}
+	var conn *icmp.PacketConn
+	var err error
+	var dest net.Addr
+	for _, network := range networks {
+		if conn, err = icmp.ListenPacket(network, ""); err == nil {
+			if strings.HasPrefix(network, "udp") {
+				dest = &net.UDPAddr{IP: ip}
+			} else {
+				dest = &net.IPAddr{IP: ip}
+			}
+			break
+		}
+	}
+	if err != nil {
+		return false, fmt.Errorf("cannot open an ICMP socket (%s): %v", icmpHint(runtime.GOOS), err)
+	}
+	defer conn.Close()
+
+	p.seq++
+	msg := icmp.Message{
+		Type: request,
+		Body: &icmp.Echo{ID: os.Getpid() & 0xffff, Seq: p.seq, Data: []byte("onms-discovery-config")},
+	}
+	data, err := msg.Marshal(nil)
+	if err != nil {
+		return false, err
+	}
+	if _, err := conn.WriteTo(data, dest); err != nil {
+		return false, err
+	}
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return false, nil
+			}
+			return false, err
+		}
+		m, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil || m.Type != reply {
+			continue
+		}
+		if host, _, err := net.SplitHostPort(peer.String()); err == nil && net.ParseIP(host).Equal(ip) {
+			return true, nil
+		}
+		if net.ParseIP(peer.String()).Equal(ip) {
+			return true, nil
+		}
+	}
+}
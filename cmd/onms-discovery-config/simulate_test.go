@@ -0,0 +1,83 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"math/big"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+type mockPinger struct{}
+
+// Only addresses with an even last octet reply
+func (p *mockPinger) Ping(ip net.IP, timeout time.Duration, retries int) bool {
+	return ip.To4()[3]%2 == 0
+}
+
+func TestSample(t *testing.T) {
+	def := discovery.Definition{}
+	def.IncludeCIDR("192.168.0.0/24")
+	def.ExcludeCIDR("192.168.0.0/25")
+	def.AddSpecific("10.0.0.1")
+	cfg := discovery.DiscoveryConfiguration{
+		Definitions: []discovery.Definition{def},
+	}
+	sample := Sample(&cfg, 50, rand.New(rand.NewSource(1)))
+	if len(sample) != 50 {
+		t.Fatalf("the sample should have 50 addresses: %d", len(sample))
+	}
+	seen := make(map[string]bool)
+	for _, ip := range sample {
+		if cfg.Definitions[0].ExcludeRangesContain(ip.String()) {
+			t.Errorf("address %s should have been excluded", ip)
+		}
+		if seen[ip.String()] {
+			t.Errorf("address %s is duplicated", ip)
+		}
+		seen[ip.String()] = true
+	}
+
+	def = discovery.Definition{}
+	def.AddSpecific("::1")
+	def.AddSpecific("0.0.0.1")
+	cfg.Definitions = []discovery.Definition{def}
+	sample = Sample(&cfg, 2, rand.New(rand.NewSource(1)))
+	if len(sample) != 2 {
+		t.Fatalf("the sample should have 2 addresses: %v", sample)
+	}
+	for _, ip := range sample {
+		if def.GetSpecific(ip.String()) == nil {
+			t.Errorf("the addresses with leading zeros should keep their family, got %s", ip)
+		}
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	def := discovery.Definition{}
+	def.IncludeCIDR("192.168.0.0/24")
+	cfg := discovery.DiscoveryConfiguration{
+		RestartSleepTime: 43200000,
+		Definitions:      []discovery.Definition{def},
+	}
+	report := Simulate(&cfg, new(mockPinger), 100, 0, rand.New(rand.NewSource(1)))
+	if report.Scope.Int64() != 254 {
+		t.Errorf("the scope should have 254 addresses: %d", report.Scope)
+	}
+	if report.Sampled != 100 {
+		t.Errorf("the sample should have 100 addresses: %d", report.Sampled)
+	}
+	if report.Responders == 0 || report.Responders == report.Sampled {
+		t.Errorf("unexpected number of responders: %d", report.Responders)
+	}
+	if report.PassesPerDay != 2 {
+		t.Errorf("there should be 2 passes per day: %f", report.PassesPerDay)
+	}
+	if report.ExpectedEvents.Cmp(new(big.Int).Mul(report.ExpectedResponders, big.NewInt(2))) != 0 {
+		t.Errorf("unexpected number of events: %d", report.ExpectedEvents)
+	}
+}
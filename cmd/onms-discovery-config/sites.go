@@ -0,0 +1,130 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Site catalog as the primary scoping mechanism, generating one definition per site
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+type Site struct {
+	Name          string
+	Location      string
+	ForeignSource string
+	CIDRs         []string
+}
+
+type SiteCatalog []*Site
+
+// LoadSiteCatalog reads a file with one site,cidr,location,foreign-source entry per line.
+// A site with multiple CIDRs is defined in multiple lines, and the location and foreign-source are optional.
+func LoadSiteCatalog(fileName string) (SiteCatalog, error) {
+	s, err := getScanner(fileName)
+	if err != nil {
+		return nil, err
+	}
+	catalog := make(SiteCatalog, 0)
+	sites := make(map[string]*Site)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		for len(fields) < 4 {
+			fields = append(fields, "")
+		}
+		if len(fields) > 4 || fields[0] == "" {
+			return nil, fmt.Errorf("invalid site entry '%s' in %s", line, fileName)
+		}
+		if err := discovery.ValidateName(fields[0]); err != nil {
+			return nil, fmt.Errorf("invalid site %s in %s: %v", fields[0], fileName, err)
+		}
+		cidr, err := iprange.NormalizeCIDR(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR for site %s in %s: %v", fields[0], fileName, err)
+		}
+		site, ok := sites[fields[0]]
+		if !ok {
+			site = &Site{Name: fields[0], Location: fields[2], ForeignSource: fields[3]}
+			sites[site.Name] = site
+			catalog = append(catalog, site)
+		} else if site.Location != fields[2] || site.ForeignSource != fields[3] {
+			return nil, fmt.Errorf("site %s has inconsistent location or foreign-source in %s", site.Name, fileName)
+		}
+		site.CIDRs = append(site.CIDRs, cidr)
+	}
+	return catalog, s.Err()
+}
+
+// Apply moves the content of the first definition into one definition per site, with the CIDRs of the site as include ranges.
+// Specifics and include ranges covered by a site are claimed by it (kept only when they have their own attributes),
+// and the ones not claimed by any site remain in a separate definition and are returned.
+func (c SiteCatalog) Apply(cfg *discovery.DiscoveryConfiguration) []string {
+	if len(cfg.Definitions) == 0 {
+		return nil
+	}
+	def := cfg.Definitions[0]
+	definitions := make([]discovery.Definition, 0, len(c)+len(cfg.Definitions))
+	for _, site := range c {
+		sd := discovery.Definition{
+			Name:          site.Name,
+			Location:      site.Location,
+			ForeignSource: site.ForeignSource,
+			ChunkSize:     def.ChunkSize,
+			Retries:       def.Retries,
+			Timeout:       def.Timeout,
+			Detectors:     def.Detectors,
+			ExcludeRanges: append([]discovery.ExcludeRange{}, def.ExcludeRanges...),
+		}
+		for _, cidr := range site.CIDRs {
+			sd.IncludeCIDR(cidr)
+		}
+		definitions = append(definitions, sd)
+	}
+	claim := func(ipr iprange.IPAddressRange) *discovery.Definition {
+		for i := range definitions {
+			for _, r := range definitions[i].IncludeRanges {
+				sr := r.ToIPAddressRange()
+				if sr.Contains(ipr.Begin) && sr.Contains(ipr.End) {
+					return &definitions[i]
+				}
+			}
+		}
+		return nil
+	}
+
+	unclaimed := make([]string, 0)
+	leftover := def
+	leftover.Specifics, leftover.IncludeRanges = nil, nil
+	for _, s := range def.Specifics {
+		if sd := claim(s.ToIPAddressRange()); sd == nil {
+			leftover.Specifics = append(leftover.Specifics, s)
+			unclaimed = append(unclaimed, s.IP.String())
+		} else if s.Attributes() != (discovery.Attributes{}) {
+			sd.Specifics = append(sd.Specifics, s)
+		}
+	}
+	for _, r := range def.IncludeRanges {
+		ipr := r.ToIPAddressRange()
+		if sd := claim(ipr); sd == nil {
+			leftover.IncludeRanges = append(leftover.IncludeRanges, r)
+			unclaimed = append(unclaimed, ipr.String())
+		} else if ipr.Location != "" || ipr.Retries != 0 || ipr.Timeout != 0 || ipr.ForeignSource != "" {
+			sd.IncludeRanges = append(sd.IncludeRanges, r)
+		}
+	}
+	if len(leftover.Specifics) > 0 || len(leftover.IncludeRanges) > 0 || len(leftover.IncludeURLs) > 0 {
+		definitions = append(definitions, leftover)
+	}
+	cfg.Definitions = append(definitions, cfg.Definitions[1:]...)
+	return unclaimed
+}
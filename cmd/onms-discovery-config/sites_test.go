@@ -0,0 +1,72 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestLoadSiteCatalog(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "sites.txt")
+	ioutil.WriteFile(file, []byte(`# site,cidr,location,foreign-source
+Berlin, 192.168.0.0/24, Berlin, BER
+Berlin, 192.168.1.0/24, Berlin, BER
+HQ, 10.0.0.0/24
+`), 0644)
+	catalog, err := LoadSiteCatalog(file)
+	if err != nil {
+		t.Fatalf("cannot load catalog: %v", err)
+	}
+	if len(catalog) != 2 || len(catalog[0].CIDRs) != 2 || catalog[0].ForeignSource != "BER" || catalog[1].Location != "" {
+		t.Errorf("unexpected catalog: %+v", catalog)
+	}
+
+	ioutil.WriteFile(file, []byte("Berlin,192.168.0.0/24,Berlin\nBerlin,192.168.1.0/24,Paris\n"), 0644)
+	if _, err := LoadSiteCatalog(file); err == nil {
+		t.Errorf("inconsistent sites should fail")
+	}
+	ioutil.WriteFile(file, []byte("Berlin,192.168.0.0\n"), 0644)
+	if _, err := LoadSiteCatalog(file); err == nil {
+		t.Errorf("invalid CIDRs should fail")
+	}
+}
+
+func TestSiteCatalogApply(t *testing.T) {
+	def := discovery.Definition{}
+	def.ExcludeCIDR("192.168.0.0/28")
+	def.AddSpecific("192.168.0.100")
+	def.AddSpecificWithAttributes("192.168.0.101", discovery.Attributes{Retries: 3})
+	def.AddSpecific("172.16.0.1")
+	def.IncludeCIDR("10.0.0.0/25")
+	def.IncludeCIDR("10.1.0.0/24")
+	cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{def}}
+	catalog := SiteCatalog{
+		{Name: "Berlin", Location: "Berlin", ForeignSource: "BER", CIDRs: []string{"192.168.0.0/24"}},
+		{Name: "HQ", CIDRs: []string{"10.0.0.0/24"}},
+	}
+	unclaimed := catalog.Apply(cfg)
+	if len(cfg.Definitions) != 3 {
+		t.Fatalf("there should be 3 definitions: %d", len(cfg.Definitions))
+	}
+	berlin := cfg.Definitions[0]
+	if berlin.Location != "Berlin" || berlin.ForeignSource != "BER" || len(berlin.IncludeRanges) != 1 || len(berlin.ExcludeRanges) != 1 {
+		t.Errorf("unexpected definition for Berlin: %s", berlin.String())
+	}
+	if len(berlin.Specifics) != 1 || berlin.Specifics[0].IP.String() != "192.168.0.101" {
+		t.Errorf("only the claimed specifics with attributes should be kept: %v", berlin.Specifics)
+	}
+	if hq := cfg.Definitions[1]; len(hq.IncludeRanges) != 1 || hq.IncludeRanges[0].End.String() != "10.0.0.254" {
+		t.Errorf("unexpected definition for HQ: %s", hq.String())
+	}
+	if len(unclaimed) != 2 || unclaimed[0] != "172.16.0.1" {
+		t.Errorf("unexpected unclaimed entries: %v", unclaimed)
+	}
+	if leftover := cfg.Definitions[2]; len(leftover.Specifics) != 1 || len(leftover.IncludeRanges) != 1 {
+		t.Errorf("unexpected definition for the unclaimed entries: %s", leftover.String())
+	}
+}
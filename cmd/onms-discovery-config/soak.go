@@ -0,0 +1,151 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Generation of synthetic events to benchmark eventd, before enabling discovery on a big scope
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/events"
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+// soakTestUEI is the default UEI of the synthetic events; unlike newSuspect, it doesn't trigger provisioning
+const soakTestUEI = "uei.opennms.org/internal/discovery/soakTest"
+
+type SoakOptions struct {
+	Events    int     // Total number of events to send
+	Rate      float64 // Maximum number of events per second (0 for no limit)
+	BatchSize int     // Number of events per connection
+	Workers   int     // Number of concurrent connections
+	UEI       string  // UEI of the events
+	Network   string  // CIDR or range for the interface of the events, cycling through its addresses
+}
+
+type SoakReport struct {
+	Sent       int           // Number of events delivered
+	Failed     int           // Number of events from batches that couldn't be delivered
+	Batches    int           // Number of connections made
+	Duration   time.Duration // Time it took to send all the events
+	Rate       float64       // Achieved events per second
+	AvgLatency time.Duration // Average time to deliver a batch
+	MaxLatency time.Duration // Maximum time to deliver a batch
+	Errors     []string      // Distinct delivery errors
+}
+
+func (r *SoakReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "events sent: %d, failed: %d, batches: %d\n", r.Sent, r.Failed, r.Batches)
+	fmt.Fprintf(&sb, "duration: %s, rate: %.2f events per second\n", r.Duration.Round(time.Millisecond), r.Rate)
+	fmt.Fprintf(&sb, "batch latency: average %s, maximum %s\n", r.AvgLatency.Round(time.Microsecond), r.MaxLatency.Round(time.Microsecond))
+	for _, e := range r.Errors {
+		fmt.Fprintf(&sb, "error: %s\n", e)
+	}
+	return sb.String()
+}
+
+// SyntheticEvents returns count events starting at the given sequence number, using the addresses of the network in order
+func SyntheticEvents(uei string, network iprange.IPAddressRange, first, count int) []events.Event {
+	size := network.Size()
+	list := make([]events.Event, 0, count)
+	for seq := first; seq < first+count; seq++ {
+		offset := int64(seq)
+		if size.IsInt64() { // Otherwise, the network is bigger than any sequence number
+			offset %= size.Int64()
+		}
+		event := events.Event{
+			UEI:       uei,
+			Source:    "DiscoverConfigGenerator",
+			Time:      time.Now().Format(time.RFC3339),
+			Interface: iprange.Offset(network.Begin, offset).String(),
+		}
+		event.AddParam("soakSequence", strconv.Itoa(seq))
+		list = append(list, event)
+	}
+	return list
+}
+
+// Soak sends the synthetic events in batches through the given function, honoring the rate and concurrency limits
+func Soak(opts *SoakOptions, send func(*events.Log) error) (*SoakReport, error) {
+	if opts.Events <= 0 {
+		return nil, fmt.Errorf("the number of events must be positive")
+	}
+	network, err := iprange.ParseRange(opts.Network)
+	if err != nil {
+		return nil, err
+	}
+	batchSize, workers := opts.BatchSize, opts.Workers
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+	uei := opts.UEI
+	if uei == "" {
+		uei = soakTestUEI
+	}
+	throttle := NewThrottle(0, opts.Rate/float64(batchSize), 0)
+
+	report := new(SoakReport)
+	var mutex sync.Mutex
+	var total time.Duration
+	seen := make(map[string]bool)
+	batches := make(chan int)
+	wg := new(sync.WaitGroup)
+	start := time.Now()
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for first := range batches {
+				count := batchSize
+				if first+count > opts.Events {
+					count = opts.Events - first
+				}
+				batch := &events.Log{Events: SyntheticEvents(uei, network, first, count)}
+				var latency time.Duration
+				err := throttle.Do(func() error {
+					sent := time.Now()
+					defer func() { latency = time.Since(sent) }()
+					return send(batch)
+				})
+				mutex.Lock()
+				report.Batches++
+				total += latency
+				if latency > report.MaxLatency {
+					report.MaxLatency = latency
+				}
+				if err != nil {
+					report.Failed += count
+					if !seen[err.Error()] {
+						seen[err.Error()] = true
+						report.Errors = append(report.Errors, err.Error())
+					}
+				} else {
+					report.Sent += count
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+	for first := 0; first < opts.Events; first += batchSize {
+		batches <- first
+	}
+	close(batches)
+	wg.Wait()
+
+	report.Duration = time.Since(start)
+	if report.Duration > 0 {
+		report.Rate = float64(report.Sent) / report.Duration.Seconds()
+	}
+	if report.Batches > 0 {
+		report.AvgLatency = total / time.Duration(report.Batches)
+	}
+	return report, nil
+}
@@ -0,0 +1,101 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/events"
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+func TestSyntheticEvents(t *testing.T) {
+	network, _ := iprange.ParseRange("10.0.0.0/31")
+	list := SyntheticEvents(soakTestUEI, network, 1, 3)
+	if len(list) != 3 {
+		t.Fatalf("there should be 3 events: %d", len(list))
+	}
+	for i, ip := range []string{"10.0.0.1", "10.0.0.0", "10.0.0.1"} {
+		if list[i].Interface != ip || list[i].UEI != soakTestUEI {
+			t.Errorf("incorrect event %d: %+v", i, list[i])
+		}
+	}
+	if list[2].Parameters[0].Value.Content != "3" {
+		t.Errorf("incorrect sequence: %+v", list[2].Parameters)
+	}
+}
+
+func TestSoak(t *testing.T) {
+	var mutex sync.Mutex
+	received := make(map[string]bool)
+	send := func(batch *events.Log) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, e := range batch.Events {
+			if e.Interface == "10.0.0.7" {
+				return fmt.Errorf("connection refused")
+			}
+		}
+		for _, e := range batch.Events {
+			received[e.Parameters[0].Value.Content] = true
+		}
+		return nil
+	}
+	report, err := Soak(&SoakOptions{Events: 25, BatchSize: 10, Workers: 3, Network: "10.0.0.0/24"}, send)
+	if err != nil {
+		t.Fatalf("cannot run soak test: %v", err)
+	}
+	if report.Batches != 3 || report.Sent != 15 || report.Failed != 10 || len(report.Errors) != 1 {
+		t.Errorf("incorrect report: %+v", report)
+	}
+	if len(received) != 15 {
+		t.Errorf("there should be 15 events received: %d", len(received))
+	}
+	if _, err := Soak(&SoakOptions{Events: 1, Network: "invalid"}, send); err == nil {
+		t.Errorf("the network should be validated")
+	}
+}
+
+func TestSoakEventd(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	defer listener.Close()
+	counts := make(chan int)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			data, _ := ioutil.ReadAll(conn)
+			conn.Close()
+			log := new(events.Log)
+			xml.Unmarshal(data, log)
+			counts <- len(log.Events)
+		}
+	}()
+	port := listener.Addr().(*net.TCPAddr).Port
+	done := make(chan *SoakReport)
+	go func() {
+		report, _ := Soak(&SoakOptions{Events: 5, BatchSize: 2, Rate: 1000, Network: "2001:db8::/64"}, func(batch *events.Log) error {
+			return batch.Send("127.0.0.1", port)
+		})
+		done <- report
+	}()
+	total := 0
+	for i := 0; i < 3; i++ {
+		total += <-counts
+	}
+	report := <-done
+	if total != 5 || report.Sent != 5 {
+		t.Errorf("eventd should get 5 events: %d, %s", total, strings.TrimSpace(report.String()))
+	}
+}
@@ -0,0 +1,138 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Staging of the newly seen addresses in dedicated definitions, before promoting them to the main ones
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+// StagingSettings are the settings of the staging definitions
+type StagingSettings struct {
+	ForeignSource string   // Foreign source of the staging definitions
+	Runs          int      // Number of runs an address stays in staging before being promoted
+	Detectors     []string // Names of the detectors to keep from the main definition; empty means none
+}
+
+// StagingState keeps the number of runs in which every address was seen, up to its promotion
+type StagingState struct {
+	Addresses map[string]int `json:"addresses"`
+	path      string
+	baseline  bool // True when there was no state, so the current addresses are not new
+}
+
+// LoadStagingState reads the state from the given file; a missing file means that all the current addresses are promoted
+func LoadStagingState(path string) (*StagingState, error) {
+	state := &StagingState{Addresses: make(map[string]int), path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		state.baseline = true
+		return state, nil
+	}
+	if err == nil {
+		data, err = openFile(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read staging state %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid staging state %s: %v", path, err)
+	}
+	return state, nil
+}
+
+// Save writes the state to its file, readable only by the owner as it lists the devices of the network
+func (s *StagingState) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err == nil {
+		data, err = sealFile(data)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("cannot write staging state %s: %v", s.path, err)
+	}
+	return nil
+}
+
+// Stage moves the specifics seen for at most the configured number of runs into the staging definition of their location,
+// and returns their addresses. The addresses that are no longer part of the configuration are forgotten, so they are staged
+// again if they come back. Include ranges are never staged, as they are not individual devices.
+func (s *StagingState) Stage(cfg *discovery.DiscoveryConfiguration, settings StagingSettings) []string {
+	addresses := make(map[string]int)
+	staged := make([]string, 0)
+	stagingDefs := make([]discovery.Definition, 0)
+	for i := range cfg.Definitions {
+		def := &cfg.Definitions[i]
+		if def.ForeignSource == settings.ForeignSource {
+			continue
+		}
+		specifics := make([]discovery.Specific, 0, len(def.Specifics))
+		for _, sp := range def.Specifics {
+			ip := sp.IP.String()
+			runs := settings.Runs + 1
+			if !s.baseline {
+				if runs = s.Addresses[ip] + 1; runs > settings.Runs+1 {
+					runs = settings.Runs + 1
+				}
+			}
+			addresses[ip] = runs
+			if runs > settings.Runs {
+				specifics = append(specifics, sp)
+				continue
+			}
+			sp.ForeignSource = "" // The one from the staging definition applies
+			staging := stagingDefinitionFor(&stagingDefs, def, settings)
+			staging.Specifics = append(staging.Specifics, sp)
+			staged = append(staged, ip)
+		}
+		def.Specifics = specifics
+	}
+	for _, def := range stagingDefs {
+		def.Sort()
+		cfg.AddDefinition(def)
+	}
+	s.Addresses = addresses
+	s.baseline = false
+	sort.Strings(staged)
+	return staged
+}
+
+// stagingDefinitionFor returns the staging definition for the location of the given definition,
+// creating it with its settings and the staging detectors when it doesn't exist
+func stagingDefinitionFor(defs *[]discovery.Definition, def *discovery.Definition, settings StagingSettings) *discovery.Definition {
+	for i := range *defs {
+		if d := &(*defs)[i]; d.Location == def.Location {
+			return d
+		}
+	}
+	staging := discovery.Definition{
+		Name:          strings.TrimSpace(settings.ForeignSource + " " + def.Location),
+		Location:      def.Location,
+		ForeignSource: settings.ForeignSource,
+		ChunkSize:     def.ChunkSize,
+		Retries:       def.Retries,
+		Timeout:       def.Timeout,
+		ExcludeRanges: append([]discovery.ExcludeRange{}, def.ExcludeRanges...),
+	}
+	if discovery.ValidateName(staging.Name) != nil { // The foreign source and the location are not restricted like the names
+		staging.Name = ""
+	}
+	for _, d := range def.Detectors {
+		for _, name := range settings.Detectors {
+			if strings.EqualFold(name, d.Name) {
+				staging.Detectors = append(staging.Detectors, d)
+			}
+		}
+	}
+	*defs = append(*defs, staging)
+	return &(*defs)[len(*defs)-1]
+}
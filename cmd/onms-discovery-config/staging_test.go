@@ -0,0 +1,133 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestStaging(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_staging")
+	if err != nil {
+		t.Fatalf("cannot create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "staging.json")
+	settings := StagingSettings{ForeignSource: "Staging", Runs: 2, Detectors: []string{"icmp"}}
+
+	build := func(addresses ...string) *discovery.DiscoveryConfiguration {
+		cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{{
+			Location:      "Lab",
+			ForeignSource: "Lab",
+			Retries:       2,
+			Detectors:     []discovery.Detector{{Name: "ICMP"}, {Name: "SNMP"}},
+		}}}
+		cfg.Definitions[0].AddIncludeRange("10.0.1.1", "10.0.1.10")
+		for _, ip := range addresses {
+			cfg.Definitions[0].AddSpecificWithAttributes(ip, discovery.Attributes{ForeignSource: "Servers"})
+		}
+		return cfg
+	}
+	run := func(addresses ...string) (*discovery.DiscoveryConfiguration, []string) {
+		state, err := LoadStagingState(stateFile)
+		if err != nil {
+			t.Fatalf("cannot load state: %v", err)
+		}
+		cfg := build(addresses...)
+		staged := state.Stage(cfg, settings)
+		if err := state.Save(); err != nil {
+			t.Fatalf("cannot save state: %v", err)
+		}
+		return cfg, staged
+	}
+
+	// The first run is the baseline
+	if cfg, staged := run("10.0.0.1", "10.0.0.2"); len(staged) != 0 || len(cfg.Definitions) != 1 {
+		t.Fatalf("nothing should be staged on the first run: %v", staged)
+	}
+
+	// A new address stays in staging for 2 runs
+	for i := 1; i <= 2; i++ {
+		cfg, staged := run("10.0.0.1", "10.0.0.2", "10.0.0.3")
+		if strings.Join(staged, ",") != "10.0.0.3" || len(cfg.Definitions) != 2 {
+			t.Fatalf("run %d: only the new address should be staged: %v", i, staged)
+		}
+		if d := cfg.Definitions[0]; len(d.Specifics) != 2 || len(d.IncludeRanges) != 1 {
+			t.Errorf("run %d: the staged address should be removed from the main definition: %s", i, d.String())
+		}
+		d := cfg.Definitions[1]
+		if d.Location != "Lab" || d.ForeignSource != "Staging" || d.Retries != 2 || len(d.Detectors) != 1 || d.Detectors[0].Name != "ICMP" {
+			t.Errorf("run %d: unexpected staging definition: %s", i, d.String())
+		}
+		if len(d.Specifics) != 1 || d.Specifics[0].ForeignSource != "" || len(d.IncludeRanges) != 0 {
+			t.Errorf("run %d: unexpected staging content: %s", i, d.String())
+		}
+	}
+
+	// Then it is promoted, and an address that disappears is forgotten
+	if cfg, staged := run("10.0.0.1", "10.0.0.3"); len(staged) != 0 || len(cfg.Definitions[0].Specifics) != 2 {
+		t.Errorf("the address should be promoted: %v", staged)
+	}
+	if _, staged := run("10.0.0.1", "10.0.0.2", "10.0.0.3"); strings.Join(staged, ",") != "10.0.0.2" {
+		t.Errorf("an address that comes back should be staged again: %v", staged)
+	}
+}
+
+func TestStagingDefinitionName(t *testing.T) {
+	defs := make([]discovery.Definition, 0)
+	if d := stagingDefinitionFor(&defs, &discovery.Definition{Location: "Lab"}, StagingSettings{ForeignSource: "Staging"}); d.Name != "Staging Lab" {
+		t.Errorf("unexpected name: %s", d.Name)
+	}
+	if d := stagingDefinitionFor(&defs, &discovery.Definition{Location: "Lab--2"}, StagingSettings{ForeignSource: "Staging"}); d.Name != "" {
+		t.Errorf("a name that cannot be an XML comment should be dropped: %s", d.Name)
+	}
+}
+
+func TestStagingStateEncrypted(t *testing.T) {
+	t.Setenv(KeyEnvVariable, "my secret passphrase")
+	dir, err := ioutil.TempDir(os.TempDir(), "_staging")
+	if err != nil {
+		t.Fatalf("cannot create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "staging.json")
+
+	state, err := LoadStagingState(stateFile)
+	if err != nil {
+		t.Fatalf("cannot load state: %v", err)
+	}
+	state.Addresses["10.0.0.1"] = 2
+	if err := state.Save(); err != nil {
+		t.Fatalf("cannot save state: %v", err)
+	}
+	info, err := os.Stat(stateFile)
+	if err != nil {
+		t.Fatalf("cannot stat state: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("the state should be readable only by the owner: %v", info.Mode())
+	}
+	data, _ := os.ReadFile(stateFile)
+	if !bytes.HasPrefix(data, encryptedFileHeader) || bytes.Contains(data, []byte("10.0.0.1")) {
+		t.Errorf("the state should be encrypted: %s", string(data))
+	}
+
+	state, err = LoadStagingState(stateFile)
+	if err != nil {
+		t.Fatalf("cannot load encrypted state: %v", err)
+	}
+	if state.baseline || state.Addresses["10.0.0.1"] != 2 {
+		t.Errorf("unexpected state: %+v", state)
+	}
+	t.Setenv(KeyEnvVariable, "")
+	if _, err := LoadStagingState(stateFile); err == nil {
+		t.Errorf("the encrypted state should not be readable without the key")
+	}
+}
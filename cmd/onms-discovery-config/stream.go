@@ -0,0 +1,158 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Streaming processing of large include lists, combining the addresses into ranges as they are read, with bounded memory
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+// streamChunkSize is the number of accepted addresses that are sorted and merged into the ranges at once
+var streamChunkSize = 65536
+
+// streamedSet tracks the addresses added by the streamed sources while building a configuration, which are not tracked by addressWhiteList
+type streamedSet struct {
+	addresses *iprange.IPAddressRangeSet
+	sources   map[string]*iprange.IPAddressRangeSet // The ranges added by each source
+}
+
+func newStreamedSet() *streamedSet {
+	return &streamedSet{addresses: new(iprange.IPAddressRangeSet), sources: make(map[string]*iprange.IPAddressRangeSet)}
+}
+
+// add records the ranges added by a source
+func (s *streamedSet) add(source string, ranges *iprange.IPAddressRangeSet) {
+	s.addresses.Union(ranges)
+	if _, ok := s.sources[source]; !ok {
+		s.sources[source] = new(iprange.IPAddressRangeSet)
+	}
+	s.sources[source].Union(ranges)
+}
+
+// previousSource returns the source that added the IP address, if any; the set is nil when nothing can be streamed
+func (s *streamedSet) previousSource(ip string) (string, bool) {
+	if source, ok := addressWhiteList[ip]; ok {
+		return source, true
+	}
+	addr := net.ParseIP(ip)
+	if s == nil || addr == nil || !s.addresses.Contains(addr) {
+		return "", false
+	}
+	for source, set := range s.sources {
+		if set.Contains(addr) {
+			return source, true
+		}
+	}
+	return "", false
+}
+
+// streamSpecifics reads one IP address per line, applying the same filters as addSpecific, and combines the accepted
+// addresses into a range set instead of tracking each of them in addressWhiteList. The accepted addresses are sorted
+// and merged into the set in chunks, so unsorted lists don't move the ranges of the set on every address. When the
+// reader is done, the ranges of a single address are added to the definition as specifics, and the others as include
+// ranges, so the memory used is proportional to the number of ranges instead of the number of addresses.
+// Duplicates are not checked for conflicting metadata, as the streamed addresses share the attributes of the input.
+func streamSpecifics(def *discovery.Definition, streamed *streamedSet, source string, r io.Reader, attrs discovery.Attributes) error {
+	stats := summary.Source(source)
+	added := new(iprange.IPAddressRangeSet)
+	chunk := make([]netip.Addr, 0, streamChunkSize)
+	duplicate := func(ip, previous string) error {
+		summary.Skip(source, ip, SkipDuplicate)
+		return duplicatePolicy.Apply(fmt.Sprintf("[%s] IP %s from %s already included from %s", SkipDuplicate, ip, source, previous))
+	}
+	flush := func() error {
+		sort.Slice(chunk, func(i, j int) bool { return chunk[i].Less(chunk[j]) })
+		ranges := new(iprange.IPAddressRangeSet) // Adding sorted addresses only appends or extends the last range
+		for i, a := range chunk {
+			if i > 0 && chunk[i-1] == a {
+				if err := duplicate(a.String(), source); err != nil {
+					return err
+				}
+				continue
+			}
+			ip := iprange.FromAddr(a)
+			ranges.Add(iprange.IPAddressRange{Begin: ip, End: ip})
+			stats.Added++
+		}
+		added.Union(ranges)
+		chunk = chunk[:0]
+		return nil
+	}
+	s := newScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		stats.Processed++
+		ip, err := iprange.NormalizeIP(line)
+		if err != nil { // Not an IP Address
+			log.Printf("ignore [%s]: '%s' is not a valid IP address", SkipInvalidIP, line)
+			summary.Skip(source, line, SkipInvalidIP)
+			continue
+		}
+		addr := net.ParseIP(ip)
+		if _, ok := addressBlackList[ip]; ok {
+			log.Printf("ignore [%s]: IP %s is blacklisted", SkipBlacklisted, ip)
+			summary.Skip(source, ip, SkipBlacklisted)
+			continue
+		}
+		if def.ExcludeRangesContain(ip) {
+			log.Printf("ignore [%s]: IP %s is part of exclude ranges", SkipInExcludeRange, ip)
+			summary.Skip(source, ip, SkipInExcludeRange)
+			continue
+		}
+		if def.IncludeRangesContain(ip) {
+			summary.Skip(source, ip, SkipCoveredByRange)
+			if err := duplicatePolicy.Apply(fmt.Sprintf("[%s] IP %s from %s is part of include ranges", SkipCoveredByRange, ip, source)); err != nil {
+				return err
+			}
+			continue
+		}
+		previous, ok := streamed.previousSource(ip)
+		if !ok && added.Contains(addr) {
+			previous, ok = source, true
+		}
+		if ok {
+			if err := duplicate(ip, previous); err != nil {
+				return err
+			}
+			continue
+		}
+		if name, match := nameFilter.Match(ip); match {
+			log.Printf("ignore [%s]: IP %s is excluded by its name %s", SkipExcludedByName, ip, name)
+			summary.Skip(source, ip, SkipExcludedByName)
+			continue
+		}
+		chunk = append(chunk, iprange.ToAddr(addr)) // The duplicates within the chunk are found when sorting it
+		if len(chunk) == streamChunkSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("cannot read %s: %v", source, err)
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	streamed.add(source, added)
+	for _, r := range added.Get() {
+		if r.Begin.Equal(r.End) {
+			def.AddSpecificWithAttributes(r.Begin.String(), attrs)
+		} else if err := def.AddIncludeRangeWithAttributes(r.Begin.String(), r.End.String(), attrs); err != nil {
+			return err
+		}
+	}
+	log.Printf("added %d IP addresses from %s as %d specifics and ranges", stats.Added, source, len(added.Get()))
+	return nil
+}
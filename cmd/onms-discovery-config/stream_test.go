@@ -0,0 +1,102 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Tests for the streaming processing of include lists
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestStreamSpecifics(t *testing.T) {
+	addressBlackList = map[string]bool{"10.0.0.9": true}
+	addressWhiteList = map[string]string{"10.0.0.20": "inc-dns"}
+	streamed := newStreamedSet()
+	duplicatePolicy = DuplicateWarn
+	summary = NewSummary()
+
+	def := &discovery.Definition{}
+	def.ExcludeCIDR("10.0.1.0/24")
+	def.AddIncludeRange("10.0.2.1", "10.0.2.10")
+	list := []string{
+		"10.0.0.1", "10.0.0.2", "010.000.000.003", "10.0.0.4", // Consecutive, one of them not normalized
+		"10.0.0.9",  // Blacklisted
+		"10.0.1.5",  // In the exclude ranges
+		"10.0.2.5",  // Covered by the include ranges
+		"10.0.0.20", // Added by another source
+		"10.0.0.2",  // Duplicate within the list
+		"10.0.0.30", // Single address
+		"not-an-ip", // Invalid
+		"2001:db8::1", "2001:db8::2",
+	}
+	attrs := discovery.Attributes{Retries: 2}
+	if err := streamSpecifics(def, streamed, "inc-list", strings.NewReader(strings.Join(list, "\n")), attrs); err != nil {
+		t.Fatalf("cannot stream the list: %v", err)
+	}
+
+	ranges := make([]string, 0)
+	for _, r := range def.IncludeRanges {
+		ranges = append(ranges, r.Begin.String()+"-"+r.End.String())
+	}
+	if strings.Join(ranges, ",") != "10.0.2.1-10.0.2.10,10.0.0.1-10.0.0.4,2001:db8::1-2001:db8::2" {
+		t.Errorf("the consecutive addresses should become include ranges: %v", ranges)
+	}
+	if len(def.Specifics) != 1 || def.Specifics[0].IP.String() != "10.0.0.30" || def.Specifics[0].Retries != 2 {
+		t.Errorf("the single address should be a specific with the attributes of the input: %s", def.String())
+	}
+	stats := summary.Source("inc-list")
+	if stats.Processed != 13 || stats.Added != 7 {
+		t.Errorf("unexpected statistics: %+v", stats)
+	}
+
+	// The streamed addresses are duplicates for the other sources and definitions
+	other := &discovery.Definition{}
+	if err := addSpecific(other, streamed, "inc-hexnnmi", "10.0.0.3", discovery.Attributes{}); err != nil {
+		t.Fatalf("cannot add specific: %v", err)
+	}
+	if err := addSpecific(other, streamed, "inc-hexnnmi", "10.0.0.5", discovery.Attributes{}); err != nil {
+		t.Fatalf("cannot add specific: %v", err)
+	}
+	if len(other.Specifics) != 1 || other.Specifics[0].IP.String() != "10.0.0.5" {
+		t.Errorf("the streamed addresses should not be added again: %s", other.String())
+	}
+	if source, ok := streamed.previousSource("2001:db8::2"); !ok || source != "inc-list" {
+		t.Errorf("the streamed address should come from inc-list, got %s", source)
+	}
+
+	duplicatePolicy = DuplicateError
+	if err := streamSpecifics(def, streamed, "inc-list", strings.NewReader("10.0.0.1\n"), attrs); err == nil {
+		t.Errorf("the duplicate should fail with the error policy")
+	}
+	duplicatePolicy = DuplicateWarn
+}
+
+func TestStreamSpecificsChunks(t *testing.T) {
+	defer func(size int) { streamChunkSize = size }(streamChunkSize)
+	streamChunkSize = 4
+	addressBlackList = make(map[string]bool)
+	addressWhiteList = make(map[string]string)
+	duplicatePolicy = DuplicateWarn
+	summary = NewSummary()
+
+	list := make([]string, 0)
+	for _, i := range rand.New(rand.NewSource(1)).Perm(100) {
+		list = append(list, fmt.Sprintf("10.0.0.%d", i))
+	}
+	list = append(list, "10.0.0.7", "10.0.0.50", "10.0.0.50") // Duplicates of previous chunks and within the last one
+	def := &discovery.Definition{}
+	if err := streamSpecifics(def, newStreamedSet(), "inc-list", strings.NewReader(strings.Join(list, "\n")), discovery.Attributes{}); err != nil {
+		t.Fatalf("cannot stream the list: %v", err)
+	}
+	if len(def.IncludeRanges) != 1 || def.IncludeRanges[0].Begin.String() != "10.0.0.0" || def.IncludeRanges[0].End.String() != "10.0.0.99" {
+		t.Errorf("the shuffled addresses should become a single range: %s", def.String())
+	}
+	if stats := summary.Source("inc-list"); stats.Processed != 103 || stats.Added != 100 {
+		t.Errorf("unexpected statistics: %+v", stats)
+	}
+}
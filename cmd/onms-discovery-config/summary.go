@@ -0,0 +1,224 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Statistics about the processed sources, reported at the end of a run
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+type DuplicatePolicy string
+
+const (
+	DuplicateWarn  DuplicatePolicy = "warn"
+	DuplicateSkip  DuplicatePolicy = "skip"
+	DuplicateError DuplicatePolicy = "error"
+)
+
+func ParseDuplicatePolicy(name string) (DuplicatePolicy, error) {
+	switch p := DuplicatePolicy(strings.ToLower(name)); p {
+	case DuplicateWarn, DuplicateSkip, DuplicateError:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid duplicate policy %s", name)
+}
+
+// Apply handles a duplicate based on the policy; only DuplicateError returns an error
+func (p DuplicatePolicy) Apply(msg string) error {
+	switch p {
+	case DuplicateError:
+		return fmt.Errorf("duplicate found: %s", msg)
+	case DuplicateWarn:
+		log.Printf("warning: %s", msg)
+	}
+	return nil
+}
+
+// SkipReason identifies why an address from a source was not added to the configuration
+type SkipReason string
+
+const (
+	SkipInvalidIP      SkipReason = "INVALID_IP"
+	SkipBlacklisted    SkipReason = "BLACKLISTED"
+	SkipInExcludeRange SkipReason = "IN_EXCLUDE_RANGE"
+	SkipExcludedByName SkipReason = "EXCLUDED_BY_NAME"
+	SkipCoveredByRange SkipReason = "COVERED_BY_RANGE"
+	SkipDuplicate      SkipReason = "DUPLICATE"
+)
+
+type SourceStats struct {
+	Processed   int `json:"processed"`
+	Added       int `json:"added"`
+	Invalid     int `json:"invalid"`
+	Blacklisted int `json:"blacklisted"`
+	Excluded    int `json:"excluded"`
+	Overlaps    int `json:"overlaps"`   // Addresses covered by include ranges
+	Duplicates  int `json:"duplicates"` // Addresses already added as specifics
+	Conflicts   int `json:"conflicts"`  // Duplicates with different metadata
+
+	Skipped map[SkipReason]int `json:"skipped"`
+}
+
+type Summary struct {
+	Sources            map[string]*SourceStats           `json:"sources"`
+	Duplicates         int                               `json:"duplicates"`
+	Overlaps           int                               `json:"overlaps"`
+	Conflicts          int                               `json:"conflicts"`
+	EstimatedAddresses *big.Int                          `json:"estimatedAddresses"`
+	Skipped            map[SkipReason]int                `json:"skipped"`
+	SwappedRanges      int                               `json:"swappedRanges"`      // Ranges with reversed boundaries
+	DroppedRanges      int                               `json:"droppedRanges"`      // Invalid ranges, or reversed ranges in strict mode
+	Classes            map[iprange.AddressClass]*big.Int `json:"classes,omitempty"`  // Addresses of the scope per address space
+	Families           []discovery.FamilyStats           `json:"families,omitempty"` // IPv4 and IPv6 content per definition
+	Generation         GenerationInfo                    `json:"generation"`
+	Delta              []string                          `json:"delta,omitempty"`             // Entries added to the current configuration with -merge-existing
+	Unclaimed          []string                          `json:"unclaimed,omitempty"`         // Addresses and ranges not claimed by any site
+	Staged             []string                          `json:"staged,omitempty"`            // New addresses placed in the staging definitions
+	DeferredAddresses  *big.Int                          `json:"deferredAddresses,omitempty"` // New addresses deferred to the next runs by -max-additions
+
+	rejects     *csv.Writer // Skipped addresses, written while they are recorded
+	rejectsFile *os.File
+}
+
+func NewSummary() *Summary {
+	return &Summary{
+		Sources:            make(map[string]*SourceStats),
+		Skipped:            make(map[SkipReason]int),
+		EstimatedAddresses: big.NewInt(0),
+	}
+}
+
+// Source returns the statistics for the given source, creating them if necessary
+func (s *Summary) Source(name string) *SourceStats {
+	stats, ok := s.Sources[name]
+	if !ok {
+		stats = &SourceStats{Skipped: make(map[SkipReason]int)}
+		s.Sources[name] = stats
+	}
+	return stats
+}
+
+func (s *Summary) AddDuplicate(source string) {
+	s.Source(source).Duplicates++
+	s.Duplicates++
+}
+
+func (s *Summary) AddOverlap(source string) {
+	s.Source(source).Overlaps++
+	s.Overlaps++
+}
+
+func (s *Summary) AddConflict(source string) {
+	s.Source(source).Conflicts++
+	s.Conflicts++
+}
+
+// Skip records an address from the source that was not added, updating the counters associated with the reason
+func (s *Summary) Skip(source string, ip string, reason SkipReason) {
+	stats := s.Source(source)
+	switch reason {
+	case SkipInvalidIP:
+		stats.Invalid++
+	case SkipBlacklisted:
+		stats.Blacklisted++
+	case SkipInExcludeRange, SkipExcludedByName:
+		stats.Excluded++
+	case SkipCoveredByRange:
+		s.AddOverlap(source)
+	case SkipDuplicate:
+		s.AddDuplicate(source)
+	}
+	stats.Skipped[reason]++
+	s.Skipped[reason]++
+	if s.rejects != nil {
+		s.rejects.Write([]string{source, ip, string(reason)})
+	}
+}
+
+func (s *Summary) String() string {
+	names := make([]string, 0, len(s.Sources))
+	for name := range s.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	for _, name := range names {
+		st := s.Sources[name]
+		fmt.Fprintf(&sb, "%s: processed=%d, added=%d, invalid=%d, blacklisted=%d, excluded=%d, overlaps=%d, duplicates=%d, conflicts=%d\n",
+			name, st.Processed, st.Added, st.Invalid, st.Blacklisted, st.Excluded, st.Overlaps, st.Duplicates, st.Conflicts)
+	}
+	reasons := make([]string, 0, len(s.Skipped))
+	for reason, count := range s.Skipped {
+		reasons = append(reasons, fmt.Sprintf("%s=%d", reason, count))
+	}
+	sort.Strings(reasons)
+	if len(reasons) > 0 {
+		fmt.Fprintf(&sb, "skipped: %s\n", strings.Join(reasons, ", "))
+	}
+	if s.SwappedRanges > 0 || s.DroppedRanges > 0 {
+		fmt.Fprintf(&sb, "ranges swapped=%d, dropped=%d\n", s.SwappedRanges, s.DroppedRanges)
+	}
+	if s.DeferredAddresses != nil && s.DeferredAddresses.Sign() > 0 {
+		fmt.Fprintf(&sb, "deferred addresses=%s\n", s.DeferredAddresses)
+	}
+	classes := make([]string, 0, len(s.Classes))
+	for class, count := range s.Classes {
+		classes = append(classes, fmt.Sprintf("%s=%s", class, count.String()))
+	}
+	sort.Strings(classes)
+	if len(classes) > 0 {
+		fmt.Fprintf(&sb, "address classes: %s\n", strings.Join(classes, ", "))
+	}
+	for i, f := range s.Families {
+		fmt.Fprintf(&sb, "definition %d (%s): ipv4 specifics=%d, ranges=%d, addresses=%s; ipv6 specifics=%d, ranges=%d, addresses=%s\n",
+			i+1, f.Location, f.IPv4Specifics, f.IPv4Ranges, f.IPv4Addresses, f.IPv6Specifics, f.IPv6Ranges, f.IPv6Addresses)
+	}
+	fmt.Fprintf(&sb, "total overlaps=%d, duplicates=%d, conflicts=%d, estimated addresses=%d", s.Overlaps, s.Duplicates, s.Conflicts, s.EstimatedAddresses)
+	return sb.String()
+}
+
+func (s *Summary) Save(fileName string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, data, 0644)
+}
+
+// OpenRejects writes the skipped addresses recorded from now on to the given file as CSV, with the source, the IP address
+// and the reason. They are written as they come, so they are not kept in memory; without a file, only their counters are kept.
+func (s *Summary) OpenRejects(fileName string) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("cannot create rejects file %s: %v", fileName, err)
+	}
+	s.rejectsFile = file
+	s.rejects = csv.NewWriter(file)
+	return s.rejects.Write([]string{"source", "ip", "reason"})
+}
+
+// CloseRejects flushes and closes the file opened by OpenRejects, if any
+func (s *Summary) CloseRejects() error {
+	if s.rejects == nil {
+		return nil
+	}
+	s.rejects.Flush()
+	err := s.rejects.Error()
+	if closeErr := s.rejectsFile.Close(); err == nil {
+		err = closeErr
+	}
+	s.rejects, s.rejectsFile = nil, nil
+	return err
+}
@@ -0,0 +1,105 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestSummary(t *testing.T) {
+	s := NewSummary()
+	s.Source("inc-list").Processed = 3
+	s.AddDuplicate("inc-list")
+	s.AddOverlap("inc-dns")
+	s.AddDuplicate("inc-dns")
+	if s.Duplicates != 2 || s.Overlaps != 1 {
+		t.Errorf("incorrect totals: duplicates=%d, overlaps=%d", s.Duplicates, s.Overlaps)
+	}
+	if s.Source("inc-dns").Duplicates != 1 || s.Source("inc-list").Duplicates != 1 {
+		t.Errorf("incorrect duplicates per source")
+	}
+
+	dir, err := ioutil.TempDir(os.TempDir(), "_summary")
+	if err != nil {
+		t.Fatalf("cannot create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "summary.json")
+	if err := s.Save(file); err != nil {
+		t.Fatalf("cannot save summary: %v", err)
+	}
+	data, _ := ioutil.ReadFile(file)
+	saved := NewSummary()
+	if err := json.Unmarshal(data, saved); err != nil {
+		t.Fatalf("cannot parse summary: %v", err)
+	}
+	if saved.Source("inc-list").Processed != 3 {
+		t.Errorf("incorrect summary: %s", string(data))
+	}
+}
+
+func TestDuplicatePolicy(t *testing.T) {
+	if _, err := ParseDuplicatePolicy("ignore"); err == nil {
+		t.Errorf("ignore should not be a valid policy")
+	}
+	p, err := ParseDuplicatePolicy("ERROR")
+	if err != nil {
+		t.Fatalf("error should be a valid policy: %v", err)
+	}
+	if p.Apply("duplicated") == nil {
+		t.Errorf("the error policy should fail")
+	}
+	if DuplicateWarn.Apply("duplicated") != nil || DuplicateSkip.Apply("duplicated") != nil {
+		t.Errorf("the warn and skip policies should not fail")
+	}
+}
+
+func TestSkip(t *testing.T) {
+	s := NewSummary()
+	file := filepath.Join(t.TempDir(), "rejects.csv")
+	if err := s.OpenRejects(file); err != nil {
+		t.Fatalf("cannot open rejects: %v", err)
+	}
+	s.Skip("inc-list", "bad,ip", SkipInvalidIP)
+	s.Skip("inc-list", "10.0.0.1", SkipDuplicate)
+	s.Skip("inc-dns", "10.0.0.2", SkipCoveredByRange)
+	s.Skip("inc-dns", "10.0.0.3", SkipExcludedByName)
+	if s.Source("inc-list").Invalid != 1 || s.Duplicates != 1 || s.Overlaps != 1 || s.Source("inc-dns").Excluded != 1 {
+		t.Errorf("the counters should be updated based on the reason: %s", s.String())
+	}
+	if s.Skipped[SkipDuplicate] != 1 || s.Source("inc-dns").Skipped[SkipCoveredByRange] != 1 {
+		t.Errorf("incorrect skipped counters: %v", s.Skipped)
+	}
+
+	if err := s.CloseRejects(); err != nil {
+		t.Fatalf("cannot save rejects: %v", err)
+	}
+	s.Skip("inc-list", "10.0.0.4", SkipBlacklisted) // No longer written
+	data, _ := ioutil.ReadFile(file)
+	expected := "source,ip,reason\ninc-list,\"bad,ip\",INVALID_IP\ninc-list,10.0.0.1,DUPLICATE\ninc-dns,10.0.0.2,COVERED_BY_RANGE\ninc-dns,10.0.0.3,EXCLUDED_BY_NAME\n"
+	if string(data) != expected {
+		t.Errorf("unexpected rejects:\n%s", string(data))
+	}
+}
+
+func TestSummaryFamilies(t *testing.T) {
+	def := discovery.Definition{Location: "Lab"}
+	def.AddSpecific("10.0.0.1")
+	def.IncludeCIDR("2001:db8::/120")
+	cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{def}}
+	s := NewSummary()
+	s.Families = cfg.FamilyStats()
+	if !strings.Contains(s.String(), "definition 1 (Lab): ipv4 specifics=1, ranges=0, addresses=1; ipv6 specifics=0, ranges=1") {
+		t.Errorf("incorrect summary: %s", s.String())
+	}
+	if !s.Families[0].SweepsIPv6() {
+		t.Errorf("the definition should sweep IPv6")
+	}
+}
@@ -0,0 +1,73 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Emission of newSuspect events for the specifics, so provisioning doesn't wait for the next Discovery pass
+
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/events"
+)
+
+// newSuspectUEI is the event Discovery sends for each responder, which triggers provisioning
+const newSuspectUEI = "uei.opennms.org/internal/discovery/newSuspect"
+
+// NewSuspects returns a newSuspect event for each specific of the configuration, with the location and foreign source
+// of the specific, or the ones of its definition
+func NewSuspects(cfg *discovery.DiscoveryConfiguration) []events.Event {
+	hostname, _ := os.Hostname()
+	now := time.Now().Format(time.RFC3339)
+	list := make([]events.Event, 0)
+	for _, d := range cfg.Definitions {
+		for _, s := range d.Specifics {
+			event := events.Event{
+				UEI:       newSuspectUEI,
+				Source:    "DiscoverConfigGenerator",
+				Time:      now,
+				Host:      hostname,
+				Interface: s.IP.String(),
+			}
+			location, foreignSource := s.Location, s.ForeignSource
+			if location == "" {
+				location = d.Location
+			}
+			if foreignSource == "" {
+				foreignSource = d.ForeignSource
+			}
+			if location != "" {
+				event.AddParam("location", location)
+			}
+			if foreignSource != "" {
+				event.AddParam("foreignSource", foreignSource)
+			}
+			list = append(list, event)
+		}
+	}
+	return list
+}
+
+// SendNewSuspects sends the newSuspect events of the specifics to eventd, in batches of the given size,
+// and returns how many were delivered; when a batch fails, the ones before it were delivered
+func (o *Options) SendNewSuspects(cfg *discovery.DiscoveryConfiguration, batchSize int, delay time.Duration) (int, error) {
+	suspects := &events.Log{Events: NewSuspects(cfg)}
+	if len(suspects.Events) == 0 {
+		return 0, nil
+	}
+	opts, err := o.eventOptions()
+	if err != nil {
+		return 0, err
+	}
+	opts.Retries, opts.Backoff = o.NotifyRetries, o.NotifyDelay
+	if err := suspects.SendBatchesWithOptions(o.OnmsHost, o.OnmsPort, batchSize, delay, opts); err != nil {
+		var batchErr *events.BatchError
+		if errors.As(err, &batchErr) {
+			return batchErr.Sent, err
+		}
+		return 0, err
+	}
+	return len(suspects.Events), nil
+}
@@ -0,0 +1,78 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/events"
+)
+
+func TestNewSuspects(t *testing.T) {
+	cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{
+		{Location: "DC1", ForeignSource: "Servers", Specifics: []discovery.Specific{
+			{IP: net.ParseIP("10.0.0.1")},
+			{IP: net.ParseIP("10.0.0.2"), Location: "DC2"},
+		}},
+		{Specifics: []discovery.Specific{{IP: net.ParseIP("10.0.1.1")}}},
+	}}
+	suspects := NewSuspects(cfg)
+	if len(suspects) != 3 {
+		t.Fatalf("there should be an event per specific: %d", len(suspects))
+	}
+	params := func(e events.Event) map[string]string {
+		m := make(map[string]string)
+		for _, p := range e.Parameters {
+			m[p.Name] = p.Value.Content
+		}
+		return m
+	}
+	if p := params(suspects[0]); suspects[0].UEI != newSuspectUEI || suspects[0].Interface != "10.0.0.1" || p["location"] != "DC1" || p["foreignSource"] != "Servers" {
+		t.Errorf("the first event should inherit the settings of the definition: %+v", suspects[0])
+	}
+	if p := params(suspects[1]); p["location"] != "DC2" || p["foreignSource"] != "Servers" {
+		t.Errorf("the location of the specific should take precedence: %+v", p)
+	}
+	if len(suspects[2].Parameters) != 0 {
+		t.Errorf("the default location should not be sent: %+v", suspects[2].Parameters)
+	}
+}
+
+func TestSendNewSuspects(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot create TCP server: %v", err)
+	}
+	defer ln.Close()
+	batches := make(chan int, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			buf, _ := ioutil.ReadAll(conn)
+			conn.Close()
+			received := new(events.Log)
+			xml.Unmarshal(buf, received)
+			batches <- len(received.Events)
+		}
+	}()
+
+	cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{{Specifics: []discovery.Specific{
+		{IP: net.ParseIP("10.0.0.1")}, {IP: net.ParseIP("10.0.0.2")}, {IP: net.ParseIP("10.0.0.3")},
+	}}}}
+	opts := &Options{OnmsHost: "127.0.0.1", OnmsPort: ln.Addr().(*net.TCPAddr).Port}
+	sent, err := opts.SendNewSuspects(cfg, 2, time.Millisecond)
+	if err != nil || sent != 3 {
+		t.Fatalf("cannot send the events (%d sent): %v", sent, err)
+	}
+	if first, second := <-batches, <-batches; first != 2 || second != 1 {
+		t.Errorf("unexpected batch sizes: %d, %d", first, second)
+	}
+}
@@ -0,0 +1,376 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Tenants for managed service providers, each one with its own definitions, exclusions, foreign sources and targets,
+// isolated from the content of the others
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+type Tenant struct {
+	Name        string
+	Prefix      string   // Prepended to the foreign sources of the content of the tenant
+	Locations   []string // Locations the content of the tenant can use; empty means all locations
+	ExcludeCIDR string   // Exclusions applied to every definition of the tenant
+	ExcludeList string
+	Output      string    // Path to a file with the configuration of the tenant only
+	Target      *Instance // OpenNMS instance to update with the configuration of the tenant, and of the others using it
+}
+
+// ParseTenant parses a tenant; e.x. name=acme,prefix=ACME-,locations=Paris;Berlin,exc-cidr=/tmp/acme_exc.txt,output=/tmp/acme.xml,home=/mnt/acme/opennms
+func ParseTenant(spec string) (*Tenant, error) {
+	tenant := new(Tenant)
+	var target *Instance
+	for _, entry := range strings.Split(spec, ",") {
+		pair := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("invalid tenant attribute '%s'", entry)
+		}
+		key, value := strings.TrimSpace(pair[0]), strings.TrimSpace(pair[1])
+		if target == nil && (key == "home" || key == "host" || key == "port") {
+			target = &Instance{Port: 5817}
+		}
+		switch key {
+		case "name":
+			tenant.Name = value
+		case "prefix":
+			tenant.Prefix = value
+		case "locations":
+			for _, location := range strings.Split(value, ";") {
+				if location = strings.TrimSpace(location); location != "" {
+					tenant.Locations = append(tenant.Locations, location)
+				}
+			}
+		case "exc-cidr":
+			tenant.ExcludeCIDR = value
+		case "exc-list":
+			tenant.ExcludeList = value
+		case "output":
+			tenant.Output = value
+		case "home":
+			target.Home = value
+		case "host":
+			target.Host = value
+		case "port":
+			port, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tenant port '%s'", value)
+			}
+			target.Port = port
+		default:
+			return nil, fmt.Errorf("unknown tenant attribute '%s'", key)
+		}
+	}
+	if tenant.Name == "" {
+		return nil, fmt.Errorf("the name is required for tenant '%s'", spec)
+	}
+	if target != nil {
+		if target.Home == "" {
+			return nil, fmt.Errorf("the home path is required for the target of tenant %s", tenant.Name)
+		}
+		target.Name = target.Home
+		target.Locations = tenant.Locations
+		tenant.Target = target
+	}
+	if tenant.Output == "" && tenant.Target == nil {
+		return nil, fmt.Errorf("tenant %s requires an output file or the home of its OpenNMS instance", tenant.Name)
+	}
+	return tenant, nil
+}
+
+// Handles returns true when the tenant can use the given location; an empty location means Default
+func (t *Tenant) Handles(location string) bool {
+	return (&Instance{Locations: t.Locations}).Handles(location)
+}
+
+// prepare adds the exclusions of the tenant to a definition before processing its inputs
+func (t *Tenant) prepare(def *discovery.Definition) error {
+	if t.ExcludeCIDR != "" {
+		log.Printf("processing Exclude CIDR %s of tenant %s", t.ExcludeCIDR, t.Name)
+		s, err := getScanner(t.ExcludeCIDR)
+		if err != nil {
+			return err
+		}
+		for s.Scan() {
+			if line := strings.TrimSpace(s.Text()); line != "" {
+				def.ExcludeCIDR(normalizeCIDR(line))
+			}
+		}
+		if err := s.Err(); err != nil {
+			return fmt.Errorf("cannot read %s: %v", t.ExcludeCIDR, err)
+		}
+	}
+	if t.ExcludeList != "" {
+		log.Printf("processing Exclude List %s of tenant %s", t.ExcludeList, t.Name)
+		s, err := getScanner(t.ExcludeList)
+		if err != nil {
+			return err
+		}
+		for s.Scan() {
+			if ip, err := iprange.NormalizeIP(s.Text()); err == nil {
+				addressBlackList[ip] = true
+			}
+		}
+		if err := s.Err(); err != nil {
+			return fmt.Errorf("cannot read %s: %v", t.ExcludeList, err)
+		}
+	}
+	return nil
+}
+
+// Apply marks the definition as owned by the tenant, and adds the prefix to its foreign sources;
+// the definitions without a foreign source use the name of the tenant, so its nodes never share a requisition with another.
+func (t *Tenant) Apply(def *discovery.Definition) {
+	def.Tenant = t.Name
+	if def.ForeignSource == "" {
+		def.ForeignSource = t.Name
+	}
+	def.ForeignSource = t.Prefix + def.ForeignSource
+	for i := range def.Specifics {
+		if s := &def.Specifics[i]; s.ForeignSource != "" {
+			s.ForeignSource = t.Prefix + s.ForeignSource
+		}
+	}
+	for i := range def.IncludeRanges {
+		if r := &def.IncludeRanges[i]; r.ForeignSource != "" {
+			r.ForeignSource = t.Prefix + r.ForeignSource
+		}
+	}
+	for i := range def.IncludeURLs {
+		if u := &def.IncludeURLs[i]; u.ForeignSource != "" {
+			u.ForeignSource = t.Prefix + u.ForeignSource
+		}
+	}
+}
+
+// GetTenants returns the tenants by name; the targets without a host use 'onms-host'
+func (o *Options) GetTenants() (map[string]*Tenant, error) {
+	tenants := make(map[string]*Tenant, len(o.Tenants))
+	for _, spec := range o.Tenants {
+		tenant, err := ParseTenant(spec)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := tenants[tenant.Name]; ok {
+			return nil, fmt.Errorf("duplicate tenant %s", tenant.Name)
+		}
+		if tenant.Target != nil && tenant.Target.Host == "" {
+			tenant.Target.Host = o.OnmsHost
+		}
+		tenants[tenant.Name] = tenant
+	}
+	return tenants, nil
+}
+
+// checkTenants verifies that every definition belongs to a tenant, and rejects the options that add content
+// to any definition regardless of its tenant, or that share state across the tenants
+func (o *Options) checkTenants(tenants map[string]*Tenant, definitions []*DefinitionSpec) error {
+	for _, spec := range definitions {
+		if spec.Tenant == "" {
+			return fmt.Errorf("definition %s requires a tenant", spec.Name)
+		}
+		if _, ok := tenants[spec.Tenant]; !ok {
+			return fmt.Errorf("definition %s refers to an unknown tenant %s", spec.Name, spec.Tenant)
+		}
+	}
+	shared := map[string]bool{
+		"inc-csv":         o.IncludeCSV != "",
+		"inc-ingest":      o.IncludeIngest != "",
+		"inc-webhooks":    o.IncludeWebhooks != "",
+		"site-catalog":    o.SiteCatalog != "",
+		"staging-state":   o.StagingFile != "",
+		"merge-existing":  o.MergeExisting,
+		"stream-lists":    o.StreamLists,
+		"requisition-dir": o.RequisitionDir != "" || o.RequisitionImport,
+		"rest-push":       o.RestPush,
+		"instance":        len(o.Instances) > 0,
+	}
+	names := make([]string, 0)
+	for name, used := range shared {
+		if used {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		return fmt.Errorf("the tenants cannot be combined with %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// FilterByTenant returns a copy of the configuration with only the definitions of the tenant
+func FilterByTenant(cfg *discovery.DiscoveryConfiguration, name string) *discovery.DiscoveryConfiguration {
+	filtered := *cfg
+	filtered.Definitions = make([]discovery.Definition, 0)
+	for _, def := range cfg.Definitions {
+		if def.Tenant == name {
+			filtered.Definitions = append(filtered.Definitions, def)
+		}
+	}
+	return &filtered
+}
+
+// saveTenantOutputs writes the configuration of each tenant with an output file, in the output format
+func saveTenantOutputs(opts *Options, cfg *discovery.DiscoveryConfiguration, serializer discovery.Serializer) error {
+	tenants, err := opts.GetTenants()
+	if err != nil {
+		return err
+	}
+	for _, tenant := range tenants {
+		if tenant.Output == "" {
+			continue
+		}
+		output, err := serializer.Serialize(FilterByTenant(cfg, tenant.Name))
+		if err != nil {
+			return fmt.Errorf("cannot serialize configuration of tenant %s: %v", tenant.Name, err)
+		}
+		if err := os.WriteFile(tenant.Output, output, 0644); err != nil {
+			return fmt.Errorf("cannot save configuration of tenant %s: %v", tenant.Name, err)
+		}
+		log.Printf("configuration of tenant %s saved at %s", tenant.Name, tenant.Output)
+	}
+	return nil
+}
+
+// tenantTargets returns the OpenNMS instances of the tenants, each one with the definitions of all the tenants using it
+func tenantTargets(cfg *discovery.DiscoveryConfiguration, tenants map[string]*Tenant) []deployTarget {
+	owners := make(map[string][]string) // Names of the tenants by home
+	instances := make(map[string]*Instance)
+	for _, tenant := range tenants {
+		if tenant.Target == nil {
+			continue
+		}
+		home := tenant.Target.Home
+		if _, ok := instances[home]; !ok {
+			instances[home] = &Instance{Name: home, Home: home, Host: tenant.Target.Host, Port: tenant.Target.Port}
+		}
+		owners[home] = append(owners[home], tenant.Name)
+	}
+	homes := make([]string, 0, len(instances))
+	for home := range instances {
+		homes = append(homes, home)
+	}
+	sort.Strings(homes)
+	targets := make([]deployTarget, 0, len(homes))
+	for _, home := range homes {
+		names := make(map[string]bool)
+		for _, name := range owners[home] {
+			names[name] = true
+		}
+		filtered := *cfg
+		filtered.Definitions = make([]discovery.Definition, 0)
+		for _, def := range cfg.Definitions {
+			if names[def.Tenant] {
+				filtered.Definitions = append(filtered.Definitions, def)
+			}
+		}
+		targets = append(targets, deployTarget{instances[home], &filtered})
+	}
+	return targets
+}
+
+// tenantRange is an include range or specific of a tenant, to find the overlaps
+type tenantRange struct {
+	begin, end net.IP
+	tenant     string
+}
+
+// CheckTenantIsolation verifies that every definition belongs to a tenant, that the content of each tenant only uses
+// its locations and foreign sources, and that the tenants updating the same OpenNMS instance don't overlap in the same
+// location, as Discovery would assign the addresses of one tenant to the definition of another.
+func CheckTenantIsolation(cfg *discovery.DiscoveryConfiguration, tenants map[string]*Tenant) error {
+	sources := make(map[string]string) // Owner of each foreign source
+	ranges := make(map[string][]tenantRange)
+	problems := make([]string, 0)
+	for i := range cfg.Definitions {
+		def := &cfg.Definitions[i]
+		tenant, ok := tenants[def.Tenant]
+		if !ok {
+			return fmt.Errorf("definition %d doesn't belong to any tenant", i+1)
+		}
+		home := ""
+		if tenant.Target != nil {
+			home = tenant.Target.Home
+		}
+		add := func(begin, end net.IP, location, foreignSource string) {
+			if location == "" {
+				location = def.Location
+			}
+			if foreignSource == "" {
+				foreignSource = def.ForeignSource
+			}
+			if !tenant.Handles(location) {
+				problems = append(problems, fmt.Sprintf("%s-%s of tenant %s uses location %s", begin, end, tenant.Name, location))
+			}
+			if owner, ok := sources[foreignSource]; ok && owner != tenant.Name {
+				problems = append(problems, fmt.Sprintf("foreign source %s is used by tenants %s and %s", foreignSource, owner, tenant.Name))
+			}
+			sources[foreignSource] = tenant.Name
+			if home != "" {
+				key := home + "/" + location
+				ranges[key] = append(ranges[key], tenantRange{begin, end, tenant.Name})
+			}
+		}
+		for _, s := range def.Specifics {
+			add(s.IP, s.IP, s.Location, s.ForeignSource)
+		}
+		for _, r := range def.IncludeRanges {
+			add(r.Begin, r.End, r.Location, r.ForeignSource)
+		}
+	}
+	keys := make([]string, 0, len(ranges))
+	for key := range ranges {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		problems = append(problems, tenantOverlaps(key, ranges[key])...)
+	}
+	if len(problems) > 0 {
+		for _, p := range problems {
+			log.Printf("tenant isolation: %s", p)
+		}
+		return errors.New("the tenants are not isolated; " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// tenantOverlaps returns the ranges of a location that overlap the ranges of another tenant, in a single sorted pass
+// keeping the range that ends last and the one that ends last for a different tenant
+func tenantOverlaps(key string, list []tenantRange) []string {
+	sort.Slice(list, func(i, j int) bool { return iprange.Compare(list[i].begin, list[j].begin) < 0 })
+	problems := make([]string, 0)
+	var first, second *tenantRange // first ends last; second ends last among the other tenants
+	for i := range list {
+		r := &list[i]
+		other := first
+		if other != nil && other.tenant == r.tenant {
+			other = second
+		}
+		if other != nil && iprange.Compare(r.begin, other.end) <= 0 {
+			problems = append(problems, fmt.Sprintf("%s-%s of tenant %s overlaps %s-%s of tenant %s in %s", r.begin, r.end, r.tenant, other.begin, other.end, other.tenant, key))
+		}
+		switch {
+		case first == nil || iprange.Compare(r.end, first.end) > 0:
+			if first != nil && first.tenant != r.tenant {
+				second = first
+			}
+			first = r
+		case r.tenant != first.tenant && (second == nil || iprange.Compare(r.end, second.end) > 0):
+			second = r
+		}
+	}
+	return problems
+}
@@ -0,0 +1,166 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestParseTenant(t *testing.T) {
+	tenant, err := ParseTenant("name=acme,prefix=ACME-,locations=Paris;Berlin,output=/tmp/acme.xml,home=/mnt/acme/opennms,port=5818")
+	if err != nil {
+		t.Fatalf("cannot parse tenant: %v", err)
+	}
+	if tenant.Name != "acme" || tenant.Prefix != "ACME-" || tenant.Output != "/tmp/acme.xml" {
+		t.Errorf("incorrect tenant: %+v", tenant)
+	}
+	if tenant.Target == nil || tenant.Target.Home != "/mnt/acme/opennms" || tenant.Target.Port != 5818 {
+		t.Fatalf("incorrect target: %+v", tenant.Target)
+	}
+	if !tenant.Handles("Berlin") || tenant.Handles("") || !tenant.Target.Handles("Paris") {
+		t.Errorf("incorrect locations: %v", tenant.Locations)
+	}
+	if tenant, err = ParseTenant("name=globex,output=/tmp/globex.xml"); err != nil || tenant.Target != nil || !tenant.Handles("") {
+		t.Errorf("a tenant without target should handle every location: %+v, %v", tenant, err)
+	}
+	for _, spec := range []string{"prefix=X-,output=/tmp/x.xml", "name=x", "name=x,port=5817", "name=x,output=/tmp/x.xml,color=red"} {
+		if _, err := ParseTenant(spec); err == nil {
+			t.Errorf("invalid tenant %s accepted", spec)
+		}
+	}
+	opts := &Options{OnmsHost: "10.0.0.1", Tenants: StringList{"name=acme,home=/mnt/acme", "name=acme,output=/tmp/acme.xml"}}
+	if _, err := opts.GetTenants(); err == nil {
+		t.Errorf("duplicate tenants accepted")
+	}
+	opts.Tenants = opts.Tenants[:1]
+	tenants, err := opts.GetTenants()
+	if err != nil || tenants["acme"].Target.Host != "10.0.0.1" {
+		t.Errorf("the host should default to onms-host: %v", err)
+	}
+}
+
+func TestCheckTenants(t *testing.T) {
+	tenants := map[string]*Tenant{"acme": {Name: "acme", Output: "/tmp/acme.xml"}}
+	opts := &Options{}
+	if err := opts.checkTenants(tenants, []*DefinitionSpec{{Name: "paris", Tenant: "acme"}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := opts.checkTenants(tenants, []*DefinitionSpec{{Name: "paris"}}); err == nil {
+		t.Errorf("a definition without tenant accepted")
+	}
+	if err := opts.checkTenants(tenants, []*DefinitionSpec{{Name: "paris", Tenant: "globex"}}); err == nil {
+		t.Errorf("a definition with an unknown tenant accepted")
+	}
+	opts = &Options{IncludeWebhooks: "/tmp/webhooks.jsonl", SiteCatalog: "/tmp/sites.txt"}
+	err := opts.checkTenants(tenants, nil)
+	if err == nil || !strings.Contains(err.Error(), "inc-webhooks, site-catalog") {
+		t.Errorf("the options shared across tenants should be rejected: %v", err)
+	}
+}
+
+func TestTenantApply(t *testing.T) {
+	tenant := &Tenant{Name: "acme", Prefix: "ACME-"}
+	def := discovery.Definition{}
+	def.AddSpecificWithAttributes("10.0.0.1", discovery.Attributes{ForeignSource: "Servers"})
+	def.AddSpecific("10.0.0.2")
+	def.IncludeCIDR("192.168.0.0/24")
+	tenant.Apply(&def)
+	if def.Tenant != "acme" || def.ForeignSource != "ACME-acme" {
+		t.Errorf("incorrect definition: %s, %s", def.Tenant, def.ForeignSource)
+	}
+	if s := def.GetSpecific("10.0.0.1"); s == nil || s.ForeignSource != "ACME-Servers" {
+		t.Errorf("the foreign source of the specific should have the prefix: %v", s)
+	}
+	if s := def.GetSpecific("10.0.0.2"); s == nil || s.ForeignSource != "" {
+		t.Errorf("the specific should inherit the foreign source of the definition: %v", s)
+	}
+}
+
+// tenantDefinition returns a definition of a tenant including the given CIDR
+func tenantDefinition(tenant *Tenant, location, cidr string) discovery.Definition {
+	def := discovery.Definition{Location: location}
+	def.IncludeCIDR(cidr)
+	tenant.Apply(&def)
+	return def
+}
+
+func TestCheckTenantIsolation(t *testing.T) {
+	acme := &Tenant{Name: "acme", Prefix: "ACME-", Locations: []string{"Paris"}, Target: &Instance{Home: "/mnt/shared"}}
+	globex := &Tenant{Name: "globex", Prefix: "GLOBEX-", Target: &Instance{Home: "/mnt/shared"}}
+	initech := &Tenant{Name: "initech", Output: "/tmp/initech.xml"}
+	tenants := map[string]*Tenant{"acme": acme, "globex": globex, "initech": initech}
+
+	cfg := &discovery.DiscoveryConfiguration{}
+	cfg.AddDefinition(tenantDefinition(acme, "Paris", "10.0.0.0/24"))
+	cfg.AddDefinition(tenantDefinition(acme, "Paris", "10.0.2.0/24"))
+	cfg.AddDefinition(tenantDefinition(globex, "Berlin", "10.0.0.0/24")) // Same instance, another location
+	cfg.AddDefinition(tenantDefinition(globex, "Paris", "10.0.1.0/24"))
+	cfg.AddDefinition(tenantDefinition(initech, "Paris", "10.0.0.0/16")) // Another instance
+	if err := CheckTenantIsolation(cfg, tenants); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	targets := tenantTargets(cfg, tenants)
+	if len(targets) != 1 || targets[0].instance.Home != "/mnt/shared" || len(targets[0].cfg.Definitions) != 4 {
+		t.Errorf("incorrect targets: %v", targets)
+	}
+	if filtered := FilterByTenant(cfg, "initech"); len(filtered.Definitions) != 1 || filtered.Definitions[0].Tenant != "initech" {
+		t.Errorf("incorrect configuration of initech: %s", filtered.String())
+	}
+
+	overlap := *cfg
+	overlap.Definitions = append([]discovery.Definition{}, cfg.Definitions...)
+	overlap.AddDefinition(tenantDefinition(globex, "Paris", "10.0.0.128/25"))
+	err := CheckTenantIsolation(&overlap, tenants)
+	if err == nil || !strings.Contains(err.Error(), "overlaps") {
+		t.Errorf("the overlap in the same instance and location should be rejected: %v", err)
+	}
+
+	location := *cfg
+	location.Definitions = append([]discovery.Definition{}, cfg.Definitions...)
+	location.AddDefinition(tenantDefinition(acme, "Berlin", "10.1.0.0/24"))
+	if err := CheckTenantIsolation(&location, tenants); err == nil {
+		t.Errorf("a location not handled by the tenant should be rejected")
+	}
+
+	shared := *cfg
+	shared.Definitions = append([]discovery.Definition{}, cfg.Definitions...)
+	def := discovery.Definition{Location: "Paris", ForeignSource: "acme"} // The prefix of acme is not ACME-
+	def.IncludeCIDR("10.2.0.0/24")
+	(&Tenant{Name: "initech"}).Apply(&def)
+	def.ForeignSource = "ACME-acme"
+	shared.AddDefinition(def)
+	if err := CheckTenantIsolation(&shared, tenants); err == nil {
+		t.Errorf("a foreign source shared by two tenants should be rejected")
+	}
+
+	shared.AddDefinition(discovery.Definition{})
+	if err := CheckTenantIsolation(&shared, tenants); err == nil {
+		t.Errorf("a definition without tenant should be rejected")
+	}
+}
+
+func TestTenantOverlaps(t *testing.T) {
+	cases := []struct {
+		ranges   [][3]string // begin, end, tenant
+		overlaps int
+	}{
+		{[][3]string{{"10.0.0.1", "10.0.0.10", "a"}, {"10.0.0.11", "10.0.0.20", "b"}}, 0},
+		{[][3]string{{"10.0.0.1", "10.0.0.10", "a"}, {"10.0.0.10", "10.0.0.20", "b"}}, 1},
+		{[][3]string{{"10.0.0.1", "10.0.0.50", "a"}, {"10.0.0.2", "10.0.0.60", "a"}, {"10.0.0.40", "10.0.0.41", "b"}}, 1},
+		{[][3]string{{"10.0.0.1", "10.0.0.50", "b"}, {"10.0.0.2", "10.0.0.60", "a"}, {"10.0.0.55", "10.0.0.56", "a"}}, 1},
+		{[][3]string{{"10.0.0.1", "10.0.0.50", "b"}, {"10.0.0.2", "10.0.0.60", "a"}, {"10.0.0.45", "10.0.0.46", "a"}}, 2},
+	}
+	for i, c := range cases {
+		list := make([]tenantRange, 0, len(c.ranges))
+		for _, r := range c.ranges {
+			list = append(list, tenantRange{net.ParseIP(r[0]), net.ParseIP(r[1]), r[2]})
+		}
+		if problems := tenantOverlaps("Paris", list); len(problems) != c.overlaps {
+			t.Errorf("case %d: expected %d overlaps, got %v", i+1, c.overlaps, problems)
+		}
+	}
+}
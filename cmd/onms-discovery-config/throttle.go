@@ -0,0 +1,66 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Throttling shared by all the external lookups (DNS, ReST APIs), to avoid overwhelming them
+
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Throttle limits the number of concurrent lookups, and how many of them can start per second
+type Throttle struct {
+	sem      chan struct{}
+	interval time.Duration
+	jitter   time.Duration
+	mutex    sync.Mutex
+	next     time.Time
+}
+
+// NewThrottle creates a throttle; zero or negative values disable the corresponding limit
+func NewThrottle(concurrency int, qps float64, jitter time.Duration) *Throttle {
+	t := &Throttle{jitter: jitter}
+	if concurrency > 0 {
+		t.sem = make(chan struct{}, concurrency)
+	}
+	if qps > 0 {
+		t.interval = time.Duration(float64(time.Second) / qps)
+	}
+	return t
+}
+
+// Do executes the function once there is capacity for it; a nil throttle has no limits
+func (t *Throttle) Do(fn func() error) error {
+	if t == nil {
+		return fn()
+	}
+	if t.sem != nil {
+		t.sem <- struct{}{}
+		defer func() { <-t.sem }()
+	}
+	t.wait()
+	return fn()
+}
+
+// wait reserves the next slot based on the rate limit, and sleeps until then
+func (t *Throttle) wait() {
+	var delay time.Duration
+	if t.jitter > 0 {
+		delay = time.Duration(rand.Int63n(int64(t.jitter)))
+	}
+	if t.interval > 0 {
+		t.mutex.Lock()
+		now := time.Now()
+		if t.next.Before(now) {
+			t.next = now
+		}
+		delay += t.next.Sub(now)
+		t.next = t.next.Add(t.interval)
+		t.mutex.Unlock()
+	}
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,53 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestThrottleConcurrency(t *testing.T) {
+	throttle := NewThrottle(2, 0, 0)
+	var current, max int32
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			throttle.Do(func() error {
+				n := atomic.AddInt32(&current, 1)
+				for {
+					m := atomic.LoadInt32(&max)
+					if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				atomic.AddInt32(&current, -1)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	if max > 2 {
+		t.Errorf("there should be at most 2 concurrent executions: %d", max)
+	}
+}
+
+func TestThrottleRate(t *testing.T) {
+	throttle := NewThrottle(0, 100, 0)
+	start := time.Now()
+	for i := 0; i < 11; i++ {
+		throttle.Do(func() error { return nil })
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("11 executions at 100 per second should take at least 100ms: %s", elapsed)
+	}
+	var nilThrottle *Throttle
+	if err := nilThrottle.Do(func() error { return nil }); err != nil {
+		t.Errorf("a nil throttle should execute the function")
+	}
+}
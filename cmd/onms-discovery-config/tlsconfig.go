@@ -0,0 +1,39 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// TLS settings with client certificates and private CAs, shared by the connections to the external services
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// loadTLSConfig returns the TLS settings with the optional client certificate and CA of a service;
+// keyOption is the name of the option for the private key, to report it when missing.
+func loadTLSConfig(service, certFile, keyFile, keyOption, caFile string) (*tls.Config, error) {
+	tlsConfig := new(tls.Config)
+	if certFile != "" {
+		if keyFile == "" {
+			return nil, fmt.Errorf("the private key of the %s client certificate is required; use '%s'", service, keyOption)
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load the %s client certificate: %v", service, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		ca, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the %s CA: %v", service, err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("invalid %s CA %s", service, caFile)
+		}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,50 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/events"
+)
+
+func TestEventOptions(t *testing.T) {
+	opts := &Options{}
+	sendOptions, err := opts.eventOptions()
+	if err != nil || sendOptions.TLS != nil || sendOptions.Timeout != 0 {
+		t.Errorf("TLS should be disabled by default: %+v, %v", sendOptions, err)
+	}
+
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	opts = &Options{OnmsCA: ca, OnmsTimeout: 5 * time.Second}
+	sendOptions, err = opts.eventOptions()
+	if err != nil {
+		t.Fatalf("cannot get the options: %v", err)
+	}
+	if sendOptions.TLS == nil || sendOptions.TLS.RootCAs == nil || sendOptions.Timeout != 5*time.Second {
+		t.Errorf("the CA should enable TLS: %+v", sendOptions)
+	}
+
+	opts = &Options{OnmsTLS: true, OnmsCert: "client.pem"}
+	if _, err := opts.eventOptions(); err == nil {
+		t.Errorf("the private key of the client certificate should be required")
+	}
+	opts.OnmsCA = filepath.Join(t.TempDir(), "missing.pem")
+	opts.OnmsCert = ""
+	if _, err := opts.eventOptions(); err == nil {
+		t.Errorf("a missing CA should fail")
+	}
+
+	opts = &Options{OnmsHost: "127.0.0.1", OnmsPort: 1, OnmsCert: "client.pem"}
+	if err := opts.sendEvent(events.Event{UEI: "uei.opennms.org/test"}); err == nil {
+		t.Errorf("the invalid TLS settings should fail before sending the event")
+	}
+}
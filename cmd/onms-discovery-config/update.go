@@ -0,0 +1,123 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Deployment of the discovery configuration to a local OpenNMS server
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/events"
+)
+
+// ErrNoChanges is returned when the generated configuration is identical to the current one
+var ErrNoChanges = errors.New("there are no differences between the generated and the current configuration; no changes applied")
+
+// ErrNotReloaded is returned when the updated configuration is kept without rollback, but Discovery was not asked to reload it
+var ErrNotReloaded = errors.New("the discovery configuration was updated, but the reload event could not be sent")
+
+// reloadEvent returns the event that asks Discovery to reload its configuration;
+// the releases without reloadDaemonConfig for Discovery use its own event instead
+func reloadEvent() events.Event {
+	hostname, _ := os.Hostname()
+	event := events.Event{
+		UEI:    "uei.opennms.org/internal/reloadDaemonConfig",
+		Source: "DiscoverConfigGenerator",
+		Time:   time.Now().Format(time.RFC3339),
+		Host:   hostname,
+	}
+	if target.Supports(FeatureReloadDaemonConfig) {
+		event.AddParam("daemonName", "Discovery")
+	} else {
+		event.UEI = "uei.opennms.org/internal/discoveryConfigChange"
+	}
+	if generation.Change != "" {
+		event.AddParam("changeTicket", generation.Change)
+	}
+	return event
+}
+
+// UpdatePolicy controls how the reload event is delivered after updating the configuration file
+type UpdatePolicy struct {
+	Retries  int                // Number of additional attempts to send the reload event
+	Delay    time.Duration      // Time to wait before the first retry, doubled after each attempt
+	Rollback bool               // Whether or not to restore the previous configuration when the event cannot be delivered
+	Reload   func() error       // Alternative way to reload the Discovery daemon, instead of sending the event via TCP
+	Events   events.SendOptions // TLS and timeout of the connection to eventd
+}
+
+var DefaultUpdatePolicy = UpdatePolicy{Retries: 3, Delay: 2 * time.Second, Rollback: true}
+
+// UpdateOpenNMS writes the configuration under onmsHomePath, and sends the reload event to eventd at onmsHost and onmsPort,
+// which can be a remote server when the configuration directory is a shared mount.
+func UpdateOpenNMS(cfg *discovery.DiscoveryConfiguration, onmsHomePath string, onmsHost string, onmsPort int) error {
+	return UpdateOpenNMSWithPolicy(cfg, onmsHomePath, onmsHost, onmsPort, DefaultUpdatePolicy)
+}
+
+// UpdateOpenNMSWithPolicy writes the configuration and asks Discovery to reload it, as a transaction:
+// a backup of the previous configuration is kept, and it is restored when the reload event cannot be delivered (if enabled).
+func UpdateOpenNMSWithPolicy(cfg *discovery.DiscoveryConfiguration, onmsHomePath string, onmsHost string, onmsPort int, policy UpdatePolicy) error {
+	if err := checkOnmsHome(onmsHomePath); err != nil {
+		return err
+	}
+	dest := discoveryConfigPath(onmsHomePath)
+	if _, err := os.Stat(dest); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("discovery configuration file not found at %s", dest)
+	}
+	currentBytes, err := ioutil.ReadFile(dest)
+	if err != nil {
+		return fmt.Errorf("cannot read discovery configuration: %v", err)
+	}
+	output := renderConfiguration(cfg)
+	if output == "" {
+		return fmt.Errorf("cannot render the discovery configuration")
+	}
+	if output == renderCurrent(currentBytes) {
+		return ErrNoChanges
+	}
+	mode := fileMode(dest, 0644) // Keep the permissions of the current file
+	if err := os.WriteFile(backupPath(onmsHomePath), currentBytes, mode); err != nil {
+		return fmt.Errorf("cannot backup discovery configuration: %v", err)
+	}
+	if err := os.WriteFile(dest, []byte(generation.Comment()+output), mode); err != nil {
+		return fmt.Errorf("cannot write discovery configuration: %v", err)
+	}
+	reloadLog := new(events.Log)
+	reloadLog.Add(reloadEvent())
+	reload := policy.Reload
+	if reload == nil {
+		reload = func() error { return reloadLog.SendWithOptions(onmsHost, onmsPort, policy.Events) }
+	}
+	rollback := func() error { return os.WriteFile(dest, currentBytes, mode) }
+	return reloadOrRollback(reload, rollback, policy)
+}
+
+// reloadOrRollback asks Discovery to reload the updated configuration, retrying with exponential backoff, and restores
+// the previous configuration via rollback when it cannot be reloaded (if enabled by the policy)
+func reloadOrRollback(reload func() error, rollback func() error, policy UpdatePolicy) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = reload(); err == nil {
+			return nil
+		}
+		if attempt >= policy.Retries {
+			break
+		}
+		delay := events.Backoff(policy.Delay, attempt)
+		log.Printf("cannot send reload event (attempt %d of %d), retrying in %v: %v", attempt+1, policy.Retries+1, delay, err)
+		time.Sleep(delay)
+	}
+	if !policy.Rollback {
+		return fmt.Errorf("%w: %v", ErrNotReloaded, err)
+	}
+	if rbErr := rollback(); rbErr != nil {
+		return fmt.Errorf("cannot send reload event: %v; the rollback of the discovery configuration also failed: %v", err, rbErr)
+	}
+	return fmt.Errorf("cannot send reload event: %v; the previous discovery configuration was restored", err)
+}
@@ -0,0 +1,111 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/events"
+)
+
+func TestUpateOpenNMS(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_discovery")
+	if err != nil {
+		t.Errorf("cannot create temp directory: %v", err)
+	}
+	os.Mkdir(dir+"/etc", 0755)
+	defer os.RemoveAll(dir)
+
+	if err := os.WriteFile(dir+"/etc/discovery-configuration.xml", []byte{}, 0644); err != nil {
+		t.Errorf("cannot create empty discovery configuration")
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot create TCP server: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		if err := UpdateOpenNMS(baseConfig, dir, "127.0.0.1", ln.Addr().(*net.TCPAddr).Port); err != nil {
+			t.Errorf("cannot send event to OpenNMS: %v", err)
+		}
+	}()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Errorf("cannot accept connections: %v", err)
+	}
+	defer conn.Close()
+
+	buf, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Errorf("cannot read content: %v", err)
+	}
+	received := new(events.Log)
+	xml.Unmarshal(buf, received)
+	if received.Events[0].UEI != "uei.opennms.org/internal/reloadDaemonConfig" {
+		t.Errorf("incorrect message received: %s", string(buf))
+	}
+}
+
+func TestUpdateOpenNMSRollback(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(dir+"/etc", 0755)
+	original := []byte("<discovery-configuration></discovery-configuration>")
+	if err := os.WriteFile(dir+"/etc/discovery-configuration.xml", original, 0644); err != nil {
+		t.Fatalf("cannot create discovery configuration")
+	}
+	cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{{}}}
+	cfg.Definitions[0].AddSpecific("10.0.0.1")
+
+	// Nothing listens on the port, so the reload event cannot be delivered
+	policy := UpdatePolicy{Retries: 1, Delay: time.Millisecond, Rollback: true}
+	if err := UpdateOpenNMSWithPolicy(cfg, dir, "127.0.0.1", 50818, policy); err == nil {
+		t.Fatalf("the update should fail")
+	}
+	data, _ := os.ReadFile(dir + "/etc/discovery-configuration.xml")
+	if !bytes.Equal(data, original) {
+		t.Errorf("the previous configuration should be restored: %s", string(data))
+	}
+	if data, _ := os.ReadFile(dir + "/etc/discovery-configuration.xml.bak"); !bytes.Equal(data, original) {
+		t.Errorf("the previous configuration should be backed up: %s", string(data))
+	}
+
+	policy.Rollback = false
+	if err := UpdateOpenNMSWithPolicy(cfg, dir, "127.0.0.1", 50818, policy); !errors.Is(err, ErrNotReloaded) {
+		t.Fatalf("the update should fail without reloading: %v", err)
+	}
+	if data, _ := os.ReadFile(dir + "/etc/discovery-configuration.xml"); string(data) != cfg.String() {
+		t.Errorf("the updated configuration should be kept without rollback: %s", string(data))
+	}
+}
+
+func TestUpdateOpenNMSInvalidName(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "_discovery")
+	if err != nil {
+		t.Fatalf("cannot create temp directory: %v", err)
+	}
+	os.Mkdir(dir+"/etc", 0755)
+	defer os.RemoveAll(dir)
+	current := []byte("<discovery-configuration/>")
+	if err := os.WriteFile(dir+"/etc/discovery-configuration.xml", current, 0644); err != nil {
+		t.Fatalf("cannot create discovery configuration")
+	}
+
+	cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{{Name: "paris-", Location: "Paris"}}}
+	if err := UpdateOpenNMS(cfg, dir, "127.0.0.1", 1); err == nil {
+		t.Errorf("a configuration that cannot be rendered should not be deployed")
+	}
+	if data, _ := os.ReadFile(dir + "/etc/discovery-configuration.xml"); !bytes.Equal(data, current) {
+		t.Errorf("the current configuration should be kept: %s", data)
+	}
+}
@@ -0,0 +1,97 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Retrieval of the encryption passphrase from a HashiCorp Vault secret, so it doesn't have to live in the environment
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VaultKeyEnvVariable is the environment variable with the secret that holds the passphrase, as path#field; e.x.
+// secret/data/onms-discovery#key (the field is 'key' when omitted). The server and the token are taken from the
+// standard VAULT_ADDR and VAULT_TOKEN variables, and the optional VAULT_CACERT and VAULT_NAMESPACE are honored.
+const VaultKeyEnvVariable = "ONMS_DISCOVERY_KEY_VAULT"
+
+// vaultSecrets keeps the passphrases read from Vault within a run, by server and secret
+var vaultSecrets sync.Map
+
+// vaultPassphrase returns the passphrase from the Vault secret set in the environment
+func vaultPassphrase() (string, error) {
+	addr, spec := strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/"), os.Getenv(VaultKeyEnvVariable)
+	if addr == "" {
+		return "", fmt.Errorf("VAULT_ADDR is required to read the passphrase from Vault")
+	}
+	id := addr + "|" + spec
+	if passphrase, ok := vaultSecrets.Load(id); ok {
+		return passphrase.(string), nil
+	}
+	passphrase, err := readVaultSecret(addr, os.Getenv("VAULT_TOKEN"), spec)
+	if err != nil {
+		return "", err
+	}
+	vaultSecrets.Store(id, passphrase)
+	return passphrase, nil
+}
+
+// readVaultSecret returns a field of a secret from Vault, supporting both versions of the KV secrets engine
+func readVaultSecret(addr, token, spec string) (string, error) {
+	path, field := spec, "key"
+	if i := strings.LastIndex(spec, "#"); i >= 0 {
+		path, field = spec[:i], spec[i+1:]
+	}
+	path = strings.Trim(path, "/")
+	if path == "" || field == "" {
+		return "", fmt.Errorf("invalid Vault secret %s; expected path#field", spec)
+	}
+	if token == "" {
+		return "", fmt.Errorf("VAULT_TOKEN is required to read the passphrase from Vault")
+	}
+	tlsConfig, err := loadTLSConfig("Vault", "", "", "", os.Getenv("VAULT_CACERT"))
+	if err != nil {
+		return "", err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	client := &http.Client{Timeout: 30 * time.Second, Transport: transport}
+
+	req, err := http.NewRequest(http.MethodGet, addr+"/v1/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("cannot read the Vault secret %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return "", fmt.Errorf("GET /v1/%s failed with %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	secret := struct {
+		Data map[string]interface{} `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", fmt.Errorf("invalid response from Vault: %v", err)
+	}
+	data := secret.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok { // KV version 2
+		data = nested
+	}
+	value, ok := data[field].(string)
+	if !ok || value == "" {
+		return "", fmt.Errorf("the Vault secret %s doesn't have the field %s", path, field)
+	}
+	return value, nil
+}
@@ -0,0 +1,54 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVaultPassphrase(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/onms-discovery":
+			w.Write([]byte(`{"data":{"data":{"key":"from vault v2"},"metadata":{"version":1}}}`))
+		case "/v1/kv/onms-discovery":
+			w.Write([]byte(`{"data":{"passphrase":"from vault v1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	if value, err := readVaultSecret(server.URL, "s.token", "secret/data/onms-discovery"); err != nil || value != "from vault v2" {
+		t.Errorf("unexpected value from KV version 2: %s, %v", value, err)
+	}
+	if value, err := readVaultSecret(server.URL, "s.token", "kv/onms-discovery#passphrase"); err != nil || value != "from vault v1" {
+		t.Errorf("unexpected value from KV version 1: %s, %v", value, err)
+	}
+	if _, err := readVaultSecret(server.URL, "s.token", "kv/onms-discovery#missing"); err == nil {
+		t.Errorf("a missing field should fail")
+	}
+	if _, err := readVaultSecret(server.URL, "s.invalid", "kv/onms-discovery#passphrase"); err == nil {
+		t.Errorf("an invalid token should fail")
+	}
+
+	t.Setenv(KeyEnvVariable, "")
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "s.token")
+	t.Setenv(VaultKeyEnvVariable, "secret/data/onms-discovery#key")
+	value, err := EncryptValue("admin")
+	if err != nil {
+		t.Fatalf("cannot encrypt value with the passphrase from Vault: %v", err)
+	}
+	t.Setenv(VaultKeyEnvVariable, "")
+	t.Setenv(KeyEnvVariable, "from vault v2")
+	if plain, err := DecryptValue(value); err != nil || plain != "admin" {
+		t.Errorf("the same passphrase should decrypt the value: %s, %v", plain, err)
+	}
+}
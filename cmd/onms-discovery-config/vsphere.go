@@ -0,0 +1,269 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// VMware vCenter as a source of the guest IP addresses of the VMs (reported by VMware Tools) and the management addresses of the ESXi hosts,
+// using the Automation API of vCenter 7.0U2 or newer: https://developer.vmware.com/apis/vsphere-automation/latest/vcenter/
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// vsphereResources are the values accepted by -vsphere-resources
+var vsphereResources = []string{"vms", "hosts", "all"}
+
+// VSphereFilter selects the inventory by the names of its containers; the folders only apply to the VMs
+type VSphereFilter struct {
+	Datacenters []string
+	Clusters    []string
+	Folders     []string
+}
+
+type VSphereClient struct {
+	URL        string // e.x. https://vcenter.example.com
+	User       string
+	Password   string
+	Client     *http.Client
+	LookupHost func(ctx context.Context, host string) ([]string, error) // Resolves the hosts added by name
+	session    string
+}
+
+func NewVSphereClient(url, user, password string, insecure bool) *VSphereClient {
+	transport := apiTransport
+	if insecure {
+		transport = apiTransportWithTLS(&tls.Config{InsecureSkipVerify: true})
+	}
+	return &VSphereClient{
+		URL:        strings.TrimSuffix(url, "/"),
+		User:       user,
+		Password:   password,
+		Client:     &http.Client{Timeout: 60 * time.Second, Transport: transport},
+		LookupHost: net.DefaultResolver.LookupHost,
+	}
+}
+
+// splitNames returns the non-empty entries of a comma separated list
+func splitNames(list string) []string {
+	names := make([]string, 0)
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// vsphereClient returns the client for the URL of 'inc-vsphere' and the filter of the inventory
+func (o *Options) vsphereClient(url string) (*VSphereClient, VSphereFilter, error) {
+	filter := VSphereFilter{
+		Datacenters: splitNames(o.VSphereDatacenters),
+		Clusters:    splitNames(o.VSphereClusters),
+		Folders:     splitNames(o.VSphereFolders),
+	}
+	valid := false
+	for _, r := range vsphereResources {
+		valid = valid || r == o.VSphereResources
+	}
+	if !valid {
+		return nil, filter, fmt.Errorf("invalid vSphere resources %s; valid resources are %s", o.VSphereResources, strings.Join(vsphereResources, ", "))
+	}
+	if o.VSphereUser == "" || o.VSphereSecret == "" {
+		return nil, filter, fmt.Errorf("the vSphere credentials are required; use 'vsphere-user' and 'vsphere-password'")
+	}
+	return NewVSphereClient(url, o.VSphereUser, o.VSphereSecret, o.VSphereInsecure), filter, nil
+}
+
+// do sends a request with the session, creating it when needed, and decodes the JSON response into target
+func (c *VSphereClient) do(method, path string, query url.Values, target interface{}) error {
+	if c.session == "" && path != "/api/session" {
+		if err := c.do(http.MethodPost, "/api/session", nil, &c.session); err != nil {
+			return fmt.Errorf("cannot authenticate with vSphere: %v", err)
+		}
+	}
+	endpoint := c.URL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if path == "/api/session" {
+		req.SetBasicAuth(c.User, c.Password)
+	} else {
+		req.Header.Set("vmware-api-session-id", c.session)
+	}
+	var resp *http.Response
+	err = lookupThrottle.Do(func() error {
+		var e error
+		resp, e = c.Client.Do(req)
+		return e
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s failed with %s: %s", method, path, resp.Status, string(body))
+	}
+	if target == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return fmt.Errorf("invalid response from %s: %v", path, err)
+	}
+	return nil
+}
+
+// Logout removes the session, if any
+func (c *VSphereClient) Logout() {
+	if c.session != "" {
+		if err := c.do(http.MethodDelete, "/api/session", nil, nil); err != nil {
+			log.Printf("cannot close the vSphere session: %v", err)
+		}
+		c.session = ""
+	}
+}
+
+// ids returns the identifiers of the datacenters, clusters or folders with the given names
+func (c *VSphereClient) ids(kind string, names []string, query url.Values) ([]string, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	query["names"] = names
+	results := make([]map[string]string, 0)
+	if err := c.do(http.MethodGet, "/api/vcenter/"+kind, query, &results); err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool)
+	ids := make([]string, 0, len(results))
+	for _, r := range results {
+		ids = append(ids, r[kind])
+		found[r["name"]] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, fmt.Errorf("cannot find vSphere %s %s", kind, name)
+		}
+	}
+	return ids, nil
+}
+
+// query returns the filter of the VMs or hosts by the identifiers of their containers
+func (c *VSphereClient) query(filter VSphereFilter, folderType string) (url.Values, error) {
+	query := url.Values{}
+	containers := []struct {
+		kind  string
+		param string
+		names []string
+	}{
+		{"datacenter", "datacenters", filter.Datacenters},
+		{"cluster", "clusters", filter.Clusters},
+		{"folder", "folders", filter.Folders},
+	}
+	for _, container := range containers {
+		if len(container.names) == 0 || (container.kind == "folder" && folderType == "") {
+			continue
+		}
+		var extra url.Values
+		if container.kind == "folder" {
+			extra = url.Values{"type": {folderType}}
+		}
+		ids, err := c.ids(container.kind, container.names, extra)
+		if err != nil {
+			return nil, err
+		}
+		query[container.param] = ids
+	}
+	return query, nil
+}
+
+// GetGuestAddresses returns the IP addresses reported by VMware Tools for the powered on VMs that match the filter;
+// the VMs without VMware Tools running are skipped, as well as the link-local addresses.
+func (c *VSphereClient) GetGuestAddresses(filter VSphereFilter) ([]string, error) {
+	query, err := c.query(filter, "VIRTUAL_MACHINE")
+	if err != nil {
+		return nil, err
+	}
+	query.Set("power_states", "POWERED_ON")
+	vms := make([]struct {
+		VM   string `json:"vm"`
+		Name string `json:"name"`
+	}, 0)
+	if err := c.do(http.MethodGet, "/api/vcenter/vm", query, &vms); err != nil {
+		return nil, err
+	}
+	addresses := make([]string, 0)
+	for _, vm := range vms {
+		interfaces := make([]struct {
+			IP struct {
+				Addresses []struct {
+					Address string `json:"ip_address"`
+					State   string `json:"state"`
+				} `json:"ip_addresses"`
+			} `json:"ip"`
+		}, 0)
+		if err := c.do(http.MethodGet, "/api/vcenter/vm/"+url.PathEscape(vm.VM)+"/guest/networking/interfaces", nil, &interfaces); err != nil {
+			log.Printf("ignore: cannot get the guest addresses of VM %s: %v", vm.Name, err)
+			continue
+		}
+		for _, intf := range interfaces {
+			for _, a := range intf.IP.Addresses {
+				ip := net.ParseIP(a.Address)
+				if ip == nil || ip.IsLinkLocalUnicast() || ip.IsLoopback() || (a.State != "" && a.State != "PREFERRED") {
+					continue
+				}
+				addresses = append(addresses, ip.String())
+			}
+		}
+	}
+	return addresses, nil
+}
+
+// GetHostAddresses returns the management addresses of the connected ESXi hosts that match the filter, resolving the hosts added by name
+func (c *VSphereClient) GetHostAddresses(filter VSphereFilter) ([]string, error) {
+	query, err := c.query(filter, "")
+	if err != nil {
+		return nil, err
+	}
+	query.Set("connection_states", "CONNECTED")
+	hosts := make([]struct {
+		Name string `json:"name"`
+	}, 0)
+	if err := c.do(http.MethodGet, "/api/vcenter/host", query, &hosts); err != nil {
+		return nil, err
+	}
+	addresses := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if ip := net.ParseIP(host.Name); ip != nil {
+			addresses = append(addresses, ip.String())
+			continue
+		}
+		var resolved []string
+		err := lookupThrottle.Do(func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			var e error
+			resolved, e = c.LookupHost(ctx, host.Name)
+			return e
+		})
+		if err != nil {
+			log.Printf("ignore: cannot resolve the vSphere host %s: %v", host.Name, err)
+			continue
+		}
+		addresses = append(addresses, resolved...)
+	}
+	return addresses, nil
+}
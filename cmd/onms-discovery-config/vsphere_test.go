@@ -0,0 +1,112 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func vsphereTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/session" {
+			switch user, password, _ := r.BasicAuth(); {
+			case r.Method == http.MethodDelete:
+				w.WriteHeader(http.StatusNoContent)
+			case r.Method != http.MethodPost || user != "admin" || password != "secret":
+				w.WriteHeader(http.StatusUnauthorized)
+			default:
+				w.Write([]byte(`"my-session"`))
+			}
+			return
+		}
+		if r.Header.Get("vmware-api-session-id") != "my-session" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		query := r.URL.Query()
+		switch r.URL.Path {
+		case "/api/vcenter/datacenter":
+			w.Write([]byte(`[{"datacenter":"datacenter-1","name":"DC1"}]`))
+		case "/api/vcenter/cluster":
+			w.Write([]byte(`[]`))
+		case "/api/vcenter/folder":
+			if query.Get("type") != "VIRTUAL_MACHINE" {
+				t.Errorf("the folders should be VM folders: %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`[{"folder":"group-v10","name":"Production"}]`))
+		case "/api/vcenter/vm":
+			if query.Get("datacenters") != "datacenter-1" || query.Get("folders") != "group-v10" || query.Get("power_states") != "POWERED_ON" {
+				t.Errorf("the filter was not applied to the VMs: %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`[{"vm":"vm-1","name":"web"},{"vm":"vm-2","name":"db"}]`))
+		case "/api/vcenter/vm/vm-1/guest/networking/interfaces":
+			w.Write([]byte(`[{"ip":{"ip_addresses":[{"ip_address":"10.0.0.10","state":"PREFERRED"},{"ip_address":"fe80::1","state":"PREFERRED"},{"ip_address":"10.0.0.99","state":"DUPLICATE"}]}}]`))
+		case "/api/vcenter/vm/vm-2/guest/networking/interfaces":
+			w.WriteHeader(http.StatusServiceUnavailable) // VMware Tools not running
+		case "/api/vcenter/host":
+			if query.Get("datacenters") != "datacenter-1" || query.Get("folders") != "" {
+				t.Errorf("the filter was not applied to the hosts: %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`[{"host":"host-1","name":"10.0.1.1"},{"host":"host-2","name":"esxi2.example.com"},{"host":"host-3","name":"unknown.example.com"}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestVSphereClient(t *testing.T) {
+	server := vsphereTestServer(t)
+	defer server.Close()
+
+	opts := &Options{VSphereResources: "vms", VSphereUser: "admin", VSphereDatacenters: "DC1", VSphereFolders: "Production"}
+	if _, _, err := opts.vsphereClient(server.URL); err == nil {
+		t.Errorf("the password should be required")
+	}
+	opts.VSphereSecret = "secret"
+	opts.VSphereResources = "templates"
+	if _, _, err := opts.vsphereClient(server.URL); err == nil {
+		t.Errorf("the resources should be invalid")
+	}
+	opts.VSphereResources = "all"
+	client, filter, err := opts.vsphereClient(server.URL)
+	if err != nil {
+		t.Fatalf("cannot create client: %v", err)
+	}
+	defer client.Logout()
+	client.LookupHost = func(ctx context.Context, host string) ([]string, error) {
+		if host == "esxi2.example.com" {
+			return []string{"10.0.1.2"}, nil
+		}
+		return nil, fmt.Errorf("no such host")
+	}
+
+	vms, err := client.GetGuestAddresses(filter)
+	if err != nil {
+		t.Fatalf("cannot get VMs: %v", err)
+	}
+	if strings.Join(vms, ",") != "10.0.0.10" {
+		t.Errorf("unexpected guest addresses: %v", vms)
+	}
+	hosts, err := client.GetHostAddresses(filter)
+	if err != nil {
+		t.Fatalf("cannot get hosts: %v", err)
+	}
+	if strings.Join(hosts, ",") != "10.0.1.1,10.0.1.2" {
+		t.Errorf("unexpected host addresses: %v", hosts)
+	}
+
+	filter.Clusters = []string{"Missing"}
+	if _, err := client.GetHostAddresses(filter); err == nil || !strings.Contains(err.Error(), "Missing") {
+		t.Errorf("an unknown cluster should fail, got %v", err)
+	}
+
+	client = NewVSphereClient(server.URL, "admin", "wrong", false)
+	if _, err := client.GetHostAddresses(VSphereFilter{}); err == nil {
+		t.Errorf("the authentication should fail")
+	}
+}
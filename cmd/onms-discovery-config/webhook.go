@@ -0,0 +1,364 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Webhooks from IPAM tools (NetBox, phpIPAM, or custom) announcing prefix and address changes to the daemon
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+// DefaultQuietPeriod is the time without webhooks the daemon waits before regenerating the configuration
+const DefaultQuietPeriod = time.Minute
+
+// webhookSource is the name of the source of the webhook entries in the summary
+const webhookSource = "inc-webhooks"
+
+// IPAMChange is an address or prefix created, updated or deleted in an IPAM
+type IPAMChange struct {
+	Address       string `json:"address"` // IP address or CIDR
+	Removed       bool   `json:"removed,omitempty"`
+	Location      string `json:"location,omitempty"`
+	ForeignSource string `json:"foreign-source,omitempty"`
+	Retries       int    `json:"retries,omitempty"`
+	Timeout       int    `json:"timeout,omitempty"`
+	Name          string `json:"name,omitempty"`
+}
+
+// Attributes returns the attributes of the specific or include range for the change
+func (c *IPAMChange) Attributes() discovery.Attributes {
+	return discovery.Attributes{Location: c.Location, ForeignSource: c.ForeignSource, Retries: c.Retries, Timeout: c.Timeout}
+}
+
+// IsCIDR returns true when the change is about a prefix
+func (c *IPAMChange) IsCIDR() bool {
+	return strings.Contains(c.Address, "/")
+}
+
+// normalize validates the address or prefix of the change
+func (c *IPAMChange) normalize() error {
+	var err error
+	if c.IsCIDR() {
+		c.Address, err = iprange.NormalizeCIDR(c.Address)
+	} else {
+		c.Address, err = iprange.NormalizeIP(c.Address)
+	}
+	return err
+}
+
+// webhookParsers extract the changes from the payload of each kind of webhook
+var webhookParsers = map[string]func(data []byte) ([]IPAMChange, error){
+	"netbox":  ParseNetBoxWebhook,
+	"phpipam": ParsePhpIPAMWebhook,
+	"custom":  ParseCustomWebhook,
+}
+
+// ParseNetBoxWebhook parses the payload of a NetBox webhook for IP addresses or prefixes; as with the default
+// 'netbox-filter', the ones that are not active are removed.
+func ParseNetBoxWebhook(data []byte) ([]IPAMChange, error) {
+	payload := struct {
+		Event string `json:"event"` // created, updated or deleted
+		Model string `json:"model"` // ipaddress or prefix
+		Data  struct {
+			Address string `json:"address"`
+			Prefix  string `json:"prefix"`
+			DNSName string `json:"dns_name"`
+			Status  struct {
+				Value string `json:"value"`
+			} `json:"status"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("invalid NetBox webhook: %v", err)
+	}
+	change := IPAMChange{Removed: payload.Event == "deleted" || (payload.Data.Status.Value != "" && payload.Data.Status.Value != "active")}
+	switch payload.Model {
+	case "ipaddress":
+		change.Address = strings.SplitN(payload.Data.Address, "/", 2)[0]
+		change.Name = payload.Data.DNSName
+	case "prefix":
+		change.Address = payload.Data.Prefix
+	default:
+		return nil, fmt.Errorf("unsupported NetBox model '%s'; the webhook should be for IP addresses or prefixes", payload.Model)
+	}
+	return []IPAMChange{change}, nil
+}
+
+// ParsePhpIPAMWebhook parses a phpIPAM address or subnet, as returned by its API, with the action that changed it
+func ParsePhpIPAMWebhook(data []byte) ([]IPAMChange, error) {
+	payload := struct {
+		Action string `json:"action"` // add, edit or delete
+		Type   string `json:"type"`   // address or subnet
+		Data   struct {
+			IP       string          `json:"ip"`
+			Hostname string          `json:"hostname"`
+			Subnet   string          `json:"subnet"`
+			Mask     json.RawMessage `json:"mask"` // A string or a number, depending on the version
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("invalid phpIPAM webhook: %v", err)
+	}
+	change := IPAMChange{Removed: payload.Action == "delete"}
+	switch payload.Type {
+	case "address":
+		change.Address, change.Name = payload.Data.IP, payload.Data.Hostname
+	case "subnet":
+		change.Address = payload.Data.Subnet + "/" + strings.Trim(string(payload.Data.Mask), `"`)
+	default:
+		return nil, fmt.Errorf("unsupported phpIPAM type '%s'; the webhook should be for addresses or subnets", payload.Type)
+	}
+	return []IPAMChange{change}, nil
+}
+
+// ParseCustomWebhook parses a list of changes, as {"changes":[{"address":"10.0.0.1","removed":false,...}]}
+func ParseCustomWebhook(data []byte) ([]IPAMChange, error) {
+	payload := struct {
+		Changes []IPAMChange `json:"changes"`
+	}{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("invalid webhook: %v", err)
+	}
+	return payload.Changes, nil
+}
+
+// LoadWebhookEntries reads the addresses and prefixes added via webhooks, one JSON object per line
+func LoadWebhookEntries(fileName string) ([]*IPAMChange, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening file: %v", err)
+	}
+	defer file.Close()
+	entries := make([]*IPAMChange, 0)
+	scanner := newScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		e := new(IPAMChange)
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			return nil, fmt.Errorf("invalid entry at line %d of %s: %v", line, fileName, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// addWebhookEntries adds every entry to the definition that matches its location and foreign-source
+func addWebhookEntries(cfg *discovery.DiscoveryConfiguration, streamed *streamedSet, entries []*IPAMChange) error {
+	for _, e := range entries {
+		attrs := e.Attributes()
+		def := definitionFor(cfg, attrs)
+		if !e.IsCIDR() {
+			if err := addNamedSpecific(def, streamed, webhookSource, e.Address, e.Name, attrs); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := prefixLimit.Check(e.Address); err != nil {
+			return err
+		}
+		log.Printf("including CIDR %s", e.Address)
+		def.IncludeCIDRWithAttributes(e.Address, attrs)
+	}
+	return nil
+}
+
+// WebhookReceiver keeps the addresses and prefixes announced by the webhooks in a file for the runs of generate,
+// and calls changed after each accepted webhook
+type WebhookReceiver struct {
+	Secret      string // Shared secret to verify the webhooks; no verification when empty
+	Incremental bool   // Whether or not to keep the applied changes until drained, for the incremental engine
+	file        string
+	changed     func()
+	entries     map[string]*IPAMChange
+	pending     []IPAMChange
+	mutex       sync.Mutex
+}
+
+// NewWebhookReceiver creates a receiver with the entries from the file, which is created when it doesn't exist
+func NewWebhookReceiver(fileName string, changed func()) (*WebhookReceiver, error) {
+	w := &WebhookReceiver{file: fileName, changed: changed, entries: make(map[string]*IPAMChange)}
+	if _, err := os.Stat(fileName); os.IsNotExist(err) {
+		return w, w.save()
+	}
+	entries, err := LoadWebhookEntries(fileName)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		w.entries[e.Address] = e
+	}
+	return w, nil
+}
+
+// Count returns the number of addresses and prefixes
+func (w *WebhookReceiver) Count() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return len(w.entries)
+}
+
+// Drain returns the changes applied since the last call, when incremental
+func (w *WebhookReceiver) Drain() []IPAMChange {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	changes := w.pending
+	w.pending = nil
+	return changes
+}
+
+// save must be called with the lock
+func (w *WebhookReceiver) save() error {
+	list := make([]*IPAMChange, 0, len(w.entries))
+	for _, e := range w.entries {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, _, _ := net.ParseCIDR(list[i].Address)
+		b, _, _ := net.ParseCIDR(list[j].Address)
+		if a == nil {
+			a = net.ParseIP(list[i].Address)
+		}
+		if b == nil {
+			b = net.ParseIP(list[j].Address)
+		}
+		if c := iprange.Compare(a, b); c != 0 {
+			return c < 0
+		}
+		return list[i].Address < list[j].Address
+	})
+	tmp := w.file + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("cannot save webhook entries: %v", err)
+	}
+	enc := json.NewEncoder(file)
+	for _, e := range list {
+		if err := enc.Encode(e); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("cannot save webhook entries: %v", err)
+	}
+	return os.Rename(tmp, w.file)
+}
+
+// Apply adds, replaces or removes the entries of the changes, returning how many were applied
+func (w *WebhookReceiver) Apply(changes []IPAMChange) (int, error) {
+	for i := range changes { // All or nothing
+		if err := changes[i].normalize(); err != nil {
+			return 0, fmt.Errorf("invalid address or prefix %s: %v", changes[i].Address, err)
+		}
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	applied := 0
+	for _, c := range changes {
+		if c.Removed {
+			if _, ok := w.entries[c.Address]; ok {
+				delete(w.entries, c.Address)
+				w.queue(c)
+				applied++
+			}
+			continue
+		}
+		entry := c
+		w.entries[c.Address] = &entry
+		w.queue(c)
+		applied++
+	}
+	if applied == 0 {
+		return 0, nil
+	}
+	return applied, w.save()
+}
+
+// queue keeps an applied change for the incremental engine; must be called with the lock
+func (w *WebhookReceiver) queue(c IPAMChange) {
+	if w.Incremental {
+		w.pending = append(w.pending, c)
+	}
+}
+
+// verify checks the NetBox signature (X-Hook-Signature, the HMAC-SHA512 of the body) or the bearer token
+func (w *WebhookReceiver) verify(r *http.Request, body []byte) bool {
+	if w.Secret == "" {
+		return true
+	}
+	if signature := r.Header.Get("X-Hook-Signature"); signature != "" {
+		mac := hmac.New(sha512.New, []byte(w.Secret))
+		mac.Write(body)
+		expected := hex.EncodeToString(mac.Sum(nil))
+		return hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected))
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(w.Secret)) == 1
+}
+
+// ServeHTTP receives the webhooks at /webhooks/{netbox,phpipam,custom}; the location and foreign-source query
+// parameters apply to the changes that don't have their own
+func (w *WebhookReceiver) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	kind := strings.TrimPrefix(r.URL.Path, "/webhooks/")
+	parse, ok := webhookParsers[kind]
+	if !ok {
+		http.NotFound(rw, r)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(rw, r.Body, 10<<20))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !w.verify(r, body) {
+		http.Error(rw, "invalid signature or token", http.StatusUnauthorized)
+		return
+	}
+	changes, err := parse(body)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	for i := range changes {
+		if changes[i].Location == "" {
+			changes[i].Location = query.Get("location")
+		}
+		if changes[i].ForeignSource == "" {
+			changes[i].ForeignSource = query.Get("foreign-source")
+		}
+	}
+	applied, err := w.Apply(changes)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("%s webhook with %d changes, %d applied", kind, len(changes), applied)
+	if applied > 0 && w.changed != nil {
+		w.changed()
+	}
+	rw.WriteHeader(http.StatusAccepted)
+}
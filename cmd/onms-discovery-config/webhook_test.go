@@ -0,0 +1,116 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestParseWebhooks(t *testing.T) {
+	changes, err := ParseNetBoxWebhook([]byte(`{"event":"created","model":"ipaddress","data":{"address":"10.0.0.1/24","dns_name":"router1","status":{"value":"active"}}}`))
+	if err != nil || len(changes) != 1 || changes[0] != (IPAMChange{Address: "10.0.0.1", Name: "router1"}) {
+		t.Errorf("unexpected changes: %v %v", changes, err)
+	}
+	changes, _ = ParseNetBoxWebhook([]byte(`{"event":"updated","model":"prefix","data":{"prefix":"10.1.0.0/24","status":{"value":"deprecated"}}}`))
+	if len(changes) != 1 || !changes[0].Removed || changes[0].Address != "10.1.0.0/24" {
+		t.Errorf("a prefix that is not active should be removed: %v", changes)
+	}
+	if _, err := ParseNetBoxWebhook([]byte(`{"event":"created","model":"device","data":{}}`)); err == nil {
+		t.Errorf("a webhook for devices should fail")
+	}
+	changes, _ = ParsePhpIPAMWebhook([]byte(`{"action":"delete","type":"subnet","data":{"subnet":"10.2.0.0","mask":"24"}}`))
+	if len(changes) != 1 || !changes[0].Removed || changes[0].Address != "10.2.0.0/24" {
+		t.Errorf("unexpected changes: %v", changes)
+	}
+	changes, _ = ParsePhpIPAMWebhook([]byte(`{"action":"add","type":"address","data":{"ip":"10.2.0.5","hostname":"srv5"}}`))
+	if len(changes) != 1 || changes[0].Removed || changes[0].Address != "10.2.0.5" || changes[0].Name != "srv5" {
+		t.Errorf("unexpected changes: %v", changes)
+	}
+	changes, _ = ParseCustomWebhook([]byte(`{"changes":[{"address":"10.3.0.1","location":"Paris"},{"address":"10.3.0.2","removed":true}]}`))
+	if len(changes) != 2 || changes[0].Location != "Paris" || !changes[1].Removed {
+		t.Errorf("unexpected changes: %v", changes)
+	}
+}
+
+func TestWebhookReceiver(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "webhooks.jsonl")
+	signals := 0
+	receiver, err := NewWebhookReceiver(file, func() { signals++ })
+	if err != nil {
+		t.Fatalf("cannot create receiver: %v", err)
+	}
+	receiver.Secret = "s3cr3t"
+	receiver.Incremental = true
+	post := func(path, body string, header ...string) int {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		for i := 0; i < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		receiver.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	netbox := `{"event":"created","model":"prefix","data":{"prefix":"10.1.0.0/24","status":{"value":"active"}}}`
+	mac := hmac.New(sha512.New, []byte("s3cr3t"))
+	mac.Write([]byte(netbox))
+	if code := post("/webhooks/netbox?location=Paris", netbox, "X-Hook-Signature", hex.EncodeToString(mac.Sum(nil))); code != http.StatusAccepted {
+		t.Errorf("the signed webhook should be accepted: %d", code)
+	}
+	if code := post("/webhooks/netbox", netbox, "X-Hook-Signature", "bogus"); code != http.StatusUnauthorized {
+		t.Errorf("a webhook with an invalid signature should be rejected: %d", code)
+	}
+	custom := `{"changes":[{"address":"10.3.0.1","name":"srv1"},{"address":"10.3.0.2"}]}`
+	if code := post("/webhooks/custom?foreign-source=IPAM", custom, "Authorization", "Bearer s3cr3t"); code != http.StatusAccepted {
+		t.Errorf("the webhook with the token should be accepted: %d", code)
+	}
+	if code := post("/webhooks/custom", `{"changes":[{"address":"10.3.0.2","removed":true},{"address":"bogus"}]}`, "Authorization", "Bearer s3cr3t"); code != http.StatusBadRequest {
+		t.Errorf("a webhook with an invalid address should be rejected: %d", code)
+	}
+	if code := post("/webhooks/custom", `{"changes":[{"address":"10.3.0.2","removed":true}]}`, "Authorization", "Bearer s3cr3t"); code != http.StatusAccepted {
+		t.Errorf("the removal should be accepted: %d", code)
+	}
+	if code := post("/webhooks/unknown", custom, "Authorization", "Bearer s3cr3t"); code != http.StatusNotFound {
+		t.Errorf("an unknown webhook should fail: %d", code)
+	}
+	if signals != 3 || receiver.Count() != 2 {
+		t.Errorf("unexpected state: %d signals, %d entries", signals, receiver.Count())
+	}
+	if changes := receiver.Drain(); len(changes) != 4 || !changes[3].Removed || len(receiver.Drain()) != 0 {
+		t.Errorf("unexpected pending changes: %+v", changes)
+	}
+
+	entries, err := LoadWebhookEntries(file)
+	if err != nil {
+		t.Fatalf("cannot load entries: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Address != "10.1.0.0/24" || entries[0].Location != "Paris" || entries[1].ForeignSource != "IPAM" || entries[1].Name != "srv1" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	restarted, err := NewWebhookReceiver(file, nil)
+	if err != nil || restarted.Count() != 2 {
+		t.Errorf("the entries should be kept across restarts: %v", err)
+	}
+
+	reset := func() {
+		addressWhiteList, nodeMetaData, nodeLabels = make(map[string]string), make(map[string]map[string]string), make(map[string]string)
+	}
+	reset()
+	defer reset()
+	cfg := &discovery.DiscoveryConfiguration{Definitions: []discovery.Definition{{}}}
+	if err := addWebhookEntries(cfg, newStreamedSet(), entries); err != nil {
+		t.Fatalf("cannot add entries: %v", err)
+	}
+	if len(cfg.Definitions) != 3 || len(cfg.Definitions[1].IncludeRanges) != 1 || len(cfg.Definitions[2].Specifics) != 1 || nodeLabels["10.3.0.1"] != "srv1" {
+		t.Errorf("unexpected configuration: %+v", cfg.Definitions)
+	}
+}
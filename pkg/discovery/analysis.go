@@ -0,0 +1,156 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Read-only analysis of an existing discovery configuration, independent of how it was generated
+
+package discovery
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+// DefaultMaxRangeSize is the size of a /16, above which an include range is considered oversized
+var DefaultMaxRangeSize = big.NewInt(65536)
+
+// DefinitionAnalysis contains the statistics of a definition
+type DefinitionAnalysis struct {
+	FamilyStats
+	ForeignSource      string   `json:"foreignSource,omitempty"`
+	ExcludeRanges      int      `json:"excludeRanges"`
+	IncludeURLs        int      `json:"includeUrls"`
+	EstimatedAddresses *big.Int `json:"estimatedAddresses"`
+}
+
+// Analysis contains the statistics and the findings about a configuration
+type Analysis struct {
+	Definitions        []DefinitionAnalysis              `json:"definitions"`
+	EstimatedAddresses *big.Int                          `json:"estimatedAddresses"`
+	Classes            map[iprange.AddressClass]*big.Int `json:"classes"`
+	Overlaps           []string                          `json:"overlaps"`       // Elements that cover addresses already covered by other elements
+	Oversized          []string                          `json:"oversized"`      // Include ranges bigger than the maximum size
+	UnusedExcludes     []string                          `json:"unusedExcludes"` // Exclude ranges that don't affect any element of their definition
+}
+
+// element is an include range or a specific of a given definition
+type element struct {
+	definition int
+	kind       string
+	ipr        iprange.IPAddressRange
+	begin, end netip.Addr // IPv4 sorts before IPv6
+}
+
+func (e *element) String() string {
+	if e.kind == "specific" {
+		return fmt.Sprintf("specific %s", e.ipr.Begin)
+	}
+	return fmt.Sprintf("include-range %s", e.ipr.String())
+}
+
+// overlaps returns the elements that cover addresses already covered by another element,
+// comparing each element against the one that reaches the farthest among the previous ones
+func overlaps(elements []*element) []string {
+	sort.SliceStable(elements, func(i, j int) bool {
+		a, b := elements[i], elements[j]
+		if c := a.begin.Compare(b.begin); c != 0 {
+			return c < 0
+		}
+		return a.end.Compare(b.end) > 0 // The broader element first
+	})
+	results := make([]string, 0)
+	var farthest *element
+	for _, e := range elements {
+		if farthest != nil && e.begin.Compare(farthest.end) <= 0 {
+			if farthest.definition == e.definition {
+				results = append(results, fmt.Sprintf("definition %d: %s overlaps %s", e.definition+1, e.String(), farthest.String()))
+			} else {
+				results = append(results, fmt.Sprintf("definitions %d and %d: %s overlaps %s", farthest.definition+1, e.definition+1, e.String(), farthest.String()))
+			}
+		}
+		if farthest == nil || e.end.Compare(farthest.end) > 0 {
+			farthest = e
+		}
+	}
+	return results
+}
+
+// Analyze returns the statistics of the configuration, the overlapping elements (within and across definitions),
+// the include ranges bigger than maxRangeSize, and the exclude ranges that have no effect.
+func (cfg *DiscoveryConfiguration) Analyze(maxRangeSize *big.Int) *Analysis {
+	a := &Analysis{
+		Definitions:        make([]DefinitionAnalysis, 0, len(cfg.Definitions)),
+		EstimatedAddresses: cfg.GetTotalEstimatedAddresses(),
+		Classes:            cfg.Classify(),
+		Oversized:          make([]string, 0),
+		UnusedExcludes:     make([]string, 0),
+	}
+	elements := make([]*element, 0)
+	add := func(def int, kind string, ipr iprange.IPAddressRange) *element {
+		e := &element{definition: def, kind: kind, ipr: ipr, begin: iprange.ToAddr(ipr.Begin), end: iprange.ToAddr(ipr.End)}
+		elements = append(elements, e)
+		return e
+	}
+	for i := range cfg.Definitions {
+		def := &cfg.Definitions[i]
+		a.Definitions = append(a.Definitions, DefinitionAnalysis{
+			FamilyStats:        def.FamilyStats(),
+			ForeignSource:      def.ForeignSource,
+			ExcludeRanges:      len(def.ExcludeRanges),
+			IncludeURLs:        len(def.IncludeURLs),
+			EstimatedAddresses: def.GetTotalEstimatedAddresses(),
+		})
+		included := make([]iprange.IPAddressRange, 0, len(def.Specifics)+len(def.IncludeRanges))
+		for _, s := range def.Specifics {
+			included = append(included, add(i, "specific", s.ToIPAddressRange()).ipr)
+		}
+		for _, r := range def.IncludeRanges {
+			e := add(i, "include-range", r.ToIPAddressRange())
+			included = append(included, e.ipr)
+			if size := e.ipr.Size(); maxRangeSize != nil && size.Cmp(maxRangeSize) > 0 {
+				a.Oversized = append(a.Oversized, fmt.Sprintf("definition %d: %s has %s addresses", i+1, e.String(), size.String()))
+			}
+		}
+		for _, r := range def.ExcludeRanges {
+			ipr := r.ToIPAddressRange()
+			used := false
+			for _, inc := range included {
+				used = used || ipr.Overlaps(inc)
+			}
+			if !used {
+				a.UnusedExcludes = append(a.UnusedExcludes, fmt.Sprintf("definition %d: exclude-range %s", i+1, ipr.String()))
+			}
+		}
+	}
+	a.Overlaps = overlaps(elements)
+	return a
+}
+
+// String returns a human readable version of the analysis
+func (a *Analysis) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "definitions: %d, estimated addresses: %s\n", len(a.Definitions), a.EstimatedAddresses.String())
+	for i, d := range a.Definitions {
+		fmt.Fprintf(&sb, "definition %d (location=%s, foreign-source=%s): ipv4 specifics=%d ranges=%d addresses=%s; ipv6 specifics=%d ranges=%d addresses=%s; exclude-ranges=%d; include-urls=%d\n",
+			i+1, d.Location, d.ForeignSource, d.IPv4Specifics, d.IPv4Ranges, d.IPv4Addresses, d.IPv6Specifics, d.IPv6Ranges, d.IPv6Addresses, d.ExcludeRanges, d.IncludeURLs)
+	}
+	classes := make([]string, 0, len(a.Classes))
+	for class, count := range a.Classes {
+		classes = append(classes, fmt.Sprintf("%s=%s", class, count.String()))
+	}
+	sort.Strings(classes)
+	fmt.Fprintf(&sb, "address space: %s\n", strings.Join(classes, ", "))
+	for _, o := range a.Overlaps {
+		fmt.Fprintf(&sb, "overlap: %s\n", o)
+	}
+	for _, o := range a.Oversized {
+		fmt.Fprintf(&sb, "oversized: %s\n", o)
+	}
+	for _, u := range a.UnusedExcludes {
+		fmt.Fprintf(&sb, "unused exclude: %s\n", u)
+	}
+	return sb.String()
+}
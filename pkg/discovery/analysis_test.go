@@ -0,0 +1,48 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package discovery
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	d1 := Definition{Location: "Default"}
+	d1.IncludeCIDR("10.0.0.0/24")
+	d1.AddSpecific("10.0.0.10")
+	d1.IncludeCIDR("172.16.0.0/12")
+	d1.ExcludeCIDR("192.168.0.0/24")
+	d2 := Definition{Location: "Lab"}
+	d2.IncludeCIDR("10.0.0.128/25")
+	d2.AddSpecific("2001:db8::1")
+	cfg := &DiscoveryConfiguration{Definitions: []Definition{d1, d2}}
+
+	a := cfg.Analyze(DefaultMaxRangeSize)
+	if len(a.Definitions) != 2 || a.Definitions[0].ExcludeRanges != 1 || a.Definitions[1].IPv6Specifics != 1 {
+		t.Errorf("unexpected definitions: %+v", a.Definitions)
+	}
+	if len(a.Overlaps) != 2 {
+		t.Fatalf("there should be 2 overlaps: %v", a.Overlaps)
+	}
+	if a.Overlaps[0] != "definition 1: specific 10.0.0.10 overlaps include-range 10.0.0.1 -> 10.0.0.254" {
+		t.Errorf("unexpected overlap: %s", a.Overlaps[0])
+	}
+	if a.Overlaps[1] != "definitions 1 and 2: include-range 10.0.0.129 -> 10.0.0.254 overlaps include-range 10.0.0.1 -> 10.0.0.254" {
+		t.Errorf("unexpected overlap: %s", a.Overlaps[1])
+	}
+	if len(a.Oversized) != 1 || !strings.Contains(a.Oversized[0], "has 1048574 addresses") {
+		t.Errorf("unexpected oversized ranges: %v", a.Oversized)
+	}
+	if len(a.UnusedExcludes) != 1 || a.UnusedExcludes[0] != "definition 1: exclude-range 192.168.0.1 -> 192.168.0.254" {
+		t.Errorf("unexpected unused excludes: %v", a.UnusedExcludes)
+	}
+	if !strings.Contains(a.String(), "unused exclude: definition 1") {
+		t.Errorf("unexpected report: %s", a.String())
+	}
+
+	if a := cfg.Analyze(big.NewInt(1 << 21)); len(a.Oversized) != 0 {
+		t.Errorf("there should be no oversized ranges: %v", a.Oversized)
+	}
+}
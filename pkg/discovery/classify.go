@@ -0,0 +1,38 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Breakdown of the scope of a configuration by address class, to verify at a glance that no unexpected public space is scanned
+
+package discovery
+
+import (
+	"math/big"
+
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+// Classify returns the number of addresses of the scope on each class, without the exclude ranges.
+// The content of the include URLs is unknown, so it is not considered.
+func (cfg *DiscoveryConfiguration) Classify() map[iprange.AddressClass]*big.Int {
+	classes := make(map[iprange.AddressClass]*big.Int)
+	for _, def := range cfg.Definitions {
+		scope := new(iprange.IPAddressRangeSet)
+		for _, r := range def.IncludeRanges {
+			scope.Add(r.ToIPAddressRange())
+		}
+		for _, s := range def.Specifics {
+			scope.Add(s.ToIPAddressRange())
+		}
+		for _, r := range def.ExcludeRanges {
+			scope.Remove(r.ToIPAddressRange())
+		}
+		for _, r := range scope.Get() {
+			for class, count := range r.Classify() {
+				if classes[class] == nil {
+					classes[class] = big.NewInt(0)
+				}
+				classes[class].Add(classes[class], count)
+			}
+		}
+	}
+	return classes
+}
@@ -0,0 +1,39 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package discovery
+
+import (
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+func TestClassify(t *testing.T) {
+	d := Definition{}
+	d.IncludeCIDR("10.0.0.0/24")                      // 254 RFC1918
+	d.AddIncludeRange("100.63.255.255", "100.64.0.9") // 1 PUBLIC and 10 CGN
+	d.AddSpecific("8.8.8.8")                          // 1 PUBLIC
+	d.AddSpecific("127.0.0.1")                        // 1 SPECIAL
+	d.AddSpecific("fd00::1")                          // 1 ULA
+	d.AddSpecific("2001:db8::1")                      // 1 GUA
+	d.AddSpecific("fe80::1")                          // 1 SPECIAL
+	d.AddExcludeRange("10.0.0.1", "10.0.0.4")
+	cfg := &DiscoveryConfiguration{Definitions: []Definition{d}}
+	expected := map[iprange.AddressClass]int64{
+		iprange.ClassRFC1918: 250,
+		iprange.ClassCGN:     10,
+		iprange.ClassPublic:  2,
+		iprange.ClassSpecial: 2,
+		iprange.ClassULA:     1,
+		iprange.ClassGUA:     1,
+	}
+	classes := cfg.Classify()
+	if len(classes) != len(expected) {
+		t.Errorf("unexpected classes: %v", classes)
+	}
+	for class, count := range expected {
+		if classes[class] == nil || classes[class].Int64() != count {
+			t.Errorf("%s: expected %d, got %v", class, count, classes[class])
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Helper functions to compare a discovery configuration against an authoritative inventory
+
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+// CoverageReport contains the inventory addresses covered and not covered by a configuration
+type CoverageReport struct {
+	Inventory int                      // Total number of addresses from the inventory
+	Covered   int                      // Number of inventory addresses that would be discovered
+	Invalid   []string                 // Inventory entries that are not valid IP addresses
+	Uncovered []string                 // Inventory addresses that are not part of the configuration
+	Unused    []iprange.IPAddressRange // Configuration ranges and specifics without inventory addresses
+}
+
+// String returns a human readable version of the coverage report
+func (r *CoverageReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "inventory addresses: %d, covered: %d, uncovered: %d, invalid: %d\n", r.Inventory, r.Covered, len(r.Uncovered), len(r.Invalid))
+	for _, ip := range r.Uncovered {
+		fmt.Fprintf(&sb, "not covered: %s\n", ip)
+	}
+	for _, ip := range r.Invalid {
+		fmt.Fprintf(&sb, "invalid: %s\n", ip)
+	}
+	for _, ipr := range r.Unused {
+		fmt.Fprintf(&sb, "without inventory: %s\n", ipr.String())
+	}
+	return sb.String()
+}
+
+// Covers returns true when the IP address would be discovered by the definition
+func (def *Definition) Covers(ipaddr string) bool {
+	ip := net.ParseIP(ipaddr)
+	if ip == nil || def.ExcludeRangesContain(ipaddr) {
+		return false
+	}
+	for _, s := range def.Specifics {
+		if s.IP.Equal(ip) {
+			return true
+		}
+	}
+	for _, r := range def.IncludeRanges {
+		ipr := r.ToIPAddressRange()
+		if ipr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers returns true when the IP address would be discovered by any of the definitions
+func (cfg *DiscoveryConfiguration) Covers(ipaddr string) bool {
+	for i := range cfg.Definitions {
+		if cfg.Definitions[i].Covers(ipaddr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Coverage verifies which addresses from the inventory are not covered by the configuration,
+// and which ranges or specifics from the configuration have no addresses from the inventory.
+func (cfg *DiscoveryConfiguration) Coverage(inventory []string) *CoverageReport {
+	report := &CoverageReport{
+		Inventory: len(inventory),
+		Invalid:   make([]string, 0),
+		Uncovered: make([]string, 0),
+		Unused:    make([]iprange.IPAddressRange, 0),
+	}
+	valid := make([]net.IP, 0, len(inventory))
+	for _, ipaddr := range inventory {
+		ip := net.ParseIP(ipaddr)
+		if ip == nil {
+			report.Invalid = append(report.Invalid, ipaddr)
+			continue
+		}
+		valid = append(valid, ip)
+		if cfg.Covers(ipaddr) {
+			report.Covered++
+		} else {
+			report.Uncovered = append(report.Uncovered, ipaddr)
+		}
+	}
+	hasInventory := func(ipr iprange.IPAddressRange) bool {
+		for _, ip := range valid {
+			if ipr.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, def := range cfg.Definitions {
+		for _, r := range def.IncludeRanges {
+			if ipr := r.ToIPAddressRange(); !hasInventory(ipr) {
+				report.Unused = append(report.Unused, ipr)
+			}
+		}
+		for _, s := range def.Specifics {
+			if ipr := s.ToIPAddressRange(); !hasInventory(ipr) {
+				report.Unused = append(report.Unused, ipr)
+			}
+		}
+	}
+	return report
+}
@@ -0,0 +1,53 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package discovery
+
+import (
+	"testing"
+)
+
+func TestCovers(t *testing.T) {
+	def := new(Definition)
+	def.IncludeCIDR("192.168.0.0/24")
+	def.ExcludeCIDR("192.168.0.0/28")
+	def.AddSpecific("10.0.0.1")
+	if !def.Covers("192.168.0.100") {
+		t.Errorf("address 192.168.0.100 should be covered")
+	}
+	if !def.Covers("10.0.0.1") {
+		t.Errorf("address 10.0.0.1 should be covered")
+	}
+	if def.Covers("192.168.0.10") {
+		t.Errorf("address 192.168.0.10 should not be covered as it is excluded")
+	}
+	if def.Covers("172.16.0.1") {
+		t.Errorf("address 172.16.0.1 should not be covered")
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	def := Definition{}
+	def.IncludeCIDR("192.168.0.0/24")
+	def.IncludeCIDR("192.168.1.0/24")
+	def.AddSpecific("10.0.0.1")
+	def.AddSpecific("10.0.0.2")
+	cfg := DiscoveryConfiguration{
+		Definitions: []Definition{def},
+	}
+	report := cfg.Coverage([]string{"192.168.0.10", "10.0.0.1", "172.16.0.1", "bad-ip"})
+	if report.Inventory != 4 {
+		t.Errorf("the inventory should have 4 entries: %d", report.Inventory)
+	}
+	if report.Covered != 2 {
+		t.Errorf("there should be 2 covered addresses: %d", report.Covered)
+	}
+	if len(report.Uncovered) != 1 || report.Uncovered[0] != "172.16.0.1" {
+		t.Errorf("address 172.16.0.1 should be the only uncovered address: %v", report.Uncovered)
+	}
+	if len(report.Invalid) != 1 {
+		t.Errorf("there should be 1 invalid entry: %v", report.Invalid)
+	}
+	if len(report.Unused) != 2 {
+		t.Errorf("there should be 2 entries without inventory: %v", report.Unused)
+	}
+}
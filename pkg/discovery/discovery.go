@@ -0,0 +1,753 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Representation and helper functions for discovery-configuration.xml
+// https://github.com/OpenNMS/opennms/blob/master/opennms-config-model/src/main/resources/xsds/discovery-configuration.xsd
+
+// Package discovery provides the model of the OpenNMS discovery configuration, with the means to build, optimize and compare it.
+package discovery
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+// Parameter represents a key/value setting of a detector
+type Parameter struct {
+	XMLName xml.Name `xml:"parameter" json:"-" yaml:"-"`
+	Key     string   `xml:"key,attr" json:"key" yaml:"key"`
+	Value   string   `xml:"value,attr" json:"value" yaml:"value"`
+}
+
+// Detector represents a service detector used to verify discovered addresses
+type Detector struct {
+	XMLName    xml.Name    `xml:"detector" json:"-" yaml:"-"`
+	Name       string      `xml:"name,attr" json:"name" yaml:"name"`
+	Class      string      `xml:"class-name,attr" json:"class-name" yaml:"class-name"`
+	Parameters []Parameter `xml:"parameter,omitempty" json:"parameters,omitempty" yaml:"parameters,omitempty"`
+}
+
+// Specific represents a single IP address to discover
+type Specific struct {
+	XMLName       xml.Name `xml:"specific" json:"-" yaml:"-"`
+	IP            net.IP   `xml:",chardata" json:"ip" yaml:"ip"`
+	Location      string   `xml:"location,attr,omitempty" json:"location,omitempty" yaml:"location,omitempty"`
+	Retries       int      `xml:"retries,attr,omitempty" json:"retries,omitempty" yaml:"retries,omitempty"`
+	Timeout       int      `xml:"timeout,attr,omitempty" json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	ForeignSource string   `xml:"foreign-source,attr,omitempty" json:"foreign-source,omitempty" yaml:"foreign-source,omitempty"`
+}
+
+// Attributes returns the optional attributes of the specific
+func (s *Specific) Attributes() Attributes {
+	return Attributes{
+		Location:      s.Location,
+		Retries:       s.Retries,
+		Timeout:       s.Timeout,
+		ForeignSource: s.ForeignSource,
+	}
+}
+
+// SetAttributes overrides the optional attributes of the specific
+func (s *Specific) SetAttributes(attrs Attributes) {
+	s.Location = attrs.Location
+	s.Retries = attrs.Retries
+	s.Timeout = attrs.Timeout
+	s.ForeignSource = attrs.ForeignSource
+}
+
+// ToIPAddressRange returns the specific as a singleton range
+func (s *Specific) ToIPAddressRange() iprange.IPAddressRange {
+	return iprange.IPAddressRange{
+		Location:      s.Location,
+		Retries:       s.Retries,
+		Timeout:       s.Timeout,
+		ForeignSource: s.ForeignSource,
+		Begin:         s.IP,
+		End:           s.IP,
+	}
+}
+
+// IncludeRange represents a range of IP addresses to discover
+type IncludeRange struct {
+	XMLName       xml.Name `xml:"include-range" json:"-" yaml:"-"`
+	Location      string   `xml:"location,attr,omitempty" json:"location,omitempty" yaml:"location,omitempty"`
+	Retries       int      `xml:"retries,attr,omitempty" json:"retries,omitempty" yaml:"retries,omitempty"`
+	Timeout       int      `xml:"timeout,attr,omitempty" json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	ForeignSource string   `xml:"foreign-source,attr,omitempty" json:"foreign-source,omitempty" yaml:"foreign-source,omitempty"`
+	Begin         net.IP   `xml:"begin" json:"begin" yaml:"begin"`
+	End           net.IP   `xml:"end" json:"end" yaml:"end"`
+}
+
+// Attributes returns the optional attributes of the include range
+func (r *IncludeRange) Attributes() Attributes {
+	return Attributes{
+		Location:      r.Location,
+		Retries:       r.Retries,
+		Timeout:       r.Timeout,
+		ForeignSource: r.ForeignSource,
+	}
+}
+
+// ToIPAddressRange returns the include range as an IP address range
+func (r *IncludeRange) ToIPAddressRange() iprange.IPAddressRange {
+	return iprange.IPAddressRange{
+		Location:      r.Location,
+		Retries:       r.Retries,
+		Timeout:       r.Timeout,
+		ForeignSource: r.ForeignSource,
+		Begin:         r.Begin,
+		End:           r.End,
+	}
+}
+
+// ExcludeRange represents a range of IP addresses to skip
+type ExcludeRange struct {
+	XMLName  xml.Name `xml:"exclude-range" json:"-" yaml:"-"`
+	Location string   `xml:"location,attr,omitempty" json:"location,omitempty" yaml:"location,omitempty"`
+	Begin    net.IP   `xml:"begin" json:"begin" yaml:"begin"`
+	End      net.IP   `xml:"end" json:"end" yaml:"end"`
+}
+
+// ToIPAddressRange returns the exclude range as an IP address range
+func (r *ExcludeRange) ToIPAddressRange() iprange.IPAddressRange {
+	return iprange.IPAddressRange{
+		Location: r.Location,
+		Begin:    r.Begin,
+		End:      r.End,
+	}
+}
+
+// IncludeURL represents a URL with a list of IP addresses to discover
+type IncludeURL struct {
+	XMLName       xml.Name `xml:"include-url" json:"-" yaml:"-"`
+	Content       string   `xml:",chardata" json:"url" yaml:"url"`
+	Location      string   `xml:"location,attr,omitempty" json:"location,omitempty" yaml:"location,omitempty"`
+	Retries       int      `xml:"retries,attr,omitempty" json:"retries,omitempty" yaml:"retries,omitempty"`
+	Timeout       int      `xml:"timeout,attr,omitempty" json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	ForeignSource string   `xml:"foreign-source,attr,omitempty" json:"foreign-source,omitempty" yaml:"foreign-source,omitempty"`
+}
+
+// Definition represents a group of ranges and specifics sharing the same discovery settings
+type Definition struct {
+	XMLName       xml.Name       `xml:"definition" json:"-" yaml:"-"`
+	Name          string         `xml:"-" json:"name,omitempty" yaml:"name,omitempty"`         // Logical name, as a comment before the definition in XML
+	Priority      int            `xml:"-" json:"priority,omitempty" yaml:"priority,omitempty"` // Position in the output, higher first, as Discovery uses the first matching definition
+	Tenant        string         `xml:"-" json:"tenant,omitempty" yaml:"tenant,omitempty"`     // Owner of the definition in multi-tenant runs
+	Location      string         `xml:"location,attr,omitempty" json:"location,omitempty" yaml:"location,omitempty"`
+	Retries       int            `xml:"retries,attr,omitempty" json:"retries,omitempty" yaml:"retries,omitempty"`
+	Timeout       int            `xml:"timeout,attr,omitempty" json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	ForeignSource string         `xml:"foreign-source,attr,omitempty" json:"foreign-source,omitempty" yaml:"foreign-source,omitempty"`
+	ChunkSize     int            `xml:"chunkSize,attr,omitempty" json:"chunk-size,omitempty" yaml:"chunk-size,omitempty"`
+	Detectors     []Detector     `xml:"detectors>detector,omitempty" json:"detectors,omitempty" yaml:"detectors,omitempty"`
+	Specifics     []Specific     `xml:"specific,omitempty" json:"specifics,omitempty" yaml:"specifics,omitempty"`
+	IncludeRanges []IncludeRange `xml:"include-range,omitempty" json:"include-ranges,omitempty" yaml:"include-ranges,omitempty"`
+	ExcludeRanges []ExcludeRange `xml:"exclude-range,omitempty" json:"exclude-ranges,omitempty" yaml:"exclude-ranges,omitempty"`
+	IncludeURLs   []IncludeURL   `xml:"include-url,omitempty" json:"include-urls,omitempty" yaml:"include-urls,omitempty"`
+
+	includeIndex *rangeIndex // For IncludeRangesContain, replaced by Reindex
+	excludeIndex *rangeIndex // For ExcludeRangesContain, replaced by Reindex
+}
+
+// Attributes are the optional settings for specifics and include ranges
+type Attributes struct {
+	Location      string
+	Retries       int
+	Timeout       int
+	ForeignSource string
+}
+
+// AddSpecific adds a single IP address to the definition
+func (def *Definition) AddSpecific(specific string) {
+	def.AddSpecificWithAttributes(specific, Attributes{})
+}
+
+// AddSpecificWithAttributes adds a single IP address with optional attributes to the definition
+func (def *Definition) AddSpecificWithAttributes(specific string, attrs Attributes) {
+	if ip := net.ParseIP(specific); ip == nil {
+		return
+	} else {
+		def.Specifics = append(def.Specifics, Specific{
+			IP:            ip,
+			Location:      attrs.Location,
+			Retries:       attrs.Retries,
+			Timeout:       attrs.Timeout,
+			ForeignSource: attrs.ForeignSource,
+		})
+	}
+}
+
+// GetSpecific returns the specific for the given IP address, or nil if it doesn't exist
+func (def *Definition) GetSpecific(ipaddr string) *Specific {
+	ip := net.ParseIP(ipaddr)
+	if ip == nil {
+		return nil
+	}
+	for i := range def.Specifics {
+		if def.Specifics[i].IP.Equal(ip) {
+			return &def.Specifics[i]
+		}
+	}
+	return nil
+}
+
+// AddIncludeURL adds a URL with IP addresses to the definition
+func (def *Definition) AddIncludeURL(url string) {
+	def.AddIncludeURLWithAttributes(url, Attributes{})
+}
+
+// AddIncludeURLWithAttributes adds a URL with IP addresses and optional attributes to the definition
+func (def *Definition) AddIncludeURLWithAttributes(url string, attrs Attributes) {
+	def.IncludeURLs = append(def.IncludeURLs, IncludeURL{
+		Content:       url,
+		Location:      attrs.Location,
+		Retries:       attrs.Retries,
+		Timeout:       attrs.Timeout,
+		ForeignSource: attrs.ForeignSource,
+	})
+}
+
+// ErrReversedRange is returned in strict mode when the end of a range comes before its beginning
+var ErrReversedRange = errors.New("the end of the range comes before its beginning")
+
+// RangeValidator checks the boundaries of the ranges added to the definitions, swapping them when reversed (unless Strict
+// is enabled), and counts the swapped and dropped ranges. It is safe for concurrent use.
+type RangeValidator struct {
+	Strict  bool                                     // Rejects reversed ranges instead of swapping their boundaries
+	Logf    func(format string, args ...interface{}) // Optional, to report the swapped and dropped ranges
+	mutex   sync.Mutex
+	swapped int
+	dropped int
+}
+
+// DefaultRangeValidator validates the ranges added via AddIncludeRange and AddExcludeRange
+var DefaultRangeValidator = new(RangeValidator)
+
+// Counts returns the number of ranges with reversed boundaries, and of invalid ranges (or reversed ranges in strict mode)
+func (v *RangeValidator) Counts() (swapped int, dropped int) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.swapped, v.dropped
+}
+
+func (v *RangeValidator) logf(format string, args ...interface{}) {
+	if v.Logf != nil {
+		v.Logf(format, args...)
+	}
+}
+
+// Validate parses the boundaries of a range, swapping them when reversed (unless Strict is enabled).
+// Invalid ranges are dropped, and the swapped and dropped ranges are counted.
+func (v *RangeValidator) Validate(begin, end string) (net.IP, net.IP, error) {
+	beginIP := net.ParseIP(begin)
+	endIP := net.ParseIP(end)
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if beginIP == nil || endIP == nil || (beginIP.To4() == nil) != (endIP.To4() == nil) {
+		v.dropped++
+		v.logf("ignore: invalid range %s-%s", begin, end)
+		return nil, nil, fmt.Errorf("invalid range %s-%s", begin, end)
+	}
+	if iprange.Compare(endIP, beginIP) < 0 {
+		if v.Strict {
+			v.dropped++
+			return nil, nil, fmt.Errorf("invalid range %s-%s: %w", begin, end, ErrReversedRange)
+		}
+		v.swapped++
+		v.logf("warning: swapping the boundaries of the reversed range %s-%s", begin, end)
+		beginIP, endIP = endIP, beginIP
+	}
+	return beginIP, endIP, nil
+}
+
+// AddIncludeRange adds a range of IP addresses to discover, validated by DefaultRangeValidator
+func (def *Definition) AddIncludeRange(begin, end string) error {
+	return def.AddIncludeRangeWithAttributes(begin, end, Attributes{})
+}
+
+// AddIncludeRangeWithAttributes adds a range of IP addresses with optional attributes, validated by DefaultRangeValidator
+func (def *Definition) AddIncludeRangeWithAttributes(begin, end string, attrs Attributes) error {
+	return DefaultRangeValidator.AddIncludeRange(def, begin, end, attrs)
+}
+
+// AddExcludeRange adds a range of IP addresses to skip, validated by DefaultRangeValidator
+func (def *Definition) AddExcludeRange(begin, end string) error {
+	return DefaultRangeValidator.AddExcludeRange(def, begin, end)
+}
+
+// AddIncludeRange adds a validated range of IP addresses with optional attributes to discover
+func (v *RangeValidator) AddIncludeRange(def *Definition, begin, end string, attrs Attributes) error {
+	beginIP, endIP, err := v.Validate(begin, end)
+	if err != nil {
+		return err
+	}
+	def.addIncludeRange(beginIP, endIP, attrs)
+	return nil
+}
+
+// AddExcludeRange adds a validated range of IP addresses to skip
+func (v *RangeValidator) AddExcludeRange(def *Definition, begin, end string) error {
+	beginIP, endIP, err := v.Validate(begin, end)
+	if err != nil {
+		return err
+	}
+	def.ExcludeRanges = append(def.ExcludeRanges, ExcludeRange{Begin: beginIP, End: endIP})
+	def.excludeIndex = new(rangeIndex)
+	return nil
+}
+
+func (def *Definition) addIncludeRange(begin, end net.IP, attrs Attributes) {
+	def.IncludeRanges = append(def.IncludeRanges, IncludeRange{
+		Location:      attrs.Location,
+		Retries:       attrs.Retries,
+		Timeout:       attrs.Timeout,
+		ForeignSource: attrs.ForeignSource,
+		Begin:         begin,
+		End:           end,
+	})
+	def.includeIndex = new(rangeIndex)
+}
+
+// IncludeCIDR adds the range of IP addresses of a CIDR to discover
+func (def *Definition) IncludeCIDR(cidr string) {
+	def.IncludeCIDRWithAttributes(cidr, Attributes{})
+}
+
+// IncludeCIDRWithAttributes adds the range of IP addresses of a CIDR with optional attributes to discover.
+// The ranges of the CIDRs are valid by construction, so they are not validated.
+func (def *Definition) IncludeCIDRWithAttributes(cidr string, attrs Attributes) {
+	if ipBegin, ipEnd, err := def.getRange(cidr); err == nil {
+		def.addIncludeRange(ipBegin.To16(), ipEnd.To16(), attrs)
+	}
+}
+
+// ExcludeCIDR adds the range of IP addresses of a CIDR to skip
+func (def *Definition) ExcludeCIDR(cidr string) {
+	if ipBegin, ipEnd, err := def.getRange(cidr); err == nil {
+		def.ExcludeRanges = append(def.ExcludeRanges, ExcludeRange{Begin: ipBegin.To16(), End: ipEnd.To16()})
+		def.excludeIndex = new(rangeIndex)
+	}
+}
+
+// IncludeRangesContain returns true if the IP address is part of any include range
+func (def *Definition) IncludeRangesContain(ipaddr string) bool {
+	ip := net.ParseIP(ipaddr)
+	if ip == nil || len(def.IncludeRanges) == 0 {
+		return false
+	}
+	return def.includeIndex.contains(ip, &def.IncludeRanges[0], len(def.IncludeRanges), def.includeRanges)
+}
+
+// ExcludeRangesContain returns true if the IP address is part of any exclude range
+func (def *Definition) ExcludeRangesContain(ipaddr string) bool {
+	ip := net.ParseIP(ipaddr)
+	if ip == nil || len(def.ExcludeRanges) == 0 {
+		return false
+	}
+	return def.excludeIndex.contains(ip, &def.ExcludeRanges[0], len(def.ExcludeRanges), def.excludeRanges)
+}
+
+// Sort sorts the ranges and specifics of the definition
+func (def *Definition) Sort() {
+	sort.SliceStable(def.Specifics, func(i, j int) bool {
+		return lessRange(def.Specifics[i].ToIPAddressRange(), def.Specifics[j].ToIPAddressRange())
+	})
+
+	sort.SliceStable(def.IncludeRanges, func(i, j int) bool {
+		return lessRange(def.IncludeRanges[i].ToIPAddressRange(), def.IncludeRanges[j].ToIPAddressRange())
+	})
+
+	sort.SliceStable(def.ExcludeRanges, func(i, j int) bool {
+		return lessRange(def.ExcludeRanges[i].ToIPAddressRange(), def.ExcludeRanges[j].ToIPAddressRange())
+	})
+	def.Reindex()
+}
+
+// lessRange orders the ranges by their boundaries and then by their attributes, so the order
+// doesn't depend on the order of the inputs
+func lessRange(a, b iprange.IPAddressRange) bool {
+	if c := iprange.Compare(a.Begin, b.Begin); c != 0 {
+		return c < 0
+	}
+	if c := iprange.Compare(a.End, b.End); c != 0 {
+		return c < 0
+	}
+	if a.Location != b.Location {
+		return a.Location < b.Location
+	}
+	if a.ForeignSource != b.ForeignSource {
+		return a.ForeignSource < b.ForeignSource
+	}
+	if a.Retries != b.Retries {
+		return a.Retries < b.Retries
+	}
+	return a.Timeout < b.Timeout
+}
+
+// rangeSet returns the combined specifics and include ranges of a sorted definition. The elements with attributes
+// are added first (specifics before include ranges), so their attributes are kept for the addresses they share
+// with the elements without attributes.
+func (def *Definition) rangeSet() *iprange.IPAddressRangeSet {
+	annotated := make([]iprange.IPAddressRange, 0)
+	plain := make([]iprange.IPAddressRange, 0)
+	add := func(r iprange.IPAddressRange) {
+		if r.SameAttributes(iprange.IPAddressRange{}) {
+			plain = append(plain, r)
+		} else {
+			annotated = append(annotated, r)
+		}
+	}
+	for _, s := range def.Specifics {
+		add(s.ToIPAddressRange())
+	}
+	for _, r := range def.IncludeRanges {
+		add(r.ToIPAddressRange())
+	}
+	rangeSet := new(iprange.IPAddressRangeSet)
+	for _, r := range append(annotated, plain...) {
+		rangeSet.Add(r)
+	}
+	return rangeSet
+}
+
+// excludeSet returns the combined exclude ranges
+func (def *Definition) excludeSet() *iprange.IPAddressRangeSet {
+	excludeSet := new(iprange.IPAddressRangeSet)
+	for _, r := range def.ExcludeRanges {
+		excludeSet.Add(r.ToIPAddressRange())
+	}
+	return excludeSet
+}
+
+// Merge combines overlapping and adjacent ranges with the same attributes, and removes specifics covered by the include ranges
+func (def *Definition) Merge() {
+	def.Sort()
+	def.setRanges(def.rangeSet().Get())
+
+	excludeSet := def.excludeSet()
+	def.ExcludeRanges = make([]ExcludeRange, 0)
+	for _, r := range excludeSet.Get() {
+		def.ExcludeRanges = append(def.ExcludeRanges, ExcludeRange{
+			Location: r.Location,
+			Begin:    r.Begin,
+			End:      r.End,
+		})
+	}
+	def.Reindex()
+}
+
+// MergeWithSubtraction merges the content like Merge, and then carves the exclude ranges out of the include ranges and specifics,
+// so OpenNMS doesn't have to evaluate them. The exclude ranges are kept when there are include URLs, as they also apply to them.
+func (def *Definition) MergeWithSubtraction() {
+	def.Merge()
+	if len(def.IncludeURLs) > 0 || len(def.ExcludeRanges) == 0 {
+		return
+	}
+	rangeSet := def.rangeSet()
+	rangeSet.Subtract(def.excludeSet())
+	def.setRanges(rangeSet.Get())
+	def.ExcludeRanges = make([]ExcludeRange, 0)
+	def.Reindex()
+}
+
+// setRanges replaces the specifics and include ranges with the given ranges, using specifics for the singletons
+func (def *Definition) setRanges(ranges []iprange.IPAddressRange) {
+	def.Specifics = make([]Specific, 0)
+	def.IncludeRanges = make([]IncludeRange, 0)
+	for _, r := range ranges {
+		if r.IsSingleton() {
+			def.Specifics = append(def.Specifics, Specific{
+				Location:      r.Location,
+				Retries:       r.Retries,
+				Timeout:       r.Timeout,
+				ForeignSource: r.ForeignSource,
+				IP:            r.Begin,
+			})
+		} else {
+			def.IncludeRanges = append(def.IncludeRanges, IncludeRange{
+				Location:      r.Location,
+				Retries:       r.Retries,
+				Timeout:       r.Timeout,
+				ForeignSource: r.ForeignSource,
+				Begin:         r.Begin,
+				End:           r.End,
+			})
+		}
+	}
+}
+
+// IsEmpty returns true when the definition has no content
+func (def *Definition) IsEmpty() bool {
+	return len(def.Specifics) == 0 && len(def.IncludeRanges) == 0 && len(def.IncludeURLs) == 0
+}
+
+// GetTotalEstimatedAddresses offers an estimate about the potential total number of IP addresses to consider for discovery.
+// The sizes of the ranges are computed arithmetically, subtracting their intersections with the exclude ranges.
+// It ignores the external files.
+func (def *Definition) GetTotalEstimatedAddresses() *big.Int {
+	excludes := def.excludeIntervals()
+	total := big.NewInt(0)
+	for _, r := range def.IncludeRanges {
+		ipr := r.ToIPAddressRange()
+		total.Add(total, ipr.Size())
+		total.Sub(total, excludes.intersection(newInterval(r.Begin, r.End)))
+	}
+	for _, s := range def.Specifics {
+		if excludes.intersection(newInterval(s.IP, s.IP)).Sign() == 0 {
+			total.Add(total, big.NewInt(1))
+		}
+	}
+	return total
+}
+
+// ValidateName verifies that the name of a definition can be written as an XML comment
+func ValidateName(name string) error {
+	if strings.Contains(name, "--") || strings.HasSuffix(name, "-") {
+		return fmt.Errorf("the name '%s' cannot contain '--' or end with '-', as it is added as an XML comment", name)
+	}
+	return nil
+}
+
+// namedDefinition matches the name written by MarshalXML before a definition, and the indentation of the definition
+var namedDefinition = regexp.MustCompile(`(<!--(?:[^-]|-[^-])*-->)(\n *)<definition`)
+
+// MarshalXML writes the name of the definition as a comment before its element
+func (def Definition) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "definition"}
+	if def.Name != "" {
+		if err := ValidateName(def.Name); err != nil {
+			return err
+		}
+		if err := e.EncodeToken(xml.Comment(def.Name)); err != nil {
+			return err
+		}
+	}
+	type plain Definition // Without MarshalXML, to avoid the recursion
+	return e.EncodeElement(plain(def), start)
+}
+
+// String returns the XML representation of the definition, or an empty string when it cannot be marshalled
+func (def *Definition) String() string {
+	data, err := xml.MarshalIndent(def, "", "   ")
+	if err != nil {
+		log.Printf("cannot marshal the definition for %s: %v", def.Location, err)
+	}
+	return string(bytes.Replace(data, []byte("--><definition"), []byte("-->\n<definition"), 1))
+}
+
+// getRange returns the usable addresses of a CIDR, without the network and broadcast addresses (or the Subnet-Router
+// anycast and the last address for IPv6). Point-to-point (/31 or /127) and host (/32 or /128) CIDRs keep all their addresses.
+func (def *Definition) getRange(cidr string) (net.IP, net.IP, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, nil, err
+	}
+	lastIP := make(net.IP, len(network.IP))
+	for i := range network.IP {
+		lastIP[i] = network.IP[i] | ^network.Mask[i]
+	}
+	if ones, bits := network.Mask.Size(); bits-ones <= 1 {
+		return network.IP, lastIP, nil
+	}
+	return iprange.Offset(network.IP, 1), iprange.Offset(lastIP, -1), nil
+}
+
+// interval is a range of addresses, where IPv4 sorts before IPv6 so the families don't mix
+type interval struct {
+	begin, end netip.Addr
+}
+
+func newInterval(begin, end net.IP) interval {
+	return interval{begin: iprange.ToAddr(begin), end: iprange.ToAddr(end)}
+}
+
+// intervals are sorted and non-overlapping
+type intervals []interval
+
+// excludeIntervals returns the exclude ranges as sorted and non-overlapping intervals
+func (def *Definition) excludeIntervals() intervals {
+	list := make(intervals, 0, len(def.ExcludeRanges))
+	for _, r := range def.ExcludeRanges {
+		list = append(list, newInterval(r.Begin, r.End))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].begin.Less(list[j].begin)
+	})
+	merged := make(intervals, 0, len(list))
+	for _, i := range list {
+		if n := len(merged); n > 0 && i.begin.Compare(merged[n-1].end) <= 0 {
+			if i.end.Compare(merged[n-1].end) > 0 {
+				merged[n-1].end = i.end
+			}
+			continue
+		}
+		merged = append(merged, i)
+	}
+	return merged
+}
+
+// intersection returns the number of addresses of the given interval covered by the list
+func (list intervals) intersection(target interval) *big.Int {
+	total := big.NewInt(0)
+	for _, i := range list {
+		if i.end.Compare(target.begin) < 0 || i.begin.Compare(target.end) > 0 {
+			continue
+		}
+		begin, end := i.begin, i.end
+		if target.begin.Compare(begin) > 0 {
+			begin = target.begin
+		}
+		if target.end.Compare(end) < 0 {
+			end = target.end
+		}
+		total.Add(total, iprange.Count(begin, end))
+	}
+	return total
+}
+
+// DiscoveryConfiguration represents the content of discovery-configuration.xml
+type DiscoveryConfiguration struct {
+	XMLName          xml.Name     `xml:"http://xmlns.opennms.org/xsd/config/discovery discovery-configuration" json:"-" yaml:"-"`
+	PacketsPerSecond int          `xml:"packets-per-second,attr,omitempty" json:"packets-per-second,omitempty" yaml:"packets-per-second,omitempty"`
+	InitialSleepTime int          `xml:"initial-sleep-time,attr,omitempty" json:"initial-sleep-time,omitempty" yaml:"initial-sleep-time,omitempty"`
+	RestartSleepTime int          `xml:"restart-sleep-time,attr,omitempty" json:"restart-sleep-time,omitempty" yaml:"restart-sleep-time,omitempty"`
+	Retries          int          `xml:"retries,attr,omitempty" json:"retries,omitempty" yaml:"retries,omitempty"`
+	Timeout          int          `xml:"timeout,attr,omitempty" json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	ChunkSize        int          `xml:"chunk-size,attr,omitempty" json:"chunk-size,omitempty" yaml:"chunk-size,omitempty"`
+	Definitions      []Definition `xml:"definition,omitempty" json:"definitions,omitempty" yaml:"definitions,omitempty"`
+}
+
+// AddDefinition adds a definition to the configuration
+func (cfg *DiscoveryConfiguration) AddDefinition(d Definition) {
+	cfg.Definitions = append(cfg.Definitions, d)
+}
+
+// OrderDefinitions orders the definitions by priority, higher first, keeping the order of the ones with the same priority,
+// as Discovery uses the first definition that matches an address.
+func (cfg *DiscoveryConfiguration) OrderDefinitions() {
+	sort.SliceStable(cfg.Definitions, func(i, j int) bool {
+		return cfg.Definitions[i].Priority > cfg.Definitions[j].Priority
+	})
+}
+
+// SortDefinitions orders the definitions by priority, higher first, and then by name, location and foreign source,
+// keeping the order of the equal ones, so the output is stable across runs; the unnamed definitions come first.
+func (cfg *DiscoveryConfiguration) SortDefinitions() {
+	sort.SliceStable(cfg.Definitions, func(i, j int) bool {
+		a, b := &cfg.Definitions[i], &cfg.Definitions[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Location != b.Location {
+			return a.Location < b.Location
+		}
+		return a.ForeignSource < b.ForeignSource
+	})
+}
+
+// Sort sorts the content of all the definitions
+func (cfg *DiscoveryConfiguration) Sort() {
+	for i := range cfg.Definitions {
+		d := &cfg.Definitions[i]
+		d.Sort()
+	}
+}
+
+// Merge optimizes the content of all the definitions
+func (cfg *DiscoveryConfiguration) Merge() {
+	for i := range cfg.Definitions {
+		d := &cfg.Definitions[i]
+		d.Merge()
+	}
+}
+
+// MergeWithSubtraction optimizes all the definitions, subtracting the exclude ranges from the include ranges
+func (cfg *DiscoveryConfiguration) MergeWithSubtraction() {
+	for i := range cfg.Definitions {
+		d := &cfg.Definitions[i]
+		d.MergeWithSubtraction()
+	}
+}
+
+// GetTotalEstimatedAddresses returns the number of addresses to be discovered
+func (cfg *DiscoveryConfiguration) GetTotalEstimatedAddresses() *big.Int {
+	total := big.NewInt(0)
+	for _, d := range cfg.Definitions {
+		total.Add(total, d.GetTotalEstimatedAddresses())
+	}
+	return total
+}
+
+// UnmarshalXML parses the configuration, taking the comment right before each definition as its name;
+// the comments inside the definitions are ignored.
+func (cfg *DiscoveryConfiguration) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain DiscoveryConfiguration // Without UnmarshalXML, to avoid the recursion
+	parsed := plain{}
+	empty := start.Copy() // The attributes are decoded from an empty copy of the element
+	empty.Name.Space = "" // Declared again through the xmlns attribute
+	data, err := xml.Marshal(struct {
+		XMLName xml.Name
+		Attrs   []xml.Attr `xml:",any,attr"`
+	}{empty.Name, empty.Attr})
+	if err != nil {
+		return err
+	}
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+	name := ""
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.Comment:
+			name = strings.TrimSpace(string(t))
+			if ValidateName(name) != nil {
+				name = ""
+			}
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) > 0 {
+				name = ""
+			}
+		case xml.StartElement:
+			if t.Name.Local == "definition" {
+				def := Definition{}
+				if err := d.DecodeElement(&def, &t); err != nil {
+					return err
+				}
+				def.Name = name
+				parsed.Definitions = append(parsed.Definitions, def)
+			} else if err := d.Skip(); err != nil {
+				return err
+			}
+			name = ""
+		case xml.EndElement:
+			*cfg = DiscoveryConfiguration(parsed)
+			return nil
+		}
+	}
+}
+
+// String returns the XML representation of the configuration, or an empty string when it cannot be marshalled
+func (cfg *DiscoveryConfiguration) String() string {
+	data, err := xml.MarshalIndent(cfg, "", "   ")
+	if err != nil {
+		log.Printf("cannot marshal the discovery configuration: %v", err)
+	}
+	return string(namedDefinition.ReplaceAll(data, []byte("$2$1$2<definition"))) // The encoder doesn't indent the comments
+}
@@ -0,0 +1,636 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package discovery
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParseDiscoveryConfiguration(t *testing.T) {
+	// https://github.com/OpenNMS/opennms/blob/master/opennms-base-assembly/src/main/filtered/etc/examples/discovery-configuration.xml
+	referenceConfig := `
+	<discovery-configuration xmlns="http://xmlns.opennms.org/xsd/config/discovery" packets-per-second="1" initial-sleep-time="30000" restart-sleep-time="86400000" retries="1" timeout="2000">
+		<definition location="MINION" foreign-source="ApexOffice">
+			<detectors>
+				<detector name="reverse-dns-lookup" class-name="org.opennms.netmgt.provision.detector.rdns.ReverseDNSLookupDetector"/>
+				<detector name="SNMP" class-name="org.opennms.netmgt.provision.detector.snmp.SnmpDetector">
+					<parameter key="timeout" value="5000"/>
+					<parameter key="ttl" value="120000"/>
+					<!-- use snmp profiles when detecting, defaults to false -->
+					<parameter key="useSnmpProfiles" value="true"/>
+				</detector>
+			</detectors>
+			<specific>10.0.0.5</specific>
+			<include-range>
+				<begin>192.168.0.1</begin>
+				<end>192.168.0.254</end>
+			</include-range>
+			<exclude-range>
+				<begin>192.168.0.120</begin>
+				<end>192.168.0.125</end>
+			</exclude-range>
+			<include-url>file:/opt/opennms/etc/include.txt</include-url>
+		</definition>
+	</discovery-configuration>
+	`
+	cfg := new(DiscoveryConfiguration)
+	if err := xml.Unmarshal([]byte(referenceConfig), cfg); err != nil {
+		t.Errorf("cannot parse configuration: %v", err)
+	}
+	output, _ := xml.MarshalIndent(cfg, "", "  ")
+	fmt.Println(string(output))
+
+	// Validating content
+	if cfg.InitialSleepTime != 30000 {
+		t.Errorf("the initial-sleep-time is incorrect")
+	}
+	if len(cfg.Definitions) != 1 {
+		t.Errorf("the config should have one definition")
+	}
+	def := cfg.Definitions[0]
+	if def.ForeignSource != "ApexOffice" {
+		t.Errorf("the definition has an incorrect foreign-source")
+	}
+	if len(def.Detectors) != 2 {
+		t.Errorf("the definition should have two detectors")
+	}
+	if def.Detectors[1].Name != "SNMP" {
+		t.Errorf("the definition has a wrong detector")
+	}
+	if len(def.Detectors[1].Parameters) != 3 {
+		t.Errorf("the SNMP detector should have 3 parameters")
+	}
+	if def.Detectors[1].Parameters[0].Key != "timeout" {
+		t.Errorf("the SNMP detector has a wrong parameter")
+	}
+	if len(def.Specifics) != 1 {
+		t.Errorf("the definition should have 1 specific")
+	}
+	if len(def.IncludeRanges) != 1 {
+		t.Errorf("the definition should have 1 include-range")
+	}
+	if len(def.ExcludeRanges) != 1 {
+		t.Errorf("the definition should have 1 exclude-range")
+	}
+	if len(def.IncludeURLs) != 1 {
+		t.Errorf("the definition should have 1 include-url")
+	}
+}
+
+func TestGetIPv4Range(t *testing.T) {
+	def := new(Definition)
+	src, dst, err := def.getRange("172.16.0.0/24")
+	if err != nil {
+		t.Errorf("cannot get range: %v", err)
+	}
+	if src.String() != "172.16.0.1" {
+		t.Errorf("invalid source address")
+	}
+	if dst.String() != "172.16.0.254" {
+		t.Errorf("invalid dst address")
+	}
+}
+
+func TestGetIPv6Range(t *testing.T) {
+	def := new(Definition)
+	src, dst, err := def.getRange("2001::0/64")
+	if err != nil {
+		t.Errorf("cannot get range: %v", err)
+	}
+	if src.String() != "2001::1" {
+		t.Errorf("invalid source address")
+	}
+	if dst.String() != "2001::ffff:ffff:ffff:fffe" {
+		t.Errorf("invalid dst address")
+	}
+}
+
+func TestGetSmallRanges(t *testing.T) {
+	def := new(Definition)
+	for cidr, expected := range map[string]string{
+		"10.0.0.5/32":      "10.0.0.5-10.0.0.5",
+		"10.0.1.0/31":      "10.0.1.0-10.0.1.1",
+		"10.0.2.0/30":      "10.0.2.1-10.0.2.2",
+		"2001:db8::5/128":  "2001:db8::5-2001:db8::5",
+		"2001:db8::a/127":  "2001:db8::a-2001:db8::b",
+		"2001:db8::10/126": "2001:db8::11-2001:db8::12",
+	} {
+		src, dst, err := def.getRange(cidr)
+		if err != nil {
+			t.Fatalf("cannot get range of %s: %v", cidr, err)
+		}
+		if r := src.String() + "-" + dst.String(); r != expected {
+			t.Errorf("invalid range for %s: %s", cidr, r)
+		}
+	}
+}
+
+func TestIncludeSmallCIDRs(t *testing.T) {
+	swapped, _ := DefaultRangeValidator.Counts()
+	def := new(Definition)
+	def.IncludeCIDR("10.0.0.5/32")
+	def.IncludeCIDR("10.0.1.0/31")
+	def.ExcludeCIDR("10.0.2.7/32")
+	if current, _ := DefaultRangeValidator.Counts(); current != swapped {
+		t.Errorf("the ranges of the CIDRs should not be swapped")
+	}
+	if len(def.IncludeRanges) != 2 || def.IncludeRanges[0].Begin.String() != "10.0.0.5" || def.IncludeRanges[0].End.String() != "10.0.0.5" {
+		t.Errorf("the /32 should include only its address: %s", def.String())
+	}
+	if def.IncludeRanges[1].Begin.String() != "10.0.1.0" || def.IncludeRanges[1].End.String() != "10.0.1.1" {
+		t.Errorf("the /31 should include both addresses: %s", def.String())
+	}
+	if def.ExcludeRangesContain("10.0.2.6") || def.ExcludeRangesContain("10.0.2.8") || !def.ExcludeRangesContain("10.0.2.7") {
+		t.Errorf("the /32 should exclude only its address: %s", def.String())
+	}
+}
+
+func TestIncludeCIDR(t *testing.T) {
+	def := new(Definition)
+	def.IncludeCIDR("192.168.0.0/24")
+	if len(def.IncludeRanges) != 1 {
+		t.Errorf("the definition should have one include-range")
+	}
+	if def.IncludeRanges[0].Begin.String() != "192.168.0.1" {
+		t.Errorf("the include range has a wrong begin address: %s", def.IncludeRanges[0].Begin)
+	}
+	if def.IncludeRanges[0].End.String() != "192.168.0.254" {
+		t.Errorf("the include range has a wrong end address")
+	}
+}
+
+func TestExcludeRangesContain(t *testing.T) {
+	def := new(Definition)
+	def.ExcludeCIDR("192.168.0.0/24")
+	def.ExcludeCIDR("192.168.1.0/24")
+	if def.ExcludeRangesContain("192.168.0.1") == false {
+		t.Errorf("address 192.168.0.1 should be in one of the excluded ranges")
+	}
+	if def.ExcludeRangesContain("192.168.1.10") == false {
+		t.Errorf("address 192.168.1.10 should be in one of the excluded ranges")
+	}
+	if def.ExcludeRangesContain("172.16.1.1") == true {
+		t.Errorf("address 172.16.1.1 should not be in any of the excluded ranges")
+	}
+}
+
+func TestIncludeRangesContain(t *testing.T) {
+	def := new(Definition)
+	def.IncludeCIDR("192.168.0.0/24")
+	def.IncludeCIDR("192.168.1.0/24")
+	if def.IncludeRangesContain("192.168.0.1") == false {
+		t.Errorf("address 192.168.0.1 should be in one of the included ranges")
+	}
+	if def.IncludeRangesContain("192.168.1.10") == false {
+		t.Errorf("address 192.168.1.10 should be in one of the included ranges")
+	}
+	if def.IncludeRangesContain("172.16.1.1") == true {
+		t.Errorf("address 172.16.1.1 should not be in any of the included ranges")
+	}
+}
+
+func TestAddSpecific(t *testing.T) {
+	def := new(Definition)
+	def.AddSpecific("192.168.0.1")
+	def.AddSpecific("192.168.0.2")
+	if len(def.Specifics) != 2 {
+		t.Errorf("the definition should have 2 specific")
+	}
+	def.AddSpecific("192.400.0.1") // Wrong IP
+	if len(def.Specifics) != 2 {
+		t.Errorf("the definition should have 2 specific")
+	}
+}
+
+func TestAddIncludeURL(t *testing.T) {
+	def := new(Definition)
+	def.AddIncludeURL("file:/tmp/ip-list.txt")
+	if len(def.IncludeURLs) != 1 {
+		t.Errorf("the definition should 1 include-url")
+	}
+}
+
+func TestAddIncludeRange(t *testing.T) {
+	def := new(Definition)
+	def.AddIncludeRange("192.168.0.10", "192.168.0.20")
+	if len(def.IncludeRanges) != 1 {
+		t.Errorf("the definition should 1 include-range")
+	}
+}
+
+func TestAddExcludeRange(t *testing.T) {
+	def := new(Definition)
+	def.AddExcludeRange("192.168.0.10", "192.168.0.20")
+	if len(def.ExcludeRanges) != 1 {
+		t.Errorf("the definition should 1 exclude-range")
+	}
+}
+
+func TestReversedRanges(t *testing.T) {
+	v := new(RangeValidator)
+	def := new(Definition)
+	if err := v.AddIncludeRange(def, "192.168.0.20", "192.168.0.10", Attributes{}); err != nil {
+		t.Fatalf("reversed ranges should be swapped: %v", err)
+	}
+	if len(def.IncludeRanges) != 1 || def.IncludeRanges[0].Begin.String() != "192.168.0.10" {
+		t.Errorf("the boundaries should be swapped: %v", def.IncludeRanges)
+	}
+	if err := v.AddExcludeRange(def, "192.168.0.1", "2001:db8::1"); err == nil {
+		t.Errorf("ranges with mixed families should be dropped")
+	}
+	v.Strict = true
+	if err := v.AddIncludeRange(def, "192.168.1.20", "192.168.1.10", Attributes{}); !errors.Is(err, ErrReversedRange) {
+		t.Errorf("reversed ranges should be rejected in strict mode, got %v", err)
+	}
+	if len(def.IncludeRanges) != 1 || len(def.ExcludeRanges) != 0 {
+		t.Errorf("the invalid ranges should not be added")
+	}
+	if swapped, dropped := v.Counts(); swapped != 1 || dropped != 2 {
+		t.Errorf("incorrect counters: swapped=%d, dropped=%d", swapped, dropped)
+	}
+
+	// The definitions use the default validator
+	swapped, _ := DefaultRangeValidator.Counts()
+	if err := def.AddIncludeRange("192.168.2.20", "192.168.2.10"); err != nil {
+		t.Fatalf("reversed ranges should be swapped: %v", err)
+	}
+	if current, _ := DefaultRangeValidator.Counts(); current != swapped+1 {
+		t.Errorf("the default validator should count the swapped range")
+	}
+}
+
+func TestMergeWithSubtraction(t *testing.T) {
+	d := Definition{}
+	d.IncludeCIDR("192.168.0.0/24")
+	d.AddSpecific("10.0.0.1")
+	d.AddSpecific("10.0.0.2")
+	d.AddExcludeRange("192.168.0.10", "192.168.0.20")
+	d.AddExcludeRange("10.0.0.2", "10.0.0.2")
+	total := d.GetTotalEstimatedAddresses()
+	d.MergeWithSubtraction()
+	if len(d.ExcludeRanges) != 0 {
+		t.Errorf("there should be no exclude ranges: %v", d.ExcludeRanges)
+	}
+	if len(d.IncludeRanges) != 2 || len(d.Specifics) != 1 || d.Specifics[0].IP.String() != "10.0.0.1" {
+		t.Errorf("unexpected content: %s", d.String())
+	}
+	if d.GetTotalEstimatedAddresses().Cmp(total) != 0 {
+		t.Errorf("the estimated addresses should not change: expected %d, got %d", total, d.GetTotalEstimatedAddresses())
+	}
+
+	d = Definition{}
+	d.IncludeCIDR("192.168.0.0/24")
+	d.AddIncludeURL("http://server/ips.txt")
+	d.AddExcludeRange("192.168.0.10", "192.168.0.20")
+	d.MergeWithSubtraction()
+	if len(d.ExcludeRanges) != 1 || len(d.IncludeRanges) != 1 {
+		t.Errorf("the exclude ranges should be kept with include URLs: %s", d.String())
+	}
+}
+
+func TestGetTotalEstimatedAddresses(t *testing.T) {
+	d := Definition{}
+	d.AddSpecific("192.168.0.1")
+	d.AddSpecific("192.168.0.2")
+	d.IncludeCIDR("172.20.0.0/16")
+	d.IncludeCIDR("10.10.0.0/16")
+	d.ExcludeCIDR("172.20.10.0/24")
+	d.ExcludeCIDR("192.169.0.0/24")
+	cfg := DiscoveryConfiguration{
+		Definitions: []Definition{d},
+	}
+	expected := big.NewInt(130816) // 2 * ClassB - ClassC + 2 = 2 * 65534 - 254 + 2
+	total := cfg.GetTotalEstimatedAddresses()
+	if total.Cmp(expected) != 0 {
+		t.Errorf("the total estimated addresses was %d and it should be %d", total, expected)
+	}
+}
+
+func TestGetTotalEstimatedAddressesLarge(t *testing.T) {
+	d := Definition{}
+	d.AddIncludeRange("10.0.0.0", "10.255.255.255")
+	d.AddIncludeRange("2001:db8::", "2001:db8::ffff:ffff:ffff:ffff")
+	d.AddExcludeRange("10.1.0.0", "10.1.255.255")
+	d.AddExcludeRange("10.1.128.0", "10.2.0.255") // Overlaps the previous one
+	d.AddExcludeRange("::a01:1", "::a01:1")       // Same integer as 10.1.0.1, but IPv6
+	d.AddExcludeRange("2001:db8::", "2001:db8::ffff")
+	d.AddSpecific("10.1.0.1")
+	d.AddSpecific("192.168.0.1")
+	expected := new(big.Int).Lsh(big.NewInt(1), 64)        // IPv6 /64
+	expected.Sub(expected, big.NewInt(65536))              // Excluded from the /64
+	expected.Add(expected, big.NewInt(16777216-65536-256)) // IPv4 /8 minus 10.1.0.0-10.2.0.255
+	expected.Add(expected, big.NewInt(1))                  // Only 192.168.0.1, as 10.1.0.1 is excluded
+	if total := d.GetTotalEstimatedAddresses(); total.Cmp(expected) != 0 {
+		t.Errorf("the total estimated addresses was %d and it should be %d", total, expected)
+	}
+}
+
+func TestSort(t *testing.T) {
+	d := Definition{}
+	d.AddSpecific("192.168.0.10")
+	d.AddSpecific("192.168.0.2")
+	d.AddSpecific("172.16.20.2")
+	d.AddSpecific("172.16.16.2")
+	d.Sort()
+	if d.Specifics[0].IP.String() != "172.16.16.2" {
+		t.Errorf("invalid sort for position 0: %s", d.Specifics[0].IP.String())
+	}
+	if d.Specifics[3].IP.String() != "192.168.0.10" {
+		t.Errorf("invalid sort for position 3: %s", d.Specifics[3].IP.String())
+	}
+}
+
+func TestSortDefinitions(t *testing.T) {
+	cfg := &DiscoveryConfiguration{Definitions: []Definition{
+		{Name: "paris", Location: "Paris"},
+		{Location: "Default", ForeignSource: "B"},
+		{Name: "berlin", Location: "Berlin"},
+		{Location: "Default", ForeignSource: "A"},
+	}}
+	cfg.SortDefinitions()
+	order := make([]string, 0)
+	for _, def := range cfg.Definitions {
+		order = append(order, def.Name+"/"+def.ForeignSource)
+	}
+	if strings.Join(order, ",") != "/A,/B,berlin/,paris/" {
+		t.Errorf("invalid sort of definitions: %v", order)
+	}
+
+	data, err := xml.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("cannot marshal configuration: %v", err)
+	}
+	if !strings.Contains(string(data), "<!--berlin--><definition location=\"Berlin\">") {
+		t.Errorf("the name should be a comment before the definition: %s", data)
+	}
+	parsed := &DiscoveryConfiguration{}
+	if err := xml.Unmarshal(data, parsed); err != nil {
+		t.Fatalf("cannot unmarshal configuration: %v", err)
+	}
+	if parsed.Definitions[2].Name != "berlin" || parsed.Definitions[0].Name != "" {
+		t.Errorf("the name should be parsed from the comment: %+v", parsed.Definitions)
+	}
+	if parsed.String() != cfg.String() {
+		t.Errorf("the configuration should render the same after parsing it: %s", parsed.String())
+	}
+	if !strings.Contains(cfg.String(), "\n   <!--berlin-->\n   <definition location=\"Berlin\">") {
+		t.Errorf("the name should be indented like the definition: %s", cfg.String())
+	}
+}
+
+func TestDefinitionNames(t *testing.T) {
+	data := `<discovery-configuration xmlns="http://xmlns.opennms.org/xsd/config/discovery" retries="2">
+   <definition location="Paris"><!--hand-written note--><specific>10.0.0.1</specific></definition>
+   <!--bad--><definition location="Berlin"/>
+   <!--london-->
+   <definition location="London"/>
+</discovery-configuration>`
+	cfg := &DiscoveryConfiguration{}
+	if err := xml.Unmarshal([]byte(data), cfg); err != nil {
+		t.Fatalf("cannot unmarshal configuration: %v", err)
+	}
+	if cfg.Retries != 2 || len(cfg.Definitions) != 3 || len(cfg.Definitions[0].Specifics) != 1 {
+		t.Fatalf("incorrect configuration: %+v", cfg)
+	}
+	if cfg.Definitions[0].Name != "" || cfg.Definitions[1].Name != "bad" || cfg.Definitions[2].Name != "london" {
+		t.Errorf("only the comments before the definitions should be names: %+v", cfg.Definitions)
+	}
+
+	for _, name := range []string{"a--b", "a-", "-"} {
+		if err := ValidateName(name); err == nil {
+			t.Errorf("invalid name %s accepted", name)
+		}
+		def := Definition{Name: name, Location: "Paris"}
+		if _, err := xml.Marshal(&def); err == nil {
+			t.Errorf("the definition named %s should not be marshalled", name)
+		}
+		if s := (&DiscoveryConfiguration{Definitions: []Definition{def}}).String(); s != "" {
+			t.Errorf("the configuration with the definition named %s should not be rendered: %s", name, s)
+		}
+	}
+	if err := ValidateName("a-b (IPv6)"); err != nil {
+		t.Errorf("valid name rejected: %v", err)
+	}
+	def := Definition{Name: "paris", Location: "Paris"}
+	if s := def.String(); !strings.HasPrefix(s, "<!--paris-->\n<definition location=\"Paris\">") {
+		t.Errorf("the name should be written before the definition: %s", s)
+	}
+}
+
+func TestOrderDefinitions(t *testing.T) {
+	cfg := &DiscoveryConfiguration{Definitions: []Definition{
+		{Name: "paris", Location: "Paris"},
+		{Name: "fallback", Priority: -1},
+		{Name: "london", Location: "London"},
+		{Name: "berlin", Location: "Berlin", Priority: 10},
+		{Name: "amsterdam", Location: "Amsterdam"},
+	}}
+	names := func() string {
+		order := make([]string, 0)
+		for _, def := range cfg.Definitions {
+			order = append(order, def.Name)
+		}
+		return strings.Join(order, ",")
+	}
+	cfg.OrderDefinitions()
+	if order := names(); order != "berlin,paris,london,amsterdam,fallback" {
+		t.Errorf("the definitions should be ordered by priority, keeping the order of the rest: %s", order)
+	}
+	cfg.SortDefinitions()
+	if order := names(); order != "berlin,amsterdam,london,paris,fallback" {
+		t.Errorf("the name should only break the ties of the priority: %s", order)
+	}
+	v6 := (&Definition{Name: "berlin", Priority: 10, Specifics: []Specific{{IP: net.ParseIP("2001:db8::1")}}}).SplitIPv6(FamilySettings{})
+	if v6 == nil || v6.Priority != 10 {
+		t.Errorf("the IPv6 definition should keep the priority: %+v", v6)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	d := Definition{}
+	d.IncludeCIDR("192.168.0.0/24")
+	d.IncludeCIDR("172.16.0.0/16")
+	d.IncludeCIDR("10.0.0.0/8")
+	d.ExcludeCIDR("10.0.1.0/24")
+	d.AddIncludeRange("192.168.0.1", "192.168.2.254") // Overlaps CIDR #1
+	d.AddExcludeRange("10.0.1.10", "10.0.2.254")      // Overlaps CIDR #4
+	d.AddSpecific("192.168.0.10")                     // Part of CIDR #1
+	// The following 5 must be collapsed
+	d.AddSpecific("200.10.0.15")
+	d.AddSpecific("200.10.0.16")
+	d.AddSpecific("200.10.0.17")
+	d.AddSpecific("200.10.0.18")
+	d.AddSpecific("200.10.0.19")
+	d.AddSpecific("200.10.0.20")
+	// The following 4 must be collapsed
+	d.AddSpecific("200.10.0.25")
+	d.AddSpecific("200.10.0.26")
+	d.AddSpecific("200.10.0.27")
+	d.AddSpecific("200.10.0.28")
+	// A few disperse specifics
+	d.AddSpecific("30.0.0.2")
+	d.AddSpecific("40.0.0.2")
+	d.AddSpecific("50.0.0.2")
+	// Verify configuration
+	cfg := &DiscoveryConfiguration{
+		PacketsPerSecond: 10,
+		InitialSleepTime: 300000,
+		RestartSleepTime: 86400000,
+		Retries:          1,
+		Timeout:          2000,
+		Definitions:      []Definition{d},
+	}
+	cfg.Merge()
+	fmt.Println(cfg.String())
+	out := cfg.Definitions[0]
+	if len(out.Specifics) != 3 {
+		t.Errorf("incorrect number of specifics: %d", len(out.Specifics))
+	}
+	if len(out.IncludeRanges) != 5 {
+		t.Errorf("incorrect number of include-ranges: %d", len(out.IncludeRanges))
+	}
+	if len(out.ExcludeRanges) != 1 {
+		t.Errorf("incorrect number of exclude-ranges: %d", len(out.ExcludeRanges))
+	}
+	r := out.IncludeRanges[2]
+	if r.Begin.String() != "192.168.0.1" || r.End.String() != "192.168.2.254" {
+		t.Errorf("incorrect merged range: %v", r)
+	}
+	r = out.IncludeRanges[3]
+	if r.Begin.String() != "200.10.0.15" || r.End.String() != "200.10.0.20" {
+		t.Errorf("incorrect merged range: %v", r)
+	}
+	e := out.ExcludeRanges[0]
+	if e.Begin.String() != "10.0.1.1" || e.End.String() != "10.0.2.254" {
+		t.Errorf("incorrect merged range: %v", e)
+	}
+}
+
+func TestMergeWithAttributes(t *testing.T) {
+	d := Definition{}
+	d.AddSpecificWithAttributes("10.0.0.1", Attributes{Location: "Paris"})
+	d.AddSpecificWithAttributes("10.0.0.2", Attributes{Location: "Paris"})
+	d.AddSpecificWithAttributes("10.0.0.3", Attributes{Location: "London"})
+	d.AddSpecificWithAttributes("10.0.0.4", Attributes{Location: "London", Timeout: 5000})
+	d.AddSpecific("10.0.0.5")
+	d.AddSpecific("10.0.0.6")
+	cfg := &DiscoveryConfiguration{Definitions: []Definition{d}}
+	cfg.Merge()
+	out := cfg.Definitions[0]
+	if len(out.IncludeRanges) != 2 || len(out.Specifics) != 2 {
+		t.Fatalf("the elements with different attributes should not be combined: %s", cfg.String())
+	}
+	paris, unannotated := out.IncludeRanges[0], out.IncludeRanges[1]
+	if paris.Begin.String() != "10.0.0.1" || paris.End.String() != "10.0.0.2" || paris.Location != "Paris" {
+		t.Errorf("incorrect range for Paris: %v", paris)
+	}
+	if unannotated.Begin.String() != "10.0.0.5" || unannotated.End.String() != "10.0.0.6" || unannotated.Location != "" {
+		t.Errorf("incorrect range without attributes: %v", unannotated)
+	}
+	if s := out.Specifics[0]; s.IP.String() != "10.0.0.3" || s.Location != "London" || s.Timeout != 0 {
+		t.Errorf("incorrect specific: %v", s)
+	}
+	if s := out.Specifics[1]; s.IP.String() != "10.0.0.4" || s.Location != "London" || s.Timeout != 5000 {
+		t.Errorf("incorrect specific: %v", s)
+	}
+}
+
+func TestMergeKeepsAnnotations(t *testing.T) {
+	build := func(reversed bool) *DiscoveryConfiguration {
+		adds := []func(d *Definition){
+			func(d *Definition) { d.IncludeCIDR("10.0.0.0/24") },
+			func(d *Definition) { d.AddSpecificWithAttributes("10.0.0.10", Attributes{ForeignSource: "Routers"}) },
+			func(d *Definition) { d.AddSpecific("10.0.0.20") },
+			func(d *Definition) {
+				d.AddIncludeRangeWithAttributes("10.0.0.100", "10.0.0.120", Attributes{Location: "Lab"})
+			},
+			func(d *Definition) { d.AddSpecificWithAttributes("10.0.0.110", Attributes{Location: "Lab"}) },
+			func(d *Definition) { d.AddSpecificWithAttributes("10.0.0.115", Attributes{Location: "Paris"}) },
+			func(d *Definition) { d.AddSpecificWithAttributes("10.0.1.1", Attributes{Location: "Paris"}) },
+			func(d *Definition) { d.AddSpecificWithAttributes("10.0.1.1", Attributes{Location: "Lab"}) },
+		}
+		d := Definition{}
+		for i := range adds {
+			if reversed {
+				adds[len(adds)-1-i](&d)
+			} else {
+				adds[i](&d)
+			}
+		}
+		cfg := &DiscoveryConfiguration{Definitions: []Definition{d}}
+		cfg.Merge()
+		return cfg
+	}
+	cfg := build(false)
+	if other := build(true); cfg.String() != other.String() {
+		t.Errorf("the result should not depend on the order of the inputs:\n%s\n%s", cfg.String(), other.String())
+	}
+	elements := make([]string, 0)
+	for _, s := range cfg.Definitions[0].Specifics {
+		elements = append(elements, fmt.Sprintf("%s[%s%s]", s.IP, s.Location, s.ForeignSource))
+	}
+	for _, r := range cfg.Definitions[0].IncludeRanges {
+		elements = append(elements, fmt.Sprintf("%s-%s[%s%s]", r.Begin, r.End, r.Location, r.ForeignSource))
+	}
+	expected := "10.0.0.10[Routers],10.0.0.115[Paris],10.0.1.1[Lab]," +
+		"10.0.0.1-10.0.0.9[],10.0.0.11-10.0.0.99[],10.0.0.100-10.0.0.114[Lab],10.0.0.116-10.0.0.120[Lab],10.0.0.121-10.0.0.254[]"
+	if strings.Join(elements, ",") != expected {
+		t.Errorf("unexpected elements: %s", strings.Join(elements, ","))
+	}
+}
+
+func TestIncludeCIDRWithAttributes(t *testing.T) {
+	def := new(Definition)
+	def.IncludeCIDRWithAttributes("192.168.0.0/24", Attributes{Retries: 2, Timeout: 5000})
+	if len(def.IncludeRanges) != 1 {
+		t.Fatalf("the definition should have one include-range")
+	}
+	if def.IncludeRanges[0].Retries != 2 || def.IncludeRanges[0].Timeout != 5000 {
+		t.Errorf("the include range has wrong attributes: %v", def.IncludeRanges[0])
+	}
+	def.AddSpecificWithAttributes("10.0.0.1", Attributes{Retries: 3})
+	if len(def.Specifics) != 1 || def.Specifics[0].Retries != 3 {
+		t.Errorf("the specific has wrong attributes: %v", def.Specifics)
+	}
+}
+
+func TestGetSpecific(t *testing.T) {
+	def := new(Definition)
+	def.AddSpecificWithAttributes("10.0.0.1", Attributes{Location: "Branch"})
+	s := def.GetSpecific("10.0.0.1")
+	if s == nil {
+		t.Fatalf("the specific 10.0.0.1 should exist")
+	}
+	s.SetAttributes(Attributes{Location: "HQ"})
+	if def.Specifics[0].Location != "HQ" {
+		t.Errorf("the location of the specific should have been updated")
+	}
+	if def.GetSpecific("10.0.0.2") != nil {
+		t.Errorf("the specific 10.0.0.2 should not exist")
+	}
+}
+
+func BenchmarkMerge(b *testing.B) {
+	base := &Definition{}
+	for i := 0; i < 20000; i++ {
+		base.AddSpecific(fmt.Sprintf("10.%d.%d.%d", i%7, (i*31)%256, (i*17)%256))
+		if i%10 == 0 {
+			base.IncludeCIDR(fmt.Sprintf("172.%d.%d.0/28", 16+i%16, i%256))
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		def := &Definition{
+			Specifics:     append([]Specific{}, base.Specifics...),
+			IncludeRanges: append([]IncludeRange{}, base.IncludeRanges...),
+		}
+		def.Merge()
+	}
+}
@@ -0,0 +1,165 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Separation of IPv4 and IPv6 content into independent definitions
+
+package discovery
+
+import (
+	"math/big"
+	"net"
+	"strings"
+
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+// FamilySettings overrides the settings of the definitions generated for IPv6
+type FamilySettings struct {
+	Retries   int
+	Timeout   int
+	Detectors []string // Names of the detectors to keep; empty means all
+}
+
+func isIPv6(ip net.IP) bool {
+	return ip.To4() == nil
+}
+
+// SplitIPv6 moves the IPv6 content of the definition into a new one, returning nil if there is no IPv6 content
+func (def *Definition) SplitIPv6(settings FamilySettings) *Definition {
+	v6 := Definition{
+		Name:          def.Name,
+		Priority:      def.Priority,
+		Tenant:        def.Tenant,
+		Location:      def.Location,
+		ForeignSource: def.ForeignSource,
+		ChunkSize:     def.ChunkSize,
+		Retries:       def.Retries,
+		Timeout:       def.Timeout,
+	}
+	if v6.Name != "" {
+		v6.Name += " (IPv6)"
+	}
+	if settings.Retries > 0 {
+		v6.Retries = settings.Retries
+	}
+	if settings.Timeout > 0 {
+		v6.Timeout = settings.Timeout
+	}
+	for _, d := range def.Detectors {
+		keep := len(settings.Detectors) == 0
+		for _, name := range settings.Detectors {
+			if strings.EqualFold(name, d.Name) {
+				keep = true
+			}
+		}
+		if keep {
+			v6.Detectors = append(v6.Detectors, d)
+		}
+	}
+
+	specifics := make([]Specific, 0, len(def.Specifics))
+	for _, s := range def.Specifics {
+		if isIPv6(s.IP) {
+			v6.Specifics = append(v6.Specifics, s)
+		} else {
+			specifics = append(specifics, s)
+		}
+	}
+	includes := make([]IncludeRange, 0, len(def.IncludeRanges))
+	for _, r := range def.IncludeRanges {
+		if isIPv6(r.Begin) {
+			v6.IncludeRanges = append(v6.IncludeRanges, r)
+		} else {
+			includes = append(includes, r)
+		}
+	}
+	if len(v6.Specifics) == 0 && len(v6.IncludeRanges) == 0 {
+		return nil
+	}
+	excludes := make([]ExcludeRange, 0, len(def.ExcludeRanges))
+	for _, r := range def.ExcludeRanges {
+		if isIPv6(r.Begin) {
+			v6.ExcludeRanges = append(v6.ExcludeRanges, r)
+		} else {
+			excludes = append(excludes, r)
+		}
+	}
+	def.Specifics = specifics
+	def.IncludeRanges = includes
+	def.ExcludeRanges = excludes
+	def.Reindex()
+	v6.Reindex()
+	return &v6
+}
+
+// SplitFamilies moves the IPv6 content of every definition into a new definition added right after it
+func (cfg *DiscoveryConfiguration) SplitFamilies(settings FamilySettings) {
+	definitions := make([]Definition, 0, len(cfg.Definitions))
+	for i := range cfg.Definitions {
+		def := cfg.Definitions[i]
+		v6 := def.SplitIPv6(settings)
+		definitions = append(definitions, def)
+		if v6 != nil {
+			definitions = append(definitions, *v6)
+		}
+	}
+	cfg.Definitions = definitions
+}
+
+// FamilyStats counts the content of a definition per address family; the addresses exclude the exclude ranges
+type FamilyStats struct {
+	Location      string   `json:"location,omitempty"`
+	IPv4Specifics int      `json:"ipv4Specifics"`
+	IPv4Ranges    int      `json:"ipv4Ranges"`
+	IPv4Addresses *big.Int `json:"ipv4Addresses"`
+	IPv6Specifics int      `json:"ipv6Specifics"`
+	IPv6Ranges    int      `json:"ipv6Ranges"`
+	IPv6Addresses *big.Int `json:"ipv6Addresses"`
+}
+
+// SweepsIPv6 returns true when there are IPv6 include ranges, which is almost always a mistake,
+// as the IPv6 subnets are too big to be swept; IPv6 addresses should be added as specifics.
+func (st *FamilyStats) SweepsIPv6() bool {
+	return st.IPv6Ranges > 0
+}
+
+// FamilyStats returns the statistics per address family of the definition
+func (def *Definition) FamilyStats() FamilyStats {
+	st := FamilyStats{Location: def.Location, IPv4Addresses: big.NewInt(0), IPv6Addresses: big.NewInt(0)}
+	scope := new(iprange.IPAddressRangeSet)
+	for _, s := range def.Specifics {
+		if isIPv6(s.IP) {
+			st.IPv6Specifics++
+		} else {
+			st.IPv4Specifics++
+		}
+		scope.Add(s.ToIPAddressRange())
+	}
+	for _, r := range def.IncludeRanges {
+		if isIPv6(r.Begin) {
+			st.IPv6Ranges++
+		} else {
+			st.IPv4Ranges++
+		}
+		scope.Add(r.ToIPAddressRange())
+	}
+	for _, r := range def.ExcludeRanges {
+		scope.Remove(r.ToIPAddressRange())
+	}
+	for _, r := range scope.Get() {
+		if isIPv6(r.Begin) {
+			st.IPv6Addresses.Add(st.IPv6Addresses, r.Size())
+		} else {
+			st.IPv4Addresses.Add(st.IPv4Addresses, r.Size())
+		}
+	}
+	return st
+}
+
+// FamilyStats returns the statistics per address family of every definition
+func (cfg *DiscoveryConfiguration) FamilyStats() []FamilyStats {
+	stats := make([]FamilyStats, 0, len(cfg.Definitions))
+	for i := range cfg.Definitions {
+		stats = append(stats, cfg.Definitions[i].FamilyStats())
+	}
+	return stats
+}
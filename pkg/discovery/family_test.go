@@ -0,0 +1,72 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package discovery
+
+import (
+	"testing"
+)
+
+func TestSplitFamilies(t *testing.T) {
+	d := Definition{
+		Location: "Lab",
+		Detectors: []Detector{
+			{Name: "ReverseDNS", Class: "org.opennms.netmgt.provision.detector.rdns.ReverseDNSLookupDetector"},
+			{Name: "SNMP", Class: "org.opennms.netmgt.provision.detector.snmp.SnmpDetector"},
+		},
+	}
+	d.IncludeCIDR("192.168.0.0/24")
+	d.IncludeCIDR("2001:db8::/120")
+	d.ExcludeCIDR("2001:db8::/124")
+	d.AddSpecific("10.0.0.1")
+	d.AddSpecific("2001:db8:1::1")
+	cfg := &DiscoveryConfiguration{Definitions: []Definition{d}}
+	cfg.SplitFamilies(FamilySettings{Timeout: 5000, Detectors: []string{"snmp"}})
+
+	if len(cfg.Definitions) != 2 {
+		t.Fatalf("there should be 2 definitions: %s", cfg.String())
+	}
+	v4, v6 := cfg.Definitions[0], cfg.Definitions[1]
+	if len(v4.IncludeRanges) != 1 || len(v4.Specifics) != 1 || len(v4.ExcludeRanges) != 0 || len(v4.Detectors) != 2 {
+		t.Errorf("incorrect IPv4 definition: %s", cfg.String())
+	}
+	if len(v6.IncludeRanges) != 1 || len(v6.Specifics) != 1 || len(v6.ExcludeRanges) != 1 {
+		t.Errorf("incorrect IPv6 definition: %s", cfg.String())
+	}
+	if v6.Location != "Lab" || v6.Timeout != 5000 || len(v6.Detectors) != 1 || v6.Detectors[0].Name != "SNMP" {
+		t.Errorf("incorrect IPv6 settings: %s", cfg.String())
+	}
+
+	ipv4Only := Definition{}
+	ipv4Only.AddSpecific("10.0.0.1")
+	cfg = &DiscoveryConfiguration{Definitions: []Definition{ipv4Only}}
+	cfg.SplitFamilies(FamilySettings{})
+	if len(cfg.Definitions) != 1 {
+		t.Errorf("there should be 1 definition")
+	}
+}
+
+func TestFamilyStats(t *testing.T) {
+	d := Definition{Location: "Lab"}
+	d.IncludeCIDR("192.168.0.0/24")
+	d.ExcludeCIDR("192.168.0.0/28")
+	d.AddSpecific("10.0.0.1")
+	d.AddSpecific("2001:db8:1::1")
+	d.AddSpecific("2001:db8:1::2")
+	st := d.FamilyStats()
+	if st.Location != "Lab" || st.IPv4Specifics != 1 || st.IPv4Ranges != 1 || st.IPv6Specifics != 2 || st.IPv6Ranges != 0 {
+		t.Errorf("incorrect counters: %+v", st)
+	}
+	if st.IPv4Addresses.Int64() != 241 || st.IPv6Addresses.Int64() != 2 {
+		t.Errorf("incorrect addresses: ipv4=%s, ipv6=%s", st.IPv4Addresses, st.IPv6Addresses)
+	}
+	if st.SweepsIPv6() {
+		t.Errorf("IPv6 specifics should not be a sweep")
+	}
+
+	d.IncludeCIDR("2001:db8::/120")
+	cfg := &DiscoveryConfiguration{Definitions: []Definition{d}}
+	stats := cfg.FamilyStats()
+	if len(stats) != 1 || !stats[0].SweepsIPv6() || stats[0].IPv6Ranges != 1 {
+		t.Errorf("the IPv6 range should be a sweep: %+v", stats)
+	}
+}
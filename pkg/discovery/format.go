@@ -0,0 +1,75 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Registry of output formats, so other tools can consume the configuration without parsing XML
+
+package discovery
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFormat is the name of the format understood by OpenNMS
+const DefaultFormat = "xml"
+
+// Serializer converts a discovery configuration into a given format
+type Serializer interface {
+	Serialize(cfg *DiscoveryConfiguration) ([]byte, error)
+}
+
+// SerializerFunc allows the use of ordinary functions as serializers
+type SerializerFunc func(cfg *DiscoveryConfiguration) ([]byte, error)
+
+// Serialize calls f(cfg)
+func (f SerializerFunc) Serialize(cfg *DiscoveryConfiguration) ([]byte, error) {
+	return f(cfg)
+}
+
+var serializers = map[string]Serializer{
+	"xml": SerializerFunc(func(cfg *DiscoveryConfiguration) ([]byte, error) {
+		return xml.MarshalIndent(cfg, "", "   ")
+	}),
+	"json": SerializerFunc(func(cfg *DiscoveryConfiguration) ([]byte, error) {
+		return json.MarshalIndent(cfg, "", "  ")
+	}),
+	"yaml": SerializerFunc(func(cfg *DiscoveryConfiguration) ([]byte, error) {
+		return yaml.Marshal(cfg)
+	}),
+}
+
+// RegisterSerializer adds or replaces the serializer for a given format
+func RegisterSerializer(format string, s Serializer) {
+	serializers[strings.ToLower(format)] = s
+}
+
+// GetSerializer returns the serializer for a given format
+func GetSerializer(format string) (Serializer, error) {
+	if s, ok := serializers[strings.ToLower(format)]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("invalid output format %s; valid formats are %s", format, strings.Join(Formats(), ", "))
+}
+
+// Formats returns the sorted names of the registered formats
+func Formats() []string {
+	names := make([]string, 0, len(serializers))
+	for name := range serializers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Format returns the configuration serialized in the given format
+func (cfg *DiscoveryConfiguration) Format(format string) ([]byte, error) {
+	s, err := GetSerializer(format)
+	if err != nil {
+		return nil, err
+	}
+	return s.Serialize(cfg)
+}
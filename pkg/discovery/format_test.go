@@ -0,0 +1,83 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package discovery
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func formatTestConfig() *DiscoveryConfiguration {
+	d := Definition{Location: "Lab"}
+	d.IncludeCIDR("192.168.0.0/24")
+	d.ExcludeCIDR("192.168.0.0/28")
+	d.AddSpecificWithAttributes("10.0.0.1", Attributes{ForeignSource: "Lab"})
+	return &DiscoveryConfiguration{PacketsPerSecond: 10, Definitions: []Definition{d}}
+}
+
+func TestFormatXML(t *testing.T) {
+	cfg := formatTestConfig()
+	data, err := cfg.Format(DefaultFormat)
+	if err != nil {
+		t.Fatalf("cannot serialize to XML: %v", err)
+	}
+	if string(data) != cfg.String() {
+		t.Errorf("the XML format should match the default representation: %s", string(data))
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	cfg := formatTestConfig()
+	data, err := cfg.Format("JSON")
+	if err != nil {
+		t.Fatalf("cannot serialize to JSON: %v", err)
+	}
+	if !strings.Contains(string(data), `"include-ranges"`) || strings.Contains(string(data), "XMLName") {
+		t.Errorf("unexpected JSON content: %s", string(data))
+	}
+	parsed := &DiscoveryConfiguration{}
+	if err := json.Unmarshal(data, parsed); err != nil {
+		t.Fatalf("cannot parse JSON: %v", err)
+	}
+	if parsed.String() != cfg.String() {
+		t.Errorf("the JSON content does not match the configuration: %s", parsed.String())
+	}
+}
+
+func TestFormatYAML(t *testing.T) {
+	cfg := formatTestConfig()
+	data, err := cfg.Format("yaml")
+	if err != nil {
+		t.Fatalf("cannot serialize to YAML: %v", err)
+	}
+	if !strings.Contains(string(data), "begin: 192.168.0.1") || !strings.Contains(string(data), "ip: 10.0.0.1") {
+		t.Errorf("unexpected YAML content: %s", string(data))
+	}
+	parsed := &DiscoveryConfiguration{}
+	if err := yaml.Unmarshal(data, parsed); err != nil {
+		t.Fatalf("cannot parse YAML: %v", err)
+	}
+	if parsed.String() != cfg.String() {
+		t.Errorf("the YAML content does not match the configuration: %s", parsed.String())
+	}
+}
+
+func TestRegisterSerializer(t *testing.T) {
+	if _, err := GetSerializer("csv"); err == nil {
+		t.Fatalf("csv should not be a valid format")
+	}
+	RegisterSerializer("csv", SerializerFunc(func(cfg *DiscoveryConfiguration) ([]byte, error) {
+		return []byte("begin,end\n"), nil
+	}))
+	defer delete(serializers, "csv")
+	data, err := formatTestConfig().Format("csv")
+	if err != nil || string(data) != "begin,end\n" {
+		t.Errorf("the registered serializer was not used: %s %v", string(data), err)
+	}
+	if strings.Join(Formats(), ",") != "csv,json,xml,yaml" {
+		t.Errorf("unexpected formats: %v", Formats())
+	}
+}
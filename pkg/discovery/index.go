@@ -0,0 +1,79 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Sorted indexes of the include and exclude ranges of a definition, for fast membership checks on large configurations
+
+package discovery
+
+import (
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+// rangeIndex is a set built once from a list of ranges, ignoring their attributes. The methods of the definition that
+// change its ranges replace the index, and it is built by the first membership check, so concurrent checks are safe as
+// long as the ranges don't change meanwhile. When the list is replaced or resized directly, the checks fall back to a
+// linear scan; elements edited in place can't be detected, so Reindex must be called after such changes.
+type rangeIndex struct {
+	once  sync.Once
+	first interface{} // Address of the first element of the indexed list
+	size  int
+	set   *iprange.IPAddressRangeSet
+}
+
+// contains returns true if the IP address is part of the ranges, scanning them when the list is not the indexed one
+func (idx *rangeIndex) contains(ip net.IP, first interface{}, size int, ranges func() []iprange.IPAddressRange) bool {
+	if idx != nil {
+		idx.once.Do(func() {
+			idx.set = newRangeIndexSet(ranges())
+			idx.first, idx.size = first, size
+		})
+		if idx.first == first && idx.size == size {
+			return idx.set.Contains(ip)
+		}
+	}
+	for _, r := range ranges() {
+		if r.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reindex replaces the indexes used by IncludeRangesContain and ExcludeRangesContain. The methods that change the ranges
+// call it, so it is only required after editing the ranges directly, before checking them again.
+func (def *Definition) Reindex() {
+	def.includeIndex = new(rangeIndex)
+	def.excludeIndex = new(rangeIndex)
+}
+
+// newRangeIndexSet returns the set of the given ranges without attributes, added in order
+// so every range is appended or combined at the end of the set
+func newRangeIndexSet(ranges []iprange.IPAddressRange) *iprange.IPAddressRangeSet {
+	sort.Slice(ranges, func(i, j int) bool { return lessRange(ranges[i], ranges[j]) })
+	set := new(iprange.IPAddressRangeSet)
+	for _, r := range ranges {
+		set.Add(iprange.IPAddressRange{Begin: r.Begin, End: r.End})
+	}
+	return set
+}
+
+// includeRanges returns the include ranges of the definition as IP address ranges
+func (def *Definition) includeRanges() []iprange.IPAddressRange {
+	ranges := make([]iprange.IPAddressRange, 0, len(def.IncludeRanges))
+	for _, r := range def.IncludeRanges {
+		ranges = append(ranges, r.ToIPAddressRange())
+	}
+	return ranges
+}
+
+// excludeRanges returns the exclude ranges of the definition as IP address ranges
+func (def *Definition) excludeRanges() []iprange.IPAddressRange {
+	ranges := make([]iprange.IPAddressRange, 0, len(def.ExcludeRanges))
+	for _, r := range def.ExcludeRanges {
+		ranges = append(ranges, r.ToIPAddressRange())
+	}
+	return ranges
+}
@@ -0,0 +1,90 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package discovery
+
+import (
+	"net"
+	"sync"
+	"testing"
+)
+
+func TestRangeIndex(t *testing.T) {
+	def := &Definition{}
+	if def.IncludeRangesContain("10.0.0.1") || def.ExcludeRangesContain("10.0.0.1") {
+		t.Fatalf("an empty definition should not contain addresses")
+	}
+	def.AddIncludeRange("10.0.0.50", "10.0.0.100")
+	def.AddIncludeRangeWithAttributes("10.0.0.1", "10.0.0.60", Attributes{Location: "Lab"})
+	def.AddExcludeRange("10.0.0.20", "10.0.0.30")
+	if !def.IncludeRangesContain("10.0.0.1") || !def.IncludeRangesContain("10.0.0.100") || def.IncludeRangesContain("10.0.0.101") {
+		t.Errorf("unexpected include membership: %s", def.String())
+	}
+	if !def.ExcludeRangesContain("10.0.0.25") || def.ExcludeRangesContain("10.0.0.31") || def.ExcludeRangesContain("invalid") {
+		t.Errorf("unexpected exclude membership: %s", def.String())
+	}
+
+	// The index must follow the changes of the ranges
+	def.AddIncludeRange("2001:db8::1", "2001:db8::ff")
+	def.ExcludeCIDR("192.168.0.0/24")
+	if !def.IncludeRangesContain("2001:db8::10") || !def.ExcludeRangesContain("192.168.0.10") {
+		t.Errorf("the index should be rebuilt after adding ranges: %s", def.String())
+	}
+	def.IncludeRanges = []IncludeRange{def.IncludeRanges[2]}
+	if def.IncludeRangesContain("10.0.0.1") || !def.IncludeRangesContain("2001:db8::10") {
+		t.Errorf("the checks should scan the ranges after replacing them: %s", def.String())
+	}
+	def.Merge()
+	if def.IncludeRangesContain("10.0.0.1") || !def.IncludeRangesContain("2001:db8::10") || !def.ExcludeRangesContain("10.0.0.20") {
+		t.Errorf("the index should be rebuilt after merging: %s", def.String())
+	}
+}
+
+func TestRangeIndexInPlaceEdits(t *testing.T) {
+	def := &Definition{}
+	def.AddIncludeRange("10.0.0.1", "10.0.0.10")
+	def.AddIncludeRange("10.0.1.1", "10.0.1.10")
+	if !def.IncludeRangesContain("10.0.0.5") {
+		t.Fatalf("unexpected include membership: %s", def.String())
+	}
+
+	// Elements edited in place keep the list and its size, so the index is stale until Reindex is called
+	def.IncludeRanges[0].End = net.ParseIP("10.0.0.20")
+	if def.IncludeRangesContain("10.0.0.15") {
+		t.Errorf("the index is not expected to detect in-place edits")
+	}
+	def.Reindex()
+	if !def.IncludeRangesContain("10.0.0.15") {
+		t.Errorf("the index should be rebuilt after Reindex: %s", def.String())
+	}
+
+	// The methods that change the ranges replace the index
+	def.IncludeRanges[1].Begin = net.ParseIP("10.0.0.30")
+	def.Sort()
+	if !def.IncludeRangesContain("10.0.0.30") || def.IncludeRangesContain("10.0.0.25") {
+		t.Errorf("the index should be rebuilt after sorting: %s", def.String())
+	}
+
+	// Definitions created without the methods scan their ranges
+	literal := &Definition{ExcludeRanges: []ExcludeRange{{Begin: net.ParseIP("10.0.0.1"), End: net.ParseIP("10.0.0.10")}}}
+	if !literal.ExcludeRangesContain("10.0.0.5") || literal.ExcludeRangesContain("10.0.0.11") {
+		t.Errorf("unexpected exclude membership: %s", literal.String())
+	}
+}
+
+func TestRangeIndexConcurrentChecks(t *testing.T) {
+	def := &Definition{}
+	for i := 0; i < 200; i++ {
+		def.IncludeCIDR(net.IPv4(10, byte(i), 0, 0).String() + "/24")
+	}
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !def.IncludeRangesContain("10.100.0.1") || def.IncludeRangesContain("10.100.1.1") {
+				t.Errorf("unexpected include membership")
+			}
+		}()
+	}
+	wg.Wait()
+}
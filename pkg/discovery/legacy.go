@@ -0,0 +1,97 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Layout of the configuration for the releases of OpenNMS without definitions, with the content at the top level
+
+package discovery
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// LegacyConfiguration is the discovery configuration before the definitions, where the specifics, ranges and URLs
+// are at the top level, each one with its own attributes
+type LegacyConfiguration struct {
+	XMLName          xml.Name       `xml:"http://xmlns.opennms.org/xsd/config/discovery discovery-configuration"`
+	PacketsPerSecond int            `xml:"packets-per-second,attr,omitempty"`
+	InitialSleepTime int            `xml:"initial-sleep-time,attr,omitempty"`
+	RestartSleepTime int            `xml:"restart-sleep-time,attr,omitempty"`
+	Retries          int            `xml:"retries,attr,omitempty"`
+	Timeout          int            `xml:"timeout,attr,omitempty"`
+	Specifics        []Specific     `xml:"specific,omitempty"`
+	IncludeRanges    []IncludeRange `xml:"include-range,omitempty"`
+	ExcludeRanges    []ExcludeRange `xml:"exclude-range,omitempty"`
+	IncludeURLs      []IncludeURL   `xml:"include-url,omitempty"`
+}
+
+// String returns the XML representation of the legacy configuration
+func (cfg *LegacyConfiguration) String() string {
+	data, _ := xml.MarshalIndent(cfg, "", "   ")
+	return string(data)
+}
+
+// Legacy returns the configuration in the legacy layout, copying the attributes of each definition to its content, and
+// describes what the layout cannot represent: the detectors, the chunk sizes, and the exclude ranges, which apply to all
+// the content instead of the one of their definition.
+func (cfg *DiscoveryConfiguration) Legacy() (*LegacyConfiguration, []string) {
+	legacy := &LegacyConfiguration{
+		PacketsPerSecond: cfg.PacketsPerSecond,
+		InitialSleepTime: cfg.InitialSleepTime,
+		RestartSleepTime: cfg.RestartSleepTime,
+		Retries:          cfg.Retries,
+		Timeout:          cfg.Timeout,
+	}
+	lost := make([]string, 0)
+	if cfg.ChunkSize > 0 {
+		lost = append(lost, fmt.Sprintf("the chunk size %d of the configuration", cfg.ChunkSize))
+	}
+	excludes := make(map[string]int) // Number of definitions with each exclude range
+	for i := range cfg.Definitions {
+		def := &cfg.Definitions[i]
+		inherit := func(location *string, retries, timeout *int, foreignSource *string) {
+			if *location == "" {
+				*location = def.Location
+			}
+			if *retries == 0 {
+				*retries = def.Retries
+			}
+			if *timeout == 0 {
+				*timeout = def.Timeout
+			}
+			if *foreignSource == "" {
+				*foreignSource = def.ForeignSource
+			}
+		}
+		for _, s := range def.Specifics {
+			inherit(&s.Location, &s.Retries, &s.Timeout, &s.ForeignSource)
+			legacy.Specifics = append(legacy.Specifics, s)
+		}
+		for _, r := range def.IncludeRanges {
+			inherit(&r.Location, &r.Retries, &r.Timeout, &r.ForeignSource)
+			legacy.IncludeRanges = append(legacy.IncludeRanges, r)
+		}
+		for _, u := range def.IncludeURLs {
+			inherit(&u.Location, &u.Retries, &u.Timeout, &u.ForeignSource)
+			legacy.IncludeURLs = append(legacy.IncludeURLs, u)
+		}
+		for _, r := range def.ExcludeRanges {
+			key := r.Location + "/" + r.Begin.String() + "-" + r.End.String()
+			if excludes[key] == 0 {
+				legacy.ExcludeRanges = append(legacy.ExcludeRanges, r)
+			}
+			excludes[key]++
+		}
+		if len(def.Detectors) > 0 {
+			lost = append(lost, fmt.Sprintf("the %d detectors of definition %d", len(def.Detectors), i+1))
+		}
+		if def.ChunkSize > 0 {
+			lost = append(lost, fmt.Sprintf("the chunk size %d of definition %d", def.ChunkSize, i+1))
+		}
+	}
+	for _, r := range legacy.ExcludeRanges {
+		if count := excludes[r.Location+"/"+r.Begin.String()+"-"+r.End.String()]; count < len(cfg.Definitions) {
+			lost = append(lost, fmt.Sprintf("the scope of the exclude range %s-%s, defined by %d of %d definitions", r.Begin, r.End, count, len(cfg.Definitions)))
+		}
+	}
+	return legacy, lost
+}
@@ -0,0 +1,47 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package discovery
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestLegacy(t *testing.T) {
+	servers := Definition{Location: "Paris", ForeignSource: "Servers", Retries: 2, Detectors: []Detector{{Name: "ICMP"}}}
+	servers.AddSpecific("10.0.0.1")
+	servers.AddSpecificWithAttributes("10.0.0.2", Attributes{Timeout: 5000})
+	servers.IncludeCIDR("10.1.0.0/30")
+	servers.ExcludeCIDR("10.1.0.0/31")
+	routers := Definition{ChunkSize: 10}
+	routers.AddSpecificWithAttributes("10.2.0.1", Attributes{Location: "London"})
+	routers.ExcludeCIDR("10.1.0.0/31")
+	routers.ExcludeCIDR("10.3.0.0/31")
+	cfg := &DiscoveryConfiguration{Retries: 1, Timeout: 2000, Definitions: []Definition{servers, routers}}
+
+	legacy, lost := cfg.Legacy()
+	if len(legacy.Specifics) != 3 || len(legacy.IncludeRanges) != 1 || len(legacy.ExcludeRanges) != 2 {
+		t.Fatalf("unexpected content: %+v", legacy)
+	}
+	if s := legacy.Specifics[1]; s.Location != "Paris" || s.ForeignSource != "Servers" || s.Retries != 2 || s.Timeout != 5000 {
+		t.Errorf("the attributes of the definition should be copied: %+v", s)
+	}
+	if s := legacy.Specifics[2]; s.Location != "London" || s.ForeignSource != "" {
+		t.Errorf("the attributes of the specific should be kept: %+v", s)
+	}
+	if len(lost) != 3 || !strings.Contains(lost[0], "detectors") || !strings.Contains(lost[1], "chunk size 10") || !strings.Contains(lost[2], "10.3.0.0") {
+		t.Errorf("unexpected lost content: %v", lost)
+	}
+	if len(servers.Specifics[0].Location) != 0 {
+		t.Errorf("the original configuration should not change")
+	}
+
+	parsed := new(LegacyConfiguration)
+	if err := xml.Unmarshal([]byte(legacy.String()), parsed); err != nil {
+		t.Fatalf("cannot parse legacy configuration: %v", err)
+	}
+	if parsed.String() != legacy.String() || strings.Contains(legacy.String(), "<definition") {
+		t.Errorf("unexpected legacy configuration:\n%s", legacy.String())
+	}
+}
@@ -0,0 +1,189 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Lint rules to find mistakes in a discovery configuration that OpenNMS would accept silently
+
+package discovery
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Severity is the level of a lint finding
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+var severityLevels = map[Severity]int{SeverityInfo: 0, SeverityWarning: 1, SeverityError: 2}
+
+// ParseSeverity validates the name of a severity
+func ParseSeverity(name string) (Severity, error) {
+	s := Severity(strings.ToLower(name))
+	if _, ok := severityLevels[s]; !ok {
+		return "", fmt.Errorf("invalid severity %s; valid severities are info, warning and error", name)
+	}
+	return s, nil
+}
+
+// AtLeast returns true when the severity is equal or higher than the given one
+func (s Severity) AtLeast(other Severity) bool {
+	return severityLevels[s] >= severityLevels[other]
+}
+
+// LintFinding is a problem found by a rule
+type LintFinding struct {
+	Rule       string   `json:"rule"`
+	Severity   Severity `json:"severity"`
+	Definition int      `json:"definition"` // Starting at 1
+	Message    string   `json:"message"`
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s [%s] definition %d: %s", f.Severity, f.Rule, f.Definition, f.Message)
+}
+
+// LintRule is a named check applied to every definition
+type LintRule struct {
+	Name        string
+	Severity    Severity
+	Description string
+	Check       func(cfg *DiscoveryConfiguration, def *Definition) []string // Returns the messages of the findings
+}
+
+// Linter applies the rules to a configuration
+type Linter struct {
+	Rules    []LintRule
+	CheckURL func(url string) error // Verifies that an include URL is reachable; nil to skip the verification
+}
+
+// NewLinter returns a linter with the default rules
+func NewLinter(checkURL func(url string) error) *Linter {
+	l := &Linter{CheckURL: checkURL}
+	l.Rules = []LintRule{
+		{
+			Name:        "missing-detectors",
+			Severity:    SeverityWarning,
+			Description: "Definition without detectors, so only ICMP is used to discover the addresses",
+			Check: func(cfg *DiscoveryConfiguration, def *Definition) []string {
+				if len(def.Detectors) == 0 && !def.IsEmpty() {
+					return []string{"there are no detectors"}
+				}
+				return nil
+			},
+		},
+		{
+			Name:        "missing-location",
+			Severity:    SeverityWarning,
+			Description: "Definition without location when other definitions have one, so its addresses are discovered from the OpenNMS server",
+			Check: func(cfg *DiscoveryConfiguration, def *Definition) []string {
+				if def.Location != "" {
+					return nil
+				}
+				for _, other := range cfg.Definitions {
+					if other.Location != "" {
+						return []string{fmt.Sprintf("there is no location, while other definitions use %s", other.Location)}
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Name:        "exclude-only",
+			Severity:    SeverityError,
+			Description: "Definition with exclude ranges but nothing to include, so it has no effect",
+			Check: func(cfg *DiscoveryConfiguration, def *Definition) []string {
+				if def.IsEmpty() && len(def.ExcludeRanges) > 0 {
+					return []string{fmt.Sprintf("there is nothing to include, so the exclude ranges (%d) have no effect", len(def.ExcludeRanges))}
+				}
+				return nil
+			},
+		},
+		{
+			Name:        "unreachable-include-url",
+			Severity:    SeverityError,
+			Description: "Include URL that cannot be read",
+			Check: func(cfg *DiscoveryConfiguration, def *Definition) []string {
+				if l.CheckURL == nil {
+					return nil
+				}
+				messages := make([]string, 0)
+				for _, u := range def.IncludeURLs {
+					if err := l.CheckURL(u.Content); err != nil {
+						messages = append(messages, fmt.Sprintf("include-url %s is unreachable: %v", redactURL(u.Content), err))
+					}
+				}
+				return messages
+			},
+		},
+		{
+			Name:        "duplicate-detector-names",
+			Severity:    SeverityError,
+			Description: "Detectors with the same name in a definition",
+			Check: func(cfg *DiscoveryConfiguration, def *Definition) []string {
+				counts := make(map[string]int)
+				for _, d := range def.Detectors {
+					counts[d.Name]++
+				}
+				messages := make([]string, 0)
+				for name, count := range counts {
+					if count > 1 {
+						messages = append(messages, fmt.Sprintf("detector %s is defined %d times", name, count))
+					}
+				}
+				sort.Strings(messages)
+				return messages
+			},
+		},
+	}
+	return l
+}
+
+// redactURL hides the password of a URL, as OpenNMS expects the credentials embedded in the include URLs
+func redactURL(raw string) string {
+	if u, err := url.Parse(raw); err == nil {
+		return u.Redacted()
+	}
+	return raw
+}
+
+// Disable removes the rules with the given names, failing when a name is unknown
+func (l *Linter) Disable(names []string) error {
+	for _, name := range names {
+		found := false
+		rules := make([]LintRule, 0, len(l.Rules))
+		for _, r := range l.Rules {
+			if r.Name == name {
+				found = true
+			} else {
+				rules = append(rules, r)
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown lint rule %s", name)
+		}
+		l.Rules = rules
+	}
+	return nil
+}
+
+// Lint returns the findings of all the rules with at least the given severity, ordered by definition
+func (l *Linter) Lint(cfg *DiscoveryConfiguration, minSeverity Severity) []LintFinding {
+	findings := make([]LintFinding, 0)
+	for i := range cfg.Definitions {
+		for _, r := range l.Rules {
+			if !r.Severity.AtLeast(minSeverity) {
+				continue
+			}
+			for _, msg := range r.Check(cfg, &cfg.Definitions[i]) {
+				findings = append(findings, LintFinding{Rule: r.Name, Severity: r.Severity, Definition: i + 1, Message: msg})
+			}
+		}
+	}
+	return findings
+}
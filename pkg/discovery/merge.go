@@ -0,0 +1,181 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Three-way merge between the last deployed configuration (base), the current one (with manual edits),
+// and the newly generated one, at the definition and range level.
+
+package discovery
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// DefinitionKey is used to match definitions between configurations
+func DefinitionKey(def *Definition) string {
+	return fmt.Sprintf("location=%s,foreign-source=%s", def.Location, def.ForeignSource)
+}
+
+// ElementKeys identifies each element by its XML representation, which includes all its attributes
+func ElementKeys(def *Definition) map[string][]string {
+	keys := map[string][]string{}
+	add := func(category string, element interface{}) {
+		data, _ := xml.Marshal(element)
+		keys[category] = append(keys[category], string(data))
+	}
+	for _, e := range def.Specifics {
+		add("specific", e)
+	}
+	for _, e := range def.IncludeRanges {
+		add("include-range", e)
+	}
+	for _, e := range def.ExcludeRanges {
+		add("exclude-range", e)
+	}
+	for _, e := range def.IncludeURLs {
+		add("include-url", e)
+	}
+	return keys
+}
+
+func setElements(def *Definition, keys map[string][]string) {
+	def.Specifics = make([]Specific, len(keys["specific"]))
+	for i, k := range keys["specific"] {
+		xml.Unmarshal([]byte(k), &def.Specifics[i])
+	}
+	def.IncludeRanges = make([]IncludeRange, len(keys["include-range"]))
+	for i, k := range keys["include-range"] {
+		xml.Unmarshal([]byte(k), &def.IncludeRanges[i])
+	}
+	def.ExcludeRanges = make([]ExcludeRange, len(keys["exclude-range"]))
+	for i, k := range keys["exclude-range"] {
+		xml.Unmarshal([]byte(k), &def.ExcludeRanges[i])
+	}
+	def.IncludeURLs = make([]IncludeURL, len(keys["include-url"]))
+	for i, k := range keys["include-url"] {
+		xml.Unmarshal([]byte(k), &def.IncludeURLs[i])
+	}
+	def.Reindex()
+}
+
+func toSet(keys []string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		set[k] = true
+	}
+	return set
+}
+
+// mergeKeys keeps the generated elements, plus the manual additions (in current but not in base).
+// Manual removals of elements that are still generated are reported as conflicts, and the generated element wins.
+func mergeKeys(base, current, generated []string) ([]string, []string) {
+	baseSet, currentSet, generatedSet := toSet(base), toSet(current), toSet(generated)
+	result := append([]string{}, generated...)
+	conflicts := make([]string, 0)
+	for _, k := range generated {
+		if baseSet[k] && !currentSet[k] {
+			conflicts = append(conflicts, fmt.Sprintf("%s was removed manually but it is still generated", k))
+		}
+	}
+	for _, k := range current {
+		if !baseSet[k] && !generatedSet[k] {
+			result = append(result, k)
+		}
+	}
+	return result, conflicts
+}
+
+// ThreeWayMerge merges the manual changes applied to the current configuration since the base was deployed
+// into the generated configuration, returning the merged configuration and the conflicts that need human review.
+func ThreeWayMerge(base, current, generated *DiscoveryConfiguration) (*DiscoveryConfiguration, []string) {
+	merged := *generated
+	merged.Definitions = make([]Definition, 0, len(generated.Definitions))
+	conflicts := make([]string, 0)
+
+	if base.PacketsPerSecond != current.PacketsPerSecond || base.InitialSleepTime != current.InitialSleepTime ||
+		base.RestartSleepTime != current.RestartSleepTime || base.Retries != current.Retries ||
+		base.Timeout != current.Timeout || base.ChunkSize != current.ChunkSize {
+		conflicts = append(conflicts, "the global discovery settings were modified manually; using the generated ones")
+	}
+
+	find := func(cfg *DiscoveryConfiguration, key string) *Definition {
+		for i := range cfg.Definitions {
+			if DefinitionKey(&cfg.Definitions[i]) == key {
+				return &cfg.Definitions[i]
+			}
+		}
+		return nil
+	}
+
+	for i := range generated.Definitions {
+		def := generated.Definitions[i]
+		key := DefinitionKey(&def)
+		b, c := find(base, key), find(current, key)
+		if c == nil {
+			if b != nil {
+				conflicts = append(conflicts, fmt.Sprintf("definition %s was removed manually but it is still generated", key))
+			}
+			merged.Definitions = append(merged.Definitions, def)
+			continue
+		}
+		if b == nil {
+			b = new(Definition)
+		}
+		baseKeys, currentKeys, generatedKeys := ElementKeys(b), ElementKeys(c), ElementKeys(&def)
+		mergedKeys := map[string][]string{}
+		for _, category := range []string{"specific", "include-range", "exclude-range", "include-url"} {
+			keys, issues := mergeKeys(baseKeys[category], currentKeys[category], generatedKeys[category])
+			mergedKeys[category] = keys
+			for _, issue := range issues {
+				conflicts = append(conflicts, fmt.Sprintf("definition %s: %s", key, issue))
+			}
+		}
+		setElements(&def, mergedKeys)
+		merged.Definitions = append(merged.Definitions, def)
+	}
+
+	// Preserve definitions added manually
+	for i := range current.Definitions {
+		key := DefinitionKey(&current.Definitions[i])
+		if find(base, key) == nil && find(generated, key) == nil {
+			merged.Definitions = append(merged.Definitions, current.Definitions[i])
+		}
+	}
+	return &merged, conflicts
+}
+
+// MergeExisting merges the generated elements into the existing configuration, preserving all its content (including the
+// global settings and the hand-edited entries), and returns the merged configuration with the added elements (the delta).
+func MergeExisting(existing, generated *DiscoveryConfiguration) (*DiscoveryConfiguration, []string) {
+	merged := *existing
+	merged.Definitions = append([]Definition{}, existing.Definitions...)
+	delta := make([]string, 0)
+	for i := range generated.Definitions {
+		def := generated.Definitions[i]
+		key := DefinitionKey(&def)
+		var target *Definition
+		for j := range merged.Definitions {
+			if DefinitionKey(&merged.Definitions[j]) == key {
+				target = &merged.Definitions[j]
+				break
+			}
+		}
+		if target == nil {
+			merged.Definitions = append(merged.Definitions, def)
+			delta = append(delta, fmt.Sprintf("+ definition %s", key))
+			continue
+		}
+		existingKeys, generatedKeys := ElementKeys(target), ElementKeys(&def)
+		for _, category := range []string{"specific", "include-range", "exclude-range", "include-url"} {
+			present := toSet(existingKeys[category])
+			for _, k := range generatedKeys[category] {
+				if !present[k] {
+					existingKeys[category] = append(existingKeys[category], k)
+					present[k] = true
+					delta = append(delta, fmt.Sprintf("+ definition %s: %s", key, k))
+				}
+			}
+		}
+		setElements(target, existingKeys)
+	}
+	return &merged, delta
+}
@@ -0,0 +1,81 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package discovery
+
+import (
+	"testing"
+)
+
+func TestThreeWayMerge(t *testing.T) {
+	b := Definition{}
+	b.IncludeCIDR("192.168.0.0/24")
+	b.AddSpecific("10.0.0.1")
+	b.AddSpecific("10.0.0.2")
+	base := &DiscoveryConfiguration{Retries: 1, Definitions: []Definition{b}}
+
+	// Manual edits: add a specific and a definition, remove 10.0.0.2
+	c := Definition{}
+	c.IncludeCIDR("192.168.0.0/24")
+	c.AddSpecific("10.0.0.1")
+	c.AddSpecific("10.0.0.100")
+	extra := Definition{Location: "Lab"}
+	extra.AddSpecific("172.16.0.1")
+	current := &DiscoveryConfiguration{Retries: 1, Definitions: []Definition{c, extra}}
+
+	// New generation: add a range, keep 10.0.0.2, remove 10.0.0.1
+	g := Definition{}
+	g.IncludeCIDR("192.168.0.0/24")
+	g.IncludeCIDR("192.168.1.0/24")
+	g.AddSpecific("10.0.0.2")
+	generated := &DiscoveryConfiguration{Retries: 1, Definitions: []Definition{g}}
+
+	merged, conflicts := ThreeWayMerge(base, current, generated)
+	if len(merged.Definitions) != 2 {
+		t.Fatalf("the manually added definition should have been preserved: %s", merged.String())
+	}
+	d := merged.Definitions[0]
+	if len(d.IncludeRanges) != 2 {
+		t.Errorf("the generated ranges should be present: %s", merged.String())
+	}
+	if len(d.Specifics) != 2 || d.GetSpecific("10.0.0.2") == nil || d.GetSpecific("10.0.0.100") == nil {
+		t.Errorf("the merged specifics are incorrect: %s", merged.String())
+	}
+	if d.GetSpecific("10.0.0.1") != nil {
+		t.Errorf("the specific removed by the generator should not be present")
+	}
+	if len(conflicts) != 1 {
+		t.Errorf("the manual removal of 10.0.0.2 should be a conflict: %v", conflicts)
+	}
+}
+
+func TestMergeExisting(t *testing.T) {
+	e := Definition{}
+	e.IncludeCIDR("192.168.0.0/24")
+	e.AddSpecific("10.0.0.100") // Hand-edited
+	existing := &DiscoveryConfiguration{Retries: 3, Definitions: []Definition{e}}
+
+	g := Definition{}
+	g.IncludeCIDR("192.168.0.0/24")
+	g.AddSpecific("10.0.0.1")
+	lab := Definition{Location: "Lab"}
+	lab.AddSpecific("172.16.0.1")
+	generated := &DiscoveryConfiguration{Retries: 1, Definitions: []Definition{g, lab}}
+
+	merged, delta := MergeExisting(existing, generated)
+	if merged.Retries != 3 {
+		t.Errorf("the existing global settings should be preserved")
+	}
+	if len(merged.Definitions) != 2 {
+		t.Fatalf("the new definition should be added: %s", merged.String())
+	}
+	d := merged.Definitions[0]
+	if len(d.IncludeRanges) != 1 || len(d.Specifics) != 2 || d.Specifics[0].IP.String() != "10.0.0.100" {
+		t.Errorf("the existing entries should be preserved, and the new ones added: %s", merged.String())
+	}
+	if len(delta) != 2 {
+		t.Errorf("only the new specific and definition should be reported: %v", delta)
+	}
+	if len(existing.Definitions[0].Specifics) != 1 {
+		t.Errorf("the existing configuration should not be modified")
+	}
+}
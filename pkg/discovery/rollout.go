@@ -0,0 +1,77 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Incremental rollout of large additions, so Provisiond is not flooded with new suspects in a single run
+
+package discovery
+
+import (
+	"math/big"
+
+	"github.com/agalue/onms-discovery-config/pkg/iprange"
+)
+
+// Scope returns the addresses discovered by the configuration, without attributes, ignoring the include URLs
+func (cfg *DiscoveryConfiguration) Scope() *iprange.IPAddressRangeSet {
+	scope := new(iprange.IPAddressRangeSet)
+	if cfg == nil {
+		return scope
+	}
+	for i := range cfg.Definitions {
+		def := &cfg.Definitions[i]
+		def.Sort()
+		rangeSet := def.rangeSet()
+		rangeSet.Subtract(def.excludeSet())
+		for _, r := range rangeSet.Get() {
+			scope.Add(iprange.IPAddressRange{Begin: r.Begin, End: r.End})
+		}
+	}
+	return scope
+}
+
+// LimitAdditions compares the scope of the configuration against the current one (nil when there is none), and when it adds
+// more than max addresses, it keeps only the first batch of them, so the rest are added on the next runs.
+// It returns the number of addresses added by the configuration, and the number of them deferred.
+func (cfg *DiscoveryConfiguration) LimitAdditions(current *DiscoveryConfiguration, max, batch int) (*big.Int, *big.Int) {
+	currentScope := current.Scope()
+	added := big.NewInt(0)
+	additions := make([]*iprange.IPAddressRangeSet, len(cfg.Definitions))
+	for i := range cfg.Definitions {
+		def := &cfg.Definitions[i]
+		def.Sort()
+		additions[i] = def.rangeSet()
+		additions[i].Subtract(def.excludeSet())
+		additions[i].Subtract(currentScope)
+		for _, r := range additions[i].Get() {
+			added.Add(added, r.Size())
+		}
+	}
+	deferred := big.NewInt(0)
+	if added.Cmp(big.NewInt(int64(max))) <= 0 {
+		return added, deferred
+	}
+	budget := big.NewInt(int64(batch))
+	for i := range cfg.Definitions {
+		postponed := new(iprange.IPAddressRangeSet)
+		for _, r := range additions[i].Get() {
+			size := r.Size()
+			if budget.Cmp(size) >= 0 {
+				budget.Sub(budget, size)
+				continue
+			}
+			if budget.Sign() > 0 {
+				size.Sub(size, budget)
+				r.Begin = iprange.Offset(r.Begin, budget.Int64())
+				budget.SetInt64(0)
+			}
+			deferred.Add(deferred, size)
+			postponed.Add(r)
+		}
+		if len(postponed.Get()) > 0 {
+			def := &cfg.Definitions[i]
+			rangeSet := def.rangeSet()
+			rangeSet.Subtract(postponed)
+			def.setRanges(rangeSet.Get())
+		}
+	}
+	return added, deferred
+}
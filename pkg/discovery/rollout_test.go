@@ -0,0 +1,57 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package discovery
+
+import (
+	"testing"
+)
+
+func TestLimitAdditions(t *testing.T) {
+	build := func() *DiscoveryConfiguration {
+		def := Definition{}
+		def.IncludeCIDR("192.168.0.0/24") // Already deployed
+		def.IncludeCIDR("10.0.0.0/24")
+		def.ExcludeCIDR("10.0.0.0/26")
+		def.AddSpecific("172.16.0.1")
+		lab := Definition{Location: "Lab"}
+		lab.IncludeCIDR("10.1.0.0/24")
+		return &DiscoveryConfiguration{Definitions: []Definition{def, lab}}
+	}
+	c := Definition{}
+	c.IncludeCIDR("192.168.0.0/24")
+	current := &DiscoveryConfiguration{Definitions: []Definition{c}}
+
+	// 10.0.0.63-10.0.0.254 (192), 172.16.0.1 (1), and 10.1.0.1-10.1.0.254 (254)
+	cfg := build()
+	added, deferred := cfg.LimitAdditions(current, 500, 100)
+	if added.Int64() != 447 || deferred.Sign() != 0 {
+		t.Errorf("nothing should be deferred below the limit: added=%s, deferred=%s", added, deferred)
+	}
+
+	cfg = build()
+	added, deferred = cfg.LimitAdditions(current, 400, 100)
+	if added.Int64() != 447 || deferred.Int64() != 347 {
+		t.Fatalf("unexpected additions: added=%s, deferred=%s", added, deferred)
+	}
+	if d := cfg.Definitions[0]; len(d.IncludeRanges) != 2 || d.IncludeRanges[0].End.String() != "10.0.0.162" || d.IncludeRanges[1].Begin.String() != "192.168.0.1" || len(d.Specifics) != 0 {
+		t.Errorf("only the first 100 new addresses should be kept: %s", d.String())
+	}
+	if d := cfg.Definitions[1]; !d.IsEmpty() {
+		t.Errorf("the additions of the second definition should be deferred: %s", d.String())
+	}
+	if scope := cfg.GetTotalEstimatedAddresses(); scope.Int64() != 354 {
+		t.Errorf("the scope should be the current one plus the batch: %s", scope)
+	}
+
+	// The next run, the first batch is part of the current configuration
+	c.AddIncludeRange("10.0.0.63", "10.0.0.162")
+	current = &DiscoveryConfiguration{Definitions: []Definition{c}}
+	cfg = build()
+	added, deferred = cfg.LimitAdditions(current, 300, 300)
+	if added.Int64() != 347 || deferred.Int64() != 47 {
+		t.Errorf("unexpected additions on the next run: added=%s, deferred=%s", added, deferred)
+	}
+	if d := cfg.Definitions[1]; len(d.IncludeRanges) != 1 || d.IncludeRanges[0].End.String() != "10.1.0.207" {
+		t.Errorf("the second batch should reach the second definition: %s", d.String())
+	}
+}
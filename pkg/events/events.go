@@ -0,0 +1,259 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Representation and helper functions for an events log object
+// https://github.com/OpenNMS/opennms/blob/master/features/events/api/src/main/java/org/opennms/netmgt/xml/event/Log.java
+// https://github.com/OpenNMS/opennms/blob/master/opennms-base-assembly/src/main/filtered/bin/send-event.pl
+
+// Package events provides the OpenNMS events and the means to send them to eventd via TCP or TLS.
+package events
+
+import (
+	"crypto/tls"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severity represents the severity of an event
+type Severity string
+
+const (
+	SeverityIndeterminate Severity = "Indeterminate"
+	SeverityCleared       Severity = "Cleared"
+	SeverityNormal        Severity = "Normal"
+	SeverityWarning       Severity = "Warning"
+	SeverityMinor         Severity = "Minor"
+	SeverityMajor         Severity = "Major"
+	SeverityCritical      Severity = "Critical"
+)
+
+// ParseSeverity returns the severity that matches the given name (case insensitive)
+func ParseSeverity(name string) (Severity, error) {
+	for _, s := range []Severity{SeverityIndeterminate, SeverityCleared, SeverityNormal, SeverityWarning, SeverityMinor, SeverityMajor, SeverityCritical} {
+		if strings.EqualFold(string(s), name) {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("invalid severity %s", name)
+}
+
+// Destinations for the log message of an event
+const (
+	LogDestLogAndDisplay = "logndisplay"
+	LogDestDisplayOnly   = "displayonly"
+	LogDestLogOnly       = "logonly"
+	LogDestSuppress      = "suppress"
+	LogDestDoNotPersist  = "donotpersist"
+)
+
+// LogMsg represents the log message of an event
+type LogMsg struct {
+	XMLName xml.Name `xml:"logmsg"`
+	Dest    string   `xml:"dest,attr,omitempty"`
+	Content string   `xml:",chardata"`
+}
+
+// ParmValue represents the value of an event parameter
+type ParmValue struct {
+	XMLName  xml.Name `xml:"value"`
+	Type     string   `xml:"type,attr"`
+	Encoding string   `xml:"encoding,attr"`
+	Content  string   `xml:",chardata"`
+}
+
+// Parm represents an event parameter
+type Parm struct {
+	XMLName xml.Name  `xml:"parm"`
+	Name    string    `xml:"parmName"`
+	Value   ParmValue `xml:"value"`
+}
+
+// Event represents an OpenNMS event
+type Event struct {
+	XMLName     xml.Name `xml:"event"`
+	UEI         string   `xml:"uei"`
+	Source      string   `xml:"source,omitempty"`
+	NodeID      int      `xml:"nodeid,omitempty"`
+	Time        string   `xml:"time,omitempty"`
+	Host        string   `xml:"host,omitempty"`
+	Interface   string   `xml:"interface,omitempty"`
+	Service     string   `xml:"service,omitempty"`
+	IfIndex     int      `xml:"ifIndex,omitempty"`
+	Parameters  []Parm   `xml:"parms>parm"`
+	Description string   `xml:"descr,omitempty"`
+	LogMsg      *LogMsg  `xml:"logmsg,omitempty"`
+	Severity    Severity `xml:"severity,omitempty"`
+}
+
+// AddParam adds a string parameter to the event
+func (e *Event) AddParam(key, value string) {
+	e.Parameters = append(e.Parameters, Parm{
+		Name: key,
+		Value: ParmValue{
+			Type:     "string",
+			Encoding: "text",
+			Content:  value,
+		},
+	})
+}
+
+// SetLogMsg sets the log message of the event and its destination (e.g. LogDestLogAndDisplay)
+func (e *Event) SetLogMsg(msg, dest string) {
+	e.LogMsg = &LogMsg{Content: msg, Dest: dest}
+}
+
+// SetDescription sets the description of the event
+func (e *Event) SetDescription(descr string) {
+	e.Description = descr
+}
+
+// SetSeverity sets the severity of the event
+func (e *Event) SetSeverity(severity Severity) {
+	e.Severity = severity
+}
+
+// Log represents a collection of events
+type Log struct {
+	XMLName xml.Name `xml:"log"`
+	Events  []Event  `xml:"events>event"`
+}
+
+// Add adds an event to the log
+func (log *Log) Add(e Event) {
+	log.Events = append(log.Events, e)
+}
+
+// DefaultTimeout is the maximum time to connect and send a log to eventd, unless SendOptions specifies it
+const DefaultTimeout = 30 * time.Second
+
+// DefaultBackoff is the time to wait before the first retry, unless SendOptions specifies it
+const DefaultBackoff = time.Second
+
+// MaxBackoff is the longest time to wait between attempts
+const MaxBackoff = time.Minute
+
+// SendOptions controls the connection to eventd
+type SendOptions struct {
+	TLS     *tls.Config   // Connect via TLS when not nil (e.x. through a TLS-terminating proxy); add Certificates for mutual TLS
+	Timeout time.Duration // Maximum time to connect and send the log on each attempt; DefaultTimeout when zero
+	Retries int           // Number of additional attempts when the log cannot be delivered (e.x. while eventd restarts)
+	Backoff time.Duration // Time to wait before the first retry, doubled after each attempt; DefaultBackoff when zero
+}
+
+// Backoff returns the time to wait after the given attempt (starting at 0), doubling the initial delay up to MaxBackoff
+func Backoff(initial time.Duration, attempt int) time.Duration {
+	delay := initial
+	for i := 0; i < attempt && delay < MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > MaxBackoff {
+		return MaxBackoff
+	}
+	return delay
+}
+
+// Send sends the log to eventd via TCP, with the default options
+func (log *Log) Send(target string, port int) error {
+	return log.SendWithOptions(target, port, SendOptions{})
+}
+
+// SendWithOptions sends the log to eventd via TCP or TLS, failing each attempt that takes longer than the timeout.
+// It retries with exponential backoff only when nothing was written (e.x. while eventd restarts), as resending
+// a log that was partially or fully written could duplicate its events.
+func (log *Log) SendWithOptions(target string, port int, opts SendOptions) error {
+	initial := opts.Backoff
+	if initial <= 0 {
+		initial = DefaultBackoff
+	}
+	var err error
+	var written bool
+	attempt := 0
+	for ; ; attempt++ {
+		if written, err = log.send(target, port, opts); err == nil || written || attempt >= opts.Retries {
+			break
+		}
+		time.Sleep(Backoff(initial, attempt))
+	}
+	if err != nil && attempt > 0 {
+		return fmt.Errorf("%v (after %d attempts)", err, attempt+1)
+	}
+	return err
+}
+
+// send makes a single attempt to deliver the log, reporting whether any of it was written
+func (log *Log) send(target string, port int, opts SendOptions) (bool, error) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	dialer := &net.Dialer{Deadline: deadline}
+	address := net.JoinHostPort(target, strconv.Itoa(port))
+	var conn net.Conn
+	var err error
+	if opts.TLS != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, opts.TLS)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return false, err
+	}
+	conn.SetDeadline(deadline)
+	bytes, _ := xml.Marshal(log)
+	n, err := conn.Write(bytes)
+	if err != nil {
+		conn.Close()
+		if n > 0 {
+			return true, fmt.Errorf("partial write of %d of %d bytes: %v", n, len(bytes), err)
+		}
+		return false, err
+	}
+	return true, conn.Close() // Reports the errors of closing, e.x. when sending the TLS close_notify
+}
+
+// BatchError reports the batch that couldn't be delivered; the events before it were, so a caller can resume from Sent
+type BatchError struct {
+	Sent int   // Number of events delivered by the previous batches
+	Size int   // Number of events of the failed batch
+	Err  error // Why the batch failed
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("cannot send events %d to %d: %v", e.Sent, e.Sent+e.Size-1, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// SendBatches sends the events in chunks of at most batchSize events per connection,
+// waiting for the given delay between chunks, to avoid overwhelming eventd with a single large payload.
+func (log *Log) SendBatches(target string, port int, batchSize int, delay time.Duration) error {
+	return log.SendBatchesWithOptions(target, port, batchSize, delay, SendOptions{})
+}
+
+// SendBatchesWithOptions is like SendBatches, with the options of each connection; the retries apply to each batch,
+// so only the one that failed is sent again, and a *BatchError reports how many events were delivered
+func (log *Log) SendBatchesWithOptions(target string, port int, batchSize int, delay time.Duration, opts SendOptions) error {
+	if batchSize <= 0 || len(log.Events) <= batchSize {
+		return log.SendWithOptions(target, port, opts)
+	}
+	for i := 0; i < len(log.Events); i += batchSize {
+		end := i + batchSize
+		if end > len(log.Events) {
+			end = len(log.Events)
+		}
+		if i > 0 && delay > 0 {
+			time.Sleep(delay)
+		}
+		batch := &Log{Events: log.Events[i:end]}
+		if err := batch.SendWithOptions(target, port, opts); err != nil {
+			return &BatchError{Sent: i, Size: end - i, Err: err}
+		}
+	}
+	return nil
+}
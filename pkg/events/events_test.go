@@ -0,0 +1,343 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package events
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseEventLog(t *testing.T) {
+	// The reference config was created using send-event.pl
+	referenceConfig := `
+	<log>
+	<events>
+	<event >
+	<uei>uei.opennms.org/internal/reloadDaemonConfig</uei>
+	<source>perl_send_event</source>
+	<time>2021-11-03T20:08:39+00:00</time>
+	<host>agalue-mbp.local</host>
+	<parms>
+		<parm>
+		<parmName><![CDATA[daemonName]]></parmName>
+		<value type="string" encoding="text"><![CDATA[Discovery]]></value>
+		</parm>
+	</parms>
+	</event>
+	</events>
+	</log>
+	`
+	log := new(Log)
+	if err := xml.Unmarshal([]byte(referenceConfig), log); err != nil {
+		t.Errorf("cannot parse configuration: %v", err)
+	}
+	output, _ := xml.MarshalIndent(log, "", "  ")
+	fmt.Println(string(output))
+
+	// Validating content
+	if len(log.Events) != 1 {
+		t.Errorf("the log should have one event")
+	}
+	if log.Events[0].UEI != "uei.opennms.org/internal/reloadDaemonConfig" {
+		t.Errorf("the event has a wrong UEI")
+	}
+	if len(log.Events[0].Parameters) != 1 {
+		t.Errorf("the event should have one parameter")
+	}
+	if log.Events[0].Parameters[0].Name != "daemonName" {
+		t.Errorf("the event parameter name is wrong")
+	}
+	if log.Events[0].Parameters[0].Value.Content != "Discovery" {
+		t.Errorf("the event parameter value is wrong")
+	}
+}
+
+func TestAddEvent(t *testing.T) {
+	log := new(Log)
+	log.Add(Event{UEI: "uei.opennms.org/test1"})
+	log.Add(Event{UEI: "uei.opennms.org/test2"})
+	if len(log.Events) != 2 {
+		t.Errorf("the log should have two events")
+	}
+}
+
+func TestSendEvent(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot create TCP server: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		log := new(Log)
+		log.Add(Event{UEI: "uei.opennms.org/test"})
+		if err := log.Send("127.0.0.1", ln.Addr().(*net.TCPAddr).Port); err != nil {
+			t.Errorf("cannot send event: %v", err)
+		}
+	}()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Errorf("cannot accept connections: %v", err)
+	}
+	defer conn.Close()
+
+	buf, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Errorf("cannot read content: %v", err)
+	}
+	received := new(Log)
+	xml.Unmarshal(buf, received)
+	if received.Events[0].UEI != "uei.opennms.org/test" {
+		t.Errorf("incorrect message received: %s", string(buf))
+	}
+}
+
+func TestSendBatches(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot create TCP server: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		log := new(Log)
+		for i := 0; i < 5; i++ {
+			log.Add(Event{UEI: fmt.Sprintf("uei.opennms.org/test%d", i)})
+		}
+		if err := log.SendBatches("127.0.0.1", ln.Addr().(*net.TCPAddr).Port, 2, 10*time.Millisecond); err != nil {
+			t.Errorf("cannot send events: %v", err)
+		}
+	}()
+
+	sizes := make([]int, 0)
+	for i := 0; i < 3; i++ {
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Fatalf("cannot accept connections: %v", err)
+		}
+		buf, err := ioutil.ReadAll(conn)
+		if err != nil {
+			t.Errorf("cannot read content: %v", err)
+		}
+		conn.Close()
+		received := new(Log)
+		xml.Unmarshal(buf, received)
+		sizes = append(sizes, len(received.Events))
+	}
+	if sizes[0] != 2 || sizes[1] != 2 || sizes[2] != 1 {
+		t.Errorf("incorrect batch sizes: %v", sizes)
+	}
+}
+
+func TestSendWithOptions(t *testing.T) {
+	server := httptest.NewUnstartedServer(nil) // Only to get a certificate valid for 127.0.0.1
+	server.StartTLS()
+	cert := server.TLS.Certificates[0]
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	server.Close()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}, ClientAuth: tls.RequireAnyClientCert})
+	if err != nil {
+		t.Fatalf("cannot create TLS server: %v", err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf, _ := ioutil.ReadAll(conn)
+		if len(conn.(*tls.Conn).ConnectionState().PeerCertificates) == 0 {
+			t.Errorf("the client certificate was not sent")
+		}
+		received <- string(buf)
+	}()
+
+	log := new(Log)
+	log.Add(Event{UEI: "uei.opennms.org/test"})
+	opts := SendOptions{TLS: &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{cert}}, Timeout: time.Second}
+	if err := log.SendWithOptions("127.0.0.1", port, opts); err != nil {
+		t.Fatalf("cannot send event via TLS: %v", err)
+	}
+	buf := <-received
+	parsed := new(Log)
+	xml.Unmarshal([]byte(buf), parsed)
+	if len(parsed.Events) != 1 || parsed.Events[0].UEI != "uei.opennms.org/test" {
+		t.Errorf("incorrect message received: %s", buf)
+	}
+
+	silent, err := net.Listen("tcp", "127.0.0.1:0") // Accepts connections, but never completes the TLS handshake
+	if err != nil {
+		t.Fatalf("cannot create TCP server: %v", err)
+	}
+	defer silent.Close()
+	start := time.Now()
+	opts.Timeout = 200 * time.Millisecond
+	if err := log.SendWithOptions("127.0.0.1", silent.Addr().(*net.TCPAddr).Port, opts); err == nil {
+		t.Errorf("the connection should time out")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the timeout was not honored: %v", elapsed)
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot create TCP server: %v", err)
+	}
+	address := ln.Addr().String()
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close() // Simulates eventd restarting
+
+	received := make(chan string, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		ln, err := net.Listen("tcp", address)
+		if err != nil {
+			t.Errorf("cannot create TCP server: %v", err)
+			return
+		}
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf, _ := ioutil.ReadAll(conn)
+		received <- string(buf)
+	}()
+
+	log := new(Log)
+	log.Add(Event{UEI: "uei.opennms.org/test"})
+	if err := log.SendWithOptions("127.0.0.1", port, SendOptions{Timeout: time.Second}); err == nil {
+		t.Fatalf("the event should not be delivered without retries")
+	}
+	if err := log.SendWithOptions("127.0.0.1", port, SendOptions{Timeout: time.Second, Retries: 5, Backoff: 20 * time.Millisecond}); err != nil {
+		t.Fatalf("the event should be delivered after eventd is back: %v", err)
+	}
+	parsed := new(Log)
+	xml.Unmarshal([]byte(<-received), parsed)
+	if len(parsed.Events) != 1 {
+		t.Errorf("the event was not received")
+	}
+
+	err = log.SendWithOptions("127.0.0.1", port, SendOptions{Retries: 1, Backoff: time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "after 2 attempts") {
+		t.Errorf("the error should report the attempts: %v", err)
+	}
+
+	for attempt, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if delay := Backoff(time.Second, attempt); delay != expected {
+			t.Errorf("unexpected backoff for attempt %d: %v", attempt, delay)
+		}
+	}
+	if delay := Backoff(time.Second, 20); delay != MaxBackoff {
+		t.Errorf("the backoff should be limited to %v: %v", MaxBackoff, delay)
+	}
+}
+
+func TestSendNoRetryAfterWrite(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot create TCP server: %v", err)
+	}
+	defer ln.Close()
+	connections := make(chan int, 10)
+	go func() {
+		for count := 1; ; count++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Read(make([]byte, 1024)) // Reads part of the log, and then resets the connection
+			conn.(*net.TCPConn).SetLinger(0)
+			conn.Close()
+			connections <- count
+		}
+	}()
+
+	log := new(Log)
+	for i := 0; i < 50000; i++ {
+		e := Event{UEI: "uei.opennms.org/test", Interface: "10.0.0.1"}
+		e.AddParam("padding", strings.Repeat("x", 100))
+		log.Add(e)
+	}
+	log.SendWithOptions("127.0.0.1", ln.Addr().(*net.TCPAddr).Port, SendOptions{Timeout: 5 * time.Second, Retries: 3, Backoff: time.Millisecond})
+	time.Sleep(100 * time.Millisecond)
+	if count := len(connections); count != 1 {
+		t.Errorf("a log that was partially written should not be sent again: %d connections", count)
+	}
+}
+
+func TestSendBatchesResume(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot create TCP server: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		ioutil.ReadAll(conn)
+		conn.Close()
+		ln.Close() // Only the first batch is delivered
+	}()
+
+	log := new(Log)
+	for i := 0; i < 5; i++ {
+		log.Add(Event{UEI: fmt.Sprintf("uei.opennms.org/test%d", i)})
+	}
+	err = log.SendBatchesWithOptions("127.0.0.1", port, 2, 10*time.Millisecond, SendOptions{Timeout: time.Second, Retries: 1, Backoff: time.Millisecond})
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("the error should report the failed batch: %v", err)
+	}
+	if batchErr.Sent != 2 || batchErr.Size != 2 {
+		t.Errorf("unexpected failed batch: %+v", batchErr)
+	}
+	if !strings.Contains(err.Error(), "cannot send events 2 to 3") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestEventHelpers(t *testing.T) {
+	e := Event{UEI: "uei.opennms.org/test"}
+	e.AddParam("key", "value")
+	e.SetLogMsg("Test Message", LogDestLogAndDisplay)
+	e.SetDescription("Test Description")
+	e.SetSeverity(SeverityMajor)
+	bytes, err := xml.Marshal(e)
+	if err != nil {
+		t.Fatalf("cannot marshal event: %v", err)
+	}
+	expected := `<event><uei>uei.opennms.org/test</uei><parms><parm><parmName>key</parmName><value type="string" encoding="text">value</value></parm></parms><descr>Test Description</descr><logmsg dest="logndisplay">Test Message</logmsg><severity>Major</severity></event>`
+	if string(bytes) != expected {
+		t.Errorf("incorrect event: %s", string(bytes))
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	if s, err := ParseSeverity("critical"); err != nil || s != SeverityCritical {
+		t.Errorf("critical should be a valid severity")
+	}
+	if _, err := ParseSeverity("unknown"); err == nil {
+		t.Errorf("unknown should not be a valid severity")
+	}
+}
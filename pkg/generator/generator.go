@@ -0,0 +1,342 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Generation of discovery configurations for programs embedding it, with progress reporting and cancellation
+
+// Package generator builds discovery configurations from pluggable sources of CIDRs and IP addresses.
+// Unlike the command, it doesn't rely on global state nor logs, so multiple runs can happen concurrently.
+package generator
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+// EntryKind is the purpose of an entry from a source
+type EntryKind int
+
+const (
+	IncludeCIDR EntryKind = iota
+	ExcludeCIDR
+	IncludeIP
+	ExcludeIP
+)
+
+// Entry is a CIDR or IP address provided by a source
+type Entry struct {
+	Kind       EntryKind
+	Value      string
+	Attributes discovery.Attributes // Only for inclusions
+}
+
+// Source provides entries for a definition, passing each of them to emit; it should stop when emit returns an error
+type Source interface {
+	Name() string
+	Read(ctx context.Context, emit func(Entry) error) error
+}
+
+type listSource struct {
+	name    string
+	entries []Entry
+}
+
+func (s *listSource) Name() string {
+	return s.name
+}
+
+func (s *listSource) Read(ctx context.Context, emit func(Entry) error) error {
+	for _, e := range s.entries {
+		if err := emit(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListSource returns a source with the given values, all of the same kind and with the same attributes
+func ListSource(name string, kind EntryKind, values []string, attrs discovery.Attributes) Source {
+	entries := make([]Entry, 0, len(values))
+	for _, v := range values {
+		entries = append(entries, Entry{Kind: kind, Value: v, Attributes: attrs})
+	}
+	return &listSource{name: name, entries: entries}
+}
+
+// MaxLineSize is the maximum length in bytes of a line from the files of FileSource
+var MaxLineSize = 16 * 1024 * 1024
+
+type fileSource struct {
+	name  string
+	path  string
+	kind  EntryKind
+	attrs discovery.Attributes
+}
+
+func (s *fileSource) Name() string {
+	return s.name
+}
+
+func (s *fileSource) Read(ctx context.Context, emit func(Entry) error) error {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %v", s.path, err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), MaxLineSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := emit(Entry{Kind: s.kind, Value: line, Attributes: s.attrs}); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("cannot read %s: %v", s.path, err)
+	}
+	return nil
+}
+
+// FileSource returns a source with the values from a file, one per line, ignoring empty lines and comments
+func FileSource(name, path string, kind EntryKind, attrs discovery.Attributes) Source {
+	return &fileSource{name: name, path: path, kind: kind, attrs: attrs}
+}
+
+// DefinitionSpec is a definition with the sources of its content; the content of the definition is kept
+type DefinitionSpec struct {
+	Definition discovery.Definition
+	Sources    []Source
+}
+
+type Options struct {
+	Base             discovery.DiscoveryConfiguration // Global settings; its definitions are ignored
+	Definitions      []DefinitionSpec
+	Optimize         bool            // Merge the ranges of each definition
+	Progress         chan<- Progress // Optional; the caller must consume it, or cancel the context
+	ProgressInterval int             // Number of entries between progress updates (1000 by default)
+	Hooks            Hooks           // Optional callbacks to customize the ingestion
+	StrictRanges     bool            // Drop the reversed ranges of the definitions, instead of swapping their boundaries
+}
+
+// Progress describes the current state of a run
+type Progress struct {
+	Definition       int    // Index of the definition being built
+	Source           string // Name of the source being read
+	Processed        int    // Entries read from the source so far
+	SourceDone       bool   // The source has been completely read
+	CompletedSources int
+	TotalSources     int
+}
+
+// SourceStats summarizes the outcome of a source
+type SourceStats struct {
+	Definition int
+	Name       string
+	Processed  int // Entries read
+	Added      int // Entries added to the definition
+	Skipped    int // Invalid, filtered, duplicated, covered or excluded entries
+}
+
+type Report struct {
+	Config             *discovery.DiscoveryConfiguration
+	Sources            []SourceStats
+	EstimatedAddresses *big.Int
+	Duration           time.Duration
+	SwappedRanges      int // Ranges of the definitions with reversed boundaries
+	DroppedRanges      int // Invalid ranges of the definitions, or reversed ranges with StrictRanges
+}
+
+// run holds the state of a single call to Run
+type run struct {
+	opts      *Options
+	completed int
+	total     int
+}
+
+func (r *run) notify(ctx context.Context, p Progress) error {
+	if r.opts.Progress == nil {
+		return ctx.Err()
+	}
+	p.CompletedSources = r.completed
+	p.TotalSources = r.total
+	select {
+	case r.opts.Progress <- p:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Run builds the configuration from the sources of each definition, reporting progress through the channel of the options.
+// It stops as soon as the context is canceled, returning its error. The progress channel is not closed.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	start := time.Now()
+	interval := opts.ProgressInterval
+	if interval <= 0 {
+		interval = 1000
+	}
+	r := &run{opts: &opts}
+	for _, spec := range opts.Definitions {
+		r.total += len(spec.Sources)
+	}
+	cfg := opts.Base
+	cfg.Definitions = nil
+	report := &Report{Config: &cfg, Sources: make([]SourceStats, 0, r.total)}
+	seen := make(map[string]bool) // Specifics must be unique across definitions
+	validator := &discovery.RangeValidator{Strict: opts.StrictRanges}
+
+	for i, spec := range opts.Definitions {
+		def := spec.Definition
+		def.Specifics = append([]discovery.Specific{}, def.Specifics...)
+		def.IncludeRanges, def.ExcludeRanges = nil, nil
+		for _, r := range spec.Definition.IncludeRanges {
+			validator.AddIncludeRange(&def, r.Begin.String(), r.End.String(), r.Attributes())
+		}
+		for _, r := range spec.Definition.ExcludeRanges {
+			validator.AddExcludeRange(&def, r.Begin.String(), r.End.String())
+		}
+		for _, s := range def.Specifics {
+			seen[s.IP.String()] = true
+		}
+
+		// Read everything first, as the exclusions must be known before adding the inclusions
+		entries := make([][]Entry, len(spec.Sources))
+		stats := make([]SourceStats, len(spec.Sources))
+		for j, source := range spec.Sources {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			processed := 0
+			err := source.Read(ctx, func(e Entry) error {
+				info := EntryInfo{Definition: i, Source: source.Name(), Entry: e}
+				if e, ok := opts.Hooks.candidate(info); ok {
+					entries[j] = append(entries[j], e)
+				} else {
+					stats[j].Skipped++
+					opts.Hooks.rejected(info, RejectFiltered)
+				}
+				processed++
+				if processed%interval == 0 {
+					return r.notify(ctx, Progress{Definition: i, Source: source.Name(), Processed: processed})
+				}
+				return ctx.Err()
+			})
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				return nil, fmt.Errorf("cannot read %s: %v", source.Name(), err)
+			}
+			r.completed++
+			if err := r.notify(ctx, Progress{Definition: i, Source: source.Name(), Processed: processed, SourceDone: true}); err != nil {
+				return nil, err
+			}
+		}
+
+		// The exclusions must be applied first, then the ranges, so the redundant specifics can be skipped
+		for j, source := range spec.Sources {
+			stats[j].Definition, stats[j].Name = i, source.Name()
+			stats[j].Processed = len(entries[j]) + stats[j].Skipped
+		}
+		blacklist := make(map[string]bool)
+		apply := func(kind EntryKind, add func(e Entry) RejectReason) error {
+			for j, source := range spec.Sources {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				for _, e := range entries[j] {
+					if e.Kind != kind {
+						continue
+					}
+					info := EntryInfo{Definition: i, Source: source.Name(), Entry: e}
+					if reason := add(e); reason == "" {
+						stats[j].Added++
+						opts.Hooks.accepted(info)
+					} else {
+						stats[j].Skipped++
+						opts.Hooks.rejected(info, reason)
+					}
+				}
+			}
+			return nil
+		}
+		steps := []struct {
+			kind EntryKind
+			add  func(e Entry) RejectReason // Empty when the entry is added
+		}{
+			{ExcludeCIDR, func(e Entry) RejectReason {
+				if _, _, err := net.ParseCIDR(e.Value); err != nil {
+					return RejectInvalid
+				}
+				def.ExcludeCIDR(e.Value)
+				return ""
+			}},
+			{ExcludeIP, func(e Entry) RejectReason {
+				ip := net.ParseIP(e.Value)
+				if ip == nil {
+					return RejectInvalid
+				}
+				blacklist[ip.String()] = true
+				return ""
+			}},
+			{IncludeCIDR, func(e Entry) RejectReason {
+				if _, _, err := net.ParseCIDR(e.Value); err != nil {
+					return RejectInvalid
+				}
+				def.IncludeCIDRWithAttributes(e.Value, e.Attributes)
+				return ""
+			}},
+			{IncludeIP, func(e Entry) RejectReason {
+				ip := net.ParseIP(e.Value)
+				if ip == nil {
+					return RejectInvalid
+				}
+				addr := ip.String()
+				switch {
+				case seen[addr]:
+					return RejectDuplicate
+				case blacklist[addr]:
+					return RejectBlacklisted
+				case def.ExcludeRangesContain(addr):
+					return RejectExcluded
+				case def.IncludeRangesContain(addr):
+					return RejectCovered
+				}
+				seen[addr] = true
+				def.AddSpecificWithAttributes(addr, e.Attributes)
+				return ""
+			}},
+		}
+		for _, step := range steps {
+			if err := apply(step.kind, step.add); err != nil {
+				return nil, err
+			}
+		}
+		if opts.Optimize {
+			def.Merge()
+		} else {
+			def.Sort()
+		}
+		if !def.IsEmpty() {
+			cfg.AddDefinition(def)
+		}
+		report.Sources = append(report.Sources, stats...)
+	}
+
+	if err := opts.Hooks.beforeWrite(&cfg); err != nil {
+		return nil, err
+	}
+	report.EstimatedAddresses = cfg.GetTotalEstimatedAddresses()
+	report.SwappedRanges, report.DroppedRanges = validator.Counts()
+	report.Duration = time.Since(start)
+	return report, nil
+}
@@ -0,0 +1,268 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "ips.txt")
+	if err := ioutil.WriteFile(fileName, []byte("# nodes\n10.0.0.1\n10.0.1.1\n10.0.1.1\n192.168.0.10\nbogus\n\n"), 0644); err != nil {
+		t.Fatalf("cannot create file: %v", err)
+	}
+	opts := Options{
+		Base: discovery.DiscoveryConfiguration{PacketsPerSecond: 10},
+		Definitions: []DefinitionSpec{{
+			Definition: discovery.Definition{Location: "Default"},
+			Sources: []Source{
+				FileSource("ips", fileName, IncludeIP, discovery.Attributes{}),
+				ListSource("cidrs", IncludeCIDR, []string{"10.0.0.0/24"}, discovery.Attributes{}),
+				ListSource("excluded", ExcludeIP, []string{"192.168.0.10"}, discovery.Attributes{}),
+			},
+		}},
+	}
+	report, err := Run(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Config.PacketsPerSecond != 10 || len(report.Config.Definitions) != 1 {
+		t.Fatalf("invalid configuration: %s", report.Config)
+	}
+	def := report.Config.Definitions[0]
+	if len(def.Specifics) != 1 || def.Specifics[0].IP.String() != "10.0.1.1" {
+		t.Errorf("expected only 10.0.1.1 as specific, got %v", def.Specifics)
+	}
+	if len(def.IncludeRanges) != 1 {
+		t.Errorf("expected 1 include range, got %d", len(def.IncludeRanges))
+	}
+	ips := report.Sources[0]
+	if ips.Processed != 5 || ips.Added != 1 || ips.Skipped != 4 {
+		t.Errorf("invalid stats for ips: %+v", ips)
+	}
+	if report.EstimatedAddresses.Int64() != 255 {
+		t.Errorf("expected 255 addresses, got %s", report.EstimatedAddresses)
+	}
+	if len(opts.Definitions[0].Definition.Specifics) != 0 {
+		t.Errorf("the definition of the options should not change")
+	}
+}
+
+func TestRunProgress(t *testing.T) {
+	values := make([]string, 0)
+	for i := 1; i <= 250; i++ {
+		values = append(values, fmt.Sprintf("10.0.%d.%d", i/200, i%200))
+	}
+	progress := make(chan Progress)
+	updates := make([]Progress, 0)
+	done := make(chan bool)
+	go func() {
+		for p := range progress {
+			updates = append(updates, p)
+		}
+		done <- true
+	}()
+	_, err := Run(context.Background(), Options{
+		Definitions: []DefinitionSpec{
+			{Sources: []Source{ListSource("a", IncludeIP, values, discovery.Attributes{})}},
+			{Sources: []Source{ListSource("b", IncludeCIDR, []string{"172.16.0.0/16"}, discovery.Attributes{})}},
+		},
+		Progress:         progress,
+		ProgressInterval: 100,
+	})
+	close(progress)
+	<-done
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(updates) != 4 {
+		t.Fatalf("expected 4 updates, got %d: %+v", len(updates), updates)
+	}
+	if updates[1].Processed != 200 || updates[1].SourceDone {
+		t.Errorf("invalid update: %+v", updates[1])
+	}
+	last := updates[3]
+	if last.Source != "b" || !last.SourceDone || last.CompletedSources != 2 || last.TotalSources != 2 {
+		t.Errorf("invalid last update: %+v", last)
+	}
+}
+
+type blockingSource struct {
+	started chan bool
+}
+
+func (s *blockingSource) Name() string {
+	return "blocking"
+}
+
+func (s *blockingSource) Read(ctx context.Context, emit func(Entry) error) error {
+	close(s.started)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRunCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	source := &blockingSource{started: make(chan bool)}
+	go func() {
+		<-source.started
+		cancel()
+	}()
+	_, err := Run(ctx, Options{Definitions: []DefinitionSpec{{Sources: []Source{source}}}})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	// A progress channel nobody reads must not block a canceled run
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = Run(ctx, Options{
+		Definitions: []DefinitionSpec{{Sources: []Source{ListSource("a", IncludeIP, []string{"10.0.0.1"}, discovery.Attributes{})}}},
+		Progress:    make(chan Progress),
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRunSourceError(t *testing.T) {
+	_, err := Run(context.Background(), Options{
+		Definitions: []DefinitionSpec{{Sources: []Source{FileSource("missing", filepath.Join(os.TempDir(), "missing-file.txt"), IncludeIP, discovery.Attributes{})}}},
+	})
+	if err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
+
+func TestRunReversedRanges(t *testing.T) {
+	def := discovery.Definition{}
+	def.IncludeRanges = []discovery.IncludeRange{
+		{Begin: net.ParseIP("10.0.0.20"), End: net.ParseIP("10.0.0.10"), Location: "Paris"},
+		{Begin: net.ParseIP("10.0.1.1"), End: net.ParseIP("10.0.1.5")},
+	}
+	def.ExcludeRanges = []discovery.ExcludeRange{{Begin: net.ParseIP("10.0.0.12"), End: net.ParseIP("2001:db8::1")}}
+	swapped, dropped := discovery.DefaultRangeValidator.Counts()
+
+	report, err := Run(context.Background(), Options{Definitions: []DefinitionSpec{{Definition: def}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ranges := report.Config.Definitions[0].IncludeRanges
+	if len(ranges) != 2 || ranges[0].Begin.String() != "10.0.0.10" || ranges[0].Location != "Paris" {
+		t.Errorf("the reversed range should be swapped: %v", ranges)
+	}
+	if len(report.Config.Definitions[0].ExcludeRanges) != 0 {
+		t.Errorf("the invalid range should be dropped")
+	}
+	if report.SwappedRanges != 1 || report.DroppedRanges != 1 {
+		t.Errorf("incorrect counters: swapped=%d, dropped=%d", report.SwappedRanges, report.DroppedRanges)
+	}
+
+	report, err = Run(context.Background(), Options{Definitions: []DefinitionSpec{{Definition: def}}, StrictRanges: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Config.Definitions[0].IncludeRanges) != 1 || report.SwappedRanges != 0 || report.DroppedRanges != 2 {
+		t.Errorf("the reversed range should be dropped in strict mode: %+v", report)
+	}
+	if s, d := discovery.DefaultRangeValidator.Counts(); s != swapped || d != dropped {
+		t.Errorf("the runs should not change the global counters")
+	}
+}
+
+func TestRunConcurrently(t *testing.T) {
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			report, err := Run(context.Background(), Options{
+				Definitions: []DefinitionSpec{{Sources: []Source{
+					ListSource("ranges", IncludeCIDR, []string{fmt.Sprintf("10.%d.0.0/24", i)}, discovery.Attributes{}),
+					ListSource("ips", IncludeIP, []string{fmt.Sprintf("10.%d.1.1", i), "192.168.0.1"}, discovery.Attributes{}),
+				}}},
+				Optimize: true,
+			})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			// Runs are independent, so every one of them gets the shared address
+			if n := report.EstimatedAddresses.Int64(); n != 256 {
+				t.Errorf("expected 256 addresses for run %d, got %d", i, n)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestRunHooks(t *testing.T) {
+	accepted := make([]string, 0)
+	rejected := make(map[string]RejectReason)
+	opts := Options{
+		Definitions: []DefinitionSpec{{
+			Definition: discovery.Definition{Location: "Default"},
+			Sources: []Source{
+				ListSource("cidrs", IncludeCIDR, []string{"10.0.0.0/24"}, discovery.Attributes{}),
+				ListSource("ips", IncludeIP, []string{"10.0.0.1", "172.16.0.1", "172.16.0.2", "192.168.0.1", "bogus", "172.16.0.1"}, discovery.Attributes{}),
+			},
+		}},
+		Hooks: Hooks{
+			OnCandidate: func(info EntryInfo) (Entry, bool) {
+				if info.Entry.Value == "192.168.0.1" {
+					return info.Entry, false
+				}
+				if info.Entry.Value == "172.16.0.2" {
+					info.Entry.Attributes.ForeignSource = "Enriched"
+				}
+				return info.Entry, true
+			},
+			OnAccepted: func(info EntryInfo) {
+				accepted = append(accepted, info.Source+":"+info.Entry.Value)
+			},
+			OnRejected: func(info EntryInfo, reason RejectReason) {
+				rejected[info.Entry.Value] = reason
+			},
+			OnBeforeWrite: func(cfg *discovery.DiscoveryConfiguration) error {
+				cfg.Definitions[0].ChunkSize = 10
+				return nil
+			},
+		},
+	}
+	report, err := Run(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(accepted) != "[cidrs:10.0.0.0/24 ips:172.16.0.1 ips:172.16.0.2]" {
+		t.Errorf("unexpected accepted entries: %v", accepted)
+	}
+	expected := map[string]RejectReason{"10.0.0.1": RejectCovered, "192.168.0.1": RejectFiltered, "bogus": RejectInvalid, "172.16.0.1": RejectDuplicate}
+	if fmt.Sprint(rejected) != fmt.Sprint(expected) {
+		t.Errorf("unexpected rejected entries: %v", rejected)
+	}
+	def := report.Config.Definitions[0]
+	if def.ChunkSize != 10 || len(def.Specifics) != 2 || def.GetSpecific("172.16.0.2").ForeignSource != "Enriched" {
+		t.Errorf("the hooks should modify the configuration: %s", report.Config)
+	}
+	if ips := report.Sources[1]; ips.Processed != 6 || ips.Added != 2 || ips.Skipped != 4 {
+		t.Errorf("invalid stats for ips: %+v", ips)
+	}
+
+	opts.Hooks.OnBeforeWrite = func(cfg *discovery.DiscoveryConfiguration) error {
+		return errors.New("rejected by policy")
+	}
+	if _, err := Run(context.Background(), opts); err == nil || err.Error() != "rejected by policy" {
+		t.Errorf("the error of OnBeforeWrite should abort the run: %v", err)
+	}
+}
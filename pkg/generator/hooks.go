@@ -0,0 +1,69 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Hook points for programs embedding the generator, to inject custom filters, enrichment, or metrics
+
+package generator
+
+import (
+	"github.com/agalue/onms-discovery-config/pkg/discovery"
+)
+
+// RejectReason identifies why an entry from a source was not added to the configuration
+type RejectReason string
+
+const (
+	RejectInvalid     RejectReason = "INVALID"          // Not a valid CIDR or IP address
+	RejectFiltered    RejectReason = "FILTERED"         // Skipped by the OnCandidate hook
+	RejectDuplicate   RejectReason = "DUPLICATE"        // IP address already added as a specific
+	RejectBlacklisted RejectReason = "BLACKLISTED"      // IP address excluded by an ExcludeIP entry
+	RejectExcluded    RejectReason = "IN_EXCLUDE_RANGE" // IP address covered by the exclude ranges
+	RejectCovered     RejectReason = "COVERED_BY_RANGE" // IP address covered by the include ranges
+)
+
+// EntryInfo identifies the origin of an entry
+type EntryInfo struct {
+	Definition int    // Index of the definition
+	Source     string // Name of the source
+	Entry      Entry
+}
+
+// Hooks are optional callbacks invoked while the configuration is built; the nil ones are ignored.
+// They are called from the goroutine of Run, in the order of the entries of each source.
+type Hooks struct {
+	// OnCandidate receives every entry read from a source, and returns the entry to apply, which can be modified
+	// (e.x. to enrich its attributes), or false to skip it.
+	OnCandidate func(info EntryInfo) (Entry, bool)
+	// OnAccepted receives every entry added to its definition
+	OnAccepted func(info EntryInfo)
+	// OnRejected receives every entry skipped, with the reason
+	OnRejected func(info EntryInfo, reason RejectReason)
+	// OnBeforeWrite receives the configuration before it is returned, so it can be verified or modified;
+	// an error aborts the run.
+	OnBeforeWrite func(cfg *discovery.DiscoveryConfiguration) error
+}
+
+func (h *Hooks) candidate(info EntryInfo) (Entry, bool) {
+	if h.OnCandidate == nil {
+		return info.Entry, true
+	}
+	return h.OnCandidate(info)
+}
+
+func (h *Hooks) accepted(info EntryInfo) {
+	if h.OnAccepted != nil {
+		h.OnAccepted(info)
+	}
+}
+
+func (h *Hooks) rejected(info EntryInfo, reason RejectReason) {
+	if h.OnRejected != nil {
+		h.OnRejected(info, reason)
+	}
+}
+
+func (h *Hooks) beforeWrite(cfg *discovery.DiscoveryConfiguration) error {
+	if h.OnBeforeWrite == nil {
+		return nil
+	}
+	return h.OnBeforeWrite(cfg)
+}
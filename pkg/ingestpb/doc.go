@@ -0,0 +1,6 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Package ingestpb provides the gRPC service to stream candidate addresses to the daemon, generated from ingest.proto.
+package ingestpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative ingest.proto
@@ -0,0 +1,88 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Address classes (RFC1918, CGN, ULA, public, and special blocks) and the split of a range across them
+
+package iprange
+
+import (
+	"math/big"
+	"net"
+)
+
+// AddressClass represents the address space of a range
+type AddressClass string
+
+const (
+	ClassRFC1918 AddressClass = "RFC1918"
+	ClassCGN     AddressClass = "CGN"     // Carrier-grade NAT, 100.64.0.0/10
+	ClassPublic  AddressClass = "PUBLIC"  // IPv4 addresses not covered by any other class
+	ClassULA     AddressClass = "ULA"     // IPv6 unique local addresses, fc00::/7
+	ClassGUA     AddressClass = "GUA"     // IPv6 global unicast addresses, 2000::/3
+	ClassSpecial AddressClass = "SPECIAL" // Loopback, link-local, multicast, and other reserved blocks
+)
+
+var addressClasses = []struct {
+	class AddressClass
+	cidr  string
+}{
+	{ClassRFC1918, "10.0.0.0/8"},
+	{ClassRFC1918, "172.16.0.0/12"},
+	{ClassRFC1918, "192.168.0.0/16"},
+	{ClassCGN, "100.64.0.0/10"},
+	{ClassSpecial, "0.0.0.0/8"},
+	{ClassSpecial, "127.0.0.0/8"},
+	{ClassSpecial, "169.254.0.0/16"},
+	{ClassSpecial, "224.0.0.0/3"}, // Multicast and reserved
+	{ClassULA, "fc00::/7"},
+	{ClassGUA, "2000::/3"},
+}
+
+// classBlocks are the ranges of the address classes
+var classBlocks = func() []IPAddressRange {
+	blocks := make([]IPAddressRange, 0, len(addressClasses))
+	for _, c := range addressClasses {
+		_, network, _ := net.ParseCIDR(c.cidr)
+		last := make(net.IP, len(network.IP))
+		for i := range network.IP {
+			last[i] = network.IP[i] | ^network.Mask[i]
+		}
+		blocks = append(blocks, IPAddressRange{Begin: network.IP, End: last, Location: string(c.class)})
+	}
+	return blocks
+}()
+
+// Classify returns the number of addresses of the range on each class
+func (r *IPAddressRange) Classify() map[AddressClass]*big.Int {
+	classes := make(map[AddressClass]*big.Int)
+	remaining := r.Size()
+	for _, block := range classBlocks {
+		if (block.Begin.To4() == nil) != (r.Begin.To4() == nil) || !r.Overlaps(block) {
+			continue
+		}
+		overlap := IPAddressRange{Begin: block.Begin, End: block.End}
+		if IP2Int(r.Begin).Cmp(IP2Int(overlap.Begin)) > 0 {
+			overlap.Begin = r.Begin
+		}
+		if IP2Int(r.End).Cmp(IP2Int(overlap.End)) < 0 {
+			overlap.End = r.End
+		}
+		class := AddressClass(block.Location)
+		if classes[class] == nil {
+			classes[class] = big.NewInt(0)
+		}
+		size := overlap.Size()
+		classes[class].Add(classes[class], size)
+		remaining.Sub(remaining, size)
+	}
+	if remaining.Sign() > 0 {
+		class := ClassSpecial
+		if r.Begin.To4() != nil {
+			class = ClassPublic
+		}
+		if classes[class] == nil {
+			classes[class] = big.NewInt(0)
+		}
+		classes[class].Add(classes[class], remaining)
+	}
+	return classes
+}
@@ -0,0 +1,317 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Code inspired by:
+// https://github.com/OpenNMS/opennms/blob/master/core/api/src/main/java/org/opennms/core/network/IPAddressRangeSet.java
+// https://github.com/OpenNMS/opennms/blob/master/core/api/src/main/java/org/opennms/core/network/IPAddressRange.java
+
+package iprange
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+)
+
+// IPAddressRangeSet represents a sorted collection of non-overlapping IP address ranges.
+// The ranges are sorted by family (IPv4 first) and address, so lookups use binary search.
+type IPAddressRangeSet struct {
+	ipRanges []IPAddressRange
+}
+
+// search returns the index of the first range that doesn't end before the given address
+func (r *IPAddressRangeSet) search(ip net.IP) int {
+	return sort.Search(len(r.ipRanges), func(i int) bool { return Compare(r.ipRanges[i].End, ip) >= 0 })
+}
+
+// splice replaces the ranges between i (inclusive) and j (exclusive) with the given ones, in place
+func (r *IPAddressRangeSet) splice(i, j int, ranges ...IPAddressRange) {
+	size := len(r.ipRanges)
+	if delta := len(ranges) - (j - i); delta > 0 {
+		r.ipRanges = append(r.ipRanges, ranges[:delta]...) // Room for the new ranges
+		copy(r.ipRanges[j+delta:], r.ipRanges[j:size])
+	} else if delta < 0 {
+		copy(r.ipRanges[j+delta:], r.ipRanges[j:])
+		r.ipRanges = r.ipRanges[:size+delta]
+	}
+	copy(r.ipRanges[i:], ranges)
+}
+
+// Contains returns true if the IP address is part of any range of the set
+func (r *IPAddressRangeSet) Contains(ip net.IP) bool {
+	i := r.search(ip)
+	return i < len(r.ipRanges) && Compare(r.ipRanges[i].Begin, ip) <= 0
+}
+
+// Add adds a range to the set, combining it with the existing ranges with the same attributes when possible.
+// The addresses already covered by ranges with different attributes are carved out of the new range,
+// so the attributes of the ranges added first win, and the ones of the new range are never lost.
+func (r *IPAddressRangeSet) Add(ipr IPAddressRange) {
+	parts := []IPAddressRange{ipr}
+	for i := r.search(ipr.Begin); i < len(r.ipRanges) && Compare(r.ipRanges[i].Begin, ipr.End) <= 0; i++ {
+		n := r.ipRanges[i]
+		if n.SameAttributes(ipr) {
+			continue
+		}
+		remaining := make([]IPAddressRange, 0, len(parts)+1)
+		for _, p := range parts {
+			remaining = append(remaining, p.Remove(n)...)
+		}
+		parts = remaining
+	}
+	for _, p := range parts {
+		r.insert(p)
+	}
+}
+
+// insert adds a range that doesn't overlap ranges with different attributes, combining it with the ones it overlaps or joins
+func (r *IPAddressRangeSet) insert(ipr IPAddressRange) {
+	i := r.search(ipr.Begin)
+	if i > 0 && r.ipRanges[i-1].Combinable(ipr) {
+		i--
+	}
+	j := i
+	for j < len(r.ipRanges) && r.ipRanges[j].Combinable(ipr) {
+		ipr = r.ipRanges[j].Combine(ipr)
+		j++
+	}
+	r.splice(i, j, ipr)
+}
+
+// Union adds all the ranges of the given set at once, merging both sorted sets in a single pass instead of inserting
+// the ranges one by one, which moves the following ranges of the set on each insert. Like Add, the addresses already
+// covered by ranges with different attributes are carved out of the new ranges.
+func (r *IPAddressRangeSet) Union(other *IPAddressRangeSet) {
+	added := make([]IPAddressRange, 0, len(other.ipRanges))
+	i := 0
+	for _, ipr := range other.ipRanges {
+		for i < len(r.ipRanges) && Compare(r.ipRanges[i].End, ipr.Begin) < 0 {
+			i++
+		}
+		parts := []IPAddressRange{ipr}
+		for j := i; j < len(r.ipRanges) && Compare(r.ipRanges[j].Begin, ipr.End) <= 0; j++ {
+			if n := r.ipRanges[j]; !n.SameAttributes(ipr) {
+				remaining := make([]IPAddressRange, 0, len(parts)+1)
+				for _, p := range parts {
+					remaining = append(remaining, p.Remove(n)...)
+				}
+				parts = remaining
+			}
+		}
+		added = append(added, parts...)
+	}
+	ranges := make([]IPAddressRange, 0, len(r.ipRanges)+len(added))
+	appendRange := func(ipr IPAddressRange) {
+		if last := len(ranges) - 1; last >= 0 && ranges[last].Combinable(ipr) {
+			ranges[last] = ranges[last].Combine(ipr)
+			return
+		}
+		ranges = append(ranges, ipr)
+	}
+	i = 0
+	for _, ipr := range added {
+		for i < len(r.ipRanges) && Compare(r.ipRanges[i].Begin, ipr.Begin) <= 0 {
+			appendRange(r.ipRanges[i])
+			i++
+		}
+		appendRange(ipr)
+	}
+	for ; i < len(r.ipRanges); i++ {
+		appendRange(r.ipRanges[i])
+	}
+	r.ipRanges = ranges
+}
+
+// Remove carves the given range out of the ranges of the set, splitting them when necessary
+func (r *IPAddressRangeSet) Remove(ipr IPAddressRange) {
+	i := r.search(ipr.Begin)
+	j := i
+	ranges := make([]IPAddressRange, 0, 2)
+	for j < len(r.ipRanges) && Compare(r.ipRanges[j].Begin, ipr.End) <= 0 {
+		ranges = append(ranges, r.ipRanges[j].Remove(ipr)...)
+		j++
+	}
+	r.splice(i, j, ranges...)
+}
+
+// Subtract carves all the ranges of the given set out of the ranges of this set
+func (r *IPAddressRangeSet) Subtract(other *IPAddressRangeSet) {
+	for _, ipr := range other.ipRanges {
+		r.Remove(ipr)
+	}
+}
+
+// Intersect keeps only the parts of the ranges of this set covered by the given set, keeping their attributes
+func (r *IPAddressRangeSet) Intersect(other *IPAddressRangeSet) {
+	ranges := make([]IPAddressRange, 0)
+	for _, n := range r.ipRanges {
+		for i := other.search(n.Begin); i < len(other.ipRanges) && Compare(other.ipRanges[i].Begin, n.End) <= 0; i++ {
+			if common, ok := n.Intersect(other.ipRanges[i]); ok {
+				ranges = append(ranges, common)
+			}
+		}
+	}
+	r.ipRanges = ranges
+}
+
+// Get returns the ranges of the set
+func (r *IPAddressRangeSet) Get() []IPAddressRange {
+	return r.ipRanges
+}
+
+// IPAddressRange represents a range of IP addresses
+type IPAddressRange struct {
+	Begin         net.IP
+	End           net.IP
+	Location      string
+	Retries       int
+	Timeout       int
+	ForeignSource string
+}
+
+// Combine returns the range that covers both ranges
+func (r *IPAddressRange) Combine(ipr IPAddressRange) IPAddressRange {
+	minIP := r.Begin
+	if Compare(ipr.Begin, r.Begin) < 0 {
+		minIP = ipr.Begin
+	}
+
+	maxIP := r.End
+	if Compare(ipr.End, r.End) > 0 {
+		maxIP = ipr.End
+	}
+
+	return IPAddressRange{
+		Begin:         minIP,
+		End:           maxIP,
+		Location:      r.Location,
+		Timeout:       r.Timeout,
+		Retries:       r.Retries,
+		ForeignSource: r.ForeignSource,
+	}
+}
+
+// Remove returns the parts of the range not covered by the given range, keeping its attributes
+func (r *IPAddressRange) Remove(ipr IPAddressRange) []IPAddressRange {
+	if !r.Overlaps(ipr) || (r.Begin.To4() == nil) != (ipr.Begin.To4() == nil) {
+		return []IPAddressRange{*r}
+	}
+	ranges := make([]IPAddressRange, 0, 2)
+	if Compare(r.Begin, ipr.Begin) < 0 {
+		lower := *r
+		lower.End = Offset(ipr.Begin, -1)
+		ranges = append(ranges, lower)
+	}
+	if Compare(r.End, ipr.End) > 0 {
+		upper := *r
+		upper.Begin = Offset(ipr.End, 1)
+		ranges = append(ranges, upper)
+	}
+	return ranges
+}
+
+// Intersect returns the part of the range covered by the given range, keeping its attributes,
+// or false when they don't overlap
+func (r *IPAddressRange) Intersect(ipr IPAddressRange) (IPAddressRange, bool) {
+	if !r.Overlaps(ipr) || (r.Begin.To4() == nil) != (ipr.Begin.To4() == nil) {
+		return IPAddressRange{}, false
+	}
+	common := *r
+	if Compare(ipr.Begin, r.Begin) > 0 {
+		common.Begin = ipr.Begin
+	}
+	if Compare(ipr.End, r.End) < 0 {
+		common.End = ipr.End
+	}
+	return common, true
+}
+
+// Combinable returns true if the ranges overlap or are adjacent, and they have the same attributes
+func (r *IPAddressRange) Combinable(ipr IPAddressRange) bool {
+	return r.SameAttributes(ipr) && (r.Overlaps(ipr) || r.AdjacentJoins(ipr))
+}
+
+// SameAttributes returns true if the ranges have the same location, foreign source, retries and timeout
+func (r *IPAddressRange) SameAttributes(ipr IPAddressRange) bool {
+	return r.Location == ipr.Location && r.ForeignSource == ipr.ForeignSource && r.Retries == ipr.Retries && r.Timeout == ipr.Timeout
+}
+
+// Contains returns true if the IP address is part of the range
+func (r *IPAddressRange) Contains(ip net.IP) bool {
+	a := ToAddr(ip)
+	return a.Compare(ToAddr(r.Begin)) >= 0 && a.Compare(ToAddr(r.End)) <= 0
+}
+
+// Overlaps returns true if the ranges share at least one address
+func (r *IPAddressRange) Overlaps(ipr IPAddressRange) bool {
+	return Compare(r.Begin, ipr.End) <= 0 && Compare(ipr.Begin, r.End) <= 0
+}
+
+// ComesBefore returns true if the range ends before the given one begins
+func (r *IPAddressRange) ComesBefore(ipr IPAddressRange) bool {
+	return Compare(r.End, ipr.Begin) < 0
+}
+
+// ComesAfter returns true if the range begins after the given one ends
+func (r *IPAddressRange) ComesAfter(ipr IPAddressRange) bool {
+	return Compare(r.Begin, ipr.End) > 0
+}
+
+// AdjacentJoins returns true if the ranges are next to each other
+func (r *IPAddressRange) AdjacentJoins(ipr IPAddressRange) bool {
+	return r.comesImmediatelyBefore(ipr) || r.comesImmediatelyAfter(ipr)
+}
+
+// Size returns the number of addresses of the range
+func (r *IPAddressRange) Size() *big.Int {
+	return Count(ToAddr(r.Begin), ToAddr(r.End))
+}
+
+// IsSingleton returns true if the range contains a single address
+func (r *IPAddressRange) IsSingleton() bool {
+	return r.Begin.Equal(r.End)
+}
+
+// Equal returns true if both ranges have the same boundaries
+func (r *IPAddressRange) Equal(ipr IPAddressRange) bool {
+	return r.Begin.Equal(ipr.Begin) && r.End.Equal(ipr.End)
+}
+
+// String returns a human readable version of the range
+func (r *IPAddressRange) String() string {
+	return fmt.Sprintf("%s -> %s", r.Begin, r.End)
+}
+
+func (r *IPAddressRange) comesImmediatelyAfter(ipr IPAddressRange) bool {
+	return r.ComesAfter(ipr) && r.isSuccessorOf(r.Begin, ipr.End)
+}
+
+func (r *IPAddressRange) comesImmediatelyBefore(ipr IPAddressRange) bool {
+	return r.ComesBefore(ipr) && r.isPredecessorOf(r.End, ipr.Begin)
+}
+
+func (r *IPAddressRange) isSuccessorOf(a, b net.IP) bool {
+	return ToAddr(b).Next() == ToAddr(a)
+}
+
+func (r *IPAddressRange) isPredecessorOf(a, b net.IP) bool {
+	return ToAddr(b).Prev() == ToAddr(a)
+}
+
+// Offset returns the address located delta positions away from the given one, within the same address family
+func Offset(ip net.IP, delta int64) net.IP {
+	result, _ := CheckedOffset(ip, delta)
+	return result
+}
+
+// CheckedOffset is like Offset, but returns false when the result wraps around the address space
+func CheckedOffset(ip net.IP, delta int64) (net.IP, bool) {
+	a := ToAddr(ip)
+	u := addrToUint128(a)
+	result := u.add(int64To128(delta))
+	ok := (delta >= 0) == (result.cmp(u) >= 0)
+	if a.Is4() {
+		ok = result.hi == 0 && result.lo <= 0xffffffff
+	}
+	return FromAddr(uint128ToAddr(result, a.Is4())), ok
+}
@@ -0,0 +1,339 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package iprange
+
+import (
+	"math/big"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestContains(t *testing.T) {
+	r := IPAddressRange{Begin: net.ParseIP("192.168.0.1"), End: net.ParseIP("192.168.0.10")}
+	if !r.Contains(net.ParseIP("192.168.0.5")) {
+		t.Errorf("range should contain 192.168.0.5")
+	}
+	if r.Contains(net.ParseIP("192.168.10.5")) {
+		t.Errorf("range should not contain 192.168.10.5")
+	}
+}
+
+func TestComesAfter(t *testing.T) {
+	a := IPAddressRange{Begin: net.ParseIP("192.168.1.1"), End: net.ParseIP("192.168.1.2")}
+	b := IPAddressRange{Begin: net.ParseIP("192.168.1.3"), End: net.ParseIP("192.168.1.4")}
+	c := IPAddressRange{Begin: net.ParseIP("192.168.1.5"), End: net.ParseIP("192.168.1.6")}
+	if !b.ComesAfter(a) {
+		t.Errorf("range b should come after a")
+	}
+	if b.ComesAfter(c) {
+		t.Errorf("range b should come before c")
+	}
+}
+
+func TestComesBefore(t *testing.T) {
+	a := IPAddressRange{Begin: net.ParseIP("192.168.1.1"), End: net.ParseIP("192.168.1.2")}
+	b := IPAddressRange{Begin: net.ParseIP("192.168.1.3"), End: net.ParseIP("192.168.1.4")}
+	c := IPAddressRange{Begin: net.ParseIP("192.168.1.5"), End: net.ParseIP("192.168.1.6")}
+	if b.ComesBefore(a) {
+		t.Errorf("range b should come after a")
+	}
+	if !b.ComesBefore(c) {
+		t.Errorf("range b should come before c")
+	}
+}
+
+func TestCombine(t *testing.T) {
+	a := IPAddressRange{Begin: net.ParseIP("192.168.1.10"), End: net.ParseIP("192.168.1.20")}
+	b := IPAddressRange{Begin: net.ParseIP("192.168.1.15"), End: net.ParseIP("192.168.1.25")}
+	c := a.Combine(b)
+	if c.Begin.String() != "192.168.1.10" || c.End.String() != "192.168.1.25" {
+		t.Errorf("Invalid range: %v", c)
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	a := IPAddressRange{Begin: net.ParseIP("192.168.1.10"), End: net.ParseIP("192.168.1.20")}
+	b := IPAddressRange{Begin: net.ParseIP("192.168.1.10"), End: net.ParseIP("192.168.1.10")}
+	c := IPAddressRange{Begin: net.ParseIP("192.168.1.9"), End: net.ParseIP("192.168.1.22")}
+	d := IPAddressRange{Begin: net.ParseIP("192.168.1.21"), End: net.ParseIP("192.168.1.22")}
+	if !a.Overlaps(b) {
+		t.Errorf("range b should overlaps a")
+	}
+	if !a.Overlaps(c) {
+		t.Errorf("range c should overlaps a")
+	}
+	if a.Overlaps(d) {
+		t.Errorf("range d should not overlaps a")
+	}
+}
+
+func TestIPAddressRangeSet(t *testing.T) {
+	r := new(IPAddressRangeSet)
+	// Add first range
+	r.Add(IPAddressRange{Begin: net.ParseIP("192.168.0.1"), End: net.ParseIP("192.168.0.10")})
+	// Add non-overlapping second range
+	r.Add(IPAddressRange{Begin: net.ParseIP("192.168.10.1"), End: net.ParseIP("192.168.10.10")})
+	// Expand second range
+	r.Add(IPAddressRange{Begin: net.ParseIP("192.168.10.1"), End: net.ParseIP("192.168.10.25")})
+	// Add specific from first range
+	r.Add(IPAddressRange{Begin: net.ParseIP("192.168.0.5"), End: net.ParseIP("192.168.0.5")})
+	// Add specific from second range
+	r.Add(IPAddressRange{Begin: net.ParseIP("192.168.10.5"), End: net.ParseIP("192.168.10.5")})
+	// Add multiple specifcs from another range
+	r.Add(IPAddressRange{Begin: net.ParseIP("172.16.0.11"), End: net.ParseIP("172.16.0.11")})
+	r.Add(IPAddressRange{Begin: net.ParseIP("172.16.0.12"), End: net.ParseIP("172.16.0.12")})
+	r.Add(IPAddressRange{Begin: net.ParseIP("172.16.0.13"), End: net.ParseIP("172.16.0.13")})
+	r.Add(IPAddressRange{Begin: net.ParseIP("172.16.0.14"), End: net.ParseIP("172.16.0.14")})
+	// We should get 3 ranges
+	ranges := r.Get()
+	if len(ranges) != 3 {
+		t.Errorf("we got an invalid number of ranges: %v", ranges)
+	}
+	if !ranges[0].Equal(IPAddressRange{Begin: net.ParseIP("172.16.0.11"), End: net.ParseIP("172.16.0.14")}) {
+		t.Errorf("invaid first range: %s", ranges[0].String())
+	}
+	if !ranges[1].Equal(IPAddressRange{Begin: net.ParseIP("192.168.0.1"), End: net.ParseIP("192.168.0.10")}) {
+		t.Errorf("invaid second range: %s", ranges[1].String())
+	}
+	if !ranges[2].Equal(IPAddressRange{Begin: net.ParseIP("192.168.10.1"), End: net.ParseIP("192.168.10.25")}) {
+		t.Errorf("invaid third range: %s", ranges[2].String())
+	}
+}
+
+func TestIPAddressRangeSetWithAttributes(t *testing.T) {
+	r := new(IPAddressRangeSet)
+	r.Add(IPAddressRange{Begin: net.ParseIP("10.0.0.10"), End: net.ParseIP("10.0.0.20"), Location: "Apex"})
+	r.Add(IPAddressRange{Begin: net.ParseIP("10.0.0.21"), End: net.ParseIP("10.0.0.21"), Location: "Lab"})    // Adjacent, different location
+	r.Add(IPAddressRange{Begin: net.ParseIP("10.0.0.22"), End: net.ParseIP("10.0.0.22"), Location: "Lab"})    // Joins the previous one
+	r.Add(IPAddressRange{Begin: net.ParseIP("10.0.0.1"), End: net.ParseIP("10.0.0.30"), Retries: 2})          // Overlaps both
+	r.Add(IPAddressRange{Begin: net.ParseIP("10.0.0.15"), End: net.ParseIP("10.0.0.15"), ForeignSource: "X"}) // Already covered
+	ranges := r.Get()
+	expected := []struct {
+		begin, end string
+		attrs      IPAddressRange
+	}{
+		{"10.0.0.1", "10.0.0.9", IPAddressRange{Retries: 2}},
+		{"10.0.0.10", "10.0.0.20", IPAddressRange{Location: "Apex"}},
+		{"10.0.0.21", "10.0.0.22", IPAddressRange{Location: "Lab"}},
+		{"10.0.0.23", "10.0.0.30", IPAddressRange{Retries: 2}},
+	}
+	if len(ranges) != len(expected) {
+		t.Fatalf("we got an invalid number of ranges: %v", ranges)
+	}
+	for i, e := range expected {
+		if ranges[i].Begin.String() != e.begin || ranges[i].End.String() != e.end || !ranges[i].SameAttributes(e.attrs) {
+			t.Errorf("invalid range %d: %s %+v", i, ranges[i].String(), ranges[i])
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	r := new(IPAddressRangeSet)
+	r.Add(IPAddressRange{Begin: net.ParseIP("192.168.0.1"), End: net.ParseIP("192.168.0.254"), Location: "Apex"})
+	r.Add(IPAddressRange{Begin: net.ParseIP("10.0.0.1"), End: net.ParseIP("10.0.0.10")})
+	r.Remove(IPAddressRange{Begin: net.ParseIP("192.168.0.100"), End: net.ParseIP("192.168.0.199")}) // Splits the range
+	r.Remove(IPAddressRange{Begin: net.ParseIP("192.168.0.250"), End: net.ParseIP("192.168.1.10")})  // Trims the end
+	r.Remove(IPAddressRange{Begin: net.ParseIP("10.0.0.1"), End: net.ParseIP("10.0.0.10")})          // Removes the range
+	r.Remove(IPAddressRange{Begin: net.ParseIP("2001:db8::1"), End: net.ParseIP("2001:db8::10")})    // Another family
+	ranges := r.Get()
+	if len(ranges) != 2 {
+		t.Fatalf("we got an invalid number of ranges: %v", ranges)
+	}
+	if !ranges[0].Equal(IPAddressRange{Begin: net.ParseIP("192.168.0.1"), End: net.ParseIP("192.168.0.99")}) || ranges[0].Location != "Apex" {
+		t.Errorf("invalid first range: %s", ranges[0].String())
+	}
+	if !ranges[1].Equal(IPAddressRange{Begin: net.ParseIP("192.168.0.200"), End: net.ParseIP("192.168.0.249")}) {
+		t.Errorf("invalid second range: %s", ranges[1].String())
+	}
+}
+
+func TestSubtractAndIntersect(t *testing.T) {
+	build := func(ranges ...IPAddressRange) *IPAddressRangeSet {
+		set := new(IPAddressRangeSet)
+		for _, ipr := range ranges {
+			set.Add(ipr)
+		}
+		return set
+	}
+	newRange := func(begin, end string) IPAddressRange {
+		return IPAddressRange{Begin: net.ParseIP(begin), End: net.ParseIP(end)}
+	}
+	toString := func(set *IPAddressRangeSet) string {
+		ranges := make([]string, 0)
+		for _, ipr := range set.Get() {
+			ranges = append(ranges, ipr.String()+" "+ipr.Location)
+		}
+		return strings.Join(ranges, ", ")
+	}
+	scope := func() *IPAddressRangeSet {
+		lab := newRange("10.0.0.1", "10.0.0.100")
+		lab.Location = "Lab"
+		return build(lab, newRange("10.0.1.1", "10.0.1.10"), newRange("2001:db8::1", "2001:db8::ff"))
+	}
+	other := build(newRange("10.0.0.50", "10.0.0.60"), newRange("10.0.0.90", "10.0.1.5"), newRange("2001:db8::10", "2001:db8::1f"))
+
+	s := scope()
+	s.Subtract(other)
+	expected := "10.0.0.1 -> 10.0.0.49 Lab, 10.0.0.61 -> 10.0.0.89 Lab, 10.0.1.6 -> 10.0.1.10 , 2001:db8::1 -> 2001:db8::f , 2001:db8::20 -> 2001:db8::ff "
+	if toString(s) != expected {
+		t.Errorf("incorrect subtraction: %s", toString(s))
+	}
+
+	s = scope()
+	s.Intersect(other)
+	expected = "10.0.0.50 -> 10.0.0.60 Lab, 10.0.0.90 -> 10.0.0.100 Lab, 10.0.1.1 -> 10.0.1.5 , 2001:db8::10 -> 2001:db8::1f "
+	if toString(s) != expected {
+		t.Errorf("incorrect intersection: %s", toString(s))
+	}
+
+	s = scope()
+	s.Intersect(build(newRange("192.168.0.1", "192.168.0.10")))
+	if len(s.Get()) != 0 {
+		t.Errorf("the intersection should be empty: %s", toString(s))
+	}
+	ipv4 := newRange("10.0.0.1", "10.0.0.10")
+	if _, ok := ipv4.Intersect(newRange("2001:db8::1", "2001:db8::10")); ok {
+		t.Errorf("ranges of different families should not intersect")
+	}
+}
+
+func TestIPAddressRangeSetContains(t *testing.T) {
+	set := new(IPAddressRangeSet)
+	bounds := make([][2]int64, 0)
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		begin, size := rnd.Int63n(1<<24), rnd.Int63n(256)
+		bounds = append(bounds, [2]int64{begin, begin + size})
+		ipr := IPAddressRange{Begin: IntToIP(big.NewInt(begin), true), End: IntToIP(big.NewInt(begin+size), true)}
+		if i%3 == 0 {
+			ipr.Location = "Lab"
+		}
+		set.Add(ipr)
+	}
+	set.Add(IPAddressRange{Begin: net.ParseIP("2001:db8::1"), End: net.ParseIP("2001:db8::ff")})
+	result := set.Get()
+	for i := 1; i < len(result); i++ {
+		if !result[i-1].ComesBefore(result[i]) {
+			t.Fatalf("the ranges should be sorted and non-overlapping: %s and %s", result[i-1].String(), result[i].String())
+		}
+	}
+	for i := 0; i < 20000; i++ {
+		n := rnd.Int63n(1 << 24)
+		ip := IntToIP(big.NewInt(n), true)
+		expected := false
+		for _, b := range bounds {
+			if n >= b[0] && n <= b[1] {
+				expected = true
+				break
+			}
+		}
+		if set.Contains(ip) != expected {
+			t.Fatalf("unexpected membership of %s: %v", ip, !expected)
+		}
+	}
+	if !set.Contains(net.ParseIP("2001:db8::10")) || set.Contains(net.ParseIP("2001:db8::100")) {
+		t.Errorf("unexpected membership of IPv6 addresses")
+	}
+}
+
+func TestIPAddressRangeSetUnion(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		first, second := new(IPAddressRangeSet), new(IPAddressRangeSet)
+		for i, r := range randomRanges(2000, seed) {
+			if i%3 == 0 {
+				r.Location = "Lab"
+			}
+			if i%2 == 0 {
+				first.Add(r)
+			} else {
+				second.Add(r)
+			}
+		}
+		expected := new(IPAddressRangeSet)
+		for _, r := range append(append([]IPAddressRange{}, first.Get()...), second.Get()...) {
+			expected.Add(r)
+		}
+		first.Union(second)
+		if len(first.Get()) != len(expected.Get()) {
+			t.Fatalf("seed %d: expected %d ranges, got %d", seed, len(expected.Get()), len(first.Get()))
+		}
+		for i, r := range first.Get() {
+			if e := expected.Get()[i]; !r.Equal(e) || !r.SameAttributes(e) {
+				t.Fatalf("seed %d: expected %s (%s), got %s (%s)", seed, e.String(), e.Location, r.String(), r.Location)
+			}
+		}
+	}
+}
+
+// randomRanges returns IPv4 and IPv6 ranges of up to 256 addresses, always the same for the given seed
+func randomRanges(count int, seed int64) []IPAddressRange {
+	rnd := rand.New(rand.NewSource(seed))
+	ranges := make([]IPAddressRange, 0, count)
+	for i := 0; i < count; i++ {
+		ipv4 := i%4 != 0
+		n := big.NewInt(rnd.Int63n(1 << 24))
+		if !ipv4 {
+			n.Add(n, IP2Int(net.ParseIP("2001:db8::")))
+		}
+		begin := IntToIP(n, ipv4)
+		ranges = append(ranges, IPAddressRange{Begin: begin, End: Offset(begin, rnd.Int63n(256))})
+	}
+	return ranges
+}
+
+func BenchmarkIPAddressRangeSetAdd(b *testing.B) {
+	ranges := randomRanges(10000, 1)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		set := new(IPAddressRangeSet)
+		for _, r := range ranges {
+			set.Add(r)
+		}
+	}
+}
+
+func BenchmarkIPAddressRangeSetUnion(b *testing.B) {
+	base, other := new(IPAddressRangeSet), new(IPAddressRangeSet)
+	for _, r := range randomRanges(10000, 1) {
+		base.Add(r)
+	}
+	for _, r := range randomRanges(10000, 2) {
+		other.Add(r)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		set := &IPAddressRangeSet{ipRanges: append([]IPAddressRange{}, base.ipRanges...)}
+		set.Union(other)
+	}
+}
+
+func BenchmarkIPAddressRangeSetContains(b *testing.B) {
+	set := new(IPAddressRangeSet)
+	for _, r := range randomRanges(10000, 1) {
+		set.Add(r)
+	}
+	addresses := make([]net.IP, 0)
+	for _, r := range randomRanges(1000, 2) {
+		addresses = append(addresses, r.End)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, ip := range addresses {
+			set.Contains(ip)
+		}
+	}
+}
+
+func BenchmarkRangeToCIDRs(b *testing.B) {
+	ranges := randomRanges(1000, 1)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, r := range ranges {
+			RangeToCIDRs(r)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Package iprange provides the arithmetic for IPv4 and IPv6 addresses and ranges used to build discovery configurations.
+package iprange
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+)
+
+// Mask2Int returns the integer representation of a network mask
+func Mask2Int(ipmask net.IPMask) *big.Int {
+	ip := big.NewInt(0)
+	ip.SetBytes(ipmask)
+	return ip
+}
+
+// IP2Int returns the integer representation of an IP address
+func IP2Int(ipaddr net.IP) *big.Int {
+	ip := big.NewInt(0)
+	if ipaddr.To4() == nil { // Ipv6
+		ip.SetBytes(ipaddr.To16())
+	} else {
+		ip.SetBytes(ipaddr.To4())
+	}
+	return ip
+}
+
+// Int2IP returns the IP address of an integer using its minimal byte representation (see IntToIP)
+func Int2IP(ipaddr *big.Int) net.IP {
+	return net.IP(ipaddr.Bytes())
+}
+
+// IntToIP converts an integer into an IPv4 address (4 bytes) or an IPv6 address (16 bytes)
+func IntToIP(ipaddr *big.Int, ipv4 bool) net.IP {
+	size := net.IPv6len
+	if ipv4 {
+		size = net.IPv4len
+	}
+	buf := make([]byte, size)
+	ipaddr.FillBytes(buf)
+	return net.IP(buf)
+}
+
+// ParseRange parses a CIDR (including the network and broadcast addresses), a range (begin-end), or a single IP address
+func ParseRange(spec string) (IPAddressRange, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.Contains(spec, "/") {
+		_, network, err := net.ParseCIDR(spec)
+		if err != nil {
+			return IPAddressRange{}, fmt.Errorf("invalid CIDR %s", spec)
+		}
+		last := make(net.IP, len(network.IP))
+		for i := range network.IP {
+			last[i] = network.IP[i] | ^network.Mask[i]
+		}
+		return IPAddressRange{Begin: network.IP, End: last}, nil
+	}
+	pair := strings.SplitN(spec, "-", 2)
+	if len(pair) == 1 {
+		pair = append(pair, pair[0])
+	}
+	begin, end := net.ParseIP(strings.TrimSpace(pair[0])), net.ParseIP(strings.TrimSpace(pair[1]))
+	if begin == nil || end == nil || (begin.To4() == nil) != (end.To4() == nil) || Compare(end, begin) < 0 {
+		return IPAddressRange{}, fmt.Errorf("invalid range %s", spec)
+	}
+	if begin.To4() != nil {
+		begin, end = begin.To4(), end.To4()
+	}
+	return IPAddressRange{Begin: begin, End: end}, nil
+}
+
+// RangeToCIDRs returns the smallest list of CIDRs that cover exactly the given range
+func RangeToCIDRs(r IPAddressRange) []string {
+	bits := 128
+	if r.Begin.To4() != nil {
+		bits = 32
+	}
+	begin, end := addrToUint128(ToAddr(r.Begin)), addrToUint128(ToAddr(r.End))
+	cidrs := make([]string, 0)
+	for begin.cmp(end) <= 0 {
+		size := begin.trailingZeros() // The largest block aligned with begin that doesn't go beyond end
+		if size > bits {
+			size = bits
+		}
+		last := begin.add(lowMask(size))
+		for last.cmp(end) > 0 {
+			size--
+			last = begin.add(lowMask(size))
+		}
+		cidrs = append(cidrs, fmt.Sprintf("%s/%d", uint128ToAddr(begin, bits == 32).String(), bits-size))
+		if last == end {
+			break // Avoids wrapping around after the last address
+		}
+		begin = last.add(uint128{0, 1})
+	}
+	return cidrs
+}
+
+// lowMask returns the integer with the lowest n bits set, which is the size of a block of 2^n addresses minus one
+func lowMask(n int) uint128 {
+	switch {
+	case n >= 128:
+		return uint128{^uint64(0), ^uint64(0)}
+	case n >= 64:
+		return uint128{1<<uint(n-64) - 1, ^uint64(0)}
+	}
+	return uint128{0, 1<<uint(n) - 1}
+}
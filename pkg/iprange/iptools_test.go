@@ -1,6 +1,6 @@
 // Author: Alejandro galue <agalue@opennms.org>
 
-package main
+package iprange
 
 import (
 	"log"
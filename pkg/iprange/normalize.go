@@ -0,0 +1,97 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Normalization of the IP addresses and CIDRs found in real exports, which are not always in canonical form
+
+package iprange
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// trimValue removes the surrounding spaces and quotes, and the trailing punctuation inside or outside the quotes;
+// e.x. "10.0.0.1", or 10.0.0.1;
+func trimValue(s string) string {
+	s = strings.TrimRight(strings.TrimSpace(s), ".,;")
+	return strings.TrimRight(strings.Trim(s, `"'`), ".,;")
+}
+
+// normalizeIPv4 removes the leading zeros of the octets of an IPv4 address, which are always decimal (not octal);
+// e.x. 010.001.002.003 is 10.1.2.3. Other values are returned as they are.
+func normalizeIPv4(s string) string {
+	octets := strings.Split(s, ".")
+	if len(octets) != 4 {
+		return s
+	}
+	for i, o := range octets {
+		n, err := strconv.ParseUint(o, 10, 8)
+		if err != nil || o == "" || strings.ContainsAny(o, "+-") {
+			return s
+		}
+		octets[i] = strconv.FormatUint(n, 10)
+	}
+	return strings.Join(octets, ".")
+}
+
+// NormalizeIP returns the canonical form of an IP address, applying the following rules in order:
+// surrounding spaces and quotes and trailing dots, commas and semicolons are removed;
+// a port suffix is removed, either from an IPv4 address (1.2.3.4:161) or a bracketed IPv6 address ([2001:db8::1]:161);
+// the zone of an IPv6 address is removed (fe80::1%eth0);
+// the leading zeros of the octets of an IPv4 address are removed, as they are decimal (010.001.002.003 is 10.1.2.3);
+// IPv6 addresses are lowercase and compressed, and IPv4-mapped IPv6 addresses become IPv4.
+func NormalizeIP(s string) (string, error) {
+	value := trimValue(s)
+	if strings.HasPrefix(value, "[") {
+		end := strings.Index(value, "]")
+		if end < 0 {
+			return "", fmt.Errorf("invalid IP address %s", s)
+		}
+		if port := value[end+1:]; port != "" && !isPort(strings.TrimPrefix(port, ":")) {
+			return "", fmt.Errorf("invalid port in IP address %s", s)
+		}
+		value = value[1:end]
+	} else if strings.Count(value, ":") == 1 {
+		idx := strings.Index(value, ":")
+		if !strings.Contains(value[:idx], ".") || !isPort(value[idx+1:]) {
+			return "", fmt.Errorf("invalid IP address %s", s)
+		}
+		value = value[:idx]
+	}
+	if idx := strings.Index(value, "%"); idx > 0 && strings.Contains(value, ":") {
+		value = value[:idx]
+	}
+	ip := net.ParseIP(normalizeIPv4(value))
+	if ip == nil {
+		return "", fmt.Errorf("invalid IP address %s", s)
+	}
+	return ip.String(), nil
+}
+
+// NormalizeCIDR returns the canonical form of a CIDR, using the rules of NormalizeIP for the address (except the port suffix)
+func NormalizeCIDR(s string) (string, error) {
+	value := trimValue(s)
+	idx := strings.Index(value, "/")
+	if idx < 0 {
+		return "", fmt.Errorf("invalid CIDR %s", s)
+	}
+	prefix, err := strconv.Atoi(value[idx+1:])
+	if err != nil || prefix < 0 {
+		return "", fmt.Errorf("invalid prefix in CIDR %s", s)
+	}
+	ip := net.ParseIP(normalizeIPv4(value[:idx]))
+	if ip == nil {
+		return "", fmt.Errorf("invalid CIDR %s", s)
+	}
+	cidr := fmt.Sprintf("%s/%d", ip.String(), prefix)
+	if _, _, err := net.ParseCIDR(cidr); err != nil {
+		return "", fmt.Errorf("invalid CIDR %s", s)
+	}
+	return cidr, nil
+}
+
+func isPort(s string) bool {
+	n, err := strconv.ParseUint(s, 10, 16)
+	return err == nil && n > 0
+}
@@ -0,0 +1,65 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package iprange
+
+import (
+	"testing"
+)
+
+func TestNormalizeIP(t *testing.T) {
+	cases := map[string]string{
+		"10.0.0.1":                "10.0.0.1",
+		" 10.0.0.1 ":              "10.0.0.1",
+		`"10.0.0.1"`:              "10.0.0.1",
+		"10.0.0.1;":               "10.0.0.1",
+		"10.0.0.1.":               "10.0.0.1",
+		"10.0.0.1,":               "10.0.0.1",
+		"010.001.002.003":         "10.1.2.3",
+		"192.168.000.010":         "192.168.0.10",
+		"1.2.3.4:161":             "1.2.3.4",
+		"001.002.003.004:161":     "1.2.3.4",
+		"[2001:db8::1]:161":       "2001:db8::1",
+		"[2001:DB8::1]":           "2001:db8::1",
+		"2001:0DB8:0000::0001":    "2001:db8::1",
+		"2001:db8::":              "2001:db8::",
+		"fe80::1%eth0":            "fe80::1",
+		"::ffff:192.168.0.1":      "192.168.0.1",
+		"'2001:db8::1',":          "2001:db8::1",
+		"[2001:db8::1]:161;":      "2001:db8::1",
+		"10.000.000.001:65535":    "10.0.0.1",
+		"0000000010.0.0.1":        "10.0.0.1",
+		"2001:db8:0:0:0:0:0:1":    "2001:db8::1",
+		"2001:db8::1%25en0":       "2001:db8::1",
+		"10.0.0.255":              "10.0.0.255",
+		"   [fe80::1%eth0]:8080 ": "fe80::1",
+	}
+	for value, expected := range cases {
+		if ip, err := NormalizeIP(value); err != nil || ip != expected {
+			t.Errorf("unexpected normalization of %q: %s %v", value, ip, err)
+		}
+	}
+	for _, value := range []string{"", "10.0.0.256", "10.0.0", "10.0.0.1:port", "10.0.0.1:0", "10.0.0.1:70000", "[2001:db8::1", "[2001:db8::1]x", "host:161", "+10.0.0.1", "10.-0.0.1", "2001:db8::g"} {
+		if ip, err := NormalizeIP(value); err == nil {
+			t.Errorf("%q should be invalid, got %s", value, ip)
+		}
+	}
+}
+
+func TestNormalizeCIDR(t *testing.T) {
+	cases := map[string]string{
+		"10.0.0.0/24":        "10.0.0.0/24",
+		"010.000.000.000/08": "10.0.0.0/8",
+		"192.168.1.0/24;":    "192.168.1.0/24",
+		`"2001:DB8::/64"`:    "2001:db8::/64",
+	}
+	for value, expected := range cases {
+		if cidr, err := NormalizeCIDR(value); err != nil || cidr != expected {
+			t.Errorf("unexpected normalization of %q: %s %v", value, cidr, err)
+		}
+	}
+	for _, value := range []string{"10.0.0.0", "10.0.0.0/33", "10.0.0.0/-1", "10.0.0.0/x", "2001:db8::/129"} {
+		if cidr, err := NormalizeCIDR(value); err == nil {
+			t.Errorf("%q should be invalid, got %s", value, cidr)
+		}
+	}
+}
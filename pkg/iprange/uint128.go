@@ -0,0 +1,112 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+// Allocation-free arithmetic for IP addresses, using netip.Addr and 128-bit integers instead of big.Int
+
+package iprange
+
+import (
+	"math/big"
+	"math/bits"
+	"net"
+	"net/netip"
+)
+
+// uint128 is an unsigned 128-bit integer, big enough for any IPv4 or IPv6 address
+type uint128 struct {
+	hi, lo uint64
+}
+
+func (u uint128) cmp(v uint128) int {
+	switch {
+	case u.hi < v.hi || (u.hi == v.hi && u.lo < v.lo):
+		return -1
+	case u == v:
+		return 0
+	}
+	return 1
+}
+
+// add returns u+v, wrapping around on overflow
+func (u uint128) add(v uint128) uint128 {
+	lo, carry := bits.Add64(u.lo, v.lo, 0)
+	return uint128{u.hi + v.hi + carry, lo}
+}
+
+// sub returns u-v, wrapping around on underflow
+func (u uint128) sub(v uint128) uint128 {
+	lo, borrow := bits.Sub64(u.lo, v.lo, 0)
+	return uint128{u.hi - v.hi - borrow, lo}
+}
+
+// trailingZeros returns the number of trailing zero bits, or 128 for zero
+func (u uint128) trailingZeros() int {
+	if u.lo != 0 {
+		return bits.TrailingZeros64(u.lo)
+	}
+	return 64 + bits.TrailingZeros64(u.hi)
+}
+
+// big returns the value as a big.Int
+func (u uint128) big() *big.Int {
+	n := new(big.Int).SetUint64(u.hi)
+	n.Lsh(n, 64)
+	return n.Or(n, new(big.Int).SetUint64(u.lo))
+}
+
+// int64To128 returns the two's complement of n, so adding it subtracts when n is negative
+func int64To128(n int64) uint128 {
+	if n < 0 {
+		return uint128{^uint64(0), uint64(n)}
+	}
+	return uint128{0, uint64(n)}
+}
+
+// addrToUint128 returns the integer representation of an address; IPv4 addresses use the lowest 32 bits
+func addrToUint128(a netip.Addr) uint128 {
+	if a.Is4() {
+		b := a.As4()
+		return uint128{0, uint64(b[0])<<24 | uint64(b[1])<<16 | uint64(b[2])<<8 | uint64(b[3])}
+	}
+	b := a.As16()
+	var u uint128
+	for i := 0; i < 8; i++ {
+		u.hi = u.hi<<8 | uint64(b[i])
+		u.lo = u.lo<<8 | uint64(b[i+8])
+	}
+	return u
+}
+
+// uint128ToAddr returns the IPv4 (using the lowest 32 bits) or IPv6 address of an integer
+func uint128ToAddr(u uint128, ipv4 bool) netip.Addr {
+	if ipv4 {
+		return netip.AddrFrom4([4]byte{byte(u.lo >> 24), byte(u.lo >> 16), byte(u.lo >> 8), byte(u.lo)})
+	}
+	var b [16]byte
+	for i := 0; i < 8; i++ {
+		b[7-i] = byte(u.hi >> (8 * i))
+		b[15-i] = byte(u.lo >> (8 * i))
+	}
+	return netip.AddrFrom16(b)
+}
+
+// Count returns the number of addresses from begin to end, both included, which must be of the same family
+func Count(begin, end netip.Addr) *big.Int {
+	n := addrToUint128(end).sub(addrToUint128(begin)).big()
+	return n.Add(n, big.NewInt(1))
+}
+
+// ToAddr converts an IP address into a netip.Addr, using the IPv4 form of the IPv4-mapped IPv6 addresses like net.IP.To4
+func ToAddr(ip net.IP) netip.Addr {
+	a, _ := netip.AddrFromSlice(ip)
+	return a.Unmap()
+}
+
+// FromAddr converts a netip.Addr into an IP address of 4 bytes for IPv4, or 16 bytes for IPv6
+func FromAddr(a netip.Addr) net.IP {
+	return net.IP(a.AsSlice())
+}
+
+// Compare orders the addresses by family (IPv4 first) and then by value, returning -1, 0 or 1
+func Compare(a, b net.IP) int {
+	return ToAddr(a).Compare(ToAddr(b))
+}
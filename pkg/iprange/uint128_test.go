@@ -0,0 +1,86 @@
+// Author: Alejandro galue <agalue@opennms.org>
+
+package iprange
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestUint128Conversion(t *testing.T) {
+	for _, value := range []string{"0.0.0.0", "10.0.0.1", "255.255.255.255", "::", "2001:db8::1", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"} {
+		ip := net.ParseIP(value)
+		a := ToAddr(ip)
+		if n := addrToUint128(a); n.big().Cmp(IP2Int(ip)) != 0 || uint128ToAddr(n, a.Is4()) != a {
+			t.Errorf("incorrect conversion of %s: %s", value, n.big())
+		}
+	}
+	if a := ToAddr(net.ParseIP("::ffff:10.0.0.1")); !a.Is4() || FromAddr(a).String() != "10.0.0.1" || len(FromAddr(a)) != net.IPv4len {
+		t.Errorf("IPv4-mapped addresses should be IPv4: %s", a)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	ordered := []string{"0.0.0.0", "10.0.0.1", "10.0.0.2", "255.255.255.255", "::", "::a00:1", "2001:db8::1"}
+	for i := 1; i < len(ordered); i++ {
+		a, b := net.ParseIP(ordered[i-1]), net.ParseIP(ordered[i])
+		if Compare(a, b) != -1 || Compare(b, a) != 1 || Compare(a, a) != 0 {
+			t.Errorf("%s should come before %s", a, b)
+		}
+	}
+	if Compare(net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.1").To4()) != 0 {
+		t.Errorf("the length of an IPv4 address should not matter")
+	}
+}
+
+func TestOffsetAndSize(t *testing.T) {
+	cases := []struct {
+		ip       string
+		delta    int64
+		expected string
+		wraps    bool
+	}{
+		{"10.0.0.255", 1, "10.0.1.0", false},
+		{"10.0.1.0", -1, "10.0.0.255", false},
+		{"255.255.255.255", 1, "0.0.0.0", true},
+		{"0.0.0.1", -2, "255.255.255.255", true},
+		{"2001:db8::ffff:ffff:ffff:ffff", 1, "2001:db8:0:1::", false},
+		{"2001:db8:0:1::", -1, "2001:db8::ffff:ffff:ffff:ffff", false},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", 1, "::", true},
+		{"::", -1, "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", true},
+	}
+	for _, c := range cases {
+		if ip := Offset(net.ParseIP(c.ip), c.delta); ip.String() != c.expected {
+			t.Errorf("%s %+d should be %s, got %s", c.ip, c.delta, c.expected, ip)
+		}
+		if _, ok := CheckedOffset(net.ParseIP(c.ip), c.delta); ok == c.wraps {
+			t.Errorf("%s %+d should wrap around: %v", c.ip, c.delta, c.wraps)
+		}
+	}
+	if n := Count(ToAddr(net.ParseIP("10.0.0.1")), ToAddr(net.ParseIP("10.0.1.0"))); n.Int64() != 256 {
+		t.Errorf("unexpected count: %s", n)
+	}
+	all := IPAddressRange{Begin: net.ParseIP("::"), End: net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")}
+	if size := all.Size(); size.BitLen() != 129 || size.TrailingZeroBits() != 128 {
+		t.Errorf("the whole IPv6 space should have 2^128 addresses: %s", size)
+	}
+}
+
+func TestRangeToCIDRsBoundaries(t *testing.T) {
+	cases := map[string]string{
+		"0.0.0.0-255.255.255.255":                       "0.0.0.0/0",
+		"::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff":    "::/0",
+		"255.255.255.254-255.255.255.255":               "255.255.255.254/31",
+		"2001:db8::ffff:ffff:ffff:ffff-2001:db8:0:1::1": "2001:db8::ffff:ffff:ffff:ffff/128, 2001:db8:0:1::/127",
+	}
+	for spec, expected := range cases {
+		r, err := ParseRange(spec)
+		if err != nil {
+			t.Fatalf("cannot parse %s: %v", spec, err)
+		}
+		if cidrs := strings.Join(RangeToCIDRs(r), ", "); cidrs != expected {
+			t.Errorf("unexpected CIDRs for %s: %s", spec, cidrs)
+		}
+	}
+}